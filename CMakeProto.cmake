@@ -26,6 +26,7 @@ protoc_go("github.com/google/gapid/core/os/android/apk" "core/os/android/apk" "a
 protoc_go("github.com/google/gapid/core/os/android" "core/os/android" "keycodes.proto")
 protoc_go("github.com/google/gapid/core/os/device/bind" "core/os/device/bind" "bind.proto")
 protoc_go("github.com/google/gapid/core/os/device" "core/os/device" "device.proto")
+protoc_go("github.com/google/gapid/core/os/device/remote" "core/os/device/remote" "remote.proto")
 protoc_java("core/os/device" "device.proto" "com/google/gapid/proto/device/Device")
 protoc_cc("core/os/device" "core/os/device" "device.proto")
 protoc_go("github.com/google/gapid/core/stream" "core/stream" "stream.proto")
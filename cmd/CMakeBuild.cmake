@@ -20,6 +20,7 @@ build_subdirectory(embed)
 build_subdirectory(filehash)
 build_subdirectory(font-gen)
 build_subdirectory(gapir)
+build_subdirectory(gapir-agent)
 build_subdirectory(gapis)
 build_subdirectory(gapit)
 build_subdirectory(gopherjs)
@@ -29,6 +30,7 @@ build_subdirectory(perf)
 build_subdirectory(protoc-gen-go)
 build_subdirectory(pullapk)
 build_subdirectory(robot)
+build_subdirectory(sign-apk)
 build_subdirectory(shadertool)
 build_subdirectory(stash)
 build_subdirectory(stringgen)
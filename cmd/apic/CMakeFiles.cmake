@@ -18,7 +18,11 @@
 # build and the file will be recreated, check in the new version.
 
 set(files
+    diff.go
+    docs.go
     format.go
+    graph.go
+    import.go
     main.go
     template.go
     validate.go
@@ -0,0 +1,70 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package diff registers and implements the "diff" apic command.
+//
+// The diff command compares two versions of an API, reporting the changes
+// that break compatibility with captures made using the older version.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/gapil"
+	"github.com/google/gapid/gapil/compat"
+)
+
+func init() {
+	app.AddVerb(&app.Verb{
+		Name:      "diff",
+		ShortHelp: "Reports breaking changes between two versions of an api file",
+		Action:    &diffVerb{},
+	})
+}
+
+type diffVerb struct {
+	Breaking bool `help:"only report breaking changes"`
+}
+
+func (v *diffVerb) Run(ctx context.Context, flags flag.FlagSet) error {
+	args := flags.Args()
+	if len(args) != 2 {
+		app.Usage(ctx, "Expected the old and new api files, got %d arguments", len(args))
+		return nil
+	}
+	oldName, newName := args[0], args[1]
+	old, errs := gapil.NewProcessor().Resolve(oldName)
+	if err := gapil.CheckErrors(oldName, errs, maxErrors); err != nil {
+		return err
+	}
+	new, errs := gapil.NewProcessor().Resolve(newName)
+	if err := gapil.CheckErrors(newName, errs, maxErrors); err != nil {
+		return err
+	}
+
+	changes := compat.Compare(old, new)
+	for _, c := range changes {
+		if c.Breaking || !v.Breaking {
+			fmt.Fprintln(os.Stdout, c)
+		}
+	}
+	if c := changes.Breaking(); c > 0 {
+		return fmt.Errorf("%d breaking changes found", c)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package docs registers and implements the "docs" apic command.
+//
+// The docs command generates browsable reference documentation for an API.
+package main
+
+import (
+	"context"
+	"flag"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/gapil/docs"
+)
+
+func init() {
+	app.AddVerb(&app.Verb{
+		Name:      "docs",
+		ShortHelp: "Generates HTML or markdown reference documentation for an api file",
+		Action:    &docsVerb{Format: "html"},
+	})
+}
+
+type docsVerb struct {
+	Format string `help:"The output format, either html or markdown"`
+	Out    string `help:"The file to write, defaults to stdout"`
+}
+
+func (v *docsVerb) Run(ctx context.Context, flags flag.FlagSet) error {
+	args := flags.Args()
+	if len(args) != 1 {
+		app.Usage(ctx, "Expected a single api file, got %d arguments", len(args))
+		return nil
+	}
+	var format docs.Format
+	switch v.Format {
+	case "html":
+		format = docs.HTML
+	case "markdown", "md":
+		format = docs.Markdown
+	default:
+		app.Usage(ctx, "Unknown format '%s'", v.Format)
+		return nil
+	}
+
+	api, accesses, err := analyzeAPI(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	out, closeOut, err := createOutput(v.Out)
+	if err != nil {
+		return err
+	}
+	defer closeOut()
+	return docs.Write(out, api, accesses, format)
+}
@@ -0,0 +1,91 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package graph registers and implements the "graph" apic command.
+//
+// The graph command exports the subroutine calls and state accesses of the
+// commands of an API as a Graphviz DOT or JSON graph.
+package main
+
+import (
+	"context"
+	"flag"
+	"regexp"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/app/flags"
+	"github.com/google/gapid/gapil/graph"
+)
+
+func init() {
+	app.AddVerb(&app.Verb{
+		Name:      "graph",
+		ShortHelp: "Exports the call and state access graph of an api file",
+		Action:    &graphVerb{Format: "dot"},
+	})
+}
+
+type graphVerb struct {
+	Format string        `help:"The output format, either dot or json"`
+	Out    string        `help:"The file to write, defaults to stdout"`
+	Match  flags.Strings `help:"Only include commands with a name matching this pattern (repeatable)"`
+}
+
+func (v *graphVerb) Run(ctx context.Context, flags flag.FlagSet) error {
+	args := flags.Args()
+	if len(args) != 1 {
+		app.Usage(ctx, "Expected a single api file, got %d arguments", len(args))
+		return nil
+	}
+	if v.Format != "dot" && v.Format != "json" {
+		app.Usage(ctx, "Unknown format '%s'", v.Format)
+		return nil
+	}
+	patterns := make([]*regexp.Regexp, len(v.Match))
+	for i, m := range v.Match {
+		re, err := regexp.Compile(m)
+		if err != nil {
+			app.Usage(ctx, "Invalid pattern '%s': %v", m, err)
+			return nil
+		}
+		patterns[i] = re
+	}
+	var filter func(string) bool
+	if len(patterns) > 0 {
+		filter = func(name string) bool {
+			for _, re := range patterns {
+				if re.MatchString(name) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	api, accesses, err := analyzeAPI(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	g := graph.Build(api, accesses, filter)
+
+	out, closeOut, err := createOutput(v.Out)
+	if err != nil {
+		return err
+	}
+	defer closeOut()
+	if v.Format == "json" {
+		return g.WriteJSON(out)
+	}
+	return g.WriteDot(out)
+}
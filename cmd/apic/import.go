@@ -0,0 +1,79 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package import registers and implements the "import" apic command.
+//
+// The import command generates api file stubs for the commands, types and
+// enums of Khronos features and extensions that are missing from an API.
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/app/flags"
+	"github.com/google/gapid/gapil"
+	"github.com/google/gapid/gapil/khronos"
+)
+
+func init() {
+	app.AddVerb(&app.Verb{
+		Name:      "import",
+		ShortHelp: "Generates api stubs from a Khronos XML registry",
+		Action:    &importVerb{API: "gles2"},
+	})
+}
+
+type importVerb struct {
+	Ext flags.Strings `help:"A feature or extension to import, such as GL_EXT_texture_border_clamp"`
+	API string        `help:"The registry api to import, such as gles2 or vulkan"`
+	Out string        `help:"The api file to write, defaults to stdout"`
+}
+
+func (v *importVerb) Run(ctx context.Context, flags flag.FlagSet) error {
+	args := flags.Args()
+	if len(args) != 2 {
+		app.Usage(ctx, "Expected the registry xml and api files, got %d arguments", len(args))
+		return nil
+	}
+	if len(v.Ext) == 0 {
+		app.Usage(ctx, "At least one feature or extension must be given with -ext")
+		return nil
+	}
+	regName, apiName := args[0], args[1]
+
+	f, err := os.Open(regName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	reg, err := khronos.Load(f)
+	if err != nil {
+		return err
+	}
+
+	target, errs := gapil.NewProcessor().Resolve(apiName)
+	if err := gapil.CheckErrors(apiName, errs, maxErrors); err != nil {
+		return err
+	}
+
+	out, closeOut, err := createOutput(v.Out)
+	if err != nil {
+		return err
+	}
+	defer closeOut()
+	return khronos.Import(reg, target, v.API, v.Ext.Strings(), out)
+}
@@ -14,7 +14,17 @@
 
 package main
 
-import "github.com/google/gapid/core/app"
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapil"
+	"github.com/google/gapid/gapil/analysis"
+	"github.com/google/gapid/gapil/semantic"
+)
 
 const maxErrors = 10
 
@@ -23,3 +33,28 @@ func main() {
 	app.Version = app.VersionSpec{Major: 0, Minor: 1}
 	app.Run(app.VerbMain)
 }
+
+// analyzeAPI resolves the api file apiName and analyzes the state accessed by
+// its commands.
+func analyzeAPI(ctx context.Context, apiName string) (*semantic.API, map[*semantic.Function]*analysis.StateAccess, error) {
+	processor := gapil.NewProcessor()
+	api, errs := processor.Resolve(apiName)
+	if err := gapil.CheckErrors(apiName, errs, maxErrors); err != nil {
+		return nil, nil, err
+	}
+	log.I(ctx, "Analyzing %v", apiName)
+	return api, analysis.Analyze(api, processor.Mappings).Accesses, nil
+}
+
+// createOutput returns the writer for the output file path, or stdout if path
+// is empty. The returned function closes the file.
+func createOutput(path string) (io.Writer, func(), error) {
+	if path == "" {
+		return os.Stdout, func() {}, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, func() { f.Close() }, nil
+}
@@ -0,0 +1,44 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"net"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/app/auth"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device/remote"
+)
+
+var (
+	rpc       = flag.String("rpc", "localhost:0", "TCP host:port of the agent's RPC listener")
+	authToken = flag.String("auth-token", "", "The connection authorization token for the agent. Required unless listening on localhost")
+)
+
+func main() {
+	app.ShortHelp = "gapir-agent exposes this host as a remote replay device for GAPIS."
+	app.Run(run)
+}
+
+func run(ctx context.Context) error {
+	l, err := net.Listen("tcp", *rpc)
+	if err != nil {
+		return log.Err(ctx, err, "Could not start listening")
+	}
+	return remote.Serve(ctx, l, auth.Token(*authToken))
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"flag"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"google.golang.org/grpc/grpclog"
@@ -29,6 +30,7 @@ import (
 	"github.com/google/gapid/core/os/android/adb"
 	"github.com/google/gapid/core/os/device/bind"
 	"github.com/google/gapid/core/os/device/host"
+	"github.com/google/gapid/core/os/device/remote"
 	"github.com/google/gapid/core/os/file"
 	"github.com/google/gapid/core/text"
 	"github.com/google/gapid/gapir/client"
@@ -41,7 +43,9 @@ import (
 
 var (
 	rpc             = flag.String("rpc", "localhost:0", "TCP host:port of the server's RPC listener")
+	httpAddr        = flag.String("http", "", "TCP host:port of the server's HTTP/JSON gateway; leave empty to disable")
 	stringsPath     = flag.String("strings", "strings", "Directory containing string table packages")
+	defaultLocale   = flag.String("default-locale", stringtable.DefaultCultureCode, "Culture code of the string table used for messages missing from other string tables")
 	persist         = flag.Bool("persist", false, "Server will keep running even when no connections remain")
 	gapisAuthToken  = flag.String("gapis-auth-token", "", "The connection authorization token for gapis")
 	gapirAuthToken  = flag.String("gapir-auth-token", "", "The connection authorization token for gapir")
@@ -50,6 +54,10 @@ var (
 	addLocalDevice  = flag.Bool("add-local-device", true, "Server will create a new local replay device")
 	idleTimeout     = flag.Duration("idle-timeout", 0, "Closes GAPIS if the server is not repeatedly pinged within this duration")
 	adbPath         = flag.String("adb", "", "Path to the adb executable; leave empty to search the environment")
+	remoteDevices   = flag.String("remote-devices", "", "Comma-separated list of TCP host:port addresses of remote device agents")
+	remoteAuthToken = flag.String("remote-auth-token", "", "The connection authorization token for the remote device agents")
+	sessionBudget   = flag.Uint64("session-memory-budget", 0, "Maximum number of bytes of data each client session can store; 0 is unlimited")
+	sessionTimeout  = flag.Duration("session-idle-timeout", time.Hour, "Closes client sessions that are not used within this duration; 0 never closes them")
 )
 
 func main() {
@@ -98,6 +106,11 @@ func run(ctx context.Context) error {
 		onDeviceScanDone(ctx)
 	}
 
+	if *remoteDevices != "" {
+		addrs := strings.Split(*remoteDevices, ",")
+		go monitorRemoteDevices(ctx, r, addrs)
+	}
+
 	if *addLocalDevice {
 		host := bind.Host(ctx)
 		r.AddDevice(ctx, host)
@@ -112,10 +125,15 @@ func run(ctx context.Context) error {
 			Features:     features,
 		},
 		StringTables:   loadStrings(ctx),
+		DefaultLocale:  *defaultLocale,
 		AuthToken:      auth.Token(*gapisAuthToken),
 		DeviceScanDone: deviceScanDone,
 		LogBroadcaster: logBroadcaster,
 		IdleTimeout:    *idleTimeout,
+		HTTPAddr:       *httpAddr,
+
+		SessionMemoryBudget: *sessionBudget,
+		SessionIdleTimeout:  *sessionTimeout,
 	})
 }
 
@@ -136,6 +154,12 @@ func monitorAndroidDevices(ctx context.Context, r *bind.Registry, onDeviceScanDo
 	}
 }
 
+func monitorRemoteDevices(ctx context.Context, r *bind.Registry, addrs []string) {
+	if err := remote.Monitor(ctx, r, addrs, auth.Token(*remoteAuthToken), time.Second*3); err != nil {
+		log.W(ctx, "Could not monitor remote devices. Error: %v", err)
+	}
+}
+
 func loadStrings(ctx context.Context) []*stringtable.StringTable {
 	files, err := filepath.Glob(filepath.Join(*stringsPath, "*.stb"))
 	if err != nil {
@@ -26,9 +26,14 @@ set(files
     flags.go
     info.go
     inputs.go
+    logs.go
     main.go
+    memory.go
     packages.go
+    reduce_shader.go
     report.go
+    sessions.go
+    shader_stats.go
     state.go
     stresstest.go
     sxs_video.go
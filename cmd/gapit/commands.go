@@ -34,6 +34,7 @@ type commandsVerb struct{ CommandsFlags }
 func init() {
 	verb := &commandsVerb{}
 	verb.Context = -1
+	verb.Thread = -1
 	app.AddVerb(&app.Verb{
 		Name:      "commands",
 		ShortHelp: "Prints the command tree for a .gfxtrace file",
@@ -75,6 +76,7 @@ func (verb *commandsVerb) Run(ctx context.Context, flags flag.FlagSet) error {
 	treePath.GroupByDrawCall = true
 	treePath.GroupByFrame = true
 	treePath.GroupByUserMarkers = true
+	treePath.GroupBySubmission = verb.Submissions
 
 	boxedTree, err := client.Get(ctx, treePath.Path())
 	if err != nil {
@@ -40,6 +40,13 @@ func (f CommandFilterFlags) commandFilter(ctx context.Context, client service.Se
 		}
 		filter.Context = contexts.(*service.Contexts).List[f.Context].Id
 	}
+	if f.Thread >= 0 {
+		threads, err := client.Get(ctx, p.Threads().Path())
+		if err != nil {
+			return nil, log.Err(ctx, err, "Failed to load the threads")
+		}
+		filter.Thread = threads.(*service.Threads).List[f.Thread].Id
+	}
 	return filter, nil
 }
 
@@ -61,9 +68,10 @@ func getGapis(ctx context.Context, gapisFlags GapisFlags, gapirFlags GapirFlags)
 		token = auth.Token(gapisFlags.Token)
 	}
 	client, err := client.Connect(ctx, client.Config{
-		Port:  gapisFlags.Port,
-		Args:  args,
-		Token: token,
+		Port:    gapisFlags.Port,
+		Args:    args,
+		Token:   token,
+		Session: gapisFlags.Session,
 	})
 	if err != nil {
 		return nil, log.Err(ctx, err, "Failed to connect to the GAPIS server")
@@ -17,6 +17,8 @@ package main
 import (
 	"time"
 
+	"github.com/google/gapid/core/app/flags"
+	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/core/os/file"
 )
 
@@ -69,6 +71,7 @@ func (v PackagesOutput) String() string {
 type (
 	CommandFilterFlags struct {
 		Context int `help:"Filter to the i'th context."`
+		Thread  int `help:"Filter to the i'th thread."`
 	}
 	ObservationFlags struct {
 		Ranges bool `help:"if true then display the read and write ranges made by each command."`
@@ -85,6 +88,7 @@ type (
 		Port    int    `help:"gapis tcp port to connect to, 0 means start new instance."`
 		Args    string `help:"The arguments to be passed to gapis"`
 		Token   string `help:"The auth token to use when connecting to an existing server."`
+		Session string `help:"The session to use when connecting to an existing server, as created by the sessions verb."`
 	}
 	GapirFlags struct {
 		DeviceFlags
@@ -96,9 +100,10 @@ type (
 	InfoFlags struct {
 	}
 	ReportFlags struct {
-		Gapis GapisFlags
-		Gapir GapirFlags
-		Out   string `help:"output report path"`
+		Gapis  GapisFlags
+		Gapir  GapirFlags
+		Out    string        `help:"output report path"`
+		Locale flags.Strings `help:"preferred culture code of the report messages (repeatable); defaults to $LANG"`
 		CommandFilterFlags
 	}
 	VideoFlags struct {
@@ -135,6 +140,7 @@ type (
 		Gapir        GapirFlags
 		Raw          bool   `help:"if true then the value of constants, instead of their names, will be dumped."`
 		Name         string `help:"Filter to commands and groups with the specified name."`
+		Submissions  bool   `help:"if true then queue submissions are expanded into the commands they execute."`
 		Observations ObservationFlags
 		CommandFilterFlags
 	}
@@ -143,6 +149,44 @@ type (
 		Gapir GapirFlags
 		At    int `help:"command index to get the state after."`
 	}
+	MemoryFlags struct {
+		Gapis GapisFlags
+		Gapir GapirFlags
+		At    int `help:"command index to get the memory allocations after."`
+	}
+	ShaderStatsFlags struct {
+		Gapis GapisFlags
+		Gapir GapirFlags
+		At    int `help:"command index to get the shaders after."`
+	}
+	ReduceShaderFlags struct {
+		Gapis     GapisFlags
+		Gapir     GapirFlags
+		At        int    `help:"command index of the draw call to reduce the shader for."`
+		Shader    string `help:"handle of the shader resource to reduce."`
+		Device    int    `help:"index of the device which renders the draw call incorrectly, as listed by 'gapit devices'."`
+		Reference int    `help:"index of the device which renders the draw call correctly, as listed by 'gapit devices'."`
+		Threshold int    `help:"largest color channel difference which is not considered a diff."`
+		Out       string `help:"output path for the reduced shader source. Printed to stdout if empty."`
+		Max       struct {
+			Width  int `help:"maximum width of the compared images"`
+			Height int `help:"maximum height of the compared images"`
+		}
+	}
+	LogsFlags struct {
+		Gapis GapisFlags
+		Level log.Severity  `help:"the minimum severity of the messages to show"`
+		Style log.Style     `help:"the style to use when printing the messages"`
+		JSON  bool          `help:"if true then the messages are printed as JSON lines"`
+		Tags  flags.Strings `help:"only show messages with a tag matching this pattern (repeatable)"`
+		Hide  flags.Strings `help:"hide messages with a tag matching this pattern (repeatable)"`
+	}
+	SessionsFlags struct {
+		Gapis  GapisFlags
+		Create bool `help:"create a new session, which is kept open when gapit exits."`
+		Close  bool `help:"close the session given by the gapis session flag."`
+		Purge  bool `help:"purge the cached data of the session given by the gapis session flag."`
+	}
 	StressTestFlags struct {
 		Gapis GapisFlags
 		Gapir GapirFlags
@@ -199,6 +243,7 @@ type (
 		DeviceFlags
 		Icons       bool           `help:"if true then package icons are also dumped."`
 		IconDensity float64        `help:"scale multiplier on icon density."`
+		Resources   bool           `help:"if true then each package's APK is pulled to report its label, version name and icon files."`
 		Format      PackagesOutput `help:"output format"`
 		Out         string         `help:"output file, standard output if none"`
 		DataHeader  string         `help:"marker to write before package data"`
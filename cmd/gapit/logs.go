@@ -0,0 +1,73 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/log"
+)
+
+type logsVerb struct{ LogsFlags }
+
+func init() {
+	verb := &logsVerb{LogsFlags{
+		Level: log.Info,
+		Style: log.Normal,
+	}}
+	app.AddVerb(&app.Verb{
+		Name:      "logs",
+		ShortHelp: "Tails the log stream of a running server",
+		Action:    verb,
+	})
+}
+
+func (verb *logsVerb) Run(ctx context.Context, flags flag.FlagSet) error {
+	if verb.Gapis.Port == 0 {
+		app.Usage(ctx, "The port of a running server must be specified with -gapis-port")
+		return nil
+	}
+
+	// Don't forward the server's logs to this process' handler, as getGapis
+	// would otherwise do.
+	client, err := getGapis(log.PutHandler(ctx, nil), verb.Gapis, GapirFlags{})
+	if err != nil {
+		return log.Err(ctx, err, "Failed to connect to the GAPIS server")
+	}
+	defer client.Close()
+
+	var handler log.Handler
+	if verb.JSON {
+		handler = log.JSON(os.Stdout)
+	} else {
+		handler = verb.Style.Handler(log.Stdout())
+	}
+	handler = log.TagFilter{Show: verb.Tags, Hide: verb.Hide}.Handler(handler)
+	filter := log.SeverityFilter(verb.Level)
+
+	err = client.GetLogStream(ctx, log.NewHandler(func(m *log.Message) {
+		if filter.ShowSeverity(m.Severity) {
+			handler.Handle(m)
+		}
+	}, handler.Close))
+	if err != nil && err != context.Canceled {
+		return fmt.Errorf("Log stream closed: %v", err)
+	}
+	return nil
+}
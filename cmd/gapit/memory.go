@@ -0,0 +1,162 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/service"
+)
+
+type memoryVerb struct{ MemoryFlags }
+
+func init() {
+	verb := &memoryVerb{
+		MemoryFlags{
+			At: -1,
+		},
+	}
+
+	app.AddVerb(&app.Verb{
+		Name:      "memory",
+		ShortHelp: "Prints the device memory allocations for a point in a .gfxtrace file",
+		Action:    verb,
+	})
+}
+
+func (verb *memoryVerb) Run(ctx context.Context, flags flag.FlagSet) error {
+	if flags.NArg() != 1 {
+		app.Usage(ctx, "Exactly one gfx trace file expected, got %d", flags.NArg())
+		return nil
+	}
+
+	client, err := getGapis(ctx, verb.Gapis, verb.Gapir)
+	if err != nil {
+		return log.Err(ctx, err, "Failed to connect to the GAPIS server")
+	}
+	defer client.Close()
+
+	filepath, err := filepath.Abs(flags.Arg(0))
+	ctx = log.V{"filepath": filepath}.Bind(ctx)
+	if err != nil {
+		return log.Err(ctx, err, "Could not find capture file")
+	}
+
+	c, err := client.LoadCapture(ctx, filepath)
+	if err != nil {
+		return log.Err(ctx, err, "Failed to load the capture file")
+	}
+
+	if verb.At == -1 {
+		boxedCapture, err := client.Get(ctx, c.Path())
+		if err != nil {
+			return log.Err(ctx, err, "Failed to load the capture")
+		}
+		verb.At = int(boxedCapture.(*service.Capture).NumCommands) - 1
+	}
+
+	boxedBreakdown, err := client.Get(ctx, c.Command(uint64(verb.At)).MemoryBreakdown().Path())
+	if err != nil {
+		return log.Err(ctx, err, "Failed to load the memory allocations")
+	}
+
+	printMemoryBreakdown(os.Stdout, boxedBreakdown.(*gfxapi.MemoryBreakdown))
+	return nil
+}
+
+func printMemoryBreakdown(w io.Writer, b *gfxapi.MemoryBreakdown) {
+	fmt.Fprintln(w, "Heaps:")
+	for _, h := range b.Heaps {
+		percent := 0.0
+		if h.Size > 0 {
+			percent = 100 * float64(h.Allocated) / float64(h.Size)
+		}
+		if h.Index == gfxapi.UnknownHeapIndex {
+			fmt.Fprintf(w, "  Device 0x%x heap unknown\n", h.Device)
+		} else {
+			fmt.Fprintf(w, "  Device 0x%x heap %d [%s] size: %s\n",
+				h.Device, h.Index, strings.Join(h.Flags, "|"), byteSize(h.Size))
+		}
+		unbound := uint64(0)
+		if h.Allocated > h.Bound {
+			unbound = h.Allocated - h.Bound
+		}
+		fmt.Fprintf(w, "    %d allocations, %s allocated (%.1f%%), %s bound, %s unbound\n",
+			h.AllocationCount, byteSize(h.Allocated), percent, byteSize(h.Bound),
+			byteSize(unbound))
+		if h.Size > 0 && h.Allocated > h.Size {
+			fmt.Fprintf(w, "    overcommitted by %s\n", byteSize(h.Allocated-h.Size))
+		}
+	}
+
+	fmt.Fprintln(w, "Allocations:")
+	for _, a := range b.Allocations {
+		fmt.Fprintf(w, "  Memory 0x%x heap %s type %d [%s] size: %s\n",
+			a.Handle, heapName(a.HeapIndex), a.TypeIndex, strings.Join(a.Properties, "|"), byteSize(a.Size))
+		if a.Mapped != nil {
+			fmt.Fprintf(w, "    mapped: 0x%x-0x%x (%s)\n",
+				a.Mapped.Offset, a.Mapped.Offset+a.Mapped.Size, byteSize(a.Mapped.Size))
+		}
+		for _, binding := range a.Bindings {
+			fmt.Fprintf(w, "    0x%x-0x%x %v (%s)", binding.Offset, binding.Offset+binding.Size,
+				binding.Name, byteSize(binding.Size))
+			if end := binding.Offset + binding.Size; end > a.Size {
+				fmt.Fprintf(w, " overruns allocation by %s", byteSize(end-a.Size))
+			}
+			if len(binding.Aliases) > 0 {
+				aliases := make([]string, len(binding.Aliases))
+				for i, j := range binding.Aliases {
+					aliases[i] = a.Bindings[j].Name
+				}
+				fmt.Fprintf(w, " aliases: %s", strings.Join(aliases, ", "))
+			}
+			fmt.Fprintln(w)
+		}
+		for _, r := range a.Unused {
+			fmt.Fprintf(w, "    0x%x-0x%x <unused> (%s)\n", r.Offset, r.Offset+r.Size, byteSize(r.Size))
+		}
+	}
+}
+
+// heapName returns the name of the heap with the given index.
+func heapName(index uint32) string {
+	if index == gfxapi.UnknownHeapIndex {
+		return "unknown"
+	}
+	return fmt.Sprint(index)
+}
+
+// byteSize returns n formatted as a human readable size.
+func byteSize(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
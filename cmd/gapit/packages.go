@@ -59,6 +59,12 @@ func (verb *packagesVerb) Run(ctx context.Context, flags flag.FlagSet) error {
 		return log.Err(ctx, err, "getting package list")
 	}
 
+	if verb.Resources {
+		if err := gapidapk.AddResources(ctx, d, pkgs); err != nil {
+			return log.Err(ctx, err, "getting package resources")
+		}
+	}
+
 	w := os.Stdout
 	if verb.Out != "" {
 		f, err := os.OpenFile(verb.Out, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
@@ -0,0 +1,197 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/gfxapi/gles/glsl"
+	"github.com/google/gapid/gapis/gfxapi/gles/glsl/ast"
+	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+type reduceShaderVerb struct{ ReduceShaderFlags }
+
+func init() {
+	verb := &reduceShaderVerb{
+		ReduceShaderFlags{
+			At:        -1,
+			Reference: 1,
+			Threshold: 8,
+		},
+	}
+	verb.Max.Width = 1920
+	verb.Max.Height = 1280
+
+	app.AddVerb(&app.Verb{
+		Name:      "reduce_shader",
+		ShortHelp: "Reduces a shader which renders a draw call differently on two devices",
+		Action:    verb,
+	})
+}
+
+func (verb *reduceShaderVerb) Run(ctx context.Context, flags flag.FlagSet) error {
+	if flags.NArg() != 1 {
+		app.Usage(ctx, "Exactly one gfx trace file expected, got %d", flags.NArg())
+		return nil
+	}
+	if verb.At < 0 || verb.Shader == "" {
+		app.Usage(ctx, "Both the draw call (-at) and the shader (-shader) must be specified")
+		return nil
+	}
+
+	client, err := getGapis(ctx, verb.Gapis, verb.Gapir)
+	if err != nil {
+		return log.Err(ctx, err, "Failed to connect to the GAPIS server")
+	}
+	defer client.Close()
+
+	filepath, err := filepath.Abs(flags.Arg(0))
+	ctx = log.V{"filepath": filepath}.Bind(ctx)
+	if err != nil {
+		return log.Err(ctx, err, "Could not find capture file")
+	}
+
+	c, err := client.LoadCapture(ctx, filepath)
+	if err != nil {
+		return log.Err(ctx, err, "Failed to load the capture file")
+	}
+
+	devices, err := client.GetDevices(ctx)
+	if err != nil {
+		return log.Err(ctx, err, "Failed to get device list")
+	}
+	for _, i := range []int{verb.Device, verb.Reference} {
+		if i < 0 || i >= len(devices) {
+			return log.Errf(ctx, nil, "Device index %d out of range [0, %d)", i, len(devices))
+		}
+	}
+
+	boxedResources, err := client.Get(ctx, c.Resources().Path())
+	if err != nil {
+		return log.Err(ctx, err, "Could not find the capture's resources")
+	}
+	var resource *path.ResourceData
+	for _, types := range boxedResources.(*service.Resources).Types {
+		if types.Type != gfxapi.ResourceType_ShaderResource {
+			continue
+		}
+		for _, r := range types.Resources {
+			if r.Handle == verb.Shader {
+				resource = c.Command(uint64(verb.At)).ResourceAfter(r.Id)
+			}
+		}
+	}
+	if resource == nil {
+		return log.Errf(ctx, nil, "Shader %s not found", verb.Shader)
+	}
+
+	boxedData, err := client.Get(ctx, resource.Path())
+	if err != nil {
+		return log.Err(ctx, err, "Could not get the shader source")
+	}
+	shader := boxedData.(*gfxapi.ResourceData).GetShader()
+	var lang ast.Language
+	switch shader.Type {
+	case gfxapi.ShaderType_Vertex:
+		lang = ast.LangVertexShader
+	case gfxapi.ShaderType_Fragment:
+		lang = ast.LangFragmentShader
+	default:
+		return log.Errf(ctx, nil, "Reducing %v shaders is not supported", shader.Type)
+	}
+
+	var frameFlags VideoFlags
+	frameFlags.Max.Width, frameFlags.Max.Height = verb.Max.Width, verb.Max.Height
+
+	// differs replays the draw call with the shader source src on both devices, and returns true
+	// if the rendered images differ.
+	differs := func(ctx context.Context, src string) (bool, error) {
+		for _, d := range glsl.Check(src, lang) {
+			if d.Severity == glsl.SeverityError {
+				return false, nil
+			}
+		}
+		data := gfxapi.NewResourceData(&gfxapi.Shader{Type: shader.Type, Source: src})
+		p, err := client.Set(ctx, resource.Path(), data)
+		if err != nil {
+			return false, log.Err(ctx, err, "Failed to replace the shader source")
+		}
+		cmd := p.GetResourceData().After
+		got, err := getFrame(ctx, frameFlags, cmd, devices[verb.Device], client)
+		if err != nil {
+			// The candidate may have failed to compile on the device.
+			log.W(ctx, "Replay failed: %v", err)
+			return false, nil
+		}
+		expected, err := getFrame(ctx, frameFlags, cmd, devices[verb.Reference], client)
+		if err != nil {
+			log.W(ctx, "Reference replay failed: %v", err)
+			return false, nil
+		}
+		return imagesDiffer(got, expected, verb.Threshold), nil
+	}
+
+	if ok, err := differs(ctx, shader.Source); err != nil {
+		return err
+	} else if !ok {
+		return log.Err(ctx, nil, "The draw call renders the same on both devices")
+	}
+
+	reduced, err := glsl.Reduce(ctx, shader.Source, lang, func(ctx context.Context, src string) (bool, error) {
+		ok, err := differs(ctx, src)
+		if ok {
+			log.I(ctx, "Reduced shader to %d bytes", len(src))
+		}
+		return ok, err
+	})
+	if err != nil {
+		log.E(ctx, "Reduction stopped early: %v", err)
+	}
+
+	if verb.Out == "" {
+		fmt.Fprint(os.Stdout, reduced)
+		return nil
+	}
+	if err := ioutil.WriteFile(verb.Out, []byte(reduced), 0666); err != nil {
+		return log.Err(ctx, err, "Failed to write the reduced shader")
+	}
+	return nil
+}
+
+// imagesDiffer returns true if the images a and b have different sizes, or if any of their
+// color channels differ by more than threshold.
+func imagesDiffer(a, b *image.NRGBA, threshold int) bool {
+	if a.Rect != b.Rect {
+		return true
+	}
+	for i := range a.Pix {
+		d := int(a.Pix[i]) - int(b.Pix[i])
+		if d > threshold || -d > threshold {
+			return true
+		}
+	}
+	return false
+}
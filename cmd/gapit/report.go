@@ -25,13 +25,13 @@ import (
 	"github.com/google/gapid/core/app"
 	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/gapis/service"
-	"github.com/google/gapid/gapis/stringtable"
 )
 
 type reportVerb struct{ ReportFlags }
 
 func init() {
 	verb := &reportVerb{}
+	verb.Thread = -1
 	app.AddVerb(&app.Verb{
 		Name:      "report",
 		ShortHelp: "Check a capture replays without issues",
@@ -56,18 +56,18 @@ func (verb *reportVerb) Run(ctx context.Context, flags flag.FlagSet) error {
 	}
 	defer client.Close()
 
-	stringTables, err := client.GetAvailableStringTables(ctx)
-	if err != nil {
-		return log.Err(ctx, err, "Failed get list of string tables")
+	locales := verb.Locale
+	if len(locales) == 0 {
+		if lang := os.Getenv("LANG"); lang != "" {
+			locales = []string{lang}
+		}
 	}
 
-	var stringTable *stringtable.StringTable
-	if len(stringTables) > 0 {
-		// TODO: Let the user pick the string table.
-		stringTable, err = client.GetStringTable(ctx, stringTables[0])
-		if err != nil {
-			return log.Err(ctx, err, "Failed get string table")
-		}
+	stringTable, err := client.GetPreferredStringTable(ctx, locales)
+	if err != nil {
+		// Messages without a string table are printed as their identifiers.
+		log.W(ctx, "Failed to get string table, printing raw messages: %v", err)
+		stringTable = nil
 	}
 
 	capturePath, err := client.LoadCapture(ctx, capture)
@@ -0,0 +1,91 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/log"
+)
+
+type sessionsVerb struct{ SessionsFlags }
+
+func init() {
+	verb := &sessionsVerb{}
+	app.AddVerb(&app.Verb{
+		Name:      "sessions",
+		ShortHelp: "Creates, shows, closes or purges a session of a running server",
+		Action:    verb,
+	})
+}
+
+func (verb *sessionsVerb) Run(ctx context.Context, flags flag.FlagSet) error {
+	if verb.Gapis.Session == "" && !verb.Create {
+		app.Usage(ctx, "A session must be given with the gapis session flag, or created with -create")
+		return nil
+	}
+	if verb.Create && verb.Gapis.Port == 0 {
+		app.Usage(ctx, "Sessions can only be created on an existing server, given with the gapis port flag")
+		return nil
+	}
+
+	client, err := getGapis(ctx, verb.Gapis, GapirFlags{})
+	if err != nil {
+		return log.Err(ctx, err, "Failed to connect to the GAPIS server")
+	}
+	defer client.Close()
+
+	stdout := os.Stdout
+	if verb.Create {
+		// This is not the connection's own session, so it is kept open when
+		// the client is closed.
+		s, err := client.CreateSession(ctx)
+		if err != nil {
+			return log.Err(ctx, err, "Failed to create session")
+		}
+		fmt.Fprintln(stdout, s.Id)
+		return nil
+	}
+
+	id := verb.Gapis.Session
+	if verb.Close {
+		if err := client.CloseSession(ctx, id); err != nil {
+			return log.Errf(ctx, err, "Failed to close session '%s'", id)
+		}
+		return nil
+	}
+	if verb.Purge {
+		if err := client.PurgeSession(ctx, id); err != nil {
+			return log.Errf(ctx, err, "Failed to purge session '%s'", id)
+		}
+	}
+
+	sessions, err := client.GetSessions(ctx)
+	if err != nil {
+		return log.Err(ctx, err, "Failed to get session")
+	}
+
+	for _, s := range sessions {
+		fmt.Fprintf(stdout, "%v: created: %v, captures: %d, memory: %d/%d bytes\n",
+			s.Id, time.Unix(s.Created, 0), len(s.Captures), s.MemoryUsed, s.MemoryBudget)
+	}
+
+	return nil
+}
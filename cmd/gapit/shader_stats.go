@@ -0,0 +1,125 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/service"
+)
+
+type shaderStatsVerb struct{ ShaderStatsFlags }
+
+func init() {
+	verb := &shaderStatsVerb{
+		ShaderStatsFlags{
+			At: -1,
+		},
+	}
+
+	app.AddVerb(&app.Verb{
+		Name:      "shader_stats",
+		ShortHelp: "Prints static metrics of all the shaders at a point in a .gfxtrace file",
+		Action:    verb,
+	})
+}
+
+func (verb *shaderStatsVerb) Run(ctx context.Context, flags flag.FlagSet) error {
+	if flags.NArg() != 1 {
+		app.Usage(ctx, "Exactly one gfx trace file expected, got %d", flags.NArg())
+		return nil
+	}
+
+	client, err := getGapis(ctx, verb.Gapis, verb.Gapir)
+	if err != nil {
+		return log.Err(ctx, err, "Failed to connect to the GAPIS server")
+	}
+	defer client.Close()
+
+	filepath, err := filepath.Abs(flags.Arg(0))
+	ctx = log.V{"filepath": filepath}.Bind(ctx)
+	if err != nil {
+		return log.Err(ctx, err, "Could not find capture file")
+	}
+
+	c, err := client.LoadCapture(ctx, filepath)
+	if err != nil {
+		return log.Err(ctx, err, "Failed to load the capture file")
+	}
+
+	if verb.At == -1 {
+		boxedCapture, err := client.Get(ctx, c.Path())
+		if err != nil {
+			return log.Err(ctx, err, "Failed to load the capture")
+		}
+		verb.At = int(boxedCapture.(*service.Capture).NumCommands) - 1
+	}
+
+	boxedResources, err := client.Get(ctx, c.Resources().Path())
+	if err != nil {
+		return log.Err(ctx, err, "Could not find the capture's resources")
+	}
+
+	for _, types := range boxedResources.(*service.Resources).Types {
+		if types.Type != gfxapi.ResourceType_ShaderResource {
+			continue
+		}
+		for _, r := range types.Resources {
+			boxedData, err := client.Get(ctx, c.Command(uint64(verb.At)).ResourceAfter(r.Id).Path())
+			if err != nil {
+				log.E(ctx, "Could not get data for shader %v: %v", r.Handle, err)
+				continue
+			}
+			shader := boxedData.(*gfxapi.ResourceData).GetShader()
+			printShaderStats(os.Stdout, r.Handle, shader)
+		}
+	}
+	return nil
+}
+
+func printShaderStats(w io.Writer, handle string, shader *gfxapi.Shader) {
+	fmt.Fprintf(w, "%s (%v):\n", handle, shader.Type)
+	m := shader.Metrics
+	if m == nil {
+		fmt.Fprintln(w, "  <no metrics available>")
+		return
+	}
+	fmt.Fprintf(w, "  ALU ops:        %d (weighted: %.1f)\n", m.AluOps, m.WeightedAluOps)
+	fmt.Fprintf(w, "  Texture ops:    %d (weighted: %.1f)\n", m.TextureOps, m.WeightedTextureOps)
+	fmt.Fprintf(w, "  Branches:       %d\n", m.Branches)
+	fmt.Fprintf(w, "  Loops:          %d\n", m.Loops)
+	fmt.Fprintf(w, "  Registers:      %d\n", m.Registers)
+	fmt.Fprintf(w, "  Precision:      lowp: %d, mediump: %d, highp: %d\n", m.Lowp, m.Mediump, m.Highp)
+	for _, l := range []struct {
+		name  string
+		names []string
+	}{
+		{"Uniforms", m.Uniforms},
+		{"Samplers", m.Samplers},
+		{"Inputs", m.Inputs},
+		{"Outputs", m.Outputs},
+	} {
+		fmt.Fprintf(w, "  %-15s %d [%s]\n", l.name+":", len(l.names), strings.Join(l.names, ", "))
+	}
+}
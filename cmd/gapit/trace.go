@@ -166,7 +166,7 @@ func (verb *traceVerb) captureADB(ctx context.Context, flags flag.FlagSet, optio
 			return log.Err(ctx, err, "Install APK")
 		}
 		pkg = &android.InstalledPackage{
-			Name:       info.Name,
+			Name:       info.Package,
 			Device:     d,
 			ABI:        d.Instance().GetConfiguration().PreferredABI(info.ABI),
 			Debuggable: info.Debuggable,
@@ -22,21 +22,26 @@ import (
 	"fmt"
 
 	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/app/flags"
 	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/core/os/android/apk"
 	"github.com/google/gapid/core/os/file"
 )
 
 var (
-	jarSignCmd     = flag.String("jarsigner", "jarsigner", "path to jarsigner")
-	zipAlignCmd    = flag.String("zipalign", "zipalign", "path to zipalign")
-	keyPass        = flag.String("keypass", "android", "key passphrase")
-	keyAlias       = flag.String("keyalias", "androiddebugkey", "key alias")
-	storePass      = flag.String("storepass", "android", "key store passphrase")
-	keyStore       = flag.String("keystore", "~/.android/debug.keystore", "key store location")
-	forceOverwrite = flag.Bool("y", false, "overwrite existing destination")
+	keyStore              = flag.String("keystore", "~/.android/debug.keystore", "JKS or PKCS#12 key store location, created if missing, or PEM file holding the signing key and certificate")
+	storePass             = flag.String("storepass", apk.DebugKeyStorePassword, "key store passphrase")
+	keyPass               = flag.String("keypass", apk.DebugKeyStorePassword, "key passphrase")
+	keyAlias              = flag.String("keyalias", apk.DebugKeyAlias, "key alias")
+	forceOverwrite        = flag.Bool("y", false, "overwrite existing destination")
+	networkSecurityConfig = flag.Bool("network-security-config", false, "trust user certificates and permit cleartext traffic")
+	permissions           flags.Strings
 )
 
+func init() {
+	flag.Var(&permissions, "permission", "permission to add to the manifest, may be repeated")
+}
+
 func main() {
 	app.ShortHelp = "make an apk debuggable and re-sign"
 	app.ShortUsage = " <source> <destination>"
@@ -59,18 +64,20 @@ func run(ctx context.Context) error {
 	if err != nil {
 		log.W(ctx, "%s", err.Error())
 	}
-	if isDebuggable {
+	if isDebuggable && len(permissions) == 0 && !*networkSecurityConfig {
 		log.W(ctx, "Source %s is already debuggable, performing regular file copy.", src)
 		return file.Copy(ctx, file.Abs(dst), file.Abs(src))
 	}
 
+	key, err := apk.LoadOrCreateDebugKey(file.Abs(*keyStore).System(), *storePass, *keyPass, *keyAlias)
+	if err != nil {
+		return log.Err(ctx, err, "Loading signing key")
+	}
+
 	return apk.ApkDebugifier{
-		Ctx:          ctx,
-		JarSignCmd:   *jarSignCmd,
-		ZipAlignCmd:  *zipAlignCmd,
-		KeyPass:      *keyPass,
-		KeyAlias:     *keyAlias,
-		StorePass:    *storePass,
-		KeyStorePath: *keyStore,
+		Ctx:                   ctx,
+		Key:                   key,
+		Permissions:           permissions,
+		NetworkSecurityConfig: *networkSecurityConfig,
 	}.Run(src, dst)
 }
@@ -0,0 +1,52 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command sign-apk aligns an apk and signs it with the debug key, in place of
+// the zipalign and apksigner tools.
+package main
+
+import (
+	"context"
+	"flag"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/android/apk"
+	"github.com/google/gapid/core/os/file"
+)
+
+var (
+	keyStore  = flag.String("keystore", "~/.android/debug.keystore", "JKS or PKCS#12 key store location, created if missing, or PEM file holding the signing key and certificate")
+	storePass = flag.String("storepass", apk.DebugKeyStorePassword, "key store passphrase")
+	keyPass   = flag.String("keypass", apk.DebugKeyStorePassword, "key passphrase")
+	keyAlias  = flag.String("keyalias", apk.DebugKeyAlias, "key alias")
+)
+
+func main() {
+	app.ShortHelp = "align and sign an apk with the debug key"
+	app.ShortUsage = " <source> <destination>"
+	app.Run(run)
+}
+
+func run(ctx context.Context) error {
+	if len(flag.Args()) != 2 {
+		app.Usage(ctx, "")
+	}
+
+	key, err := apk.LoadOrCreateDebugKey(file.Abs(*keyStore).System(), *storePass, *keyPass, *keyAlias)
+	if err != nil {
+		return log.Err(ctx, err, "Loading signing key")
+	}
+	return apk.SignFile(flag.Arg(0), flag.Arg(1), key)
+}
@@ -21,7 +21,6 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"reflect"
 	"sort"
 
 	"github.com/golang/protobuf/proto"
@@ -38,12 +37,11 @@ type tableAndTypeMap struct {
 }
 
 const (
-	defaultCultureCode    = "en-us"
 	ErrDuplicateParameter = fault.Const("Duplicate stringtable parameter found")
 	ErrDuplicateStringKey = fault.Const("Duplicate string key found")
 	ErrNoStringtables     = fault.Const("No string table files provided")
 	ErrNoEntry            = fault.Const("No entry provided")
-	ErrParameterList      = fault.Const("Parameter list different")
+	ErrInconsistentTables = fault.Const("String tables are inconsistent")
 )
 
 var (
@@ -53,7 +51,7 @@ var (
 )
 
 func main() {
-	app.ShortHelp = "stringgen compiles string table files to string packages and a Go definition file."
+	app.ShortHelp = "stringgen validates and compiles string table files to string packages and a Go definition file."
 	app.Run(run)
 }
 
@@ -127,9 +125,6 @@ func run(ctx context.Context) error {
 	return nil
 }
 
-// entry is a map of Info -> parameter list
-type entry map[stringtable.Info][]string
-
 func writePackages(tables map[stringtable.Info]*tableAndTypeMap, path string) error {
 	for info, table := range tables {
 		data, err := proto.Marshal(table.stringTable)
@@ -182,52 +177,34 @@ func writeDefinitionsAbstract(table *tableAndTypeMap, path, templateRoutine, tem
 }
 
 // checks for consistency between the various localizations of strings.
+// Entries missing from a localization are reported as warnings, as they will
+// fall back to the default table. All other problems are errors.
 func validate(ctx context.Context, tables map[stringtable.Info]*tableAndTypeMap) error {
-	all := map[string]entry{}
-
+	var def *stringtable.StringTable
+	all := make([]*stringtable.StringTable, 0, len(tables))
 	for info, table := range tables {
-		for key, node := range table.stringTable.Entries {
-			e := all[key]
-			if e == nil {
-				e = make(entry)
-			}
-			strParams := []string{}
-			for _, p := range params(key, node, table.paramTypeMap) {
-				strParams = append(strParams, p.Identifier)
-			}
-			e[info] = strParams
-			all[key] = e
+		if info.CultureCode == stringtable.DefaultCultureCode {
+			def = table.stringTable
 		}
+		all = append(all, table.stringTable)
+	}
+	if def == nil {
+		return log.Errf(ctx, ErrNoEntry, "code: %v", stringtable.DefaultCultureCode)
 	}
 
-	for key, entry := range all {
-		ctx := log.V{"Key": key}.Bind(ctx)
-		if len(entry) != len(tables) {
-			for info := range tables {
-				if _, found := entry[info]; !found {
-					return log.Errf(ctx, ErrNoEntry, "Table: %v", info)
-				}
-			}
-		}
-		var validInfo *stringtable.Info
-		var validParams []string
-		for info, params := range entry {
-			if info.CultureCode == defaultCultureCode {
-				validInfo, validParams = &info, params
-				break
-			}
-		}
-		if validInfo == nil || validParams == nil {
-			return log.Errf(ctx, ErrNoEntry, "code: %v", defaultCultureCode)
-		}
-		for info, params := range entry {
-			if info.CultureCode != defaultCultureCode &&
-				!reflect.DeepEqual(validParams, params) {
-				return log.Errf(ctx, ErrParameterList, "first: %v, second: %v", *validInfo, info)
-			}
+	errors := 0
+	for _, p := range stringtable.Validate(def, all) {
+		ctx := log.V{"Key": p.Key, "Table": p.CultureCode}.Bind(ctx)
+		if p.Error {
+			log.E(ctx, "%v", p.Message)
+			errors++
+		} else {
+			log.W(ctx, "%v", p.Message)
 		}
 	}
-
+	if errors > 0 {
+		return log.Errf(ctx, ErrInconsistentTables, "%d error(s)", errors)
+	}
 	return nil
 }
 
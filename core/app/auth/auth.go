@@ -19,6 +19,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"io"
+	"net/http"
 
 	"github.com/google/gapid/core/data/endian"
 	"github.com/google/gapid/core/os/device"
@@ -31,6 +32,13 @@ var (
 	ioHeader  = []byte{'A', 'U', 'T', 'H'}
 	rpcHeader = "auth_token"
 
+	// HTTPHeader is the name of the HTTP header used to send the auth-token.
+	HTTPHeader = "Auth-Token"
+
+	// HTTPQuery is the name of the HTTP query parameter used to send the
+	// auth-token to streaming endpoints. See CheckHTTPStream.
+	HTTPQuery = "auth_token"
+
 	// ErrInvalidToken is returned by Check when the auth-token was not as
 	// expected.
 	ErrInvalidToken = fmt.Errorf("Invalid auth-token code")
@@ -97,3 +105,26 @@ func ClientInterceptor(token Token) grpc.UnaryClientInterceptor {
 		return invoker(ctx, method, req, reply, cc, opts...)
 	}
 }
+
+// CheckHTTP returns ErrInvalidToken if the HTTP request r does not carry the
+// given auth token in the HTTPHeader header. The token is not accepted as a
+// query parameter, as URLs end up in logs and proxies.
+func CheckHTTP(token Token, r *http.Request) error {
+	if token == NoAuth {
+		return nil
+	}
+	if Token(r.Header.Get(HTTPHeader)) != token {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// CheckHTTPStream is like CheckHTTP, but also accepts the token as the
+// HTTPQuery query parameter. It is intended for server-sent event streams, as
+// browsers cannot set the headers of an EventSource request.
+func CheckHTTPStream(token Token, r *http.Request) error {
+	if token == NoAuth || Token(r.URL.Query().Get(HTTPQuery)) == token {
+		return nil
+	}
+	return CheckHTTP(token, r)
+}
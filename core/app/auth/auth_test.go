@@ -16,6 +16,7 @@ package auth_test
 
 import (
 	"bytes"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/google/gapid/core/app/auth"
@@ -52,6 +53,54 @@ func TestGenToken(t *testing.T) {
 	assert.For("length").That(len(token)).Equals(8)
 }
 
+func TestCheckHTTP(t *testing.T) {
+	assert := assert.To(t)
+	for _, test := range []struct {
+		name     string
+		token    auth.Token
+		url      string
+		header   string
+		expected error
+	}{
+		{"no-auth", auth.NoAuth, "/", "", nil},
+		{"header", auth.Token("abc"), "/", "abc", nil},
+		{"query", auth.Token("abc"), "/?auth_token=abc", "", auth.ErrInvalidToken},
+		{"missing", auth.Token("abc"), "/", "", auth.ErrInvalidToken},
+		{"wrong-header", auth.Token("abc"), "/", "xyz", auth.ErrInvalidToken},
+		{"wrong-query", auth.Token("abc"), "/?auth_token=xyz", "", auth.ErrInvalidToken},
+	} {
+		r := httptest.NewRequest("GET", test.url, nil)
+		if test.header != "" {
+			r.Header.Set(auth.HTTPHeader, test.header)
+		}
+		assert.For("%s", test.name).ThatError(auth.CheckHTTP(test.token, r)).Equals(test.expected)
+	}
+}
+
+func TestCheckHTTPStream(t *testing.T) {
+	assert := assert.To(t)
+	for _, test := range []struct {
+		name     string
+		token    auth.Token
+		url      string
+		header   string
+		expected error
+	}{
+		{"no-auth", auth.NoAuth, "/", "", nil},
+		{"header", auth.Token("abc"), "/", "abc", nil},
+		{"query", auth.Token("abc"), "/?auth_token=abc", "", nil},
+		{"missing", auth.Token("abc"), "/", "", auth.ErrInvalidToken},
+		{"wrong-header", auth.Token("abc"), "/", "xyz", auth.ErrInvalidToken},
+		{"wrong-query", auth.Token("abc"), "/?auth_token=xyz", "", auth.ErrInvalidToken},
+	} {
+		r := httptest.NewRequest("GET", test.url, nil)
+		if test.header != "" {
+			r.Header.Set(auth.HTTPHeader, test.header)
+		}
+		assert.For("%s", test.name).ThatError(auth.CheckHTTPStream(test.token, r)).Equals(test.expected)
+	}
+}
+
 type readCloser struct {
 	*bytes.Buffer
 	closed bool
@@ -14,7 +14,10 @@
 
 package app
 
-import "github.com/google/gapid/core/log"
+import (
+	"github.com/google/gapid/core/app/flags"
+	"github.com/google/gapid/core/log"
+)
 
 type (
 	AppFlags struct {
@@ -23,10 +26,17 @@ type (
 		Profile ProfileFlags
 	}
 	LogFlags struct {
-		Level  log.Severity `help:"_The severity to enable logs at"`
-		Style  log.Style    `help:"_The style to use when printing the log"`
-		Stacks bool         `help:"_If true, stack traces are logged for all errors"`
-		File   string       `help:"_The file to store the logs in"`
+		Level  log.Severity  `help:"_The severity to enable logs at"`
+		Style  log.Style     `help:"_The style to use when printing the log"`
+		Stacks bool          `help:"_If true, stack traces are logged for all errors"`
+		File   string        `help:"_The file to store the logs in"`
+		JSON   bool          `help:"_If true, logs are written as JSON lines instead of using the style"`
+		Tags   flags.Strings `help:"_Only show messages with a tag matching this pattern (repeatable)"`
+		Hide   flags.Strings `help:"_Hide messages with a tag matching this pattern (repeatable)"`
+		Rotate struct {
+			Size  int64 `help:"_Rotate the log file once it exceeds this many bytes; 0 disables rotation"`
+			Count int   `help:"_The number of rotated log files to keep"`
+		}
 	}
 	ProfileFlags struct {
 		CPU string `help:"_write cpu profile to file"`
@@ -16,6 +16,7 @@ package app
 
 import (
 	"context"
+	"io"
 	"os"
 	"path/filepath"
 
@@ -62,6 +63,18 @@ func prepareContext(flags *LogFlags) (context.Context, func(), task.CancelFunc)
 	return ctx, handler.Close, task.CancelFunc(cancel)
 }
 
+// newHandler returns the log handler configured by flags. JSON logs are
+// written to out, styled logs are written to w.
+func newHandler(flags *LogFlags, out io.Writer, w log.Writer) log.Handler {
+	var handler log.Handler
+	if flags.JSON {
+		handler = log.JSON(out)
+	} else {
+		handler = flags.Style.Handler(w)
+	}
+	return log.TagFilter{Show: flags.Tags, Hide: flags.Hide}.Handler(handler)
+}
+
 func updateContext(ctx context.Context, flags *LogFlags, closeLogs func()) (context.Context, func()) {
 	ctx = log.PutFilter(ctx, log.SeverityFilter(flags.Level))
 	if flags.Stacks {
@@ -77,16 +90,19 @@ func updateContext(ctx context.Context, flags *LogFlags, closeLogs func()) (cont
 	if flags.File != "" {
 		// Create the server logfile.
 		os.MkdirAll(filepath.Dir(flags.File), 0755)
-		file, err := os.Create(flags.File)
+		var file io.WriteCloser
+		var err error
+		if flags.Rotate.Size > 0 {
+			file, err = log.NewRotatingFile(flags.File, flags.Rotate.Size, flags.Rotate.Count)
+		} else {
+			file, err = os.Create(flags.File)
+		}
 		if err != nil {
 			panic(err)
 		}
 		log.I(ctx, "Logging to: %v", flags.File)
 		// Build the logging context
-		handler := flags.Style.Handler(func(s string, _ log.Severity) {
-			file.WriteString(s)
-			file.WriteString("\n")
-		})
+		handler := newHandler(flags, file, log.IOWriter(file))
 		ctx = log.PutHandler(ctx, wrapHandler(handler))
 		closeLogs()
 		closeLogs = func() {
@@ -94,7 +110,7 @@ func updateContext(ctx context.Context, flags *LogFlags, closeLogs func()) (cont
 			file.Close()
 		}
 	} else {
-		handler := flags.Style.Handler(log.Std())
+		handler := newHandler(flags, os.Stdout, log.Std())
 		ctx = log.PutHandler(ctx, wrapHandler(handler))
 		closeLogs()
 		closeLogs = handler.Close
@@ -25,12 +25,17 @@ set(files
     clock.go
     err.go
     filter.go
+    filter_test.go
     handler.go
+    json.go
+    json_test.go
     log.go
     log.proto
     log_test.go
     message.go
     process.go
+    rotate.go
+    rotate_test.go
     severity.go
     stacktracer.go
     style.go
@@ -16,6 +16,7 @@ package log
 
 import (
 	"context"
+	"path"
 
 	"github.com/google/gapid/core/context/keys"
 )
@@ -47,3 +48,47 @@ type SeverityFilter Severity
 
 // ShowSeverity returns true if the message of severity s should be shown.
 func (f SeverityFilter) ShowSeverity(s Severity) bool { return Severity(f) <= s }
+
+// TagFilter filters messages by their tag.
+// Patterns use the syntax of path.Match.
+type TagFilter struct {
+	// Show is the list of tag patterns to show. If empty, all tags are shown.
+	Show []string
+	// Hide is the list of tag patterns to hide. Hide takes precedence over
+	// Show.
+	Hide []string
+}
+
+// ShowTag returns true if messages with the given tag should be shown.
+func (f TagFilter) ShowTag(tag string) bool {
+	for _, p := range f.Hide {
+		if match, _ := path.Match(p, tag); match {
+			return false
+		}
+	}
+	if len(f.Show) == 0 {
+		return true
+	}
+	for _, p := range f.Show {
+		if match, _ := path.Match(p, tag); match {
+			return true
+		}
+	}
+	return false
+}
+
+// Handler returns a Handler that forwards the messages with tags shown by the
+// filter to h.
+func (f TagFilter) Handler(h Handler) Handler {
+	if len(f.Show) == 0 && len(f.Hide) == 0 {
+		return h
+	}
+	return handler{
+		handle: func(m *Message) {
+			if f.ShowTag(m.Tag) {
+				h.Handle(m)
+			}
+		},
+		close: h.Close,
+	}
+}
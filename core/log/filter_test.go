@@ -0,0 +1,42 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log_test
+
+import (
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+)
+
+func TestTagFilter(t *testing.T) {
+	assert := assert.To(t)
+	for _, test := range []struct {
+		filter   log.TagFilter
+		tag      string
+		expected bool
+	}{
+		{log.TagFilter{}, "any", true},
+		{log.TagFilter{Show: []string{"gles"}}, "gles", true},
+		{log.TagFilter{Show: []string{"gles"}}, "vulkan", false},
+		{log.TagFilter{Show: []string{"replay*"}}, "replay-batch", true},
+		{log.TagFilter{Hide: []string{"replay*"}}, "replay-batch", false},
+		{log.TagFilter{Hide: []string{"replay*"}}, "gles", true},
+		{log.TagFilter{Show: []string{"*"}, Hide: []string{"gles"}}, "gles", false},
+	} {
+		assert.For("%+v.ShowTag(%v)", test.filter, test.tag).
+			That(test.filter.ShowTag(test.tag)).Equals(test.expected)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// jsonMessage is the JSON encoding of a Message.
+type jsonMessage struct {
+	Time      string                 `json:"time,omitempty"`
+	Severity  string                 `json:"severity"`
+	Tag       string                 `json:"tag,omitempty"`
+	Process   string                 `json:"process,omitempty"`
+	Text      string                 `json:"text"`
+	Trace     []string               `json:"trace,omitempty"`
+	Values    map[string]interface{} `json:"values,omitempty"`
+	Callstack []string               `json:"callstack,omitempty"`
+}
+
+// JSON returns a Handler that writes each message to w as a single line of
+// JSON. Values that cannot be encoded as JSON are written as strings.
+func JSON(w io.Writer) Handler {
+	e := json.NewEncoder(w)
+	return handler{
+		handle: func(msg *Message) {
+			m := jsonMessage{
+				Severity: msg.Severity.String(),
+				Tag:      msg.Tag,
+				Process:  msg.Process,
+				Text:     msg.Text,
+				Trace:    msg.Trace,
+			}
+			if !msg.Time.IsZero() {
+				m.Time = msg.Time.Format(time.RFC3339Nano)
+			}
+			if len(msg.Values) > 0 {
+				m.Values = make(map[string]interface{}, len(msg.Values))
+				for _, v := range msg.Values {
+					if _, err := json.Marshal(v.Value); err != nil {
+						m.Values[v.Name] = fmt.Sprint(v.Value)
+					} else {
+						m.Values[v.Name] = v.Value
+					}
+				}
+			}
+			for _, l := range msg.Callstack {
+				m.Callstack = append(m.Callstack, fmt.Sprintf("%s:%d", l.File, l.Line))
+			}
+			e.Encode(m)
+		},
+		close: func() {},
+	}
+}
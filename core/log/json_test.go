@@ -0,0 +1,34 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+)
+
+func TestJSON(t *testing.T) {
+	for _, test := range testMessages {
+		buf := &bytes.Buffer{}
+		test.send(log.JSON(buf))
+		assert.To(t).
+			For("JSON(%s)", test.msg).
+			ThatString(strings.TrimSpace(buf.String())).Equals(test.json)
+	}
+}
@@ -43,6 +43,7 @@ type testMessage struct {
 	brief    string
 	normal   string
 	detailed string
+	json     string
 }
 
 func (m testMessage) send(h log.Handler) {
@@ -63,6 +64,7 @@ var testMessages = []testMessage{
 		brief:    "W: plain warning",
 		normal:   "12:34:56.789 W: plain warning",
 		detailed: "12:34:56.789 Warning: plain warning",
+		json:     `{"time":"2000-01-22T12:34:56.789Z","severity":"Warning","text":"plain warning"}`,
 	}, {
 		msg:      "info with values",
 		severity: log.Info,
@@ -72,5 +74,6 @@ var testMessages = []testMessage{
 		brief:    "I: info with values",
 		normal:   "12:34:56.789 I: info with values (cat: meow, dog: woof)",
 		detailed: "12:34:56.789 Info: info with values \n  cat: meow\n  dog: woof",
+		json:     `{"time":"2000-01-22T12:34:56.789Z","severity":"Info","text":"info with values","values":{"cat":"meow","dog":"woof"}}`,
 	},
 }
@@ -0,0 +1,98 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// RotatingFile is an io.WriteCloser that writes to a log file, rotating the
+// file once it grows beyond a maximum size.
+// Rotated files are named <path>.1 (the most recent) to <path>.<count>.
+type RotatingFile struct {
+	mutex   sync.Mutex
+	path    string
+	maxSize int64
+	count   int
+	file    *os.File
+	size    int64
+}
+
+// NewRotatingFile returns a new RotatingFile that writes to the file at path,
+// rotating it once it exceeds maxSize bytes and keeping at most count rotated
+// files. Any existing file at path is rotated.
+func NewRotatingFile(path string, maxSize int64, count int) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, maxSize: maxSize, count: count}
+	if err := f.rotate(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Write writes p to the log file, rotating the file first if p would take the
+// file beyond its maximum size.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Close closes the log file.
+func (f *RotatingFile) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *RotatingFile) rotate() error {
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+	if f.count > 0 {
+		os.Remove(f.rotated(f.count))
+		for i := f.count - 1; i > 0; i-- {
+			os.Rename(f.rotated(i), f.rotated(i+1))
+		}
+		os.Rename(f.path, f.rotated(1))
+	}
+	file, err := os.Create(f.path)
+	if err != nil {
+		return err
+	}
+	f.file, f.size = file, 0
+	return nil
+}
+
+func (f *RotatingFile) rotated(i int) string {
+	return fmt.Sprintf("%s.%d", f.path, i)
+}
@@ -0,0 +1,92 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+)
+
+func TestRotatingFile(t *testing.T) {
+	assert := assert.To(t)
+	dir, err := ioutil.TempDir("", "rotate")
+	if !assert.For("TempDir").ThatError(err).Succeeded() {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "log.txt")
+	f, err := log.NewRotatingFile(path, 8, 2)
+	if !assert.For("NewRotatingFile").ThatError(err).Succeeded() {
+		return
+	}
+	for _, s := range []string{"aaaa\n", "bbbb\n", "cccc\n", "dddd\n"} {
+		f.Write([]byte(s))
+	}
+	f.Close()
+
+	for _, test := range []struct {
+		path     string
+		expected string
+	}{
+		{path, "dddd\n"},
+		{path + ".1", "cccc\n"},
+		{path + ".2", "bbbb\n"},
+	} {
+		data, err := ioutil.ReadFile(test.path)
+		assert.For("ReadFile(%v)", test.path).ThatError(err).Succeeded()
+		assert.For("Content(%v)", test.path).ThatString(string(data)).Equals(test.expected)
+	}
+	_, err = os.Stat(path + ".3")
+	assert.For("Stat(.3)").That(os.IsNotExist(err)).Equals(true)
+}
+
+func TestRotatingFileWholeLines(t *testing.T) {
+	assert := assert.To(t)
+	dir, err := ioutil.TempDir("", "rotate")
+	if !assert.For("TempDir").ThatError(err).Succeeded() {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "log.txt")
+	f, err := log.NewRotatingFile(path, 9, 1)
+	if !assert.For("NewRotatingFile").ThatError(err).Succeeded() {
+		return
+	}
+	w := log.IOWriter(f)
+	w("aaaa", log.Info)
+	w("bbbb", log.Info)
+	f.Close()
+
+	// Each line must be written in a single call, so that rotation does not
+	// split it across files.
+	for _, test := range []struct {
+		path     string
+		expected string
+	}{
+		{path, "bbbb\n"},
+		{path + ".1", "aaaa\n"},
+	} {
+		data, err := ioutil.ReadFile(test.path)
+		assert.For("ReadFile(%v)", test.path).ThatError(err).Succeeded()
+		assert.For("Content(%v)", test.path).ThatString(string(data)).Equals(test.expected)
+	}
+}
@@ -16,6 +16,7 @@ package log
 
 import (
 	"bytes"
+	"io"
 	"os"
 )
 
@@ -30,17 +31,22 @@ func Std() Writer {
 		if severity >= Error {
 			out = os.Stderr
 		}
-		out.WriteString(text)
-		out.WriteString("\n")
+		// The line is written with a single call so that it is not split.
+		out.WriteString(text + "\n")
 	}
 }
 
 // Stdout returns a Writer that writes to stdout for all severities.
 func Stdout() Writer {
 	return func(text string, severity Severity) {
-		out := os.Stdout
-		out.WriteString(text)
-		out.WriteString("\n")
+		os.Stdout.WriteString(text + "\n")
+	}
+}
+
+// IOWriter returns a Writer that writes to w for all severities.
+func IOWriter(w io.Writer) Writer {
+	return func(text string, severity Severity) {
+		io.WriteString(w, text+"\n")
 	}
 }
 
@@ -18,13 +18,25 @@
 # build and the file will be recreated, check in the new version.
 
 set(files
+    align.go
     analysis.go
     apk.go
     apk.pb.go
     apk.proto
     debugifier.go
+    debugifier_test.go
     doc.go
+    key.go
+    keystore.go
+    keystore_test.go
+    pkcs12.go
+    pkcs12_test.go
+    rc2.go
+    sign.go
+    sign_test.go
+    sign_v1.go
+    sign_v2.go
 )
 set(dirs
-    
+    testdata
 )
@@ -0,0 +1,439 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apk
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+)
+
+const (
+	// alignment is the byte alignment of the data of uncompressed entries,
+	// matching "zipalign 4".
+	alignment = 4
+	// alignmentExtraID is the zip extra field ID used by the Android tools to
+	// pad local file headers so that the entry data is aligned.
+	alignmentExtraID = 0xd935
+	// alignmentExtraSize is the size of an alignment extra field without any
+	// padding: the ID, the data size and the alignment.
+	alignmentExtraSize = 6
+	// localHeaderSize is the size of a zip local file header without the name
+	// and extra fields.
+	localHeaderSize = 30
+
+	localHeaderSignature   = 0x04034b50
+	centralHeaderSignature = 0x02014b50
+	eocdSignature          = 0x06054b50
+	eocdSize               = 22
+	zipVersion             = 20 // 2.0, the version supporting deflate.
+
+	// The zip64 extensions, used when a size, offset or entry count does not
+	// fit the fields of the original format.
+	zip64ExtraID          = 0x0001
+	zip64EOCDSignature    = 0x06064b50
+	zip64EOCDSize         = 56
+	zip64LocatorSignature = 0x07064b50
+	zip64LocatorSize      = 20
+	zip64Version          = 45 // 4.5, the version supporting zip64.
+	uint16Max             = 0xffff
+	uint32Max             = 0xffffffff
+)
+
+// Entry is a single file to be written to an APK.
+// Entries returned by ReadEntries hold no Data, instead their compressed
+// content is copied from the source archive when they are written, so that
+// the archive is never held in memory. SetData replaces the content of an
+// entry.
+type Entry struct {
+	Name         string
+	Method       uint16 // zip.Store or zip.Deflate
+	ModifiedTime uint16 // MS-DOS time
+	ModifiedDate uint16 // MS-DOS date
+	Data         []byte // The uncompressed file content
+
+	source *zip.File   // The file in the source archive, or nil.
+	raw    io.ReaderAt // The source archive.
+}
+
+// ReadEntries returns the entries of the zip archive r, whose files are files.
+// The content of the entries is read from r when it is needed.
+func ReadEntries(r io.ReaderAt, files []*zip.File) []Entry {
+	out := make([]Entry, 0, len(files))
+	for _, f := range files {
+		out = append(out, Entry{
+			Name:         f.Name,
+			Method:       f.Method,
+			ModifiedTime: f.ModifiedTime,
+			ModifiedDate: f.ModifiedDate,
+			source:       f,
+			raw:          r,
+		})
+	}
+	return out
+}
+
+// SetData replaces the content of the entry with data.
+func (e *Entry) SetData(data []byte) {
+	e.Data, e.source, e.raw = data, nil, nil
+}
+
+// Open returns a reader of the uncompressed content of the entry.
+func (e Entry) Open() (io.ReadCloser, error) {
+	if e.source != nil {
+		return e.source.Open()
+	}
+	return ioutil.NopCloser(bytes.NewReader(e.Data)), nil
+}
+
+// Content returns the uncompressed content of the entry.
+func (e Entry) Content() ([]byte, error) {
+	if e.source == nil {
+		return e.Data, nil
+	}
+	r, err := e.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// zipEntry is an entry prepared for writing to a zip archive.
+type zipEntry struct {
+	Entry
+	method       uint16
+	crc          uint32
+	csize, usize uint64
+	compressed   *io.SectionReader
+}
+
+// prepare returns the entry prepared for writing, compressing its Data if it
+// is not copied from a source archive.
+func prepare(e Entry) (*zipEntry, error) {
+	if f := e.source; f != nil {
+		if f.Method != zip.Store && f.Method != zip.Deflate {
+			return nil, zip.ErrAlgorithm
+		}
+		offset, err := f.DataOffset()
+		if err != nil {
+			return nil, err
+		}
+		return &zipEntry{
+			Entry:      e,
+			method:     f.Method,
+			crc:        f.CRC32,
+			csize:      f.CompressedSize64,
+			usize:      f.UncompressedSize64,
+			compressed: io.NewSectionReader(e.raw, offset, int64(f.CompressedSize64)),
+		}, nil
+	}
+	data := e.Data
+	switch e.Method {
+	case zip.Store:
+	case zip.Deflate:
+		buf := &bytes.Buffer{}
+		fw, err := flate.NewWriter(buf, flate.DefaultCompression)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := fw.Write(e.Data); err != nil {
+			return nil, err
+		}
+		if err := fw.Close(); err != nil {
+			return nil, err
+		}
+		data = buf.Bytes()
+	default:
+		return nil, zip.ErrAlgorithm
+	}
+	return &zipEntry{
+		Entry:      e,
+		method:     e.Method,
+		crc:        crc32.ChecksumIEEE(e.Data),
+		csize:      uint64(len(data)),
+		usize:      uint64(len(e.Data)),
+		compressed: io.NewSectionReader(bytes.NewReader(data), 0, int64(len(data))),
+	}, nil
+}
+
+// localHeader returns the local file header of the entry written at offset,
+// padded so that the data of an uncompressed entry is aligned, as zipalign
+// does.
+func (e *zipEntry) localHeader(offset uint64) []byte {
+	h := e.header()
+	var extra []byte
+	if e.csize >= uint32Max || e.usize >= uint32Max {
+		// The local zip64 extra field must hold both sizes.
+		h.version = zip64Version
+		h.csize, h.usize = uint32Max, uint32Max
+		extra = zip64Extra(e.usize, e.csize)
+	}
+	if e.method == zip.Store {
+		extra = append(extra, alignmentExtra(offset+uint64(localHeaderSize+len(e.Name)+len(extra)))...)
+	}
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.LittleEndian, uint32(localHeaderSignature))
+	binary.Write(buf, binary.LittleEndian, h)
+	binary.Write(buf, binary.LittleEndian, uint16(len(e.Name)))
+	binary.Write(buf, binary.LittleEndian, uint16(len(extra)))
+	buf.WriteString(e.Name)
+	buf.Write(extra)
+	return buf.Bytes()
+}
+
+// centralHeader returns the central directory file header of the entry
+// written at offset.
+func (e *zipEntry) centralHeader(offset uint64) []byte {
+	h := e.header()
+	tail := centralHeaderTail{nameSize: uint16(len(e.Name)), offset: uint32(offset)}
+	// Only the fields that overflow are held in the central zip64 extra field,
+	// in this order.
+	extra := []uint64{}
+	if e.usize >= uint32Max {
+		h.usize = uint32Max
+		extra = append(extra, e.usize)
+	}
+	if e.csize >= uint32Max {
+		h.csize = uint32Max
+		extra = append(extra, e.csize)
+	}
+	if offset >= uint32Max {
+		tail.offset = uint32Max
+		extra = append(extra, offset)
+	}
+	if len(extra) > 0 {
+		h.version = zip64Version
+		tail.extraSize = uint16(4 + 8*len(extra))
+	}
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.LittleEndian, uint32(centralHeaderSignature))
+	binary.Write(buf, binary.LittleEndian, h.version) // Version made by.
+	binary.Write(buf, binary.LittleEndian, h)
+	binary.Write(buf, binary.LittleEndian, tail)
+	buf.WriteString(e.Name)
+	if len(extra) > 0 {
+		binary.Write(buf, binary.LittleEndian, uint16(zip64ExtraID))
+		binary.Write(buf, binary.LittleEndian, uint16(8*len(extra)))
+		binary.Write(buf, binary.LittleEndian, extra)
+	}
+	return buf.Bytes()
+}
+
+// header returns the fields common to the local and central file headers of
+// the entry, assuming the sizes fit in them.
+func (e *zipEntry) header() zipHeader {
+	h := zipHeader{
+		version: zipVersion,
+		method:  e.method,
+		time:    e.ModifiedTime,
+		date:    e.ModifiedDate,
+		crc:     e.crc,
+		csize:   uint32(e.csize),
+		usize:   uint32(e.usize),
+	}
+	if !isASCII(e.Name) {
+		h.flags |= 0x800 // Names are UTF-8.
+	}
+	return h
+}
+
+// zipLayout is the size and position of the parts of a zip archive.
+type zipLayout struct {
+	count    int    // The number of entries.
+	cdOffset uint64 // The offset of the central directory.
+	cdSize   uint64 // The size of the central directory.
+	zip64    bool   // Whether any zip64 extensions are needed.
+}
+
+// layoutAligned returns the layout of the entries written by writeAligned,
+// with gap bytes inserted before the central directory.
+func layoutAligned(entries []*zipEntry, gap uint64) zipLayout {
+	l := zipLayout{count: len(entries), zip64: len(entries) >= uint16Max}
+	offset := uint64(0)
+	for _, e := range entries {
+		l.cdSize += uint64(len(e.centralHeader(offset)))
+		if e.csize >= uint32Max || e.usize >= uint32Max || offset >= uint32Max {
+			l.zip64 = true
+		}
+		offset += uint64(len(e.localHeader(offset))) + e.csize
+	}
+	l.cdOffset = offset + gap
+	if l.cdOffset >= uint32Max || l.cdSize >= uint32Max {
+		l.zip64 = true
+	}
+	return l
+}
+
+// writeAligned writes the local headers and data of the entries to w as the
+// start of a zip archive, padding the local headers of uncompressed entries so
+// that their data starts on a 4-byte boundary, as zipalign does. It returns
+// the central directory of the entries.
+// The archive is written directly rather than with archive/zip so that the
+// offset of each entry is known, and no data descriptors are used. Zip64
+// extensions are only used where a size or offset requires them.
+func writeAligned(w io.Writer, entries []*zipEntry) ([]byte, error) {
+	cd := &bytes.Buffer{}
+	offset := uint64(0)
+	for _, e := range entries {
+		header := e.localHeader(offset)
+		if _, err := w.Write(header); err != nil {
+			return nil, err
+		}
+		if n, err := io.Copy(w, e.compressed); err != nil {
+			return nil, err
+		} else if uint64(n) != e.csize {
+			return nil, io.ErrUnexpectedEOF
+		}
+		cd.Write(e.centralHeader(offset))
+		offset += uint64(len(header)) + e.csize
+	}
+	return cd.Bytes(), nil
+}
+
+// endOfCentralDirectory returns the end of central directory record for the
+// layout, preceded by the zip64 end of central directory record and locator
+// if the layout needs zip64 extensions.
+func endOfCentralDirectory(l zipLayout) []byte {
+	buf := &bytes.Buffer{}
+	if !l.zip64 {
+		binary.Write(buf, binary.LittleEndian, uint32(eocdSignature))
+		binary.Write(buf, binary.LittleEndian, eocdRecord{
+			diskEntries:  uint16(l.count),
+			totalEntries: uint16(l.count),
+			cdSize:       uint32(l.cdSize),
+			cdOffset:     uint32(l.cdOffset),
+		})
+		return buf.Bytes()
+	}
+	binary.Write(buf, binary.LittleEndian, uint32(zip64EOCDSignature))
+	binary.Write(buf, binary.LittleEndian, zip64EOCDRecord{
+		size:         zip64EOCDSize - 12, // Excluding the signature and size.
+		versionMade:  zip64Version,
+		version:      zip64Version,
+		diskEntries:  uint64(l.count),
+		totalEntries: uint64(l.count),
+		cdSize:       l.cdSize,
+		cdOffset:     l.cdOffset,
+	})
+	binary.Write(buf, binary.LittleEndian, uint32(zip64LocatorSignature))
+	binary.Write(buf, binary.LittleEndian, zip64Locator{
+		eocdOffset: l.cdOffset + l.cdSize,
+		disks:      1,
+	})
+	// The fields of the original record are all saturated so that readers
+	// look for the zip64 record.
+	binary.Write(buf, binary.LittleEndian, uint32(eocdSignature))
+	binary.Write(buf, binary.LittleEndian, eocdRecord{
+		diskEntries:  uint16Max,
+		totalEntries: uint16Max,
+		cdSize:       uint32Max,
+		cdOffset:     uint32Max,
+	})
+	return buf.Bytes()
+}
+
+// zipHeader holds the fields common to the local and central file headers,
+// following the signature (and for the central header, the version made by).
+type zipHeader struct {
+	version uint16
+	flags   uint16
+	method  uint16
+	time    uint16
+	date    uint16
+	crc     uint32
+	csize   uint32
+	usize   uint32
+}
+
+// centralHeaderTail holds the fields of a central file header that follow the
+// name size.
+type centralHeaderTail struct {
+	nameSize      uint16
+	extraSize     uint16
+	commentSize   uint16
+	disk          uint16
+	internalAttrs uint16
+	externalAttrs uint32
+	offset        uint32
+}
+
+// eocdRecord holds the fields of the end of central directory record that
+// follow the signature.
+type eocdRecord struct {
+	disk         uint16
+	cdDisk       uint16
+	diskEntries  uint16
+	totalEntries uint16
+	cdSize       uint32
+	cdOffset     uint32
+	commentSize  uint16
+}
+
+// zip64EOCDRecord holds the fields of the zip64 end of central directory
+// record that follow the signature.
+type zip64EOCDRecord struct {
+	size         uint64
+	versionMade  uint16
+	version      uint16
+	disk         uint32
+	cdDisk       uint32
+	diskEntries  uint64
+	totalEntries uint64
+	cdSize       uint64
+	cdOffset     uint64
+}
+
+// zip64Locator holds the fields of the zip64 end of central directory locator
+// that follow the signature.
+type zip64Locator struct {
+	eocdDisk   uint32
+	eocdOffset uint64
+	disks      uint32
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// zip64Extra returns the zip64 extra field of a local file header.
+func zip64Extra(usize, csize uint64) []byte {
+	extra := make([]byte, 20)
+	binary.LittleEndian.PutUint16(extra[0:], zip64ExtraID)
+	binary.LittleEndian.PutUint16(extra[2:], 16)
+	binary.LittleEndian.PutUint64(extra[4:], usize)
+	binary.LittleEndian.PutUint64(extra[12:], csize)
+	return extra
+}
+
+// alignmentExtra returns the extra field to place at offset so that the data
+// following it is aligned.
+func alignmentExtra(offset uint64) []byte {
+	padding := (alignment - (offset+alignmentExtraSize)%alignment) % alignment
+	extra := make([]byte, alignmentExtraSize+padding)
+	binary.LittleEndian.PutUint16(extra[0:], alignmentExtraID)
+	binary.LittleEndian.PutUint16(extra[2:], uint16(len(extra)-4))
+	binary.LittleEndian.PutUint16(extra[4:], alignment)
+	return extra
+}
@@ -20,6 +20,8 @@ import (
 	"path/filepath"
 
 	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/android/binaryxml"
+	"github.com/google/gapid/core/os/android/manifest"
 )
 
 // engineSignatures is used to identify the middleware engine used based on
@@ -44,17 +46,75 @@ func Analyze(ctx context.Context, apkData []byte) (*Information, error) {
 	if err != nil {
 		return nil, log.Err(ctx, err, "Finding launch activity")
 	}
-	return &Information{
-		Name:        m.Package, // TODO
+	info := describe(ctx, files, m)
+	info.Activity = activity
+	info.Action = action
+	return info, nil
+}
+
+// Describe parses the APK file and returns the APK's information, except for
+// its launch activity, which the APK does not need to have.
+func Describe(ctx context.Context, apkData []byte) (*Information, error) {
+	files, err := Read(ctx, apkData)
+	if err != nil {
+		return nil, err
+	}
+	m, err := GetManifest(ctx, files)
+	if err != nil {
+		return nil, err
+	}
+	return describe(ctx, files, m), nil
+}
+
+// describe returns the information about the APK with the given files and
+// manifest. Manifest values that refer to resources are resolved with the
+// APK's resource table when it has one.
+func describe(ctx context.Context, files []*zip.File, m manifest.Manifest) *Information {
+	info := &Information{
+		Name:        m.Package,
 		VersionCode: int32(m.VersionCode),
 		VersionName: m.VersionName,
 		Package:     m.Package,
-		Activity:    activity,
-		Action:      action,
 		Engine:      engine(files),
 		ABI:         GatherABIs(files),
 		Debuggable:  m.Application.Debuggable,
-	}, nil
+	}
+	table, err := GetResources(ctx, files)
+	if err != nil {
+		log.W(ctx, "Resources will not be resolved: %v", err)
+		return info
+	}
+	if label := table.ResolveAttribute(m.Application.Label); label != "" {
+		if _, isRef := binaryxml.ParseReference(label); !isRef {
+			info.Label = label
+		}
+	}
+	info.VersionName = table.ResolveAttribute(m.VersionName)
+	info.Icons = icons(table, m.Application.Icon)
+	return info
+}
+
+// icons returns the icon files for each density of the icon resource.
+func icons(table *binaryxml.ResourceTable, icon string) []*Icon {
+	id, ok := binaryxml.ParseReference(icon)
+	if !ok {
+		return nil
+	}
+	out := []*Icon{}
+	for _, c := range table.Lookup(id) {
+		v := c.Entry.Value
+		if ref, ok := v.Reference(); ok {
+			if v, ok = table.Resolve(ref); !ok {
+				continue
+			}
+		}
+		density := c.Config.DensityName()
+		if density == "" {
+			density = "default"
+		}
+		out = append(out, &Icon{Density: density, Path: v.String()})
+	}
+	return out
 }
 
 func engine(files []*zip.File) string {
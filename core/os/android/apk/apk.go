@@ -29,9 +29,11 @@ import (
 )
 
 const (
-	mainfestPath       = "AndroidManifest.xml"
-	ErrMissingManifest = fault.Const("Couldn't find APK's manifest file.")
-	ErrInvalidAPK      = fault.Const("File is not an APK.")
+	mainfestPath        = "AndroidManifest.xml"
+	resourcesPath       = "resources.arsc"
+	ErrMissingManifest  = fault.Const("Couldn't find APK's manifest file.")
+	ErrMissingResources = fault.Const("Couldn't find APK's resource table.")
+	ErrInvalidAPK       = fault.Const("File is not an APK.")
 )
 
 // Read parses the APK file, returning its contents.
@@ -67,9 +69,33 @@ func GetManifest(ctx context.Context, files []*zip.File) (manifest.Manifest, err
 	return manifest.Parse(ctx, manifestXML)
 }
 
+// GetResources returns the decoded resource table of the APK.
+func GetResources(ctx context.Context, files []*zip.File) (*binaryxml.ResourceTable, error) {
+	resourcesZipFile := findFile(files, resourcesPath)
+	if resourcesZipFile == nil {
+		return nil, log.Err(ctx, ErrMissingResources, "")
+	}
+	resourcesFile, err := resourcesZipFile.Open()
+	if err != nil {
+		return nil, log.Err(ctx, err, "Couldn't open APK's resource table")
+	}
+	defer resourcesFile.Close()
+
+	resourcesData, err := ioutil.ReadAll(resourcesFile)
+	if err != nil {
+		return nil, log.Err(ctx, err, "Couldn't read APK's resource table")
+	}
+
+	return binaryxml.DecodeResourceTable(ctx, resourcesData)
+}
+
 func findManifest(files []*zip.File) *zip.File {
+	return findFile(files, mainfestPath)
+}
+
+func findFile(files []*zip.File, name string) *zip.File {
 	for _, file := range files {
-		if file.Name == mainfestPath {
+		if file.Name == name {
 			return file
 		}
 	}
@@ -17,154 +17,111 @@ package apk
 import (
 	"archive/zip"
 	"context"
-	"io"
-	"io/ioutil"
-	"os"
-	"os/exec"
-	"os/user"
-	"path/filepath"
-	"regexp"
-	"strings"
 
 	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/core/os/android/binaryxml"
 )
 
-// ApkDebugifier makes an APK debuggable. The fields in the struct
-// are used to configure the various paths and passwords required,
-// as well as providing a log context.
-// Intended use is ApkDebugifier{Ctx: ..., JarSignCmd: "jarsigner", ..., KeyStorePath: "..."}.Run(...).
+const (
+	networkSecurityConfigKey  = "gapid_network_security_config"
+	networkSecurityConfigPath = "res/xml/" + networkSecurityConfigKey + ".xml"
+)
+
+// ApkDebugifier makes an APK debuggable. The fields in the struct configure
+// the key used to re-sign the APK and further manifest changes, as well as
+// providing a log context.
+// Intended use is ApkDebugifier{Ctx: ..., Key: ...}.Run(...).
 type ApkDebugifier struct {
-	Ctx          context.Context // log context
-	JarSignCmd   string          // path to the jarsigner binary
-	ZipAlignCmd  string          // path to the zipalign binary
-	KeyPass      string          // key passphrase
-	KeyAlias     string          // key alias for signing
-	StorePass    string          // keystore passphrase
-	KeyStorePath string          // path to keystore (e.g. /path/to/debug.keystore)
+	Ctx                   context.Context // log context
+	Key                   *SigningKey     // key to sign with, a new debug key is generated if nil
+	Permissions           []string        // permissions to add to the manifest
+	NetworkSecurityConfig bool            // whether to trust user certificates and permit cleartext traffic
 }
 
 // Run takes the path (src) to an APK, sets the debuggable flag in its manifest,
-// re-signs and aligns it, and saves it to a different path (dst).
+// applies the other configured changes, re-signs and aligns it, and saves it
+// to dst, which may be the same path as src.
 func (a ApkDebugifier) Run(src string, dst string) error {
-	tempFile, err := ioutil.TempFile("", "debuggable.apk")
-	if err != nil {
-		return err
+	log.I(a.Ctx, "Making apk %s debuggable", src)
+	key := a.Key
+	if key == nil {
+		log.I(a.Ctx, "Generating debug signing key")
+		var err error
+		if key, err = GenerateDebugKey(); err != nil {
+			return err
+		}
 	}
-	defer os.Remove(tempFile.Name())
+	log.I(a.Ctx, "Signing and aligning apk to %s", dst)
+	return rewrite(src, dst, key, a.makeApkDebuggable)
+}
 
-	log.I(a.Ctx, "Making apk %s debuggable and saving to %s", src, tempFile.Name())
-	err = a.makeApkDebuggableAndRemoveSignatureFiles(src, tempFile)
-	if err != nil {
-		return err
+func (a ApkDebugifier) makeApkDebuggable(entries []Entry) ([]Entry, error) {
+	manifest, resources, config := -1, -1, -1
+	for i, e := range entries {
+		switch e.Name {
+		case mainfestPath:
+			manifest = i
+		case resourcesPath:
+			resources = i
+		case networkSecurityConfigPath:
+			config = i
+		}
 	}
-
-	log.I(a.Ctx, "Signing apk %s", tempFile.Name())
-	err = a.jarSign(tempFile.Name())
-	if err != nil {
-		return err
+	if manifest < 0 {
+		return nil, ErrMissingManifest
 	}
 
-	log.I(a.Ctx, "Zipaligning %s to %s", tempFile.Name(), dst)
-	err = a.zipAlign(tempFile.Name(), dst)
+	log.I(a.Ctx, "Modifying manifest file")
+	data, err := entries[manifest].Content()
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	return nil
-}
-
-func expandHomeDir(p string) string {
-	if !strings.HasPrefix(p, "~") {
-		return p
-	}
-	user, err := user.Current()
+	doc, err := binaryxml.DecodeDocument(data)
 	if err != nil {
-		return p // shrug
+		return nil, err
 	}
-	return filepath.Join(user.HomeDir, strings.TrimLeft(p, "~"))
-}
-
-func (a ApkDebugifier) execCommand(name string, args ...string) error {
-	cmd := exec.Command(name, args...)
-	log.I(a.Ctx, "Executing %v", cmd.Args)
-	out, err := cmd.CombinedOutput()
-	logger := log.From(a.Ctx).Writer(log.Debug)
-	if err != nil {
-		logger = log.From(a.Ctx).Writer(log.Error)
+	if err := binaryxml.SetDebuggable(doc); err != nil {
+		return nil, err
 	}
-	logger.Write(out)
-	return err
-}
-
-func (a ApkDebugifier) jarSign(apk string) error {
-	return a.execCommand(
-		expandHomeDir(a.JarSignCmd),
-		"-sigalg", "SHA1withRSA",
-		"-digestalg", "SHA1",
-		"-keystore", expandHomeDir(a.KeyStorePath),
-		"-storepass", a.StorePass,
-		"-keypass", a.KeyPass,
-		apk,
-		a.KeyAlias,
-	)
-}
-
-func (a ApkDebugifier) zipAlign(src string, dst string) error {
-	return a.execCommand(expandHomeDir(a.ZipAlignCmd), "-v", "-f", "4", src, dst)
-}
-
-func (a ApkDebugifier) makeApkDebuggableAndRemoveSignatureFiles(src string, outFile *os.File) error {
-	inZip, err := zip.OpenReader(src)
-	if err != nil {
-		return err
+	if len(a.Permissions) > 0 {
+		log.I(a.Ctx, "Adding permissions %v", a.Permissions)
+		if err := binaryxml.AddPermissions(doc, a.Permissions...); err != nil {
+			return nil, err
+		}
 	}
-	defer inZip.Close()
-
-	defer outFile.Close()
-	w := zip.NewWriter(outFile)
-	defer w.Close()
-
-	jarSignatureFilePattern := regexp.MustCompile(`META-INF/[^/]*(DSA|RSA|SF)`)
-
-	for _, zf := range inZip.File {
-		if jarSignatureFilePattern.MatchString(zf.Name) {
-			log.I(a.Ctx, "Skipping file %s", zf.Name)
-			continue
+	if a.NetworkSecurityConfig {
+		if resources < 0 {
+			return nil, ErrMissingResources
 		}
-
-		fr, err := zf.Open()
+		log.I(a.Ctx, "Adding network security config")
+		table, err := entries[resources].Content()
 		if err != nil {
-			return err
+			return nil, err
 		}
-		defer fr.Close()
-
-		fw, err := w.CreateHeader(&zip.FileHeader{
-			Name:         zf.Name,
-			Method:       zf.Method,
-			ModifiedDate: zf.ModifiedDate,
-			ModifiedTime: zf.ModifiedTime,
-		})
+		table, id, err := binaryxml.AddFileResource(table,
+			"xml", networkSecurityConfigKey, networkSecurityConfigPath)
 		if err != nil {
-			return err
+			return nil, err
 		}
-
-		if zf.Name == "AndroidManifest.xml" {
-			log.I(a.Ctx, "Modifying manifest file")
-			err := binaryxml.SetDebuggableFlag(fr, fw)
-			if err != nil {
-				return err
-			}
-		} else {
-			_, err = io.Copy(fw, fr)
+		entries[resources].SetData(table)
+		if err := binaryxml.SetNetworkSecurityConfig(doc, id); err != nil {
+			return nil, err
 		}
-
-		if err != nil {
-			return err
+		data := binaryxml.DebugNetworkSecurityConfig().Encode()
+		if config >= 0 {
+			entries[config].SetData(data)
+		} else {
+			entries = append(entries, Entry{
+				Name:         networkSecurityConfigPath,
+				Method:       zip.Deflate,
+				ModifiedTime: entries[manifest].ModifiedTime,
+				ModifiedDate: entries[manifest].ModifiedDate,
+				Data:         data,
+			})
 		}
 	}
-
-	return nil
+	entries[manifest].SetData(doc.Encode())
+	return entries, nil
 }
 
 func IsApkDebuggable(ctx context.Context, apk string) (bool, error) {
@@ -0,0 +1,84 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apk
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/android/binaryxml"
+)
+
+func TestApkDebugifier(t *testing.T) {
+	ctx := log.Testing(t)
+	key, err := GenerateDebugKey()
+	assert.With(ctx).ThatError(err).Succeeded()
+	dir, err := ioutil.TempDir("", "apk")
+	assert.With(ctx).ThatError(err).Succeeded()
+	defer os.RemoveAll(dir)
+	dst := filepath.Join(dir, "debuggable.apk")
+
+	err = ApkDebugifier{
+		Ctx:                   ctx,
+		Key:                   key,
+		Permissions:           []string{"com.example.permission.TEST"},
+		NetworkSecurityConfig: true,
+	}.Run("testdata/calculator.apk", dst)
+	assert.With(ctx).ThatError(err).Succeeded()
+
+	r, err := zip.OpenReader(dst)
+	assert.With(ctx).ThatError(err).Succeeded()
+	defer r.Close()
+
+	// The network security config is added as the first resource of a new xml
+	// type in the resource table.
+	const configID = 0x7f030000
+	table, err := GetResources(ctx, r.File)
+	assert.With(ctx).ThatError(err).Succeeded()
+	name, _ := table.Name(configID)
+	assert.For(ctx, "resource name").That(name).Equals("com.example:xml/" + networkSecurityConfigKey)
+	assert.For(ctx, "resource path").That(table.ResolveAttribute("@0x7f030000")).Equals(networkSecurityConfigPath)
+
+	m, err := GetManifest(ctx, r.File)
+	assert.With(ctx).ThatError(err).Succeeded()
+	assert.For(ctx, "debuggable").That(m.Application.Debuggable).Equals(true)
+	xml, err := GetManifestXML(ctx, r.File)
+	assert.With(ctx).ThatError(err).Succeeded()
+	assert.For(ctx, "manifest").ThatString(xml).Contains(`android:networkSecurityConfig="@0x7f030000"`)
+	assert.For(ctx, "manifest").ThatString(xml).Contains(`android:name="com.example.permission.TEST"`)
+
+	config := findFile(r.File, networkSecurityConfigPath)
+	assert.For(ctx, "config entry").That(config != nil).Equals(true)
+	fr, err := config.Open()
+	assert.With(ctx).ThatError(err).Succeeded()
+	data, err := ioutil.ReadAll(fr)
+	fr.Close()
+	assert.With(ctx).ThatError(err).Succeeded()
+	doc, err := binaryxml.DecodeDocument(data)
+	assert.With(ctx).ThatError(err).Succeeded()
+	assert.For(ctx, "config").That(doc.String()).Equals(binaryxml.DebugNetworkSecurityConfig().String())
+
+	data, err = ioutil.ReadFile(dst)
+	assert.With(ctx).ThatError(err).Succeeded()
+	info, err := Describe(ctx, data)
+	assert.With(ctx).ThatError(err).Succeeded()
+	assert.For(ctx, "package").That(info.Package).Equals("com.google.android.calculator")
+	assert.For(ctx, "name").That(info.Name).Equals(info.Package)
+}
@@ -14,7 +14,8 @@
 
 // Package apk provides methods to get information (e.g. manifests, ABIs)
 // from APKs, as well as taking an APK and making it debuggable, for testing
-// purposes.
+// purposes. APKs are aligned and signed natively, without the need for the
+// jarsigner and zipalign tools.
 package apk
 
 // The following are the imports that generated source files pull in when present
@@ -0,0 +1,184 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apk
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/gapid/core/fault"
+)
+
+const (
+	ErrNoPrivateKey  = fault.Const("No RSA private key found in key file.")
+	ErrNoCertificate = fault.Const("No certificate found in key file.")
+	ErrKeyMismatch   = fault.Const("Certificate does not match the private key.")
+
+	debugKeyBits     = 2048
+	debugKeyValidity = 30 * 365 * 24 * time.Hour
+)
+
+// SigningKey is an RSA private key and the certificate for its public key,
+// used to sign APKs.
+type SigningKey struct {
+	Key         *rsa.PrivateKey
+	Certificate *x509.Certificate
+}
+
+// GenerateDebugKey returns a new RSA key with a self-signed certificate
+// matching the subject used by the Android SDK's debug keystore.
+func GenerateDebugKey() (*SigningKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, debugKeyBits)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 63))
+	if err != nil {
+		return nil, err
+	}
+	name := pkix.Name{CommonName: "Android Debug", Organization: []string{"Android"}, Country: []string{"US"}}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:       serial,
+		Subject:            name,
+		Issuer:             name,
+		NotBefore:          now,
+		NotAfter:           now.Add(debugKeyValidity),
+		SignatureAlgorithm: x509.SHA256WithRSA,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &SigningKey{Key: key, Certificate: cert}, nil
+}
+
+// LoadSigningKey reads a signing key from the PEM file at path. The file must
+// hold an RSA private key, in either PKCS#1 or PKCS#8 form, and the
+// certificate for it.
+func LoadSigningKey(path string) (*SigningKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	k := &SigningKey{}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		switch block.Type {
+		case "RSA PRIVATE KEY":
+			if k.Key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+				return nil, err
+			}
+		case "PRIVATE KEY":
+			key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			rsaKey, ok := key.(*rsa.PrivateKey)
+			if !ok {
+				return nil, ErrNoPrivateKey
+			}
+			k.Key = rsaKey
+		case "CERTIFICATE":
+			if k.Certificate == nil {
+				if k.Certificate, err = x509.ParseCertificate(block.Bytes); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	if err := k.check(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// check returns an error if the key is incomplete or the certificate is not
+// for the key.
+func (k *SigningKey) check() error {
+	switch {
+	case k.Key == nil:
+		return ErrNoPrivateKey
+	case k.Certificate == nil:
+		return ErrNoCertificate
+	}
+	if pub, ok := k.Certificate.PublicKey.(*rsa.PublicKey); !ok ||
+		pub.N.Cmp(k.Key.N) != 0 || pub.E != k.Key.E {
+		return ErrKeyMismatch
+	}
+	return nil
+}
+
+// LoadOrCreateDebugKey reads the signing key with the alias from a debug
+// keystore at path, such as the Android SDK's, as LoadKeyStore does. If the
+// file does not exist then a new debug key is generated and saved there in a
+// JKS keystore, which all versions of the SDK can read. This keeps APKs signed
+// by the SDK and by this package upgradeable in place.
+// If path is a PEM file then the key is read from it as LoadSigningKey does.
+func LoadOrCreateDebugKey(path, storePassword, keyPassword, alias string) (*SigningKey, error) {
+	data, err := ioutil.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		k, err := GenerateDebugKey()
+		if err != nil {
+			return nil, err
+		}
+		if err := k.SaveKeyStore(path, storePassword, keyPassword, alias); err != nil {
+			return nil, err
+		}
+		return k, nil
+	case err != nil:
+		return nil, err
+	case bytes.Contains(data, []byte("-----BEGIN ")):
+		return LoadSigningKey(path)
+	case isKeyStore(data), isPKCS12(data):
+		return LoadKeyStore(path, storePassword, keyPassword, alias)
+	default:
+		return nil, ErrUnsupportedKeyStore
+	}
+}
+
+// Save writes the key and its certificate to the PEM file at path.
+func (k *SigningKey) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := pem.Encode(f, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k.Key)}); err != nil {
+		return err
+	}
+	return pem.Encode(f, &pem.Block{Type: "CERTIFICATE", Bytes: k.Certificate.Raw})
+}
@@ -0,0 +1,316 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apk
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/google/gapid/core/fault"
+)
+
+const (
+	ErrInvalidKeyStore     = fault.Const("Keystore is not a valid JKS or PKCS#12 keystore.")
+	ErrKeyStorePassword    = fault.Const("Keystore password is incorrect, or the keystore is corrupt.")
+	ErrKeyPassword         = fault.Const("Key password is incorrect.")
+	ErrKeyAliasNotFound    = fault.Const("Key alias not found in keystore.")
+	ErrUnsupportedKeyStore = fault.Const("Unsupported key file format, only JKS and PKCS#12 keystores and PEM files are supported.")
+
+	// DebugKeyStorePassword and DebugKeyAlias are the store and key password
+	// and the key alias of the Android SDK's debug keystore, and the defaults
+	// of LoadOrCreateDebugKey.
+	DebugKeyStorePassword = "android"
+	DebugKeyAlias         = "androiddebugkey"
+
+	// The constants of the JKS keystore format of the Java runtime.
+	jksMagic          = 0xfeedfeed
+	jksVersion        = 2
+	jksPrivateKeyTag  = 1
+	jksCertificateTag = 2
+	jksCertificate    = "X.509"
+	jksDigestWhitener = "Mighty Aphrodite"
+	jksSaltSize       = sha1.Size
+)
+
+// oidJKSKeyProtector identifies the proprietary algorithm that JKS keystores
+// protect private keys with.
+var oidJKSKeyProtector = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 42, 2, 17, 1, 1}
+
+// encryptedPrivateKeyInfo is the PKCS#8 structure of an encrypted private key.
+type encryptedPrivateKeyInfo struct {
+	Algorithm     algorithmIdentifier
+	EncryptedData []byte
+}
+
+// privateKeyInfo is the PKCS#8 structure of a private key.
+type privateKeyInfo struct {
+	Version    int
+	Algorithm  algorithmIdentifier
+	PrivateKey []byte
+}
+
+// isKeyStore returns true if data starts like a JKS keystore.
+func isKeyStore(data []byte) bool {
+	return len(data) >= 4 && binary.BigEndian.Uint32(data) == jksMagic
+}
+
+// LoadKeyStore reads the signing key with the alias from the JKS or PKCS#12
+// keystore at path, such as the Android SDK's debug keystore. The store
+// password protects the integrity of the keystore and the key password
+// protects the key.
+func LoadKeyStore(path, storePassword, keyPassword, alias string) (*SigningKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if isPKCS12(data) {
+		return loadPKCS12(data, storePassword, keyPassword, alias)
+	}
+	if !isKeyStore(data) || len(data) < sha1.Size {
+		return nil, ErrInvalidKeyStore
+	}
+	body, digest := data[:len(data)-sha1.Size], data[len(data)-sha1.Size:]
+	if subtle.ConstantTimeCompare(keyStoreDigest(body, storePassword), digest) != 1 {
+		return nil, ErrKeyStorePassword
+	}
+
+	r := &keyStoreReader{data: body[4:]}
+	version := r.uint32()
+	if version != 1 && version != jksVersion {
+		return nil, ErrInvalidKeyStore
+	}
+	var protected, certificate []byte
+	for i, count := uint32(0), r.uint32(); i < count && r.err == nil; i++ {
+		tag, name := r.uint32(), r.string()
+		r.bytes(8) // The creation time.
+		switch tag {
+		case jksPrivateKeyTag:
+			key := r.bytes(uint64(r.uint32()))
+			chain := r.uint32()
+			for j := uint32(0); j < chain && r.err == nil; j++ {
+				if version == jksVersion {
+					r.string() // The certificate type.
+				}
+				cert := r.bytes(uint64(r.uint32()))
+				if j == 0 && strings.EqualFold(name, alias) {
+					protected, certificate = key, cert
+				}
+			}
+		case jksCertificateTag:
+			if version == jksVersion {
+				r.string()
+			}
+			r.bytes(uint64(r.uint32()))
+		default:
+			return nil, ErrInvalidKeyStore
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if protected == nil {
+		return nil, ErrKeyAliasNotFound
+	}
+
+	info := encryptedPrivateKeyInfo{}
+	if _, err := asn1.Unmarshal(protected, &info); err != nil {
+		return nil, err
+	}
+	if !info.Algorithm.Algorithm.Equal(oidJKSKeyProtector) {
+		return nil, ErrUnsupportedKeyStore
+	}
+	plain, err := recoverJKSKey(info.EncryptedData, keyPassword)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(plain)
+	if err != nil {
+		return nil, err
+	}
+	k := &SigningKey{}
+	var ok bool
+	if k.Key, ok = key.(*rsa.PrivateKey); !ok {
+		return nil, ErrNoPrivateKey
+	}
+	if k.Certificate, err = x509.ParseCertificate(certificate); err != nil {
+		return nil, err
+	}
+	if err := k.check(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// SaveKeyStore writes the key and its certificate to a new JKS keystore at
+// path, under the alias, protected by the store and key passwords.
+func (k *SigningKey) SaveKeyStore(path, storePassword, keyPassword, alias string) error {
+	salt := make([]byte, jksSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	plain, err := asn1.Marshal(privateKeyInfo{
+		Algorithm:  algorithmIdentifier{Algorithm: oidRSAEncryption, Parameters: asn1Null},
+		PrivateKey: x509.MarshalPKCS1PrivateKey(k.Key),
+	})
+	if err != nil {
+		return err
+	}
+	protected, err := asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm:     algorithmIdentifier{Algorithm: oidJKSKeyProtector, Parameters: asn1Null},
+		EncryptedData: protectJKSKey(plain, keyPassword, salt),
+	})
+	if err != nil {
+		return err
+	}
+
+	buf := &bytes.Buffer{}
+	writeString := func(s string) {
+		binary.Write(buf, binary.BigEndian, uint16(len(s)))
+		buf.WriteString(s)
+	}
+	binary.Write(buf, binary.BigEndian, []uint32{jksMagic, jksVersion, 1, jksPrivateKeyTag})
+	writeString(strings.ToLower(alias)) // JKS aliases are case-insensitive.
+	binary.Write(buf, binary.BigEndian, time.Now().UnixNano()/int64(time.Millisecond))
+	binary.Write(buf, binary.BigEndian, uint32(len(protected)))
+	buf.Write(protected)
+	binary.Write(buf, binary.BigEndian, uint32(1)) // The certificate chain length.
+	writeString(jksCertificate)
+	binary.Write(buf, binary.BigEndian, uint32(len(k.Certificate.Raw)))
+	buf.Write(k.Certificate.Raw)
+	buf.Write(keyStoreDigest(buf.Bytes(), storePassword))
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0600)
+}
+
+// keyStoreDigest returns the digest that protects the integrity of the JKS
+// keystore data.
+func keyStoreDigest(data []byte, password string) []byte {
+	h := sha1.New()
+	h.Write(jksPassword(password))
+	h.Write([]byte(jksDigestWhitener))
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// protectJKSKey returns the plain private key encrypted with the JKS key
+// protection algorithm: the key is XORed with a keystream of chained SHA-1
+// digests of the password and the salt, and followed by a digest of the
+// password and the plain key to check the password when it is recovered.
+func protectJKSKey(plain []byte, password string, salt []byte) []byte {
+	out := append([]byte{}, salt...)
+	out = append(out, jksKeyStream(plain, password, salt)...)
+	return append(out, jksKeyCheck(plain, password)...)
+}
+
+// recoverJKSKey returns the plain private key of the protected key returned
+// by protectJKSKey.
+func recoverJKSKey(protected []byte, password string) ([]byte, error) {
+	if len(protected) < jksSaltSize+sha1.Size {
+		return nil, ErrInvalidKeyStore
+	}
+	salt := protected[:jksSaltSize]
+	encrypted := protected[jksSaltSize : len(protected)-sha1.Size]
+	check := protected[len(protected)-sha1.Size:]
+	plain := jksKeyStream(encrypted, password, salt)
+	if subtle.ConstantTimeCompare(jksKeyCheck(plain, password), check) != 1 {
+		return nil, ErrKeyPassword
+	}
+	return plain, nil
+}
+
+// jksKeyStream returns data XORed with the keystream of the password and salt.
+func jksKeyStream(data []byte, password string, salt []byte) []byte {
+	pw := jksPassword(password)
+	out := make([]byte, len(data))
+	digest := salt
+	for i := 0; i < len(data); i += sha1.Size {
+		h := sha1.New()
+		h.Write(pw)
+		h.Write(digest)
+		digest = h.Sum(nil)
+		for j := 0; j < sha1.Size && i+j < len(data); j++ {
+			out[i+j] = data[i+j] ^ digest[j]
+		}
+	}
+	return out
+}
+
+// jksKeyCheck returns the digest used to check the recovered plain key.
+func jksKeyCheck(plain []byte, password string) []byte {
+	h := sha1.New()
+	h.Write(jksPassword(password))
+	h.Write(plain)
+	return h.Sum(nil)
+}
+
+// jksPassword returns the password as the big-endian UTF-16 bytes digested by
+// the JKS algorithms.
+func jksPassword(password string) []byte {
+	chars := utf16.Encode([]rune(password))
+	out := make([]byte, 2*len(chars))
+	for i, c := range chars {
+		binary.BigEndian.PutUint16(out[2*i:], c)
+	}
+	return out
+}
+
+// keyStoreReader reads the big-endian fields of a JKS keystore, recording an
+// error instead of reading beyond the end of the data.
+type keyStoreReader struct {
+	data []byte
+	err  error
+}
+
+func (r *keyStoreReader) bytes(n uint64) []byte {
+	if r.err != nil || n > uint64(len(r.data)) {
+		r.err = ErrInvalidKeyStore
+		return nil
+	}
+	out := r.data[:n]
+	r.data = r.data[n:]
+	return out
+}
+
+func (r *keyStoreReader) uint32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+// string reads a string in the length-prefixed form of Java's
+// DataOutput.writeUTF.
+func (r *keyStoreReader) string() string {
+	b := r.bytes(2)
+	if b == nil {
+		return ""
+	}
+	return string(r.bytes(uint64(binary.BigEndian.Uint16(b))))
+}
@@ -0,0 +1,93 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apk
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+)
+
+func TestKeyStore(t *testing.T) {
+	ctx := assert.Context(t)
+	dir, err := ioutil.TempDir("", "keystore")
+	assert.With(ctx).ThatError(err).Succeeded()
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "debug.keystore")
+
+	created, err := LoadOrCreateDebugKey(path, DebugKeyStorePassword, DebugKeyStorePassword, DebugKeyAlias)
+	assert.With(ctx).ThatError(err).Succeeded()
+	loaded, err := LoadKeyStore(path, DebugKeyStorePassword, DebugKeyStorePassword, "AndroidDebugKey")
+	assert.With(ctx).ThatError(err).Succeeded()
+	assert.For(ctx, "key").That(loaded.Key.N.Cmp(created.Key.N)).Equals(0)
+	assert.For(ctx, "certificate").That(loaded.Certificate.Raw).DeepEquals(created.Certificate.Raw)
+	reloaded, err := LoadOrCreateDebugKey(path, DebugKeyStorePassword, DebugKeyStorePassword, DebugKeyAlias)
+	assert.With(ctx).ThatError(err).Succeeded()
+	assert.For(ctx, "reloaded").That(reloaded.Key.N.Cmp(created.Key.N)).Equals(0)
+
+	_, err = LoadKeyStore(path, "wrong", DebugKeyStorePassword, DebugKeyAlias)
+	assert.For(ctx, "store password").ThatError(err).Equals(ErrKeyStorePassword)
+	_, err = LoadKeyStore(path, DebugKeyStorePassword, "wrong", DebugKeyAlias)
+	assert.For(ctx, "key password").ThatError(err).Equals(ErrKeyPassword)
+	_, err = LoadKeyStore(path, DebugKeyStorePassword, DebugKeyStorePassword, "missing")
+	assert.For(ctx, "alias").ThatError(err).Equals(ErrKeyAliasNotFound)
+
+	data, err := ioutil.ReadFile(path)
+	assert.With(ctx).ThatError(err).Succeeded()
+	assert.With(ctx).ThatError(ioutil.WriteFile(path, data[:len(data)/2], 0600)).Succeeded()
+	_, err = LoadKeyStore(path, DebugKeyStorePassword, DebugKeyStorePassword, DebugKeyAlias)
+	assert.For(ctx, "truncated").ThatError(err).Failed()
+
+	pem := filepath.Join(dir, "key.pem")
+	assert.With(ctx).ThatError(created.Save(pem)).Succeeded()
+	fromPEM, err := LoadOrCreateDebugKey(pem, DebugKeyStorePassword, DebugKeyStorePassword, DebugKeyAlias)
+	assert.With(ctx).ThatError(err).Succeeded()
+	assert.For(ctx, "pem").That(fromPEM.Key.N.Cmp(created.Key.N)).Equals(0)
+}
+
+func TestKeyStorePasswords(t *testing.T) {
+	ctx := assert.Context(t)
+	dir, err := ioutil.TempDir("", "keystore")
+	assert.With(ctx).ThatError(err).Succeeded()
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "release.keystore")
+
+	created, err := LoadOrCreateDebugKey(path, "store", "key", "release")
+	assert.With(ctx).ThatError(err).Succeeded()
+	loaded, err := LoadKeyStore(path, "store", "key", "release")
+	assert.With(ctx).ThatError(err).Succeeded()
+	assert.For(ctx, "key").That(loaded.Key.N.Cmp(created.Key.N)).Equals(0)
+	_, err = LoadKeyStore(path, "key", "key", "release")
+	assert.For(ctx, "store password").ThatError(err).Equals(ErrKeyStorePassword)
+	_, err = LoadKeyStore(path, "store", "store", "release")
+	assert.For(ctx, "key password").ThatError(err).Equals(ErrKeyPassword)
+}
+
+func TestJKSKeyProtection(t *testing.T) {
+	ctx := assert.Context(t)
+	salt := make([]byte, jksSaltSize)
+	plain := []byte("a private key that spans more than one digest")
+	protected := protectJKSKey(plain, DebugKeyStorePassword, salt)
+	assert.For(ctx, "size").That(len(protected)).Equals(len(salt) + len(plain) + 20)
+
+	recovered, err := recoverJKSKey(protected, DebugKeyStorePassword)
+	assert.With(ctx).ThatError(err).Succeeded()
+	assert.For(ctx, "recovered").That(recovered).DeepEquals(plain)
+	_, err = recoverJKSKey(protected, "wrong")
+	assert.For(ctx, "key password").ThatError(err).Equals(ErrKeyPassword)
+}
@@ -0,0 +1,462 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apk
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"hash"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/google/gapid/core/fault"
+)
+
+const (
+	ErrUnsupportedEncryption = fault.Const("Keystore is encrypted with an unsupported algorithm.")
+
+	pkcs12Version = 3
+
+	asn1TagBMPString = 30
+
+	// The purposes of the material derived by the PKCS#12 key derivation
+	// function.
+	pkcs12KeyMaterial = 1
+	pkcs12IVMaterial  = 2
+	pkcs12MACMaterial = 3
+)
+
+var (
+	oidEncryptedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+	oidKeyBag        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 1}
+	oidShroudedKey   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidX509Cert      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidFriendlyName  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 20}
+	oidSHA256        = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+
+	// The password based encryption schemes of PKCS#12 and PKCS#5.
+	oidPBEWithSHA1And3DES   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}
+	oidPBEWithSHA1AndRC2128 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 5}
+	oidPBEWithSHA1AndRC240  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 6}
+	oidPBES2                = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2               = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1         = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256       = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidDESEDE3CBC           = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+	oidAES128CBC            = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC            = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC            = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// The structures of a PKCS#12 keystore, as described by RFC 7292, and of the
+// password based encryption schemes it uses.
+type (
+	pfxPDU struct {
+		Version  int
+		AuthSafe contentInfo
+		MacData  macData `asn1:"optional"`
+	}
+	macData struct {
+		Mac        digestInfo
+		MacSalt    []byte
+		Iterations int `asn1:"optional,default:1"`
+	}
+	digestInfo struct {
+		Algorithm algorithmIdentifier
+		Digest    []byte
+	}
+	encryptedData struct {
+		Version              int
+		EncryptedContentInfo encryptedContentInfo
+	}
+	encryptedContentInfo struct {
+		ContentType                asn1.ObjectIdentifier
+		ContentEncryptionAlgorithm algorithmIdentifier
+		EncryptedContent           []byte `asn1:"tag:0,optional"`
+	}
+	safeBag struct {
+		ID         asn1.ObjectIdentifier
+		Value      asn1.RawValue     `asn1:"tag:0,explicit"`
+		Attributes []pkcs12Attribute `asn1:"set,optional"`
+	}
+	pkcs12Attribute struct {
+		ID     asn1.ObjectIdentifier
+		Values asn1.RawValue
+	}
+	certBag struct {
+		ID   asn1.ObjectIdentifier
+		Data []byte `asn1:"tag:0,explicit"`
+	}
+	pbeParams struct {
+		Salt       []byte
+		Iterations int
+	}
+	pbes2Params struct {
+		KeyDerivationFunc algorithmIdentifier
+		EncryptionScheme  algorithmIdentifier
+	}
+	pbkdf2Params struct {
+		Salt       []byte
+		Iterations int
+		KeyLength  int                 `asn1:"optional"`
+		PRF        algorithmIdentifier `asn1:"optional"`
+	}
+)
+
+// isPKCS12 returns true if data starts like a PKCS#12 keystore, which is a DER
+// encoded sequence.
+func isPKCS12(data []byte) bool {
+	return len(data) >= 2 && data[0] == 0x30 && data[1] >= 0x80
+}
+
+// loadPKCS12 reads the signing key with the alias from the PKCS#12 keystore
+// data. The store password protects the integrity of the keystore and its
+// certificates, and the key password protects the key.
+func loadPKCS12(data []byte, storePassword, keyPassword, alias string) (*SigningKey, error) {
+	pfx := pfxPDU{}
+	if err := unmarshalDER(data, &pfx); err != nil {
+		return nil, err
+	}
+	if pfx.Version != pkcs12Version || !pfx.AuthSafe.ContentType.Equal(oidData) {
+		return nil, ErrInvalidKeyStore
+	}
+	var authSafe []byte
+	if err := unmarshalDER(pfx.AuthSafe.Content.Bytes, &authSafe); err != nil {
+		return nil, err
+	}
+	if len(pfx.MacData.Mac.Algorithm.Algorithm) != 0 {
+		if err := pfx.MacData.verify(authSafe, storePassword); err != nil {
+			return nil, err
+		}
+	}
+	var contents []contentInfo
+	if err := unmarshalDER(authSafe, &contents); err != nil {
+		return nil, err
+	}
+
+	k := &SigningKey{}
+	certs := []*x509.Certificate{}
+	for _, c := range contents {
+		var bags []byte
+		switch {
+		case c.ContentType.Equal(oidData):
+			if err := unmarshalDER(c.Content.Bytes, &bags); err != nil {
+				return nil, err
+			}
+		case c.ContentType.Equal(oidEncryptedData):
+			e := encryptedData{}
+			if err := unmarshalDER(c.Content.Bytes, &e); err != nil {
+				return nil, err
+			}
+			info := e.EncryptedContentInfo
+			var err error
+			if bags, err = pbeDecrypt(info.ContentEncryptionAlgorithm, info.EncryptedContent, storePassword, ErrKeyStorePassword); err != nil {
+				return nil, err
+			}
+		default:
+			continue // Contents encrypted with public keys hold nothing of ours.
+		}
+		var safe []safeBag
+		if err := unmarshalDER(bags, &safe); err != nil {
+			return nil, err
+		}
+		for _, b := range safe {
+			switch {
+			case b.ID.Equal(oidKeyBag), b.ID.Equal(oidShroudedKey):
+				if k.Key != nil || !strings.EqualFold(b.friendlyName(), alias) {
+					continue
+				}
+				plain := b.Value.Bytes
+				if b.ID.Equal(oidShroudedKey) {
+					info := encryptedPrivateKeyInfo{}
+					if err := unmarshalDER(b.Value.Bytes, &info); err != nil {
+						return nil, err
+					}
+					var err error
+					if plain, err = pbeDecrypt(info.Algorithm, info.EncryptedData, keyPassword, ErrKeyPassword); err != nil {
+						return nil, err
+					}
+				}
+				key, err := x509.ParsePKCS8PrivateKey(plain)
+				if err != nil {
+					return nil, err
+				}
+				var ok bool
+				if k.Key, ok = key.(*rsa.PrivateKey); !ok {
+					return nil, ErrNoPrivateKey
+				}
+			case b.ID.Equal(oidCertBag):
+				bag := certBag{}
+				if err := unmarshalDER(b.Value.Bytes, &bag); err != nil {
+					return nil, err
+				}
+				if !bag.ID.Equal(oidX509Cert) {
+					continue
+				}
+				cert, err := x509.ParseCertificate(bag.Data)
+				if err != nil {
+					return nil, err
+				}
+				certs = append(certs, cert)
+			}
+		}
+	}
+	if k.Key == nil {
+		return nil, ErrKeyAliasNotFound
+	}
+	// The certificates of a key's chain are in separate bags, the one for the
+	// key is found by its public key.
+	for _, cert := range certs {
+		if k.Certificate = cert; k.check() == nil {
+			return k, nil
+		}
+	}
+	return nil, ErrNoCertificate
+}
+
+// friendlyName returns the name attribute of the bag, which holds the alias of
+// the keystore entry.
+func (b *safeBag) friendlyName() string {
+	for _, a := range b.Attributes {
+		if !a.ID.Equal(oidFriendlyName) {
+			continue
+		}
+		name := asn1.RawValue{}
+		if _, err := asn1.Unmarshal(a.Values.Bytes, &name); err != nil || name.Tag != asn1TagBMPString {
+			return ""
+		}
+		chars := make([]uint16, len(name.Bytes)/2)
+		for i := range chars {
+			chars[i] = binary.BigEndian.Uint16(name.Bytes[2*i:])
+		}
+		return string(utf16.Decode(chars))
+	}
+	return ""
+}
+
+// verify returns an error if the MAC of the data does not match the password.
+func (m *macData) verify(data []byte, password string) error {
+	var h func() hash.Hash
+	switch alg := m.Mac.Algorithm.Algorithm; {
+	case alg.Equal(oidSHA1):
+		h = sha1.New
+	case alg.Equal(oidSHA256):
+		h = sha256.New
+	default:
+		return ErrUnsupportedEncryption
+	}
+	key := pkcs12KDF(h, bmpPassword(password), m.MacSalt, m.Iterations, pkcs12MACMaterial, h().Size())
+	mac := hmac.New(h, key)
+	mac.Write(data)
+	if !hmac.Equal(mac.Sum(nil), m.Mac.Digest) {
+		return ErrKeyStorePassword
+	}
+	return nil
+}
+
+// pbeDecrypt returns the data decrypted with the password based encryption
+// algorithm, or the wrong error if the password does not decrypt it.
+func pbeDecrypt(alg algorithmIdentifier, data []byte, password string, wrong error) ([]byte, error) {
+	var block cipher.Block
+	var iv []byte
+	switch a := alg.Algorithm; {
+	case a.Equal(oidPBES2):
+		var err error
+		if block, iv, err = pbes2Cipher(alg.Parameters.FullBytes, password); err != nil {
+			return nil, err
+		}
+	case a.Equal(oidPBEWithSHA1And3DES), a.Equal(oidPBEWithSHA1AndRC2128), a.Equal(oidPBEWithSHA1AndRC240):
+		params := pbeParams{}
+		if err := unmarshalDER(alg.Parameters.FullBytes, &params); err != nil {
+			return nil, err
+		}
+		size := 24
+		switch {
+		case a.Equal(oidPBEWithSHA1AndRC2128):
+			size = 16
+		case a.Equal(oidPBEWithSHA1AndRC240):
+			size = 5
+		}
+		pw := bmpPassword(password)
+		key := pkcs12KDF(sha1.New, pw, params.Salt, params.Iterations, pkcs12KeyMaterial, size)
+		iv = pkcs12KDF(sha1.New, pw, params.Salt, params.Iterations, pkcs12IVMaterial, rc2BlockSize)
+		if a.Equal(oidPBEWithSHA1And3DES) {
+			var err error
+			if block, err = des.NewTripleDESCipher(key); err != nil {
+				return nil, err
+			}
+		} else {
+			block = newRC2Cipher(key, 8*size)
+		}
+	default:
+		return nil, ErrUnsupportedEncryption
+	}
+
+	size := block.BlockSize()
+	if len(data) == 0 || len(data)%size != 0 {
+		return nil, ErrInvalidKeyStore
+	}
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, data)
+	// The padding only decrypts to the bytes of its length with the right
+	// password.
+	pad := int(plain[len(plain)-1])
+	if pad == 0 || pad > size || !bytes.Equal(plain[len(plain)-pad:], bytes.Repeat([]byte{byte(pad)}, pad)) {
+		return nil, wrong
+	}
+	return plain[:len(plain)-pad], nil
+}
+
+// pbes2Cipher returns the block cipher and IV of the PBES2 parameters, with
+// the key derived from the password by PBKDF2.
+func pbes2Cipher(params []byte, password string) (cipher.Block, []byte, error) {
+	p := pbes2Params{}
+	if err := unmarshalDER(params, &p); err != nil {
+		return nil, nil, err
+	}
+	if !p.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, nil, ErrUnsupportedEncryption
+	}
+	kdf := pbkdf2Params{}
+	if err := unmarshalDER(p.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+		return nil, nil, err
+	}
+	h := sha1.New
+	switch prf := kdf.PRF.Algorithm; {
+	case len(prf) == 0, prf.Equal(oidHMACWithSHA1):
+	case prf.Equal(oidHMACWithSHA256):
+		h = sha256.New
+	default:
+		return nil, nil, ErrUnsupportedEncryption
+	}
+	var size int
+	newCipher := aes.NewCipher
+	switch scheme := p.EncryptionScheme.Algorithm; {
+	case scheme.Equal(oidAES128CBC):
+		size = 16
+	case scheme.Equal(oidAES192CBC):
+		size = 24
+	case scheme.Equal(oidAES256CBC):
+		size = 32
+	case scheme.Equal(oidDESEDE3CBC):
+		size, newCipher = 24, des.NewTripleDESCipher
+	default:
+		return nil, nil, ErrUnsupportedEncryption
+	}
+	var iv []byte
+	if err := unmarshalDER(p.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, nil, err
+	}
+	block, err := newCipher(pbkdf2([]byte(password), kdf.Salt, kdf.Iterations, size, h))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(iv) != block.BlockSize() {
+		return nil, nil, ErrInvalidKeyStore
+	}
+	return block, iv, nil
+}
+
+// pkcs12KDF returns n bytes of the material for the purpose derived from the
+// password and salt, as described by RFC 7292 appendix B.2.
+func pkcs12KDF(h func() hash.Hash, password, salt []byte, iterations int, purpose byte, n int) []byte {
+	d := h()
+	v := d.BlockSize()
+	// fill returns b repeated to fill a whole number of blocks.
+	fill := func(b []byte) []byte {
+		out := make([]byte, v*((len(b)+v-1)/v))
+		for i := range out {
+			out[i] = b[i%len(b)]
+		}
+		return out
+	}
+	in := append(fill(salt), fill(password)...)
+	prefix := bytes.Repeat([]byte{purpose}, v)
+	out := []byte{}
+	for {
+		d.Reset()
+		d.Write(prefix)
+		d.Write(in)
+		a := d.Sum(nil)
+		for i := 1; i < iterations; i++ {
+			d.Reset()
+			d.Write(a)
+			a = d.Sum(a[:0])
+		}
+		if out = append(out, a...); len(out) >= n {
+			return out[:n]
+		}
+		// Add the digest, repeated to a block, plus one to each block of the
+		// input for the next digest.
+		b := fill(a)
+		for j := 0; j < len(in); j += v {
+			carry := 1
+			for i := v - 1; i >= 0; i-- {
+				carry += int(in[j+i]) + int(b[i])
+				in[j+i] = byte(carry)
+				carry >>= 8
+			}
+		}
+	}
+}
+
+// pbkdf2 returns the n byte key derived from the password and salt with the
+// HMAC of the hash, as described by RFC 8018 section 5.2.
+func pbkdf2(password, salt []byte, iterations, n int, h func() hash.Hash) []byte {
+	prf := hmac.New(h, password)
+	out := []byte{}
+	for block := uint32(1); len(out) < n; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.Write(prf, binary.BigEndian, block)
+		u := prf.Sum(nil)
+		t := append([]byte{}, u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		out = append(out, t...)
+	}
+	return out[:n]
+}
+
+// bmpPassword returns the password as the null terminated big-endian UTF-16
+// bytes used by the PKCS#12 key derivation function.
+func bmpPassword(password string) []byte {
+	return append(jksPassword(password), 0, 0)
+}
+
+// unmarshalDER parses the DER encoded data into out, which it must fill
+// exactly.
+func unmarshalDER(data []byte, out interface{}) error {
+	if rest, err := asn1.Unmarshal(data, out); err != nil || len(rest) != 0 {
+		return ErrInvalidKeyStore
+	}
+	return nil
+}
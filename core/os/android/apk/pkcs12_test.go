@@ -0,0 +1,85 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apk
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+)
+
+// The debug keystores in testdata were written by openssl with the algorithms
+// and iteration counts of keytool's PKCS#12 keystores: PBE-SHA1-3DES keys,
+// PBE-SHA1-RC2-40 certificates and SHA-1 MACs before JDK 12, and PBES2 with
+// AES-256 and HMAC-SHA256 since.
+var pkcs12KeyStores = []string{
+	"testdata/debug-legacy.keystore",
+	"testdata/debug-pbes2.keystore",
+}
+
+func TestPKCS12(t *testing.T) {
+	assert := assert.To(t)
+	var first *SigningKey
+	for _, path := range pkcs12KeyStores {
+		k, err := LoadOrCreateDebugKey(path, DebugKeyStorePassword, DebugKeyStorePassword, DebugKeyAlias)
+		if !assert.For("%s", path).ThatError(err).Succeeded() {
+			continue
+		}
+		assert.For("%s subject", path).That(k.Certificate.Subject.CommonName).Equals("Android Debug")
+		if first == nil {
+			first = k
+		} else {
+			assert.For("%s key", path).That(k.Key.N.Cmp(first.Key.N)).Equals(0)
+		}
+
+		_, err = LoadKeyStore(path, DebugKeyStorePassword, DebugKeyStorePassword, "AndroidDebugKey")
+		assert.For("%s alias case", path).ThatError(err).Succeeded()
+		_, err = LoadKeyStore(path, "wrong", DebugKeyStorePassword, DebugKeyAlias)
+		assert.For("%s store password", path).ThatError(err).Equals(ErrKeyStorePassword)
+		_, err = LoadKeyStore(path, DebugKeyStorePassword, "wrong", DebugKeyAlias)
+		assert.For("%s key password", path).ThatError(err).Equals(ErrKeyPassword)
+		_, err = LoadKeyStore(path, DebugKeyStorePassword, DebugKeyStorePassword, "missing")
+		assert.For("%s alias", path).ThatError(err).Equals(ErrKeyAliasNotFound)
+	}
+}
+
+func TestRC2(t *testing.T) {
+	assert := assert.To(t)
+	// The test vectors of RFC 2268 section 5.
+	for _, test := range []struct {
+		key    string
+		bits   int
+		plain  string
+		cipher string
+	}{
+		{"0000000000000000", 63, "0000000000000000", "ebb773f993278eff"},
+		{"ffffffffffffffff", 64, "ffffffffffffffff", "278b27e42e2f0d49"},
+		{"3000000000000000", 64, "1000000000000001", "30649edf9be7d2c2"},
+		{"88", 64, "0000000000000000", "61a8a244adacccf0"},
+		{"88bca90e90875a", 64, "0000000000000000", "6ccf4308974c267f"},
+		{"88bca90e90875a7f0f79c384627bafb2", 64, "0000000000000000", "1a807d272bbe5db1"},
+		{"88bca90e90875a7f0f79c384627bafb2", 128, "0000000000000000", "2269552ab0f85ca6"},
+	} {
+		key, _ := hex.DecodeString(test.key)
+		plain, _ := hex.DecodeString(test.plain)
+		c := newRC2Cipher(key, test.bits)
+		got := make([]byte, rc2BlockSize)
+		c.Encrypt(got, plain)
+		assert.For("encrypt %s/%d", test.key, test.bits).That(hex.EncodeToString(got)).Equals(test.cipher)
+		c.Decrypt(got, got)
+		assert.For("decrypt %s/%d", test.key, test.bits).That(hex.EncodeToString(got)).Equals(test.plain)
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apk
+
+import (
+	"archive/zip"
+	"bufio"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Sign writes the entries to w as a zip-aligned APK signed by key with both
+// the v1 (JAR) and v2 APK signature schemes. Any existing JAR signature files
+// in entries are replaced.
+// The entries are streamed from their source archives, so the APK is never
+// held in memory. APKs that need zip64 extensions are only signed with the v1
+// scheme, as the v2 scheme does not support zip64.
+func Sign(w io.Writer, entries []Entry, key *SigningKey) error {
+	unsigned := make([]Entry, 0, len(entries))
+	for _, e := range entries {
+		if !jarSignatureFilePattern.MatchString(e.Name) {
+			unsigned = append(unsigned, e)
+		}
+	}
+	prepared := make([]*zipEntry, len(unsigned))
+	for i, e := range unsigned {
+		var err error
+		if prepared[i], err = prepare(e); err != nil {
+			return err
+		}
+	}
+	manifest, err := newManifestV1(unsigned)
+	if err != nil {
+		return err
+	}
+	blockSize, err := signingBlockV2Size(key)
+	if err != nil {
+		return err
+	}
+
+	signedV2 := true
+	var all []*zipEntry
+	var layout zipLayout
+	for {
+		v1, err := manifest.sign(key, signedV2)
+		if err != nil {
+			return err
+		}
+		// The JAR signature files conventionally come first in the archive.
+		all = make([]*zipEntry, 0, len(v1)+len(prepared))
+		for _, e := range v1 {
+			z, err := prepare(e)
+			if err != nil {
+				return err
+			}
+			all = append(all, z)
+		}
+		all = append(all, prepared...)
+		if !signedV2 {
+			layout = layoutAligned(all, 0)
+			break
+		}
+		if layout = layoutAligned(all, uint64(blockSize)); !layout.zip64 {
+			break
+		}
+		signedV2 = false
+	}
+
+	out := bufio.NewWriter(w)
+	if !signedV2 {
+		cd, err := writeAligned(out, all)
+		if err != nil {
+			return err
+		}
+		out.Write(cd)
+		out.Write(endOfCentralDirectory(layout))
+		return out.Flush()
+	}
+
+	// The v2 digest covers the entries, the central directory, and the end of
+	// central directory record with the offset of the signing block in place
+	// of the offset of the central directory.
+	digester := &digesterV2{}
+	cd, err := writeAligned(io.MultiWriter(out, digester), all)
+	if err != nil {
+		return err
+	}
+	digester.endSection()
+	digester.Write(cd)
+	digester.endSection()
+	blockOffset := layout
+	blockOffset.cdOffset -= uint64(blockSize)
+	digester.Write(endOfCentralDirectory(blockOffset))
+	block, err := signingBlockV2(digester.sum(), key)
+	if err != nil {
+		return err
+	}
+	out.Write(block)
+	out.Write(cd)
+	out.Write(endOfCentralDirectory(layout))
+	return out.Flush()
+}
+
+// SignFile aligns and signs the APK at src with key as Sign does, writing it
+// to dst. dst may be the same path as src.
+func SignFile(src, dst string, key *SigningKey) error {
+	return rewrite(src, dst, key, func(entries []Entry) ([]Entry, error) { return entries, nil })
+}
+
+// rewrite signs the entries of the APK at src, as changed by edit, writing
+// the APK to dst. The APK is written to a temporary file that replaces dst
+// once it is complete, so that dst may be the same path as src.
+func rewrite(src, dst string, key *SigningKey, edit func([]Entry) ([]Entry, error)) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	r, err := zip.NewReader(in, info.Size())
+	if err != nil {
+		return ErrInvalidAPK
+	}
+	entries, err := edit(ReadEntries(in, r.File))
+	if err != nil {
+		return err
+	}
+
+	out, err := ioutil.TempFile(filepath.Dir(dst), filepath.Base(dst))
+	if err != nil {
+		return err
+	}
+	if err = Sign(out, entries, key); err == nil {
+		err = out.Chmod(0644)
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	in.Close() // The source cannot be replaced while open on some platforms.
+	if err == nil {
+		err = os.Rename(out.Name(), dst)
+	}
+	if err != nil {
+		os.Remove(out.Name())
+	}
+	return err
+}
@@ -0,0 +1,246 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apk
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+)
+
+func TestSign(t *testing.T) {
+	ctx := assert.Context(t)
+	key, err := GenerateDebugKey()
+	assert.With(ctx).ThatError(err).Succeeded()
+
+	entries := []Entry{
+		{Name: "AndroidManifest.xml", Method: zip.Deflate, Data: []byte("manifest")},
+		{Name: "res/raw/a", Method: zip.Store, Data: []byte("abc")},
+		{Name: "res/raw/bb", Method: zip.Store, Data: []byte("defg")},
+		{Name: "lib/armeabi-v7a/" + strings.Repeat("x", 80) + ".so", Method: zip.Store, Data: []byte("so")},
+		{Name: "META-INF/OLD.RSA", Method: zip.Deflate, Data: []byte("stale")},
+	}
+	buf := &bytes.Buffer{}
+	err = Sign(buf, entries, key)
+	assert.With(ctx).ThatError(err).Succeeded()
+	apk := buf.Bytes()
+
+	files := readZip(ctx, apk)
+	assert.For(ctx, "stale signature").That(files["META-INF/OLD.RSA"]).IsNil()
+	assert.For(ctx, "entry").That(string(files["res/raw/bb"])).Equals("defg")
+
+	// v1: the manifest digests the entries, and the signature block signs the
+	// signature file.
+	manifest := string(files[v1ManifestPath])
+	assert.For(ctx, "manifest").ThatString(manifest).Contains(
+		"Name: res/raw/a\r\nSHA1-Digest: " + digestV1([]byte("abc")) + "\r\n")
+	for _, line := range strings.Split(manifest, "\r\n") {
+		assert.For(ctx, "line length").That(len(line) <= maxManifestLine).Equals(true)
+	}
+	sf := files[v1SignaturePath]
+	assert.For(ctx, "signature file").ThatString(string(sf)).Contains(
+		"SHA1-Digest-Manifest: " + digestV1(files[v1ManifestPath]) + "\r\n")
+	info := contentInfo{}
+	_, err = asn1.Unmarshal(files[v1SignerPath], &info)
+	assert.With(ctx).ThatError(err).Succeeded()
+	signed := signedData{}
+	_, err = asn1.Unmarshal(info.Content.Bytes, &signed)
+	assert.With(ctx).ThatError(err).Succeeded()
+	assert.For(ctx, "certificate").That(signed.Certificates.Bytes).DeepEquals(key.Certificate.Raw)
+	sfDigest := sha1.Sum(sf)
+	err = rsa.VerifyPKCS1v15(&key.Key.PublicKey, crypto.SHA1, sfDigest[:], signed.SignerInfos[0].EncryptedDigest)
+	assert.For(ctx, "v1 signature").ThatError(err).Succeeded()
+
+	// v2: the signing block sits before the central directory and signs the
+	// digest of the rest of the archive.
+	eocd := findEOCD(apk)
+	cdOffset := int(binary.LittleEndian.Uint32(apk[eocd+16:]))
+	assert.For(ctx, "magic").That(string(apk[cdOffset-16 : cdOffset])).Equals(v2SigningBlockMagic)
+	size := int(binary.LittleEndian.Uint64(apk[cdOffset-24:]))
+	start := cdOffset - size - 8
+	assert.For(ctx, "block size").That(binary.LittleEndian.Uint64(apk[start:])).Equals(uint64(size))
+	assert.For(ctx, "block id").That(binary.LittleEndian.Uint32(apk[start+16:])).Equals(uint32(v2BlockID))
+
+	value := apk[start+20 : cdOffset-24]
+	next := func(b []byte) ([]byte, []byte) {
+		n := binary.LittleEndian.Uint32(b)
+		return b[4 : 4+n], b[4+n:]
+	}
+	signers, _ := next(value)
+	signer, _ := next(signers)
+	signedData, rest := next(signer)
+	signatures, rest := next(rest)
+	publicKey, _ := next(rest)
+	assert.For(ctx, "public key").That(len(publicKey) > 0).Equals(true)
+	signature, _ := next(signatures)
+	algorithm := binary.LittleEndian.Uint32(signature)
+	assert.For(ctx, "algorithm").That(algorithm).Equals(uint32(v2RSAPKCS1SHA256))
+	sig, _ := next(signature[4:])
+	hash := sha256.Sum256(signedData)
+	err = rsa.VerifyPKCS1v15(&key.Key.PublicKey, crypto.SHA256, hash[:], sig)
+	assert.For(ctx, "v2 signature").ThatError(err).Succeeded()
+
+	digests, _ := next(signedData)
+	digest, _ := next(digests)
+	got, _ := next(digest[4:])
+	end := append([]byte{}, apk[eocd:]...)
+	binary.LittleEndian.PutUint32(end[16:], uint32(start))
+	expected := digestV2(apk[:start], apk[cdOffset:eocd], end)
+	assert.For(ctx, "v2 digest").That(got).DeepEquals(expected)
+}
+
+func TestSignStreamed(t *testing.T) {
+	ctx := assert.Context(t)
+	key, err := GenerateDebugKey()
+	assert.With(ctx).ThatError(err).Succeeded()
+
+	src := &bytes.Buffer{}
+	w := zip.NewWriter(src)
+	for _, f := range []struct {
+		name   string
+		method uint16
+		data   string
+	}{
+		{"AndroidManifest.xml", zip.Deflate, "manifest"},
+		{"classes.dex", zip.Deflate, strings.Repeat("dex", 1000)},
+		{"res/raw/a", zip.Store, "abc"},
+		{"META-INF/CERT.RSA", zip.Deflate, "stale"},
+	} {
+		fw, err := w.CreateHeader(&zip.FileHeader{Name: f.name, Method: f.method})
+		assert.With(ctx).ThatError(err).Succeeded()
+		fw.Write([]byte(f.data))
+	}
+	assert.With(ctx).ThatError(w.Close()).Succeeded()
+	in, err := zip.NewReader(bytes.NewReader(src.Bytes()), int64(src.Len()))
+	assert.With(ctx).ThatError(err).Succeeded()
+
+	entries := ReadEntries(bytes.NewReader(src.Bytes()), in.File)
+	entries[0].SetData([]byte("modified"))
+	buf := &bytes.Buffer{}
+	err = Sign(buf, entries, key)
+	assert.With(ctx).ThatError(err).Succeeded()
+
+	files := readZip(ctx, buf.Bytes())
+	assert.For(ctx, "modified").That(string(files["AndroidManifest.xml"])).Equals("modified")
+	assert.For(ctx, "copied").That(string(files["classes.dex"])).Equals(strings.Repeat("dex", 1000))
+	assert.For(ctx, "stored").That(string(files["res/raw/a"])).Equals("abc")
+	assert.For(ctx, "signer").That(string(files[v1SignerPath])).NotEquals("stale")
+	assert.For(ctx, "manifest").ThatString(string(files[v1ManifestPath])).Contains(
+		"Name: classes.dex\r\nSHA1-Digest: " + digestV1([]byte(strings.Repeat("dex", 1000))) + "\r\n")
+	assert.For(ctx, "signed v2").ThatString(string(files[v1SignaturePath])).Contains("X-Android-APK-Signed: 2")
+}
+
+func TestSignZip64(t *testing.T) {
+	ctx := assert.Context(t)
+	key, err := GenerateDebugKey()
+	assert.With(ctx).ThatError(err).Succeeded()
+
+	// More entries than the original zip format can count.
+	entries := make([]Entry, uint16Max)
+	for i := range entries {
+		entries[i] = Entry{Name: fmt.Sprintf("assets/%05d", i), Method: zip.Store, Data: []byte{byte(i)}}
+	}
+	buf := &bytes.Buffer{}
+	err = Sign(buf, entries, key)
+	assert.With(ctx).ThatError(err).Succeeded()
+	apk := buf.Bytes()
+
+	files := readZip(ctx, apk)
+	assert.For(ctx, "entries").That(len(files)).Equals(len(entries) + 3)
+	assert.For(ctx, "entry").That(files["assets/65534"]).DeepEquals([]byte{0xfe})
+	eocd := findEOCD(apk)
+	assert.For(ctx, "entry count").That(binary.LittleEndian.Uint16(apk[eocd+10:])).Equals(uint16(uint16Max))
+	assert.For(ctx, "zip64 locator").That(binary.LittleEndian.Uint32(apk[eocd-zip64LocatorSize:])).Equals(uint32(zip64LocatorSignature))
+
+	// The v2 scheme does not support zip64, so the APK is only signed with v1.
+	assert.For(ctx, "signed v2").ThatString(string(files[v1SignaturePath])).DoesNotContain("X-Android-APK-Signed")
+	assert.For(ctx, "signing block").That(bytes.Contains(apk, []byte(v2SigningBlockMagic))).Equals(false)
+}
+
+func TestSignFile(t *testing.T) {
+	ctx := assert.Context(t)
+	key, err := GenerateDebugKey()
+	assert.With(ctx).ThatError(err).Succeeded()
+	dir, err := ioutil.TempDir("", "apk")
+	assert.With(ctx).ThatError(err).Succeeded()
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "app.apk")
+	src := &bytes.Buffer{}
+	w := zip.NewWriter(src)
+	fw, err := w.Create("AndroidManifest.xml")
+	assert.With(ctx).ThatError(err).Succeeded()
+	fw.Write([]byte("manifest"))
+	assert.With(ctx).ThatError(w.Close()).Succeeded()
+	assert.With(ctx).ThatError(ioutil.WriteFile(path, src.Bytes(), 0644)).Succeeded()
+
+	// Signing in place must not truncate the source while it is read.
+	err = SignFile(path, path, key)
+	assert.With(ctx).ThatError(err).Succeeded()
+	apk, err := ioutil.ReadFile(path)
+	assert.With(ctx).ThatError(err).Succeeded()
+	files := readZip(ctx, apk)
+	assert.For(ctx, "entry").That(string(files["AndroidManifest.xml"])).Equals("manifest")
+	assert.For(ctx, "signer").That(len(files[v1SignerPath]) > 0).Equals(true)
+}
+
+// readZip returns the uncompressed content of the files of the zip archive,
+// checking that uncompressed files are aligned.
+func readZip(ctx assert.Manager, data []byte) map[string][]byte {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	assert.With(ctx).ThatError(err).Succeeded()
+	files := map[string][]byte{}
+	for _, f := range r.File {
+		offset, err := f.DataOffset()
+		assert.With(ctx).ThatError(err).Succeeded()
+		if f.Method == zip.Store {
+			assert.For(ctx, "%s offset", f.Name).That(offset % alignment).Equals(int64(0))
+		}
+		fr, err := f.Open()
+		assert.With(ctx).ThatError(err).Succeeded()
+		files[f.Name], err = ioutil.ReadAll(fr)
+		assert.With(ctx).ThatError(err).Succeeded()
+		fr.Close()
+	}
+	return files
+}
+
+// findEOCD returns the offset of the zip end of central directory record in
+// data, or -1 if it cannot be found.
+func findEOCD(data []byte) int {
+	for i := len(data) - eocdSize; i >= 0 && i >= len(data)-eocdSize-0xffff; i-- {
+		if binary.LittleEndian.Uint32(data[i:]) != eocdSignature {
+			continue
+		}
+		commentSize := int(binary.LittleEndian.Uint16(data[i+20:]))
+		if i+eocdSize+commentSize == len(data) {
+			return i
+		}
+	}
+	return -1
+}
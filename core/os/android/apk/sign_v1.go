@@ -0,0 +1,233 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apk
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"encoding/asn1"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"regexp"
+	"sort"
+)
+
+const (
+	v1ManifestPath  = "META-INF/MANIFEST.MF"
+	v1SignaturePath = "META-INF/CERT.SF"
+	v1SignerPath    = "META-INF/CERT.RSA"
+
+	// maxManifestLine is the maximum length of a JAR manifest line in bytes,
+	// excluding the line break.
+	maxManifestLine = 72
+)
+
+// jarSignatureFilePattern matches the files of a JAR signature.
+var jarSignatureFilePattern = regexp.MustCompile(`^META-INF/([^/]*\.(DSA|RSA|EC|SF)|MANIFEST\.MF)$`)
+
+var (
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidSHA1          = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidRSAEncryption = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	asn1Null         = asn1.RawValue{Tag: asn1.TagNull}
+)
+
+// The PKCS#7 structures of a JAR signature block file, as described by
+// RFC 2315.
+type (
+	contentInfo struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue `asn1:"optional"`
+	}
+	algorithmIdentifier struct {
+		Algorithm  asn1.ObjectIdentifier
+		Parameters asn1.RawValue `asn1:"optional"`
+	}
+	issuerAndSerial struct {
+		Issuer asn1.RawValue
+		Serial *big.Int
+	}
+	signerInfo struct {
+		Version                   int
+		IssuerAndSerialNumber     issuerAndSerial
+		DigestAlgorithm           algorithmIdentifier
+		DigestEncryptionAlgorithm algorithmIdentifier
+		EncryptedDigest           []byte
+	}
+	signedData struct {
+		Version          int
+		DigestAlgorithms []algorithmIdentifier `asn1:"set"`
+		ContentInfo      contentInfo
+		Certificates     asn1.RawValue
+		SignerInfos      []signerInfo `asn1:"set"`
+	}
+)
+
+// manifestV1 is the JAR manifest of a set of entries, along with the
+// sections of the signature file that digest each section of the manifest.
+type manifestV1 struct {
+	manifest []byte
+	sections []byte
+}
+
+// newManifestV1 returns the JAR manifest of the entries, streaming the content
+// of each entry to digest it.
+func newManifestV1(entries []Entry) (*manifestV1, error) {
+	sorted := make([]Entry, len(entries))
+	copy(sorted, entries)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	manifest := &bytes.Buffer{}
+	writeManifestAttribute(manifest, "Manifest-Version", "1.0")
+	writeManifestAttribute(manifest, "Created-By", "1.0 (Android)")
+	manifest.WriteString("\r\n")
+	sections := &bytes.Buffer{}
+	for _, e := range sorted {
+		if len(e.Name) == 0 || e.Name[len(e.Name)-1] == '/' {
+			continue // Directories are not signed.
+		}
+		digest, err := digestEntryV1(e)
+		if err != nil {
+			return nil, err
+		}
+		section := &bytes.Buffer{}
+		writeManifestAttribute(section, "Name", e.Name)
+		writeManifestAttribute(section, "SHA1-Digest", digest)
+		section.WriteString("\r\n")
+
+		writeManifestAttribute(sections, "Name", e.Name)
+		writeManifestAttribute(sections, "SHA1-Digest", digestV1(section.Bytes()))
+		sections.WriteString("\r\n")
+
+		manifest.Write(section.Bytes())
+	}
+	return &manifestV1{manifest: manifest.Bytes(), sections: sections.Bytes()}, nil
+}
+
+// sign returns the entries of a v1 (JAR) signature of the manifest.
+// If signedV2 is true then the signature file declares that the APK is also
+// signed with the v2 scheme, so that the v2 signature cannot be stripped.
+func (m *manifestV1) sign(key *SigningKey, signedV2 bool) ([]Entry, error) {
+	signature := &bytes.Buffer{}
+	writeManifestAttribute(signature, "Signature-Version", "1.0")
+	writeManifestAttribute(signature, "Created-By", "1.0 (Android)")
+	writeManifestAttribute(signature, "SHA1-Digest-Manifest", digestV1(m.manifest))
+	if signedV2 {
+		writeManifestAttribute(signature, "X-Android-APK-Signed", "2")
+	}
+	signature.WriteString("\r\n")
+	signature.Write(m.sections)
+
+	block, err := signatureBlock(signature.Bytes(), key)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := func(name string, data []byte) Entry {
+		return Entry{Name: name, Method: zip.Deflate, ModifiedDate: 0x21, Data: data} // 1980-01-01
+	}
+	return []Entry{
+		entry(v1ManifestPath, m.manifest),
+		entry(v1SignaturePath, signature.Bytes()),
+		entry(v1SignerPath, block),
+	}, nil
+}
+
+// digestV1 returns the base64 encoded SHA-1 digest of data.
+func digestV1(data []byte) string {
+	digest := sha1.Sum(data)
+	return base64.StdEncoding.EncodeToString(digest[:])
+}
+
+// digestEntryV1 returns the base64 encoded SHA-1 digest of the uncompressed
+// content of the entry.
+func digestEntryV1(e Entry) (string, error) {
+	r, err := e.Open()
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	h := sha1.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeManifestAttribute writes the attribute to buf, wrapping it onto
+// continuation lines so that no line is longer than the JAR specification
+// permits.
+func writeManifestAttribute(buf *bytes.Buffer, name, value string) {
+	line := name + ": " + value
+	limit := maxManifestLine
+	for len(line) > limit {
+		buf.WriteString(line[:limit])
+		buf.WriteString("\r\n ")
+		line = line[limit:]
+		limit = maxManifestLine - 1 // Continuation lines start with a space.
+	}
+	buf.WriteString(line)
+	buf.WriteString("\r\n")
+}
+
+// signatureBlock returns the PKCS#7 detached signature of the signature file
+// content sf.
+func signatureBlock(sf []byte, key *SigningKey) ([]byte, error) {
+	digest := sha1.Sum(sf)
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key.Key, crypto.SHA1, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	sha1Alg := algorithmIdentifier{Algorithm: oidSHA1, Parameters: asn1Null}
+	data, err := asn1.Marshal(signedData{
+		Version:          1,
+		DigestAlgorithms: []algorithmIdentifier{sha1Alg},
+		ContentInfo:      contentInfo{ContentType: oidData},
+		Certificates: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        0,
+			IsCompound: true,
+			Bytes:      key.Certificate.Raw,
+		},
+		SignerInfos: []signerInfo{{
+			Version: 1,
+			IssuerAndSerialNumber: issuerAndSerial{
+				Issuer: asn1.RawValue{FullBytes: key.Certificate.RawIssuer},
+				Serial: key.Certificate.SerialNumber,
+			},
+			DigestAlgorithm:           sha1Alg,
+			DigestEncryptionAlgorithm: algorithmIdentifier{Algorithm: oidRSAEncryption, Parameters: asn1Null},
+			EncryptedDigest:           sig,
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        0,
+			IsCompound: true,
+			Bytes:      data,
+		},
+	})
+}
@@ -0,0 +1,167 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apk
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+)
+
+const (
+	// The constants of the APK Signature Scheme v2, described at
+	// https://source.android.com/security/apksigning/v2.
+	v2BlockID           = 0x7109871a
+	v2RSAPKCS1SHA256    = 0x0103
+	v2ChunkSize         = 1024 * 1024
+	v2SigningBlockMagic = "APK Sig Block 42"
+)
+
+// signingBlockV2 returns the APK Signing Block holding the v2 signature of
+// the archive with the given v2 digest.
+func signingBlockV2(digest []byte, key *SigningKey) ([]byte, error) {
+	signed := signedDataV2(digest, key)
+	hash := sha256.Sum256(signed)
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key.Key, crypto.SHA256, hash[:])
+	if err != nil {
+		return nil, err
+	}
+	return signerBlockV2(signed, sig, key)
+}
+
+// signingBlockV2Size returns the size of the APK Signing Block returned by
+// signingBlockV2, which does not depend on the digest.
+func signingBlockV2Size(key *SigningKey) (int, error) {
+	block, err := signerBlockV2(signedDataV2(make([]byte, sha256.Size), key), make([]byte, key.Key.Size()), key)
+	return len(block), err
+}
+
+// signedDataV2 returns the signed data of a v2 signer with the digest.
+func signedDataV2(digest []byte, key *SigningKey) []byte {
+	signed := &bytes.Buffer{}
+	signed.Write(prefixed(prefixed(uint32LE(v2RSAPKCS1SHA256), prefixed(digest))))
+	signed.Write(prefixed(prefixed(key.Certificate.Raw)))
+	signed.Write(prefixed()) // No additional attributes.
+	return signed.Bytes()
+}
+
+// signerBlockV2 returns the APK Signing Block holding the single v2 signer of
+// the signed data with the signature sig.
+func signerBlockV2(signed, sig []byte, key *SigningKey) ([]byte, error) {
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.Key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	signer := &bytes.Buffer{}
+	signer.Write(prefixed(signed))
+	signer.Write(prefixed(prefixed(uint32LE(v2RSAPKCS1SHA256), prefixed(sig))))
+	signer.Write(prefixed(publicKey))
+	return signingBlock(v2BlockID, prefixed(prefixed(signer.Bytes()))), nil
+}
+
+// digesterV2 computes the v2 scheme digest of the sections of a zip archive
+// as they are written. Each section is split into 1MB chunks, each chunk is
+// digested, and the digests of the chunks are then digested together. Only
+// the current chunk is held in memory.
+type digesterV2 struct {
+	chunk   []byte
+	digests []byte
+}
+
+// Write adds p to the current section.
+func (d *digesterV2) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		m := v2ChunkSize - len(d.chunk)
+		if m > len(p) {
+			m = len(p)
+		}
+		d.chunk = append(d.chunk, p[:m]...)
+		p = p[m:]
+		if len(d.chunk) == v2ChunkSize {
+			d.endSection()
+		}
+	}
+	return n, nil
+}
+
+// endSection ends the current section, as chunks do not span sections.
+func (d *digesterV2) endSection() {
+	if len(d.chunk) == 0 {
+		return
+	}
+	h := sha256.New()
+	h.Write([]byte{0xa5})
+	h.Write(uint32LE(uint32(len(d.chunk))))
+	h.Write(d.chunk)
+	d.digests = h.Sum(d.digests)
+	d.chunk = d.chunk[:0]
+}
+
+// sum ends the current section and returns the digest of the sections.
+func (d *digesterV2) sum() []byte {
+	d.endSection()
+	top := sha256.New()
+	top.Write([]byte{0x5a})
+	top.Write(uint32LE(uint32(len(d.digests) / sha256.Size)))
+	top.Write(d.digests)
+	return top.Sum(nil)
+}
+
+// digestV2 returns the v2 scheme digest of the sections of a zip archive.
+func digestV2(sections ...[]byte) []byte {
+	d := &digesterV2{}
+	for _, s := range sections {
+		d.Write(s)
+		d.endSection()
+	}
+	return d.sum()
+}
+
+// signingBlock returns an APK Signing Block holding the single ID-value pair.
+func signingBlock(id uint32, value []byte) []byte {
+	pair := make([]byte, 12, 12+len(value))
+	binary.LittleEndian.PutUint64(pair, uint64(4+len(value)))
+	binary.LittleEndian.PutUint32(pair[8:], id)
+	pair = append(pair, value...)
+
+	size := uint64(len(pair) + 8 + len(v2SigningBlockMagic))
+	block := make([]byte, 8, 8+size)
+	binary.LittleEndian.PutUint64(block, size)
+	block = append(block, pair...)
+	block = append(block, make([]byte, 8)...)
+	binary.LittleEndian.PutUint64(block[len(block)-8:], size)
+	return append(block, v2SigningBlockMagic...)
+}
+
+// prefixed returns the concatenation of data prefixed with its 32-bit
+// little-endian length.
+func prefixed(data ...[]byte) []byte {
+	buf := &bytes.Buffer{}
+	for _, d := range data {
+		buf.Write(d)
+	}
+	return append(uint32LE(uint32(buf.Len())), buf.Bytes()...)
+}
+
+func uint32LE(v uint32) []byte {
+	out := make([]byte, 4)
+	binary.LittleEndian.PutUint32(out, v)
+	return out
+}
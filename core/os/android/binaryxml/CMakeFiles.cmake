@@ -23,6 +23,12 @@ set(files
     decode.go
     decode_test.go
     doc.go
+    edit.go
+    edit_test.go
+    resource_config.go
+    resource_table.go
+    resource_table_edit.go
+    resource_table_test.go
     string_pool.go
     value.go
     xml_attribute.go
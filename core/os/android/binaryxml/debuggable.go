@@ -19,7 +19,11 @@ import (
 	"io"
 )
 
-const debuggableAttr uint32 = 0x0101000f
+const (
+	nameAttr                  uint32 = 0x01010003
+	debuggableAttr            uint32 = 0x0101000f
+	networkSecurityConfigAttr uint32 = 0x01010527
+)
 
 func startElementVisitor(path string, f func(*xmlContext, *xmlStartElement)) chunkVisitor {
 	return func(ctx *xmlContext, c chunk, when int) {
@@ -31,25 +35,18 @@ func startElementVisitor(path string, f func(*xmlContext, *xmlStartElement)) chu
 }
 
 // setManifestApplicationDebuggable sets android:debuggable="true" under the <application/> element of the manifest.
-// The function returns true on success. It will fail if it cannot find the application element.
-func setManifestApplicationDebuggableAttributeToTrue(xml *xmlTree) (success bool) {
-	success = false
-	xml.visit(startElementVisitor("manifest/application", func(ctx *xmlContext, xse *xmlStartElement) {
-		refDebuggable := xml.ensureAttributeNameMapsToResource(debuggableAttr, "debuggable")
-
-		if at, ok := xse.attributes.forName(refDebuggable); ok {
-			at.typedValue = valIntBoolean(true)
-		} else {
-			xse.addAttribute(&xmlAttribute{
-				namespace:  ctx.strings.ref("http://schemas.android.com/apk/res/android"),
-				name:       refDebuggable,
-				rawValue:   invalidStringPoolRef,
-				typedValue: valIntBoolean(true),
-			})
+// It will fail if it cannot find the application element, or cannot add the attribute.
+func setManifestApplicationDebuggableAttributeToTrue(xml *xmlTree) error {
+	apps := (&Document{xml}).Elements("manifest/application")
+	if len(apps) == 0 {
+		return fmt.Errorf("error modifying manifest")
+	}
+	for _, app := range apps {
+		if err := app.SetAndroidAttribute("debuggable", debuggableAttr, BoolValue(true)); err != nil {
+			return err
 		}
-		success = true
-	}))
-	return success
+	}
+	return nil
 }
 
 // SetDebuggableFlag takes a Reader that produces a manifest binary xml,
@@ -62,9 +59,76 @@ func SetDebuggableFlag(r io.Reader, w io.Writer) error {
 
 	}
 
-	if !setManifestApplicationDebuggableAttributeToTrue(tree) {
-		return fmt.Errorf("error modifying manifest")
+	if err := SetDebuggable(&Document{tree}); err != nil {
+		return err
 	}
 	_, err = w.Write(tree.encode())
 	return err
 }
+
+// SetDebuggable sets android:debuggable="true" under the <application/>
+// element of the manifest.
+func SetDebuggable(manifest *Document) error {
+	return setManifestApplicationDebuggableAttributeToTrue(manifest.tree)
+}
+
+// AddPermissions adds a <uses-permission/> element to the manifest for each of
+// the named permissions that it does not already request.
+func AddPermissions(manifest *Document, permissions ...string) error {
+	root := manifest.Root()
+	if root == nil || root.Name() != "manifest" {
+		return fmt.Errorf("Document is not a manifest")
+	}
+	var before *Element
+	if apps := root.Children("application"); len(apps) > 0 {
+		before = apps[0]
+	}
+	for _, p := range permissions {
+		if hasPermission(root, p) {
+			continue
+		}
+		e := root.InsertChild("uses-permission", before)
+		if err := e.SetAndroidAttribute("name", nameAttr, StringValue(p)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hasPermission(manifest *Element, permission string) bool {
+	for _, e := range manifest.Children("uses-permission") {
+		if name, ok := e.Attribute("name"); ok && name == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// SetNetworkSecurityConfig sets android:networkSecurityConfig under the
+// <application/> element of the manifest to the XML resource with the given ID.
+func SetNetworkSecurityConfig(manifest *Document, id uint32) error {
+	apps := manifest.Elements("manifest/application")
+	if len(apps) == 0 {
+		return fmt.Errorf("Manifest has no application element")
+	}
+	for _, app := range apps {
+		if err := app.SetAndroidAttribute("networkSecurityConfig", networkSecurityConfigAttr, ReferenceValue(id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DebugNetworkSecurityConfig returns a network security configuration that
+// permits cleartext traffic and trusts user-installed certificate
+// authorities, so that the application's traffic can be inspected.
+func DebugNetworkSecurityConfig() *Document {
+	d := NewDocument("network-security-config")
+	base := d.Root().InsertChild("base-config", nil)
+	base.SetAttribute("cleartextTrafficPermitted", BoolValue(true))
+	anchors := base.InsertChild("trust-anchors", nil)
+	for _, src := range []string{"system", "user"} {
+		anchors.InsertChild("certificates", nil).SetAttribute("src", StringValue(src))
+	}
+	return d
+}
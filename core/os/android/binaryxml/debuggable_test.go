@@ -37,7 +37,8 @@ func TestSettingDebuggableFlag(t *testing.T) {
 		assert.With(ctx).ThatError(err).Succeeded()
 
 		assert.With(ctx).ThatString(tree.toXmlString()).DoesNotContain(`android:debuggable="true"`)
-		setManifestApplicationDebuggableAttributeToTrue(tree)
+		err = setManifestApplicationDebuggableAttributeToTrue(tree)
+		assert.With(ctx).ThatError(err).Succeeded()
 
 		xmlString := tree.toXmlString()
 		assert.With(ctx).ThatString(xmlString).Contains(`android:debuggable="true"`)
@@ -12,5 +12,6 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package binaryxml is a package for dealing with the binary format of the android manifest.
+// Package binaryxml is a package for dealing with the binary format of the android manifest
+// and of compiled resource tables (resources.arsc).
 package binaryxml
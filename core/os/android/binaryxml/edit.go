@@ -0,0 +1,322 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binaryxml
+
+import "bytes"
+
+const androidNamespace = "http://schemas.android.com/apk/res/android"
+
+// Document is a decoded binary XML document that can be modified and encoded
+// again. New strings are added to the document's string pool, and the
+// resource map is updated for new attributes that map to resource IDs.
+type Document struct {
+	tree *xmlTree
+}
+
+// Element is an element of a Document. An Element must not be used after it
+// has been removed from the document.
+type Element struct {
+	doc   *Document
+	start *xmlStartElement
+}
+
+// Value is a typed attribute value.
+type Value interface {
+	value(x *xmlTree) (raw stringPoolRef, typed typedValue)
+}
+
+type stringValue string
+type boolValue bool
+type intValue int32
+type referenceValue uint32
+
+// StringValue returns a string attribute value.
+func StringValue(s string) Value { return stringValue(s) }
+
+// BoolValue returns a boolean attribute value.
+func BoolValue(b bool) Value { return boolValue(b) }
+
+// IntValue returns an integer attribute value.
+func IntValue(i int32) Value { return intValue(i) }
+
+// ReferenceValue returns an attribute value referring to the resource with
+// the given ID.
+func ReferenceValue(id uint32) Value { return referenceValue(id) }
+
+func (v stringValue) value(x *xmlTree) (stringPoolRef, typedValue) {
+	ref := x.strings.ref(string(v))
+	return ref, valStringID(ref)
+}
+
+func (v boolValue) value(*xmlTree) (stringPoolRef, typedValue) {
+	return invalidStringPoolRef, valIntBoolean(v)
+}
+
+func (v intValue) value(*xmlTree) (stringPoolRef, typedValue) {
+	return invalidStringPoolRef, valIntDec(v)
+}
+
+func (v referenceValue) value(*xmlTree) (stringPoolRef, typedValue) {
+	return invalidStringPoolRef, valReference(v)
+}
+
+// DecodeDocument decodes a binary XML document.
+func DecodeDocument(data []byte) (*Document, error) {
+	tree, err := decodeXmlTree(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return &Document{tree}, nil
+}
+
+// NewDocument returns a new document holding an empty root element with the
+// given name.
+func NewDocument(root string) *Document {
+	tree := &xmlTree{strings: &stringPool{}, resourceMap: &xmlResourceMap{}}
+	tree.strings.setRoot(tree)
+	tree.resourceMap.setRoot(tree)
+	d := &Document{tree}
+	d.insert(0, d.newElement(root, 0)...)
+	return d
+}
+
+// Encode returns the binary XML encoding of the document.
+func (d *Document) Encode() []byte {
+	return d.tree.encode()
+}
+
+// String returns the document as a textual XML string.
+func (d *Document) String() string {
+	return d.tree.toXmlString()
+}
+
+// Root returns the root element of the document, or nil if there is none.
+func (d *Document) Root() *Element {
+	for _, c := range d.tree.chunks {
+		if xse, ok := c.(*xmlStartElement); ok {
+			return &Element{d, xse}
+		}
+	}
+	return nil
+}
+
+// Elements returns all the elements with the given path, such as
+// "manifest/application", in document order.
+func (d *Document) Elements(path string) []*Element {
+	out := []*Element{}
+	d.tree.visit(startElementVisitor(path, func(ctx *xmlContext, xse *xmlStartElement) {
+		out = append(out, &Element{d, xse})
+	}))
+	return out
+}
+
+// newElement returns the start and end chunks of a new element.
+func (d *Document) newElement(name string, lineNumber uint32) []chunk {
+	ref := d.tree.strings.ref(name)
+	return []chunk{
+		&xmlStartElement{
+			lineNumber: lineNumber,
+			comment:    invalidStringPoolRef,
+			namespace:  invalidStringPoolRef,
+			name:       ref,
+		},
+		&xmlEndElement{
+			lineNumber: lineNumber,
+			comment:    invalidStringPoolRef,
+			namespace:  invalidStringPoolRef,
+			name:       ref,
+		},
+	}
+}
+
+// insert inserts chunks into the document at the given index.
+func (d *Document) insert(index int, chunks ...chunk) {
+	for _, c := range chunks {
+		c.setRoot(d.tree)
+	}
+	tail := append(chunks, d.tree.chunks[index:]...)
+	d.tree.chunks = append(d.tree.chunks[:index], tail...)
+}
+
+// indexOf returns the index of the chunk c in the document, or -1 if the
+// document does not hold it.
+func (d *Document) indexOf(c chunk) int {
+	for i, o := range d.tree.chunks {
+		if o == c {
+			return i
+		}
+	}
+	return -1
+}
+
+// endOf returns the index of the end element matching the start element at
+// index i.
+func (d *Document) endOf(i int) int {
+	depth := 0
+	for ; i < len(d.tree.chunks); i++ {
+		switch d.tree.chunks[i].(type) {
+		case *xmlStartElement:
+			depth++
+		case *xmlEndElement:
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(d.tree.chunks)
+}
+
+// ensureNamespace declares the namespace uri with the given prefix for the
+// whole document, unless it is already declared.
+func (d *Document) ensureNamespace(prefix, uri string) {
+	for _, c := range d.tree.chunks {
+		if ns, ok := c.(*xmlStartNamespace); ok && ns.namespaceURI.get() == uri {
+			return
+		}
+	}
+	p, u := d.tree.strings.ref(prefix), d.tree.strings.ref(uri)
+	d.insert(len(d.tree.chunks), &xmlEndNamespace{
+		comment:         invalidStringPoolRef,
+		namespacePrefix: p,
+		namespaceURI:    u,
+	})
+	d.insert(0, &xmlStartNamespace{
+		comment:         invalidStringPoolRef,
+		namespacePrefix: p,
+		namespaceURI:    u,
+	})
+}
+
+// Name returns the name of the element.
+func (e *Element) Name() string {
+	return e.start.name.get()
+}
+
+// Attribute returns the value of the attribute with the given name, in any
+// namespace, formatted as in a decoded XML file.
+func (e *Element) Attribute(name string) (string, bool) {
+	for _, a := range e.start.attributes {
+		if a.name.get() != name {
+			continue
+		}
+		if a.rawValue.isValid() {
+			return a.rawValue.get(), true
+		}
+		return a.typedValue.String(), true
+	}
+	return "", false
+}
+
+// SetAttribute sets the value of the attribute with the given name and no
+// namespace, adding the attribute if necessary.
+func (e *Element) SetAttribute(name string, v Value) {
+	e.setAttribute(invalidStringPoolRef, e.doc.tree.attributeName(name), v)
+}
+
+// SetAndroidAttribute sets the value of the android namespace attribute with
+// the given name and resource ID, such as android:debuggable (0x0101000f),
+// adding the attribute if necessary.
+func (e *Element) SetAndroidAttribute(name string, id uint32, v Value) error {
+	x := e.doc.tree
+	attr, err := x.ensureAttributeNameMapsToResource(id, name)
+	if err != nil {
+		return err
+	}
+	e.doc.ensureNamespace("android", androidNamespace)
+	e.setAttribute(x.strings.ref(androidNamespace), attr, v)
+	return nil
+}
+
+func (e *Element) setAttribute(namespace, name stringPoolRef, v Value) {
+	raw, typed := v.value(e.doc.tree)
+	if at, ok := e.start.attributes.forName(name); ok {
+		at.rawValue, at.typedValue = raw, typed
+		return
+	}
+	e.start.addAttribute(&xmlAttribute{
+		namespace:  namespace,
+		name:       name,
+		rawValue:   raw,
+		typedValue: typed,
+	})
+}
+
+// RemoveAttribute removes the attributes with the given name, in any
+// namespace. It returns true if an attribute was removed.
+func (e *Element) RemoveAttribute(name string) bool {
+	attributes := e.start.attributes[:0]
+	for _, a := range e.start.attributes {
+		if a.name.get() != name {
+			attributes = append(attributes, a)
+		}
+	}
+	removed := len(attributes) != len(e.start.attributes)
+	e.start.attributes = attributes
+	return removed
+}
+
+// Children returns the child elements with the given name, or all the child
+// elements if name is empty.
+func (e *Element) Children(name string) []*Element {
+	out := []*Element{}
+	i := e.doc.indexOf(e.start)
+	if i < 0 {
+		return out
+	}
+	depth := 0
+	for _, c := range e.doc.tree.chunks[i+1:] {
+		switch c := c.(type) {
+		case *xmlStartElement:
+			if depth == 0 && (name == "" || c.name.get() == name) {
+				out = append(out, &Element{e.doc, c})
+			}
+			depth++
+		case *xmlEndElement:
+			if depth == 0 {
+				return out
+			}
+			depth--
+		}
+	}
+	return out
+}
+
+// InsertChild adds a new child element with the given name before the child
+// element before, or after the last child if before is nil.
+func (e *Element) InsertChild(name string, before *Element) *Element {
+	var i int
+	if before != nil {
+		i = e.doc.indexOf(before.start)
+	} else {
+		i = e.doc.endOf(e.doc.indexOf(e.start))
+	}
+	chunks := e.doc.newElement(name, e.start.lineNumber)
+	e.doc.insert(i, chunks...)
+	return &Element{e.doc, chunks[0].(*xmlStartElement)}
+}
+
+// Remove removes the element and all its children from the document.
+func (e *Element) Remove() {
+	i := e.doc.indexOf(e.start)
+	if i < 0 {
+		return
+	}
+	chunks := e.doc.tree.chunks
+	end := e.doc.endOf(i)
+	if end < len(chunks) {
+		end++
+	}
+	e.doc.tree.chunks = append(chunks[:i], chunks[end:]...)
+}
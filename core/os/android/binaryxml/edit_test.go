@@ -0,0 +1,135 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binaryxml
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+)
+
+func TestAddPermissions(t *testing.T) {
+	ctx := assert.Context(t)
+	for _, fn := range []string{
+		"testdata/manifest1.binxml",
+		"testdata/manifest4.binxml",
+	} {
+		data, err := ioutil.ReadFile(fn)
+		assert.With(ctx).ThatError(err).Succeeded()
+		doc, err := DecodeDocument(data)
+		assert.With(ctx).ThatError(err).Succeeded()
+
+		const permission = "android.permission.INTERNET"
+		err = AddPermissions(doc, permission, "com.example.permission.TEST", permission)
+		assert.With(ctx).ThatError(err).Succeeded()
+		err = SetNetworkSecurityConfig(doc, 0x7f0a0000)
+		assert.With(ctx).ThatError(err).Succeeded()
+
+		// Make sure the changes survive encoding.
+		doc, err = DecodeDocument(doc.Encode())
+		assert.With(ctx).ThatError(err).Succeeded()
+
+		count := 0
+		for _, e := range doc.Elements("manifest/uses-permission") {
+			if name, _ := e.Attribute("name"); name == permission {
+				count++
+			}
+		}
+		assert.For(ctx, "%s permissions", fn).That(count).Equals(1)
+		// The permissions are added before the application element.
+		last := ""
+		for _, e := range doc.Root().Children("") {
+			if e.Name() == "application" {
+				break
+			}
+			last = e.Name()
+		}
+		assert.For(ctx, "%s before application", fn).That(last).Equals("uses-permission")
+		assert.For(ctx, "%s config", fn).ThatString(doc.String()).Contains(`android:networkSecurityConfig="@0x7f0a0000"`)
+		assert.For(ctx, "%s test permission", fn).ThatString(doc.String()).Contains(`android:name="com.example.permission.TEST"`)
+	}
+}
+
+func TestEditDocument(t *testing.T) {
+	ctx := assert.Context(t)
+	doc := NewDocument("root")
+	a := doc.Root().InsertChild("a", nil)
+	c := doc.Root().InsertChild("c", nil)
+	b := doc.Root().InsertChild("b", c)
+	a.SetAttribute("name", StringValue("value"))
+	a.SetAttribute("enabled", BoolValue(true))
+	err := b.SetAndroidAttribute("name", nameAttr, IntValue(42))
+	assert.With(ctx).ThatError(err).Succeeded()
+	c.InsertChild("d", nil)
+
+	doc, err = DecodeDocument(doc.Encode())
+	assert.With(ctx).ThatError(err).Succeeded()
+	names := []string{}
+	for _, e := range doc.Root().Children("") {
+		names = append(names, e.Name())
+	}
+	assert.For(ctx, "children").ThatSlice(names).Equals([]string{"a", "b", "c"})
+
+	a = doc.Elements("root/a")[0]
+	value, ok := a.Attribute("name")
+	assert.For(ctx, "plain attribute").That(value).Equals("value")
+	assert.For(ctx, "plain attribute found").That(ok).Equals(true)
+	value, _ = a.Attribute("enabled")
+	assert.For(ctx, "bool attribute").That(value).Equals("true")
+	value, _ = doc.Elements("root/b")[0].Attribute("name")
+	assert.For(ctx, "android attribute").That(value).Equals("42")
+	assert.For(ctx, "namespace").ThatString(doc.String()).Contains(`android:name="42"`)
+	assert.For(ctx, "resource map").ThatSlice(doc.tree.resourceMap.ids).Equals([]uint32{nameAttr})
+
+	assert.For(ctx, "remove attribute").That(a.RemoveAttribute("enabled")).Equals(true)
+	_, ok = a.Attribute("enabled")
+	assert.For(ctx, "removed attribute").That(ok).Equals(false)
+
+	doc.Elements("root/c")[0].Remove()
+	doc, err = DecodeDocument(doc.Encode())
+	assert.With(ctx).ThatError(err).Succeeded()
+	assert.For(ctx, "removed element").That(len(doc.Root().Children(""))).Equals(2)
+	assert.For(ctx, "removed child").That(len(doc.Elements("root/c/d"))).Equals(0)
+}
+
+func TestDebugNetworkSecurityConfig(t *testing.T) {
+	ctx := assert.Context(t)
+	doc, err := DecodeDocument(DebugNetworkSecurityConfig().Encode())
+	assert.With(ctx).ThatError(err).Succeeded()
+	base := doc.Elements("network-security-config/base-config")
+	assert.For(ctx, "base-config").That(len(base)).Equals(1)
+	value, _ := base[0].Attribute("cleartextTrafficPermitted")
+	assert.For(ctx, "cleartext").That(value).Equals("true")
+	srcs := []string{}
+	for _, e := range doc.Elements("network-security-config/base-config/trust-anchors/certificates") {
+		src, _ := e.Attribute("src")
+		srcs = append(srcs, src)
+	}
+	assert.For(ctx, "certificates").ThatSlice(srcs).Equals([]string{"system", "user"})
+	assert.For(ctx, "string").ThatString(doc.String()).Contains(`<network-security-config>`)
+}
+
+func TestInsertIntoStyledStringPool(t *testing.T) {
+	ctx := assert.Context(t)
+	p := &stringPool{strings: []string{"styled", "plain"}, ptrs: []int{0, 1}, styleIndices: []uint32{0}}
+	_, err := p.insertStringAtIndex("name", 0)
+	assert.For(ctx, "insert").ThatError(err).Failed()
+	assert.For(ctx, "unchanged").ThatSlice(p.strings).Equals([]string{"styled", "plain"})
+
+	ref := p.ref("appended")
+	assert.For(ctx, "appended").That(ref.get()).Equals("appended")
+	assert.For(ctx, "styled").That(stringPoolRef{p, 0}.get()).Equals("styled")
+}
@@ -0,0 +1,183 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binaryxml
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// Standard screen densities, in dots per inch.
+const (
+	DensityDefault = 0
+	DensityLow     = 120
+	DensityMedium  = 160
+	DensityTV      = 213
+	DensityHigh    = 240
+	DensityXHigh   = 320
+	DensityXXHigh  = 480
+	DensityXXXHigh = 640
+	DensityAny     = 0xfffe
+	DensityNone    = 0xffff
+)
+
+var densityNames = map[uint16]string{
+	DensityLow:     "ldpi",
+	DensityMedium:  "mdpi",
+	DensityTV:      "tvdpi",
+	DensityHigh:    "hdpi",
+	DensityXHigh:   "xhdpi",
+	DensityXXHigh:  "xxhdpi",
+	DensityXXXHigh: "xxxhdpi",
+	DensityAny:     "anydpi",
+	DensityNone:    "nodpi",
+}
+
+var orientationNames = map[uint8]string{1: "port", 2: "land", 3: "square"}
+
+// ResourceConfig is the device configuration that a set of resource values
+// apply to. Zero fields match any configuration.
+// See ResTable_config in ResourceTypes.h.
+type ResourceConfig struct {
+	MCC                   uint16
+	MNC                   uint16
+	Language              string
+	Region                string
+	Orientation           uint8
+	Touchscreen           uint8
+	Density               uint16
+	Keyboard              uint8
+	Navigation            uint8
+	ScreenWidth           uint16
+	ScreenHeight          uint16
+	SDKVersion            uint16
+	ScreenLayout          uint8
+	UIMode                uint8
+	SmallestScreenWidthDp uint16
+	ScreenWidthDp         uint16
+	ScreenHeightDp        uint16
+}
+
+// resourceConfigSize is the size of the ResTable_config fields decoded into a
+// ResourceConfig.
+const resourceConfigSize = 36
+
+func decodeResourceConfig(data []byte) (ResourceConfig, error) {
+	if len(data) < 4 {
+		return ResourceConfig{}, fmt.Errorf("Resource configuration too short")
+	}
+	size := binary.LittleEndian.Uint32(data)
+	if size > uint32(len(data)) || size < 4 {
+		return ResourceConfig{}, fmt.Errorf("Invalid resource configuration size %d", size)
+	}
+	// Older files have smaller configurations, missing fields are zero.
+	b := make([]byte, resourceConfigSize)
+	copy(b, data[:size])
+	u16 := func(offset int) uint16 { return binary.LittleEndian.Uint16(b[offset:]) }
+	return ResourceConfig{
+		MCC:                   u16(4),
+		MNC:                   u16(6),
+		Language:              unpackLocale(b[8], b[9], 'a'),
+		Region:                unpackLocale(b[10], b[11], '0'),
+		Orientation:           b[12],
+		Touchscreen:           b[13],
+		Density:               u16(14),
+		Keyboard:              b[16],
+		Navigation:            b[17],
+		ScreenWidth:           u16(20),
+		ScreenHeight:          u16(22),
+		SDKVersion:            u16(24),
+		ScreenLayout:          b[28],
+		UIMode:                b[29],
+		SmallestScreenWidthDp: u16(30),
+		ScreenWidthDp:         u16(32),
+		ScreenHeightDp:        u16(34),
+	}, nil
+}
+
+// unpackLocale returns the language or region code held in the two bytes.
+// Three letter codes are packed into the two bytes as 5-bit offsets from base.
+func unpackLocale(a, b, base byte) string {
+	switch {
+	case a == 0:
+		return ""
+	case a&0x80 == 0:
+		return string([]byte{a, b})
+	default:
+		return string([]byte{
+			base + b&0x1f,
+			base + ((b&0xe0)>>5 | (a&0x03)<<3),
+			base + (a&0x7c)>>2,
+		})
+	}
+}
+
+// IsDefault returns true if the configuration matches any device.
+func (c ResourceConfig) IsDefault() bool {
+	return c == ResourceConfig{}
+}
+
+// DensityName returns the resource qualifier for the configuration's density,
+// such as "hdpi", or an empty string if the density is not specified.
+func (c ResourceConfig) DensityName() string {
+	if c.Density == DensityDefault {
+		return ""
+	}
+	if name, ok := densityNames[c.Density]; ok {
+		return name
+	}
+	return fmt.Sprintf("%ddpi", c.Density)
+}
+
+// String returns the common resource qualifiers of the configuration, as
+// used in resource directory names, for example "en-rGB-hdpi-v21".
+func (c ResourceConfig) String() string {
+	parts := []string{}
+	if c.MCC != 0 {
+		parts = append(parts, fmt.Sprintf("mcc%d", c.MCC))
+	}
+	if c.MNC != 0 {
+		parts = append(parts, fmt.Sprintf("mnc%d", c.MNC))
+	}
+	if c.Language != "" {
+		parts = append(parts, c.Language)
+	}
+	if c.Region != "" {
+		parts = append(parts, "r"+c.Region)
+	}
+	if c.SmallestScreenWidthDp != 0 {
+		parts = append(parts, fmt.Sprintf("sw%ddp", c.SmallestScreenWidthDp))
+	}
+	if c.ScreenWidthDp != 0 {
+		parts = append(parts, fmt.Sprintf("w%ddp", c.ScreenWidthDp))
+	}
+	if c.ScreenHeightDp != 0 {
+		parts = append(parts, fmt.Sprintf("h%ddp", c.ScreenHeightDp))
+	}
+	if name, ok := orientationNames[c.Orientation]; ok {
+		parts = append(parts, name)
+	}
+	if d := c.DensityName(); d != "" {
+		parts = append(parts, d)
+	}
+	if c.SDKVersion != 0 {
+		parts = append(parts, fmt.Sprintf("v%d", c.SDKVersion))
+	}
+	if len(parts) == 0 {
+		return "default"
+	}
+	return strings.Join(parts, "-")
+}
@@ -0,0 +1,475 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binaryxml
+
+import (
+	"bytes"
+	"context"
+	eb "encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/google/gapid/core/data/binary"
+	"github.com/google/gapid/core/data/endian"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device"
+)
+
+const (
+	// resTableEntryComplex is the ResTable_entry flag for bag entries.
+	resTableEntryComplex = 0x0001
+	// resTableTypeSparse is the ResTable_type flag for sparse entry offsets.
+	resTableTypeSparse = 0x01
+	// resTableNoEntry is the ResTable_type offset of a missing entry.
+	resTableNoEntry = 0xffffffff
+	// complexEntrySize is the size of a ResTable_map_entry, which precedes
+	// the items of a bag entry.
+	complexEntrySize = 16
+	// bagItemSize is the size of a ResTable_map: a name and a value.
+	bagItemSize = 12
+	// maxReferenceDepth is the maximum number of references followed when
+	// resolving a resource value.
+	maxReferenceDepth = 16
+)
+
+// ResourceTable is a decoded compiled resource table, as found in the
+// resources.arsc file of an APK.
+type ResourceTable struct {
+	Packages []*ResourcePackage
+	strings  *stringPool // The pool of string values.
+}
+
+// ResourcePackage is a package of resources in a ResourceTable.
+type ResourcePackage struct {
+	ID    uint8
+	Name  string
+	Types []*ResourceType
+}
+
+// ResourceType is a type of resource in a package, such as "string".
+type ResourceType struct {
+	ID      uint8
+	Name    string
+	Specs   []uint32 // The configuration change flags of each entry.
+	Configs []*ResourceTypeConfig
+}
+
+// ResourceTypeConfig holds the entries of a resource type for a single
+// configuration.
+type ResourceTypeConfig struct {
+	Config  ResourceConfig
+	Entries map[uint16]*ResourceEntry
+}
+
+// ResourceEntry is a single resource value in a particular configuration.
+type ResourceEntry struct {
+	Key    string
+	Value  ResourceValue            // The value, if this is not a bag entry.
+	Parent uint32                   // The parent of a bag entry.
+	Bag    map[uint32]ResourceValue // The values of a bag entry by attribute.
+}
+
+// ResourceValue is a single typed resource value.
+type ResourceValue struct {
+	Type uint8  // The Res_value data type.
+	Data uint32 // The raw value data.
+	str  string // The string, for string values.
+}
+
+// ConfigEntry is the entry of a resource for a single configuration.
+type ConfigEntry struct {
+	Config ResourceConfig
+	Entry  *ResourceEntry
+}
+
+// DecodeResourceTable decodes the compiled resource table data.
+func DecodeResourceTable(ctx context.Context, data []byte) (*ResourceTable, error) {
+	t, err := decodeResourceTable(data)
+	if err != nil {
+		return nil, log.Err(ctx, err, "Decoding resource table")
+	}
+	return t, nil
+}
+
+// resChunk is a raw chunk of a resource table.
+type resChunk struct {
+	ty     uint16
+	offset int    // The offset of the chunk in the data holding it.
+	header []byte // The header, excluding the type and sizes.
+	data   []byte
+}
+
+// splitChunks splits data into the chunks it holds.
+func splitChunks(data []byte) ([]resChunk, error) {
+	out := []resChunk{}
+	for offset := 0; offset+8 <= len(data); {
+		ty := eb.LittleEndian.Uint16(data[offset:])
+		headerSize := int(eb.LittleEndian.Uint16(data[offset+2:]))
+		size := int(eb.LittleEndian.Uint32(data[offset+4:]))
+		if headerSize < 8 || size < headerSize || offset+size > len(data) {
+			return nil, fmt.Errorf("Invalid chunk 0x%x at offset 0x%x", ty, offset)
+		}
+		out = append(out, resChunk{
+			ty:     ty,
+			offset: offset,
+			header: data[offset+8 : offset+headerSize],
+			data:   data[offset+headerSize : offset+size],
+		})
+		offset += size
+	}
+	return out, nil
+}
+
+func decodeResourceTable(data []byte) (*ResourceTable, error) {
+	chunks, err := splitChunks(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) != 1 || chunks[0].ty != resTableType {
+		return nil, fmt.Errorf("Expected a single resource table chunk")
+	}
+	chunks, err = splitChunks(chunks[0].data)
+	if err != nil {
+		return nil, err
+	}
+	t := &ResourceTable{}
+	for _, c := range chunks {
+		switch c.ty {
+		case resStringPoolType:
+			t.strings = &stringPool{}
+			if err := t.strings.decode(c.header, c.data); err != nil {
+				return nil, err
+			}
+		case resTablePackageType:
+			p, err := t.decodePackage(c)
+			if err != nil {
+				return nil, err
+			}
+			t.Packages = append(t.Packages, p)
+		}
+	}
+	if t.strings == nil {
+		return nil, fmt.Errorf("Resource table has no string pool")
+	}
+	return t, nil
+}
+
+func (t *ResourceTable) decodePackage(c resChunk) (*ResourcePackage, error) {
+	r := endian.Reader(bytes.NewReader(c.header), device.LittleEndian)
+	id := r.Uint32()
+	name := make([]uint16, 128)
+	for i := range name {
+		name[i] = r.Uint16()
+	}
+	typeStrings := int(r.Uint32())
+	r.Uint32() // lastPublicType
+	keyStrings := int(r.Uint32())
+	if err := r.Error(); err != nil {
+		return nil, err
+	}
+	for i, c := range name {
+		if c == 0 {
+			name = name[:i]
+			break
+		}
+	}
+	p := &ResourcePackage{ID: uint8(id), Name: string(utf16.Decode(name))}
+
+	chunks, err := splitChunks(c.data)
+	if err != nil {
+		return nil, err
+	}
+	// The string pool offsets are relative to the start of the package chunk.
+	dataOffset := 8 + len(c.header)
+	types, keys := &stringPool{}, &stringPool{}
+	for _, c := range chunks {
+		if c.ty != resStringPoolType {
+			continue
+		}
+		switch c.offset + dataOffset {
+		case typeStrings:
+			err = types.decode(c.header, c.data)
+		case keyStrings:
+			err = keys.decode(c.header, c.data)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, c := range chunks {
+		switch c.ty {
+		case resTableTypeSpecType:
+			r := endian.Reader(bytes.NewReader(c.header), device.LittleEndian)
+			id := r.Uint8()
+			r.Uint8()  // res0
+			r.Uint16() // res1
+			count := r.Uint32()
+			if err := r.Error(); err != nil {
+				return nil, err
+			}
+			if uint64(count)*4 > uint64(len(c.data)) {
+				return nil, fmt.Errorf("Resource type spec count %d exceeds the chunk size", count)
+			}
+			specs := make([]uint32, count)
+			r = endian.Reader(bytes.NewReader(c.data), device.LittleEndian)
+			for i := range specs {
+				specs[i] = r.Uint32()
+			}
+			if err := r.Error(); err != nil {
+				return nil, err
+			}
+			ty := p.typeOf(id, types)
+			ty.Specs = specs
+		case resTableTypeType:
+			config, id, err := t.decodeType(c, keys)
+			if err != nil {
+				return nil, err
+			}
+			ty := p.typeOf(id, types)
+			ty.Configs = append(ty.Configs, config)
+		}
+	}
+	return p, nil
+}
+
+// typeOf returns the type of the package with the given ID, adding it if
+// necessary.
+func (p *ResourcePackage) typeOf(id uint8, names *stringPool) *ResourceType {
+	if t := p.Type(id); t != nil {
+		return t
+	}
+	t := &ResourceType{ID: id}
+	if i := int(id) - 1; i >= 0 && i < len(names.strings) {
+		t.Name = names.strings[i]
+	}
+	p.Types = append(p.Types, t)
+	return t
+}
+
+func (t *ResourceTable) decodeType(c resChunk, keys *stringPool) (*ResourceTypeConfig, uint8, error) {
+	if len(c.header) < 12 {
+		return nil, 0, fmt.Errorf("Resource type header too short")
+	}
+	id := c.header[0]
+	flags := c.header[1]
+	count := int(eb.LittleEndian.Uint32(c.header[4:]))
+	entriesStart := int(eb.LittleEndian.Uint32(c.header[8:])) - 8 - len(c.header)
+	config, err := decodeResourceConfig(c.header[12:])
+	if err != nil {
+		return nil, 0, err
+	}
+	if entriesStart < 0 || entriesStart > len(c.data) || count*4 > len(c.data) {
+		return nil, 0, fmt.Errorf("Invalid resource type entries")
+	}
+
+	out := &ResourceTypeConfig{Config: config, Entries: map[uint16]*ResourceEntry{}}
+	for i := 0; i < count; i++ {
+		index, offset := uint16(i), eb.LittleEndian.Uint32(c.data[i*4:])
+		if flags&resTableTypeSparse != 0 {
+			index, offset = uint16(offset), (offset>>16)*4
+		} else if offset == resTableNoEntry {
+			continue
+		}
+		e, err := t.decodeEntry(c.data[entriesStart:], int(offset), keys)
+		if err != nil {
+			return nil, 0, err
+		}
+		out.Entries[index] = e
+	}
+	return out, id, nil
+}
+
+func (t *ResourceTable) decodeEntry(data []byte, offset int, keys *stringPool) (*ResourceEntry, error) {
+	if offset < 0 || offset+8 > len(data) {
+		return nil, fmt.Errorf("Resource entry offset 0x%x is out of bounds", offset)
+	}
+	r := endian.Reader(bytes.NewReader(data[offset:]), device.LittleEndian)
+	r.Uint16() // size
+	flags := r.Uint16()
+	key := r.Uint32()
+	e := &ResourceEntry{}
+	if int(key) < len(keys.strings) {
+		e.Key = keys.strings[key]
+	}
+	if flags&resTableEntryComplex != 0 {
+		e.Parent = r.Uint32()
+		count := r.Uint32()
+		if err := r.Error(); err != nil {
+			return nil, err
+		}
+		// Each bag item is a name followed by a value.
+		if uint64(count)*bagItemSize > uint64(len(data)-offset-complexEntrySize) {
+			return nil, fmt.Errorf("Resource entry bag count %d exceeds the chunk size", count)
+		}
+		e.Bag = make(map[uint32]ResourceValue, count)
+		for i := uint32(0); i < count; i++ {
+			name := r.Uint32()
+			e.Bag[name] = t.decodeValue(r)
+		}
+	} else {
+		e.Value = t.decodeValue(r)
+	}
+	return e, r.Error()
+}
+
+func (t *ResourceTable) decodeValue(r binary.Reader) ResourceValue {
+	r.Uint16() // size
+	r.Uint8()  // res0
+	v := ResourceValue{Type: r.Uint8(), Data: r.Uint32()}
+	if valueType(v.Type) == typeString && int(v.Data) < len(t.strings.strings) {
+		v.str = t.strings.strings[v.Data]
+	}
+	return v
+}
+
+// Package returns the package with the given ID, or nil if there is none.
+func (t *ResourceTable) Package(id uint8) *ResourcePackage {
+	for _, p := range t.Packages {
+		if p.ID == id {
+			return p
+		}
+	}
+	return nil
+}
+
+// Type returns the resource type with the given ID, or nil if there is none.
+func (p *ResourcePackage) Type(id uint8) *ResourceType {
+	for _, t := range p.Types {
+		if t.ID == id {
+			return t
+		}
+	}
+	return nil
+}
+
+// Lookup returns the entries of the resource with the given ID for each of
+// the configurations that define it.
+func (t *ResourceTable) Lookup(id uint32) []ConfigEntry {
+	p := t.Package(uint8(id >> 24))
+	if p == nil {
+		return nil
+	}
+	ty := p.Type(uint8(id >> 16))
+	if ty == nil {
+		return nil
+	}
+	out := []ConfigEntry{}
+	for _, c := range ty.Configs {
+		if e, ok := c.Entries[uint16(id)]; ok {
+			out = append(out, ConfigEntry{c.Config, e})
+		}
+	}
+	return out
+}
+
+// Name returns the name of the resource with the given ID, in the form
+// "package:type/key".
+func (t *ResourceTable) Name(id uint32) (string, bool) {
+	entries := t.Lookup(id)
+	if len(entries) == 0 {
+		return "", false
+	}
+	p := t.Package(uint8(id >> 24))
+	ty := p.Type(uint8(id >> 16))
+	return fmt.Sprintf("%s:%s/%s", p.Name, ty.Name, entries[0].Entry.Key), true
+}
+
+// Resolve returns the value of the resource with the given ID for the default
+// configuration, following references to other resources. If there is no
+// default configuration the first one is used.
+func (t *ResourceTable) Resolve(id uint32) (ResourceValue, bool) {
+	for depth := 0; depth < maxReferenceDepth; depth++ {
+		entries := t.Lookup(id)
+		if len(entries) == 0 {
+			return ResourceValue{}, false
+		}
+		e := entries[0].Entry
+		for _, c := range entries {
+			if c.Config.IsDefault() {
+				e = c.Entry
+				break
+			}
+		}
+		ref, ok := e.Value.Reference()
+		if !ok {
+			return e.Value, e.Bag == nil
+		}
+		id = ref
+	}
+	return ResourceValue{}, false
+}
+
+// ResolveAttribute returns the attribute value s from a decoded XML file,
+// with references to resources such as "@0x7f040001" replaced by their value.
+// Values that are not references, or that cannot be resolved, are returned
+// unchanged.
+func (t *ResourceTable) ResolveAttribute(s string) string {
+	id, ok := ParseReference(s)
+	if !ok {
+		return s
+	}
+	if v, ok := t.Resolve(id); ok {
+		return v.String()
+	}
+	return s
+}
+
+// ParseReference returns the resource ID of a reference in a decoded XML
+// file, such as "@0x7f040001".
+func ParseReference(s string) (uint32, bool) {
+	if !strings.HasPrefix(s, "@0x") {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(s[1:], 0, 32)
+	return uint32(id), err == nil
+}
+
+// Reference returns the resource ID that the value refers to, if it is a
+// reference.
+func (v ResourceValue) Reference() (uint32, bool) {
+	return v.Data, valueType(v.Type) == typeReference && v.Data != 0
+}
+
+// String returns the value formatted as in a decoded XML file.
+func (v ResourceValue) String() string {
+	switch valueType(v.Type) {
+	case typeString:
+		return v.str
+	case typeReference:
+		return valReference(v.Data).String()
+	case typeFloat:
+		return valFloat(math.Float32frombits(v.Data)).String()
+	case typeDimension:
+		r := endian.Reader(bytes.NewReader([]byte{
+			byte(v.Data), byte(v.Data >> 8), byte(v.Data >> 16), byte(v.Data >> 24),
+		}), device.LittleEndian)
+		if d, err := decodeDimension(r); err == nil {
+			return d.String()
+		}
+	case typeIntDec:
+		return valIntDec(v.Data).String()
+	case typeIntBoolean:
+		return valIntBoolean(v.Data != 0).String()
+	case typeIntColorARGB8, typeIntColorRGB8, typeIntColorARGB4, typeIntColorRGB4:
+		return fmt.Sprintf("#%08x", v.Data)
+	case typeNull:
+		return ""
+	}
+	return valIntHex(v.Data).String()
+}
@@ -0,0 +1,332 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binaryxml
+
+import (
+	eb "encoding/binary"
+	"fmt"
+
+	"github.com/google/gapid/core/data/binary"
+)
+
+const (
+	// appPackageID is the ID of the application's resource package.
+	appPackageID = 0x7f
+	// defaultConfigSize is the size of the ResTable_config of new resource
+	// types.
+	defaultConfigSize = 64
+)
+
+// AddFileResource adds a resource of the type typeName, such as "xml", with
+// the given key to the compiled resource table data. The resource's value in
+// the default configuration is the path of a file in the APK, such as
+// "res/xml/config.xml". If the resource already exists its default value is
+// replaced. AddFileResource returns the modified resource table and the ID of
+// the resource.
+func AddFileResource(data []byte, typeName, key, path string) ([]byte, uint32, error) {
+	chunks, err := splitChunks(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(chunks) != 1 || chunks[0].ty != resTableType {
+		return nil, 0, fmt.Errorf("Expected a single resource table chunk")
+	}
+	table := chunks[0]
+	if chunks, err = splitChunks(table.data); err != nil {
+		return nil, 0, err
+	}
+
+	pool, pkg := -1, -1
+	for i, c := range chunks {
+		switch {
+		case c.ty == resStringPoolType && pool < 0:
+			pool = i
+		case c.ty == resTablePackageType && len(c.header) >= 4:
+			if pkg < 0 || eb.LittleEndian.Uint32(c.header) == appPackageID {
+				pkg = i
+			}
+		}
+	}
+	if pool < 0 || pkg < 0 {
+		return nil, 0, fmt.Errorf("Resource table has no string pool or package")
+	}
+
+	strings := &stringPool{}
+	if err := strings.decode(chunks[pool].header, chunks[pool].data); err != nil {
+		return nil, 0, err
+	}
+	value := strings.ref(path).stringPoolIndex()
+
+	encodedPkg, id, err := addFileResource(chunks[pkg], typeName, key, value)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	out := encodeChunk(resTableType, func(w binary.Writer) {
+		w.Data(table.header)
+	}, func(w binary.Writer) {
+		for i, c := range chunks {
+			switch i {
+			case pool:
+				w.Data(strings.encode())
+			case pkg:
+				w.Data(encodedPkg)
+			default:
+				w.Data(rawChunk(c))
+			}
+		}
+	})
+	return out, id, nil
+}
+
+// addFileResource adds the resource to the package chunk c, returning the
+// encoded package and the ID of the resource. value is the index of the
+// file path in the table's string pool.
+func addFileResource(c resChunk, typeName, key string, value uint32) ([]byte, uint32, error) {
+	if len(c.header) < 4+256+16 {
+		return nil, 0, fmt.Errorf("Resource package header too short")
+	}
+	header := append([]byte{}, c.header...)
+	pkgID := eb.LittleEndian.Uint32(header)
+	typeStrings := int(eb.LittleEndian.Uint32(header[260:]))
+	keyStrings := int(eb.LittleEndian.Uint32(header[268:]))
+
+	chunks, err := splitChunks(c.data)
+	if err != nil {
+		return nil, 0, err
+	}
+	dataOffset := 8 + len(c.header)
+	types, keys := &stringPool{}, &stringPool{}
+	typesIdx, keysIdx := -1, -1
+	for i, c := range chunks {
+		if c.ty != resStringPoolType {
+			continue
+		}
+		switch c.offset + dataOffset {
+		case typeStrings:
+			typesIdx, err = i, types.decode(c.header, c.data)
+		case keyStrings:
+			keysIdx, err = i, keys.decode(c.header, c.data)
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	if typesIdx < 0 || keysIdx < 0 {
+		return nil, 0, fmt.Errorf("Resource package has no type or key strings")
+	}
+
+	typeID := uint8(types.ref(typeName).stringPoolIndex() + 1)
+	keyIndex := keys.ref(key).stringPoolIndex()
+
+	// Find the type's spec and default configuration chunks.
+	spec, config := -1, -1
+	configSize := defaultConfigSize
+	for i, c := range chunks {
+		if len(c.header) < 1 {
+			continue
+		}
+		switch {
+		case c.ty == resTableTypeSpecType && c.header[0] == typeID:
+			spec = i
+		case c.ty == resTableTypeType && len(c.header) >= 16:
+			configSize = len(c.header) - 12
+			if c.header[0] == typeID && isDefaultConfig(c.header[12:]) {
+				config = i
+			}
+		}
+	}
+
+	var specs []byte
+	if spec >= 0 {
+		specs = chunks[spec].data
+	}
+	var index uint32
+	var typeChunk []byte
+	if config >= 0 {
+		index, typeChunk, err = addTypeEntry(chunks[config], uint32(len(specs)/4), keyIndex, value)
+		if err != nil {
+			return nil, 0, err
+		}
+	} else {
+		index = uint32(len(specs) / 4)
+		cfg := make([]byte, configSize)
+		eb.LittleEndian.PutUint32(cfg, uint32(configSize))
+		typeChunk = encodeTypeChunk(typeID, cfg, nil, nil)
+		typeChunk, err = appendTypeEntry(typeChunk, index, keyIndex, value)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	if index >= uint32(len(specs)/4) {
+		specs = append(append([]byte{}, specs...), make([]byte, 4*(index+1)-uint32(len(specs)))...)
+	}
+	specChunk := encodeChunk(resTableTypeSpecType, func(w binary.Writer) {
+		w.Uint8(typeID)
+		w.Uint8(0)  // res0
+		w.Uint16(0) // res1
+		w.Uint32(uint32(len(specs) / 4))
+	}, func(w binary.Writer) {
+		w.Data(specs)
+	})
+
+	// Lay out the package, keeping the order of the existing chunks and
+	// adding new chunks for the type at the end.
+	encoded := make([][]byte, len(chunks))
+	for i, c := range chunks {
+		switch i {
+		case typesIdx:
+			encoded[i] = types.encode()
+		case keysIdx:
+			encoded[i] = keys.encode()
+		case spec:
+			encoded[i] = specChunk
+		case config:
+			encoded[i] = typeChunk
+		default:
+			encoded[i] = rawChunk(c)
+		}
+	}
+	if spec < 0 {
+		encoded = append(encoded, specChunk)
+		// New types are public, like the types that aapt creates.
+		if lastPublicType := eb.LittleEndian.Uint32(header[264:]); uint32(typeID) > lastPublicType {
+			eb.LittleEndian.PutUint32(header[264:], uint32(typeID))
+		}
+	}
+	if config < 0 {
+		encoded = append(encoded, typeChunk)
+	}
+	offset := dataOffset
+	for i, e := range encoded {
+		switch i {
+		case typesIdx:
+			eb.LittleEndian.PutUint32(header[260:], uint32(offset))
+		case keysIdx:
+			eb.LittleEndian.PutUint32(header[268:], uint32(offset))
+		}
+		offset += len(e)
+	}
+
+	out := encodeChunk(resTablePackageType, func(w binary.Writer) {
+		w.Data(header)
+	}, func(w binary.Writer) {
+		for _, e := range encoded {
+			w.Data(e)
+		}
+	})
+	return out, pkgID<<24 | uint32(typeID)<<16 | index, nil
+}
+
+// addTypeEntry sets the value of the entry with the given key in the type
+// chunk c, adding a new entry at index specCount if there is none. It
+// returns the index of the entry and the encoded chunk.
+func addTypeEntry(c resChunk, specCount, key, value uint32) (uint32, []byte, error) {
+	if c.header[1]&resTableTypeSparse != 0 {
+		return 0, nil, fmt.Errorf("Sparse resource types are not supported")
+	}
+	count := int(eb.LittleEndian.Uint32(c.header[4:]))
+	entriesStart := int(eb.LittleEndian.Uint32(c.header[8:])) - 8 - len(c.header)
+	if entriesStart < count*4 || entriesStart > len(c.data) {
+		return 0, nil, fmt.Errorf("Invalid resource type entries")
+	}
+	offsets := append([]byte{}, c.data[:count*4]...)
+	entries := append([]byte{}, c.data[entriesStart:]...)
+
+	for i := 0; i < count; i++ {
+		offset := eb.LittleEndian.Uint32(offsets[i*4:])
+		if offset == resTableNoEntry || int(offset)+16 > len(entries) {
+			continue
+		}
+		flags := eb.LittleEndian.Uint16(entries[offset+2:])
+		if eb.LittleEndian.Uint32(entries[offset+4:]) == key && flags&resTableEntryComplex == 0 {
+			entries[offset+11] = uint8(typeString)
+			eb.LittleEndian.PutUint32(entries[offset+12:], value)
+			return uint32(i), encodeTypeChunk(c.header[0], c.header[12:], offsets, entries), nil
+		}
+	}
+
+	if specCount < uint32(count) {
+		specCount = uint32(count)
+	}
+	data, err := appendTypeEntry(encodeTypeChunk(c.header[0], c.header[12:], offsets, entries), specCount, key, value)
+	return specCount, data, err
+}
+
+// appendTypeEntry adds a string entry at the given index to the encoded type
+// chunk data, which must have no more than index entries.
+func appendTypeEntry(data []byte, index, key, value uint32) ([]byte, error) {
+	chunks, err := splitChunks(data)
+	if err != nil {
+		return nil, err
+	}
+	c := chunks[0]
+	count := eb.LittleEndian.Uint32(c.header[4:])
+	entriesStart := int(eb.LittleEndian.Uint32(c.header[8:])) - 8 - len(c.header)
+	offsets := append([]byte{}, c.data[:count*4]...)
+	entries := c.data[entriesStart:]
+	for ; count < index; count++ {
+		offsets = append(offsets, 0xff, 0xff, 0xff, 0xff)
+	}
+	offsets = append(offsets, make([]byte, 4)...)
+	eb.LittleEndian.PutUint32(offsets[index*4:], uint32(len(entries)))
+	entry := make([]byte, 16)
+	eb.LittleEndian.PutUint16(entry[0:], 8) // size
+	eb.LittleEndian.PutUint32(entry[4:], key)
+	eb.LittleEndian.PutUint16(entry[8:], valueSize)
+	entry[11] = uint8(typeString)
+	eb.LittleEndian.PutUint32(entry[12:], value)
+	return encodeTypeChunk(c.header[0], c.header[12:], offsets, append(append([]byte{}, entries...), entry...)), nil
+}
+
+// encodeTypeChunk returns a resource type chunk with the given entry offsets
+// and entries.
+func encodeTypeChunk(id uint8, config, offsets, entries []byte) []byte {
+	return encodeChunk(resTableTypeType, func(w binary.Writer) {
+		w.Uint8(id)
+		w.Uint8(0)  // flags
+		w.Uint16(0) // reserved
+		w.Uint32(uint32(len(offsets) / 4))
+		w.Uint32(uint32(8 + 12 + len(config) + len(offsets)))
+		w.Data(config)
+	}, func(w binary.Writer) {
+		w.Data(offsets)
+		w.Data(entries)
+	})
+}
+
+// isDefaultConfig returns true if the encoded ResTable_config matches any
+// configuration.
+func isDefaultConfig(config []byte) bool {
+	size := int(eb.LittleEndian.Uint32(config))
+	if size > len(config) {
+		size = len(config)
+	}
+	for _, b := range config[4:size] {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// rawChunk returns the encoding of the unmodified chunk c.
+func rawChunk(c resChunk) []byte {
+	return encodeChunk(c.ty, func(w binary.Writer) {
+		w.Data(c.header)
+	}, func(w binary.Writer) {
+		w.Data(c.data)
+	})
+}
@@ -0,0 +1,260 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binaryxml
+
+import (
+	eb "encoding/binary"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/data/binary"
+)
+
+// utf8StringPool returns an encoded UTF-8 string pool chunk.
+func utf8StringPool(strs ...string) []byte {
+	return encodeChunk(resStringPoolType, func(w binary.Writer) {
+		w.Uint32(uint32(len(strs)))
+		w.Uint32(0)
+		w.Uint32(1 << 8) // UTF-8
+		w.Uint32(uint32(28 + len(strs)*4))
+		w.Uint32(0)
+	}, func(w binary.Writer) {
+		offset := 0
+		for _, s := range strs {
+			w.Uint32(uint32(offset))
+			offset += len(s) + 3
+		}
+		for _, s := range strs {
+			w.Uint8(uint8(len(s)))
+			w.Uint8(uint8(len(s)))
+			w.Data([]byte(s))
+			w.Uint8(0)
+		}
+		for ; offset%4 != 0; offset++ {
+			w.Uint8(0)
+		}
+	})
+}
+
+type testEntry struct {
+	key  uint32
+	ty   valueType
+	data uint32
+}
+
+// typeChunk returns an encoded resource type chunk.
+func typeChunk(id uint8, language string, density uint16, entries ...testEntry) []byte {
+	const configSize = 36
+	return encodeChunk(resTableTypeType, func(w binary.Writer) {
+		w.Uint8(id)
+		w.Uint8(0)
+		w.Uint16(0)
+		w.Uint32(uint32(len(entries)))
+		w.Uint32(uint32(20 + configSize + len(entries)*4))
+		config := make([]byte, configSize)
+		config[0] = configSize
+		copy(config[8:], language)
+		config[14], config[15] = byte(density), byte(density>>8)
+		w.Data(config)
+	}, func(w binary.Writer) {
+		for i := range entries {
+			w.Uint32(uint32(i * 16))
+		}
+		for _, e := range entries {
+			w.Uint16(8)
+			w.Uint16(0)
+			w.Uint32(e.key)
+			w.Uint16(8)
+			w.Uint8(0)
+			w.Uint8(uint8(e.ty))
+			w.Uint32(e.data)
+		}
+	})
+}
+
+// testResourceTable returns an encoded resource table, with the extra chunks
+// appended to its package.
+func testResourceTable(extra ...[]byte) []byte {
+	typeStrings := (&stringPool{strings: []string{"string", "mipmap"}, ptrs: []int{0, 1}}).encode()
+	keyStrings := (&stringPool{strings: []string{"app_name", "label", "ic_launcher"}, ptrs: []int{0, 1, 2}}).encode()
+	const packageHeaderSize = 288
+	pkg := encodeChunk(resTablePackageType, func(w binary.Writer) {
+		w.Uint32(0x7f)
+		name := make([]uint16, 128)
+		for i, c := range "com.example" {
+			name[i] = uint16(c)
+		}
+		for _, c := range name {
+			w.Uint16(c)
+		}
+		w.Uint32(packageHeaderSize)
+		w.Uint32(2)
+		w.Uint32(uint32(packageHeaderSize + len(typeStrings)))
+		w.Uint32(3)
+		w.Uint32(0)
+	}, func(w binary.Writer) {
+		w.Data(typeStrings)
+		w.Data(keyStrings)
+		w.Data(encodeChunk(resTableTypeSpecType, func(w binary.Writer) {
+			w.Uint8(1)
+			w.Uint8(0)
+			w.Uint16(0)
+			w.Uint32(2)
+		}, func(w binary.Writer) {
+			w.Uint32(0)
+			w.Uint32(0x4) // Locale
+		}))
+		w.Data(typeChunk(1, "", 0,
+			testEntry{0, typeString, 0},
+			testEntry{1, typeReference, 0x7f010000}))
+		w.Data(typeChunk(1, "fr", 0, testEntry{0, typeString, 3}))
+		w.Data(typeChunk(2, "", DensityHigh, testEntry{2, typeString, 1}))
+		w.Data(typeChunk(2, "", DensityXHigh, testEntry{2, typeString, 2}))
+		for _, c := range extra {
+			w.Data(c)
+		}
+	})
+	return encodeChunk(resTableType, func(w binary.Writer) {
+		w.Uint32(1)
+	}, func(w binary.Writer) {
+		w.Data(utf8StringPool(
+			"My App",
+			"res/mipmap-hdpi-v4/ic_launcher.png",
+			"res/mipmap-xhdpi-v4/ic_launcher.png",
+			"Mon Appli"))
+		w.Data(pkg)
+	})
+}
+
+func TestResourceTable(t *testing.T) {
+	ctx := assert.Context(t)
+	table, err := decodeResourceTable(testResourceTable())
+	assert.With(ctx).ThatError(err).Succeeded()
+
+	assert.For(ctx, "packages").That(len(table.Packages)).Equals(1)
+	p := table.Packages[0]
+	assert.For(ctx, "package").That(p.Name).Equals("com.example")
+	assert.For(ctx, "types").That(len(p.Types)).Equals(2)
+	assert.For(ctx, "type name").That(p.Type(2).Name).Equals("mipmap")
+	assert.For(ctx, "specs").ThatSlice(p.Type(1).Specs).Equals([]uint32{0, 4})
+
+	name, ok := table.Name(0x7f010001)
+	assert.For(ctx, "name ok").That(ok).Equals(true)
+	assert.For(ctx, "name").That(name).Equals("com.example:string/label")
+
+	assert.For(ctx, "label").That(table.ResolveAttribute("@0x7f010001")).Equals("My App")
+	assert.For(ctx, "literal").That(table.ResolveAttribute("Literal")).Equals("Literal")
+	assert.For(ctx, "missing").That(table.ResolveAttribute("@0x7f010009")).Equals("@0x7f010009")
+
+	strings := table.Lookup(0x7f010000)
+	assert.For(ctx, "string configs").That(len(strings)).Equals(2)
+	assert.For(ctx, "french").That(strings[1].Config.String()).Equals("fr")
+	assert.For(ctx, "french value").That(strings[1].Entry.Value.String()).Equals("Mon Appli")
+
+	icons := table.Lookup(0x7f020000)
+	assert.For(ctx, "icon configs").That(len(icons)).Equals(2)
+	assert.For(ctx, "hdpi").That(icons[0].Config.DensityName()).Equals("hdpi")
+	assert.For(ctx, "hdpi path").That(icons[0].Entry.Value.String()).Equals("res/mipmap-hdpi-v4/ic_launcher.png")
+	assert.For(ctx, "xhdpi").That(icons[1].Config.String()).Equals("xhdpi")
+}
+
+// lastPublicType returns the lastPublicType field of the header of the
+// package in the encoded resource table.
+func lastPublicType(ctx assert.Manager, data []byte) uint32 {
+	chunks, err := splitChunks(data)
+	assert.With(ctx).ThatError(err).Succeeded()
+	chunks, err = splitChunks(chunks[0].data)
+	assert.With(ctx).ThatError(err).Succeeded()
+	for _, c := range chunks {
+		if c.ty == resTablePackageType {
+			return eb.LittleEndian.Uint32(c.header[264:])
+		}
+	}
+	return 0
+}
+
+func TestResourceTableCorruptCounts(t *testing.T) {
+	ctx := assert.Context(t)
+	spec := encodeChunk(resTableTypeSpecType, func(w binary.Writer) {
+		w.Uint8(3)
+		w.Uint8(0)
+		w.Uint16(0)
+		w.Uint32(0xffffffff)
+	}, func(w binary.Writer) {
+		w.Uint32(0)
+	})
+	_, err := decodeResourceTable(testResourceTable(spec))
+	assert.For(ctx, "spec count").ThatError(err).HasMessage("Resource type spec count 4294967295 exceeds the chunk size")
+
+	const configSize = 36
+	bag := encodeChunk(resTableTypeType, func(w binary.Writer) {
+		w.Uint8(3)
+		w.Uint8(0)
+		w.Uint16(0)
+		w.Uint32(1)
+		w.Uint32(20 + configSize + 4)
+		config := make([]byte, configSize)
+		config[0] = configSize
+		w.Data(config)
+	}, func(w binary.Writer) {
+		w.Uint32(0)
+		w.Uint16(16)
+		w.Uint16(resTableEntryComplex)
+		w.Uint32(0)
+		w.Uint32(0)          // parent
+		w.Uint32(0xffffffff) // count
+	})
+	_, err = decodeResourceTable(testResourceTable(bag))
+	assert.For(ctx, "bag count").ThatError(err).HasMessage("Resource entry bag count 4294967295 exceeds the chunk size")
+}
+
+func TestUnpackLocale(t *testing.T) {
+	ctx := assert.Context(t)
+	assert.For(ctx, "two letters").That(unpackLocale('e', 'n', 'a')).Equals("en")
+	// "fil" packed as in ResourceTypes.cpp.
+	assert.For(ctx, "three letters").That(unpackLocale(0xad, 0x05, 'a')).Equals("fil")
+	assert.For(ctx, "empty").That(unpackLocale(0, 0, 'a')).Equals("")
+}
+
+func TestAddFileResource(t *testing.T) {
+	ctx := assert.Context(t)
+	const path = "res/xml/config.xml"
+	data, id, err := AddFileResource(testResourceTable(), "xml", "config", path)
+	assert.With(ctx).ThatError(err).Succeeded()
+	assert.For(ctx, "id").That(id).Equals(uint32(0x7f030000))
+
+	table, err := decodeResourceTable(data)
+	assert.With(ctx).ThatError(err).Succeeded()
+	name, _ := table.Name(id)
+	assert.For(ctx, "name").That(name).Equals("com.example:xml/config")
+	assert.For(ctx, "path").That(table.ResolveAttribute("@0x7f030000")).Equals(path)
+	assert.For(ctx, "label").That(table.ResolveAttribute("@0x7f010001")).Equals("My App")
+	assert.For(ctx, "last public type").That(lastPublicType(ctx, data)).Equals(uint32(3))
+
+	// Adding to an existing type appends an entry.
+	data, id, err = AddFileResource(data, "string", "other", path)
+	assert.With(ctx).ThatError(err).Succeeded()
+	assert.For(ctx, "appended id").That(id).Equals(uint32(0x7f010002))
+
+	// Adding an existing resource replaces its value.
+	data, id, err = AddFileResource(data, "xml", "config", "res/xml/other.xml")
+	assert.With(ctx).ThatError(err).Succeeded()
+	assert.For(ctx, "existing id").That(id).Equals(uint32(0x7f030000))
+	table, err = decodeResourceTable(data)
+	assert.With(ctx).ThatError(err).Succeeded()
+	assert.For(ctx, "replaced path").That(table.ResolveAttribute("@0x7f030000")).Equals("res/xml/other.xml")
+	assert.For(ctx, "specs").ThatSlice(table.Package(0x7f).Type(1).Specs).Equals([]uint32{0, 4, 0})
+	assert.For(ctx, "appended path").That(table.ResolveAttribute("@0x7f010002")).Equals(path)
+}
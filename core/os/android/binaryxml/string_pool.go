@@ -61,15 +61,21 @@ func (r stringPoolRef) get() string {
 type stringPool struct {
 	rootHolder
 	strings []string
-	styles  []string
 	flags   uint32
 	ptrs    []int // ptrs maps indices in stringPoolRefs to indices in the raw strings array.
+	// The style span offsets and data are kept verbatim. Styles apply to the
+	// first strings of the pool, so strings must only be appended to pools
+	// with styles.
+	styleIndices []uint32
+	styleData    []byte
 }
 
-func (c *stringPool) decode(header, data []byte) error {
-	const sortedFlag = 1 << 0
-	const utf8Flag = 1 << 8
+const (
+	sortedFlag = 1 << 0
+	utf8Flag   = 1 << 8
+)
 
+func (c *stringPool) decode(header, data []byte) error {
 	// dataOffset is the offset of data relative to the start of the chunk.
 	dataOffset := 8 + uint32(len(header))
 
@@ -85,15 +91,27 @@ func (c *stringPool) decode(header, data []byte) error {
 	for i := range indices {
 		indices[i] = r.Uint32()
 	}
+	c.styleIndices = make([]uint32, styleCount)
+	for i := range c.styleIndices {
+		c.styleIndices[i] = r.Uint32()
+	}
+	if err := r.Error(); err != nil {
+		return err
+	}
 
 	c.ptrs = make([]int, stringCount)
 	c.strings = make([]string, stringCount)
-	c.styles = make([]string, styleCount)
 	for i := range c.strings {
 		offset := stringsStart + indices[i]
+		if offset >= uint32(len(data)) {
+			return fmt.Errorf("String %d offset 0x%x is out of bounds", i, offset)
+		}
 		r = endian.Reader(bytes.NewReader(data[offset:]), device.LittleEndian)
 		if c.flags&utf8Flag != 0 {
-			panic("TODO: UTF8 encoded xml support.")
+			decodeLength8(r) // The length in UTF-16 code units.
+			str := make([]byte, decodeLength8(r))
+			r.Data(str)
+			c.strings[i] = string(str)
 		} else {
 			runeCount := decodeLength(r)
 			str := make([]uint16, runeCount)
@@ -101,12 +119,17 @@ func (c *stringPool) decode(header, data []byte) error {
 				str[i] = r.Uint16()
 			}
 			c.strings[i] = string(utf16.Decode(str))
-			c.ptrs[i] = i
+		}
+		c.ptrs[i] = i
+		if err := r.Error(); err != nil {
+			return err
 		}
 	}
 	if styleCount > 0 {
-		_ = stylesStart
-		panic("TODO: Style decoding")
+		if stylesStart > uint32(len(data)) {
+			return fmt.Errorf("Styles offset 0x%x is out of bounds", stylesStart)
+		}
+		c.styleData = data[stylesStart:]
 	}
 
 	return nil
@@ -126,36 +149,51 @@ func utf16EncodeStringPoolEntry(str string) []byte {
 	return b.Bytes()
 }
 
+func utf8EncodeStringPoolEntry(str string) []byte {
+	var b bytes.Buffer
+	w := endian.Writer(&b, device.LittleEndian)
+	encodeLength8(w, uint32(len(utf16.Encode([]rune(str)))))
+	encodeLength8(w, uint32(len(str)))
+	w.Data([]byte(str))
+	w.Uint8(0)
+	return b.Bytes()
+}
+
 func (c *stringPool) encode() []byte {
-	if len(c.styles) > 0 {
-		panic("TODO: implement style encoding support.")
+	encodedStrings := make([][]byte, len(c.strings))
+	stringsSize := 0
+	for i, str := range c.strings {
+		if c.flags&utf8Flag != 0 {
+			encodedStrings[i] = utf8EncodeStringPoolEntry(str)
+		} else {
+			encodedStrings[i] = utf16EncodeStringPoolEntry(str)
+		}
+		stringsSize += len(encodedStrings[i])
 	}
+	// The strings are padded to a multiple of 4 bytes.
+	padding := (4 - stringsSize%4) % 4
 
 	return encodeChunk(resStringPoolType, func(w binary.Writer) {
 		totalHeaderLength := 8 + 5*4 // 8 for the basic header + the five uint32s below
+		stringsStart := totalHeaderLength + (len(c.strings)+len(c.styleIndices))*4
 		w.Uint32(uint32(len(c.strings)))
-		w.Uint32(uint32(len(c.styles)))
+		w.Uint32(uint32(len(c.styleIndices)))
 		w.Uint32(c.flags)
-		w.Uint32(uint32(totalHeaderLength + len(c.strings)*4)) // strings start after header and indices
-		w.Uint32(0)                                            // stylesStart
-	}, func(w binary.Writer) {
-		encodedStrings := make([][]byte, len(c.strings))
-		for i, str := range c.strings {
-			encodedStrings[i] = utf16EncodeStringPoolEntry(str)
+		w.Uint32(uint32(stringsStart)) // strings start after header and indices
+		if len(c.styleIndices) > 0 {
+			w.Uint32(uint32(stringsStart + stringsSize + padding))
+		} else {
+			w.Uint32(0) // stylesStart
 		}
-
+	}, func(w binary.Writer) {
 		// encode indices
 		index := 0
 		for _, es := range encodedStrings {
 			w.Uint32(uint32(index))
 			index += len(es)
 		}
-		// compute padding (copied logic in bin_xml.py)
-		padding := index % 4
-		if padding == 3 {
-			padding = 1
-		} else if padding == 1 {
-			padding = 3
+		for _, i := range c.styleIndices {
+			w.Uint32(i)
 		}
 
 		// encode actual strings
@@ -166,6 +204,7 @@ func (c *stringPool) encode() []byte {
 		for p := 0; p < padding; p++ {
 			w.Uint8(0)
 		}
+		w.Data(c.styleData)
 	})
 }
 
@@ -194,13 +233,24 @@ func (p *stringPool) ref(str string) stringPoolRef {
 	if found {
 		return ref
 	}
-	return p.insertStringAtIndex(str, len(p.strings))
+	return p.appendString(str)
+}
+
+// appendString adds a string to the end of the pool.
+func (p *stringPool) appendString(str string) stringPoolRef {
+	ref, _ := p.insertStringAtIndex(str, len(p.strings))
+	return ref
 }
 
 // insertStringAtIndex inserts a string at a given index in the pool and then
 // updates the ptrs array, so that existing pool references continue to work.
 // This index is the final position of the string in the encoded string pool.
-func (p *stringPool) insertStringAtIndex(str string, index int) stringPoolRef {
+// Strings can only be appended to pools with styles.
+func (p *stringPool) insertStringAtIndex(str string, index int) (stringPoolRef, error) {
+	if len(p.styleIndices) > 0 && index != len(p.strings) {
+		return invalidStringPoolRef, fmt.Errorf("Cannot insert string %q into the middle of a string pool with styles", str)
+	}
+	p.flags &^= sortedFlag
 	p.strings = append(p.strings[0:index], append([]string{str}, p.strings[index:]...)...)
 	for i, ptr := range p.ptrs {
 		if ptr >= index && ptr != missingString {
@@ -208,5 +258,5 @@ func (p *stringPool) insertStringAtIndex(str string, index int) stringPoolRef {
 		}
 	}
 	p.ptrs = append(p.ptrs, index)
-	return stringPoolRef{p, uint32(len(p.ptrs) - 1)}
+	return stringPoolRef{p, uint32(len(p.ptrs) - 1)}, nil
 }
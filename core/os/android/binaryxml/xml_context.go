@@ -35,7 +35,13 @@ func (s *stack) push(c chunk) {
 func (s *stack) pop() {
 	*s = (*s)[:len(*s)-1]
 }
+
+// head returns the chunk at the top of the stack, or nil if it is empty, as it
+// is for the root element of a document without namespaces.
 func (s *stack) head() chunk {
+	if len(*s) == 0 {
+		return nil
+	}
 	return (*s)[len(*s)-1]
 }
 
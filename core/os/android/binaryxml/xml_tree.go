@@ -127,21 +127,37 @@ func (c *xmlTree) decodeString(r binary.Reader) stringPoolRef {
 // string associated with a resource id, shifting all the strings after it. The
 // resource map is updated to associate this string's position in the pool with
 // the given resource id.
-func (xml *xmlTree) ensureAttributeNameMapsToResource(resourceId uint32, attrName string) stringPoolRef {
+func (xml *xmlTree) ensureAttributeNameMapsToResource(resourceId uint32, attrName string) (stringPoolRef, error) {
 	attrIdx, foundAttr := xml.resourceMap.indexOf(resourceId)
 	if foundAttr {
 		poolRef, found := xml.strings.findFromStringPoolIndex(attrIdx)
-		if found {
-			if poolRef.get() != attrName {
-				panic("Attribute found but name mismatch. Perhaps this is allowed?")
-			}
-			return poolRef
-		} else {
-			panic("Resource map or string pool broken.")
+		if !found {
+			return invalidStringPoolRef, fmt.Errorf("Resource map or string pool broken.")
 		}
+		if poolRef.get() != attrName {
+			return invalidStringPoolRef, fmt.Errorf("Attribute 0x%x found with name %q, expected %q",
+				resourceId, poolRef.get(), attrName)
+		}
+		return poolRef, nil
 	}
 
 	insertIndex := len(xml.resourceMap.ids)
+	ref, err := xml.strings.insertStringAtIndex(attrName, insertIndex)
+	if err != nil {
+		return invalidStringPoolRef, err
+	}
 	xml.resourceMap.ids = append(xml.resourceMap.ids, resourceId)
-	return xml.strings.insertStringAtIndex(attrName, insertIndex)
+	return ref, nil
+}
+
+// attributeName finds a name for an attribute that does not map to a
+// resource. If such a name does not exist, it is added to the end of the
+// string pool.
+func (xml *xmlTree) attributeName(name string) stringPoolRef {
+	for i, ptr := range xml.strings.ptrs {
+		if ptr >= len(xml.resourceMap.ids) && xml.strings.strings[ptr] == name {
+			return stringPoolRef{xml.strings, uint32(i)}
+		}
+	}
+	return xml.strings.appendString(name)
 }
@@ -86,6 +86,8 @@ func TestParseManifest(_t *testing.T) {
 					Name: "BobsGameTvActivity",
 				},
 			},
+			Label: "@string/app_name",
+			Icon:  "@drawable/ic_launcher",
 		},
 		Features: []manifest.Feature{
 			{
@@ -44,4 +44,5 @@ set(dirs
     bind
     deviceinfo
     host
+    remote
 )
@@ -36,6 +36,7 @@ void glDriver(device::OpenGLDriver* driver) {
     GLint uniformbufferalignment = 1;
     GLint maxtransformfeedbackseparateattribs = 0;
     GLint maxtransformfeedbackinterleavedcomponents = 0;
+    GLint maxvertexattribs = 0;
 
     auto glGetIntegerv = reinterpret_cast<PFNGLGETINTEGERV>(core::GetGlesProcAddress("glGetIntegerv", true));
     auto glGetError = reinterpret_cast<PFNGLGETERROR>(core::GetGlesProcAddress("glGetError", true));
@@ -49,6 +50,7 @@ void glDriver(device::OpenGLDriver* driver) {
         glGetIntegerv(GL_UNIFORM_BUFFER_OFFSET_ALIGNMENT, &uniformbufferalignment);
         glGetIntegerv(GL_MAX_TRANSFORM_FEEDBACK_SEPARATE_ATTRIBS, &maxtransformfeedbackseparateattribs);
         glGetIntegerv(GL_MAX_TRANSFORM_FEEDBACK_INTERLEAVED_COMPONENTS, &maxtransformfeedbackinterleavedcomponents);
+        glGetIntegerv(GL_MAX_VERTEX_ATTRIBS, &maxvertexattribs);
 
         glGetError();  // Clear error state.
         glGetIntegerv(GL_MAJOR_VERSION, &major_version);
@@ -87,6 +89,7 @@ void glDriver(device::OpenGLDriver* driver) {
     driver->set_uniformbufferalignment(uniformbufferalignment);
     driver->set_maxtransformfeedbackseparateattribs(maxtransformfeedbackseparateattribs);
     driver->set_maxtransformfeedbackinterleavedcomponents(maxtransformfeedbackinterleavedcomponents);
+    driver->set_maxvertexattribs(maxvertexattribs);
 }
 
 }  // namespace query
\ No newline at end of file
@@ -34,6 +34,7 @@ const GLint GL_MAJOR_VERSION  = 0x821B;
 const GLint GL_NUM_EXTENSIONS = 0x821D;
 const GLint GL_MAX_TRANSFORM_FEEDBACK_INTERLEAVED_COMPONENTS = 0x8C8A;
 const GLint GL_MAX_TRANSFORM_FEEDBACK_SEPARATE_ATTRIBS       = 0x8C8B;
+const GLint GL_MAX_VERTEX_ATTRIBS                            = 0x8869;
 
 typedef void (*PFNGLGETINTEGERV)(GLenum param, GLint* values);
 typedef GLenum (*PFNGLGETERROR)();
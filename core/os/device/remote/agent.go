@@ -0,0 +1,184 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/google/gapid/core/app/auth"
+	"github.com/google/gapid/core/app/layout"
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/net/grpcutil"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/core/os/device/host"
+	"github.com/google/gapid/core/os/process"
+	"github.com/google/gapid/core/os/shell"
+	"github.com/google/gapid/core/text"
+	"github.com/google/gapid/core/vulkan/loader"
+	xctx "golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// Serve runs the agent on the given listener until ctx is stopped.
+// Connecting clients must present authToken, which may only be auth.NoAuth if
+// l is listening on a loopback address.
+func Serve(ctx context.Context, l net.Listener, authToken auth.Token) error {
+	host, _, err := net.SplitHostPort(l.Addr().String())
+	if err != nil {
+		return err
+	}
+	if authToken == auth.NoAuth && !isLoopback(host) {
+		return log.Errf(ctx, nil, "Refusing to serve on %v without an auth token", l.Addr())
+	}
+	a := &agent{ctx: ctx, host: host, gapirs: map[uint32]*gapirInstance{}}
+	defer a.stopAll()
+	return grpcutil.ServeWithListener(ctx, l, func(ctx context.Context, listener net.Listener, server *grpc.Server) error {
+		if addr, ok := listener.Addr().(*net.TCPAddr); ok {
+			// The following message is parsed by launchers to detect the selected port. DO NOT CHANGE!
+			fmt.Printf("Bound on port '%d'\n", addr.Port)
+		}
+		RegisterAgentServer(server, a)
+		go func() {
+			<-task.ShouldStop(ctx)
+			server.GracefulStop()
+		}()
+		return nil
+	}, grpc.UnaryInterceptor(auth.ServerInterceptor(authToken)))
+}
+
+// isLoopback returns true if host is a loopback IP address.
+func isLoopback(host string) bool {
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// gapirFlags are the gapir command line flags clients may pass to StartGapir,
+// mapped to whether the flag takes a value. Flags that access the agent's
+// file system or network, such as --cache, --log and --port, are not allowed.
+var gapirFlags = map[string]bool{
+	"--auth-token":      true,
+	"--idle-timeout-ms": true,
+	"--log-level":       true,
+}
+
+// checkGapirArgs returns an error if args contains a flag that is not in
+// gapirFlags.
+func checkGapirArgs(args []string) error {
+	for i := 0; i < len(args); i++ {
+		hasValue, ok := gapirFlags[args[i]]
+		if !ok {
+			return fmt.Errorf("gapir argument %q is not allowed", args[i])
+		}
+		if hasValue {
+			if i++; i == len(args) {
+				return fmt.Errorf("gapir argument %q requires a value", args[i-1])
+			}
+		}
+	}
+	return nil
+}
+
+type agent struct {
+	ctx    context.Context
+	host   string
+	mutex  sync.Mutex
+	nextID uint32
+	gapirs map[uint32]*gapirInstance
+}
+
+type gapirInstance struct {
+	stop    task.CancelFunc
+	forward *forwarder
+}
+
+func (a *agent) Instance(ctx xctx.Context, req *InstanceRequest) (*device.Instance, error) {
+	return host.Instance(a.ctx), nil
+}
+
+func (a *agent) StartGapir(ctx xctx.Context, req *StartGapirRequest) (*StartGapirResponse, error) {
+	if err := checkGapirArgs(req.Args); err != nil {
+		return nil, log.Err(a.ctx, err, "Invalid gapir arguments")
+	}
+
+	gapir, err := layout.Gapir(a.ctx)
+	if err != nil {
+		return nil, log.Err(a.ctx, err, "Couldn't locate gapir executable")
+	}
+
+	env := shell.CloneEnv()
+	if _, err := loader.SetupReplay(a.ctx, env); err != nil {
+		return nil, err
+	}
+
+	// gapir must outlive the RPC, so it is bound to the agent's context.
+	gapirCtx, stop := task.WithCancel(log.PutProcess(a.ctx, "gapir"))
+	stdout := text.Writer(func(line string) error { log.I(gapirCtx, "%s", line); return nil })
+	stderr := text.Writer(func(line string) error { log.E(gapirCtx, "%s", line); return nil })
+
+	log.I(a.ctx, "Starting gapir: %v %v", gapir.System(), req.Args)
+	port, err := process.Start(gapirCtx, gapir.System(), process.StartOptions{
+		Env:    env,
+		Args:   req.Args,
+		Stdout: stdout,
+		Stderr: stderr,
+	})
+	if err != nil {
+		stop()
+		return nil, log.Err(a.ctx, err, "Starting gapir")
+	}
+
+	// gapir is reached through the same interface as the agent.
+	f, err := forward(a.ctx, net.JoinHostPort(a.host, "0"), fmt.Sprintf("localhost:%d", port))
+	if err != nil {
+		stop()
+		return nil, log.Err(a.ctx, err, "Forwarding gapir port")
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.nextID++
+	id := a.nextID
+	a.gapirs[id] = &gapirInstance{stop: stop, forward: f}
+	log.I(a.ctx, "Started gapir %d. Port %d forwarded to %d", id, f.port(), port)
+	return &StartGapirResponse{Id: id, Port: int32(f.port())}, nil
+}
+
+func (a *agent) StopGapir(ctx xctx.Context, req *StopGapirRequest) (*StopGapirResponse, error) {
+	a.mutex.Lock()
+	g, ok := a.gapirs[req.Id]
+	delete(a.gapirs, req.Id)
+	a.mutex.Unlock()
+	if !ok {
+		return nil, log.Errf(a.ctx, nil, "Unknown gapir instance %d", req.Id)
+	}
+	log.I(a.ctx, "Stopping gapir %d", req.Id)
+	g.forward.close()
+	g.stop()
+	return &StopGapirResponse{}, nil
+}
+
+func (a *agent) stopAll() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for id, g := range a.gapirs {
+		g.forward.close()
+		g.stop()
+		delete(a.gapirs, id)
+	}
+}
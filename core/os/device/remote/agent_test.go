@@ -0,0 +1,57 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/gapid/core/app/auth"
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+)
+
+func TestCheckGapirArgs(t *testing.T) {
+	assert := assert.To(t)
+	for _, test := range []struct {
+		args  []string
+		valid bool
+	}{
+		{nil, true},
+		{[]string{"--idle-timeout-ms", "1000", "--auth-token", "abc", "--log-level", "V"}, true},
+		{[]string{"--auth-token"}, false},
+		{[]string{"--cache", "/tmp/cache"}, false},
+		{[]string{"--log", "/etc/passwd"}, false},
+		{[]string{"--port", "1234"}, false},
+		{[]string{"--wait-for-debugger"}, false},
+		{[]string{"--log-level", "V", "extra"}, false},
+	} {
+		err := checkGapirArgs(test.args)
+		if test.valid {
+			assert.For("%v", test.args).ThatError(err).Succeeded()
+		} else {
+			assert.For("%v", test.args).ThatError(err).Failed()
+		}
+	}
+}
+
+func TestServeRequiresAuthToken(t *testing.T) {
+	ctx := log.Testing(t)
+	l, err := net.Listen("tcp", "0.0.0.0:0")
+	assert.For(ctx, "listen").ThatError(err).Succeeded()
+	defer l.Close()
+	err = Serve(ctx, l, auth.NoAuth)
+	assert.For(ctx, "serve").ThatError(err).Failed()
+}
@@ -0,0 +1,154 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/google/gapid/core/app/auth"
+	"github.com/google/gapid/core/context/keys"
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/net/grpcutil"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/core/os/device/bind"
+	"google.golang.org/grpc"
+)
+
+// Device extends the bind.Device interface with the features of a remote host
+// reached through an agent.
+type Device interface {
+	bind.Device
+	// Addr returns the TCP host:port of the device's agent.
+	Addr() string
+	// StartGapir launches gapir on the remote host with the given command line
+	// arguments. It returns the TCP host:port that is forwarded to the new
+	// gapir, and a function that stops it.
+	StartGapir(ctx context.Context, args []string) (addr string, stop func(), err error)
+}
+
+// binding represents a remote host connected through an agent.
+type binding struct {
+	bind.Simple
+	addr   string
+	conn   *grpc.ClientConn
+	client AgentClient
+}
+
+// verify that binding implements Device
+var _ Device = (*binding)(nil)
+
+// Connect connects to the agent listening on the TCP host:port addr, returning
+// the remote host as a Device.
+func Connect(ctx context.Context, addr string, authToken auth.Token) (Device, error) {
+	return connect(ctx, addr, authToken)
+}
+
+func connect(ctx context.Context, addr string, authToken auth.Token) (*binding, error) {
+	conn, err := grpcutil.Dial(ctx, addr,
+		grpc.WithInsecure(),
+		grpc.WithUnaryInterceptor(auth.ClientInterceptor(authToken)))
+	if err != nil {
+		return nil, log.Err(ctx, err, "Dialing agent")
+	}
+	d := &binding{addr: addr, conn: conn, client: NewAgentClient(conn)}
+	inst, err := d.instance(ctx)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	d.To = inst
+	d.LastStatus = bind.Status_Online
+	return d, nil
+}
+
+func (b *binding) instance(ctx context.Context) (*device.Instance, error) {
+	inst, err := b.client.Instance(ctx, &InstanceRequest{})
+	if err != nil {
+		return nil, log.Err(ctx, err, "Querying agent device instance")
+	}
+	return inst, nil
+}
+
+// Addr returns the TCP host:port of the device's agent.
+func (b *binding) Addr() string { return b.addr }
+
+// StartGapir launches gapir on the remote host.
+func (b *binding) StartGapir(ctx context.Context, args []string) (string, func(), error) {
+	res, err := b.client.StartGapir(ctx, &StartGapirRequest{Args: args})
+	if err != nil {
+		return "", nil, log.Err(ctx, err, "Starting remote gapir")
+	}
+	host, _, err := net.SplitHostPort(b.addr)
+	if err != nil {
+		return "", nil, err
+	}
+	stop := func() {
+		// The context of the start request may have been cancelled by the time
+		// gapir is stopped.
+		ctx := keys.Clone(context.Background(), ctx)
+		if _, err := b.client.StopGapir(ctx, &StopGapirRequest{Id: res.Id}); err != nil {
+			log.W(ctx, "Couldn't stop remote gapir. Error: %v", err)
+		}
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(res.Port))), stop, nil
+}
+
+func (b *binding) String() string {
+	return b.Simple.String() + " (" + b.addr + ")"
+}
+
+// Monitor updates the registry r with the remote hosts of the agents at each
+// of addrs, checking every interval until ctx is stopped. Agents are added to
+// r once they can be reached, and removed when they stop responding.
+func Monitor(ctx context.Context, r *bind.Registry, addrs []string, authToken auth.Token, interval time.Duration) error {
+	devices := map[string]*binding{}
+	defer func() {
+		for _, d := range devices {
+			r.RemoveDevice(ctx, d)
+			d.conn.Close()
+		}
+	}()
+
+	for {
+		for _, addr := range addrs {
+			ctx := log.V{"agent": addr}.Bind(ctx)
+			if d, ok := devices[addr]; ok {
+				if _, err := d.instance(ctx); err != nil {
+					log.W(ctx, "Lost connection to remote device %v. Error: %v", d, err)
+					r.RemoveDevice(ctx, d)
+					d.conn.Close()
+					delete(devices, addr)
+				}
+				continue
+			}
+			d, err := connect(ctx, addr, authToken)
+			if err != nil {
+				continue // Not yet reachable.
+			}
+			log.I(ctx, "Connected to remote device %v", d)
+			devices[addr] = d
+			r.AddDevice(ctx, d)
+		}
+		select {
+		case <-task.ShouldStop(ctx):
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
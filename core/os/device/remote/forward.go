@@ -0,0 +1,111 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/google/gapid/core/log"
+)
+
+// forwarder accepts TCP connections and relays each of them to a target
+// address.
+type forwarder struct {
+	listener net.Listener
+	target   string
+	mutex    sync.Mutex
+	conns    map[net.Conn]struct{}
+}
+
+// forward starts listening on the TCP host:port addr, relaying all accepted
+// connections to the TCP host:port target.
+func forward(ctx context.Context, addr, target string) (*forwarder, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	f := &forwarder{listener: l, target: target, conns: map[net.Conn]struct{}{}}
+	go f.serve(ctx)
+	return f, nil
+}
+
+// port returns the TCP port the forwarder is listening on.
+func (f *forwarder) port() int {
+	return f.listener.Addr().(*net.TCPAddr).Port
+}
+
+// close stops listening and closes all the relayed connections.
+func (f *forwarder) close() {
+	f.listener.Close()
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for c := range f.conns {
+		c.Close()
+	}
+	f.conns = nil
+}
+
+func (f *forwarder) serve(ctx context.Context) {
+	for {
+		src, err := f.listener.Accept()
+		if err != nil {
+			return // Listener closed.
+		}
+		dst, err := net.Dial("tcp", f.target)
+		if err != nil {
+			log.W(ctx, "Couldn't connect to %v. Error: %v", f.target, err)
+			src.Close()
+			continue
+		}
+		if !f.track(src, dst) {
+			return // Forwarder closed.
+		}
+		go f.relay(src, dst)
+		go f.relay(dst, src)
+	}
+}
+
+// track adds the connections to the set closed by close, returning false if
+// the forwarder has already been closed.
+func (f *forwarder) track(conns ...net.Conn) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.conns == nil {
+		for _, c := range conns {
+			c.Close()
+		}
+		return false
+	}
+	for _, c := range conns {
+		f.conns[c] = struct{}{}
+	}
+	return true
+}
+
+// relay copies from src to dst until either fails, then closes both.
+func (f *forwarder) relay(dst, src net.Conn) {
+	io.Copy(dst, src)
+	dst.Close()
+	src.Close()
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.conns != nil {
+		delete(f.conns, dst)
+		delete(f.conns, src)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bufio"
+	"net"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+)
+
+func TestForward(t *testing.T) {
+	ctx := log.Testing(t)
+	assert := assert.To(t)
+
+	// Start an echo server to forward to.
+	target, err := net.Listen("tcp", "localhost:0")
+	assert.For("listen").ThatError(err).Succeeded()
+	defer target.Close()
+	go func() {
+		for {
+			c, err := target.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				r := bufio.NewReader(c)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					c.Write([]byte(line))
+				}
+			}()
+		}
+	}()
+
+	f, err := forward(ctx, "localhost:0", target.Addr().String())
+	assert.For("forward").ThatError(err).Succeeded()
+
+	conn, err := net.Dial("tcp", f.listener.Addr().String())
+	assert.For("dial").ThatError(err).Succeeded()
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	for _, msg := range []string{"hello\n", "world\n"} {
+		conn.Write([]byte(msg))
+		got, err := r.ReadString('\n')
+		assert.For("read").ThatError(err).Succeeded()
+		assert.For("echo").ThatString(got).Equals(msg)
+	}
+
+	f.close()
+	_, err = r.ReadString('\n')
+	assert.For("read after close").ThatError(err).Failed()
+}
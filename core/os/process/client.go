@@ -110,8 +110,16 @@ func Start(ctx context.Context, name string, opts StartOptions) (int, error) {
 	}
 }
 
+// Connect connects to the process listening on the local TCP port, sending
+// the authToken.
 func Connect(port int, authToken auth.Token) (net.Conn, error) {
-	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", port))
+	return ConnectAddr(fmt.Sprintf("localhost:%d", port), authToken)
+}
+
+// ConnectAddr connects to the process listening on the TCP host:port addr,
+// sending the authToken.
+func ConnectAddr(addr string, authToken auth.Token) (net.Conn, error) {
+	conn, err := net.Dial("tcp", addr)
 	if err != nil {
 		return nil, err
 	}
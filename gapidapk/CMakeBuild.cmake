@@ -124,7 +124,7 @@ foreach(abi ${ANDROID_ACTIVE_ABI_LIST})
 
     string(REPLACE ";" "," all_inputs "${TARGET_SOURCES}")
 
-    set(gradle_out "${apk_dir}/app/build/outputs/apk/app-debug.apk")
+    set(gradle_out "${apk_dir}/app/build/outputs/apk/app-debug-unsigned.apk")
     gradle(${abi}-gradle-gapid-apk
         OUTPUT "${gradle_out}"
         DIRECTORY "${apk_dir}"
@@ -137,14 +137,17 @@ foreach(abi ${ANDROID_ACTIVE_ABI_LIST})
             filehash
     )
 
+    # gradle leaves the apk unsigned, it is aligned and signed with the debug
+    # key by sign-apk instead of the SDK build tools.
     set(abi_gapid_apk "${abi_bin}/gapid-${abi}.apk")
     add_custom_command(
         OUTPUT "${abi_gapid_apk}"
-        COMMAND "${CMAKE_COMMAND}" -E copy
+        COMMAND sign-apk
             "${gradle_out}"
             "${abi_gapid_apk}"
         DEPENDS
             "${gradle_out}"
+            sign-apk
     )
     add_custom_target("${abi}-gapid-apk" ALL DEPENDS ${abi_gapid_apk})
 
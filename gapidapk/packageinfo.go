@@ -16,11 +16,15 @@ package gapidapk
 
 import (
 	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 
 	"github.com/golang/protobuf/jsonpb"
 	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/core/os/android"
 	"github.com/google/gapid/core/os/android/adb"
+	"github.com/google/gapid/core/os/android/apk"
 	"github.com/google/gapid/gapidapk/pkginfo"
 )
 
@@ -71,3 +75,42 @@ func PackageList(ctx context.Context, d adb.Device, includeIcons bool, iconDensi
 
 	return out, nil
 }
+
+// AddResources pulls the APK of each of the packages in l from the device
+// and fills in the package's label, version name and icon files from the
+// APK's manifest and resource table.
+func AddResources(ctx context.Context, d adb.Device, l *pkginfo.PackageList) error {
+	dir, err := ioutil.TempDir("", "gapid-packages")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	for _, p := range l.Packages {
+		ctx := log.V{"package": p.Name}.Bind(ctx)
+		path := filepath.Join(dir, p.Name+".apk")
+		pkg := &android.InstalledPackage{Name: p.Name, Device: d}
+		if err := pkg.Pull(ctx, path); err != nil {
+			log.W(ctx, "Couldn't pull APK: %v", err)
+			continue
+		}
+		data, err := ioutil.ReadFile(path)
+		os.Remove(path)
+		if err != nil {
+			return err
+		}
+		info, err := apk.Describe(ctx, data)
+		if err != nil {
+			log.W(ctx, "Couldn't analyze APK: %v", err)
+			continue
+		}
+		if info.Label != "" {
+			p.Label = info.Label
+		}
+		p.VersionName = info.VersionName
+		for _, i := range info.Icons {
+			p.IconFiles = append(p.IconFiles, &pkginfo.IconFile{Density: i.Density, Path: i.Path})
+		}
+	}
+	return nil
+}
@@ -23,8 +23,13 @@ set(files
 set(dirs
     analysis
     ast
+    compat
+    docs
     format
     fuzz
+    graph
+    interpreter
+    khronos
     langsvr
     parser
     resolver
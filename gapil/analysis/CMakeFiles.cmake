@@ -18,6 +18,8 @@
 # build and the file will be recreated, check in the new version.
 
 set(files
+    access.go
+    access_test.go
     analysis.go
     analyze.go
     analyze_test.go
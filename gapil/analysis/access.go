@@ -0,0 +1,174 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analysis
+
+import (
+	"sort"
+
+	"github.com/google/gapid/gapil/semantic"
+)
+
+// StateAccess holds the global state read and written by a command,
+// including the state accessed by the subroutines it calls.
+//
+// State is identified by its path from a global through the fields of the
+// classes holding it, with "[]" for map and array lookups. For example
+// "Contexts[].Bound.ArrayBuffer".
+type StateAccess struct {
+	Reads  []string // The sorted paths of the state read by the command.
+	Writes []string // The sorted paths of the state written by the command.
+}
+
+// accesses returns the state accessed by each of the commands of api.
+func accesses(api *semantic.API) map[*semantic.Function]*StateAccess {
+	functions := map[*semantic.Function]*functionAccess{}
+	out := make(map[*semantic.Function]*StateAccess, len(api.Functions))
+	for _, f := range api.Functions {
+		a := accessOf(f, functions)
+		out[f] = &StateAccess{Reads: sorted(a.reads), Writes: sorted(a.writes)}
+	}
+	return out
+}
+
+// functionAccess holds the state accessed by a single function.
+type functionAccess struct {
+	reads, writes map[string]struct{}
+	result        string // The state path returned by the function, if any.
+}
+
+// accessWalker gathers the state accessed by the statements of a function.
+type accessWalker struct {
+	*functionAccess
+	functions map[*semantic.Function]*functionAccess
+	locals    map[*semantic.Local]string // The state paths held by locals.
+}
+
+func accessOf(f *semantic.Function, functions map[*semantic.Function]*functionAccess) *functionAccess {
+	if a, ok := functions[f]; ok {
+		return a // Already done, or a recursive call.
+	}
+	a := &functionAccess{reads: map[string]struct{}{}, writes: map[string]struct{}{}}
+	functions[f] = a
+	if f.Block != nil {
+		w := &accessWalker{a, functions, map[*semantic.Local]string{}}
+		w.walk(f.Block)
+	}
+	return a
+}
+
+func (w *accessWalker) walk(n semantic.Node) {
+	switch n := n.(type) {
+	case semantic.Type:
+		// Types hold no state accesses.
+	case *semantic.Global, *semantic.Local, *semantic.Member, *semantic.MapIndex, *semantic.ArrayIndex:
+		if p := w.path(n.(semantic.Expression)); p != "" {
+			w.reads[p] = struct{}{}
+		}
+	case *semantic.Call:
+		w.call(n)
+	case *semantic.DeclareLocal:
+		if p := w.path(n.Local.Value); p != "" {
+			w.locals[n.Local] = p
+		}
+	case *semantic.Assign:
+		w.write(n.LHS)
+		w.walk(n.RHS)
+	case *semantic.ArrayAssign:
+		w.write(n.To)
+		w.walk(n.Value)
+	case *semantic.MapAssign:
+		w.write(n.To)
+		w.walk(n.Value)
+	case *semantic.MapRemove:
+		w.write(n.Map)
+		w.walk(n.Key)
+	case *semantic.Return:
+		if n.Value != nil {
+			if p := w.path(n.Value); p != "" {
+				w.reads[p] = struct{}{}
+				w.result = p
+			}
+		}
+	default:
+		semantic.Visit(n, w.walk)
+	}
+}
+
+func (w *accessWalker) write(n semantic.Expression) {
+	if p := w.path(n); p != "" {
+		w.writes[p] = struct{}{}
+	}
+}
+
+// call adds the state accessed by the called function and its arguments,
+// returning the state path returned by the function.
+func (w *accessWalker) call(n *semantic.Call) string {
+	if n.Target.Object != nil {
+		w.walk(n.Target.Object)
+	}
+	for _, a := range n.Arguments {
+		w.walk(a)
+	}
+	callee := accessOf(n.Target.Function, w.functions)
+	for p := range callee.reads {
+		w.reads[p] = struct{}{}
+	}
+	for p := range callee.writes {
+		w.writes[p] = struct{}{}
+	}
+	return callee.result
+}
+
+// path returns the state path of the expression n, or an empty string if n
+// does not refer to global state. The parts of n that are not part of the path
+// are walked.
+func (w *accessWalker) path(n semantic.Expression) string {
+	switch n := n.(type) {
+	case *semantic.Global:
+		return n.Name()
+	case *semantic.Local:
+		return w.locals[n]
+	case *semantic.Member:
+		if p := w.path(n.Object); p != "" {
+			return p + "." + n.Field.Name()
+		}
+	case *semantic.MapIndex:
+		p := w.path(n.Map)
+		w.walk(n.Index)
+		if p != "" {
+			return p + "[]"
+		}
+	case *semantic.ArrayIndex:
+		p := w.path(n.Array)
+		w.walk(n.Index)
+		if p != "" {
+			return p + "[]"
+		}
+	case *semantic.Call:
+		return w.call(n)
+	default:
+		w.walk(n)
+	}
+	return ""
+}
+
+func sorted(set map[string]struct{}) []string {
+	out := make([]string, 0, len(set))
+	for s := range set {
+		out = append(out, s)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,72 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analysis_test
+
+import (
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapil/analysis"
+)
+
+func TestAccesses(t *testing.T) {
+	ctx := log.Testing(t)
+
+	common := `
+    class Buffer { u32 size }
+    class Context { ref!Buffer bound  u32[4] color }
+    map!(u32, ref!Context) Contexts
+    u32 Current
+    u32 Errors
+
+    sub ref!Context GetContext() {
+      ctx := Contexts[Current]
+      return ctx
+    }
+    sub void setError() { Errors = 1 }`
+
+	for _, test := range []struct {
+		source string
+		reads  []string
+		writes []string
+	}{
+		{`cmd void f() {}`, []string{}, []string{}},
+		{`cmd void f(u32 s) { GetContext().bound.size = s }`,
+			[]string{"Contexts[]", "Current"},
+			[]string{"Contexts[].bound.size"}},
+		{`cmd u32 f() {
+            ctx := GetContext()
+            if ctx.bound == null { setError() }
+            return ctx.color[1]
+          }`,
+			[]string{"Contexts[]", "Contexts[].bound", "Contexts[].color[]", "Current"},
+			[]string{"Errors"}},
+		{`cmd void f(u32 id) { delete(Contexts, id) Current = id }`,
+			[]string{},
+			[]string{"Contexts", "Current"}},
+		{`cmd void f(u32 id) { Contexts[id] = new!Context() }`,
+			[]string{},
+			[]string{"Contexts[]"}},
+	} {
+		ctx := log.V{"source": test.source}.Bind(ctx)
+		api, mappings, err := compile(ctx, common+" "+test.source)
+		assert.With(ctx).ThatError(err).Succeeded()
+		res := analysis.Analyze(api, mappings)
+		got := res.Accesses[api.Functions[0]]
+		assert.With(ctx).ThatSlice(got.Reads).Equals(test.reads)
+		assert.With(ctx).ThatSlice(got.Writes).Equals(test.writes)
+	}
+}
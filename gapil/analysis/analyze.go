@@ -76,6 +76,7 @@ func Analyze(api *semantic.API, mappings *resolver.Mappings) *Results {
 		Globals:      s.globals,
 		Parameters:   s.parameters,
 		Instances:    s.instances,
+		Accesses:     accesses(api),
 	}
 }
 
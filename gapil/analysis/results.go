@@ -33,6 +33,8 @@ type Results struct {
 	// Instances is the map of semantic create statements to the possible values
 	// for those instances.
 	Instances map[*semantic.Create]Value
+	// Accesses is the map of semantic commands to the state they access.
+	Accesses map[*semantic.Function]*StateAccess
 }
 
 // Unreachable represents an unreachable block or statement.
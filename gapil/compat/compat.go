@@ -0,0 +1,323 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package compat compares two versions of an API, reporting the changes that
+// affect compatibility with captures made using the older version.
+package compat
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/gapid/gapil/semantic"
+	"github.com/google/gapid/gapil/semantic/printer"
+)
+
+// breakingAnnotations are the annotations which change how commands and types
+// are captured, serialized or replayed.
+var breakingAnnotations = map[string]bool{
+	"indirect":            true,
+	"internal":            true,
+	"pfn":                 true,
+	"replay_custom_value": true,
+	"replay_remap":        true,
+	"serialize":           true,
+}
+
+// ignoredAnnotations are the annotations which only document the API.
+var ignoredAnnotations = map[string]bool{
+	"doc": true,
+}
+
+// Change describes a single difference between two versions of an API.
+type Change struct {
+	// Breaking is true if the change breaks compatibility with captures made
+	// using the older version of the API.
+	Breaking bool
+	// Message describes the change.
+	Message string
+}
+
+func (c Change) String() string {
+	if c.Breaking {
+		return "breaking: " + c.Message
+	}
+	return "compatible: " + c.Message
+}
+
+// Changes is a list of changes.
+type Changes []Change
+
+func (l Changes) String() string {
+	lines := make([]string, len(l))
+	for i, c := range l {
+		lines[i] = c.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Breaking returns the number of breaking changes in the list.
+func (l Changes) Breaking() int {
+	count := 0
+	for _, c := range l {
+		if c.Breaking {
+			count++
+		}
+	}
+	return count
+}
+
+func (l *Changes) addf(breaking bool, msg string, args ...interface{}) {
+	*l = append(*l, Change{Breaking: breaking, Message: fmt.Sprintf(msg, args...)})
+}
+
+// Compare returns the changes made to the commands, enums, classes and
+// pseudonyms of the API old to produce the API new. The breaking changes are
+// listed first, each group sorted by message.
+func Compare(old, new *semantic.API) Changes {
+	l := Changes{}
+	l.commands(old.Functions, new.Functions)
+	l.enums(old.Enums, new.Enums)
+	l.classes(old.Classes, new.Classes)
+	l.pseudonyms(old.Pseudonyms, new.Pseudonyms)
+	sort.SliceStable(l, func(i, j int) bool {
+		if l[i].Breaking != l[j].Breaking {
+			return l[i].Breaking
+		}
+		return l[i].Message < l[j].Message
+	})
+	return l
+}
+
+func (l *Changes) commands(old, new []*semantic.Function) {
+	byName := map[string]*semantic.Function{}
+	for _, f := range new {
+		byName[f.Name()] = f
+	}
+	for _, o := range old {
+		n, ok := byName[o.Name()]
+		if !ok {
+			l.addf(true, "command %s was removed", o.Name())
+			continue
+		}
+		delete(byName, o.Name())
+		what := "command " + o.Name()
+		if a, b := typename(o.Return.Type), typename(n.Return.Type); a != b {
+			l.addf(true, "%s return type changed from %s to %s", what, a, b)
+		}
+		l.annotations(what, o.Annotations, n.Annotations)
+		op, np := o.CallParameters(), n.CallParameters()
+		if len(op) != len(np) {
+			l.addf(true, "%s now has %d parameters, was %d", what, len(np), len(op))
+		}
+		for i := 0; i < len(op) && i < len(np); i++ {
+			a, b := op[i], np[i]
+			if a.Name() != b.Name() {
+				l.addf(false, "%s parameter %d renamed from %s to %s", what, i, a.Name(), b.Name())
+			}
+			param := fmt.Sprintf("%s parameter %s", what, b.Name())
+			if at, bt := typename(a.Type), typename(b.Type); at != bt {
+				l.addf(true, "%s type changed from %s to %s", param, at, bt)
+			}
+			l.annotations(param, a.Annotations, b.Annotations)
+		}
+	}
+	for _, n := range new {
+		if _, ok := byName[n.Name()]; ok {
+			l.addf(false, "command %s was added", n.Name())
+		}
+	}
+}
+
+func (l *Changes) enums(old, new []*semantic.Enum) {
+	byName := map[string]*semantic.Enum{}
+	for _, e := range new {
+		byName[e.Name()] = e
+	}
+	for _, o := range old {
+		n, ok := byName[o.Name()]
+		if !ok {
+			l.addf(true, "enum %s was removed", o.Name())
+			continue
+		}
+		delete(byName, o.Name())
+		what := "enum " + o.Name()
+		if o.IsBitfield != n.IsBitfield {
+			l.addf(false, "%s bitfield changed from %v to %v", what, o.IsBitfield, n.IsBitfield)
+		}
+		l.annotations(what, o.Annotations, n.Annotations)
+		entries := map[string]*semantic.EnumEntry{}
+		for _, e := range n.Entries {
+			entries[e.Name()] = e
+		}
+		for _, oe := range o.Entries {
+			ne, ok := entries[oe.Name()]
+			if !ok {
+				l.addf(true, "%s entry %s was removed", what, oe.Name())
+				continue
+			}
+			delete(entries, oe.Name())
+			if oe.Value != ne.Value {
+				l.addf(true, "%s entry %s renumbered from 0x%x to 0x%x", what, oe.Name(), oe.Value, ne.Value)
+			}
+		}
+		for _, ne := range n.Entries {
+			if _, ok := entries[ne.Name()]; ok {
+				l.addf(false, "%s entry %s was added", what, ne.Name())
+			}
+		}
+	}
+	for _, n := range new {
+		if _, ok := byName[n.Name()]; ok {
+			l.addf(false, "enum %s was added", n.Name())
+		}
+	}
+}
+
+func (l *Changes) classes(old, new []*semantic.Class) {
+	byName := map[string]*semantic.Class{}
+	for _, c := range new {
+		byName[c.Name()] = c
+	}
+	for _, o := range old {
+		n, ok := byName[o.Name()]
+		if !ok {
+			l.addf(true, "class %s was removed", o.Name())
+			continue
+		}
+		delete(byName, o.Name())
+		what := "class " + o.Name()
+		l.annotations(what, o.Annotations, n.Annotations)
+		// Classes can mirror structures read from application memory, so any
+		// change to the fields changes the layout of the class.
+		fields := map[string]*semantic.Field{}
+		for _, f := range n.Fields {
+			fields[f.Name()] = f
+		}
+		common := []string{}
+		for _, of := range o.Fields {
+			nf, ok := fields[of.Name()]
+			if !ok {
+				l.addf(true, "%s field %s was removed", what, of.Name())
+				continue
+			}
+			delete(fields, of.Name())
+			common = append(common, of.Name())
+			field := fmt.Sprintf("%s field %s", what, of.Name())
+			if a, b := typename(of.Type), typename(nf.Type); a != b {
+				l.addf(true, "%s type changed from %s to %s", field, a, b)
+			}
+			l.annotations(field, of.Annotations, nf.Annotations)
+		}
+		order := []string{}
+		for _, nf := range n.Fields {
+			if _, added := fields[nf.Name()]; added {
+				l.addf(true, "%s field %s was added", what, nf.Name())
+			} else {
+				order = append(order, nf.Name())
+			}
+		}
+		if strings.Join(common, ",") != strings.Join(order, ",") {
+			l.addf(true, "%s fields were reordered", what)
+		}
+	}
+	for _, n := range new {
+		if _, ok := byName[n.Name()]; ok {
+			l.addf(false, "class %s was added", n.Name())
+		}
+	}
+}
+
+func (l *Changes) pseudonyms(old, new []*semantic.Pseudonym) {
+	byName := map[string]*semantic.Pseudonym{}
+	for _, p := range new {
+		byName[p.Name()] = p
+	}
+	for _, o := range old {
+		n, ok := byName[o.Name()]
+		if !ok {
+			l.addf(true, "type %s was removed", o.Name())
+			continue
+		}
+		delete(byName, o.Name())
+		what := "type " + o.Name()
+		if a, b := typename(o.To), typename(n.To); a != b {
+			l.addf(true, "%s changed from %s to %s", what, a, b)
+		}
+		l.annotations(what, o.Annotations, n.Annotations)
+	}
+	for _, n := range new {
+		if _, ok := byName[n.Name()]; ok {
+			l.addf(false, "type %s was added", n.Name())
+		}
+	}
+}
+
+// annotations reports the annotations that were added to, removed from or
+// changed on the declaration what.
+func (l *Changes) annotations(what string, old, new semantic.Annotations) {
+	o, n := annotationMap(old), annotationMap(new)
+	for _, name := range sortedKeys(o) {
+		if _, ok := n[name]; !ok {
+			l.addf(breakingAnnotations[name], "%s annotation @%s was removed", what, name)
+		}
+	}
+	for _, name := range sortedKeys(n) {
+		a, ok := o[name]
+		switch {
+		case !ok:
+			l.addf(breakingAnnotations[name], "%s annotation @%s was added", what, name)
+		case a != n[name]:
+			l.addf(breakingAnnotations[name], "%s annotation changed from %s to %s", what, a, n[name])
+		}
+	}
+}
+
+// annotationMap returns the annotations of l keyed by name, with the printed
+// form of each annotation as the value.
+func annotationMap(l semantic.Annotations) map[string]string {
+	out := map[string]string{}
+	for _, a := range l {
+		if ignoredAnnotations[a.Name()] {
+			continue
+		}
+		args := make([]string, len(a.Arguments))
+		for i, arg := range a.Arguments {
+			args[i] = printer.New().WriteExpression(arg).String()
+		}
+		s := "@" + a.Name()
+		if len(args) > 0 {
+			s += "(" + strings.Join(args, ", ") + ")"
+		}
+		out[a.Name()] = s
+	}
+	return out
+}
+
+func sortedKeys(m map[string]string) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func typename(t semantic.Type) string {
+	if t == nil {
+		return "<nil>"
+	}
+	return printer.New().WriteType(t).String()
+}
@@ -0,0 +1,93 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compat_test
+
+import (
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/gapil/ast"
+	"github.com/google/gapid/gapil/compat"
+	"github.com/google/gapid/gapil/parser"
+	"github.com/google/gapid/gapil/resolver"
+	"github.com/google/gapid/gapil/semantic"
+)
+
+func resolve(assert assert.Manager, source string) *semantic.API {
+	m := resolver.NewMappings()
+	astAPI, errs := parser.Parse("compat_test.api", source, m)
+	assert.For("parse errors").That(errs).IsNil()
+	api, errs := resolver.Resolve([]*ast.API{astAPI}, nil, m)
+	assert.For("resolve errors").That(errs).IsNil()
+	return api
+}
+
+func TestCompare(t *testing.T) {
+	assert := assert.To(t)
+	old := resolve(assert, `
+type u32 GLuint
+
+enum GLenum {
+	GL_ONE   = 0x1
+	GL_TWO   = 0x2
+	GL_THREE = 0x3
+}
+
+class Point {
+	u32 x
+	u32 y
+}
+
+@indirect("Device")
+cmd void draw(GLenum mode, u32 count) {}
+cmd void clear(u32 mask) {}
+cmd u32 query(u32 id) { return id }
+`)
+	new := resolve(assert, `
+type u64 GLuint
+
+enum GLenum {
+	GL_ONE   = 0x1
+	GL_TWO   = 0x20
+	GL_FOUR  = 0x4
+}
+
+class Point {
+	u32 y
+	u32 x
+	u32 z
+}
+
+cmd void draw(GLenum mode, u32 n) {}
+cmd u32 query(u64 id) { return as!u32(id) }
+cmd void flush() {}
+`)
+	changes := compat.Compare(old, new)
+	assert.For("changes").ThatSlice(changes).DeepEquals(compat.Changes{
+		{true, "class Point field z was added"},
+		{true, "class Point fields were reordered"},
+		{true, "command clear was removed"},
+		{true, `command draw annotation @indirect was removed`},
+		{true, "command query parameter id type changed from u32 to u64"},
+		{true, "enum GLenum entry GL_THREE was removed"},
+		{true, "enum GLenum entry GL_TWO renumbered from 0x2 to 0x20"},
+		{true, "type GLuint changed from u32 to u64"},
+		{false, "command draw parameter 1 renamed from count to n"},
+		{false, "command flush was added"},
+		{false, "enum GLenum entry GL_FOUR was added"},
+	})
+	assert.For("breaking").That(changes.Breaking()).Equals(8)
+	assert.For("unchanged").That(len(compat.Compare(old, old))).Equals(0)
+}
@@ -0,0 +1,341 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package docs generates browsable reference documentation for an API.
+package docs
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/gapid/gapil/analysis"
+	"github.com/google/gapid/gapil/semantic"
+	"github.com/google/gapid/gapil/semantic/printer"
+)
+
+// Format is an output format of the documentation.
+type Format int
+
+const (
+	// HTML is a standalone HTML page.
+	HTML Format = iota
+	// Markdown is a GitHub flavoured markdown page.
+	Markdown
+)
+
+// Write writes the reference documentation for the commands, classes, enums
+// and types of api to w, using the format f. If accesses is not nil, the
+// state read and written by each command is listed with the command.
+func Write(w io.Writer, api *semantic.API, accesses map[*semantic.Function]*analysis.StateAccess, f Format) error {
+	p := build(api, accesses)
+	switch f {
+	case HTML:
+		return writeHTML(w, p)
+	case Markdown:
+		return writeMarkdown(w, p)
+	default:
+		return fmt.Errorf("Unknown documentation format %v", f)
+	}
+}
+
+// page is the documentation of an API, independent of the output format.
+type page struct {
+	title    string
+	sections []*section
+}
+
+// section is a list of documented declarations of the same kind.
+type section struct {
+	id, title string
+	entries   []*entry
+}
+
+// entry is the documentation for a single declaration.
+type entry struct {
+	id, title   string
+	signature   text
+	docs        []string // Paragraphs of documentation.
+	annotations []text
+	table       *table
+	lists       []*list
+}
+
+type table struct {
+	headers []string
+	rows    [][]text
+}
+
+type list struct {
+	title string
+	items []text
+}
+
+// text is a sequence of spans, some of which link to other declarations.
+type text []span
+
+type span struct {
+	s    string
+	link string // The anchor or URL the span links to, if any.
+}
+
+func plain(s string) text { return text{{s: s}} }
+
+// builder builds the page from the semantic tree.
+type builder struct {
+	anchors map[string]string // The anchors of the documented types by name.
+}
+
+var wordRE = regexp.MustCompile(`\w+`)
+
+func build(api *semantic.API, accesses map[*semantic.Function]*analysis.StateAccess) *page {
+	b := &builder{anchors: map[string]string{}}
+	for _, c := range api.Classes {
+		b.anchors[c.Name()] = "class-" + c.Name()
+	}
+	for _, e := range api.Enums {
+		b.anchors[e.Name()] = "enum-" + e.Name()
+	}
+	for _, p := range api.Pseudonyms {
+		b.anchors[p.Name()] = "type-" + p.Name()
+	}
+
+	title := "API reference"
+	if api.Name() != "" {
+		title = api.Name() + " " + title
+	}
+	p := &page{title: title}
+
+	commands := &section{id: "commands", title: "Commands"}
+	for _, f := range api.Functions {
+		commands.entries = append(commands.entries, b.command(f, accesses[f]))
+	}
+	classes := &section{id: "classes", title: "Classes"}
+	for _, c := range api.Classes {
+		classes.entries = append(classes.entries, b.class(c))
+	}
+	enums := &section{id: "enums", title: "Enums"}
+	for _, e := range api.Enums {
+		enums.entries = append(enums.entries, b.enum(e))
+	}
+	types := &section{id: "types", title: "Types"}
+	for _, t := range api.Pseudonyms {
+		types.entries = append(types.entries, b.pseudonym(t))
+	}
+	state := &section{id: "state", title: "State"}
+	for _, g := range api.Globals {
+		state.entries = append(state.entries, b.global(g))
+	}
+
+	for _, s := range []*section{commands, classes, enums, types, state} {
+		if len(s.entries) > 0 {
+			sort.Slice(s.entries, func(i, j int) bool { return s.entries[i].title < s.entries[j].title })
+			p.sections = append(p.sections, s)
+		}
+	}
+	return p
+}
+
+func (b *builder) command(f *semantic.Function, access *analysis.StateAccess) *entry {
+	sig := text{{s: "cmd "}}
+	sig = append(sig, b.typ(f.Return.Type)...)
+	sig = append(sig, span{s: " " + f.Name() + "("})
+	t := &table{headers: []string{"Parameter", "Type", "Description"}}
+	for i, p := range f.CallParameters() {
+		if i > 0 {
+			sig = append(sig, span{s: ", "})
+		}
+		sig = append(sig, b.typ(p.Type)...)
+		sig = append(sig, span{s: " " + p.Name()})
+		t.rows = append(t.rows, []text{plain(p.Name()), b.typ(p.Type), plain(strings.Join(p.Docs, " "))})
+	}
+	sig = append(sig, span{s: ")"})
+
+	e := &entry{
+		id:          "cmd-" + f.Name(),
+		title:       f.Name(),
+		signature:   sig,
+		docs:        paragraphs(f.Docs),
+		annotations: b.annotations(f.Annotations),
+	}
+	if len(t.rows) > 0 {
+		e.table = t
+	}
+	if access != nil {
+		if l := b.state("Reads", access.Reads); l != nil {
+			e.lists = append(e.lists, l)
+		}
+		if l := b.state("Writes", access.Writes); l != nil {
+			e.lists = append(e.lists, l)
+		}
+	}
+	return e
+}
+
+// state returns the list of state paths linked to their globals, or nil if
+// there are none.
+func (b *builder) state(title string, paths []string) *list {
+	if len(paths) == 0 {
+		return nil
+	}
+	l := &list{title: title}
+	for _, p := range paths {
+		global := wordRE.FindString(p)
+		l.items = append(l.items, text{{s: p, link: "#state-" + global}})
+	}
+	return l
+}
+
+func (b *builder) class(c *semantic.Class) *entry {
+	t := &table{headers: []string{"Field", "Type", "Description"}}
+	for _, f := range c.Fields {
+		t.rows = append(t.rows, []text{plain(f.Name()), b.typ(f.Type), plain(strings.Join(f.Docs, " "))})
+	}
+	e := &entry{
+		id:          "class-" + c.Name(),
+		title:       c.Name(),
+		signature:   plain("class " + c.Name()),
+		docs:        paragraphs(c.Docs),
+		annotations: b.annotations(c.Annotations),
+	}
+	if len(t.rows) > 0 {
+		e.table = t
+	}
+	return e
+}
+
+func (b *builder) enum(n *semantic.Enum) *entry {
+	kind := "enum"
+	if n.IsBitfield {
+		kind = "bitfield"
+	}
+	t := &table{headers: []string{"Name", "Value", "Description"}}
+	for _, v := range n.Entries {
+		t.rows = append(t.rows, []text{
+			plain(v.Name()),
+			plain(fmt.Sprintf("0x%08X", v.Value)),
+			plain(strings.Join(v.Docs, " ")),
+		})
+	}
+	e := &entry{
+		id:          "enum-" + n.Name(),
+		title:       n.Name(),
+		signature:   plain(kind + " " + n.Name()),
+		docs:        paragraphs(n.Docs),
+		annotations: b.annotations(n.Annotations),
+	}
+	if len(t.rows) > 0 {
+		e.table = t
+	}
+	return e
+}
+
+func (b *builder) pseudonym(p *semantic.Pseudonym) *entry {
+	sig := text{{s: "type "}}
+	sig = append(sig, b.typ(p.To)...)
+	sig = append(sig, span{s: " " + p.Name()})
+	return &entry{
+		id:          "type-" + p.Name(),
+		title:       p.Name(),
+		signature:   sig,
+		docs:        paragraphs(p.Docs),
+		annotations: b.annotations(p.Annotations),
+	}
+}
+
+func (b *builder) global(g *semantic.Global) *entry {
+	sig := b.typ(g.Type)
+	sig = append(sig, span{s: " " + g.Name()})
+	return &entry{
+		id:          "state-" + g.Name(),
+		title:       g.Name(),
+		signature:   sig,
+		annotations: b.annotations(g.Annotations),
+	}
+}
+
+// typ returns the text of the type t, with the names of the documented types
+// linked to their documentation.
+func (b *builder) typ(t semantic.Type) text {
+	return b.link(printer.New().WriteType(t).String())
+}
+
+// link returns s with the names of the documented types linked to their
+// documentation.
+func (b *builder) link(s string) text {
+	out := text{}
+	last := 0
+	for _, m := range wordRE.FindAllStringIndex(s, -1) {
+		anchor, ok := b.anchors[s[m[0]:m[1]]]
+		if !ok {
+			continue
+		}
+		if m[0] > last {
+			out = append(out, span{s: s[last:m[0]]})
+		}
+		out = append(out, span{s: s[m[0]:m[1]], link: "#" + anchor})
+		last = m[1]
+	}
+	if last < len(s) {
+		out = append(out, span{s: s[last:]})
+	}
+	return out
+}
+
+// annotations returns the text of the annotations. The URL argument of a @doc
+// annotation links to the specification it names.
+func (b *builder) annotations(l semantic.Annotations) []text {
+	out := []text{}
+	for _, a := range l {
+		args := make([]string, len(a.Arguments))
+		for i, arg := range a.Arguments {
+			if s, ok := arg.(semantic.StringValue); ok {
+				args[i] = strconv.Quote(string(s))
+			} else {
+				args[i] = printer.New().WriteExpression(arg).String()
+			}
+		}
+		s := "@" + a.Name()
+		if len(args) > 0 {
+			s += "(" + strings.Join(args, ", ") + ")"
+		}
+		t := plain(s)
+		if a.Name() == "doc" && len(a.Arguments) > 0 {
+			if url, ok := a.Arguments[0].(semantic.StringValue); ok {
+				t = text{{s: s, link: string(url)}}
+			}
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+// paragraphs joins the documentation lines into paragraphs separated by blank
+// lines.
+func paragraphs(docs semantic.Documentation) []string {
+	out := []string{}
+	current := []string{}
+	for _, l := range append(docs, "") {
+		if l = strings.TrimSpace(l); l != "" {
+			current = append(current, l)
+		} else if len(current) > 0 {
+			out = append(out, strings.Join(current, " "))
+			current = current[:0]
+		}
+	}
+	return out
+}
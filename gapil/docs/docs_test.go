@@ -0,0 +1,98 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docs_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/gapil/analysis"
+	"github.com/google/gapid/gapil/ast"
+	"github.com/google/gapid/gapil/docs"
+	"github.com/google/gapid/gapil/parser"
+	"github.com/google/gapid/gapil/resolver"
+)
+
+const source = `
+type u32 GLuint
+
+/// The buffer binding targets.
+enum GLenum {
+	GL_ARRAY_BUFFER = 0x8892
+}
+
+/// A buffer object.
+class Buffer {
+	GLuint size /// The size in bytes.
+}
+
+map!(GLuint, ref!Buffer) Buffers
+ref!Buffer Bound
+
+@doc("https://example.com/glBindBuffer.xhtml")
+/**
+ * Binds the named buffer
+ * to a target.
+ *
+ * A second paragraph.
+ */
+cmd void glBindBuffer(GLenum target, GLuint buffer) {
+	Bound = Buffers[buffer]
+}
+`
+
+func TestWrite(t *testing.T) {
+	assert := assert.To(t)
+	m := resolver.NewMappings()
+	astAPI, errs := parser.Parse("docs_test.api", source, m)
+	assert.For("parse errors").That(errs).IsNil()
+	api, errs := resolver.Resolve([]*ast.API{astAPI}, nil, m)
+	assert.For("resolve errors").That(errs).IsNil()
+	accesses := analysis.Analyze(api, m).Accesses
+
+	buf := &bytes.Buffer{}
+	err := docs.Write(buf, api, accesses, docs.Markdown)
+	assert.For("markdown err").ThatError(err).Succeeded()
+	markdown := buf.String()
+	for _, s := range []string{
+		"- [Commands](#commands) (1)\n",
+		"<a name=\"cmd-glBindBuffer\"></a>\n### glBindBuffer\n",
+		"cmd void glBindBuffer([GLenum](#enum-GLenum) target, [GLuint](#type-GLuint) buffer)\n",
+		"[@doc(\"https://example.com/glBindBuffer.xhtml\")](https://example.com/glBindBuffer.xhtml)\n",
+		"\nBinds the named buffer to a target.\n\nA second paragraph.\n",
+		"| buffer | [GLuint](#type-GLuint) |  |\n",
+		"Reads:\n\n- [Buffers\\[\\]](#state-Buffers)\n",
+		"Writes:\n\n- [Bound](#state-Bound)\n",
+		"| size | [GLuint](#type-GLuint) | The size in bytes. |\n",
+		"| GL\\_ARRAY\\_BUFFER | 0x00008892 |  |\n",
+		"map!([GLuint](#type-GLuint), ref![Buffer](#class-Buffer)) Buffers\n",
+	} {
+		assert.For("markdown").ThatString(markdown).Contains(s)
+	}
+
+	buf.Reset()
+	err = docs.Write(buf, api, accesses, docs.HTML)
+	assert.For("html err").ThatError(err).Succeeded()
+	page := buf.String()
+	for _, s := range []string{
+		"<h3 id=\"cmd-glBindBuffer\">glBindBuffer</h3>\n",
+		"<pre>cmd void glBindBuffer(<a href=\"#enum-GLenum\">GLenum</a> target, <a href=\"#type-GLuint\">GLuint</a> buffer)</pre>\n",
+		"<li><code><a href=\"#state-Buffers\">Buffers[]</a></code></li>\n",
+		"<pre>ref!<a href=\"#class-Buffer\">Buffer</a> Bound</pre>\n",
+	} {
+		assert.For("html").ThatString(page).Contains(s)
+	}
+}
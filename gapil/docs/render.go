@@ -0,0 +1,146 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docs
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+const style = `body { font-family: sans-serif; max-width: 60em; margin: auto; }
+pre, code { background: #f4f4f4; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ccc; padding: 0.2em 0.5em; text-align: left; }`
+
+func writeHTML(w io.Writer, p *page) error {
+	buf := &bytes.Buffer{}
+	title := html.EscapeString(p.title)
+	fmt.Fprintf(buf, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	fmt.Fprintf(buf, "<title>%s</title>\n<style>\n%s\n</style>\n</head>\n<body>\n", title, style)
+	fmt.Fprintf(buf, "<h1>%s</h1>\n<ul>\n", title)
+	for _, s := range p.sections {
+		fmt.Fprintf(buf, "<li><a href=\"#%s\">%s</a> (%d)</li>\n", s.id, s.title, len(s.entries))
+	}
+	fmt.Fprintln(buf, "</ul>")
+	for _, s := range p.sections {
+		fmt.Fprintf(buf, "<h2 id=\"%s\">%s</h2>\n", s.id, s.title)
+		for _, e := range s.entries {
+			fmt.Fprintf(buf, "<h3 id=\"%s\">%s</h3>\n", e.id, html.EscapeString(e.title))
+			fmt.Fprintf(buf, "<pre>%s</pre>\n", htmlText(e.signature))
+			for _, a := range e.annotations {
+				fmt.Fprintf(buf, "<code>%s</code><br>\n", htmlText(a))
+			}
+			for _, d := range e.docs {
+				fmt.Fprintf(buf, "<p>%s</p>\n", html.EscapeString(d))
+			}
+			if t := e.table; t != nil {
+				fmt.Fprintln(buf, "<table>")
+				fmt.Fprintf(buf, "<tr><th>%s</th></tr>\n", strings.Join(t.headers, "</th><th>"))
+				for _, row := range t.rows {
+					cells := make([]string, len(row))
+					for i, c := range row {
+						cells[i] = htmlText(c)
+					}
+					fmt.Fprintf(buf, "<tr><td>%s</td></tr>\n", strings.Join(cells, "</td><td>"))
+				}
+				fmt.Fprintln(buf, "</table>")
+			}
+			for _, l := range e.lists {
+				fmt.Fprintf(buf, "<p>%s:</p>\n<ul>\n", l.title)
+				for _, i := range l.items {
+					fmt.Fprintf(buf, "<li><code>%s</code></li>\n", htmlText(i))
+				}
+				fmt.Fprintln(buf, "</ul>")
+			}
+		}
+	}
+	fmt.Fprintln(buf, "</body>\n</html>")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func htmlText(t text) string {
+	out := &bytes.Buffer{}
+	for _, s := range t {
+		if s.link != "" {
+			fmt.Fprintf(out, "<a href=\"%s\">%s</a>", html.EscapeString(s.link), html.EscapeString(s.s))
+		} else {
+			out.WriteString(html.EscapeString(s.s))
+		}
+	}
+	return out.String()
+}
+
+func writeMarkdown(w io.Writer, p *page) error {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "# %s\n\n", markdownEscape(p.title))
+	for _, s := range p.sections {
+		fmt.Fprintf(buf, "- [%s](#%s) (%d)\n", s.title, s.id, len(s.entries))
+	}
+	for _, s := range p.sections {
+		fmt.Fprintf(buf, "\n<a name=\"%s\"></a>\n## %s\n", s.id, s.title)
+		for _, e := range s.entries {
+			fmt.Fprintf(buf, "\n<a name=\"%s\"></a>\n### %s\n\n", e.id, markdownEscape(e.title))
+			fmt.Fprintf(buf, "%s\n", markdownText(e.signature))
+			for _, a := range e.annotations {
+				fmt.Fprintf(buf, "\n%s\n", markdownText(a))
+			}
+			for _, d := range e.docs {
+				fmt.Fprintf(buf, "\n%s\n", markdownEscape(d))
+			}
+			if t := e.table; t != nil {
+				fmt.Fprintf(buf, "\n| %s |\n", strings.Join(t.headers, " | "))
+				fmt.Fprintf(buf, "|%s\n", strings.Repeat(" --- |", len(t.headers)))
+				for _, row := range t.rows {
+					cells := make([]string, len(row))
+					for i, c := range row {
+						cells[i] = markdownText(c)
+					}
+					fmt.Fprintf(buf, "| %s |\n", strings.Join(cells, " | "))
+				}
+			}
+			for _, l := range e.lists {
+				fmt.Fprintf(buf, "\n%s:\n\n", l.title)
+				for _, i := range l.items {
+					fmt.Fprintf(buf, "- %s\n", markdownText(i))
+				}
+			}
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`,
+	`<`, `\<`, `>`, `\>`, `|`, `\|`, `#`, `\#`,
+)
+
+func markdownEscape(s string) string { return markdownEscaper.Replace(s) }
+
+func markdownText(t text) string {
+	out := &bytes.Buffer{}
+	for _, s := range t {
+		if s.link != "" {
+			fmt.Fprintf(out, "[%s](%s)", markdownEscape(s.s), s.link)
+		} else {
+			out.WriteString(markdownEscape(s.s))
+		}
+	}
+	return out.String()
+}
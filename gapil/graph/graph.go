@@ -0,0 +1,124 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package graph builds graphs of the subroutines called by API commands and
+// of the global state they read and write.
+package graph
+
+import (
+	"sort"
+
+	"github.com/google/gapid/gapil/analysis"
+	"github.com/google/gapid/gapil/resolver"
+	"github.com/google/gapid/gapil/semantic"
+)
+
+// Kind is the kind of a graph node.
+type Kind string
+
+const (
+	// Command is the kind of a node representing an API command.
+	Command = Kind("command")
+	// Subroutine is the kind of a node representing an API subroutine.
+	Subroutine = Kind("subroutine")
+	// State is the kind of a node representing a path of the global state.
+	State = Kind("state")
+)
+
+// Relation is the relation that an edge represents.
+type Relation string
+
+const (
+	// Calls is the relation of a command or subroutine calling a subroutine.
+	Calls = Relation("calls")
+	// Reads is the relation of a command reading a piece of state.
+	Reads = Relation("reads")
+	// Writes is the relation of a command writing a piece of state.
+	Writes = Relation("writes")
+)
+
+// Node is a single node of a Graph.
+type Node struct {
+	Name string `json:"name"` // The function name or state path.
+	Kind Kind   `json:"kind"`
+}
+
+// Edge is a single directed edge of a Graph.
+type Edge struct {
+	From     string   `json:"from"` // The name of the source node.
+	To       string   `json:"to"`   // The name of the target node.
+	Relation Relation `json:"relation"`
+}
+
+// Graph holds the call and state access relations of a set of commands.
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+}
+
+// Build returns the graph of the commands of api for which filter returns
+// true, along with all the subroutines they transitively call and the state
+// they access. If filter is nil then all the commands are included.
+func Build(api *semantic.API, accesses map[*semantic.Function]*analysis.StateAccess, filter func(name string) bool) *Graph {
+	g := &Graph{}
+	visited := map[*semantic.Function]bool{}
+	pending := []*semantic.Function{}
+	states := map[string]bool{}
+
+	for _, f := range api.Functions {
+		if filter != nil && !filter(f.Name()) {
+			continue
+		}
+		g.Nodes = append(g.Nodes, Node{Name: f.Name(), Kind: Command})
+		visited[f] = true
+		for _, s := range resolver.SubroutineCalls(f) {
+			g.Edges = append(g.Edges, Edge{From: f.Name(), To: s.Name(), Relation: Calls})
+			pending = append(pending, s)
+		}
+		if a, ok := accesses[f]; ok {
+			for _, p := range a.Reads {
+				g.Edges = append(g.Edges, Edge{From: f.Name(), To: p, Relation: Reads})
+				states[p] = true
+			}
+			for _, p := range a.Writes {
+				g.Edges = append(g.Edges, Edge{From: f.Name(), To: p, Relation: Writes})
+				states[p] = true
+			}
+		}
+	}
+
+	for len(pending) > 0 {
+		s := pending[0]
+		pending = pending[1:]
+		if visited[s] {
+			continue
+		}
+		visited[s] = true
+		g.Nodes = append(g.Nodes, Node{Name: s.Name(), Kind: Subroutine})
+		for _, c := range resolver.SubroutineCalls(s) {
+			g.Edges = append(g.Edges, Edge{From: s.Name(), To: c.Name(), Relation: Calls})
+			pending = append(pending, c)
+		}
+	}
+
+	paths := make([]string, 0, len(states))
+	for p := range states {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		g.Nodes = append(g.Nodes, Node{Name: p, Kind: State})
+	}
+	return g
+}
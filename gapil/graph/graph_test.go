@@ -0,0 +1,110 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/gapil/analysis"
+	"github.com/google/gapid/gapil/ast"
+	"github.com/google/gapid/gapil/graph"
+	"github.com/google/gapid/gapil/parser"
+	"github.com/google/gapid/gapil/resolver"
+)
+
+const source = `
+class Buffer {
+	u32 size
+}
+
+map!(u32, ref!Buffer) Buffers
+ref!Buffer Bound
+
+sub ref!Buffer getBuffer(u32 id) {
+	check(id)
+	return Buffers[id]
+}
+
+sub void check(u32 id) {
+	if !(id in Buffers) { abort }
+}
+
+cmd void glBindBuffer(u32 buffer) {
+	Bound = getBuffer(buffer)
+}
+
+cmd void glBufferSize(u32 size) {
+	Bound.size = size
+}
+`
+
+func TestBuild(t *testing.T) {
+	assert := assert.To(t)
+	m := resolver.NewMappings()
+	astAPI, errs := parser.Parse("graph_test.api", source, m)
+	assert.For("parse errors").That(errs).IsNil()
+	api, errs := resolver.Resolve([]*ast.API{astAPI}, nil, m)
+	assert.For("resolve errors").That(errs).IsNil()
+	accesses := analysis.Analyze(api, m).Accesses
+
+	g := graph.Build(api, accesses, func(name string) bool { return name == "glBindBuffer" })
+	assert.For("nodes").ThatSlice(g.Nodes).Equals([]graph.Node{
+		{Name: "glBindBuffer", Kind: graph.Command},
+		{Name: "getBuffer", Kind: graph.Subroutine},
+		{Name: "check", Kind: graph.Subroutine},
+		{Name: "Bound", Kind: graph.State},
+		{Name: "Buffers", Kind: graph.State},
+		{Name: "Buffers[]", Kind: graph.State},
+	})
+	assert.For("edges").ThatSlice(g.Edges).Equals([]graph.Edge{
+		{From: "glBindBuffer", To: "getBuffer", Relation: graph.Calls},
+		{From: "glBindBuffer", To: "Buffers", Relation: graph.Reads},
+		{From: "glBindBuffer", To: "Buffers[]", Relation: graph.Reads},
+		{From: "glBindBuffer", To: "Bound", Relation: graph.Writes},
+		{From: "getBuffer", To: "check", Relation: graph.Calls},
+	})
+
+	buf := &bytes.Buffer{}
+	assert.For("dot err").ThatError(g.WriteDot(buf)).Succeeded()
+	dot := buf.String()
+	for _, s := range []string{
+		"digraph api {\n",
+		`  "glBindBuffer" [label="glBindBuffer", shape=box];` + "\n",
+		`  "state:Bound" [label="Bound", shape=note];` + "\n",
+		`  "getBuffer" -> "check";` + "\n",
+		`  "glBindBuffer" -> "state:Bound" [label="writes", style=bold];` + "\n",
+	} {
+		assert.For("dot").ThatString(dot).Contains(s)
+	}
+
+	buf.Reset()
+	assert.For("json err").ThatError(g.WriteJSON(buf)).Succeeded()
+	got := &graph.Graph{}
+	assert.For("json decode").ThatError(json.NewDecoder(buf).Decode(got)).Succeeded()
+	assert.For("json").That(got).DeepEquals(g)
+
+	all := graph.Build(api, accesses, nil)
+	commands := []string{}
+	for _, n := range all.Nodes {
+		if n.Kind == graph.Command {
+			commands = append(commands, n.Name)
+		}
+	}
+	assert.For("all commands").That(strings.Join(commands, ",")).Equals("glBindBuffer,glBufferSize")
+}
@@ -0,0 +1,89 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// WriteJSON writes the graph to w as an indented JSON object.
+func (g *Graph) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// WriteDot writes the graph to w in the Graphviz DOT language.
+// Commands are drawn as boxes, subroutines as ellipses and state as notes.
+// Reads are drawn as dashed edges and writes as bold edges.
+func (g *Graph) WriteDot(w io.Writer) error {
+	out := &dotWriter{w: w}
+	out.printf("digraph api {\n")
+	out.printf("  rankdir=LR;\n")
+	for _, n := range g.Nodes {
+		out.printf("  %s [label=%s, shape=%s];\n",
+			dotID(n.Kind, n.Name), strconv.Quote(n.Name), shapes[n.Kind])
+	}
+	for _, e := range g.Edges {
+		// Edges always start at a function, and only calls end at one.
+		from, to := dotID(Command, e.From), dotID(State, e.To)
+		if e.Relation == Calls {
+			to = dotID(Subroutine, e.To)
+		}
+		out.printf("  %s -> %s%s;\n", from, to, styles[e.Relation])
+	}
+	out.printf("}\n")
+	return out.err
+}
+
+var shapes = map[Kind]string{
+	Command:    "box",
+	Subroutine: "ellipse",
+	State:      "note",
+}
+
+var styles = map[Relation]string{
+	Calls:  "",
+	Reads:  ` [label="reads", style=dashed]`,
+	Writes: ` [label="writes", style=bold]`,
+}
+
+// dotID returns the quoted DOT identifier of the node with the given kind
+// and name. Commands and subroutines share a namespace, so only state paths
+// are prefixed to keep them from colliding with function names.
+func dotID(kind Kind, name string) string {
+	if kind == State {
+		name = "state:" + name
+	}
+	return strconv.Quote(name)
+}
+
+// dotWriter is an io.Writer wrapper that holds on to the first write error.
+type dotWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (d *dotWriter) printf(format string, args ...interface{}) {
+	if d.err == nil {
+		_, d.err = fmt.Fprintf(d.w, format, args...)
+	}
+}
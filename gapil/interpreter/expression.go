@@ -0,0 +1,322 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"github.com/google/gapid/gapil/ast"
+	"github.com/google/gapid/gapil/semantic"
+	"github.com/google/gapid/gapis/memory"
+)
+
+// eval returns the value of the expression n.
+func (s *State) eval(f *frame, n semantic.Expression) Value {
+	switch n := n.(type) {
+	case semantic.BoolValue:
+		return bool(n)
+	case semantic.StringValue:
+		return string(n)
+	case semantic.Int8Value:
+		return int8(n)
+	case semantic.Uint8Value:
+		return uint8(n)
+	case semantic.Int16Value:
+		return int16(n)
+	case semantic.Uint16Value:
+		return uint16(n)
+	case semantic.Int32Value:
+		return int32(n)
+	case semantic.Uint32Value:
+		return uint32(n)
+	case semantic.Int64Value:
+		return int64(n)
+	case semantic.Uint64Value:
+		return uint64(n)
+	case semantic.Float32Value:
+		return float32(n)
+	case semantic.Float64Value:
+		return float64(n)
+	case semantic.Null:
+		return s.zero(n.Type)
+	case *semantic.EnumEntry:
+		return n.Value
+	case *semantic.Label:
+		return number(n.ExpressionType(), s.eval(f, n.Value))
+	case *semantic.Definition:
+		return s.eval(f, n.Expression)
+	case *semantic.DefinitionUsage:
+		return s.eval(f, n.Expression)
+	case *semantic.Local:
+		return f.locals[n]
+	case *semantic.Parameter:
+		return f.params[n]
+	case *semantic.Global:
+		return s.Globals[n]
+	case *semantic.Observed:
+		return f.params[n.Parameter]
+	case *semantic.Unknown:
+		if n.Inferred == nil {
+			return s.zero(n.ExpressionType())
+		}
+		return s.eval(f, n.Inferred)
+	case *semantic.Ignore:
+		return nil
+	case *semantic.UnaryOp:
+		v := s.eval(f, n.Expression)
+		switch n.Operator {
+		case ast.OpNot:
+			return !v.(bool)
+		case ast.OpMinus:
+			return arithmetic(n.Type, ast.OpMinus, number(n.Type, uint64(0)), v)
+		}
+		panic(failf("Unsupported unary operator %s", n.Operator))
+	case *semantic.BinaryOp:
+		return s.binaryOp(f, n)
+	case *semantic.BitTest:
+		bits, bitfield := s.eval(f, n.Bits), s.eval(f, n.Bitfield)
+		return toUint(bits)&toUint(bitfield) != 0
+	case *semantic.MapContains:
+		_, ok := s.eval(f, n.Map).(*Map).Entries[s.eval(f, n.Key)]
+		return ok
+	case *semantic.SliceContains:
+		v := s.eval(f, n.Value)
+		for _, e := range s.read(f.ctx, s.eval(f, n.Slice).(Slice), n.Type.To) {
+			if equal(e, v) {
+				return true
+			}
+		}
+		return false
+	case *semantic.Member:
+		return s.object(f, n.Object).Fields[n.Field]
+	case *semantic.MessageValue:
+		m := &Message{Identifier: n.AST.Name.Value, Arguments: map[string]Value{}}
+		for _, a := range n.Arguments {
+			m.Arguments[a.Field.Name()] = s.eval(f, a.Value)
+		}
+		return m
+	case *semantic.PointerRange:
+		p := s.eval(f, n.Pointer).(Pointer)
+		from, to := toUint(s.eval(f, n.Range.LHS)), toUint(s.eval(f, n.Range.RHS))
+		return s.sliceOf(p, n.Type.To, from, to)
+	case *semantic.SliceRange:
+		sl := s.eval(f, n.Slice).(Slice)
+		from, to := toUint(s.eval(f, n.Range.LHS)), toUint(s.eval(f, n.Range.RHS))
+		return s.subslice(sl, n.Type.To, from, to)
+	case *semantic.ArrayIndex:
+		a := s.eval(f, n.Array).(Array)
+		i := toUint(s.eval(f, n.Index))
+		if i >= uint64(len(a)) {
+			panic(failf("Array index %d out of bounds", i))
+		}
+		return a[i]
+	case *semantic.SliceIndex:
+		sl := s.eval(f, n.Slice).(Slice)
+		i := toUint(s.eval(f, n.Index))
+		return s.read(f.ctx, s.subslice(sl, n.Type.To, i, i+1), n.Type.To)[0]
+	case *semantic.MapIndex:
+		m := s.eval(f, n.Map).(*Map)
+		if v, ok := m.Entries[s.eval(f, n.Index)]; ok {
+			return v
+		}
+		return s.zero(n.Type.ValueType)
+	case *semantic.Length:
+		var l int
+		switch v := s.eval(f, n.Object).(type) {
+		case Slice:
+			return number(n.Type, v.Count)
+		case *Map:
+			l = len(v.Entries)
+		case string:
+			l = len(v)
+		case Array:
+			l = len(v)
+		default:
+			panic(failf("Cannot take the length of %T", v))
+		}
+		return number(n.Type, uint64(l))
+	case *semantic.Cast:
+		return s.cast(f, n.Object.ExpressionType(), n.Type, s.eval(f, n.Object))
+	case *semantic.Call:
+		return s.call(f, n)
+	case *semantic.Select:
+		v := s.eval(f, n.Value)
+		for _, c := range n.Choices {
+			for _, cond := range c.Conditions {
+				if equal(v, s.eval(f, cond)) {
+					return s.eval(f, c.Expression)
+				}
+			}
+		}
+		if n.Default == nil {
+			return s.zero(n.Type)
+		}
+		return s.eval(f, n.Default)
+	case *semantic.ArrayInitializer:
+		ty := semantic.Underlying(n.Array).(*semantic.StaticArray)
+		a := make(Array, len(n.Values))
+		for i, v := range n.Values {
+			a[i] = s.copy(ty.ValueType, s.eval(f, v))
+		}
+		return a
+	case *semantic.ClassInitializer:
+		return s.initialize(f, n)
+	case *semantic.Create:
+		return s.initialize(f, n.Initializer)
+	case *semantic.New:
+		return s.zero(n.Type.To)
+	case *semantic.Make:
+		return Slice{Count: toUint(s.eval(f, n.Size)), Pool: s.newPool()}
+	case *semantic.Clone:
+		return s.cloneSlice(s.eval(f, n.Slice).(Slice), n.Type.To)
+	case *semantic.Callable:
+		return n.Function
+	}
+	panic(failf("Unsupported expression %T", n))
+}
+
+// object returns the class instance of the expression n, which is either a
+// class value or a reference to a class.
+func (s *State) object(f *frame, n semantic.Expression) *Object {
+	o, ok := s.eval(f, n).(*Object)
+	if !ok || o == nil {
+		panic(failf("Member access on nil reference"))
+	}
+	return o
+}
+
+// initialize returns a new instance of the class of n.
+func (s *State) initialize(f *frame, n *semantic.ClassInitializer) *Object {
+	o := &Object{Class: n.Class, Fields: make(map[*semantic.Field]Value, len(n.Class.Fields))}
+	for i, v := range n.InitialValues() {
+		field := n.Class.Fields[i]
+		if v != nil {
+			o.Fields[field] = s.copy(field.Type, s.eval(f, v))
+		} else {
+			o.Fields[field] = s.zero(field.Type)
+		}
+	}
+	return o
+}
+
+// binaryOp returns the result of the binary operation n.
+func (s *State) binaryOp(f *frame, n *semantic.BinaryOp) Value {
+	switch n.Operator {
+	case ast.OpAnd:
+		return s.eval(f, n.LHS).(bool) && s.eval(f, n.RHS).(bool)
+	case ast.OpOr:
+		return s.eval(f, n.LHS).(bool) || s.eval(f, n.RHS).(bool)
+	}
+	lhs, rhs := s.eval(f, n.LHS), s.eval(f, n.RHS)
+	switch n.Operator {
+	case ast.OpEQ, ast.OpNE, ast.OpLT, ast.OpLE, ast.OpGT, ast.OpGE:
+		return compare(n.Operator, lhs, rhs)
+	case ast.OpPlus:
+		if l, ok := lhs.(string); ok {
+			return l + rhs.(string)
+		}
+	}
+	return arithmetic(n.Type, n.Operator, lhs, rhs)
+}
+
+// call calls the function targeted by n, returning the function's result.
+func (s *State) call(f *frame, n *semantic.Call) Value {
+	target := n.Target.Function
+	args := make([]Value, 0, len(n.Arguments)+1)
+	if n.Target.Object != nil {
+		args = append(args, s.eval(f, n.Target.Object))
+	}
+	for _, a := range n.Arguments {
+		args = append(args, s.eval(f, a))
+	}
+	if target.Extern {
+		e, ok := s.Externs[target.Name()]
+		if !ok {
+			panic(failf("Extern %s is not implemented", target.Name()))
+		}
+		v, err := e(f.ctx, s, args)
+		if err != nil {
+			if abort, ok := err.(ErrAborted); ok {
+				panic(abort)
+			}
+			panic(failure{err})
+		}
+		return v
+	}
+	if target.Block == nil {
+		panic(failf("Function %s has no body", target.Name()))
+	}
+	callee := s.frame(f.ctx, f.invocation)
+	callee.function = target
+	for i, p := range target.CallParameters() {
+		if p == target.This {
+			callee.params[p] = args[i]
+		} else {
+			callee.params[p] = s.copy(p.Type, args[i])
+		}
+	}
+	s.block(callee, target.Block)
+	return callee.result
+}
+
+// cast returns the value v of type from converted to the type to.
+func (s *State) cast(f *frame, from, to semantic.Type, v Value) Value {
+	src, dst := semantic.Underlying(from), semantic.Underlying(to)
+	switch src := src.(type) {
+	case *semantic.Pointer:
+		switch dst := dst.(type) {
+		case *semantic.Pointer:
+			return v
+		case *semantic.Builtin:
+			if dst == semantic.StringType {
+				return s.cstring(f.ctx, v.(Pointer))
+			}
+			return number(to, v.(Pointer).Address)
+		}
+	case *semantic.Slice:
+		sl := v.(Slice)
+		switch dst := dst.(type) {
+		case *semantic.Slice:
+			out := sl
+			out.Count = (sl.Count * s.sizeOf(src.To)) / s.sizeOf(dst.To)
+			return out
+		case *semantic.Pointer:
+			return Pointer{Address: sl.Base, Pool: sl.Pool}
+		case *semantic.Builtin:
+			if dst == semantic.StringType {
+				buf := make([]byte, 0, sl.Count)
+				for _, c := range s.read(f.ctx, sl, src.To) {
+					buf = append(buf, byte(toUint(c)))
+				}
+				return string(buf)
+			}
+		}
+	case *semantic.Builtin:
+		if src == semantic.StringType {
+			if _, ok := dst.(*semantic.Slice); ok {
+				str := v.(string)
+				id := s.newPool()
+				s.Memory[id].Write(0, memory.Blob([]byte(str)))
+				return Slice{Count: uint64(len(str)), Pool: id}
+			}
+			break
+		}
+		if _, ok := dst.(*semantic.Pointer); ok {
+			return Pointer{Address: toUint(v), Pool: memory.ApplicationPool}
+		}
+		return number(to, v)
+	case *semantic.Enum:
+		return number(to, v)
+	}
+	return s.copy(to, v)
+}
@@ -0,0 +1,227 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package interpreter executes the bodies of API functions directly from their
+// semantic tree, against a dynamic model of the API state and memory pools.
+//
+// The interpreter allows the semantics of commands to be tested from the .api
+// source without generating code, and allows the results of the generated
+// mutators to be checked against the interpreted results.
+package interpreter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/gapil/semantic"
+	"github.com/google/gapid/gapis/memory"
+)
+
+// ErrAborted is the error returned by Execute when the execution of the
+// command was terminated by a call to the abort() intrinsic.
+type ErrAborted string
+
+func (e ErrAborted) Error() string {
+	return fmt.Sprintf("aborted(%s)", string(e))
+}
+
+// IsAbortedError returns true if err is an ErrAborted.
+func IsAbortedError(err error) bool {
+	_, ok := err.(ErrAborted)
+	return ok
+}
+
+// failure is the panic value used to unwind the interpreter when the execution
+// of a command fails. Execute recovers failures and returns their error.
+type failure struct{ err error }
+
+// failf returns a failure with an error formatted from format and args.
+func failf(format string, args ...interface{}) failure {
+	return failure{fmt.Errorf(format, args...)}
+}
+
+// Extern is the implementation of an extern function.
+type Extern func(ctx context.Context, s *State, args []Value) (Value, error)
+
+// Observation is a block of memory in the application pool that was observed
+// by a command.
+type Observation struct {
+	Address uint64
+	Data    []byte
+}
+
+// Invocation is a single call to a command.
+type Invocation struct {
+	// Function is the command being called.
+	Function *semantic.Function
+	// Arguments are the values of the call parameters, in order.
+	Arguments []Value
+	// Result is the value returned by the command. It is the value of the
+	// observed return parameter.
+	Result Value
+	// Reads are the observations applied before the command is executed.
+	Reads []Observation
+	// Writes are the observations applied at the fence of the command.
+	Writes []Observation
+}
+
+// State is the dynamic state of an API.
+type State struct {
+	// API is the API being interpreted.
+	API *semantic.API
+	// MemoryLayout is the layout of the data in memory.
+	MemoryLayout *device.MemoryLayout
+	// Globals are the values of the API's global variables.
+	Globals map[*semantic.Global]Value
+	// Memory are the memory pools.
+	Memory memory.Pools
+	// NextPoolID is the identifier of the next pool to be created.
+	NextPoolID memory.PoolID
+	// Externs are the implementations of the extern functions, keyed by name.
+	Externs map[string]Extern
+}
+
+// New returns a new state for api, with all the globals holding their initial
+// values.
+func New(api *semantic.API, layout *device.MemoryLayout) *State {
+	s := &State{
+		API:          api,
+		MemoryLayout: layout,
+		Globals:      map[*semantic.Global]Value{},
+		Memory:       memory.Pools{memory.ApplicationPool: {}},
+		NextPoolID:   memory.ApplicationPool + 1,
+		Externs:      map[string]Extern{},
+	}
+	f := s.frame(context.Background(), nil)
+	for _, g := range api.Globals {
+		if g.Default != nil {
+			s.Globals[g] = s.copy(g.Type, s.eval(f, g.Default))
+		} else {
+			s.Globals[g] = s.zero(g.Type)
+		}
+	}
+	return s
+}
+
+// Global returns the value of the global with the given name, or nil if there
+// is no global with that name.
+func (s *State) Global(name string) Value {
+	for _, g := range s.API.Globals {
+		if g.Name() == name {
+			return s.Globals[g]
+		}
+	}
+	return nil
+}
+
+// Call executes the command or subroutine with the given name with the
+// arguments args.
+func (s *State) Call(ctx context.Context, name string, args ...Value) (Value, error) {
+	for _, l := range [][]*semantic.Function{s.API.Functions, s.API.Subroutines} {
+		for _, f := range l {
+			if f.Name() == name {
+				return s.Execute(ctx, &Invocation{Function: f, Arguments: args})
+			}
+		}
+	}
+	return nil, fmt.Errorf("Function %s not found", name)
+}
+
+// Execute executes the invocation, returning the value returned by the
+// function. If the function was aborted then the returned error is an
+// ErrAborted. Panics that are not raised by the interpreter, such as runtime
+// errors, are not recovered.
+func (s *State) Execute(ctx context.Context, i *Invocation) (result Value, err error) {
+	defer func() {
+		switch r := recover().(type) {
+		case nil:
+		case ErrAborted:
+			result, err = nil, r
+		case failure:
+			result, err = nil, r.err
+		default:
+			panic(r)
+		}
+	}()
+	f := i.Function
+	if f.Block == nil {
+		return nil, fmt.Errorf("Function %s has no body", f.Name())
+	}
+	params := f.CallParameters()
+	if len(i.Arguments) != len(params) {
+		return nil, fmt.Errorf("Function %s expects %d arguments, got %d",
+			f.Name(), len(params), len(i.Arguments))
+	}
+	fr := s.frame(ctx, i)
+	fr.function = f
+	for j, p := range params {
+		fr.params[p] = s.argument(p.Type, i.Arguments[j])
+	}
+	if f.Return.Type != semantic.VoidType {
+		fr.params[f.Return] = s.argument(f.Return.Type, i.Result)
+	}
+	s.observe(i.Reads)
+	s.block(fr, f.Block)
+	return fr.result, nil
+}
+
+// argument returns the argument v converted to a value of type ty.
+// Go numeric values of any type may be used for numeric types, and nil may
+// be used for the default value of any type.
+func (s *State) argument(ty semantic.Type, v Value) Value {
+	if v == nil {
+		return s.zero(ty)
+	}
+	switch semantic.Underlying(ty).(type) {
+	case *semantic.Builtin, *semantic.Enum:
+		switch v := v.(type) {
+		case int:
+			return number(ty, int64(v))
+		case uint:
+			return number(ty, uint64(v))
+		}
+		if isNumber(v) {
+			return number(ty, v)
+		}
+	}
+	return s.copy(ty, v)
+}
+
+// observe applies the observations to the application pool.
+func (s *State) observe(l []Observation) {
+	for _, o := range l {
+		s.Memory[memory.ApplicationPool].Write(o.Address, memory.Blob(o.Data))
+	}
+}
+
+// frame holds the state of a single function call.
+type frame struct {
+	ctx        context.Context
+	invocation *Invocation
+	function   *semantic.Function
+	locals     map[*semantic.Local]Value
+	params     map[*semantic.Parameter]Value
+	result     Value
+	returned   bool
+}
+
+func (s *State) frame(ctx context.Context, i *Invocation) *frame {
+	return &frame{
+		ctx:        ctx,
+		invocation: i,
+		locals:     map[*semantic.Local]Value{},
+		params:     map[*semantic.Parameter]Value{},
+	}
+}
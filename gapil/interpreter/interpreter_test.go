@@ -0,0 +1,316 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter_test
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/gapil"
+	"github.com/google/gapid/gapil/ast"
+	"github.com/google/gapid/gapil/interpreter"
+	"github.com/google/gapid/gapil/parser"
+	"github.com/google/gapid/gapil/resolver"
+	"github.com/google/gapid/gapil/semantic"
+	"github.com/google/gapid/gapis/memory"
+)
+
+const source = `
+class Obj {
+  u32 A
+  u32 B
+}
+
+enum E {
+  X = 1
+  Y = 2
+}
+
+u32 Counter = 5
+map!(u32, Obj) Objects
+ref!Obj Current
+E Last
+
+sub u32 double(u32 x) { return x * 2 }
+
+sub void check(u32 x) {
+  if x > 10 { abort }
+}
+
+cmd void inc(u32 by) { Counter += by }
+
+cmd void add(u32 id, u32 a) { Objects[id] = Obj(A: a, B: double(a)) }
+
+cmd void remove(u32 id) { delete(Objects, id) }
+
+cmd void setB(u32 id, u32 b) {
+  o := Objects[id]
+  o.B = b
+  Objects[id] = o
+}
+
+cmd void limited(u32 x) {
+  check(x)
+  Counter = x
+}
+
+cmd void create(u32 a) {
+  Current = new!Obj(A: a)
+  r := Current
+  r.B = a + 1
+}
+
+cmd void choose(E e) {
+  Last = e
+  Counter = switch e {
+    case X: 10
+    case Y: 20
+    default: 30
+  }
+}
+
+cmd void fill(u32* ptr, u32 count) {
+  s := ptr[0:count]
+  for i in (0 .. count) {
+    s[i] = i * 3
+  }
+}
+
+cmd void sum(const u32* ptr, u32 count) {
+  s := ptr[0:count]
+  read(s)
+  Counter = 0
+  for i in (0 .. count) {
+    Counter += s[i]
+  }
+}
+
+cmd void sumObjects() {
+  Counter = 0
+  for _, _, o in Objects {
+    Counter += o.A
+  }
+}
+
+cmd void lastIndex() {
+  for i, _, _ in Objects {
+    Counter = as!u32(i)
+  }
+}
+
+extern u32 external(u32 x)
+
+cmd void callExternal(u32 x) { Counter = external(x) }
+
+cmd void name(const char* str) {
+  if as!string(str) == "hello" {
+    Counter = 1
+  } else {
+    Counter = 2
+  }
+}
+
+cmd void duplicate(const u32* ptr, u32 count) {
+  s := clone(ptr[0:count])
+  Counter = s[count - 1]
+}
+
+cmd u32 result() {
+  Counter += 1
+  return ?
+}
+
+cmd void output(u32* ptr) {
+  fence
+  Counter = ptr[0]
+}
+`
+
+func compile(ctx context.Context, source string) (*semantic.API, error) {
+	const maxErrors = 10
+	mappings := resolver.NewMappings()
+	parsed, errs := parser.Parse("interpreter_test.api", source, mappings)
+	if err := gapil.CheckErrors(source, errs, maxErrors); err != nil {
+		return nil, err
+	}
+	compiled, errs := resolver.Resolve([]*ast.API{parsed}, nil, mappings)
+	if err := gapil.CheckErrors(source, errs, maxErrors); err != nil {
+		return nil, err
+	}
+	return compiled, nil
+}
+
+func field(v interpreter.Value, name string) interpreter.Value {
+	return v.(*interpreter.Object).Get(name)
+}
+
+func TestInterpreter(t *testing.T) {
+	ctx := log.Testing(t)
+	api, err := compile(ctx, source)
+	if !assert.With(ctx).ThatError(err).Succeeded() {
+		return
+	}
+	s := interpreter.New(api, device.Little32)
+
+	call := func(name string, args ...interpreter.Value) {
+		_, err := s.Call(ctx, name, args...)
+		assert.For(ctx, "%s error", name).ThatError(err).Succeeded()
+	}
+	counter := func() interpreter.Value { return s.Global("Counter") }
+
+	assert.For(ctx, "initial Counter").That(counter()).Equals(uint32(5))
+	call("inc", 3)
+	assert.For(ctx, "Counter after inc").That(counter()).Equals(uint32(8))
+
+	call("add", 1, 7)
+	call("add", 2, 9)
+	objects := s.Global("Objects").(*interpreter.Map)
+	assert.For(ctx, "Objects count").That(len(objects.Entries)).Equals(2)
+	assert.For(ctx, "Objects[1].B").That(field(objects.Entries[uint32(1)], "B")).Equals(uint32(14))
+
+	call("setB", 1, 3)
+	assert.For(ctx, "Objects[1].B after setB").That(field(objects.Entries[uint32(1)], "B")).Equals(uint32(3))
+	call("sumObjects")
+	assert.For(ctx, "Counter after sumObjects").That(counter()).Equals(uint32(16))
+	call("remove", 2)
+	assert.For(ctx, "Objects count after remove").That(len(objects.Entries)).Equals(1)
+
+	_, err = s.Call(ctx, "limited", 20)
+	assert.For(ctx, "limited(20) aborted").That(interpreter.IsAbortedError(err)).Equals(true)
+	assert.For(ctx, "Counter after abort").That(counter()).Equals(uint32(16))
+	call("limited", 4)
+	assert.For(ctx, "Counter after limited").That(counter()).Equals(uint32(4))
+
+	call("create", 6)
+	current := s.Global("Current")
+	assert.For(ctx, "Current.A").That(field(current, "A")).Equals(uint32(6))
+	assert.For(ctx, "Current.B").That(field(current, "B")).Equals(uint32(7))
+
+	call("choose", 2)
+	assert.For(ctx, "Counter after choose").That(counter()).Equals(uint32(20))
+	assert.For(ctx, "Last").That(s.Global("Last")).Equals(uint32(2))
+
+	call("add", 3, 1)
+	call("lastIndex")
+	assert.For(ctx, "Counter after lastIndex").That(counter()).Equals(uint32(1))
+}
+
+func TestInterpreterMemory(t *testing.T) {
+	ctx := log.Testing(t)
+	api, err := compile(ctx, source)
+	if !assert.With(ctx).ThatError(err).Succeeded() {
+		return
+	}
+	s := interpreter.New(api, device.Little32)
+	p := interpreter.Pointer{Address: 0x1000, Pool: memory.ApplicationPool}
+
+	_, err = s.Call(ctx, "fill", p, 4)
+	assert.For(ctx, "fill error").ThatError(err).Succeeded()
+	buf := make([]byte, 16)
+	s.Memory[memory.ApplicationPool].Slice(memory.Range{Base: 0x1000, Size: 16}).Get(ctx, 0, buf)
+	assert.For(ctx, "memory").ThatSlice(buf).Equals([]byte{
+		0, 0, 0, 0, 3, 0, 0, 0, 6, 0, 0, 0, 9, 0, 0, 0,
+	})
+
+	_, err = s.Call(ctx, "sum", p, 4)
+	assert.For(ctx, "sum error").ThatError(err).Succeeded()
+	assert.For(ctx, "sum").That(s.Global("Counter")).Equals(uint32(18))
+
+	_, err = s.Call(ctx, "duplicate", p, 3)
+	assert.For(ctx, "duplicate error").ThatError(err).Succeeded()
+	assert.For(ctx, "duplicate").That(s.Global("Counter")).Equals(uint32(6))
+	assert.For(ctx, "pools").That(len(s.Memory)).Equals(2)
+
+	s.Memory[memory.ApplicationPool].Write(0x2000, memory.Blob([]byte("hello\x00")))
+	_, err = s.Call(ctx, "name", interpreter.Pointer{Address: 0x2000, Pool: memory.ApplicationPool})
+	assert.For(ctx, "name error").ThatError(err).Succeeded()
+	assert.For(ctx, "name").That(s.Global("Counter")).Equals(uint32(1))
+}
+
+func TestInterpreterObservations(t *testing.T) {
+	ctx := log.Testing(t)
+	api, err := compile(ctx, source)
+	if !assert.With(ctx).ThatError(err).Succeeded() {
+		return
+	}
+	s := interpreter.New(api, device.Little32)
+	function := func(name string) *semantic.Function {
+		for _, f := range api.Functions {
+			if f.Name() == name {
+				return f
+			}
+		}
+		return nil
+	}
+
+	result, err := s.Execute(ctx, &interpreter.Invocation{
+		Function: function("result"),
+		Result:   uint32(42),
+	})
+	assert.For(ctx, "result error").ThatError(err).Succeeded()
+	assert.For(ctx, "result").That(result).Equals(uint32(42))
+	assert.For(ctx, "Counter after result").That(s.Global("Counter")).Equals(uint32(6))
+
+	_, err = s.Execute(ctx, &interpreter.Invocation{
+		Function:  function("output"),
+		Arguments: []interpreter.Value{interpreter.Pointer{Address: 0x100, Pool: memory.ApplicationPool}},
+		Writes:    []interpreter.Observation{{Address: 0x100, Data: []byte{7, 0, 0, 0}}},
+	})
+	assert.For(ctx, "output error").ThatError(err).Succeeded()
+	assert.For(ctx, "Counter after output").That(s.Global("Counter")).Equals(uint32(7))
+}
+
+func TestInterpreterErrors(t *testing.T) {
+	ctx := log.Testing(t)
+	api, err := compile(ctx, source)
+	if !assert.With(ctx).ThatError(err).Succeeded() {
+		return
+	}
+	s := interpreter.New(api, device.Little32)
+
+	_, err = s.Call(ctx, "callExternal", 1)
+	assert.For(ctx, "unimplemented extern").ThatError(err).Failed()
+
+	failed := errors.New("extern failed")
+	s.Externs["external"] = func(ctx context.Context, s *interpreter.State, args []interpreter.Value) (interpreter.Value, error) {
+		return nil, failed
+	}
+	_, err = s.Call(ctx, "callExternal", 1)
+	assert.For(ctx, "extern error").ThatError(err).Equals(failed)
+
+	s.Externs["external"] = func(ctx context.Context, s *interpreter.State, args []interpreter.Value) (interpreter.Value, error) {
+		return nil, interpreter.ErrAborted("external")
+	}
+	_, err = s.Call(ctx, "callExternal", 1)
+	assert.For(ctx, "extern abort").That(interpreter.IsAbortedError(err)).Equals(true)
+
+	s.Externs["external"] = func(ctx context.Context, s *interpreter.State, args []interpreter.Value) (interpreter.Value, error) {
+		var m map[uint32]uint32
+		m[0] = 1
+		return uint32(0), nil
+	}
+	var recovered interface{}
+	func() {
+		defer func() { recovered = recover() }()
+		s.Call(ctx, "callExternal", 1)
+	}()
+	_, isRuntimeError := recovered.(runtime.Error)
+	assert.For(ctx, "runtime error panics").That(isRuntimeError).Equals(true)
+}
@@ -0,0 +1,346 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/google/gapid/core/data/endian"
+	"github.com/google/gapid/core/math/u64"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/gapil/semantic"
+	"github.com/google/gapid/gapis/memory"
+)
+
+// layout returns the data layout of the builtin type ty.
+func (s *State) layout(ty *semantic.Builtin) *device.DataTypeLayout {
+	l := s.MemoryLayout
+	switch ty {
+	case semantic.BoolType, semantic.Int8Type, semantic.Uint8Type:
+		return l.GetI8()
+	case semantic.CharType:
+		return l.GetChar()
+	case semantic.Int16Type, semantic.Uint16Type:
+		return l.GetI16()
+	case semantic.Int32Type, semantic.Uint32Type:
+		return l.GetI32()
+	case semantic.Int64Type, semantic.Uint64Type:
+		return l.GetI64()
+	case semantic.IntType, semantic.UintType:
+		return l.GetInteger()
+	case semantic.SizeType:
+		return l.GetSize()
+	case semantic.Float32Type:
+		return l.GetF32()
+	case semantic.Float64Type:
+		return l.GetF64()
+	}
+	panic(failf("Type %v cannot be stored in memory", ty.Name()))
+}
+
+// alignOf returns the byte alignment of the type ty in memory.
+func (s *State) alignOf(ty semantic.Type) uint64 {
+	switch ty := semantic.Underlying(ty).(type) {
+	case *semantic.Builtin:
+		if ty == semantic.VoidType {
+			return 1
+		}
+		return uint64(s.layout(ty).GetAlignment())
+	case *semantic.Enum:
+		return uint64(s.MemoryLayout.GetI32().GetAlignment())
+	case *semantic.Pointer:
+		return uint64(s.MemoryLayout.GetPointer().GetAlignment())
+	case *semantic.StaticArray:
+		return s.alignOf(ty.ValueType)
+	case *semantic.Class:
+		alignment := uint64(1)
+		for _, f := range ty.Fields {
+			alignment = u64.Max(alignment, s.alignOf(f.Type))
+		}
+		return alignment
+	}
+	panic(failf("Type %v cannot be stored in memory", typename(ty)))
+}
+
+// sizeOf returns the byte size of the type ty in memory.
+func (s *State) sizeOf(ty semantic.Type) uint64 {
+	switch ty := semantic.Underlying(ty).(type) {
+	case *semantic.Builtin:
+		if ty == semantic.VoidType {
+			return 1
+		}
+		return uint64(s.layout(ty).GetSize())
+	case *semantic.Enum:
+		return uint64(s.MemoryLayout.GetI32().GetSize())
+	case *semantic.Pointer:
+		return uint64(s.MemoryLayout.GetPointer().GetSize())
+	case *semantic.StaticArray:
+		return s.sizeOf(ty.ValueType) * uint64(ty.Size)
+	case *semantic.Class:
+		var size, align uint64
+		for _, f := range ty.Fields {
+			a := s.alignOf(f.Type)
+			size = u64.AlignUp(size, a) + s.sizeOf(f.Type)
+			align = u64.Max(align, a)
+		}
+		return u64.AlignUp(size, align)
+	}
+	panic(failf("Type %v cannot be stored in memory", typename(ty)))
+}
+
+// sliceOf returns the slice of count elements of type el starting at p.
+func (s *State) sliceOf(p Pointer, el semantic.Type, from, to uint64) Slice {
+	if to < from {
+		panic(failf("Invalid range [%d:%d]", from, to))
+	}
+	size := s.sizeOf(el)
+	return Slice{Root: p.Address, Base: p.Address + from*size, Count: to - from, Pool: p.Pool}
+}
+
+// subslice returns the elements [from, to) of the slice sl of elements of
+// type el.
+func (s *State) subslice(sl Slice, el semantic.Type, from, to uint64) Slice {
+	if to < from || to > sl.Count {
+		panic(failf("Slice index out of bounds [%d:%d] for slice of %d elements", from, to, sl.Count))
+	}
+	size := s.sizeOf(el)
+	return Slice{Root: sl.Root, Base: sl.Base + from*size, Count: to - from, Pool: sl.Pool}
+}
+
+// pool returns the pool with the given identifier.
+func (s *State) pool(id memory.PoolID) *memory.Pool {
+	p, ok := s.Memory[id]
+	if !ok {
+		panic(failf("Pool %d does not exist", id))
+	}
+	return p
+}
+
+// newPool creates a new pool, returning its identifier.
+func (s *State) newPool() memory.PoolID {
+	id := s.NextPoolID
+	s.Memory[id] = &memory.Pool{}
+	s.NextPoolID++
+	return id
+}
+
+// rangeOf returns the memory range of the slice sl of elements of type el.
+func (s *State) rangeOf(sl Slice, el semantic.Type) memory.Range {
+	return memory.Range{Base: sl.Base, Size: sl.Count * s.sizeOf(el)}
+}
+
+// load reads the element of type ty at addr in the given pool.
+func (s *State) load(ctx context.Context, pool memory.PoolID, addr uint64, ty semantic.Type) Value {
+	buf := make([]byte, s.sizeOf(ty))
+	data := s.pool(pool).Slice(memory.Range{Base: addr, Size: uint64(len(buf))})
+	if err := data.Get(ctx, 0, buf); err != nil {
+		panic(failure{err})
+	}
+	d := memory.NewDecoder(endian.Reader(bytes.NewReader(buf), s.MemoryLayout.GetEndian()), s.MemoryLayout)
+	v := s.decode(d, ty)
+	if err := d.Error(); err != nil {
+		panic(failure{err})
+	}
+	return v
+}
+
+// store writes the value v of type ty to addr in the given pool.
+func (s *State) store(pool memory.PoolID, addr uint64, ty semantic.Type, v Value) {
+	buf := &bytes.Buffer{}
+	e := memory.NewEncoder(endian.Writer(buf, s.MemoryLayout.GetEndian()), s.MemoryLayout)
+	s.encode(e, ty, v)
+	if err := e.Error(); err != nil {
+		panic(failure{err})
+	}
+	s.pool(pool).Write(addr, memory.Blob(buf.Bytes()))
+}
+
+func (s *State) decode(d *memory.Decoder, ty semantic.Type) Value {
+	switch ty := semantic.Underlying(ty).(type) {
+	case *semantic.Builtin:
+		switch ty {
+		case semantic.BoolType:
+			return d.Bool()
+		case semantic.CharType:
+			return uint8(d.Char())
+		case semantic.Int8Type:
+			return d.I8()
+		case semantic.Uint8Type, semantic.VoidType:
+			return d.U8()
+		case semantic.Int16Type:
+			return d.I16()
+		case semantic.Uint16Type:
+			return d.U16()
+		case semantic.Int32Type:
+			return d.I32()
+		case semantic.Uint32Type:
+			return d.U32()
+		case semantic.Int64Type:
+			return d.I64()
+		case semantic.Uint64Type:
+			return d.U64()
+		case semantic.IntType:
+			return int64(d.Int())
+		case semantic.UintType:
+			return uint64(d.Uint())
+		case semantic.SizeType:
+			return uint64(d.Size())
+		case semantic.Float32Type:
+			return d.F32()
+		case semantic.Float64Type:
+			return d.F64()
+		}
+	case *semantic.Enum:
+		return d.U32()
+	case *semantic.Pointer:
+		return Pointer{Address: d.Pointer(), Pool: memory.ApplicationPool}
+	case *semantic.StaticArray:
+		a := make(Array, ty.Size)
+		for i := range a {
+			a[i] = s.decode(d, ty.ValueType)
+		}
+		return a
+	case *semantic.Class:
+		d.Align(s.alignOf(ty))
+		o := &Object{Class: ty, Fields: make(map[*semantic.Field]Value, len(ty.Fields))}
+		for _, f := range ty.Fields {
+			o.Fields[f] = s.decode(d, f.Type)
+		}
+		d.Align(s.alignOf(ty))
+		return o
+	}
+	panic(failf("Type %v cannot be loaded from memory", typename(ty)))
+}
+
+func (s *State) encode(e *memory.Encoder, ty semantic.Type, v Value) {
+	switch ty := semantic.Underlying(ty).(type) {
+	case *semantic.Builtin:
+		switch ty {
+		case semantic.BoolType:
+			e.Bool(v.(bool))
+		case semantic.CharType:
+			e.Char(memory.Char(toUint(v)))
+		case semantic.Int8Type:
+			e.I8(int8(toInt(v)))
+		case semantic.Uint8Type, semantic.VoidType:
+			e.U8(uint8(toUint(v)))
+		case semantic.Int16Type:
+			e.I16(int16(toInt(v)))
+		case semantic.Uint16Type:
+			e.U16(uint16(toUint(v)))
+		case semantic.Int32Type:
+			e.I32(int32(toInt(v)))
+		case semantic.Uint32Type:
+			e.U32(uint32(toUint(v)))
+		case semantic.Int64Type:
+			e.I64(toInt(v))
+		case semantic.Uint64Type:
+			e.U64(toUint(v))
+		case semantic.IntType:
+			e.Int(memory.Int(toInt(v)))
+		case semantic.UintType:
+			e.Uint(memory.Uint(toUint(v)))
+		case semantic.SizeType:
+			e.Size(memory.Size(toUint(v)))
+		case semantic.Float32Type:
+			e.F32(float32(toFloat(v)))
+		case semantic.Float64Type:
+			e.F64(toFloat(v))
+		default:
+			panic(failf("Type %v cannot be stored in memory", ty.Name()))
+		}
+	case *semantic.Enum:
+		e.U32(uint32(toUint(v)))
+	case *semantic.Pointer:
+		e.Pointer(v.(Pointer).Address)
+	case *semantic.StaticArray:
+		for _, el := range v.(Array) {
+			s.encode(e, ty.ValueType, el)
+		}
+	case *semantic.Class:
+		e.Align(s.alignOf(ty))
+		o := v.(*Object)
+		for _, f := range ty.Fields {
+			s.encode(e, f.Type, o.Fields[f])
+		}
+		e.Align(s.alignOf(ty))
+	default:
+		panic(failf("Type %v cannot be stored in memory", typename(ty)))
+	}
+}
+
+// read returns the elements of the slice sl of elements of type el.
+func (s *State) read(ctx context.Context, sl Slice, el semantic.Type) []Value {
+	if f := s.pool(sl.Pool).OnRead; f != nil {
+		f(s.rangeOf(sl, el))
+	}
+	size := s.sizeOf(el)
+	out := make([]Value, sl.Count)
+	for i := range out {
+		out[i] = s.load(ctx, sl.Pool, sl.Base+uint64(i)*size, el)
+	}
+	return out
+}
+
+// write writes the values to the elements of the slice sl of elements of
+// type el.
+func (s *State) write(sl Slice, el semantic.Type, values []Value) {
+	size := s.sizeOf(el)
+	count := u64.Min(sl.Count, uint64(len(values)))
+	for i := uint64(0); i < count; i++ {
+		s.store(sl.Pool, sl.Base+i*size, el, values[i])
+	}
+	if f := s.pool(sl.Pool).OnWrite; f != nil {
+		f(memory.Range{Base: sl.Base, Size: count * size})
+	}
+}
+
+// copySlice copies the elements of src to dst, both slices of elements of
+// type el. The number of elements copied is the minimum of the two counts.
+func (s *State) copySlice(dst, src Slice, el semantic.Type) {
+	count := u64.Min(dst.Count, src.Count)
+	dst, src = s.subslice(dst, el, 0, count), s.subslice(src, el, 0, count)
+	if f := s.pool(src.Pool).OnRead; f != nil {
+		f(s.rangeOf(src, el))
+	}
+	s.pool(dst.Pool).Write(dst.Base, s.pool(src.Pool).Slice(s.rangeOf(src, el)))
+	if f := s.pool(dst.Pool).OnWrite; f != nil {
+		f(s.rangeOf(dst, el))
+	}
+}
+
+// cloneSlice returns a copy of the slice sl of elements of type el in a new
+// pool.
+func (s *State) cloneSlice(sl Slice, el semantic.Type) Slice {
+	if f := s.pool(sl.Pool).OnRead; f != nil {
+		f(s.rangeOf(sl, el))
+	}
+	id := s.newPool()
+	s.Memory[id].Write(0, s.pool(sl.Pool).Slice(s.rangeOf(sl, el)))
+	return Slice{Count: sl.Count, Pool: id}
+}
+
+// cstring returns the null-terminated string starting at p.
+func (s *State) cstring(ctx context.Context, p Pointer) string {
+	buf := []byte{}
+	for addr := p.Address; ; addr++ {
+		c := s.load(ctx, p.Pool, addr, semantic.CharType).(uint8)
+		if c == 0 {
+			return string(buf)
+		}
+		buf = append(buf, c)
+	}
+}
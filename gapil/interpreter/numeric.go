@@ -0,0 +1,303 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"github.com/google/gapid/gapil/ast"
+	"github.com/google/gapid/gapil/semantic"
+)
+
+func isSigned(v Value) bool {
+	switch v.(type) {
+	case int8, int16, int32, int64:
+		return true
+	}
+	return false
+}
+
+func isFloat(v Value) bool {
+	switch v.(type) {
+	case float32, float64:
+		return true
+	}
+	return false
+}
+
+func isNumber(v Value) bool {
+	switch v.(type) {
+	case int8, int16, int32, int64, uint8, uint16, uint32, uint64, float32, float64:
+		return true
+	}
+	return false
+}
+
+func toInt(v Value) int64 {
+	switch v := v.(type) {
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	case float32:
+		return int64(v)
+	case float64:
+		return int64(v)
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	}
+	return int64(toUint(v))
+}
+
+func toUint(v Value) uint64 {
+	switch v := v.(type) {
+	case uint8:
+		return uint64(v)
+	case uint16:
+		return uint64(v)
+	case uint32:
+		return uint64(v)
+	case uint64:
+		return v
+	case int8, int16, int32, int64:
+		return uint64(toInt(v))
+	case float32:
+		return uint64(v)
+	case float64:
+		return uint64(v)
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	case Pointer:
+		return v.Address
+	}
+	panic(failf("%T %v is not a number", v, v))
+}
+
+func toFloat(v Value) float64 {
+	switch v := v.(type) {
+	case float32:
+		return float64(v)
+	case float64:
+		return v
+	case int8, int16, int32, int64:
+		return float64(toInt(v))
+	}
+	return float64(toUint(v))
+}
+
+// number returns the number v converted to the builtin, enum or pseudonym
+// type ty.
+func number(ty semantic.Type, v Value) Value {
+	switch ty := semantic.Underlying(ty).(type) {
+	case *semantic.Enum:
+		return uint32(toUint(v))
+	case *semantic.Builtin:
+		switch ty {
+		case semantic.BoolType:
+			return toUint(v) != 0
+		case semantic.Int8Type:
+			return int8(toInt(v))
+		case semantic.Uint8Type, semantic.CharType:
+			return uint8(toUint(v))
+		case semantic.Int16Type:
+			return int16(toInt(v))
+		case semantic.Uint16Type:
+			return uint16(toUint(v))
+		case semantic.Int32Type:
+			return int32(toInt(v))
+		case semantic.Uint32Type:
+			return uint32(toUint(v))
+		case semantic.Int64Type, semantic.IntType:
+			return toInt(v)
+		case semantic.Uint64Type, semantic.UintType, semantic.SizeType:
+			return toUint(v)
+		case semantic.Float32Type:
+			return float32(toFloat(v))
+		case semantic.Float64Type:
+			return toFloat(v)
+		}
+	}
+	panic(failf("%v is not a numeric type", typename(ty)))
+}
+
+// arithmetic returns the result of the arithmetic or bitwise operator op
+// applied to a and b, as a value of type ty.
+func arithmetic(ty semantic.Type, op string, a, b Value) Value {
+	switch {
+	case isFloat(a) || isFloat(b):
+		x, y := toFloat(a), toFloat(b)
+		switch op {
+		case ast.OpPlus:
+			return number(ty, x+y)
+		case ast.OpMinus:
+			return number(ty, x-y)
+		case ast.OpMultiply:
+			return number(ty, x*y)
+		case ast.OpDivide:
+			return number(ty, x/y)
+		}
+	case isSigned(a):
+		x, y := toInt(a), toInt(b)
+		switch op {
+		case ast.OpPlus:
+			return number(ty, x+y)
+		case ast.OpMinus:
+			return number(ty, x-y)
+		case ast.OpMultiply:
+			return number(ty, x*y)
+		case ast.OpDivide:
+			if y == 0 {
+				panic(failf("Integer divide by zero"))
+			}
+			return number(ty, x/y)
+		case ast.OpBitwiseAnd:
+			return number(ty, x&y)
+		case ast.OpBitwiseOr:
+			return number(ty, x|y)
+		case ast.OpBitShiftLeft:
+			return number(ty, x<<uint64(y))
+		case ast.OpBitShiftRight:
+			return number(ty, x>>uint64(y))
+		}
+	default:
+		x, y := toUint(a), toUint(b)
+		switch op {
+		case ast.OpPlus:
+			return number(ty, x+y)
+		case ast.OpMinus:
+			return number(ty, x-y)
+		case ast.OpMultiply:
+			return number(ty, x*y)
+		case ast.OpDivide:
+			if y == 0 {
+				panic(failf("Integer divide by zero"))
+			}
+			return number(ty, x/y)
+		case ast.OpBitwiseAnd:
+			return number(ty, x&y)
+		case ast.OpBitwiseOr:
+			return number(ty, x|y)
+		case ast.OpBitShiftLeft:
+			return number(ty, x<<y)
+		case ast.OpBitShiftRight:
+			return number(ty, x>>y)
+		}
+	}
+	panic(failf("Unsupported operator %s for %T and %T", op, a, b))
+}
+
+// compare returns the result of the comparison operator op applied to a and b.
+func compare(op string, a, b Value) bool {
+	if op == ast.OpEQ {
+		return equal(a, b)
+	}
+	if op == ast.OpNE {
+		return !equal(a, b)
+	}
+	var c int
+	switch {
+	case isNumber(a) && isNumber(b):
+		switch {
+		case isFloat(a) || isFloat(b):
+			c = sign(toFloat(a) - toFloat(b))
+		case isSigned(a) || isSigned(b):
+			x, y := toInt(a), toInt(b)
+			if x < y {
+				c = -1
+			} else if x > y {
+				c = 1
+			}
+		default:
+			x, y := toUint(a), toUint(b)
+			if x < y {
+				c = -1
+			} else if x > y {
+				c = 1
+			}
+		}
+	default:
+		a, aok := a.(string)
+		b, bok := b.(string)
+		if !aok || !bok {
+			panic(failf("Unsupported operator %s for %T and %T", op, a, b))
+		}
+		if a < b {
+			c = -1
+		} else if a > b {
+			c = 1
+		}
+	}
+	switch op {
+	case ast.OpLT:
+		return c < 0
+	case ast.OpLE:
+		return c <= 0
+	case ast.OpGT:
+		return c > 0
+	case ast.OpGE:
+		return c >= 0
+	}
+	panic(failf("Unsupported comparison operator %s", op))
+}
+
+// equal returns true if a and b are equal values.
+func equal(a, b Value) bool {
+	if isNumber(a) && isNumber(b) {
+		switch {
+		case isFloat(a) || isFloat(b):
+			return toFloat(a) == toFloat(b)
+		case isSigned(a) && isSigned(b):
+			return toInt(a) == toInt(b)
+		}
+		return toUint(a) == toUint(b)
+	}
+	if x, ok := a.(Array); ok {
+		y, ok := b.(Array)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	if o, ok := a.(*Object); ok && o == nil {
+		a = nil
+	}
+	if o, ok := b.(*Object); ok && o == nil {
+		b = nil
+	}
+	return a == b
+}
+
+func sign(f float64) int {
+	switch {
+	case f < 0:
+		return -1
+	case f > 0:
+		return 1
+	}
+	return 0
+}
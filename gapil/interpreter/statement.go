@@ -0,0 +1,183 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"github.com/google/gapid/gapil/ast"
+	"github.com/google/gapid/gapil/semantic"
+)
+
+// block executes the statements of b, stopping early if the function returns.
+func (s *State) block(f *frame, b *semantic.Block) {
+	for _, n := range b.Statements {
+		if s.statement(f, n); f.returned {
+			return
+		}
+	}
+}
+
+// statement executes the single statement n.
+func (s *State) statement(f *frame, n semantic.Statement) {
+	switch n := n.(type) {
+	case *semantic.Block:
+		s.block(f, n)
+	case *semantic.DeclareLocal:
+		f.locals[n.Local] = s.copy(n.Local.Type, s.eval(f, n.Local.Value))
+	case *semantic.Assign:
+		s.assign(f, n)
+	case *semantic.ArrayAssign:
+		a := s.eval(f, n.To.Array).(Array)
+		i := toUint(s.eval(f, n.To.Index))
+		if i >= uint64(len(a)) {
+			panic(failf("Array index %d out of bounds", i))
+		}
+		a[i] = s.compound(n.Operator, n.To.Type.ValueType, a[i], s.eval(f, n.Value))
+	case *semantic.MapAssign:
+		m := s.eval(f, n.To.Map).(*Map)
+		k := s.eval(f, n.To.Index)
+		m.Entries[k] = s.copy(n.To.Type.ValueType, s.eval(f, n.Value))
+	case *semantic.MapRemove:
+		m := s.eval(f, n.Map).(*Map)
+		delete(m.Entries, s.eval(f, n.Key))
+	case *semantic.SliceAssign:
+		sl := s.eval(f, n.To.Slice).(Slice)
+		i := toUint(s.eval(f, n.To.Index))
+		v := s.eval(f, n.Value)
+		s.write(s.subslice(sl, n.To.Type.To, i, i+1), n.To.Type.To, []Value{v})
+	case *semantic.Branch:
+		if s.eval(f, n.Condition).(bool) {
+			s.block(f, n.True)
+		} else if n.False != nil {
+			s.block(f, n.False)
+		}
+	case *semantic.Switch:
+		v := s.eval(f, n.Value)
+		for _, c := range n.Cases {
+			for _, cond := range c.Conditions {
+				if equal(v, s.eval(f, cond)) {
+					s.block(f, c.Block)
+					return
+				}
+			}
+		}
+		if n.Default == nil {
+			panic(failf("Missing switch case handler for value %T %v", v, v))
+		}
+		s.block(f, n.Default)
+	case *semantic.Iteration:
+		ty := n.Iterator.Type
+		from, to := number(ty, s.eval(f, n.From)), s.eval(f, n.To)
+		for i := from; compare(ast.OpLT, i, to); i = arithmetic(ty, ast.OpPlus, i, uint64(1)) {
+			f.locals[n.Iterator] = i
+			if s.block(f, n.Block); f.returned {
+				return
+			}
+		}
+	case *semantic.MapIteration:
+		m := s.eval(f, n.Map).(*Map)
+		for i, k := range m.Keys() {
+			f.locals[n.IndexIterator] = number(n.IndexIterator.Type, int64(i))
+			f.locals[n.KeyIterator] = k
+			f.locals[n.ValueIterator] = m.Entries[k]
+			if s.block(f, n.Block); f.returned {
+				return
+			}
+		}
+	case *semantic.Call:
+		s.call(f, n)
+	case *semantic.Read:
+		sl := s.eval(f, n.Slice).(Slice)
+		if p := s.pool(sl.Pool); p.OnRead != nil {
+			p.OnRead(s.rangeOf(sl, elementOf(n.Slice)))
+		}
+	case *semantic.Write:
+		sl := s.eval(f, n.Slice).(Slice)
+		if p := s.pool(sl.Pool); p.OnWrite != nil {
+			p.OnWrite(s.rangeOf(sl, elementOf(n.Slice)))
+		}
+	case *semantic.Copy:
+		dst, src := s.eval(f, n.Dst).(Slice), s.eval(f, n.Src).(Slice)
+		s.copySlice(dst, src, elementOf(n.Dst))
+	case *semantic.Fence:
+		if st, ok := n.Statement.(semantic.Statement); ok {
+			s.statement(f, st)
+		}
+		if f.invocation != nil {
+			s.observe(f.invocation.Writes)
+		}
+	case *semantic.Return:
+		if n.Value != nil {
+			v := s.eval(f, n.Value)
+			if n.Function.Subroutine {
+				f.result = s.copy(n.Function.Return.Type, v)
+			} else {
+				f.result = v
+			}
+		}
+		f.returned = true
+	case *semantic.Abort:
+		panic(ErrAborted(n.Function.Name()))
+	case *semantic.Assert:
+		if !s.eval(f, n.Condition).(bool) {
+			panic(failf("Assert failed in %s", f.function.Name()))
+		}
+	default:
+		panic(failf("Unsupported statement %T", n))
+	}
+}
+
+// assign executes the assignment n.
+func (s *State) assign(f *frame, n *semantic.Assign) {
+	if _, ok := n.LHS.(*semantic.Ignore); ok {
+		s.eval(f, n.RHS)
+		return
+	}
+	ty := n.LHS.ExpressionType()
+	switch lhs := n.LHS.(type) {
+	case *semantic.Local:
+		f.locals[lhs] = s.compound(n.Operator, ty, f.locals[lhs], s.eval(f, n.RHS))
+	case *semantic.Parameter:
+		f.params[lhs] = s.compound(n.Operator, ty, f.params[lhs], s.eval(f, n.RHS))
+	case *semantic.Global:
+		s.Globals[lhs] = s.compound(n.Operator, ty, s.Globals[lhs], s.eval(f, n.RHS))
+	case *semantic.Member:
+		o := s.object(f, lhs.Object)
+		o.Fields[lhs.Field] = s.compound(n.Operator, ty, o.Fields[lhs.Field], s.eval(f, n.RHS))
+	default:
+		panic(failf("Unsupported assignment to %T", lhs))
+	}
+}
+
+// compound returns the value to store for an assignment with the operator op
+// to the old value of type ty.
+func (s *State) compound(op string, ty semantic.Type, old, v Value) Value {
+	switch op {
+	case ast.OpAssign:
+		return s.copy(ty, v)
+	case ast.OpAssignPlus:
+		return arithmetic(ty, ast.OpPlus, old, v)
+	case ast.OpAssignMinus:
+		return arithmetic(ty, ast.OpMinus, old, v)
+	}
+	panic(failf("Unsupported assignment operator %s", op))
+}
+
+// elementOf returns the element type of the slice expression e.
+func elementOf(e semantic.Expression) semantic.Type {
+	if sl, ok := semantic.Underlying(e.ExpressionType()).(*semantic.Slice); ok {
+		return sl.To
+	}
+	panic(failf("%T is not a slice expression", e))
+}
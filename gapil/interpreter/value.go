@@ -0,0 +1,238 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpreter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/google/gapid/gapil/semantic"
+	"github.com/google/gapid/gapis/memory"
+)
+
+// Value is a dynamic value held by the interpreter.
+//
+// Values of builtin types are held as the Go type of the same size:
+// bool, string, int8 to int64, uint8 to uint64, float32 and float64.
+// int is held as int64, uint and size as uint64 and char as uint8.
+// Enums are held as uint32, pseudonyms as their underlying type, static arrays
+// as Array, classes as *Object, references as *Object (or nil), maps as *Map,
+// pointers as Pointer, slices as Slice and functions as *semantic.Function.
+type Value interface{}
+
+// Object is an instance of a class.
+type Object struct {
+	Class  *semantic.Class
+	Fields map[*semantic.Field]Value
+}
+
+// Get returns the value of the field with the given name.
+func (o *Object) Get(name string) Value {
+	for f, v := range o.Fields {
+		if f.Name() == name {
+			return v
+		}
+	}
+	return nil
+}
+
+func (o *Object) String() string {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "%s{", o.Class.Name())
+	for i, f := range o.Class.Fields {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		fmt.Fprintf(buf, "%s: %v", f.Name(), o.Fields[f])
+	}
+	buf.WriteString("}")
+	return buf.String()
+}
+
+// Array is an instance of a static array.
+type Array []Value
+
+// Map is an instance of a map. Maps are reference types, copying a map value
+// shares the entries.
+type Map struct {
+	Type    *semantic.Map
+	Entries map[Value]Value
+}
+
+// Keys returns the keys of the map in ascending order.
+func (m *Map) Keys() []Value {
+	keys := make([]Value, 0, len(m.Entries))
+	for k := range m.Entries {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return less(keys[i], keys[j]) })
+	return keys
+}
+
+// Pointer is an address in a memory pool.
+type Pointer struct {
+	Address uint64
+	Pool    memory.PoolID
+}
+
+// IsNullptr returns true if the pointer is the application null pointer.
+func (p Pointer) IsNullptr() bool { return p.Address == 0 && p.Pool == memory.ApplicationPool }
+
+func (p Pointer) String() string { return fmt.Sprintf("0x%x@%d", p.Address, p.Pool) }
+
+// Slice is a range of elements in a memory pool.
+type Slice struct {
+	Root  uint64        // Original pointer this slice derives from.
+	Base  uint64        // Address of first element.
+	Count uint64        // Number of elements in the slice.
+	Pool  memory.PoolID // The pool identifier.
+}
+
+func (s Slice) String() string { return fmt.Sprintf("[0x%x:%d]@%d", s.Base, s.Count, s.Pool) }
+
+// Message is an instance of a message value.
+type Message struct {
+	Identifier string
+	Arguments  map[string]Value
+}
+
+// zero returns the default value of the type ty.
+func (s *State) zero(ty semantic.Type) Value {
+	switch ty := ty.(type) {
+	case *semantic.Pseudonym:
+		return s.zero(ty.To)
+	case *semantic.Enum:
+		return uint32(0)
+	case *semantic.Class:
+		o := &Object{Class: ty, Fields: make(map[*semantic.Field]Value, len(ty.Fields))}
+		for _, f := range ty.Fields {
+			if f.Default != nil {
+				o.Fields[f] = s.copy(f.Type, s.eval(s.frame(context.Background(), nil), f.Default))
+			} else {
+				o.Fields[f] = s.zero(f.Type)
+			}
+		}
+		return o
+	case *semantic.StaticArray:
+		a := make(Array, ty.Size)
+		for i := range a {
+			a[i] = s.zero(ty.ValueType)
+		}
+		return a
+	case *semantic.Map:
+		return &Map{Type: ty, Entries: map[Value]Value{}}
+	case *semantic.Pointer:
+		return Pointer{Pool: memory.ApplicationPool}
+	case *semantic.Slice:
+		return Slice{Pool: memory.ApplicationPool}
+	case *semantic.Reference:
+		return nil
+	case *semantic.Builtin:
+		switch ty {
+		case semantic.BoolType:
+			return false
+		case semantic.StringType:
+			return ""
+		case semantic.Int8Type:
+			return int8(0)
+		case semantic.Uint8Type, semantic.CharType:
+			return uint8(0)
+		case semantic.Int16Type:
+			return int16(0)
+		case semantic.Uint16Type:
+			return uint16(0)
+		case semantic.Int32Type:
+			return int32(0)
+		case semantic.Uint32Type:
+			return uint32(0)
+		case semantic.Int64Type, semantic.IntType:
+			return int64(0)
+		case semantic.Uint64Type, semantic.UintType, semantic.SizeType:
+			return uint64(0)
+		case semantic.Float32Type:
+			return float32(0)
+		case semantic.Float64Type:
+			return float64(0)
+		}
+		return nil
+	}
+	panic(failf("Cannot create a value of type %T %v", ty, typename(ty)))
+}
+
+// copy returns a copy of v, a value of type ty. Classes and static arrays are
+// copied deeply, all other values are returned as is.
+func (s *State) copy(ty semantic.Type, v Value) Value {
+	switch ty := semantic.Underlying(ty).(type) {
+	case *semantic.Class:
+		o, ok := v.(*Object)
+		if !ok || o == nil {
+			return v
+		}
+		out := &Object{Class: o.Class, Fields: make(map[*semantic.Field]Value, len(o.Fields))}
+		for _, f := range ty.Fields {
+			out.Fields[f] = s.copy(f.Type, o.Fields[f])
+		}
+		return out
+	case *semantic.StaticArray:
+		a, ok := v.(Array)
+		if !ok {
+			return v
+		}
+		out := make(Array, len(a))
+		for i, e := range a {
+			out[i] = s.copy(ty.ValueType, e)
+		}
+		return out
+	}
+	return v
+}
+
+// less returns true if a orders before b. It is used to iterate maps in a
+// deterministic order.
+func less(a, b Value) bool {
+	switch a := a.(type) {
+	case string:
+		if b, ok := b.(string); ok {
+			return a < b
+		}
+	case bool:
+		if b, ok := b.(bool); ok {
+			return !a && b
+		}
+	case Pointer:
+		if b, ok := b.(Pointer); ok {
+			return a.Pool < b.Pool || (a.Pool == b.Pool && a.Address < b.Address)
+		}
+	}
+	switch {
+	case isSigned(a) && isSigned(b):
+		return toInt(a) < toInt(b)
+	case isNumber(a) && isNumber(b):
+		if isFloat(a) || isFloat(b) {
+			return toFloat(a) < toFloat(b)
+		}
+		return toUint(a) < toUint(b)
+	}
+	return fmt.Sprint(a) < fmt.Sprint(b)
+}
+
+func typename(ty semantic.Type) string {
+	if n, ok := ty.(semantic.NamedNode); ok {
+		return n.Name()
+	}
+	return fmt.Sprintf("%T", ty)
+}
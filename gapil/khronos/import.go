@@ -0,0 +1,538 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package khronos
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/google/gapid/core/text/parse"
+	"github.com/google/gapid/gapil/format"
+	"github.com/google/gapid/gapil/parser"
+	"github.com/google/gapid/gapil/semantic"
+)
+
+// Import writes to w the api file declarations for the commands, types and
+// enums required by the named features and extensions of reg that are not
+// declared by target. api is the name of the registry API to import from,
+// such as "gles2" or "vulkan".
+//
+// Enum values that extend an enum already declared by target are written as a
+// separate declaration of that enum, to be merged by hand.
+func Import(reg *Registry, target *semantic.API, api string, names []string, w io.Writer) error {
+	i := newImporter(reg, target, api)
+	for _, name := range names {
+		if err := i.require(name); err != nil {
+			return err
+		}
+	}
+
+	src := i.String()
+	m := parse.NewCSTMap()
+	out, errs := parser.Parse("import.api", src, m)
+	if len(errs) > 0 {
+		return fmt.Errorf("Generated api file does not parse: %v\n%s", errs, src)
+	}
+	format.Format(out, m, w)
+	return nil
+}
+
+type importer struct {
+	reg      *Registry
+	api      string
+	vulkan   bool
+	declared map[string]bool          // Names declared by the target or already imported.
+	values   map[string]string        // The values of the known enum entries by name.
+	types    map[string]*Type         // The registry types by name.
+	commands map[string]*Command      // The registry commands by name.
+	blocks   map[string]*Enums        // The registry enum blocks by name.
+	enums    map[string]*Enum         // The registry enum values by name.
+	owners   map[string]*Enums        // The registry enum blocks by value name.
+	entries  map[string]*bytes.Buffer // The entries of the imported and extended enums.
+	extended []string                 // The names of the target enums with new entries.
+	defines  bytes.Buffer             // The imported definitions.
+	decls    []func(io.Writer)        // Writers of the imported types.
+	cmds     bytes.Buffer             // The imported commands.
+}
+
+func newImporter(reg *Registry, target *semantic.API, api string) *importer {
+	i := &importer{
+		reg:      reg,
+		api:      api,
+		vulkan:   api == "vulkan",
+		declared: map[string]bool{},
+		values:   map[string]string{},
+		types:    map[string]*Type{},
+		commands: map[string]*Command{},
+		blocks:   map[string]*Enums{},
+		enums:    map[string]*Enum{},
+		owners:   map[string]*Enums{},
+		entries:  map[string]*bytes.Buffer{},
+	}
+	for _, f := range target.Functions {
+		i.declared[f.Name()] = true
+	}
+	for _, f := range target.Subroutines {
+		i.declared[f.Name()] = true
+	}
+	for _, f := range target.Externs {
+		i.declared[f.Name()] = true
+	}
+	for _, c := range target.Classes {
+		i.declared[c.Name()] = true
+	}
+	for _, p := range target.Pseudonyms {
+		i.declared[p.Name()] = true
+	}
+	for _, d := range target.Definitions {
+		i.declared[d.Name()] = true
+	}
+	for _, g := range target.Globals {
+		i.declared[g.Name()] = true
+	}
+	for _, e := range target.Enums {
+		i.declared[e.Name()] = true
+		for _, v := range e.Entries {
+			i.declared[v.Name()] = true
+			i.values[v.Name()] = fmt.Sprintf("0x%08X", v.Value)
+		}
+	}
+	for _, t := range reg.Types {
+		i.types[t.Name()] = t
+	}
+	for _, c := range reg.Commands {
+		i.commands[c.Name()] = c
+	}
+	for _, b := range reg.Enums {
+		i.blocks[b.Name] = b
+		for _, e := range b.Values {
+			if e.API == "" || e.API == api {
+				i.enums[e.Name] = e
+				i.owners[e.Name] = b
+			}
+		}
+	}
+	return i
+}
+
+// String returns the unformatted api file source of the imported
+// declarations.
+func (i *importer) String() string {
+	out := &bytes.Buffer{}
+	out.Write(i.defines.Bytes())
+	for _, name := range i.extended {
+		fmt.Fprintf(out, "\n// New entries of the existing %s.\n", name)
+		fmt.Fprintf(out, "%s %s {\n%s}\n", i.enumKind(name), name, i.entries[name])
+	}
+	for _, d := range i.decls {
+		d(out)
+	}
+	out.Write(i.cmds.Bytes())
+	return out.String()
+}
+
+// require imports everything required by the named feature or extension.
+func (i *importer) require(name string) error {
+	for _, f := range i.reg.Features {
+		if f.Name == name && f.API == i.api {
+			version := "GLES" + strings.Replace(f.Number, ".", "", -1)
+			r := &requirer{i, nil, nil, f.Number}
+			if !i.vulkan {
+				r.annotations = []string{fmt.Sprintf("@if(Version.%s)", version)}
+				r.docVersion = version
+			}
+			return r.require(f.Requires)
+		}
+	}
+	for _, e := range i.reg.Extensions {
+		if e.Name != name {
+			continue
+		}
+		if !supports(e.Supported, i.api) {
+			return fmt.Errorf("Extension %s is not supported by %s", name, i.api)
+		}
+		r := &requirer{i, e, nil, ""}
+		if i.vulkan {
+			r.annotations = []string{fmt.Sprintf(`@extension("%s")`, name)}
+		} else {
+			r.annotations = []string{fmt.Sprintf("@if(Extension.%s)", name)}
+		}
+		return r.require(e.Requires)
+	}
+	return fmt.Errorf("Feature or extension %s not found", name)
+}
+
+func supports(supported, api string) bool {
+	for _, s := range strings.Split(supported, "|") {
+		if s == api {
+			return true
+		}
+	}
+	return false
+}
+
+// enumKind returns "bitfield" if the enum name holds flag bits, otherwise
+// "enum".
+func (i *importer) enumKind(name string) string {
+	if b, ok := i.blocks[name]; (ok && b.Type == "bitmask") || name == "GLbitfield" {
+		return "bitfield"
+	}
+	return "enum"
+}
+
+// requirer imports the requirements of a single feature or extension.
+type requirer struct {
+	*importer
+	ext         *Extension // nil for a feature.
+	annotations []string   // The annotations of each declaration.
+	docVersion  string     // The GLES version of a feature.
+}
+
+func (r *requirer) require(requires []*Require) error {
+	for _, req := range requires {
+		if req.API != "" && req.API != r.api {
+			continue
+		}
+		for _, t := range req.Types {
+			if err := r.addType(t.Name); err != nil {
+				return err
+			}
+		}
+		for _, e := range req.Enums {
+			if err := r.addEnum(e); err != nil {
+				return err
+			}
+		}
+		for _, c := range req.Commands {
+			if err := r.addCommand(c.Name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *requirer) annotate(out io.Writer) {
+	for _, a := range r.annotations {
+		fmt.Fprintln(out, a)
+	}
+}
+
+// declare adds a type declaration with the annotations of the feature or
+// extension. f writes the declaration without its annotations.
+func (r *requirer) declare(f func(out io.Writer)) {
+	annotations := r.annotations
+	r.decls = append(r.decls, func(out io.Writer) {
+		fmt.Fprintln(out)
+		for _, a := range annotations {
+			fmt.Fprintln(out, a)
+		}
+		f(out)
+	})
+}
+
+// addTypes adds all the registry types referenced by the declaration type.
+func (r *requirer) addTypes(typ string) error {
+	for _, w := range wordRE.FindAllString(typ, -1) {
+		if _, ok := r.types[w]; ok {
+			if err := r.addType(w); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *requirer) addType(name string) error {
+	t, ok := r.types[name]
+	if !ok || r.declared[name] {
+		return nil
+	}
+	r.declared[name] = true
+	switch t.Category {
+	case "handle":
+		r.declare(func(out io.Writer) {
+			if t.Type == "VK_DEFINE_NON_DISPATCHABLE_HANDLE" {
+				fmt.Fprintf(out, "@replay_remap @nonDispatchHandle type u64 %s\n", name)
+			} else {
+				fmt.Fprintf(out, "@replay_remap @dispatchHandle type size %s\n", name)
+			}
+		})
+	case "bitmask":
+		r.declare(func(out io.Writer) { fmt.Fprintf(out, "type VkFlags %s\n", name) })
+		if t.Requires != "" {
+			return r.addType(t.Requires)
+		}
+	case "enum":
+		// The entries are written once all the requirements are imported, as
+		// the enum may be extended by later requirements.
+		entries := &bytes.Buffer{}
+		r.entries[name] = entries
+		r.declare(func(out io.Writer) {
+			fmt.Fprintf(out, "%s %s {\n%s}\n", r.enumKind(name), name, entries)
+		})
+		if b := r.blocks[name]; b != nil {
+			for _, e := range b.Values {
+				if err := r.addEntry(name, e); err != nil {
+					return err
+				}
+			}
+		}
+	case "struct", "union":
+		fields := &bytes.Buffer{}
+		for _, m := range t.Members {
+			typ, field, array := m.Parse()
+			if err := r.addTypes(typ + array); err != nil {
+				return err
+			}
+			fmt.Fprintf(fields, "  %s%s %s\n", typ, array, field)
+		}
+		r.declare(func(out io.Writer) {
+			if t.Category == "union" {
+				fmt.Fprintln(out, "@union")
+			}
+			fmt.Fprintf(out, "class %s {\n%s}\n", name, fields)
+		})
+	case "funcpointer":
+		r.declare(func(out io.Writer) { fmt.Fprintf(out, "@external type void* %s\n", name) })
+	}
+	return nil
+}
+
+func (r *requirer) addCommand(name string) error {
+	c, ok := r.commands[name]
+	if !ok {
+		return fmt.Errorf("Command %s not found", name)
+	}
+	if r.declared[name] {
+		return nil
+	}
+	r.declared[name] = true
+
+	ret, _, _ := c.Proto.Parse()
+	if err := r.addTypes(ret); err != nil {
+		return err
+	}
+	params := make([]string, len(c.Params))
+	types := make([]string, len(c.Params))
+	for j, p := range c.Params {
+		typ, param, array := p.Parse()
+		if array != "" {
+			typ += "*" // Array parameters decay to pointers.
+		}
+		if err := r.addTypes(typ); err != nil {
+			return err
+		}
+		types[j] = typ
+		params[j] = fmt.Sprintf("%s %s", typ, param)
+	}
+
+	out := &r.cmds
+	fmt.Fprintln(out)
+	r.annotate(out)
+	switch {
+	case r.vulkan && len(types) > 0:
+		switch types[0] {
+		case "VkCommandBuffer":
+			fmt.Fprintln(out, `@indirect("VkCommandBuffer", "VkDevice")`)
+		case "VkQueue":
+			fmt.Fprintln(out, `@indirect("VkQueue", "VkDevice")`)
+		case "VkDevice":
+			fmt.Fprintln(out, `@indirect("VkDevice")`)
+		case "VkPhysicalDevice":
+			fmt.Fprintln(out, `@indirect("VkPhysicalDevice", "VkInstance")`)
+		case "VkInstance":
+			fmt.Fprintln(out, `@indirect("VkInstance")`)
+		}
+	case !r.vulkan && r.ext != nil:
+		fmt.Fprintf(out, "@doc(%q, Extension.%s)\n", extensionDoc(r.ext.Name), r.ext.Name)
+	case !r.vulkan && r.docVersion != "":
+		fmt.Fprintf(out, "@doc(%q, Version.%s)\n", manPage(r.docVersion, name), r.docVersion)
+	}
+	fmt.Fprintf(out, "cmd %s %s(%s) {\n", ret, name, strings.Join(params, ", "))
+	if ret != "void" {
+		fmt.Fprintln(out, "  return ?")
+	}
+	fmt.Fprintln(out, "}")
+	return nil
+}
+
+// extensionDoc returns the URL of the specification of the GL extension.
+func extensionDoc(ext string) string {
+	name := strings.TrimPrefix(ext, "GL_")
+	vendor := strings.SplitN(name, "_", 2)[0]
+	if vendor == "KHR" {
+		name = strings.TrimPrefix(name, "KHR_")
+	}
+	return fmt.Sprintf("https://www.khronos.org/registry/gles/extensions/%s/%s.txt", vendor, name)
+}
+
+// manPage returns the URL of the reference page of the GLES command.
+func manPage(version, cmd string) string {
+	switch version {
+	case "GLES20":
+		return fmt.Sprintf("https://www.khronos.org/opengles/sdk/docs/man/xhtml/%s.xml", cmd)
+	case "GLES30":
+		return fmt.Sprintf("https://www.khronos.org/opengles/sdk/docs/man3/html/%s.xhtml", cmd)
+	default:
+		dir := "man" + strings.TrimPrefix(version, "GLES")
+		return fmt.Sprintf("https://www.khronos.org/opengles/sdk/docs/%s/html/%s.xhtml", dir, cmd)
+	}
+}
+
+// addEnum adds an enum value required by the feature or extension.
+func (r *requirer) addEnum(e *Enum) error {
+	if e.API != "" && e.API != r.api {
+		return nil
+	}
+	if e.Extends != "" {
+		if err := r.addType(e.Extends); err != nil {
+			return err
+		}
+		return r.addEntry(e.Extends, e)
+	}
+	if e.Value == "" && e.Bitpos == "" && e.Alias == "" {
+		// A reference to a value declared by an enum block.
+		v, ok := r.enums[e.Name]
+		if !ok {
+			return fmt.Errorf("Enum %s not found", e.Name)
+		}
+		e = v
+		if b := r.owners[e.Name]; !r.vulkan && b.Type == "bitmask" {
+			return r.addEntry("GLbitfield", e)
+		} else if !r.vulkan {
+			return r.addEntry("GLenum", e)
+		}
+	}
+	return r.addDefine(e)
+}
+
+func (r *requirer) addDefine(e *Enum) error {
+	if r.declared[e.Name] {
+		return nil
+	}
+	r.declared[e.Name] = true
+	value, err := r.value(e)
+	if err != nil {
+		return err
+	}
+	r.annotate(&r.defines)
+	fmt.Fprintf(&r.defines, "define %s %s\n", e.Name, value)
+	return nil
+}
+
+// addEntry adds the value e to the entries of the enum.
+func (r *requirer) addEntry(enum string, e *Enum) error {
+	if r.declared[e.Name] {
+		return nil
+	}
+	value, err := r.value(e)
+	if err != nil {
+		return err
+	}
+	if _, err := strconv.ParseUint(value, 0, 32); err != nil || !r.declared[enum] {
+		// The value does not fit in an enum, or the enum is neither declared by
+		// the target nor imported.
+		return r.addDefine(e)
+	}
+	if _, ok := r.entries[enum]; !ok {
+		r.entries[enum] = &bytes.Buffer{}
+		r.extended = append(r.extended, enum)
+	}
+	r.declared[e.Name] = true
+	r.values[e.Name] = value
+	fmt.Fprintf(r.entries[enum], "  %s = %s,\n", e.Name, value)
+	return nil
+}
+
+// value returns the api file literal for the value of e.
+func (r *requirer) value(e *Enum) (string, error) {
+	switch {
+	case e.Offset != "":
+		ext := e.ExtNumber
+		if ext == "" && r.ext != nil {
+			ext = r.ext.Number
+		}
+		n, err := strconv.ParseInt(ext, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("Invalid extension number for %s: %v", e.Name, err)
+		}
+		offset, err := strconv.ParseInt(e.Offset, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("Invalid offset for %s: %v", e.Name, err)
+		}
+		v := 1000000000 + (n-1)*1000 + offset
+		if e.Dir == "-" {
+			return fmt.Sprintf("0x%08X", uint32(-v)), nil
+		}
+		return fmt.Sprint(v), nil
+	case e.Bitpos != "":
+		bit, err := strconv.ParseUint(e.Bitpos, 10, 5)
+		if err != nil {
+			return "", fmt.Errorf("Invalid bit position for %s: %v", e.Name, err)
+		}
+		return fmt.Sprintf("0x%08X", uint32(1)<<bit), nil
+	case e.Alias != "":
+		if v, ok := r.values[e.Alias]; ok {
+			return v, nil
+		}
+		if v, ok := r.enums[e.Alias]; ok {
+			return r.value(v)
+		}
+		return "", fmt.Errorf("Alias %s of %s not found", e.Alias, e.Name)
+	default:
+		return cValue(e.Name, e.Value)
+	}
+}
+
+// cValue returns the api file literal for the C literal v.
+func cValue(name, v string) (string, error) {
+	if strings.HasPrefix(v, `"`) {
+		return v, nil
+	}
+	v = strings.TrimSuffix(strings.TrimPrefix(v, "("), ")")
+	switch v {
+	case "~0U":
+		return "0xFFFFFFFF", nil
+	case "~0U-1":
+		return "0xFFFFFFFE", nil
+	case "~0U-2":
+		return "0xFFFFFFFD", nil
+	case "~0ULL":
+		return "0xFFFFFFFFFFFFFFFF", nil
+	}
+	if f := strings.TrimSuffix(v, "f"); f != v && !strings.HasPrefix(v, "0x") {
+		if _, err := strconv.ParseFloat(f, 64); err == nil {
+			return f, nil
+		}
+	}
+	i := strings.TrimRight(v, "uUlL")
+	if u, err := strconv.ParseUint(i, 0, 64); err == nil {
+		if u > 0xFFFFFFFF {
+			return fmt.Sprintf("0x%X", u), nil
+		}
+		if strings.HasPrefix(i, "0x") {
+			return fmt.Sprintf("0x%08X", u), nil
+		}
+		return i, nil
+	}
+	if s, err := strconv.ParseInt(i, 0, 64); err == nil {
+		return fmt.Sprintf("0x%08X", uint32(s)), nil
+	}
+	return "", fmt.Errorf("Unsupported value %q for %s", v, name)
+}
@@ -0,0 +1,286 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package khronos_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/gapil/ast"
+	"github.com/google/gapid/gapil/khronos"
+	"github.com/google/gapid/gapil/parser"
+	"github.com/google/gapid/gapil/resolver"
+	"github.com/google/gapid/gapil/semantic"
+)
+
+func resolve(assert assert.Manager, source string) *semantic.API {
+	m := resolver.NewMappings()
+	astAPI, errs := parser.Parse("import_test.api", source, m)
+	assert.For("parse errors").That(errs).IsNil()
+	api, errs := resolver.Resolve([]*ast.API{astAPI}, nil, m)
+	assert.For("resolve errors").That(errs).IsNil()
+	return api
+}
+
+func load(assert assert.Manager, xml string) *khronos.Registry {
+	reg, err := khronos.Load(strings.NewReader(xml))
+	assert.For("load").ThatError(err).Succeeded()
+	return reg
+}
+
+func lines(s string) []string {
+	out := []string{}
+	for _, l := range strings.Split(s, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			out = append(out, strings.Join(strings.Fields(l), " "))
+		}
+	}
+	return out
+}
+
+const vkXML = `<registry>
+<types>
+  <type category="basetype">typedef <type>uint32_t</type> <name>VkFlags</name>;</type>
+  <type category="handle"><type>VK_DEFINE_HANDLE</type>(<name>VkPhysicalDevice</name>)</type>
+  <type category="handle" parent="VkInstance"><type>VK_DEFINE_NON_DISPATCHABLE_HANDLE</type>(<name>VkSurfaceKHR</name>)</type>
+  <type name="VkPresentModeKHR" category="enum"/>
+  <type name="VkSurfaceTransformFlagBitsKHR" category="enum"/>
+  <type requires="VkSurfaceTransformFlagBitsKHR" category="bitmask">typedef <type>VkFlags</type> <name>VkSurfaceTransformFlagsKHR</name>;</type>
+  <type category="struct" name="VkSurfaceCapabilitiesKHR">
+    <member><type>uint32_t</type> <name>minImageCount</name></member>
+    <member><type>VkSurfaceTransformFlagsKHR</type> <name>supportedTransforms</name></member>
+    <member><type>uint8_t</type> <name>uuid</name>[<enum>VK_UUID_SIZE</enum>]</member>
+    <member>const <type>char</type>* const* <name>ppNames</name> <comment>names</comment></member>
+  </type>
+</types>
+<enums name="API Constants">
+  <enum value="16" name="VK_UUID_SIZE"/>
+</enums>
+<enums name="VkResult" type="enum">
+  <enum value="0" name="VK_SUCCESS"/>
+</enums>
+<enums name="VkPresentModeKHR" type="enum">
+  <enum value="0" name="VK_PRESENT_MODE_IMMEDIATE_KHR"/>
+  <enum value="2" name="VK_PRESENT_MODE_FIFO_KHR"/>
+</enums>
+<enums name="VkSurfaceTransformFlagBitsKHR" type="bitmask">
+  <enum bitpos="0" name="VK_SURFACE_TRANSFORM_IDENTITY_BIT_KHR"/>
+  <enum bitpos="8" name="VK_SURFACE_TRANSFORM_INHERIT_BIT_KHR"/>
+</enums>
+<commands>
+  <command>
+    <proto><type>VkResult</type> <name>vkGetPhysicalDeviceSurfaceCapabilitiesKHR</name></proto>
+    <param><type>VkPhysicalDevice</type> <name>physicalDevice</name></param>
+    <param><type>VkSurfaceKHR</type> <name>surface</name></param>
+    <param><type>VkSurfaceCapabilitiesKHR</type>* <name>pSurfaceCapabilities</name></param>
+  </command>
+  <command>
+    <proto><type>void</type> <name>vkDestroyInstance</name></proto>
+    <param><type>VkInstance</type> <name>instance</name></param>
+  </command>
+</commands>
+<extensions>
+  <extension name="VK_KHR_surface" number="1" supported="vulkan">
+    <require>
+      <enum value="25" name="VK_KHR_SURFACE_SPEC_VERSION"/>
+      <enum value="&quot;VK_KHR_surface&quot;" name="VK_KHR_SURFACE_EXTENSION_NAME"/>
+      <enum offset="0" dir="-" extends="VkResult" name="VK_ERROR_SURFACE_LOST_KHR"/>
+      <enum offset="0" extends="VkStructureType" name="VK_STRUCTURE_TYPE_SURFACE_KHR"/>
+      <enum bitpos="9" extends="VkSurfaceTransformFlagBitsKHR" name="VK_SURFACE_TRANSFORM_EXTRA_BIT_KHR"/>
+      <type name="VkSurfaceKHR"/>
+      <type name="VkPresentModeKHR"/>
+      <command name="vkGetPhysicalDeviceSurfaceCapabilitiesKHR"/>
+      <command name="vkDestroyInstance"/>
+    </require>
+  </extension>
+  <extension name="VK_KHR_disabled" number="2" supported="disabled"/>
+</extensions>
+</registry>`
+
+const vkTarget = `
+type u32 VkFlags
+@dispatchHandle type size VkInstance
+@dispatchHandle type size VkPhysicalDevice
+define VK_UUID_SIZE 16
+
+enum VkResult {
+	VK_SUCCESS = 0
+}
+
+enum VkStructureType {
+	VK_STRUCTURE_TYPE_APPLICATION_INFO = 0
+	VK_STRUCTURE_TYPE_SURFACE_KHR = 1000000000
+}
+
+cmd void vkDestroyInstance(VkInstance instance) {}
+`
+
+func TestImportVulkan(t *testing.T) {
+	assert := assert.To(t)
+	reg := load(assert, vkXML)
+	target := resolve(assert, vkTarget)
+
+	buf := &bytes.Buffer{}
+	err := khronos.Import(reg, target, "vulkan", []string{"VK_KHR_surface"}, buf)
+	assert.For("err").ThatError(err).Succeeded()
+	assert.For("output").ThatSlice(lines(buf.String())).Equals(lines(`
+@extension("VK_KHR_surface")
+define VK_KHR_SURFACE_SPEC_VERSION 25
+@extension("VK_KHR_surface")
+define VK_KHR_SURFACE_EXTENSION_NAME "VK_KHR_surface"
+
+// New entries of the existing VkResult.
+enum VkResult {
+  VK_ERROR_SURFACE_LOST_KHR = 0xC4653600,
+}
+
+@extension("VK_KHR_surface")
+@replay_remap @nonDispatchHandle type u64 VkSurfaceKHR
+
+@extension("VK_KHR_surface")
+enum VkPresentModeKHR {
+  VK_PRESENT_MODE_IMMEDIATE_KHR = 0,
+  VK_PRESENT_MODE_FIFO_KHR      = 2,
+}
+
+@extension("VK_KHR_surface")
+bitfield VkSurfaceTransformFlagBitsKHR {
+  VK_SURFACE_TRANSFORM_IDENTITY_BIT_KHR = 0x00000001,
+  VK_SURFACE_TRANSFORM_INHERIT_BIT_KHR  = 0x00000100,
+  VK_SURFACE_TRANSFORM_EXTRA_BIT_KHR    = 0x00000200,
+}
+
+@extension("VK_KHR_surface")
+type VkFlags VkSurfaceTransformFlagsKHR
+
+@extension("VK_KHR_surface")
+class VkSurfaceCapabilitiesKHR {
+  u32                        minImageCount
+  VkSurfaceTransformFlagsKHR supportedTransforms
+  u8[VK_UUID_SIZE]           uuid
+  const char* const*         ppNames
+}
+
+@extension("VK_KHR_surface")
+@indirect("VkPhysicalDevice", "VkInstance")
+cmd VkResult vkGetPhysicalDeviceSurfaceCapabilitiesKHR(VkPhysicalDevice physicalDevice, VkSurfaceKHR surface, VkSurfaceCapabilitiesKHR* pSurfaceCapabilities) {
+  return ?
+}
+`))
+
+	err = khronos.Import(reg, target, "vulkan", []string{"VK_KHR_missing"}, buf)
+	assert.For("missing").ThatError(err).Failed()
+	err = khronos.Import(reg, target, "vulkan", []string{"VK_KHR_disabled"}, buf)
+	assert.For("unsupported").ThatError(err).Failed()
+}
+
+const glXML = `<registry>
+<enums namespace="GL" group="AttribMask" type="bitmask">
+  <enum value="0x00004000" name="GL_COLOR_BUFFER_BIT"/>
+</enums>
+<enums namespace="GL" start="0x8000" end="0x80FF">
+  <enum value="0x8C40" name="GL_SRGB_EXT"/>
+  <enum value="0x8C41" name="GL_SRGB"/>
+  <enum value="0xFFFFFFFFFFFFFFFF" name="GL_TIMEOUT_IGNORED"/>
+</enums>
+<commands namespace="GL">
+  <command>
+    <proto>void <name>glBlendBarrierKHR</name></proto>
+  </command>
+  <command>
+    <proto><ptype>GLboolean</ptype> <name>glIsEnabled</name></proto>
+    <param group="EnableCap"><ptype>GLenum</ptype> <name>cap</name></param>
+  </command>
+  <command>
+    <proto>void <name>glGetPointervKHR</name></proto>
+    <param><ptype>GLenum</ptype> <name>pname</name></param>
+    <param>void **<name>params</name></param>
+  </command>
+</commands>
+<feature api="gles2" name="GL_ES_VERSION_2_0" number="2.0">
+  <require>
+    <enum name="GL_COLOR_BUFFER_BIT"/>
+    <enum name="GL_SRGB"/>
+    <command name="glIsEnabled"/>
+  </require>
+</feature>
+<extensions>
+  <extension name="GL_KHR_blend_equation_advanced" supported="gl|glcore|gles2">
+    <require>
+      <enum name="GL_SRGB_EXT"/>
+      <enum name="GL_TIMEOUT_IGNORED"/>
+      <command name="glBlendBarrierKHR"/>
+      <command name="glGetPointervKHR"/>
+    </require>
+    <require api="gl">
+      <command name="glIsEnabled"/>
+    </require>
+  </extension>
+</extensions>
+</registry>`
+
+const glTarget = `
+type u8 GLboolean
+
+enum GLenum {
+	GL_SRGB = 0x8C41
+}
+
+bitfield GLbitfield {
+	GL_DEPTH_BUFFER_BIT = 0x100
+}
+`
+
+func TestImportGLES(t *testing.T) {
+	assert := assert.To(t)
+	reg := load(assert, glXML)
+	target := resolve(assert, glTarget)
+
+	buf := &bytes.Buffer{}
+	err := khronos.Import(reg, target, "gles2", []string{"GL_ES_VERSION_2_0", "GL_KHR_blend_equation_advanced"}, buf)
+	assert.For("err").ThatError(err).Succeeded()
+	assert.For("output").ThatSlice(lines(buf.String())).Equals(lines(`
+@if(Extension.GL_KHR_blend_equation_advanced)
+define GL_TIMEOUT_IGNORED 0xFFFFFFFFFFFFFFFF
+
+// New entries of the existing GLbitfield.
+bitfield GLbitfield {
+  GL_COLOR_BUFFER_BIT = 0x00004000,
+}
+
+// New entries of the existing GLenum.
+enum GLenum {
+  GL_SRGB_EXT = 0x00008C40,
+}
+
+@if(Version.GLES20)
+@doc("https://www.khronos.org/opengles/sdk/docs/man/xhtml/glIsEnabled.xml", Version.GLES20)
+cmd GLboolean glIsEnabled(GLenum cap) {
+  return ?
+}
+
+@if(Extension.GL_KHR_blend_equation_advanced)
+@doc("https://www.khronos.org/registry/gles/extensions/KHR/blend_equation_advanced.txt", Extension.GL_KHR_blend_equation_advanced)
+cmd void glBlendBarrierKHR() {
+}
+
+@if(Extension.GL_KHR_blend_equation_advanced)
+@doc("https://www.khronos.org/registry/gles/extensions/KHR/blend_equation_advanced.txt", Extension.GL_KHR_blend_equation_advanced)
+cmd void glGetPointervKHR(GLenum pname, void** params) {
+}
+`))
+}
@@ -0,0 +1,195 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package khronos reads the Khronos XML API registries (gl.xml and vk.xml) and
+// generates api file stubs for the commands, types and enums they declare.
+package khronos
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Registry is the root of a Khronos XML API registry.
+type Registry struct {
+	Types      []*Type      `xml:"types>type"`
+	Enums      []*Enums     `xml:"enums"`
+	Commands   []*Command   `xml:"commands>command"`
+	Features   []*Feature   `xml:"feature"`
+	Extensions []*Extension `xml:"extensions>extension"`
+}
+
+// Type is a type declared by the registry.
+type Type struct {
+	NameAttr string  `xml:"name,attr"`
+	NameElem string  `xml:"name"`
+	Category string  `xml:"category,attr"`
+	Requires string  `xml:"requires,attr"`
+	Parent   string  `xml:"parent,attr"`
+	Type     string  `xml:"type"` // The handle macro or the type of a bitmask.
+	Members  []*Decl `xml:"member"`
+}
+
+// Name returns the name of the type, which is either held by the name
+// attribute or by the name element.
+func (t *Type) Name() string {
+	if t.NameAttr != "" {
+		return t.NameAttr
+	}
+	return t.NameElem
+}
+
+// Enums is a block of enum values. In vk.xml each block of values with a name
+// declares an enum type.
+type Enums struct {
+	Name   string  `xml:"name,attr"`
+	Type   string  `xml:"type,attr"` // "enum" or "bitmask".
+	Values []*Enum `xml:"enum"`
+}
+
+// Enum is an enum value, either declared in an Enums block or required by a
+// Feature or an Extension.
+type Enum struct {
+	Name      string `xml:"name,attr"`
+	Value     string `xml:"value,attr"`
+	Alias     string `xml:"alias,attr"`
+	Bitpos    string `xml:"bitpos,attr"`
+	Extends   string `xml:"extends,attr"`
+	Offset    string `xml:"offset,attr"`
+	Dir       string `xml:"dir,attr"`
+	ExtNumber string `xml:"extnumber,attr"`
+	API       string `xml:"api,attr"`
+}
+
+// Decl is the C declaration of a command, a command parameter or a struct
+// member.
+type Decl struct {
+	Inner string `xml:",innerxml"`
+}
+
+// Command is a command declared by the registry.
+type Command struct {
+	Proto  Decl    `xml:"proto"`
+	Params []*Decl `xml:"param"`
+}
+
+// Name returns the name of the command.
+func (c *Command) Name() string {
+	_, name, _ := c.Proto.Parse()
+	return name
+}
+
+// Feature is a core version of an API.
+type Feature struct {
+	Name     string     `xml:"name,attr"`
+	API      string     `xml:"api,attr"`
+	Number   string     `xml:"number,attr"`
+	Requires []*Require `xml:"require"`
+}
+
+// Extension is an API extension.
+type Extension struct {
+	Name      string     `xml:"name,attr"`
+	Number    string     `xml:"number,attr"`
+	Supported string     `xml:"supported,attr"`
+	Requires  []*Require `xml:"require"`
+}
+
+// Require lists the commands, types and enums required by a Feature or an
+// Extension.
+type Require struct {
+	API      string  `xml:"api,attr"`
+	Types    []Ref   `xml:"type"`
+	Enums    []*Enum `xml:"enum"`
+	Commands []Ref   `xml:"command"`
+}
+
+// Ref is a reference to a named command or type.
+type Ref struct {
+	Name string `xml:"name,attr"`
+}
+
+// Load reads a registry from r.
+func Load(r io.Reader) (*Registry, error) {
+	reg := &Registry{}
+	if err := xml.NewDecoder(r).Decode(reg); err != nil {
+		return nil, err
+	}
+	return reg, nil
+}
+
+var (
+	cTypes = map[string]string{
+		"int8_t":   "s8",
+		"uint8_t":  "u8",
+		"int16_t":  "s16",
+		"uint16_t": "u16",
+		"int32_t":  "s32",
+		"uint32_t": "u32",
+		"int64_t":  "s64",
+		"uint64_t": "u64",
+		"float":    "f32",
+		"double":   "f64",
+		"size_t":   "size",
+	}
+	wordRE    = regexp.MustCompile(`\w+`)
+	spaceRE   = regexp.MustCompile(`\s+`)
+	pointerRE = regexp.MustCompile(`\s*\*\s*`)
+)
+
+// Parse returns the type, the name and the array dimensions of the
+// declaration, with the C types replaced by their api file equivalents. The
+// type keeps the position of its const qualifiers and is of the form
+// "const char* const*".
+func (d *Decl) Parse() (typ, name, array string) {
+	dec := xml.NewDecoder(strings.NewReader("<decl>" + d.Inner + "</decl>"))
+	before, after := &bytes.Buffer{}, &bytes.Buffer{}
+	out, elem := before, ""
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			elem = tok.Name.Local
+		case xml.EndElement:
+			if elem == "name" {
+				out = after
+			}
+			elem = ""
+		case xml.CharData:
+			switch elem {
+			case "name":
+				name = strings.TrimSpace(string(tok))
+			case "comment":
+			default:
+				out.Write(tok)
+			}
+		}
+	}
+	typ = wordRE.ReplaceAllStringFunc(before.String(), func(w string) string {
+		if t, ok := cTypes[w]; ok {
+			return t
+		}
+		return w
+	})
+	typ = strings.TrimSpace(spaceRE.ReplaceAllString(typ, " "))
+	typ = strings.TrimSpace(pointerRE.ReplaceAllString(typ, "* "))
+	array = strings.Replace(after.String(), " ", "", -1)
+	return typ, name, array
+}
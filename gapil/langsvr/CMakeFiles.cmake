@@ -21,6 +21,8 @@ set(files
     analyze.go
     debug_logger.go
     main.go
+    main_test.go
+    symbols.go
 )
 set(dirs
     vscode
@@ -124,9 +124,11 @@ func (s *server) nodeLocation(fa *fullAnalysis, n ast.Node) ls.Location {
 }
 
 func (s *server) definition(sem semantic.Node) ast.Node {
-	switch sem := partial(sem).(type) {
+	switch sem := symbol(sem).(type) {
 	case *semantic.Class:
 		return sem.AST
+	case *semantic.Definition:
+		return sem.AST
 	case *semantic.Enum:
 		return sem.AST
 	case *semantic.EnumEntry:
@@ -141,8 +143,6 @@ func (s *server) definition(sem semantic.Node) ast.Node {
 		if sem.Declaration != nil {
 			return sem.Declaration.AST
 		}
-	case *semantic.Member:
-		return sem.Field.AST
 	case *semantic.Parameter:
 		return sem.AST
 	case *semantic.Pseudonym:
@@ -235,27 +235,25 @@ func (s *server) Completions(ctx context.Context, doc *ls.Document, pos ls.Posit
 		return ls.CompletionList{}, err
 	}
 
-	offset := doc.Body().Offset(pos)
+	return da.completions(doc.Body().Offset(pos)), nil
+}
+
+// completions returns the completion items for the cursor at offset.
+func (da *docAnalysis) completions(offset int) ls.CompletionList {
 	list := ls.CompletionList{}
 	for _, n := range da.walkUp(offset) {
+		if member, ok := n.ast.(*ast.Member); ok {
+			// The member name may not resolve while it is being typed, so
+			// complete from the type of the object instead.
+			if sems := da.full.mappings.ASTToSemantic[member.Object]; len(sems) > 0 {
+				memberCompletions(&list, typeof(partial(sems[0])))
+			}
+			return list
+		}
 		switch sem := partial(n.sem).(type) {
 		case *semantic.API:
-			for _, f := range sem.Subroutines {
-				list.Add(f.Name(), ls.Function, "subroutine")
-			}
-			for _, f := range sem.Externs {
-				list.Add(f.Name(), ls.Function, "extern")
-			}
-			for _, f := range sem.Enums {
-				list.Add(f.Name(), ls.Enum, "enum")
-				for _, e := range f.Entries {
-					list.Add(e.Name(), ls.Enum, fmt.Sprintf("%v(%v)", f.Name(), da.full.mappings.CST(e.AST.Value).Token().String()))
-				}
-			}
-			for _, c := range sem.Classes {
-				list.Add(c.Name(), ls.Class, "class")
-			}
-			return list, nil
+			apiCompletions(&list, da, sem)
+			return list
 
 		case *semantic.Block:
 			for _, sem := range sem.Statements {
@@ -265,24 +263,75 @@ func (s *server) Completions(ctx context.Context, doc *ls.Document, pos ls.Posit
 				}
 			}
 
-		case *semantic.Member:
-			switch obj := underlying(partial(sem.Object)).(type) {
-			case *semantic.Local, *semantic.Global, *semantic.Field, *semantic.Member:
-				switch ty := underlying(typeof(obj)).(type) {
-				case *semantic.Class:
-					for _, f := range ty.Fields {
-						list.Add(f.Name(), ls.Field, typename(f.Type))
-					}
-				}
-			}
-			return list, nil
 		case *semantic.Function:
 			for _, param := range sem.CallParameters() {
 				list.Add(param.Name(), ls.Variable, typename(param.Type))
 			}
 		}
 	}
-	return list, nil
+	return list
+}
+
+// apiCompletions adds the types, functions, globals and constants declared by
+// api and the builtin types and functions to list.
+func apiCompletions(list *ls.CompletionList, da *docAnalysis, api *semantic.API) {
+	for _, t := range semantic.BuiltinTypes {
+		list.Add(t.Name(), ls.Class, "builtin")
+	}
+	for _, f := range builtinFunctions {
+		list.Add(f, ls.Function, "builtin")
+	}
+	for _, f := range api.Functions {
+		list.Add(f.Name(), ls.Function, "cmd")
+	}
+	for _, f := range api.Subroutines {
+		list.Add(f.Name(), ls.Function, "subroutine")
+	}
+	for _, f := range api.Externs {
+		list.Add(f.Name(), ls.Function, "extern")
+	}
+	for _, e := range api.Enums {
+		list.Add(e.Name(), ls.Enum, "enum")
+		for _, entry := range e.Entries {
+			list.Add(entry.Name(), ls.Enum, fmt.Sprintf("%v(%v)", e.Name(), da.full.mappings.CST(entry.AST.Value).Token().String()))
+		}
+	}
+	for _, c := range api.Classes {
+		list.Add(c.Name(), ls.Class, "class")
+	}
+	for _, p := range api.Pseudonyms {
+		list.Add(p.Name(), ls.Class, "type "+typename(p.To))
+	}
+	for _, d := range api.Definitions {
+		list.Add(d.Name(), ls.Value, "define")
+	}
+	for _, g := range api.Globals {
+		list.Add(g.Name(), ls.Variable, typename(g.Type))
+	}
+}
+
+// memberCompletions adds the fields and methods of the type ty to list.
+func memberCompletions(list *ls.CompletionList, ty semantic.Type) {
+	for ty != nil {
+		if owner, ok := ty.(semantic.Owner); ok {
+			owner.VisitMembers(func(m semantic.Owned) {
+				switch m := m.(type) {
+				case *semantic.Field:
+					list.Add(m.Name(), ls.Field, typename(m.Type))
+				case *semantic.Function:
+					list.Add(m.Name(), ls.Method, typename(m.Return.Type))
+				}
+			})
+		}
+		switch t := ty.(type) {
+		case *semantic.Pseudonym:
+			ty = t.To
+		case *semantic.Reference:
+			ty = t.To
+		default:
+			ty = nil
+		}
+	}
 }
 
 // Signatures returns the list of function signatures that are candidates
@@ -294,10 +343,16 @@ func (s *server) Signatures(ctx context.Context, doc *ls.Document, pos ls.Positi
 	if da == nil || err != nil {
 		return nil, 0, 0, err
 	}
-	offset := doc.Body().Offset(pos)
+	sigs, activeParam = da.signatures(doc.Body().Text(), doc.Body().Offset(pos))
+	return sigs, 0, activeParam, nil
+}
+
+// signatures returns the signature of the call enclosing offset and the index
+// of the argument at offset. text is the source of the document.
+func (da *docAnalysis) signatures(text string, offset int) (ls.SignatureList, int) {
 	for _, n := range da.walkUp(offset) {
 		call, ok := partial(n.sem).(*semantic.Call)
-		if !ok {
+		if !ok || call.Target == nil {
 			continue
 		}
 		function := call.Target.Function
@@ -305,19 +360,63 @@ func (s *server) Signatures(ctx context.Context, doc *ls.Document, pos ls.Positi
 			continue
 		}
 		params := ls.ParameterList{}
-		paramIndex := 0
 		for _, p := range function.CallParameters() {
-			params.Add(p.Name(), da.full.documentation(p.AST))
-		}
-		for i, a := range call.AST.Arguments {
-			if tok := da.full.mappings.CST(a).Token(); offset >= tok.Start {
-				paramIndex = i
+			if p == function.This {
+				continue
 			}
+			doc := ""
+			if p.AST != nil {
+				doc = da.full.documentation(p.AST)
+			}
+			params.Add(fmt.Sprintf("%s %s", typename(p.Type), p.Name()), doc)
+		}
+		doc := ""
+		if function.AST != nil {
+			doc = da.full.documentation(function.AST)
+		}
+		sigs := ls.SignatureList{}
+		sigs.Add(signatureLabel(function), doc, params)
+
+		paramIndex := 0
+		if start := da.full.mappings.CST(call.AST).Token().Start; start <= offset {
+			paramIndex = argumentIndex(text[start:offset])
 		}
-		sigs.Add(function.Name(), da.full.documentation(function.AST), params)
-		return sigs, 0, paramIndex, nil
+		return sigs, paramIndex
 	}
-	return nil, 0, 0, nil
+	return nil, 0
+}
+
+// signatureLabel returns the declaration of f up to its parameter list.
+func signatureLabel(f *semantic.Function) string {
+	kind := ast.KeywordCmd
+	switch {
+	case f.Extern:
+		kind = ast.KeywordExtern
+	case f.Subroutine:
+		kind = ast.KeywordSub
+	}
+	return fmt.Sprintf("%s %s %s", kind, typename(f.Return.Type), f.Name())
+}
+
+// argumentIndex returns the index of the argument being written at the end of
+// text, which holds the source of a call up to the cursor.
+func argumentIndex(text string) int {
+	depth, index, inString := 0, 0, false
+	for _, r := range text {
+		switch {
+		case inString:
+			inString = r != '"'
+		case r == '"':
+			inString = true
+		case r == '(' || r == '[' || r == '{':
+			depth++
+		case r == ')' || r == ']' || r == '}':
+			depth--
+		case r == ',' && depth == 1:
+			index++
+		}
+	}
+	return index
 }
 
 // Definitions returns the list of definition locations for the given symbol in
@@ -345,19 +444,15 @@ func (s *server) References(ctx context.Context, doc *ls.Document, pos ls.Positi
 	if da == nil || err != nil {
 		return nil, err
 	}
-	for _, n := range da.walkUp(doc.Body().Offset(pos)) {
-		if _, isIdent := n.ast.(*ast.Identifier); !isIdent {
-			continue
-		}
-		locations := []ls.Location{}
-		for _, n := range da.full.mappings.SemanticToAST[n.sem] {
-			if _, isIdent := n.(*ast.Identifier); isIdent {
-				locations = append(locations, s.nodeLocation(da.full, n))
-			}
-		}
-		return locations, nil
+	sym := da.symbolAt(doc.Body().Offset(pos))
+	if sym == nil {
+		return nil, nil
 	}
-	return nil, nil
+	locations := []ls.Location{}
+	for _, id := range da.full.identifiers(sym) {
+		locations = append(locations, s.nodeLocation(da.full, id))
+	}
+	return locations, nil
 }
 
 // Highlights returns a list of highlights for the given symbol in the
@@ -367,35 +462,42 @@ func (s *server) Highlights(ctx context.Context, doc *ls.Document, pos ls.Positi
 	if da == nil || err != nil {
 		return nil, err
 	}
-	for _, n := range da.walkUp(doc.Body().Offset(pos)) {
-		if _, isIdent := n.ast.(*ast.Identifier); !isIdent {
-			continue
-		}
-		highlights := ls.HighlightList{}
-		for _, n := range da.full.mappings.SemanticToAST[n.sem] {
-			if _, isIdent := n.(*ast.Identifier); isIdent && da.contains(n) {
-				highlights.Add(da.full.nodeRange(doc, n), ls.TextHighlight)
-			}
+	sym := da.symbolAt(doc.Body().Offset(pos))
+	if sym == nil {
+		return nil, nil
+	}
+	highlights := ls.HighlightList{}
+	for _, id := range da.full.identifiers(sym) {
+		if da.contains(id) {
+			highlights.Add(da.full.nodeRange(doc, id), ls.TextHighlight)
 		}
-		return highlights, nil
 	}
-	return nil, nil
+	return highlights, nil
 }
 
 // Format returns a list of edits required to format the the entire document.
 func (s *server) Format(ctx context.Context, doc *ls.Document, opts ls.FormattingOptions) (ls.TextEditList, error) {
+	edits := ls.TextEditList{}
+	if formatted, changed := formatAPI(doc.Path(), doc.Body().Text()); changed {
+		edits.Add(doc.Body().FullRange(), formatted)
+	}
+	return edits, nil
+}
+
+// formatAPI returns the formatted source of the API file at path with the
+// contents text, and whether formatting changed it. Files that fail to parse
+// are not formatted.
+func formatAPI(path, text string) (string, bool) {
 	m := parse.NewCSTMap()
-	ast, err := parser.Parse("", doc.Body().Text(), m)
-	if err != nil {
+	api, errs := parser.Parse(path, text, m)
+	if len(errs) > 0 {
 		// Reformatting ASTs with parse errors?
 		// You're going to have a bad time.
-		return ls.TextEditList{}, nil
+		return text, false
 	}
 	formatted := &bytes.Buffer{}
-	format.Format(ast, m, formatted)
-	edits := ls.TextEditList{}
-	edits.Add(doc.Body().FullRange(), formatted.String())
-	return edits, nil
+	format.Format(api, m, formatted)
+	return formatted.String(), formatted.String() != text
 }
 
 func (s *server) FormatRange(ctx context.Context, doc *ls.Document, rng ls.Range, opts ls.FormattingOptions) (ls.TextEditList, error) {
@@ -406,26 +508,44 @@ func (s *server) FormatRange(ctx context.Context, doc *ls.Document, rng ls.Range
 }
 
 // Rename is called to rename the symbol at pos with newName.
+// All the references to the symbol are renamed, across all the API files.
 func (s *server) Rename(ctx context.Context, doc *ls.Document, pos ls.Position, newName string) (ls.WorkspaceEdit, error) {
 	da, err := s.docAnalysis(ctx, doc)
 	if da == nil || err != nil {
 		return ls.WorkspaceEdit{}, err
 	}
-	offset := doc.Body().Offset(pos)
+	ids, err := da.renameTargets(ctx, doc.Body().Offset(pos), newName)
+	if err != nil {
+		return ls.WorkspaceEdit{}, err
+	}
 	edits := ls.WorkspaceEdit{}
-	for _, n := range da.walkUp(offset) {
-		if _, ok := n.ast.(*ast.Identifier); !ok {
-			continue // We can only sensibly rename identifiers.
-		}
-		for _, n := range da.full.mappings.SemanticToAST[n.sem] {
-			if _, ok := n.(*ast.Identifier); ok {
-				edits.Add(s.nodeLocation(da.full, n), newName)
-			}
-		}
+	for _, id := range ids {
+		edits.Add(s.nodeLocation(da.full, id), newName)
 	}
 	return edits, nil
 }
 
+// renameTargets returns the identifiers to replace with newName to rename the
+// symbol at offset.
+func (da *docAnalysis) renameTargets(ctx context.Context, offset int, newName string) ([]*ast.Identifier, error) {
+	if !isIdentifier(newName) {
+		return nil, log.Errf(ctx, nil, "'%s' is not a valid identifier", newName)
+	}
+	sym := da.symbolAt(offset)
+	if sym == nil {
+		return nil, log.Err(ctx, nil, "No symbol found to rename")
+	}
+	if declarationName(sym) == nil {
+		return nil, log.Err(ctx, nil, "Symbol is not declared in an API file")
+	}
+	if owned, ok := sym.(semantic.Owned); ok && owned.Owner() != nil {
+		if owned.Owner().Member(newName) != nil {
+			return nil, log.Errf(ctx, nil, "'%s' is already declared in %s", newName, owned.Owner().Name())
+		}
+	}
+	return da.full.identifiers(sym), nil
+}
+
 // Hover returns a list of source code snippets and range for the given
 // symbol at the specified position.
 func (s *server) Hover(ctx context.Context, doc *ls.Document, pos ls.Position) (ls.SourceCodeList, ls.Range, error) {
@@ -0,0 +1,183 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	ls "github.com/google/gapid/core/langsvr"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapil/ast"
+	"github.com/google/gapid/gapil/parser"
+	"github.com/google/gapid/gapil/resolver"
+)
+
+const testAPI = `// Point is a position.
+class Point {
+  s32 X
+  s32 Y
+}
+
+// sum adds a and b.
+sub s32 sum(s32 a, s32 b) {
+  return a + b
+}
+
+cmd void move(s32 dx, s32 dy) {
+  p := Point(X: dx, Y: dy)
+  total := sum(p.X, p.Y)
+}
+`
+
+// analyze parses and resolves source, returning the analysis of the document.
+func analyze(ctx context.Context, source string) *docAnalysis {
+	m := resolver.NewMappings()
+	api, errs := parser.Parse("langsvr_test.api", source, m)
+	assert.For(ctx, "parse errors").That(errs).IsNil()
+	_, errs = resolver.Resolve([]*ast.API{api}, nil, m)
+	assert.For(ctx, "resolve errors").That(errs).IsNil()
+	full := &fullAnalysis{mappings: m}
+	return &docAnalysis{full: full, ast: api}
+}
+
+// offsetOf returns the offset of the n'th occurrence of substr in source.
+func offsetOf(source, substr string, n int) int {
+	offset := 0
+	for i := 0; i < n; i++ {
+		offset += strings.Index(source[offset:], substr) + len(substr)
+	}
+	return offset + strings.Index(source[offset:], substr)
+}
+
+func labels(list ls.CompletionList) []string {
+	out := []string{}
+	for _, item := range list.Items {
+		out = append(out, item.Label)
+	}
+	return out
+}
+
+func hasLabel(list ls.CompletionList, label string) bool {
+	for _, item := range list.Items {
+		if item.Label == label {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCompletions(t *testing.T) {
+	ctx := log.Testing(t)
+	da := analyze(ctx, testAPI)
+
+	api := da.completions(offsetOf(testAPI, "\n\n", 0))
+	for _, name := range []string{"Point", "sum", "move", "s32", "len"} {
+		assert.For(ctx, "API completion %v", name).That(hasLabel(api, name)).Equals(true)
+	}
+
+	members := da.completions(offsetOf(testAPI, "X, p.Y", 0))
+	assert.For(ctx, "member completions").ThatSlice(labels(members)).Equals([]string{"X", "Y"})
+
+	locals := da.completions(offsetOf(testAPI, "sum(p", 0))
+	for _, name := range []string{"p", "dx", "dy"} {
+		assert.For(ctx, "local completion %v", name).That(hasLabel(locals, name)).Equals(true)
+	}
+}
+
+func TestSignatures(t *testing.T) {
+	ctx := log.Testing(t)
+	da := analyze(ctx, testAPI)
+
+	for _, test := range []struct {
+		at    string
+		param int
+	}{
+		{"p.X, p.Y)", 0},
+		{"p.Y)", 1},
+	} {
+		sigs, param := da.signatures(testAPI, offsetOf(testAPI, test.at, 0))
+		assert.For(ctx, "signatures at %v", test.at).That(len(sigs)).Equals(1)
+		if len(sigs) != 1 {
+			continue
+		}
+		assert.For(ctx, "label").ThatString(sigs[0].Label).Equals("sub s32 sum")
+		assert.For(ctx, "documentation").ThatString(sigs[0].Documentation).Contains("sum adds a and b.")
+		assert.For(ctx, "parameters").That(len(sigs[0].Parameters)).Equals(2)
+		assert.For(ctx, "active parameter at %v", test.at).That(param).Equals(test.param)
+	}
+
+	sigs, _ := da.signatures(testAPI, offsetOf(testAPI, "\n\n", 0))
+	assert.For(ctx, "signatures outside call").That(len(sigs)).Equals(0)
+}
+
+func TestArgumentIndex(t *testing.T) {
+	assert := assert.To(t)
+	for _, test := range []struct {
+		text  string
+		index int
+	}{
+		{"f(", 0},
+		{"f(a", 0},
+		{"f(a, ", 1},
+		{"f(a, g(b, c), ", 2},
+		{"f(a, \"x, y\", [1, 2], ", 3},
+	} {
+		assert.For("%s", test.text).That(argumentIndex(test.text)).Equals(test.index)
+	}
+}
+
+func TestRename(t *testing.T) {
+	ctx := log.Testing(t)
+	da := analyze(ctx, testAPI)
+
+	ids, err := da.renameTargets(ctx, offsetOf(testAPI, "X", 0), "Z")
+	assert.For(ctx, "rename field").ThatError(err).Succeeded()
+	assert.For(ctx, "field identifiers").That(len(ids)).Equals(3)
+	for _, id := range ids {
+		assert.For(ctx, "identifier").ThatString(id.Value).Equals("X")
+	}
+
+	ids, err = da.renameTargets(ctx, offsetOf(testAPI, "dx", 1), "deltaX")
+	assert.For(ctx, "rename parameter").ThatError(err).Succeeded()
+	assert.For(ctx, "parameter identifiers").That(len(ids)).Equals(2)
+
+	_, err = da.renameTargets(ctx, offsetOf(testAPI, "X", 0), "cmd")
+	assert.For(ctx, "rename to keyword").ThatError(err).Failed()
+
+	_, err = da.renameTargets(ctx, offsetOf(testAPI, "X", 0), "Y")
+	assert.For(ctx, "rename to existing field").ThatError(err).Failed()
+
+	_, err = da.renameTargets(ctx, offsetOf(testAPI, "\n\n", 0), "Z")
+	assert.For(ctx, "rename without symbol").ThatError(err).Failed()
+}
+
+func TestFormat(t *testing.T) {
+	ctx := log.Testing(t)
+
+	formatted, changed := formatAPI("langsvr_test.api", "cmd void  f( s32 a ) {\n}\n")
+	assert.For(ctx, "unformatted changed").That(changed).Equals(true)
+
+	again, changed := formatAPI("langsvr_test.api", formatted)
+	assert.For(ctx, "formatted changed").That(changed).Equals(false)
+	assert.For(ctx, "formatted").ThatString(again).Equals(formatted)
+
+	broken := "cmd void f( {\n"
+	text, changed := formatAPI("langsvr_test.api", broken)
+	assert.For(ctx, "parse error changed").That(changed).Equals(false)
+	assert.For(ctx, "parse error text").ThatString(text).Equals(broken)
+}
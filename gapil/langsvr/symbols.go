@@ -0,0 +1,177 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"regexp"
+	"sort"
+
+	"github.com/google/gapid/gapil/ast"
+	"github.com/google/gapid/gapil/semantic"
+)
+
+// builtinFunctions are the names of the functions built into the API
+// language.
+var builtinFunctions = []string{
+	"as", "assert", "clone", "copy", "len", "make", "new", "read", "write",
+}
+
+// keywords are the words which cannot be used as identifiers.
+var keywords = map[string]bool{}
+
+func init() {
+	for _, k := range []string{
+		ast.KeywordAbort, ast.KeywordAPI, ast.KeywordAlias, ast.KeywordBitfield,
+		ast.KeywordCase, ast.KeywordClass, ast.KeywordCmd, ast.KeywordConst,
+		ast.KeywordDefault, ast.KeywordDefine, ast.KeywordDelete, ast.KeywordElse,
+		ast.KeywordEnum, ast.KeywordExtern, ast.KeywordFalse, ast.KeywordFence,
+		ast.KeywordFor, ast.KeywordIf, ast.KeywordImport, ast.KeywordIn,
+		ast.KeywordLabel, ast.KeywordNull, ast.KeywordReturn, ast.KeywordPseudonym,
+		ast.KeywordSwitch, ast.KeywordSub, ast.KeywordThis, ast.KeywordTrue,
+		ast.KeywordWhen, ast.KeywordApiIndex,
+	} {
+		keywords[k] = true
+	}
+	for _, t := range semantic.BuiltinTypes {
+		keywords[t.Name()] = true
+	}
+}
+
+var identifierRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// isIdentifier returns true if name can be used as the name of a symbol.
+func isIdentifier(name string) bool {
+	return identifierRE.MatchString(name) && !keywords[name]
+}
+
+// symbol returns the declared symbol that the semantic node sem refers to.
+func symbol(sem semantic.Node) semantic.Node {
+	switch sem := partial(sem).(type) {
+	case *semantic.Callable:
+		return sem.Function
+	case *semantic.ClassInitializer:
+		return sem.Class
+	case *semantic.DeclareLocal:
+		return sem.Local
+	case *semantic.DefinitionUsage:
+		return sem.Definition
+	case *semantic.Member:
+		return sem.Field
+	default:
+		return sem
+	}
+}
+
+// declarationName returns the identifier that names the symbol sym in its
+// declaration, or nil if sym is not declared in an API file.
+func declarationName(sym semantic.Node) *ast.Identifier {
+	switch sym := sym.(type) {
+	case *semantic.Class:
+		if sym.AST != nil {
+			return sym.AST.Name
+		}
+	case *semantic.Definition:
+		if sym.AST != nil {
+			return sym.AST.Name
+		}
+	case *semantic.Enum:
+		if sym.AST != nil {
+			return sym.AST.Name
+		}
+	case *semantic.EnumEntry:
+		if sym.AST != nil {
+			return sym.AST.Name
+		}
+	case *semantic.Field:
+		if sym.AST != nil {
+			return sym.AST.Name
+		}
+	case *semantic.Function:
+		if sym.AST != nil {
+			return sym.AST.Generic.Name
+		}
+	case *semantic.Global:
+		if sym.AST != nil {
+			return sym.AST.Name
+		}
+	case *semantic.Local:
+		if sym.Declaration != nil && sym.Declaration.AST != nil {
+			return sym.Declaration.AST.Name
+		}
+	case *semantic.Parameter:
+		if sym.AST != nil {
+			return sym.AST.Name
+		}
+	case *semantic.Pseudonym:
+		if sym.AST != nil {
+			return sym.AST.Name
+		}
+	}
+	return nil
+}
+
+// symbolAt returns the symbol named by the identifier at offset, or nil if
+// there is no identifier at offset.
+func (da *docAnalysis) symbolAt(offset int) semantic.Node {
+	var ident *ast.Identifier
+	for _, n := range da.walkUp(offset) {
+		if id, ok := n.ast.(*ast.Identifier); ok && ident == nil {
+			ident = id
+		}
+		if ident == nil || n.sem == nil {
+			continue
+		}
+		sym := symbol(n.sem)
+		// Declaration names are not always mapped to the declared symbol, in
+		// which case the symbol is found on the declaring node.
+		if n.ast == ident || declarationName(sym) == ident {
+			return sym
+		}
+		return nil
+	}
+	return nil
+}
+
+// identifiers returns all the identifiers that refer to the symbol sym,
+// ordered by file and position.
+func (fa *fullAnalysis) identifiers(sym semantic.Node) []*ast.Identifier {
+	seen := map[*ast.Identifier]bool{}
+	out := []*ast.Identifier{}
+	add := func(id *ast.Identifier) {
+		if id != nil && !seen[id] && fa.mappings.CST(id) != nil {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	add(declarationName(sym))
+	for sem, asts := range fa.mappings.SemanticToAST {
+		if symbol(sem) != sym {
+			continue
+		}
+		for _, n := range asts {
+			if id, ok := n.(*ast.Identifier); ok {
+				add(id)
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := fa.mappings.CST(out[i]).Token(), fa.mappings.CST(out[j]).Token()
+		if a.Source.Filename != b.Source.Filename {
+			return a.Source.Filename < b.Source.Filename
+		}
+		return a.Start < b.Start
+	})
+	return out
+}
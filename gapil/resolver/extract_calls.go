@@ -25,63 +25,110 @@ func extractCalls(rv *resolver, block *semantic.Block) {
 	}
 	rv.with(semantic.VoidType, func() {
 		for i := 0; i < len(block.Statements); i++ {
-			var parent interface{}
-			inSelect := false
-			var traverse func(n semantic.Node)
-			var traverseExpressions func(n []semantic.Expression)
-			visit := func(n semantic.Node) semantic.Node {
-				switch n := n.(type) {
-				case *semantic.Call:
-					traverseExpressions(n.Arguments)
-
-					if !n.Target.Function.Subroutine {
-						return n // Can't extract a call to a non-subroutine.
-					}
-					if parent == nil {
-						return n // Can't extract a call any more than a call statement.
-					}
-					if _, ok := parent.(*semantic.DeclareLocal); ok {
-						return n // No point extracting a call when it's already just an assignment.
-					}
-					if inSelect {
-						rv.errorf(n, "Cannot call subroutines inside select expressions.")
-						return n
-					}
-					decl := rv.declareTemporaryLocal(n)
-					block.Statements.InsertBefore(decl, i)
-					i++ // +1 for new injected statement
-					return decl.Local
-				case *semantic.Block:
-					rv.with(semantic.VoidType, func() { extractCalls(rv, n) })
-				case *semantic.Select:
-					wasInSelect := false
-					inSelect = true
-					traverse(n)
-					inSelect = wasInSelect
-				case semantic.Type, *semantic.Callable, semantic.Invalid:
-					// Don't traverse into these.
-				default:
-					traverse(n)
+			extract := func(n *semantic.Call, parent interface{}, inSelect bool) semantic.Expression {
+				if !n.Target.Function.Subroutine {
+					return n // Can't extract a call to a non-subroutine.
 				}
-				return n
-			}
-			with := func(p interface{}, f func()) {
-				oldParent := parent
-				parent = p
-				f()
-				parent = oldParent
+				if parent == nil {
+					return n // Can't extract a call any more than a call statement.
+				}
+				if _, ok := parent.(*semantic.DeclareLocal); ok {
+					return n // No point extracting a call when it's already just an assignment.
+				}
+				if inSelect {
+					rv.errorf(n, "Cannot call subroutines inside select expressions.")
+					return n
+				}
+				decl := rv.declareTemporaryLocal(n)
+				block.Statements.InsertBefore(decl, i)
+				i++ // +1 for new injected statement
+				return decl.Local
 			}
-			traverse = func(n semantic.Node) {
-				with(n, func() { semantic.Replace(n, visit) })
+			traverseCalls(block.Statements[i], extract, func(n *semantic.Block) {
+				rv.with(semantic.VoidType, func() { extractCalls(rv, n) })
+			})
+		}
+	})
+}
+
+// traverseCalls calls visit for each call expression in the statement s,
+// replacing the call with the returned expression. visit is passed the parent
+// of the call, and whether the call is inside a select expression. The blocks
+// nested in s are not traversed, instead they are passed to block.
+func traverseCalls(s semantic.Node,
+	visit func(n *semantic.Call, parent interface{}, inSelect bool) semantic.Expression,
+	block func(n *semantic.Block)) {
+
+	var parent interface{}
+	inSelect := false
+	var traverse func(n semantic.Node)
+	var traverseExpressions func(n []semantic.Expression)
+	replace := func(n semantic.Node) semantic.Node {
+		switch n := n.(type) {
+		case *semantic.Call:
+			traverseExpressions(n.Arguments)
+			return visit(n, parent, inSelect)
+		case *semantic.Block:
+			block(n)
+		case *semantic.Select:
+			wasInSelect := false
+			inSelect = true
+			traverse(n)
+			inSelect = wasInSelect
+		case semantic.Type, *semantic.Callable, semantic.Invalid:
+			// Don't traverse into these.
+		default:
+			traverse(n)
+		}
+		return n
+	}
+	with := func(p interface{}, f func()) {
+		oldParent := parent
+		parent = p
+		f()
+		parent = oldParent
+	}
+	traverse = func(n semantic.Node) {
+		with(n, func() { semantic.Replace(n, replace) })
+	}
+	traverseExpressions = func(n []semantic.Expression) {
+		with(n, func() {
+			for i, a := range n {
+				n[i] = replace(a).(semantic.Expression)
 			}
-			traverseExpressions = func(n []semantic.Expression) {
-				with(n, func() {
-					for i, a := range n {
-						n[i] = visit(a).(semantic.Expression)
-					}
-				})
+		})
+	}
+	traverse(s)
+}
+
+// SubroutineCalls returns the distinct subroutines directly called by the
+// function f, in the order they are first called. The calls are found with
+// the same traversal that the resolver uses to extract subroutine calls.
+func SubroutineCalls(f *semantic.Function) []*semantic.Function {
+	out := []*semantic.Function{}
+	seen := map[*semantic.Function]bool{}
+	add := func(n *semantic.Call) {
+		if s := n.Target.Function; s.Subroutine && !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	visit := func(n *semantic.Call, parent interface{}, inSelect bool) semantic.Expression {
+		add(n)
+		return n
+	}
+	var traverseBlock func(b *semantic.Block)
+	traverseBlock = func(b *semantic.Block) {
+		for _, s := range b.Statements {
+			traverseCalls(s, visit, traverseBlock)
+			if call, ok := s.(*semantic.Call); ok {
+				// Call statements are called after their arguments.
+				add(call)
 			}
-			traverse(block.Statements[i])
 		}
-	})
+	}
+	if f.Block != nil {
+		traverseBlock(f.Block)
+	}
+	return out
 }
@@ -542,3 +542,32 @@ func TestApiIndex(t *testing.T) {
 		test.check(ctx)
 	}
 }
+
+func TestSubroutineCalls(t *testing.T) {
+	ctx := log.Testing(t)
+	api := test{
+		name: "Subroutine calls",
+		source: `
+sub u32 a(u32 x) { return x }
+sub u32 b(u32 x) { return x }
+sub void c(u32 x) {}
+extern u32 e()
+cmd void f(u32 x) {
+  c(a(x))
+  if x > 2 {
+    y := b(a(x)) + e()
+  }
+}`,
+	}.check(ctx)
+	var f *semantic.Function
+	for _, fn := range api.Functions {
+		if fn.Name() == "f" {
+			f = fn
+		}
+	}
+	names := []string{}
+	for _, s := range resolver.SubroutineCalls(f) {
+		names = append(names, s.Name())
+	}
+	assert.For(ctx, "calls").ThatSlice(names).Equals([]string{"a", "c", "b"})
+}
@@ -30,6 +30,7 @@ import (
 	"github.com/google/gapid/core/os/device"
 	"github.com/google/gapid/core/os/device/bind"
 	"github.com/google/gapid/core/os/device/host"
+	"github.com/google/gapid/core/os/device/remote"
 	"github.com/google/gapid/core/os/process"
 	"github.com/google/gapid/core/os/shell"
 	"github.com/google/gapid/core/text"
@@ -42,7 +43,7 @@ const sessionTimeout = time.Second * 10
 
 type session struct {
 	device   bind.Device
-	port     int
+	addr     string
 	auth     auth.Token
 	closeCBs []func()
 	inited   chan struct{}
@@ -60,6 +61,8 @@ func (s *session) init(ctx context.Context, d bind.Device, abi *device.ABI, laun
 		err = s.newHost(ctx, d, launchArgs)
 	} else if d, ok := d.(adb.Device); ok {
 		err = s.newADB(ctx, d, abi)
+	} else if d, ok := d.(remote.Device); ok {
+		err = s.newRemote(ctx, d, launchArgs)
 	} else {
 		err = log.Errf(ctx, nil, "Cannot connect to device type %v", d)
 	}
@@ -130,7 +133,7 @@ func (s *session) newHost(ctx context.Context, d bind.Device, launchArgs []strin
 		return nil
 	}
 
-	s.port = port
+	s.addr = fmt.Sprintf("localhost:%d", port)
 	s.auth = authToken
 	return nil
 }
@@ -156,7 +159,7 @@ func (s *session) newADB(ctx context.Context, d adb.Device, abi *device.ABI) err
 	if err != nil {
 		return log.Err(ctx, err, "Finding free port")
 	}
-	s.port = int(localPort)
+	s.addr = fmt.Sprintf("localhost:%d", localPort)
 	socket, ok := socketNames[abi.Architecture]
 	ctx = log.V{"socket": socket}.Bind(ctx)
 	if !ok {
@@ -172,6 +175,34 @@ func (s *session) newADB(ctx context.Context, d adb.Device, abi *device.ABI) err
 		return err
 	}
 
+	return s.waitForConnection(ctx)
+}
+
+// newRemote spawns a new GAPIR instance on the remote host through its agent.
+func (s *session) newRemote(ctx context.Context, d remote.Device, launchArgs []string) error {
+	ctx = log.V{"agent": d.Addr()}.Bind(ctx)
+
+	authToken := auth.GenToken()
+	args := []string{
+		"--idle-timeout-ms", strconv.Itoa(int(sessionTimeout / time.Millisecond)),
+		"--auth-token", string(authToken),
+	}
+	args = append(args, launchArgs...)
+
+	log.I(ctx, "Starting gapir on remote host: %v", args)
+	addr, stop, err := d.StartGapir(ctx, args)
+	if err != nil {
+		return err
+	}
+	s.onClose(stop)
+	s.addr = addr
+	s.auth = authToken
+
+	return s.waitForConnection(ctx)
+}
+
+// waitForConnection waits for GAPIR to respond to pings.
+func (s *session) waitForConnection(ctx context.Context) error {
 	log.I(ctx, "Waiting for connection to GAPIR...")
 	for i := 0; i < 10; i++ {
 		if _, err := s.ping(ctx); err == nil {
@@ -186,7 +217,7 @@ func (s *session) newADB(ctx context.Context, d adb.Device, abi *device.ABI) err
 
 func (s *session) connect(ctx context.Context) (io.ReadWriteCloser, error) {
 	<-s.inited
-	return process.Connect(s.port, s.auth)
+	return process.ConnectAddr(s.addr, s.auth)
 }
 
 func (s *session) onClose(f func()) {
@@ -201,7 +232,7 @@ func (s *session) close() {
 }
 
 func (s *session) ping(ctx context.Context) (time.Duration, error) {
-	connection, err := process.Connect(s.port, s.auth)
+	connection, err := process.ConnectAddr(s.addr, s.auth)
 	if err != nil {
 		return 0, err
 	}
@@ -36,11 +36,11 @@ import (
 	"github.com/pkg/errors"
 )
 
-// The list of captures currently imported.
+// The captures currently imported, by the database holding them.
 // TODO: This needs to be moved to persistent storage.
 var (
 	capturesLock sync.RWMutex
-	captures     = []id.ID{}
+	captures     = map[database.Database][]id.ID{}
 )
 
 type Capture struct {
@@ -67,12 +67,31 @@ func New(ctx context.Context, name string, header *Header, atoms []atom.Atom) (*
 	if err != nil {
 		return nil, err
 	}
+	add(ctx, id)
 
+	return &path.Capture{Id: path.NewID(id)}, nil
+}
+
+// add adds the capture with the given identifier to the list of captures of
+// the database held by the context.
+func add(ctx context.Context, id id.ID) {
+	d := database.Get(ctx)
 	capturesLock.Lock()
-	captures = append(captures, id)
-	capturesLock.Unlock()
+	defer capturesLock.Unlock()
+	for _, c := range captures[d] {
+		if c == id {
+			return
+		}
+	}
+	captures[d] = append(captures[d], id)
+}
 
-	return &path.Capture{Id: path.NewID(id)}, nil
+// Release forgets all the captures imported into the database d. It is called
+// when d is no longer used.
+func Release(d database.Database) {
+	capturesLock.Lock()
+	defer capturesLock.Unlock()
+	delete(captures, d)
 }
 
 // NewState returns a new, default-initialized State object built for the
@@ -122,12 +141,14 @@ type AtomsImportHandler interface {
 	TransformAtomStream(context.Context, []atom.Atom) ([]atom.Atom, error)
 }
 
-// Captures returns all the captures stored by the database by identifier.
-func Captures() []*path.Capture {
+// Captures returns all the captures stored by the database held by the
+// context, by identifier.
+func Captures(ctx context.Context) []*path.Capture {
 	capturesLock.RLock()
 	defer capturesLock.RUnlock()
-	out := make([]*path.Capture, len(captures))
-	for i, c := range captures {
+	ids := captures[database.Get(ctx)]
+	out := make([]*path.Capture, len(ids))
+	for i, c := range ids {
 		out[i] = &path.Capture{Id: path.NewID(c)}
 	}
 	return out
@@ -156,10 +177,7 @@ func Import(ctx context.Context, name string, data []byte) (*path.Capture, error
 	if err != nil {
 		return nil, err
 	}
-
-	capturesLock.Lock()
-	captures = append(captures, id)
-	capturesLock.Unlock()
+	add(ctx, id)
 
 	return &path.Capture{Id: path.NewID(id)}, nil
 }
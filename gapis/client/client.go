@@ -41,6 +41,19 @@ func Bind(conn *grpc.ClientConn) Client {
 	return &client{service.NewGapidClient(conn), conn.Close}
 }
 
+// bindSession creates a new rpc client using conn for communication, that
+// closes the server session with the given identifier when it is closed.
+func bindSession(conn *grpc.ClientConn, id string) Client {
+	c := &client{client: service.NewGapidClient(conn)}
+	c.close = func() error {
+		// The session may have already been closed by the server, so errors
+		// are ignored.
+		c.CloseSession(context.Background(), id)
+		return conn.Close()
+	}
+	return c
+}
+
 // New creates a new client using c for communication.
 func New(c service.GapidClient) service.Service {
 	return &client{c, func() error { return nil }}
@@ -173,6 +186,19 @@ func (c *client) GetStringTable(ctx context.Context, i *stringtable.Info) (*stri
 	return res.GetTable(), nil
 }
 
+func (c *client) GetPreferredStringTable(ctx context.Context, cultureCodes []string) (*stringtable.StringTable, error) {
+	res, err := c.client.GetPreferredStringTable(ctx, &service.GetPreferredStringTableRequest{
+		CultureCodes: cultureCodes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := res.GetError(); err != nil {
+		return nil, err.Get()
+	}
+	return res.GetTable(), nil
+}
+
 func (c *client) ImportCapture(ctx context.Context, name string, data []byte) (*path.Capture, error) {
 	res, err := c.client.ImportCapture(ctx, &service.ImportCaptureRequest{
 		Name: name,
@@ -281,3 +307,47 @@ func (c *client) Find(ctx context.Context, req *service.FindRequest, handler ser
 	h := func(ctx context.Context, m *service.FindResponse) error { return handler(m) }
 	return event.Feed(ctx, event.AsHandler(ctx, h), grpcutil.ToProducer(stream))
 }
+
+func (c *client) CreateSession(ctx context.Context) (*service.Session, error) {
+	res, err := c.client.CreateSession(ctx, &service.CreateSessionRequest{})
+	if err != nil {
+		return nil, err
+	}
+	if err := res.GetError(); err != nil {
+		return nil, err.Get()
+	}
+	return res.GetSession(), nil
+}
+
+func (c *client) GetSessions(ctx context.Context) ([]*service.Session, error) {
+	res, err := c.client.GetSessions(ctx, &service.GetSessionsRequest{})
+	if err != nil {
+		return nil, err
+	}
+	if err := res.GetError(); err != nil {
+		return nil, err.Get()
+	}
+	return res.GetSessions().List, nil
+}
+
+func (c *client) CloseSession(ctx context.Context, id string) error {
+	res, err := c.client.CloseSession(ctx, &service.CloseSessionRequest{Id: id})
+	if err != nil {
+		return err
+	}
+	if err := res.GetError(); err != nil {
+		return err.Get()
+	}
+	return nil
+}
+
+func (c *client) PurgeSession(ctx context.Context, id string) error {
+	res, err := c.client.PurgeSession(ctx, &service.PurgeSessionRequest{Id: id})
+	if err != nil {
+		return err
+	}
+	if err := res.GetError(); err != nil {
+		return err.Get()
+	}
+	return nil
+}
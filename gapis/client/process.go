@@ -25,7 +25,11 @@ import (
 	"github.com/google/gapid/core/os/device/host"
 	"github.com/google/gapid/core/os/file"
 	"github.com/google/gapid/core/os/process"
+	"github.com/google/gapid/gapis/service"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	xctx "golang.org/x/net/context"
 )
 
 const (
@@ -70,6 +74,10 @@ type Config struct {
 	Port  int
 	Args  []string
 	Token auth.Token
+	// Session is the identifier of the server session to use.
+	// If empty, a new session is used for the connection, and closed when the
+	// client is closed.
+	Session string
 }
 
 // Connect attempts to connect to a GAPIS process.
@@ -100,15 +108,46 @@ func Connect(ctx context.Context, cfg Config) (Client, error) {
 
 	target := fmt.Sprintf("localhost:%d", cfg.Port)
 
+	authInterceptor := auth.ClientInterceptor(cfg.Token)
+
 	conn, err := grpcutil.Dial(ctx, target,
 		grpc.WithInsecure(),
-		grpc.WithUnaryInterceptor(auth.ClientInterceptor(cfg.Token)))
+		grpc.WithUnaryInterceptor(func(ctx xctx.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			return authInterceptor(withSession(ctx, cfg.Session), method, req, reply, cc, invoker, opts...)
+		}),
+		grpc.WithStreamInterceptor(func(ctx xctx.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return streamer(withSession(ctx, cfg.Session), desc, cc, method, opts...)
+		}))
 	if err != nil {
 		return nil, log.Err(ctx, err, "Dialing GAPIS")
 	}
-	client := Bind(conn)
+	if cfg.Session != "" {
+		return Bind(conn), nil
+	}
 
-	return client, nil
+	// Open a new session for the connection. Its identifier is issued by the
+	// server, and used by all the following requests.
+	session, err := Bind(conn).CreateSession(ctx)
+	if err != nil {
+		conn.Close()
+		return nil, log.Err(ctx, err, "Creating GAPIS session")
+	}
+	cfg.Session = session.Id
+	return bindSession(conn, cfg.Session), nil
+}
+
+// withSession returns a new context derived from ctx that holds the RPC
+// metadata for the given session identifier. An empty identifier leaves ctx
+// unaltered, using the server's default session.
+func withSession(ctx xctx.Context, session string) xctx.Context {
+	if session == "" {
+		return ctx
+	}
+	pair := metadata.Pairs(service.SessionHeader, session)
+	if md, ok := metadata.FromContext(ctx); ok {
+		return metadata.NewContext(ctx, metadata.Join(md, pair))
+	}
+	return metadata.NewContext(ctx, pair)
 }
 
 func logLevel(ctx context.Context) log.Severity {
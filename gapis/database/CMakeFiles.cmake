@@ -21,7 +21,9 @@ set(files
     database.go
     hash.go
     memory.go
+    memory_test.go
     resolvable.go
+    to_proto.go
 )
 set(dirs
     
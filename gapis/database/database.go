@@ -33,6 +33,12 @@ type Database interface {
 	resolve(context.Context, id.ID) (interface{}, error)
 	// containts returns true if the database has an entry for the specified id.
 	contains(context.Context, id.ID) bool
+	// usage returns the number of bytes of resolved data charged to the
+	// database's budget.
+	usage() uint64
+	// purge discards the cached results of all resolved Resolvables, returning
+	// the number of results discarded.
+	purge() int
 }
 
 // Store stores v to the database held by the context.
@@ -59,6 +65,25 @@ func Resolve(ctx context.Context, id id.ID) (interface{}, error) {
 	return Get(ctx).resolve(ctx, id)
 }
 
+// Contains returns true if the database held by the context has an entry for
+// the specified id.
+func Contains(ctx context.Context, id id.ID) bool {
+	return Get(ctx).contains(ctx, id)
+}
+
+// Usage returns the number of bytes of resolved data charged to the budget of
+// d. Databases without a budget do not measure their data and return 0.
+func Usage(d Database) uint64 {
+	return d.usage()
+}
+
+// Purge discards the cached results of all the resolved Resolvables held by
+// d, returning the number of results discarded. Purged Resolvables will be
+// re-resolved on next use.
+func Purge(d Database) int {
+	return d.purge()
+}
+
 // Build stores resolvable into d, and then resolves and returns the resolved
 // object.
 func Build(ctx context.Context, r Resolvable) (interface{}, error) {
@@ -88,3 +113,9 @@ func Put(ctx context.Context, d Database) context.Context {
 	}
 	return keys.WithValue(ctx, databaseKey, d)
 }
+
+// Replace amends a Context by attaching a Database reference to it, shadowing
+// any Database already held by the context.
+func Replace(ctx context.Context, d Database) context.Context {
+	return keys.WithValue(ctx, databaseKey, d)
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"sort"
 	"sync"
 
 	"github.com/golang/protobuf/proto"
@@ -29,9 +30,20 @@ import (
 
 // NewInMemory builds a new in memory database.
 func NewInMemory(ctx context.Context) Database {
-	m := &memory{}
+	return NewInMemoryWithBudget(ctx, 0)
+}
+
+// NewInMemoryWithBudget builds a new in memory database that holds no more than
+// budget bytes of resolved Resolvable results, measured by the size of their
+// encoded proto. When a resolve exceeds the budget, the results of the least
+// recently used Resolvables are evicted, to be rebuilt on next use. Stored
+// data that is not the result of a Resolvable cannot be rebuilt, so it is
+// neither charged against the budget nor evicted.
+// A budget of 0 is unlimited.
+func NewInMemoryWithBudget(ctx context.Context, budget uint64) Database {
+	m := &memory{budget: budget}
 	m.records = map[id.ID]*record{}
-	m.resolveCtx = Put(ctx, m)
+	m.resolveCtx = Replace(ctx, m)
 	return m
 }
 
@@ -39,6 +51,8 @@ type record struct {
 	proto        proto.Message
 	object       interface{}
 	resolveState *resolveState
+	size         uint64 // Size of the encoded resolved object charged to the budget.
+	used         uint64 // Value of memory.clock when the record was last used.
 }
 
 type resolveState struct {
@@ -81,6 +95,9 @@ type memory struct {
 	mutex      sync.Mutex
 	records    map[id.ID]*record
 	resolveCtx context.Context
+	budget     uint64 // Maximum number of bytes of resolved data. 0 is unlimited.
+	size       uint64 // Number of bytes of resolved data held.
+	clock      uint64 // Incremented each time a record is used.
 }
 
 // Implements Database
@@ -95,10 +112,14 @@ func (d *memory) storeLocked(ctx context.Context, id id.ID, v interface{}, m pro
 	if v == nil && m == nil {
 		panic(fmt.Errorf("Store nil in database (that is bad), id '%v'", id))
 	}
+	d.clock++
 	r, got := d.records[id]
 	if !got {
-		d.records[id] = &record{object: v, proto: m}
-	} else if config.DebugDatabaseVerify {
+		d.records[id] = &record{object: v, proto: m, used: d.clock}
+		return nil
+	}
+	r.used = d.clock
+	if config.DebugDatabaseVerify {
 		if !reflect.DeepEqual(m, r.proto) {
 			return fmt.Errorf("Duplicate object id %v", id)
 		}
@@ -122,6 +143,8 @@ func (d *memory) resolveLocked(ctx context.Context, id id.ID) (interface{}, erro
 		// Database doesn't recognise this identifier.
 		return nil, fmt.Errorf("Resource '%v' not found", id)
 	}
+	d.clock++
+	r.used = d.clock
 
 	// TODO: Don't kick a go-routine if the record doesn't need resolving.
 
@@ -142,11 +165,20 @@ func (d *memory) resolveLocked(ctx context.Context, id id.ID) (interface{}, erro
 		// Build the resolvable on a separate go-routine.
 		go func() {
 			err := r.resolve(rs.ctx)
+			size := uint64(0)
+			if err == nil {
+				size = d.sizeOf(rs.ctx, r)
+			}
 
 			// Signal that the resolvable has finished.
 			d.mutex.Lock()
 			close(rs.finished)
 			rs.err, rs.finished = err, nil
+			if size > 0 && r.resolveState == rs { // Not cancelled.
+				r.size = size
+				d.size += size
+				d.evictLocked(r)
+			}
 			d.mutex.Unlock()
 		}()
 	}
@@ -192,3 +224,86 @@ func (d *memory) contains(ctx context.Context, id id.ID) (res bool) {
 	_, got := d.records[id]
 	return got
 }
+
+// Implements Database
+func (d *memory) usage() uint64 {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.size
+}
+
+// Implements Database
+func (d *memory) purge() int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	count := 0
+	for _, r := range d.records {
+		if r.evictable() {
+			r.evict()
+			d.size -= r.size
+			r.size = 0
+			count++
+		}
+	}
+	return count
+}
+
+// sizeOf returns the number of bytes to charge to the budget for the
+// resolved object of the record r. Objects are only measured if the database
+// has a budget, as encoding them can be expensive.
+func (d *memory) sizeOf(ctx context.Context, r *record) uint64 {
+	if d.budget == 0 {
+		return 0
+	}
+	if _, ok := r.proto.(Resolvable); !ok {
+		return 0 // Not a cached result.
+	}
+	m, err := toProto(ctx, r.object)
+	if err != nil {
+		return 0 // Cannot be measured.
+	}
+	return uint64(proto.Size(m))
+}
+
+// evictable returns true if the record holds the result of a resolved
+// Resolvable that no go-routine is waiting on, which can be dropped and
+// rebuilt from the proto.
+func (r *record) evictable() bool {
+	if _, ok := r.proto.(Resolvable); !ok {
+		return false // Not a cached result.
+	}
+	rs := r.resolveState
+	return rs != nil && rs.finished == nil && rs.waiting == 0
+}
+
+// evict drops the resolved object of the record, so it is rebuilt from the
+// proto on next use.
+func (r *record) evict() {
+	r.object, r.resolveState = nil, nil
+}
+
+// evictLocked drops the results of the least recently used resolved
+// Resolvables until the size of the data held is within the budget, or there
+// are no more results that can be evicted. The record keep is never evicted.
+// Evicted records keep their proto, so they are rebuilt on next use.
+// evictLocked must be called with a locked mutex.
+func (d *memory) evictLocked(keep *record) {
+	if d.size <= d.budget {
+		return
+	}
+	candidates := []*record{}
+	for _, r := range d.records {
+		if r != keep && r.evictable() {
+			candidates = append(candidates, r)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].used < candidates[j].used })
+	for _, r := range candidates {
+		if d.size <= d.budget {
+			return
+		}
+		r.evict()
+		d.size -= r.size
+		r.size = 0
+	}
+}
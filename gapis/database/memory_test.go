@@ -0,0 +1,121 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/data/id"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/database"
+)
+
+// testResolvable is a Resolvable that resolves to a large string built from
+// its small name.
+type testResolvable struct {
+	Name string `protobuf:"bytes,1,opt,name=name"`
+}
+
+func (r *testResolvable) Reset()         { *r = testResolvable{} }
+func (r *testResolvable) String() string { return proto.CompactTextString(r) }
+func (r *testResolvable) ProtoMessage()  {}
+
+var (
+	resolvesMutex sync.Mutex
+	resolves      = map[string]int{}
+)
+
+func (r *testResolvable) Resolve(ctx context.Context) (interface{}, error) {
+	resolvesMutex.Lock()
+	resolves[r.Name]++
+	resolvesMutex.Unlock()
+	return testResult(r.Name), nil
+}
+
+func testResult(name string) string { return name + strings.Repeat("x", 100) }
+
+func resolveCount(name string) int {
+	resolvesMutex.Lock()
+	defer resolvesMutex.Unlock()
+	return resolves[name]
+}
+
+func TestEviction(t *testing.T) {
+	ctx := log.Testing(t)
+	d := database.NewInMemoryWithBudget(ctx, 450)
+	ctx = database.Put(ctx, d)
+
+	// Data that is not a Resolvable is neither charged nor evicted.
+	data, err := database.Store(ctx, strings.Repeat("y", 1000))
+	assert.With(ctx).ThatError(err).Succeeded()
+	assert.For(ctx, "data usage").That(database.Usage(d)).Equals(uint64(0))
+
+	ids := []id.ID{}
+	for i := 0; i < 4; i++ {
+		r := &testResolvable{Name: fmt.Sprintf("eviction-%d", i)}
+		got, err := database.Build(ctx, r)
+		assert.For(ctx, "build %d", i).ThatError(err).Succeeded()
+		assert.For(ctx, "resolved %d", i).That(got).Equals(testResult(r.Name))
+		id, err := database.Hash(ctx, r)
+		assert.For(ctx, "hash %d", i).ThatError(err).Succeeded()
+		ids = append(ids, id)
+	}
+
+	// The results are charged to the budget, not the small requests.
+	usage := database.Usage(d)
+	assert.For(ctx, "usage within budget").That(usage <= 450).Equals(true)
+	assert.For(ctx, "usage of results").That(usage >= 300).Equals(true)
+
+	got, err := database.Resolve(ctx, data)
+	assert.For(ctx, "data").ThatError(err).Succeeded()
+	assert.For(ctx, "data value").That(got).Equals(strings.Repeat("y", 1000))
+
+	// Evicted results are rebuilt from the request on next use.
+	got, err = database.Resolve(ctx, ids[0])
+	assert.For(ctx, "rebuild").ThatError(err).Succeeded()
+	assert.For(ctx, "rebuilt").That(got).Equals(testResult("eviction-0"))
+	assert.For(ctx, "oldest evicted").That(resolveCount("eviction-0")).Equals(2)
+
+	// Results that were not evicted are not rebuilt.
+	got, err = database.Resolve(ctx, ids[3])
+	assert.For(ctx, "kept").ThatError(err).Succeeded()
+	assert.For(ctx, "kept value").That(got).Equals(testResult("eviction-3"))
+	assert.For(ctx, "newest kept").That(resolveCount("eviction-3")).Equals(1)
+}
+
+func TestPurge(t *testing.T) {
+	ctx := log.Testing(t)
+	d := database.NewInMemoryWithBudget(ctx, 1000)
+	ctx = database.Put(ctx, d)
+
+	r := &testResolvable{Name: "purge"}
+	_, err := database.Build(ctx, r)
+	assert.For(ctx, "build").ThatError(err).Succeeded()
+	assert.For(ctx, "usage").That(database.Usage(d) > 0).Equals(true)
+
+	assert.For(ctx, "purged").That(database.Purge(d)).Equals(1)
+	assert.For(ctx, "purged usage").That(database.Usage(d)).Equals(uint64(0))
+
+	got, err := database.Build(ctx, r)
+	assert.For(ctx, "rebuild").ThatError(err).Succeeded()
+	assert.For(ctx, "rebuilt").That(got).Equals(testResult("purge"))
+	assert.For(ctx, "resolves").That(resolveCount("purge")).Equals(2)
+}
@@ -23,6 +23,7 @@ set(files
     doc.go
     gfxapi.pb.go
     gfxapi.proto
+    memory_breakdown.go
     mesh.go
     resource.go
     state.go
@@ -33,6 +34,7 @@ set(dirs
     .vscode
     all
     core
+    fuzz
     gles
     templates
     test
@@ -27,6 +27,7 @@ var _ atom.Atom = &SwitchThread{}
 
 func (a *SwitchThread) Mutate(ctx context.Context, gs *gfxapi.State, b *builder.Builder) error {
 	err := a.mutate(ctx, gs, nil)
+	gs.Thread = uint64(a.ThreadID)
 	if b == nil || err != nil {
 		return err
 	}
@@ -0,0 +1,224 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fuzz generates random command sequences for an API, and runs them
+// through the API's generated Mutate functions to find commands that crash or
+// break the invariants of the state.
+package fuzz
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+
+	"github.com/google/gapid/core/math/interval"
+	"github.com/google/gapid/gapil/analysis"
+	"github.com/google/gapid/gapil/semantic"
+	"github.com/google/gapid/gapis/memory"
+)
+
+const (
+	// ScratchBase is the address of the application memory that the generated
+	// pointers point into.
+	ScratchBase = 0x10000
+	// ScratchSize is the size in bytes of the memory at ScratchBase.
+	ScratchSize = 0x10000
+)
+
+// Call is a single generated command.
+type Call struct {
+	Command *semantic.Function
+	// Args holds the values of the command's call parameters, in order.
+	// Integers are uint64 or int64, floats are float64 and pointers are
+	// memory.Pointer. A nil argument is the zero value of its type.
+	Args []interface{}
+}
+
+func (c Call) String() string {
+	args := make([]string, len(c.Args))
+	for i, a := range c.Args {
+		switch a := a.(type) {
+		case string:
+			args[i] = fmt.Sprintf("%q", a)
+		case memory.Pointer:
+			args[i] = fmt.Sprintf("%#x", a.Address())
+		default:
+			args[i] = fmt.Sprint(a)
+		}
+	}
+	return fmt.Sprintf("%s(%s)", c.Command.Name(), strings.Join(args, ", "))
+}
+
+// Sequence is a list of generated commands.
+type Sequence []Call
+
+func (s Sequence) String() string {
+	lines := make([]string, len(s))
+	for i, c := range s {
+		lines[i] = fmt.Sprintf("%d: %v", i, c)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Generator generates random command sequences that are mostly valid, by
+// picking the parameter values that the static analysis found to not lead to
+// an abort.
+type Generator struct {
+	// Commands is the list of commands to pick from.
+	Commands []*semantic.Function
+	// Invalid is the probability of picking a parameter value that the
+	// analysis did not find to be possible.
+	Invalid float64
+
+	rng     *rand.Rand
+	results *analysis.Results
+	used    map[semantic.Type][]interface{} // The values used for each named type.
+}
+
+// NewGenerator returns a Generator for the commands of api using the results
+// of its static analysis. seed is the seed of the random number generator.
+func NewGenerator(api *semantic.API, results *analysis.Results, seed int64) *Generator {
+	return &Generator{
+		Commands: append([]*semantic.Function{}, api.Functions...),
+		Invalid:  0.05,
+		rng:      rand.New(rand.NewSource(seed)),
+		results:  results,
+		used:     map[semantic.Type][]interface{}{},
+	}
+}
+
+// Generate returns a new random sequence of length commands.
+func (g *Generator) Generate(length int) Sequence {
+	out := make(Sequence, length)
+	for i := range out {
+		out[i] = g.call(g.Commands[g.rng.Intn(len(g.Commands))])
+	}
+	return out
+}
+
+func (g *Generator) call(f *semantic.Function) Call {
+	params := f.CallParameters()
+	args := make([]interface{}, len(params))
+	for i, p := range params {
+		args[i] = g.value(p.Type, g.results.Parameters[p])
+	}
+	return Call{Command: f, Args: args}
+}
+
+// value returns a random value of type t, preferring the possible values v.
+// Values of named types are reused between commands, as these are usually
+// handles to objects.
+func (g *Generator) value(t semantic.Type, v analysis.Value) interface{} {
+	_, named := t.(*semantic.Pseudonym)
+	if used := g.used[t]; named && len(used) > 0 && g.rng.Intn(2) == 0 {
+		return used[g.rng.Intn(len(used))]
+	}
+	if g.rng.Float64() < g.Invalid {
+		v = nil
+	}
+	out := g.random(semantic.Underlying(t), v)
+	if named {
+		g.used[t] = append(g.used[t], out)
+	}
+	return out
+}
+
+func (g *Generator) random(t semantic.Type, v analysis.Value) interface{} {
+	switch t := t.(type) {
+	case *semantic.Enum:
+		if v, ok := v.(*analysis.EnumValue); ok && v.Valid() {
+			return g.uint(v.Numbers.Ranges)
+		}
+		if len(t.Entries) > 0 && g.rng.Intn(4) != 0 {
+			return uint64(t.Entries[g.rng.Intn(len(t.Entries))].Value)
+		}
+		return uint64(g.rng.Uint32())
+	case *semantic.Pointer:
+		if g.rng.Intn(8) == 0 {
+			return memory.Nullptr
+		}
+		return memory.BytePtr(ScratchBase+uint64(g.rng.Intn(ScratchSize/2)), memory.ApplicationPool)
+	case *semantic.Builtin:
+		switch t {
+		case semantic.BoolType:
+			if v, ok := v.(*analysis.BoolValue); ok {
+				switch v.Possibility {
+				case analysis.True:
+					return true
+				case analysis.False:
+					return false
+				}
+			}
+			return g.rng.Intn(2) == 0
+		case semantic.StringType:
+			return []string{"", "a", "main", "\x00\xff"}[g.rng.Intn(4)]
+		case semantic.Float32Type, semantic.Float64Type:
+			return []float64{0, 1, -1, 0.5, 1e6, math.NaN(), g.rng.NormFloat64()}[g.rng.Intn(7)]
+		case semantic.Int8Type, semantic.Int16Type, semantic.Int32Type, semantic.Int64Type:
+			// Analysis values of signed integers are biased into the unsigned range.
+			bias := uint64(1) << (8*size(t) - 1)
+			if v, ok := v.(*analysis.UintValue); ok && v.Valid() {
+				return int64(g.uint(v.Ranges) - bias)
+			}
+			return int64(g.uint(interval.U64SpanList{{Start: 0, End: bias<<1 - 1}}) - bias)
+		default:
+			if v, ok := v.(*analysis.UintValue); ok && v.Valid() {
+				return g.uint(v.Ranges)
+			}
+			max := uint64(math.MaxUint64)
+			if s := size(t); s < 8 {
+				max = uint64(1)<<(8*s) - 1
+			}
+			return g.uint(interval.U64SpanList{{Start: 0, End: max}})
+		}
+	}
+	return nil // The zero value.
+}
+
+// uint returns a random value in the ranges, preferring the range boundaries
+// and small values.
+func (g *Generator) uint(ranges interval.U64SpanList) uint64 {
+	if len(ranges) == 0 {
+		return 0
+	}
+	r := ranges[g.rng.Intn(len(ranges))]
+	n := r.End - r.Start
+	switch {
+	case n <= 1:
+		return r.Start
+	case g.rng.Intn(4) == 0:
+		return r.Start
+	case g.rng.Intn(4) == 0:
+		return r.End - 1
+	case g.rng.Intn(2) == 0 && n > 16:
+		return r.Start + uint64(g.rng.Intn(16))
+	default:
+		return r.Start + uint64(g.rng.Int63())%n
+	}
+}
+
+// size returns the size in bytes of the integer builtin t.
+func size(t *semantic.Builtin) uint {
+	switch t {
+	case semantic.Int8Type, semantic.Uint8Type, semantic.CharType:
+		return 1
+	case semantic.Int16Type, semantic.Uint16Type:
+		return 2
+	case semantic.Int32Type, semantic.Uint32Type:
+		return 4
+	default:
+		return 8
+	}
+}
@@ -0,0 +1,254 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuzz
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"runtime/debug"
+
+	"github.com/google/gapid/core/data"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/memory"
+)
+
+// Invariant checks a property of the state that must hold after every
+// command, returning an error if it does not.
+type Invariant func(ctx context.Context, s *gfxapi.State) error
+
+// Failure describes a command sequence that crashed or broke an invariant.
+type Failure struct {
+	Sequence Sequence
+	Index    int         // The index of the failing call in Sequence.
+	Panic    interface{} // The recovered panic, or nil.
+	Stack    string      // The stack of the panic.
+	Err      error       // The error of the broken invariant, or nil.
+}
+
+func (f *Failure) Error() string {
+	if f.Panic != nil {
+		return fmt.Sprintf("Panic in %v: %v", f.Sequence[f.Index], f.Panic)
+	}
+	return fmt.Sprintf("Invariant broken by %v: %v", f.Sequence[f.Index], f.Err)
+}
+
+// Same returns true if f and o are failures of the same command with the same
+// panic or error.
+func (f *Failure) Same(o *Failure) bool {
+	return o != nil &&
+		f.Sequence[f.Index].Command == o.Sequence[o.Index].Command &&
+		fmt.Sprint(f.Panic) == fmt.Sprint(o.Panic) &&
+		fmt.Sprint(f.Err) == fmt.Sprint(o.Err)
+}
+
+// Harness runs command sequences through the generated Mutate functions of an
+// API.
+type Harness struct {
+	// API is the API of the commands.
+	API gfxapi.API
+	// MemoryLayout is the memory layout of the state.
+	MemoryLayout *device.MemoryLayout
+	// Invariants are checked after each command.
+	Invariants []Invariant
+	// Mutate mutates the state s by the atom a. If nil, the atom's own Mutate
+	// method is called.
+	Mutate func(ctx context.Context, a atom.Atom, s *gfxapi.State)
+}
+
+// Queries is an Invariant that checks that the API can be queried for the
+// current context and framebuffer.
+func Queries(api gfxapi.API) Invariant {
+	return func(ctx context.Context, s *gfxapi.State) error {
+		api.Context(s)
+		api.GetFramebufferAttachmentInfo(s, gfxapi.FramebufferAttachment_Color0)
+		return nil
+	}
+}
+
+// Atoms returns the atoms of h.API for the calls of seq.
+func (h *Harness) Atoms(ctx context.Context, seq Sequence) ([]atom.Atom, error) {
+	out := make([]atom.Atom, len(seq))
+	for i, c := range seq {
+		a := atom.Create(h.API, c.Command.Name())
+		if a == nil {
+			return nil, fmt.Errorf("Command %s is not registered for %v", c.Command.Name(), h.API.Name())
+		}
+		for j, p := range c.Command.CallParameters() {
+			if err := setParameter(a, p.Name(), c.Args[j]); err != nil {
+				return nil, fmt.Errorf("%v: %v", c, err)
+			}
+		}
+		out[i] = a
+	}
+	return out, nil
+}
+
+// Run mutates a new state by each of the calls of seq, checking the invariants
+// after each command. Run returns the first failure, or nil if all the calls
+// succeeded. Commands that abort are not failures.
+func (h *Harness) Run(ctx context.Context, seq Sequence) (*Failure, error) {
+	atoms, err := h.Atoms(ctx, seq)
+	if err != nil {
+		return nil, err
+	}
+	s := gfxapi.NewStateWithEmptyAllocator(h.MemoryLayout)
+	scratch := make([]byte, ScratchSize)
+	rand.New(rand.NewSource(0)).Read(scratch)
+	s.Memory[memory.ApplicationPool].Write(ScratchBase, memory.Blob(scratch))
+
+	mutate := h.Mutate
+	if mutate == nil {
+		mutate = func(ctx context.Context, a atom.Atom, s *gfxapi.State) { a.Mutate(ctx, s, nil) }
+	}
+	for i, a := range atoms {
+		ctx := log.V{"index": i, "command": a.AtomName()}.Bind(ctx)
+		if f := h.step(ctx, seq, i, func() error { mutate(ctx, a, s); return nil }); f != nil {
+			return f, nil
+		}
+		for _, inv := range h.Invariants {
+			if f := h.step(ctx, seq, i, func() error { return inv(ctx, s) }); f != nil {
+				return f, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// step calls f, returning a failure if f panics or returns an error.
+func (h *Harness) step(ctx context.Context, seq Sequence, i int, f func() error) (failure *Failure) {
+	defer func() {
+		if r := recover(); r != nil {
+			failure = &Failure{Sequence: seq, Index: i, Panic: r, Stack: string(debug.Stack())}
+		}
+	}()
+	if err := f(); err != nil {
+		return &Failure{Sequence: seq, Index: i, Err: err}
+	}
+	return nil
+}
+
+// Minimize returns the failure of the shortest subsequence of f.Sequence found
+// to fail in the same way as f.
+func (h *Harness) Minimize(ctx context.Context, f *Failure) (*Failure, error) {
+	var err error
+	last := f
+	Minimize(f.Sequence[:f.Index+1], func(seq Sequence) bool {
+		if err != nil {
+			return false
+		}
+		var got *Failure
+		got, err = h.Run(ctx, seq)
+		if f.Same(got) {
+			last = got
+			return true
+		}
+		return false
+	})
+	return last, err
+}
+
+// Fuzz runs count sequences of length commands generated by g, returning the
+// minimized failures. Failures that are the same as an earlier failure are
+// dropped.
+func (h *Harness) Fuzz(ctx context.Context, g *Generator, count, length int) ([]*Failure, error) {
+	out := []*Failure{}
+next:
+	for i := 0; i < count; i++ {
+		f, err := h.Run(ctx, g.Generate(length))
+		if err != nil {
+			return nil, err
+		}
+		if f == nil {
+			continue
+		}
+		for _, o := range out {
+			if f.Same(o) {
+				continue next
+			}
+		}
+		log.I(ctx, "Minimizing failure: %v", f)
+		if f, err = h.Minimize(ctx, f); err != nil {
+			return nil, err
+		}
+		out = append(out, f)
+	}
+	return out, nil
+}
+
+// setParameter sets the parameter of a with the given name to the generated
+// value v.
+func setParameter(a atom.Atom, name string, v interface{}) error {
+	s := reflect.ValueOf(a)
+	for s.Kind() != reflect.Struct {
+		s = s.Elem()
+	}
+	t := s.Type()
+	for i, count := 0, t.NumField(); i < count; i++ {
+		if n, ok := t.Field(i).Tag.Lookup("param"); ok && n == name {
+			return assign(s.Field(i), v)
+		}
+	}
+	return atom.ErrParameterNotFound
+}
+
+func assign(f reflect.Value, v interface{}) error {
+	if v == nil {
+		return nil
+	}
+	if a, ok := f.Addr().Interface().(data.Assignable); ok && a.Assign(v) {
+		return nil
+	}
+	switch v := v.(type) {
+	case uint64:
+		switch f.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			f.SetUint(v)
+			return nil
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			f.SetInt(int64(v))
+			return nil
+		}
+	case int64:
+		switch f.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			f.SetInt(v)
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			f.SetUint(uint64(v))
+			return nil
+		}
+	case float64:
+		if k := f.Kind(); k == reflect.Float32 || k == reflect.Float64 {
+			f.SetFloat(v)
+			return nil
+		}
+	case bool:
+		if f.Kind() == reflect.Bool {
+			f.SetBool(v)
+			return nil
+		}
+	case string:
+		if f.Kind() == reflect.String {
+			f.SetString(v)
+			return nil
+		}
+	}
+	return fmt.Errorf("Cannot assign %T to %v", v, f.Type())
+}
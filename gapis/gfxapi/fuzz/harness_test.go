@@ -0,0 +1,113 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuzz_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/gapil"
+	"github.com/google/gapid/gapil/analysis"
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/gfxapi/fuzz"
+	"github.com/google/gapid/gapis/gfxapi/gles"
+)
+
+// fuzzedCommands are the GLES commands that are fuzzed.
+var fuzzedCommands = map[string]bool{
+	"glClear":      true,
+	"glClearColor": true,
+	"glDisable":    true,
+	"glEnable":     true,
+	"glFinish":     true,
+	"glFlush":      true,
+	"glGetError":   true,
+	"glLineWidth":  true,
+	"glViewport":   true,
+}
+
+func newGLESGenerator(ctx context.Context) *fuzz.Generator {
+	processor := gapil.NewProcessor()
+	api, errs := processor.Resolve("../gles/gles.api")
+	assert.For(ctx, "resolve errors").That(len(errs)).Equals(0)
+	results := analysis.Analyze(api, processor.Mappings)
+	g := fuzz.NewGenerator(api, results, 1)
+	g.Commands = nil
+	for _, f := range api.Functions {
+		if fuzzedCommands[f.Name()] {
+			g.Commands = append(g.Commands, f)
+		}
+	}
+	assert.For(ctx, "commands").That(len(g.Commands)).Equals(len(fuzzedCommands))
+	return g
+}
+
+func commandNames(seq fuzz.Sequence) []string {
+	out := make([]string, len(seq))
+	for i, c := range seq {
+		out[i] = c.Command.Name()
+	}
+	return out
+}
+
+func TestFuzzGLES(t *testing.T) {
+	ctx := log.Testing(t)
+	g := newGLESGenerator(ctx)
+	h := &fuzz.Harness{API: gles.API(), MemoryLayout: device.Little32}
+
+	failures, err := h.Fuzz(ctx, g, 20, 20)
+	assert.For(ctx, "err").ThatError(err).Succeeded()
+	assert.For(ctx, "failures").That(len(failures)).Equals(0)
+}
+
+func TestFuzzGLESCrash(t *testing.T) {
+	ctx := log.Testing(t)
+	g := newGLESGenerator(ctx)
+	h := &fuzz.Harness{API: gles.API(), MemoryLayout: device.Little32}
+
+	// Crash on a glClear that follows a glFlush.
+	flushed := map[*gfxapi.State]bool{}
+	h.Mutate = func(ctx context.Context, a atom.Atom, s *gfxapi.State) {
+		switch a.AtomName() {
+		case "glFlush":
+			flushed[s] = true
+		case "glClear":
+			if flushed[s] {
+				panic("glClear after glFlush")
+			}
+		}
+		a.Mutate(ctx, s, nil)
+	}
+
+	failures, err := h.Fuzz(ctx, g, 20, 20)
+	assert.For(ctx, "err").ThatError(err).Succeeded()
+	assert.For(ctx, "failures").That(len(failures)).Equals(1)
+	if len(failures) != 1 {
+		return
+	}
+	f := failures[0]
+	assert.For(ctx, "panic").That(f.Panic).Equals("glClear after glFlush")
+	assert.For(ctx, "stack").That(f.Stack != "").Equals(true)
+	assert.For(ctx, "index").That(f.Index).Equals(1)
+	assert.For(ctx, "minimized").ThatSlice(commandNames(f.Sequence)).Equals([]string{"glFlush", "glClear"})
+
+	again, err := h.Run(ctx, f.Sequence)
+	assert.For(ctx, "rerun err").ThatError(err).Succeeded()
+	assert.For(ctx, "rerun fails").That(f.Same(again)).Equals(true)
+}
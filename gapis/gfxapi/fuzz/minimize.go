@@ -0,0 +1,50 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuzz
+
+// Minimize returns the shortest subsequence of seq found for which fails
+// returns true, removing calls using delta debugging. fails must return true
+// for seq.
+func Minimize(seq Sequence, fails func(Sequence) bool) Sequence {
+	n := 2
+	for len(seq) >= 2 {
+		chunk := (len(seq) + n - 1) / n
+		reduced := false
+		for start := 0; start < len(seq); start += chunk {
+			end := start + chunk
+			if end > len(seq) {
+				end = len(seq)
+			}
+			// Try the sequence without the calls [start, end).
+			candidate := append(append(Sequence{}, seq[:start]...), seq[end:]...)
+			if fails(candidate) {
+				seq, reduced = candidate, true
+				if n > 2 {
+					n--
+				}
+				break
+			}
+		}
+		if !reduced {
+			if n >= len(seq) {
+				break
+			}
+			if n *= 2; n > len(seq) {
+				n = len(seq)
+			}
+		}
+	}
+	return seq
+}
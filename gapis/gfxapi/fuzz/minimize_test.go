@@ -0,0 +1,61 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuzz_test
+
+import (
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapil/semantic"
+	"github.com/google/gapid/gapis/gfxapi/fuzz"
+)
+
+func TestMinimize(t *testing.T) {
+	ctx := log.Testing(t)
+	cmds := make([]*semantic.Function, 8)
+	seq := make(fuzz.Sequence, 40)
+	for i := range cmds {
+		cmds[i] = &semantic.Function{Named: semantic.Named(string('a' + i))}
+	}
+	for i := range seq {
+		seq[i] = fuzz.Call{Command: cmds[i%len(cmds)], Args: []interface{}{i}}
+	}
+	// The sequence fails if a call to 'c' comes after a call to 'f'.
+	fails := func(seq fuzz.Sequence) bool {
+		seenF := false
+		for _, c := range seq {
+			switch c.Command {
+			case cmds[5]:
+				seenF = true
+			case cmds[2]:
+				if seenF {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	got := fuzz.Minimize(seq, fails)
+	cmdsOf := func(seq fuzz.Sequence) []*semantic.Function {
+		out := make([]*semantic.Function, len(seq))
+		for i, c := range seq {
+			out[i] = c.Command
+		}
+		return out
+	}
+	assert.For(ctx, "fails").That(fails(got)).Equals(true)
+	assert.For(ctx, "commands").ThatSlice(cmdsOf(got)).Equals([]*semantic.Function{cmds[5], cmds[2]})
+}
@@ -47,12 +47,15 @@ set(files
     markers.go
     markers_test.go
     mutate.go
+    program_interface.go
     read_framebuffer.go
     replay.go
     resolvables.pb.go
     resolvables.proto
     resources.go
     resources_test.go
+    shader_lint.go
+    shader_metrics.go
     state.go
     string.go
     stub_program.go
@@ -246,6 +246,10 @@ func (t *findIssues) Transform(ctx context.Context, i atom.ID, a atom.Atom, out
 		tmp.Free()
 
 	case *GlLinkProgram:
+		if program := c.Objects.Shared.Programs[a.Program]; program != nil {
+			t.checkProgramInterface(a, i, program)
+		}
+
 		const buflen = 2048
 		tmp := atom.Must(atom.Alloc(ctx, t.state, 4+buflen))
 		out.MutateAndWrite(ctx, dID, NewGlGetProgramiv(a.Program, GLenum_GL_LINK_STATUS, tmp.Ptr()))
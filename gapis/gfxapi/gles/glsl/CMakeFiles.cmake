@@ -20,6 +20,14 @@
 set(files
     glsl.go
     glsl_test.go
+    interface.go
+    interface_test.go
+    lint.go
+    lint_test.go
+    metrics.go
+    metrics_test.go
+    reduce.go
+    reduce_test.go
 )
 set(dirs
     ast
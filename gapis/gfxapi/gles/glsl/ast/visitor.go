@@ -183,3 +183,110 @@ func VisitChildren(n interface{}, v ChildVisitor) {
 		return child
 	})
 }
+
+// Inspect traverses the program tree rooted at n in depth-first order. It calls f for each
+// declaration, statement, condition and expression node of the tree, and then traverses the
+// children of the node if f returned true. Unlike VisitChildren, Inspect does not descend into
+// types or into the symbols referenced by VarRefExpr nodes, so every node is visited once.
+func Inspect(n interface{}, f func(n interface{}) bool) {
+	if n == nil || !f(n) {
+		return
+	}
+	visit := func(c interface{}) {
+		if c != nil {
+			Inspect(c, f)
+		}
+	}
+	switch n := n.(type) {
+	case *Ast:
+		for _, d := range n.Decls {
+			visit(d)
+		}
+	case *FunctionDecl:
+		for _, p := range n.Params {
+			visit(p)
+		}
+		if n.Stmts != nil {
+			visit(n.Stmts)
+		}
+	case *MultiVarDecl:
+		for _, v := range n.Vars {
+			visit(v)
+		}
+	case *VariableSym:
+		if n.Init != nil {
+			visit(n.Init)
+		}
+	case *UniformDecl:
+		if n.Block != nil {
+			for _, v := range n.Block.Vars {
+				visit(v)
+			}
+		}
+	case *InvariantDecl:
+		for _, v := range n.Vars {
+			visit(v)
+		}
+
+	case *CompoundStmt:
+		for _, s := range n.Stmts {
+			visit(s)
+		}
+	case *ExpressionStmt:
+		visit(n.Expr)
+	case *DeclarationStmt:
+		visit(n.Decl)
+	case *IfStmt:
+		visit(n.IfExpr)
+		visit(n.ThenStmt)
+		visit(n.ElseStmt)
+	case *SwitchStmt:
+		visit(n.Expr)
+		if n.Stmts != nil {
+			visit(n.Stmts)
+		}
+	case *CaseStmt:
+		visit(n.Expr)
+	case *WhileStmt:
+		visit(n.Cond)
+		visit(n.Stmt)
+	case *DoStmt:
+		visit(n.Stmt)
+		visit(n.Expr)
+	case *ForStmt:
+		visit(n.Init)
+		visit(n.Cond)
+		visit(n.Loop)
+		visit(n.Body)
+	case *ReturnStmt:
+		visit(n.Expr)
+	case *ExpressionCond:
+		visit(n.Expr)
+	case *VarDeclCond:
+		if n.Sym != nil {
+			visit(n.Sym)
+		}
+
+	case *BinaryExpr:
+		visit(n.Left)
+		visit(n.Right)
+	case *IndexExpr:
+		visit(n.Base)
+		visit(n.Index)
+	case *ConditionalExpr:
+		visit(n.Cond)
+		visit(n.TrueExpr)
+		visit(n.FalseExpr)
+	case *UnaryExpr:
+		visit(n.Expr)
+	case *DotExpr:
+		visit(n.Expr)
+	case *ParenExpr:
+		visit(n.Expr)
+	case *CallExpr:
+		visit(n.Callee)
+		for _, a := range n.Args {
+			visit(a)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glsl
+
+import (
+	"fmt"
+
+	"github.com/google/gapid/gapis/gfxapi/gles/glsl/ast"
+)
+
+// CheckInterface checks that the interfaces of the parsed vertex and fragment shaders of a
+// program match. It reports:
+//
+// - fragment shader inputs which do not match a vertex shader output by type and precision,
+//
+// - varyings written by the vertex shader but never read by the fragment shader,
+//
+// - uniforms declared with different types or precisions by the two shaders,
+//
+// - vertex shader inputs with locations not below maxVertexAttribs.
+//
+// If maxVertexAttribs is 0 the input locations are not checked.
+func CheckInterface(vertex, fragment interface{}, maxVertexAttribs int) []Diagnostic {
+	vs := newStageInterface(vertex, ast.LangVertexShader)
+	fs := newStageInterface(fragment, ast.LangFragmentShader)
+	out := []Diagnostic{}
+	report := func(s Severity, msg string, args ...interface{}) {
+		out = append(out, Diagnostic{Severity: s, Message: fmt.Sprintf(msg, args...)})
+	}
+
+	for _, in := range fs.inputs {
+		v := vs.find(vs.outputs, in.name())
+		switch {
+		case v == nil:
+			if in.used {
+				report(SeverityError, "Fragment shader input '%s' is not an output of the vertex shader.",
+					in.name())
+			}
+		case !v.sameType(in):
+			report(SeverityError, "Varying '%s' is declared as %s in the vertex shader but as %s in "+
+				"the fragment shader.", in.name(), v.typeName(), in.typeName())
+		case v.explicit && in.explicit && v.precision != in.precision:
+			report(SeverityWarning, "Varying '%s' has %v precision in the vertex shader but %v "+
+				"precision in the fragment shader.", in.name(), v.precision, in.precision)
+		}
+	}
+
+	for _, v := range vs.outputs {
+		if in := fs.find(fs.inputs, v.name()); v.used && (in == nil || !in.used) {
+			report(SeverityWarning, "Varying '%s' is written by the vertex shader but never read by "+
+				"the fragment shader.", v.name())
+		}
+	}
+
+	for _, u := range vs.uniforms {
+		f := fs.find(fs.uniforms, u.name())
+		switch {
+		case f == nil:
+		case !u.sameType(f):
+			report(SeverityError, "Uniform '%s' is declared as %s in the vertex shader but as %s in "+
+				"the fragment shader.", u.name(), u.typeName(), f.typeName())
+		case u.precision != f.precision:
+			report(SeverityError, "Uniform '%s' has %v precision in the vertex shader but %v "+
+				"precision in the fragment shader.", u.name(), precisionName(u.precision),
+				precisionName(f.precision))
+		}
+	}
+
+	if maxVertexAttribs > 0 {
+		for _, in := range vs.inputs {
+			if in.location < 0 {
+				continue
+			}
+			if last := in.location + locations(in.sym.SymType, ast.LangVertexShader) - 1; last >= maxVertexAttribs {
+				report(SeverityError, "Vertex shader input '%s' uses location %d, but the device "+
+					"only supports %d vertex attributes.", in.name(), last, maxVertexAttribs)
+			}
+		}
+	}
+	return out
+}
+
+// stageVar is a global interface variable of a single shader stage.
+type stageVar struct {
+	sym       *ast.VariableSym
+	lang      ast.Language
+	precision ast.Precision // The explicit or default precision of the variable.
+	explicit  bool          // True if the variable is declared with a precision qualifier.
+	location  int           // The layout location of the variable, or -1.
+	used      bool
+}
+
+func (v *stageVar) name() string     { return v.sym.SymName }
+func (v *stageVar) typeName() string { return typeName(v.sym.SymType, v.lang, false) }
+
+// sameType returns true if the variables v and o have the same type, ignoring precision.
+func (v *stageVar) sameType(o *stageVar) bool {
+	return typeName(v.sym.SymType, v.lang, true) == typeName(o.sym.SymType, o.lang, true)
+}
+
+// stageInterface holds the interface variables of a single shader stage.
+type stageInterface struct {
+	inputs, outputs, uniforms []*stageVar
+}
+
+func (s *stageInterface) find(list []*stageVar, name string) *stageVar {
+	for _, v := range list {
+		if v.name() == name {
+			return v
+		}
+	}
+	return nil
+}
+
+func newStageInterface(program interface{}, lang ast.Language) *stageInterface {
+	s := &stageInterface{}
+	tree, ok := program.(*ast.Ast)
+	if !ok {
+		return s
+	}
+
+	used := map[ast.ValueSymbol]bool{}
+	ast.Inspect(tree, func(n interface{}) bool {
+		if ref, ok := n.(*ast.VarRefExpr); ok {
+			used[ref.Sym] = true
+		}
+		return true
+	})
+
+	defaults := map[ast.BareType]ast.Precision{
+		ast.TInt:         ast.HighP,
+		ast.TFloat:       ast.HighP,
+		ast.TSampler2D:   ast.LowP,
+		ast.TSamplerCube: ast.LowP,
+	}
+	if lang == ast.LangFragmentShader {
+		defaults[ast.TInt] = ast.MediumP
+		delete(defaults, ast.TFloat)
+	}
+
+	for _, d := range tree.Decls {
+		switch d := d.(type) {
+		case *ast.PrecisionDecl:
+			if d.Type != nil {
+				defaults[d.Type.Type] = d.Type.Precision
+			}
+		case *ast.MultiVarDecl:
+			if d.Quals == nil {
+				continue
+			}
+			var list *[]*stageVar
+			switch d.Quals.Storage {
+			case ast.StorUniform:
+				list = &s.uniforms
+			case ast.StorIn, ast.StorCentroidIn, ast.StorAttribute:
+				list = &s.inputs
+			case ast.StorOut, ast.StorCentroidOut:
+				list = &s.outputs
+			case ast.StorVarying:
+				if lang == ast.LangFragmentShader {
+					list = &s.inputs
+				} else {
+					list = &s.outputs
+				}
+			default:
+				continue
+			}
+			location := layoutLocation(d.Quals.Layout)
+			for _, v := range d.Vars {
+				*list = append(*list, &stageVar{
+					sym:       v,
+					lang:      lang,
+					precision: precisionOf(v.SymType, defaults),
+					explicit:  precisionOf(v.SymType, nil) != ast.NoneP,
+					location:  location,
+					used:      used[v],
+				})
+				if location >= 0 {
+					location += locations(v.SymType, lang)
+				}
+			}
+		}
+	}
+	return s
+}
+
+// layoutLocation returns the location specified by the layout qualifier l, or -1 if there is
+// none.
+func layoutLocation(l *ast.LayoutQualifier) int {
+	if l == nil {
+		return -1
+	}
+	for _, id := range l.Ids {
+		if id.Name != "location" {
+			continue
+		}
+		switch v := id.Value.(type) {
+		case ast.IntValue:
+			return int(v)
+		case ast.UintValue:
+			return int(v)
+		}
+	}
+	return -1
+}
+
+// precisionOf returns the explicit precision of the type t, or the default precision of its
+// type if it has none.
+func precisionOf(t ast.Type, defaults map[ast.BareType]ast.Precision) ast.Precision {
+	switch t := t.(type) {
+	case *ast.BuiltinType:
+		if t.Precision != ast.NoneP {
+			return t.Precision
+		}
+		if p, ok := ast.GetPrecisionType(t.Type); ok {
+			return defaults[p]
+		}
+	case *ast.ArrayType:
+		return precisionOf(t.Base, defaults)
+	}
+	return ast.NoneP
+}
+
+func precisionName(p ast.Precision) string {
+	if p == ast.NoneP {
+		return "no"
+	}
+	return p.String()
+}
+
+// typeName returns the name of the type t, ignoring its precision. If canonical is true,
+// equivalent types such as mat4 and mat4x4 are given the same name.
+func typeName(t ast.Type, lang ast.Language, canonical bool) string {
+	switch t := t.(type) {
+	case *ast.BuiltinType:
+		if canonical {
+			return t.Type.Canonicalize().String()
+		}
+		return t.Type.String()
+	case *ast.ArrayType:
+		if size := arraySize(t, lang); size > 0 {
+			return fmt.Sprintf("%s[%d]", typeName(t.Base, lang, canonical), size)
+		}
+		return typeName(t.Base, lang, canonical) + "[]"
+	case *ast.StructType:
+		if t.Sym != nil {
+			return t.Sym.SymName
+		}
+	}
+	return "<unknown>"
+}
+
+// locations returns the number of vertex attribute locations used by a value of type t.
+func locations(t ast.Type, lang ast.Language) int {
+	switch t := t.(type) {
+	case *ast.BuiltinType:
+		col, _ := ast.TypeDimensions(t.Type)
+		return int(col)
+	case *ast.ArrayType:
+		size := arraySize(t, lang)
+		if size == 0 {
+			size = 1
+		}
+		return size * locations(t.Base, lang)
+	}
+	return 1
+}
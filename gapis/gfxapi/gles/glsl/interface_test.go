@@ -0,0 +1,86 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glsl
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/gapid/gapis/gfxapi/gles/glsl/ast"
+)
+
+func TestCheckInterface(t *testing.T) {
+	vertex := `#version 300 es
+layout(location = 0) in vec4 position;
+layout(location = 14) in mat3 normalMatrix;
+uniform mat4 mvp;
+uniform mediump float scale;
+out highp vec2 uv;
+out vec3 normal;
+out float depth;
+out lowp vec4 color;
+void main() {
+	uv = position.xy * scale;
+	normal = normalMatrix * position.xyz;
+	depth = position.z;
+	color = position;
+	gl_Position = mvp * position;
+}`
+	fragment := `#version 300 es
+precision mediump float;
+uniform highp float scale;
+uniform vec4 mvp;
+in mediump vec2 uv;
+in vec4 normal;
+in float depth;
+in vec4 color;
+in vec4 missing;
+out vec4 fragColor;
+void main() {
+	fragColor = vec4(uv * scale, 0.0, 1.0) + normal + color + missing + mvp;
+}`
+	vs, _, _, errs := Parse(vertex, ast.LangVertexShader)
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected errors parsing the vertex shader: %v", errs)
+	}
+	fs, _, _, errs := Parse(fragment, ast.LangFragmentShader)
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected errors parsing the fragment shader: %v", errs)
+	}
+
+	expected := []Diagnostic{
+		{Severity: SeverityWarning, Message: "Varying 'uv' has highp precision in the vertex shader " +
+			"but mediump precision in the fragment shader."},
+		{Severity: SeverityError, Message: "Varying 'normal' is declared as vec3 in the vertex shader " +
+			"but as vec4 in the fragment shader."},
+		{Severity: SeverityError, Message: "Fragment shader input 'missing' is not an output of the " +
+			"vertex shader."},
+		{Severity: SeverityWarning, Message: "Varying 'depth' is written by the vertex shader but " +
+			"never read by the fragment shader."},
+		{Severity: SeverityError, Message: "Uniform 'mvp' is declared as mat4 in the vertex shader " +
+			"but as vec4 in the fragment shader."},
+		{Severity: SeverityError, Message: "Uniform 'scale' has mediump precision in the vertex " +
+			"shader but highp precision in the fragment shader."},
+		{Severity: SeverityError, Message: "Vertex shader input 'normalMatrix' uses location 16, but " +
+			"the device only supports 16 vertex attributes."},
+	}
+	got := CheckInterface(vs, fs, 16)
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Interface problems were not as expected.\nExpected: %v\nGot:      %v", expected, got)
+	}
+	if got := CheckInterface(vs, fs, 0); len(got) != len(expected)-1 {
+		t.Errorf("Locations were checked with no attribute limit: %v", got)
+	}
+}
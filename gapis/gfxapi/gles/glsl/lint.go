@@ -0,0 +1,321 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glsl
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/google/gapid/core/text/parse"
+	"github.com/google/gapid/gapis/gfxapi/gles/glsl/ast"
+)
+
+// Severity is the severity of a Diagnostic.
+type Severity uint8
+
+const (
+	// SeverityError is used for problems which prevent the shader from compiling.
+	SeverityError Severity = iota
+	// SeverityWarning is used for problems which may affect the correctness or performance of
+	// the shader on some devices.
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Diagnostic is a problem found in a shader source.
+type Diagnostic struct {
+	Severity Severity
+	// Line and Column are the 1-based position of the problem in the source, or 0 if the
+	// position is not known.
+	Line, Column int
+	Message      string
+}
+
+func (d Diagnostic) String() string {
+	if d.Line == 0 {
+		return fmt.Sprintf("%v: %s", d.Severity, d.Message)
+	}
+	return fmt.Sprintf("%d:%d: %v: %s", d.Line, d.Column, d.Severity, d.Message)
+}
+
+// Check parses, analyzes and lints the shader source src written in the language lang,
+// returning all the problems found. Parse errors are reported with SeverityError. As the
+// semantic analysis is not complete, its errors are reported with SeverityWarning.
+func Check(src string, lang ast.Language) []Diagnostic {
+	program, _, _, errs := Parse(src, lang)
+	if len(errs) > 0 {
+		out := make([]Diagnostic, len(errs))
+		for i, err := range errs {
+			out[i] = errorDiagnostic(SeverityError, err)
+		}
+		return out
+	}
+	out := []Diagnostic{}
+	for _, err := range analyze(program) {
+		out = append(out, errorDiagnostic(SeverityWarning, err))
+	}
+	return append(out, Lint(program, lang)...)
+}
+
+// analyze calls Analyze, turning any unexpected failure of the analysis into an error.
+func analyze(program interface{}) (errs []error) {
+	defer func() {
+		if r := recover(); r != nil {
+			errs = []error{fmt.Errorf("Semantic analysis failed: %v", r)}
+		}
+	}()
+	return Analyze(program)
+}
+
+var errorPosition = regexp.MustCompile(`^(\d+):(\d+): (.*)$`)
+
+// errorDiagnostic returns the diagnostic for the parse or analysis error err.
+func errorDiagnostic(s Severity, err error) Diagnostic {
+	msg := err.Error()
+	if m := errorPosition.FindStringSubmatch(msg); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		col, _ := strconv.Atoi(m[2])
+		return Diagnostic{Severity: s, Line: line, Column: col, Message: m[3]}
+	}
+	return Diagnostic{Severity: s, Message: msg}
+}
+
+// Lint checks the parsed program written in the language lang for common mobile pitfalls:
+//
+// - fragment shaders using floating point types without a default precision,
+//
+// - varyings which are declared but never used,
+//
+// - uniform arrays indexed by non-constant expressions.
+func Lint(program interface{}, lang ast.Language) []Diagnostic {
+	l := &linter{
+		lang:        lang,
+		used:        map[ast.ValueSymbol]bool{},
+		uniforms:    map[ast.ValueSymbol]bool{},
+		loopIndices: map[ast.ValueSymbol]bool{},
+	}
+	l.lint(program)
+	return l.out
+}
+
+type linter struct {
+	lang        ast.Language
+	out         []Diagnostic
+	used        map[ast.ValueSymbol]bool
+	uniforms    map[ast.ValueSymbol]bool
+	loopIndices map[ast.ValueSymbol]bool
+	varyings    []*ast.VariableSym
+}
+
+func (l *linter) warnf(at *parse.Leaf, msg string, args ...interface{}) {
+	l.report(SeverityWarning, at, msg, args...)
+}
+
+func (l *linter) report(s Severity, at *parse.Leaf, msg string, args ...interface{}) {
+	d := Diagnostic{Severity: s, Message: fmt.Sprintf(msg, args...)}
+	if at != nil && at.Token().Source != nil {
+		d.Line, d.Column = at.Token().Cursor()
+	}
+	l.out = append(l.out, d)
+}
+
+func (l *linter) lint(program interface{}) {
+	tree, ok := program.(*ast.Ast)
+	if !ok {
+		return
+	}
+	for _, d := range tree.Decls {
+		switch d := d.(type) {
+		case *ast.MultiVarDecl:
+			l.declareGlobals(d)
+		case *ast.UniformDecl:
+			if d.Block != nil {
+				for _, decl := range d.Block.Vars {
+					for _, v := range decl.Vars {
+						l.uniforms[v] = true
+					}
+				}
+			}
+		}
+	}
+
+	hasDefaultFloat, reportedPrecision := false, false
+	ast.Inspect(tree, func(n interface{}) bool {
+		switch n := n.(type) {
+		case *ast.PrecisionDecl:
+			if n.Type != nil && n.Type.Type == ast.TFloat {
+				hasDefaultFloat = true
+			}
+		case *ast.VariableSym:
+			if !hasDefaultFloat && !reportedPrecision && l.missingPrecision(n.SymType) {
+				l.report(SeverityError, n.NameCst, "No precision specified for '%s'. "+
+					"Fragment shaders have no default precision for floating point types.", n.SymName)
+				reportedPrecision = true
+			}
+		case *ast.ForStmt:
+			if s, ok := n.Init.(*ast.DeclarationStmt); ok {
+				if d, ok := s.Decl.(*ast.MultiVarDecl); ok {
+					for _, v := range d.Vars {
+						l.loopIndices[v] = true
+					}
+				}
+			}
+		case *ast.VarRefExpr:
+			if n.Sym != nil {
+				l.used[n.Sym] = true
+			}
+		case *ast.IndexExpr:
+			l.checkIndex(n)
+		}
+		return true
+	})
+
+	for _, v := range l.varyings {
+		if l.used[v] {
+			continue
+		}
+		if l.lang == ast.LangFragmentShader {
+			l.warnf(v.NameCst, "Varying '%s' is declared but never read.", v.SymName)
+		} else {
+			l.warnf(v.NameCst, "Varying '%s' is declared but never written.", v.SymName)
+		}
+	}
+}
+
+func (l *linter) declareGlobals(d *ast.MultiVarDecl) {
+	if d.Quals == nil {
+		return
+	}
+	for _, v := range d.Vars {
+		switch d.Quals.Storage {
+		case ast.StorUniform:
+			l.uniforms[v] = true
+		case ast.StorVarying:
+			l.varyings = append(l.varyings, v)
+		case ast.StorIn, ast.StorCentroidIn:
+			if l.lang == ast.LangFragmentShader {
+				l.varyings = append(l.varyings, v)
+			}
+		case ast.StorOut, ast.StorCentroidOut:
+			if l.lang == ast.LangVertexShader {
+				l.varyings = append(l.varyings, v)
+			}
+		}
+	}
+}
+
+// missingPrecision returns true if t is a floating point type without a precision qualifier
+// in a fragment shader.
+func (l *linter) missingPrecision(t ast.Type) bool {
+	if l.lang != ast.LangFragmentShader {
+		return false
+	}
+	switch t := t.(type) {
+	case *ast.BuiltinType:
+		return t.Precision == ast.NoneP && ast.GetFundamentalType(t.Type) == ast.TFloat
+	case *ast.ArrayType:
+		return l.missingPrecision(t.Base)
+	}
+	return false
+}
+
+// checkIndex warns about uniform arrays indexed by expressions which are neither constant nor
+// loop indices. Such indexing is slow on many mobile GPUs, and is not required to be supported
+// in fragment shaders by version 1.00 of the language.
+func (l *linter) checkIndex(e *ast.IndexExpr) {
+	base := e.Base
+	for {
+		if d, ok := base.(*ast.DotExpr); ok {
+			base = d.Expr
+		} else if p, ok := base.(*ast.ParenExpr); ok {
+			base = p.Expr
+		} else {
+			break
+		}
+	}
+	ref, ok := base.(*ast.VarRefExpr)
+	if !ok || !l.uniforms[ref.Sym] {
+		return
+	}
+	if v, ok := ref.Sym.(*ast.VariableSym); !ok || !isArray(v.SymType) {
+		return
+	}
+	if isConstant(e.Index, func(sym ast.ValueSymbol) bool { return l.loopIndices[sym] }) {
+		return
+	}
+	l.warnf(e.LBracketCst, "Uniform array '%s' is indexed with a non-constant expression.",
+		ref.Sym.Name())
+}
+
+func isArray(t ast.Type) bool {
+	_, ok := t.(*ast.ArrayType)
+	return ok
+}
+
+// isConstant returns true if the expression e can be evaluated at compile time. References to
+// variables are considered constant if the variable is declared const, or if sym is not nil and
+// returns true for the variable.
+func isConstant(e ast.Expression, sym func(ast.ValueSymbol) bool) bool {
+	switch e := e.(type) {
+	case *ast.ConstantExpr:
+		return true
+	case *ast.VarRefExpr:
+		if sym != nil && sym(e.Sym) {
+			return true
+		}
+		v, ok := e.Sym.(*ast.VariableSym)
+		return ok && v.Quals != nil && v.Quals.Storage == ast.StorConst && v.Init != nil
+	case *ast.ParenExpr:
+		return isConstant(e.Expr, sym)
+	case *ast.UnaryExpr:
+		return e.Op != ast.UoPreinc && e.Op != ast.UoPredec &&
+			e.Op != ast.UoPostinc && e.Op != ast.UoPostdec && isConstant(e.Expr, sym)
+	case *ast.BinaryExpr:
+		return !ast.IsAssignmentOp(e.Op) && isConstant(e.Left, sym) && isConstant(e.Right, sym)
+	case *ast.ConditionalExpr:
+		return isConstant(e.Cond, sym) && isConstant(e.TrueExpr, sym) && isConstant(e.FalseExpr, sym)
+	case *ast.IndexExpr:
+		return isConstant(e.Base, sym) && isConstant(e.Index, sym)
+	case *ast.DotExpr:
+		return isConstant(e.Expr, sym)
+	case *ast.CallExpr:
+		switch c := e.Callee.(type) {
+		case *ast.TypeConversionExpr:
+		case *ast.VarRefExpr:
+			if _, ok := c.Sym.(*ast.BuiltinFunction); !ok {
+				return false
+			}
+		default:
+			return false
+		}
+		for _, a := range e.Args {
+			if !isConstant(a, sym) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
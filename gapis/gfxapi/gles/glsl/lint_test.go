@@ -0,0 +1,108 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glsl
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/gapid/gapis/gfxapi/gles/glsl/ast"
+)
+
+func TestLint(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		lang     ast.Language
+		source   string
+		expected []Diagnostic
+	}{
+		{
+			name: "clean",
+			lang: ast.LangFragmentShader,
+			source: `precision mediump float;
+uniform vec4 colors[4];
+varying vec2 uv;
+void main() {
+	vec4 c = vec4(uv, 0.0, 1.0);
+	for (int i = 0; i < 4; i++) {
+		c += colors[i] + colors[2];
+	}
+	gl_FragColor = c;
+}`,
+			expected: []Diagnostic{},
+		},
+		{
+			name: "missing precision",
+			lang: ast.LangFragmentShader,
+			source: `uniform vec4 color;
+void main() {
+	vec4 c = color;
+	gl_FragColor = c;
+}`,
+			expected: []Diagnostic{
+				{SeverityError, 1, 14, "No precision specified for 'color'. " +
+					"Fragment shaders have no default precision for floating point types."},
+			},
+		},
+		{
+			name: "unused varyings",
+			lang: ast.LangVertexShader,
+			source: `attribute vec4 position;
+varying vec2 uv;
+varying vec4 color;
+void main() {
+	uv = position.xy;
+	gl_Position = position;
+}`,
+			expected: []Diagnostic{
+				{SeverityWarning, 3, 14, "Varying 'color' is declared but never written."},
+			},
+		},
+		{
+			name: "dynamic indexing",
+			lang: ast.LangVertexShader,
+			source: `attribute vec4 position;
+attribute float index;
+uniform mat4 bones[8];
+void main() {
+	gl_Position = bones[int(index)] * position;
+}`,
+			expected: []Diagnostic{
+				{SeverityWarning, 5, 21, "Uniform array 'bones' is indexed with a non-constant expression."},
+			},
+		},
+	} {
+		program, _, _, errs := Parse(test.source, test.lang)
+		if len(errs) > 0 {
+			t.Errorf("Unexpected errors parsing %s: %v", test.name, errs)
+			continue
+		}
+		got := Lint(program, test.lang)
+		if got == nil {
+			got = []Diagnostic{}
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("Lint of %s was not as expected.\nExpected: %v\nGot:      %v",
+				test.name, test.expected, got)
+		}
+	}
+}
+
+func TestCheckParseErrors(t *testing.T) {
+	got := Check("void main() { int a = ; }", ast.LangVertexShader)
+	if len(got) == 0 || got[0].Severity != SeverityError || got[0].Line != 1 || got[0].Column != 23 {
+		t.Errorf("Unexpected diagnostics for a source with a parse error: %v", got)
+	}
+}
@@ -0,0 +1,629 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glsl
+
+import (
+	"math"
+	"strings"
+
+	"github.com/google/gapid/gapis/gfxapi/gles/glsl/ast"
+	"github.com/google/gapid/gapis/gfxapi/gles/glsl/evaluator"
+)
+
+// DefaultLoopIterations is the number of iterations assumed for loops whose iteration count
+// cannot be determined statically.
+const DefaultLoopIterations = 16
+
+// Metrics holds the static metrics of a shader program, as computed by ComputeMetrics.
+type Metrics struct {
+	// ALUOps and TextureOps are the number of arithmetic and texture operations in the program
+	// source. Operations which can be folded into constants are not counted.
+	ALUOps, TextureOps int
+	// WeightedALUOps and WeightedTextureOps are the estimated number of arithmetic and texture
+	// operations executed by a single invocation of main. Loop bodies are weighted by their
+	// iteration count and the bodies of called functions are counted at each call site.
+	WeightedALUOps, WeightedTextureOps float64
+	// Branches is the number of if, switch and ?: constructs in the program.
+	Branches int
+	// Loops is the number of for, while and do-while loops in the program.
+	Loops int
+	// Uniforms, Inputs, Outputs and Samplers list the names of the interface variables
+	// referenced by the program, in declaration order. Uniform blocks are listed by their block
+	// name.
+	Uniforms, Inputs, Outputs, Samplers []string
+	// LowP, MediumP and HighP are the number of times each precision qualifier is used.
+	LowP, MediumP, HighP int
+	// Registers is an estimate of the maximum number of vec4 registers needed to hold the live
+	// variables of any function in the program.
+	Registers int
+}
+
+// ComputeMetrics returns the static metrics of the parsed program, whose source is written in
+// the language lang. The program does not need to be semantically analyzed.
+func ComputeMetrics(program interface{}, lang ast.Language) Metrics {
+	b := &metricsBuilder{
+		lang:       lang,
+		interfaces: map[ast.ValueSymbol]*interfaceVar{},
+		precisions: map[*ast.BuiltinType]bool{},
+		functions:  map[*ast.FunctionDecl]*functionMetrics{},
+	}
+	tree, _ := program.(*ast.Ast)
+	if tree == nil {
+		return b.m
+	}
+	b.declare(tree)
+	b.walk(tree)
+	b.finish()
+	return b.m
+}
+
+type interfaceVar struct {
+	name string
+	list *[]string
+	used bool
+}
+
+type liveRange struct {
+	first, last int
+	registers   int
+}
+
+type functionMetrics struct {
+	weightedALU, weightedTex float64
+	calls                    map[*ast.FunctionDecl]float64
+	total                    *[2]float64 // Memoized result of metricsBuilder.weighted.
+}
+
+type metricsBuilder struct {
+	lang       ast.Language
+	m          Metrics
+	interfaces map[ast.ValueSymbol]*interfaceVar
+	order      []*interfaceVar
+	precisions map[*ast.BuiltinType]bool
+	functions  map[*ast.FunctionDecl]*functionMetrics
+	main       *ast.FunctionDecl
+
+	// The state of the function currently being walked.
+	fn     *functionMetrics
+	weight float64
+	pos    int
+	ranges map[ast.ValueSymbol]*liveRange
+}
+
+// declare classifies the global interface variables of the program.
+func (b *metricsBuilder) declare(tree *ast.Ast) {
+	add := func(sym ast.ValueSymbol, name string, list *[]string) {
+		v := &interfaceVar{name: name, list: list}
+		b.interfaces[sym] = v
+		b.order = append(b.order, v)
+	}
+	for _, d := range tree.Decls {
+		switch d := d.(type) {
+		case *ast.MultiVarDecl:
+			if d.Quals == nil {
+				continue
+			}
+			for _, v := range d.Vars {
+				switch d.Quals.Storage {
+				case ast.StorUniform:
+					if isSampler(v.SymType) {
+						add(v, v.SymName, &b.m.Samplers)
+					} else {
+						add(v, v.SymName, &b.m.Uniforms)
+					}
+				case ast.StorIn, ast.StorCentroidIn, ast.StorAttribute:
+					add(v, v.SymName, &b.m.Inputs)
+				case ast.StorOut, ast.StorCentroidOut:
+					add(v, v.SymName, &b.m.Outputs)
+				case ast.StorVarying:
+					if b.lang == ast.LangFragmentShader {
+						add(v, v.SymName, &b.m.Inputs)
+					} else {
+						add(v, v.SymName, &b.m.Outputs)
+					}
+				}
+			}
+		case *ast.UniformDecl:
+			if d.Block == nil {
+				continue
+			}
+			block := &interfaceVar{name: d.Block.SymName, list: &b.m.Uniforms}
+			b.order = append(b.order, block)
+			for _, decl := range d.Block.Vars {
+				for _, v := range decl.Vars {
+					b.interfaces[v] = block
+				}
+			}
+		case *ast.FunctionDecl:
+			if d.SymName == "main" && d.Stmts != nil {
+				b.main = d
+			}
+		}
+	}
+}
+
+func (b *metricsBuilder) finish() {
+	for _, v := range b.order {
+		if v.used {
+			*v.list = append(*v.list, v.name)
+		}
+	}
+	if b.main != nil {
+		w := b.weighted(b.main, map[*ast.FunctionDecl]bool{})
+		b.m.WeightedALUOps, b.m.WeightedTextureOps = w[0], w[1]
+	}
+}
+
+// weighted returns the weighted ALU and texture operation counts of the function f, including
+// the functions it calls.
+func (b *metricsBuilder) weighted(f *ast.FunctionDecl, visiting map[*ast.FunctionDecl]bool) [2]float64 {
+	fm := b.functions[f]
+	if fm == nil || visiting[f] { // Recursion is not allowed by the language.
+		return [2]float64{}
+	}
+	if fm.total != nil {
+		return *fm.total
+	}
+	visiting[f] = true
+	total := [2]float64{fm.weightedALU, fm.weightedTex}
+	for callee, weight := range fm.calls {
+		c := b.weighted(callee, visiting)
+		total[0] += weight * c[0]
+		total[1] += weight * c[1]
+	}
+	delete(visiting, f)
+	fm.total = &total
+	return total
+}
+
+// definition returns the definition of the function declared by f, or nil if the function is
+// not defined by the program.
+func (b *metricsBuilder) definition(f *ast.FunctionDecl) *ast.FunctionDecl {
+	if f.Stmts != nil {
+		return f
+	}
+	for d := range b.functions {
+		if d.SymName != f.SymName || len(d.Params) != len(f.Params) {
+			continue
+		}
+		match := true
+		for i, p := range d.Params {
+			if p.SymType == nil || f.Params[i].SymType == nil || !p.SymType.Equal(f.Params[i].SymType) {
+				match = false
+			}
+		}
+		if match {
+			return d
+		}
+	}
+	return nil
+}
+
+func (b *metricsBuilder) alu() {
+	if b.fn != nil {
+		b.m.ALUOps++
+		b.fn.weightedALU += b.weight
+	}
+}
+
+func (b *metricsBuilder) texture() {
+	if b.fn != nil {
+		b.m.TextureOps++
+		b.fn.weightedTex += b.weight
+	}
+}
+
+func (b *metricsBuilder) precision(t ast.Type) {
+	switch t := t.(type) {
+	case *ast.BuiltinType:
+		if b.precisions[t] {
+			return
+		}
+		b.precisions[t] = true
+		switch t.Precision {
+		case ast.LowP:
+			b.m.LowP++
+		case ast.MediumP:
+			b.m.MediumP++
+		case ast.HighP:
+			b.m.HighP++
+		}
+	case *ast.ArrayType:
+		b.precision(t.Base)
+	}
+}
+
+// define starts the live range of the local variable sym.
+func (b *metricsBuilder) define(sym ast.ValueSymbol) {
+	if b.fn == nil {
+		return
+	}
+	b.pos++
+	b.ranges[sym] = &liveRange{first: b.pos, last: b.pos, registers: b.registers(sym.Type())}
+}
+
+// reference marks the symbol sym as used at the current position.
+func (b *metricsBuilder) reference(sym ast.ValueSymbol) {
+	if v := b.interfaces[sym]; v != nil {
+		v.used = true
+	}
+	b.pos++
+	if r := b.ranges[sym]; r != nil {
+		r.last = b.pos
+	}
+}
+
+// loop walks the parts of a loop, weighting them by the given number of iterations. The
+// variables declared before the loop and used inside it are kept live for the whole loop.
+func (b *metricsBuilder) loop(iterations float64, parts ...interface{}) {
+	b.m.Loops++
+	start, weight := b.pos, b.weight
+	b.weight *= iterations
+	for _, p := range parts {
+		b.walk(p)
+	}
+	b.weight = weight
+	for _, r := range b.ranges {
+		if r.first <= start && r.last > start {
+			r.last = b.pos
+		}
+	}
+}
+
+func (b *metricsBuilder) function(f *ast.FunctionDecl) {
+	b.precision(f.RetType)
+	for _, p := range f.Params {
+		b.precision(p.SymType)
+	}
+	if f.Stmts == nil {
+		return
+	}
+	b.fn = &functionMetrics{calls: map[*ast.FunctionDecl]float64{}}
+	b.functions[f] = b.fn
+	b.weight, b.pos, b.ranges = 1, 0, map[ast.ValueSymbol]*liveRange{}
+	for _, p := range f.Params {
+		b.ranges[p] = &liveRange{registers: b.registers(p.SymType)}
+	}
+	b.walk(f.Stmts)
+
+	// Find the position with the most live registers.
+	for pos := 0; pos <= b.pos; pos++ {
+		live := 0
+		for _, r := range b.ranges {
+			if r.first <= pos && pos <= r.last {
+				live += r.registers
+			}
+		}
+		if live > b.m.Registers {
+			b.m.Registers = live
+		}
+	}
+	b.fn, b.ranges = nil, nil
+}
+
+// members counts the precision qualifiers of the members of a struct or uniform block.
+func (b *metricsBuilder) members(decls []*ast.MultiVarDecl) {
+	for _, d := range decls {
+		for _, v := range d.Vars {
+			b.precision(v.SymType)
+		}
+	}
+}
+
+func (b *metricsBuilder) call(e *ast.CallExpr) {
+	for _, a := range e.Args {
+		b.walk(a)
+	}
+	ref, ok := e.Callee.(*ast.VarRefExpr)
+	if !ok || ref.Sym == nil {
+		return // Type constructors are free.
+	}
+	switch f := ref.Sym.(type) {
+	case *ast.FunctionDecl:
+		if def := b.definition(f); def != nil {
+			if b.fn != nil {
+				b.fn.calls[def] += b.weight
+			}
+			return
+		}
+	case *ast.BuiltinFunction:
+	default:
+		return
+	}
+	// Builtin functions, including those not known to the parser.
+	if isTextureFunction(ref.Sym.Name()) {
+		b.texture()
+	} else {
+		b.alu()
+	}
+}
+
+func (b *metricsBuilder) walk(n interface{}) {
+	if e, ok := n.(ast.Expression); ok && isConstant(e, nil) {
+		return // Constant expressions are folded by the compiler.
+	}
+	switch n := n.(type) {
+	case *ast.Ast:
+		// Walk the function definitions first, so that calls can be matched to them.
+		for _, d := range n.Decls {
+			if f, ok := d.(*ast.FunctionDecl); ok && f.Stmts != nil {
+				b.functions[f] = nil
+			}
+		}
+		for _, d := range n.Decls {
+			b.walk(d)
+		}
+	case *ast.FunctionDecl:
+		b.function(n)
+	case *ast.PrecisionDecl:
+		b.precision(n.Type)
+	case *ast.MultiVarDecl:
+		for _, v := range n.Vars {
+			b.walk(v)
+		}
+	case *ast.UniformDecl:
+		if n.Block != nil {
+			b.members(n.Block.Vars)
+		}
+	case *ast.StructSym:
+		b.members(n.Vars)
+	case *ast.VariableSym:
+		b.precision(n.SymType)
+		if s, ok := n.SymType.(*ast.StructType); ok && s.StructDef && s.Sym != nil {
+			b.walk(s.Sym)
+		}
+		if n.Init != nil {
+			b.walk(n.Init)
+		}
+		b.define(n)
+
+	case *ast.CompoundStmt:
+		for _, s := range n.Stmts {
+			b.walk(s)
+		}
+	case *ast.ExpressionStmt:
+		b.walk(n.Expr)
+	case *ast.DeclarationStmt:
+		b.walk(n.Decl)
+	case *ast.IfStmt:
+		b.m.Branches++
+		b.walk(n.IfExpr)
+		b.walk(n.ThenStmt)
+		if n.ElseStmt != nil {
+			b.walk(n.ElseStmt)
+		}
+	case *ast.SwitchStmt:
+		b.m.Branches++
+		b.walk(n.Expr)
+		b.walk(n.Stmts)
+	case *ast.ReturnStmt:
+		if n.Expr != nil {
+			b.walk(n.Expr)
+		}
+	case *ast.WhileStmt:
+		b.loop(DefaultLoopIterations, n.Cond, n.Stmt)
+	case *ast.DoStmt:
+		b.loop(DefaultLoopIterations, n.Stmt, n.Expr)
+	case *ast.ForStmt:
+		if n.Init != nil {
+			b.walk(n.Init)
+		}
+		b.loop(b.iterations(n), n.Cond, n.Body, n.Loop)
+	case *ast.ExpressionCond:
+		b.walk(n.Expr)
+	case *ast.VarDeclCond:
+		b.walk(n.Sym)
+
+	case *ast.BinaryExpr:
+		b.walk(n.Left)
+		b.walk(n.Right)
+		if n.Op != ast.BoComma && n.Op != ast.BoAssign {
+			b.alu()
+		}
+	case *ast.UnaryExpr:
+		b.walk(n.Expr)
+		if n.Op != ast.UoPlus {
+			b.alu()
+		}
+	case *ast.ConditionalExpr:
+		b.m.Branches++
+		b.walk(n.Cond)
+		b.walk(n.TrueExpr)
+		b.walk(n.FalseExpr)
+	case *ast.IndexExpr:
+		b.walk(n.Base)
+		b.walk(n.Index)
+	case *ast.DotExpr:
+		b.walk(n.Expr)
+	case *ast.ParenExpr:
+		b.walk(n.Expr)
+	case *ast.CallExpr:
+		b.call(n)
+	case *ast.VarRefExpr:
+		if n.Sym != nil {
+			b.reference(n.Sym)
+		}
+	}
+}
+
+// evaluate returns the numeric value of the constant expression e, written in the language
+// lang.
+func evaluate(e ast.Expression, lang ast.Language) (val float64, ok bool) {
+	if e == nil || !isConstant(e, nil) {
+		return 0, false
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			val, ok = 0, false // The expression could not be evaluated.
+		}
+	}()
+	var resolve func(sym ast.ValueSymbol) ast.Value
+	resolve = func(sym ast.ValueSymbol) ast.Value {
+		v, err := evaluator.Evaluate(sym.(*ast.VariableSym).Init, resolve, lang)
+		if len(err) > 0 {
+			panic(err[0])
+		}
+		return v
+	}
+	v, err := evaluator.Evaluate(e, resolve, lang)
+	if len(err) > 0 {
+		return 0, false
+	}
+	switch v := v.(type) {
+	case ast.IntValue:
+		return float64(v), true
+	case ast.UintValue:
+		return float64(v), true
+	case ast.FloatValue:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// iterations returns the number of iterations of the for loop l. If the loop is not of the
+// form for(i = a; i op b; i += c) with constant a, b and c, then DefaultLoopIterations is
+// returned.
+func (b *metricsBuilder) iterations(l *ast.ForStmt) float64 {
+	var counter ast.ValueSymbol
+	var init ast.Expression
+	switch s := l.Init.(type) {
+	case *ast.DeclarationStmt:
+		if d, ok := s.Decl.(*ast.MultiVarDecl); ok && len(d.Vars) == 1 {
+			counter, init = d.Vars[0], d.Vars[0].Init
+		}
+	case *ast.ExpressionStmt:
+		if e, ok := s.Expr.(*ast.BinaryExpr); ok && e.Op == ast.BoAssign {
+			if ref, ok := e.Left.(*ast.VarRefExpr); ok {
+				counter, init = ref.Sym, e.Right
+			}
+		}
+	}
+	isCounter := func(e ast.Expression) bool {
+		ref, ok := e.(*ast.VarRefExpr)
+		return ok && counter != nil && ref.Sym == counter
+	}
+
+	cond, ok := l.Cond.(*ast.ExpressionCond)
+	if !ok {
+		return DefaultLoopIterations
+	}
+	cmp, ok := cond.Expr.(*ast.BinaryExpr)
+	if !ok || !isCounter(cmp.Left) {
+		return DefaultLoopIterations
+	}
+
+	step := 0.0
+	switch e := l.Loop.(type) {
+	case *ast.UnaryExpr:
+		if isCounter(e.Expr) {
+			switch e.Op {
+			case ast.UoPreinc, ast.UoPostinc:
+				step = 1
+			case ast.UoPredec, ast.UoPostdec:
+				step = -1
+			}
+		}
+	case *ast.BinaryExpr:
+		if isCounter(e.Left) {
+			if v, ok := evaluate(e.Right, b.lang); ok {
+				switch e.Op {
+				case ast.BoAddAssign:
+					step = v
+				case ast.BoSubAssign:
+					step = -v
+				}
+			}
+		}
+	}
+
+	start, ok := evaluate(init, b.lang)
+	if !ok || step == 0 {
+		return DefaultLoopIterations
+	}
+	end, ok := evaluate(cmp.Right, b.lang)
+	if !ok {
+		return DefaultLoopIterations
+	}
+
+	n := (end - start) / step
+	switch cmp.Op {
+	case ast.BoLess, ast.BoMore, ast.BoNotEq:
+		n = math.Ceil(n)
+	case ast.BoLessEq, ast.BoMoreEq:
+		n = math.Floor(n) + 1
+	default:
+		return DefaultLoopIterations
+	}
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// registers returns the number of vec4 registers needed to hold a value of type t.
+func (b *metricsBuilder) registers(t ast.Type) int {
+	switch t := t.(type) {
+	case *ast.BuiltinType:
+		if ast.GetFundamentalType(t.Type) == ast.TVoid {
+			return 0 // Samplers and void.
+		}
+		col, row := ast.TypeDimensions(t.Type)
+		return int(col) * ((int(row) + 3) / 4)
+	case *ast.ArrayType:
+		size := arraySize(t, b.lang)
+		if size == 0 {
+			size = 1
+		}
+		return size * b.registers(t.Base)
+	case *ast.StructType:
+		if t.Sym == nil {
+			return 0
+		}
+		count := 0
+		for _, d := range t.Sym.Vars {
+			for _, v := range d.Vars {
+				count += b.registers(v.SymType)
+			}
+		}
+		return count
+	}
+	return 0
+}
+
+// arraySize returns the size of the array type t, or 0 if the size is not known.
+func arraySize(t *ast.ArrayType, lang ast.Language) int {
+	if t.ComputedSize != 0 {
+		return int(t.ComputedSize)
+	}
+	if v, ok := evaluate(t.Size, lang); ok && v > 0 {
+		return int(v)
+	}
+	return 0
+}
+
+func isSampler(t ast.Type) bool {
+	switch t := t.(type) {
+	case *ast.BuiltinType:
+		return strings.Contains(t.Type.String(), "sampler")
+	case *ast.ArrayType:
+		return isSampler(t.Base)
+	}
+	return false
+}
+
+func isTextureFunction(name string) bool {
+	return strings.HasPrefix(name, "texture") || strings.HasPrefix(name, "texel") ||
+		strings.HasPrefix(name, "shadow")
+}
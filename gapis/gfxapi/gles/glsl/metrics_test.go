@@ -0,0 +1,128 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glsl
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/gapid/gapis/gfxapi/gles/glsl/ast"
+)
+
+func TestComputeMetrics(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		lang     ast.Language
+		source   string
+		expected Metrics
+	}{
+		{
+			name: "vertex",
+			lang: ast.LangVertexShader,
+			source: `
+attribute highp vec4 position;
+attribute vec2 texcoord;
+uniform mat4 mvp;
+uniform vec4 unused;
+varying mediump vec2 uv;
+void main() {
+	uv = texcoord;
+	gl_Position = mvp * position;
+}`,
+			expected: Metrics{
+				ALUOps:         1,
+				WeightedALUOps: 1,
+				Uniforms:       []string{"mvp"},
+				Inputs:         []string{"position", "texcoord"},
+				Outputs:        []string{"uv"},
+				MediumP:        1,
+				HighP:          1,
+			},
+		},
+		{
+			name: "fragment",
+			lang: ast.LangFragmentShader,
+			source: `
+precision mediump float;
+const int N = 4;
+uniform sampler2D tex;
+uniform lowp vec4 color;
+varying vec2 uv;
+vec4 shade(vec4 c) {
+	return c.x > 0.5 ? c * color : c;
+}
+void main() {
+	vec4 c = texture2D(tex, uv);
+	for (int i = 0; i < N * 2; i++) {
+		c = shade(c) + vec4(0.1);
+	}
+	if (c.a < 0.5) {
+		discard;
+	}
+	gl_FragColor = max(c, 0.0);
+}`,
+			expected: Metrics{
+				ALUOps:             7,
+				TextureOps:         1,
+				WeightedALUOps:     2 + 8*(3+2),
+				WeightedTextureOps: 1,
+				Branches:           2,
+				Loops:              1,
+				Uniforms:           []string{"color"},
+				Inputs:             []string{"uv"},
+				Samplers:           []string{"tex"},
+				LowP:               1,
+				MediumP:            1,
+				Registers:          2,
+			},
+		},
+		{
+			name: "loops",
+			lang: ast.LangFragmentShader,
+			source: `
+precision highp float;
+uniform float k;
+void main() {
+	float a = 0.0;
+	for (int i = 10; i >= 0; i -= 2) {
+		a += k;
+	}
+	while (a > 1.0) {
+		a *= 0.5;
+	}
+	gl_FragColor = vec4(a);
+}`,
+			expected: Metrics{
+				ALUOps:         5,
+				WeightedALUOps: 6*3 + 16*2,
+				Loops:          2,
+				Uniforms:       []string{"k"},
+				HighP:          1,
+				Registers:      2,
+			},
+		},
+	} {
+		program, _, _, errs := Parse(test.source, test.lang)
+		if len(errs) > 0 {
+			t.Errorf("Unexpected errors parsing %s: %v", test.name, errs)
+			continue
+		}
+		got := ComputeMetrics(program, test.lang)
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("Metrics of %s were not as expected.\nExpected: %+v\nGot:      %+v",
+				test.name, test.expected, got)
+		}
+	}
+}
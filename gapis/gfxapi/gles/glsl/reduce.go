@@ -0,0 +1,252 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glsl
+
+import (
+	"context"
+
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/gapis/gfxapi/gles/glsl/ast"
+	pp "github.com/google/gapid/gapis/gfxapi/gles/glsl/preprocessor"
+)
+
+// Predicate is the function used by Reduce to test whether a candidate shader source still
+// exhibits the property being reduced for. Returning an error aborts the reduction.
+type Predicate func(ctx context.Context, src string) (bool, error)
+
+// Reduce attempts to find a smaller shader than src for which keep still returns true. It
+// repeatedly deletes declarations and statements, replaces control flow statements with their
+// bodies and replaces expressions with one of their operands. Each candidate is formatted with
+// Format and must parse successfully before keep is called. The reduction stops once no single
+// change produces a smaller source accepted by keep, and the smallest accepted source is
+// returned. The original source src is not passed to keep.
+//
+// As a candidate may be syntactically valid but fail to compile, keep should reject candidates
+// which only exhibit the property because they are invalid.
+func Reduce(ctx context.Context, src string, lang ast.Language, keep Predicate) (string, error) {
+	program, version, extensions, errs := Parse(src, lang)
+	if len(errs) > 0 {
+		return src, errs[0]
+	}
+	tree, ok := program.(*ast.Ast)
+	if !ok {
+		return src, nil
+	}
+	r := &reducer{
+		tree:       tree,
+		lang:       lang,
+		version:    version,
+		extensions: extensions,
+		keep:       keep,
+		best:       Format(tree, version, extensions),
+	}
+
+	for progress := true; progress; {
+		progress = false
+		edits := r.edits()
+		for i := 0; i < len(edits); {
+			if task.Stopped(ctx) {
+				return r.best, task.StopReason(ctx)
+			}
+			e := edits[i]
+			e.apply()
+			ok, err := r.try(ctx)
+			if err != nil {
+				e.undo()
+				return r.best, err
+			}
+			if !ok {
+				e.undo()
+				i++
+				continue
+			}
+			// The tree has changed, so collect the edits again. The edits before i were already
+			// rejected, so carry on from the same position.
+			progress = true
+			edits = r.edits()
+		}
+	}
+	return r.best, nil
+}
+
+// edit is a single reversible change of the tree.
+type edit struct {
+	apply, undo func()
+}
+
+type reducer struct {
+	tree       *ast.Ast
+	lang       ast.Language
+	version    Version
+	extensions []pp.Extension
+	keep       Predicate
+	best       string
+}
+
+// try formats the current tree and returns true if it is smaller than the best source found so
+// far, parses and is accepted by the predicate. If so, it becomes the new best source.
+func (r *reducer) try(ctx context.Context) (bool, error) {
+	src := Format(r.tree, r.version, r.extensions)
+	if len(src) >= len(r.best) {
+		return false, nil
+	}
+	if _, _, _, errs := Parse(src, r.lang); len(errs) > 0 {
+		return false, nil
+	}
+	ok, err := r.keep(ctx, src)
+	if err != nil || !ok {
+		return false, err
+	}
+	r.best = src
+	return true, nil
+}
+
+// edits returns the candidate changes of the tree. Coarser changes, which remove more of the
+// program, are returned first.
+func (r *reducer) edits() []edit {
+	out := []edit{}
+	out = append(out, removals(&r.tree.Decls, func(d interface{}) bool {
+		f, ok := d.(*ast.FunctionDecl)
+		return !ok || f.SymName != "main" || f.Stmts == nil
+	})...)
+
+	exprs := []edit{}
+	ast.Inspect(r.tree, func(n interface{}) bool {
+		if c, ok := n.(*ast.CompoundStmt); ok {
+			out = append(out, removals(&c.Stmts, func(interface{}) bool { return true })...)
+			for i, s := range c.Stmts {
+				for _, b := range bodies(s) {
+					out = append(out, replacement(&c.Stmts[i], b))
+				}
+			}
+		}
+		if s, ok := n.(*ast.IfStmt); ok && s.ElseStmt != nil {
+			out = append(out, replacement(&s.ElseStmt, nil))
+		}
+		for _, slot := range slots(n) {
+			for _, o := range operands(*slot) {
+				exprs = append(exprs, exprReplacement(slot, o))
+			}
+		}
+		return true
+	})
+	return append(out, exprs...)
+}
+
+// removals returns the edits deleting each of the elements of list for which ok returns true.
+func removals(list *[]interface{}, ok func(interface{}) bool) []edit {
+	out := []edit{}
+	for i, n := range *list {
+		if !ok(n) {
+			continue
+		}
+		i := i
+		var old []interface{}
+		out = append(out, edit{
+			apply: func() {
+				old = *list
+				*list = append(append([]interface{}{}, old[:i]...), old[i+1:]...)
+			},
+			undo: func() { *list = old },
+		})
+	}
+	return out
+}
+
+func replacement(slot *interface{}, n interface{}) edit {
+	old := *slot
+	return edit{
+		apply: func() { *slot = n },
+		undo:  func() { *slot = old },
+	}
+}
+
+func exprReplacement(slot *ast.Expression, e ast.Expression) edit {
+	old := *slot
+	return edit{
+		apply: func() { *slot = e },
+		undo:  func() { *slot = old },
+	}
+}
+
+// bodies returns the statements which can replace the control flow statement s.
+func bodies(s interface{}) []interface{} {
+	switch s := s.(type) {
+	case *ast.IfStmt:
+		if s.ElseStmt != nil {
+			return []interface{}{s.ThenStmt, s.ElseStmt}
+		}
+		return []interface{}{s.ThenStmt}
+	case *ast.WhileStmt:
+		return []interface{}{s.Stmt}
+	case *ast.DoStmt:
+		return []interface{}{s.Stmt}
+	case *ast.ForStmt:
+		return []interface{}{s.Body}
+	}
+	return nil
+}
+
+// operands returns the sub-expressions which can replace the expression e. The operands of an
+// expression usually bind at least as tightly as the expression itself, so they can be
+// substituted without adding parentheses.
+func operands(e ast.Expression) []ast.Expression {
+	switch e := e.(type) {
+	case *ast.BinaryExpr:
+		return []ast.Expression{e.Left, e.Right}
+	case *ast.ConditionalExpr:
+		return []ast.Expression{e.TrueExpr, e.FalseExpr}
+	case *ast.UnaryExpr:
+		return []ast.Expression{e.Expr}
+	}
+	return nil
+}
+
+// slots returns pointers to the expression children of the node n.
+func slots(n interface{}) []*ast.Expression {
+	switch n := n.(type) {
+	case *ast.VariableSym:
+		if n.Init != nil {
+			return []*ast.Expression{&n.Init}
+		}
+	case *ast.ExpressionStmt:
+		return []*ast.Expression{&n.Expr}
+	case *ast.ReturnStmt:
+		if n.Expr != nil {
+			return []*ast.Expression{&n.Expr}
+		}
+	case *ast.IfStmt:
+		return []*ast.Expression{&n.IfExpr}
+	case *ast.ExpressionCond:
+		return []*ast.Expression{&n.Expr}
+	case *ast.BinaryExpr:
+		return []*ast.Expression{&n.Left, &n.Right}
+	case *ast.ConditionalExpr:
+		return []*ast.Expression{&n.Cond, &n.TrueExpr, &n.FalseExpr}
+	case *ast.UnaryExpr:
+		return []*ast.Expression{&n.Expr}
+	case *ast.ParenExpr:
+		return []*ast.Expression{&n.Expr}
+	case *ast.IndexExpr:
+		return []*ast.Expression{&n.Index}
+	case *ast.CallExpr:
+		out := make([]*ast.Expression, len(n.Args))
+		for i := range n.Args {
+			out[i] = &n.Args[i]
+		}
+		return out
+	}
+	return nil
+}
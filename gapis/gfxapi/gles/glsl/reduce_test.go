@@ -0,0 +1,74 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glsl
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/gapid/gapis/gfxapi/gles/glsl/ast"
+)
+
+func TestReduce(t *testing.T) {
+	src := `#version 100
+precision mediump float;
+uniform vec4 color;
+uniform float scale;
+varying vec2 uv;
+vec4 tint(vec4 c) {
+	return c * 0.5;
+}
+void main() {
+	vec4 c = tint(color) + vec4(uv, 0.0, 1.0);
+	if (scale > 1.0) {
+		c = c * scale;
+	} else {
+		c = vec4(0.0);
+	}
+	for (int i = 0; i < 4; i++) {
+		c += color;
+	}
+	gl_FragColor = c.x > 0.5 ? c : vec4(1.0);
+}`
+	calls := 0
+	got, err := Reduce(context.Background(), src, ast.LangFragmentShader, func(ctx context.Context, s string) (bool, error) {
+		calls++
+		return strings.Contains(s, "scale") && strings.Contains(s, "gl_FragColor"), nil
+	})
+	if err != nil {
+		t.Fatalf("Reduce returned error: %v", err)
+	}
+	if calls == 0 {
+		t.Errorf("Predicate was never called")
+	}
+	expected := `#version 100
+uniform float scale;
+void main() {
+    gl_FragColor;
+}
+`
+	if got != expected {
+		t.Errorf("Reduce returned:\n%s\nexpected:\n%s", got, expected)
+	}
+
+	fail := errors.New("fail")
+	if _, err := Reduce(context.Background(), src, ast.LangFragmentShader, func(ctx context.Context, s string) (bool, error) {
+		return false, fail
+	}); err != fail {
+		t.Errorf("Reduce returned error %v, expected %v", err, fail)
+	}
+}
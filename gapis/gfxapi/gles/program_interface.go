@@ -0,0 +1,66 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gles
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/gfxapi/gles/glsl"
+	"github.com/google/gapid/gapis/gfxapi/gles/glsl/ast"
+	"github.com/google/gapid/gapis/service"
+)
+
+// checkProgramInterface reports mismatches between the interfaces of the
+// vertex and fragment shaders attached to the program being linked, and
+// attribute locations which are not supported by the replay device.
+func (t *findIssues) checkProgramInterface(a atom.Atom, i atom.ID, program *Program) {
+	maxAttribs := int(t.device.GetConfiguration().GetDrivers().GetOpenGL().GetMaxVertexAttribs())
+
+	names := make([]string, 0, len(program.AttributeBindings))
+	for name := range program.AttributeBindings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if loc := int(program.AttributeBindings[name]); maxAttribs > 0 && loc >= maxAttribs {
+			t.onIssue(a, i, service.Severity_ErrorLevel, fmt.Errorf("Program %d binds attribute '%s' "+
+				"to location %d, but the device only supports %d vertex attributes.",
+				program.ID, name, loc, maxAttribs))
+		}
+	}
+
+	vs, fs := program.Shaders[GLenum_GL_VERTEX_SHADER], program.Shaders[GLenum_GL_FRAGMENT_SHADER]
+	if vs == nil || fs == nil {
+		return
+	}
+	// Shaders which fail to parse have already been reported by glShaderSource.
+	vertex, _, _, errs := glsl.Parse(vs.Source, ast.LangVertexShader)
+	if len(errs) > 0 {
+		return
+	}
+	fragment, _, _, errs := glsl.Parse(fs.Source, ast.LangFragmentShader)
+	if len(errs) > 0 {
+		return
+	}
+	for _, d := range glsl.CheckInterface(vertex, fragment, maxAttribs) {
+		severity := service.Severity_WarningLevel
+		if d.Severity == glsl.SeverityError {
+			severity = service.Severity_ErrorLevel
+		}
+		t.onIssue(a, i, severity, fmt.Errorf("Program %d: %s", program.ID, d.Message))
+	}
+}
@@ -163,7 +163,11 @@ func (s *Shader) ResourceData(ctx context.Context, t *gfxapi.State) (*gfxapi.Res
 		ty = gfxapi.ShaderType_Compute
 	}
 
-	return gfxapi.NewResourceData(&gfxapi.Shader{Type: ty, Source: s.Source}), nil
+	return gfxapi.NewResourceData(&gfxapi.Shader{
+		Type:    ty,
+		Source:  s.Source,
+		Metrics: shaderMetrics(ty, s.Source),
+	}), nil
 }
 
 func (shader *Shader) SetResourceData(
@@ -178,6 +182,10 @@ func (shader *Shader) SetResourceData(
 		return fmt.Errorf("Subcommands currently not supported") // TODO: Subcommands
 	}
 
+	if err := shader.checkSource(ctx, data.GetShader().GetSource()); err != nil {
+		return err
+	}
+
 	// Dirty. TODO: Make separate type for getting info for a single resource.
 	capturePath := at.Capture
 	resources, err := resolve.Resources(ctx, capturePath)
@@ -270,8 +278,9 @@ func (p *Program) ResourceData(ctx context.Context, s *gfxapi.State) (*gfxapi.Re
 			ty = gfxapi.ShaderType_Compute
 		}
 		shaders = append(shaders, &gfxapi.Shader{
-			Type:   ty,
-			Source: shader.Source,
+			Type:    ty,
+			Source:  shader.Source,
+			Metrics: shaderMetrics(ty, shader.Source),
 		})
 	}
 
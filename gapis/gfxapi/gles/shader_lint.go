@@ -0,0 +1,67 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gles
+
+import (
+	"context"
+
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/gfxapi/gles/glsl"
+	"github.com/google/gapid/gapis/gfxapi/gles/glsl/ast"
+	"github.com/google/gapid/gapis/messages"
+	"github.com/google/gapid/gapis/service"
+)
+
+// checkSource parses, analyzes and lints the edited source of the shader.
+// If any errors are found, checkSource returns a service.ErrInvalidArgument
+// holding all the problems found. Warnings are only logged.
+func (shader *Shader) checkSource(ctx context.Context, source string) error {
+	var lang ast.Language
+	switch shader.ShaderType {
+	case GLenum_GL_VERTEX_SHADER:
+		lang = ast.LangVertexShader
+	case GLenum_GL_FRAGMENT_SHADER:
+		lang = ast.LangFragmentShader
+	default:
+		return nil // Only vertex and fragment shaders can be parsed.
+	}
+
+	diagnostics := glsl.Check(source, lang)
+	errors := 0
+	out := make([]*service.Diagnostic, len(diagnostics))
+	for i, d := range diagnostics {
+		severity := service.Severity_WarningLevel
+		if d.Severity == glsl.SeverityError {
+			severity = service.Severity_ErrorLevel
+			errors++
+		}
+		out[i] = &service.Diagnostic{
+			Severity: severity,
+			Line:     uint32(d.Line),
+			Column:   uint32(d.Column),
+			Message:  d.Message,
+		}
+	}
+	if errors > 0 {
+		return &service.ErrInvalidArgument{
+			Reason:      messages.ErrInvalidShader(uint32(errors)),
+			Diagnostics: out,
+		}
+	}
+	for _, d := range diagnostics {
+		log.W(ctx, "Shader<%d>: %v", shader.ID, d)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gles
+
+import (
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/gfxapi/gles/glsl"
+	"github.com/google/gapid/gapis/gfxapi/gles/glsl/ast"
+)
+
+// shaderMetrics returns the static metrics of the shader source of type ty, or
+// nil if the source could not be parsed.
+func shaderMetrics(ty gfxapi.ShaderType, source string) *gfxapi.ShaderMetrics {
+	var lang ast.Language
+	switch ty {
+	case gfxapi.ShaderType_Vertex:
+		lang = ast.LangVertexShader
+	case gfxapi.ShaderType_Fragment:
+		lang = ast.LangFragmentShader
+	default:
+		return nil
+	}
+	program, _, _, errs := glsl.Parse(source, lang)
+	if len(errs) > 0 {
+		return nil
+	}
+	m := glsl.ComputeMetrics(program, lang)
+	return &gfxapi.ShaderMetrics{
+		AluOps:             uint32(m.ALUOps),
+		TextureOps:         uint32(m.TextureOps),
+		WeightedAluOps:     float32(m.WeightedALUOps),
+		WeightedTextureOps: float32(m.WeightedTextureOps),
+		Branches:           uint32(m.Branches),
+		Loops:              uint32(m.Loops),
+		Uniforms:           m.Uniforms,
+		Inputs:             m.Inputs,
+		Outputs:            m.Outputs,
+		Samplers:           m.Samplers,
+		Lowp:               uint32(m.LowP),
+		Mediump:            uint32(m.MediumP),
+		Highp:              uint32(m.HighP),
+		Registers:          uint32(m.Registers),
+	}
+}
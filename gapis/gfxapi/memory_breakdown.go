@@ -0,0 +1,29 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gfxapi
+
+import "context"
+
+// UnknownHeapIndex is the heap index of the allocations whose memory heap is
+// not known.
+const UnknownHeapIndex = ^uint32(0)
+
+// MemoryBreakdownProvider is the interface implemented by APIs that can report
+// their device memory allocations.
+type MemoryBreakdownProvider interface {
+	// MemoryBreakdown returns the device memory allocations of the API in the
+	// state s. If nil, nil then the API has no device memory allocations.
+	MemoryBreakdown(ctx context.Context, s *State) (*MemoryBreakdown, error)
+}
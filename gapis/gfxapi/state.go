@@ -40,6 +40,9 @@ type State struct {
 	// NextPoolID hold the identifier of the next Pool to be created.
 	NextPoolID memory.PoolID
 
+	// Thread is the identifier of the thread that is executing the commands.
+	Thread uint64
+
 	// APIs holds the per-API context states.
 	APIs map[API]interface{}
 
@@ -0,0 +1,100 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/gapil"
+	"github.com/google/gapid/gapil/interpreter"
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/database"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/memory"
+)
+
+// TestInterpreterMatchesMutate checks that interpreting the commands of the
+// test API gives the same results as their generated mutators.
+func TestInterpreterMatchesMutate(t *testing.T) {
+	ctx := log.Testing(t)
+	ctx = database.Put(ctx, database.NewInMemory(ctx))
+	api, errs := gapil.NewProcessor().Resolve("gfxapi_test.api")
+	if !assert.For(ctx, "resolve errors").That(len(errs)).Equals(0) {
+		return
+	}
+	ip := func(addr uint64) interpreter.Pointer {
+		return interpreter.Pointer{Address: addr, Pool: memory.ApplicationPool}
+	}
+
+	for _, test := range []struct {
+		atom  atom.Atom
+		name  string
+		args  []interpreter.Value
+		addrs []uint64 // Addresses of the memory written by the command.
+		size  uint64   // Size of the memory written at each address.
+	}{
+		{NewCmdVoidWriteU8(p(0x1000)), "cmdVoidWriteU8", []interpreter.Value{ip(0x1000)}, []uint64{0x1000}, 1},
+		{NewCmdVoidWriteS16(p(0x1000)), "cmdVoidWriteS16", []interpreter.Value{ip(0x1000)}, []uint64{0x1000}, 2},
+		{NewCmdVoidWriteU32(p(0x1000)), "cmdVoidWriteU32", []interpreter.Value{ip(0x1000)}, []uint64{0x1000}, 4},
+		{NewCmdVoidWriteF64(p(0x1000)), "cmdVoidWriteF64", []interpreter.Value{ip(0x1000)}, []uint64{0x1000}, 8},
+		{NewCmdVoidWriteBool(p(0x1000)), "cmdVoidWriteBool", []interpreter.Value{ip(0x1000)}, []uint64{0x1000}, 1},
+		{
+			NewCmdVoidWritePtrs(p(0x1000), p(0x2000), p(0x3000)),
+			"cmdVoidWritePtrs",
+			[]interpreter.Value{ip(0x1000), ip(0x2000), ip(0x3000)},
+			[]uint64{0x1000, 0x2000, 0x3000}, 4,
+		},
+	} {
+		ctx := log.V{"command": test.name}.Bind(ctx)
+		generated := gfxapi.NewStateWithEmptyAllocator(device.Little32)
+		test.atom.Mutate(ctx, generated, nil)
+
+		interpreted := interpreter.New(api, device.Little32)
+		_, err := interpreted.Call(ctx, test.name, test.args...)
+		assert.For(ctx, "interpreter error").ThatError(err).Succeeded()
+
+		for _, addr := range test.addrs {
+			rng := memory.Range{Base: addr, Size: test.size}
+			got, expected := make([]byte, test.size), make([]byte, test.size)
+			interpreted.Memory[memory.ApplicationPool].Slice(rng).Get(ctx, 0, got)
+			generated.Memory[memory.ApplicationPool].Slice(rng).Get(ctx, 0, expected)
+			assert.For(ctx, "memory at 0x%x", addr).ThatSlice(got).Equals(expected)
+		}
+	}
+
+	// cmdAdd calls a subroutine and stores the result in a new slice.
+	generated := gfxapi.NewStateWithEmptyAllocator(device.Little32)
+	NewCmdAdd(10, 20).Mutate(ctx, generated, nil)
+	expected := GetState(generated).Ints.Read(ctx, nil, generated, nil)
+
+	interpreted := interpreter.New(api, device.Little32)
+	_, err := interpreted.Call(ctx, "cmdAdd", 10, 20)
+	assert.For(ctx, "cmdAdd error").ThatError(err).Succeeded()
+	ints, ok := interpreted.Global("ints").(interpreter.Slice)
+	if !assert.For(ctx, "ints is a slice").That(ok).Equals(true) {
+		return
+	}
+	buf := make([]byte, ints.Count*4)
+	interpreted.Memory[ints.Pool].Slice(memory.Range{Base: ints.Base, Size: uint64(len(buf))}).Get(ctx, 0, buf)
+	got := make([]memory.Int, ints.Count)
+	for i := range got {
+		got[i] = memory.Int(int32(binary.LittleEndian.Uint32(buf[i*4:])))
+	}
+	assert.For(ctx, "ints").ThatSlice(got).Equals(expected)
+}
@@ -30,13 +30,19 @@ set(files
     enum.go
     externs.go
     find_issues.go
+    find_issues_test.go
+    memory_breakdown.go
+    memory_breakdown_test.go
     mutate.go
     read_framebuffer.go
     replay.go
     resolvables.pb.go
     resolvables.proto
     resources.go
+    resources_test.go
     state.go
+    subcommands.go
+    truncate_submit.go
     vulkan.go
 )
 set(dirs
@@ -19,28 +19,525 @@ import (
 	"fmt"
 
 	"github.com/google/gapid/core/data/binary"
+	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/gapis/atom"
 	"github.com/google/gapid/gapis/atom/transform"
+	"github.com/google/gapid/gapis/capture"
 	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/memory"
 	"github.com/google/gapid/gapis/replay"
 	"github.com/google/gapid/gapis/replay/builder"
 	"github.com/google/gapid/gapis/replay/value"
+	"github.com/google/gapid/gapis/service"
 )
 
 // findIssues is an atom transform that detects issues when replaying the
 // stream of atoms. Any issues that are found are written to all the chans in
 // the slice out. Once the last issue is sent (if any) all the chans in out are
 // closed.
-// NOTE: right now this transform is just used to close chans passed in requests.
+//
+// Commands recorded into command buffers are checked when they are executed
+// by a queue submission, as that is when the state they depend on is known.
 type findIssues struct {
-	res []replay.Result
+	state  *gfxapi.State
+	issues []replay.Issue
+	res    []replay.Result
+
+	// commands holds the information of each command recorded into a command
+	// buffer, needed to check the command when it is executed.
+	commands map[atom.Atom]*recordedCommand
+	// destroyed maps destroyed objects to the atom that destroyed them.
+	destroyed map[handle]atom.ID
+	// updated maps the descriptor sets allocated by the capture to whether
+	// they have been updated.
+	updated map[VkDescriptorSet]bool
+	// pendingWrites maps the resources written by executed commands to the
+	// command that wrote them, until a barrier synchronizes the writes.
+	pendingWrites map[handle]*recordedCommand
+	// renderPasses maps the command buffers being recorded to the render pass
+	// they have begun.
+	renderPasses map[VkCommandBuffer]renderPass
+}
+
+func newFindIssues(ctx context.Context, c *capture.Capture) *findIssues {
+	return &findIssues{
+		state:         c.NewState(),
+		commands:      map[atom.Atom]*recordedCommand{},
+		destroyed:     map[handle]atom.ID{},
+		updated:       map[VkDescriptorSet]bool{},
+		pendingWrites: map[handle]*recordedCommand{},
+		renderPasses:  map[VkCommandBuffer]renderPass{},
+	}
+}
+
+// handle is a Vulkan object handle, qualified by its type.
+type handle struct {
+	ty  string
+	val uint64
+}
+
+func (h handle) String() string { return fmt.Sprintf("%s 0x%x", h.ty, h.val) }
+
+func imageHandle(h VkImage) handle                 { return handle{"VkImage", uint64(h)} }
+func bufferHandle(h VkBuffer) handle               { return handle{"VkBuffer", uint64(h)} }
+func imageViewHandle(h VkImageView) handle         { return handle{"VkImageView", uint64(h)} }
+func bufferViewHandle(h VkBufferView) handle       { return handle{"VkBufferView", uint64(h)} }
+func pipelineHandle(h VkPipeline) handle           { return handle{"VkPipeline", uint64(h)} }
+func framebufferHandle(h VkFramebuffer) handle     { return handle{"VkFramebuffer", uint64(h)} }
+func renderPassHandle(h VkRenderPass) handle       { return handle{"VkRenderPass", uint64(h)} }
+func samplerHandle(h VkSampler) handle             { return handle{"VkSampler", uint64(h)} }
+func descriptorSetHandle(h VkDescriptorSet) handle { return handle{"VkDescriptorSet", uint64(h)} }
+
+// exists returns true if the object h is in the state.
+func (t *findIssues) exists(h handle) bool {
+	st := GetState(t.state)
+	switch h.ty {
+	case "VkImage":
+		return st.Images.Contains(VkImage(h.val))
+	case "VkBuffer":
+		return st.Buffers.Contains(VkBuffer(h.val))
+	case "VkImageView":
+		return st.ImageViews.Contains(VkImageView(h.val))
+	case "VkBufferView":
+		return st.BufferViews.Contains(VkBufferView(h.val))
+	case "VkPipeline":
+		return st.GraphicsPipelines.Contains(VkPipeline(h.val)) ||
+			st.ComputePipelines.Contains(VkPipeline(h.val))
+	case "VkFramebuffer":
+		return st.Framebuffers.Contains(VkFramebuffer(h.val))
+	case "VkRenderPass":
+		return st.RenderPasses.Contains(VkRenderPass(h.val))
+	case "VkSampler":
+		return st.Samplers.Contains(VkSampler(h.val))
+	case "VkDescriptorSet":
+		return st.DescriptorSets.Contains(VkDescriptorSet(h.val))
+	}
+	return true
+}
+
+// imageLayout is the layout an image is expected to be in.
+type imageLayout struct {
+	image  VkImage
+	layout VkImageLayout
+}
+
+// subpassExternal is the value of VK_SUBPASS_EXTERNAL.
+const subpassExternal = ^uint32(0)
+
+// renderPass is a render pass begun in a command buffer.
+type renderPass struct {
+	obj    *RenderPassObject
+	images []handle // The images of the framebuffer attachments.
+}
+
+// externalDependencies returns whether the render pass rp declares a
+// dependency on the commands before it (src) and after it (dst). Without an
+// explicit external dependency, the implicit one does not make the writes of
+// earlier commands available to the render pass, nor the writes of the
+// render pass available to later commands.
+func externalDependencies(rp *RenderPassObject) (src, dst bool) {
+	for _, d := range rp.SubpassDependencies {
+		src = src || d.SrcSubpass == subpassExternal
+		dst = dst || d.DstSubpass == subpassExternal
+	}
+	return src, dst
+}
+
+// recordedCommand holds the information of a command recorded into a command
+// buffer.
+type recordedCommand struct {
+	id        atom.ID           // The atom that recorded the command.
+	a         atom.Atom         // The atom that recorded the command.
+	uses      []handle          // Objects used by the command.
+	reads     []handle          // Resources read by the command.
+	writes    []handle          // Resources written by the command.
+	layouts   []imageLayout     // Image layouts expected by the command.
+	syncs     []handle          // Resources synchronized by the command.
+	syncAll   bool              // If true, all resources are synchronized.
+	sets      []VkDescriptorSet // Descriptor sets bound by the command.
+	secondary []VkCommandBuffer // Secondary command buffers executed.
+}
+
+func (c *recordedCommand) String() string {
+	return fmt.Sprintf("%v (atom %v)", c.a.AtomName(), c.id)
 }
 
 // reportTo adds r to the list of issue listeners.
 func (t *findIssues) reportTo(r replay.Result) { t.res = append(t.res, r) }
 
+func (t *findIssues) onIssue(i atom.ID, s service.Severity, e error) {
+	t.issues = append(t.issues, replay.Issue{Atom: i, Severity: s, Error: e})
+}
+
 func (t *findIssues) Transform(ctx context.Context, i atom.ID, a atom.Atom, out transform.Writer) {
+	ctx = log.Enter(ctx, "findIssues")
+
+	if submit, ok := a.(*VkQueueSubmit); ok {
+		restore := t.hookSubmit(ctx, submit)
+		defer restore()
+	}
+
+	if err := a.Mutate(ctx, t.state, nil /* no builder */); err != nil {
+		t.onIssue(i, service.Severity_ErrorLevel, err)
+	}
 	out.MutateAndWrite(ctx, i, a)
+
+	t.track(ctx, i, a)
+	if c := t.record(ctx, i, a); c != nil {
+		for _, h := range c.handles() {
+			t.checkDestroyed(c, h)
+		}
+		t.commands[a] = c
+	}
+}
+
+// track updates the tracking of destroyed objects and descriptor sets after
+// the atom a has been mutated.
+func (t *findIssues) track(ctx context.Context, i atom.ID, a atom.Atom) {
+	s, l := t.state, t.state.MemoryLayout
+	switch a := a.(type) {
+	case *VkDestroyImage:
+		t.destroyed[imageHandle(a.Image)] = i
+	case *VkDestroyBuffer:
+		t.destroyed[bufferHandle(a.Buffer)] = i
+	case *VkDestroyImageView:
+		t.destroyed[imageViewHandle(a.ImageView)] = i
+	case *VkDestroyBufferView:
+		t.destroyed[bufferViewHandle(a.BufferView)] = i
+	case *VkDestroyPipeline:
+		t.destroyed[pipelineHandle(a.Pipeline)] = i
+	case *VkDestroyFramebuffer:
+		t.destroyed[framebufferHandle(a.Framebuffer)] = i
+	case *VkDestroyRenderPass:
+		t.destroyed[renderPassHandle(a.RenderPass)] = i
+	case *VkDestroySampler:
+		t.destroyed[samplerHandle(a.Sampler)] = i
+	case *VkFreeDescriptorSets:
+		sets := a.PDescriptorSets.Slice(0, uint64(a.DescriptorSetCount), l).Read(ctx, a, s, nil)
+		for _, set := range sets {
+			t.destroyed[descriptorSetHandle(set)] = i
+			delete(t.updated, set)
+		}
+	case *VkAllocateDescriptorSets:
+		info := a.PAllocateInfo.Read(ctx, a, s, nil)
+		sets := a.PDescriptorSets.Slice(0, uint64(info.DescriptorSetCount), l).Read(ctx, a, s, nil)
+		for _, set := range sets {
+			t.updated[set] = false
+			delete(t.destroyed, descriptorSetHandle(set))
+		}
+	case *VkUpdateDescriptorSets:
+		writes := a.PDescriptorWrites.Slice(0, uint64(a.DescriptorWriteCount), l).Read(ctx, a, s, nil)
+		for _, w := range writes {
+			if _, ok := t.updated[w.DstSet]; ok {
+				t.updated[w.DstSet] = true
+			}
+		}
+		copies := a.PDescriptorCopies.Slice(0, uint64(a.DescriptorCopyCount), l).Read(ctx, a, s, nil)
+		for _, c := range copies {
+			if _, ok := t.updated[c.DstSet]; ok {
+				t.updated[c.DstSet] = true
+			}
+		}
+	}
+}
+
+// record returns the information needed to check the command recorded by the
+// atom a when it is executed, or nil if a does not record a checked command.
+func (t *findIssues) record(ctx context.Context, i atom.ID, a atom.Atom) *recordedCommand {
+	s, l := t.state, t.state.MemoryLayout
+	c := &recordedCommand{id: i, a: a}
+	switch a := a.(type) {
+	case *VkCmdBindPipeline:
+		c.uses = []handle{pipelineHandle(a.Pipeline)}
+
+	case *VkCmdBindVertexBuffers:
+		for _, b := range a.PBuffers.Slice(0, uint64(a.BindingCount), l).Read(ctx, a, s, nil) {
+			c.reads = append(c.reads, bufferHandle(b))
+		}
+
+	case *VkCmdBindIndexBuffer:
+		c.reads = []handle{bufferHandle(a.Buffer)}
+
+	case *VkCmdBindDescriptorSets:
+		c.sets = a.PDescriptorSets.Slice(0, uint64(a.DescriptorSetCount), l).Read(ctx, a, s, nil)
+		for _, set := range c.sets {
+			c.uses = append(c.uses, descriptorSetHandle(set))
+		}
+
+	case *VkCmdDrawIndirect:
+		c.reads = []handle{bufferHandle(a.Buffer)}
+
+	case *VkCmdDrawIndexedIndirect:
+		c.reads = []handle{bufferHandle(a.Buffer)}
+
+	case *VkCmdDispatchIndirect:
+		c.reads = []handle{bufferHandle(a.Buffer)}
+
+	case *VkCmdCopyBuffer:
+		c.reads = []handle{bufferHandle(a.SrcBuffer)}
+		c.writes = []handle{bufferHandle(a.DstBuffer)}
+
+	case *VkCmdUpdateBuffer:
+		c.writes = []handle{bufferHandle(a.DstBuffer)}
+
+	case *VkCmdFillBuffer:
+		c.writes = []handle{bufferHandle(a.DstBuffer)}
+
+	case *VkCmdCopyImage:
+		c.reads = []handle{imageHandle(a.SrcImage)}
+		c.writes = []handle{imageHandle(a.DstImage)}
+		c.layouts = []imageLayout{{a.SrcImage, a.SrcImageLayout}, {a.DstImage, a.DstImageLayout}}
+
+	case *VkCmdBlitImage:
+		c.reads = []handle{imageHandle(a.SrcImage)}
+		c.writes = []handle{imageHandle(a.DstImage)}
+		c.layouts = []imageLayout{{a.SrcImage, a.SrcImageLayout}, {a.DstImage, a.DstImageLayout}}
+
+	case *VkCmdResolveImage:
+		c.reads = []handle{imageHandle(a.SrcImage)}
+		c.writes = []handle{imageHandle(a.DstImage)}
+		c.layouts = []imageLayout{{a.SrcImage, a.SrcImageLayout}, {a.DstImage, a.DstImageLayout}}
+
+	case *VkCmdCopyBufferToImage:
+		c.reads = []handle{bufferHandle(a.SrcBuffer)}
+		c.writes = []handle{imageHandle(a.DstImage)}
+		c.layouts = []imageLayout{{a.DstImage, a.DstImageLayout}}
+
+	case *VkCmdCopyImageToBuffer:
+		c.reads = []handle{imageHandle(a.SrcImage)}
+		c.writes = []handle{bufferHandle(a.DstBuffer)}
+		c.layouts = []imageLayout{{a.SrcImage, a.SrcImageLayout}}
+
+	case *VkCmdClearColorImage:
+		c.writes = []handle{imageHandle(a.Image)}
+		c.layouts = []imageLayout{{a.Image, a.ImageLayout}}
+
+	case *VkCmdClearDepthStencilImage:
+		c.writes = []handle{imageHandle(a.Image)}
+		c.layouts = []imageLayout{{a.Image, a.ImageLayout}}
+
+	case *VkCmdBeginRenderPass:
+		info := a.PRenderPassBegin.Read(ctx, a, s, nil)
+		c.uses = []handle{renderPassHandle(info.RenderPass), framebufferHandle(info.Framebuffer)}
+		st := GetState(s)
+		fb, rp := st.Framebuffers.Get(info.Framebuffer), st.RenderPasses.Get(info.RenderPass)
+		if fb == nil || rp == nil {
+			break
+		}
+		t.beginRenderPass(c, a.CommandBuffer, rp, fb)
+
+	case *VkCmdEndRenderPass:
+		t.endRenderPass(c, a.CommandBuffer)
+
+	case *VkCmdPipelineBarrier:
+		t.recordBarrier(ctx, a, c, a.MemoryBarrierCount,
+			a.PBufferMemoryBarriers, a.BufferMemoryBarrierCount,
+			a.PImageMemoryBarriers, a.ImageMemoryBarrierCount)
+
+	case *VkCmdWaitEvents:
+		t.recordBarrier(ctx, a, c, a.MemoryBarrierCount,
+			a.PBufferMemoryBarriers, a.BufferMemoryBarrierCount,
+			a.PImageMemoryBarriers, a.ImageMemoryBarrierCount)
+
+	case *VkCmdExecuteCommands:
+		c.secondary = a.PCommandBuffers.Slice(0, uint64(a.CommandBufferCount), l).Read(ctx, a, s, nil)
+
+	default:
+		return nil
+	}
+	return c
+}
+
+// beginRenderPass fills in the command c beginning the render pass rp with
+// the framebuffer fb in the command buffer cb.
+func (t *findIssues) beginRenderPass(c *recordedCommand, cb VkCommandBuffer, rp *RenderPassObject, fb *FramebufferObject) {
+	src, _ := externalDependencies(rp)
+	pass := renderPass{obj: rp}
+	for idx, view := range fb.ImageAttachments {
+		if view == nil || view.Image == nil {
+			continue
+		}
+		img := view.Image.VulkanHandle
+		pass.images = append(pass.images, imageHandle(img))
+		c.writes = append(c.writes, imageHandle(img))
+		if src {
+			// The external dependency synchronizes the earlier writes to the
+			// attachments before they are loaded.
+			c.syncs = append(c.syncs, imageHandle(img))
+		}
+		desc, ok := rp.AttachmentDescriptions[idx]
+		if !ok {
+			continue
+		}
+		if desc.LoadOp == VkAttachmentLoadOp_VK_ATTACHMENT_LOAD_OP_LOAD {
+			c.reads = append(c.reads, imageHandle(img))
+		}
+		if desc.InitialLayout != VkImageLayout_VK_IMAGE_LAYOUT_UNDEFINED {
+			c.layouts = append(c.layouts, imageLayout{img, desc.InitialLayout})
+		}
+	}
+	t.renderPasses[cb] = pass
+}
+
+// endRenderPass fills in the command c ending the render pass begun in the
+// command buffer cb.
+func (t *findIssues) endRenderPass(c *recordedCommand, cb VkCommandBuffer) {
+	pass, ok := t.renderPasses[cb]
+	if !ok {
+		return
+	}
+	delete(t.renderPasses, cb)
+	if _, dst := externalDependencies(pass.obj); dst {
+		// The external dependency synchronizes the writes to the attachments
+		// with the commands after the render pass.
+		c.syncs = append(c.syncs, pass.images...)
+	}
+}
+
+func (t *findIssues) recordBarrier(ctx context.Context, a atom.Atom, c *recordedCommand,
+	memoryBarrierCount uint32,
+	bufferBarriers VkBufferMemoryBarrierᶜᵖ, bufferBarrierCount uint32,
+	imageBarriers VkImageMemoryBarrierᶜᵖ, imageBarrierCount uint32) {
+
+	s, l := t.state, t.state.MemoryLayout
+	c.syncAll = memoryBarrierCount > 0
+	for _, b := range bufferBarriers.Slice(0, uint64(bufferBarrierCount), l).Read(ctx, a, s, nil) {
+		c.syncs = append(c.syncs, bufferHandle(b.Buffer))
+	}
+	for _, b := range imageBarriers.Slice(0, uint64(imageBarrierCount), l).Read(ctx, a, s, nil) {
+		c.uses = append(c.uses, imageHandle(b.Image))
+		c.syncs = append(c.syncs, imageHandle(b.Image))
+		if b.OldLayout != VkImageLayout_VK_IMAGE_LAYOUT_UNDEFINED {
+			c.layouts = append(c.layouts, imageLayout{b.Image, b.OldLayout})
+		}
+	}
+}
+
+// handles returns all the objects and resources used by the command.
+func (c *recordedCommand) handles() []handle {
+	out := make([]handle, 0, len(c.uses)+len(c.reads)+len(c.writes))
+	out = append(out, c.uses...)
+	out = append(out, c.reads...)
+	return append(out, c.writes...)
+}
+
+// checkDestroyed reports an issue if the object h used by the command c has
+// been destroyed.
+func (t *findIssues) checkDestroyed(c *recordedCommand, h handle) {
+	if by, ok := t.destroyed[h]; ok && !t.exists(h) {
+		t.onIssue(c.id, service.Severity_ErrorLevel, fmt.Errorf(
+			"%v uses %v which was destroyed by atom %v", c, h, by))
+	}
+}
+
+// hookSubmit replaces the commands of the command buffers submitted by a with
+// commands that are checked as they are executed. The returned function
+// restores the original commands.
+func (t *findIssues) hookSubmit(ctx context.Context, a *VkQueueSubmit) (restore func()) {
+	s, l := t.state, t.state.MemoryLayout
+	a.Extras().Observations().ApplyReads(s.Memory[memory.ApplicationPool])
+
+	restores := []func(){}
+	for _, info := range a.PSubmits.Slice(0, uint64(a.SubmitCount), l).Read(ctx, a, s, nil) {
+		cbs := info.PCommandBuffers.Slice(0, uint64(info.CommandBufferCount), l).Read(ctx, a, s, nil)
+		for _, cb := range cbs {
+			restores = append(restores, t.hook(ctx, cb))
+		}
+	}
+	return func() {
+		for _, f := range restores {
+			f()
+		}
+		// Only writes within a single submission are checked for barriers.
+		t.pendingWrites = map[handle]*recordedCommand{}
+	}
+}
+
+// hook replaces the commands of the command buffer cb with commands that are
+// checked as they are executed. The returned function restores the original
+// commands.
+//
+// Commands deferred by a vkCmdWaitEvents are copied, hooked, to the pending
+// commands of the queue, so they are still checked when the atom signalling
+// the events executes them, after the hooks have been removed. Issues are
+// therefore reported against the atom that recorded the command.
+func (t *findIssues) hook(ctx context.Context, cb VkCommandBuffer) (restore func()) {
+	o := GetState(t.state).CommandBuffers.Get(cb)
+	if o == nil {
+		return func() {}
+	}
+	original := o.Commands
+	hooked := make(CommandBufferCommands, len(original))
+	for j, cmd := range original {
+		cmd := cmd
+		hooked[j] = CommandBufferCommand{func() {
+			done := t.execute(ctx, *cmd.a)
+			cmd.function()
+			done()
+		}, cmd.a}
+	}
+	o.Commands = hooked
+	return func() { o.Commands = original }
+}
+
+// execute checks the command recorded by the atom a, which is about to be
+// executed. The returned function must be called once the command has been
+// executed.
+func (t *findIssues) execute(ctx context.Context, a atom.Atom) (done func()) {
+	c, ok := t.commands[a]
+	if !ok {
+		return func() {}
+	}
+
+	for _, h := range c.handles() {
+		t.checkDestroyed(c, h)
+	}
+
+	st := GetState(t.state)
+	for _, l := range c.layouts {
+		if img := st.Images.Get(l.image); img != nil && img.Info.Layout != l.layout {
+			t.onIssue(c.id, service.Severity_ErrorLevel, fmt.Errorf(
+				"%v expects %v to be in layout %v, but it is in layout %v",
+				c, imageHandle(l.image), l.layout, img.Info.Layout))
+		}
+	}
+
+	for _, set := range c.sets {
+		if updated, ok := t.updated[set]; ok && !updated {
+			t.onIssue(c.id, service.Severity_WarningLevel, fmt.Errorf(
+				"%v binds %v which has not been updated since it was allocated",
+				c, descriptorSetHandle(set)))
+		}
+	}
+
+	// Synchronization happens before the reads of the command, as is the case
+	// for the external dependencies of a render pass.
+	if c.syncAll {
+		t.pendingWrites = map[handle]*recordedCommand{}
+	}
+	for _, h := range c.syncs {
+		delete(t.pendingWrites, h)
+	}
+	for _, h := range c.reads {
+		if w, ok := t.pendingWrites[h]; ok {
+			t.onIssue(c.id, service.Severity_WarningLevel, fmt.Errorf(
+				"%v reads %v written by %v without a pipeline barrier or subpass dependency", c, h, w))
+			delete(t.pendingWrites, h) // Only report the hazard once.
+		}
+	}
+	for _, h := range c.writes {
+		t.pendingWrites[h] = c
+	}
+
+	restores := make([]func(), len(c.secondary))
+	for j, cb := range c.secondary {
+		restores[j] = t.hook(ctx, cb)
+	}
+	return func() {
+		for _, f := range restores {
+			f()
+		}
+	}
 }
 
 func (t *findIssues) Flush(ctx context.Context, out transform.Writer) {
@@ -60,7 +557,7 @@ func (t *findIssues) Flush(ctx context.Context, out transform.Writer) {
 				return fmt.Errorf("Flush did not get expected EOS code")
 			}
 			for _, res := range t.res {
-				res([]replay.Issue{}, nil)
+				res(t.issues, nil)
 			}
 			t.res = nil
 			return err
@@ -0,0 +1,202 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vulkan
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/replay"
+)
+
+const (
+	testCommandBuffer = VkCommandBuffer(1)
+	testImage         = VkImage(2)
+	testQueue         = VkQueue(3)
+	testEvent         = VkEvent(4)
+)
+
+func newTestFindIssues() *findIssues {
+	return &findIssues{
+		state:         gfxapi.NewStateWithEmptyAllocator(device.Little32),
+		commands:      map[atom.Atom]*recordedCommand{},
+		destroyed:     map[handle]atom.ID{},
+		updated:       map[VkDescriptorSet]bool{},
+		pendingWrites: map[handle]*recordedCommand{},
+		renderPasses:  map[VkCommandBuffer]renderPass{},
+	}
+}
+
+// run executes the recorded commands cmds in order, as a submission would.
+func (t *findIssues) run(ctx context.Context, cmds ...*recordedCommand) []replay.Issue {
+	for _, c := range cmds {
+		t.commands[c.a] = c
+		t.execute(ctx, c.a)()
+	}
+	return t.issues
+}
+
+func testRenderPass(deps ...VkSubpassDependency) *RenderPassObject {
+	rp := &RenderPassObject{
+		AttachmentDescriptions: U32ːVkAttachmentDescriptionᵐ{
+			0: VkAttachmentDescription{
+				LoadOp:        VkAttachmentLoadOp_VK_ATTACHMENT_LOAD_OP_LOAD,
+				InitialLayout: VkImageLayout_VK_IMAGE_LAYOUT_UNDEFINED,
+			},
+		},
+		SubpassDependencies: U32ːVkSubpassDependencyᵐ{},
+	}
+	for i, d := range deps {
+		rp.SubpassDependencies[uint32(i)] = d
+	}
+	return rp
+}
+
+func testFramebuffer() *FramebufferObject {
+	return &FramebufferObject{
+		ImageAttachments: U32ːImageViewObjectʳᵐ{
+			0: &ImageViewObject{Image: &ImageObject{VulkanHandle: testImage}},
+		},
+	}
+}
+
+// renderPassCommands returns the commands beginning and ending the render
+// pass rp, recorded by the atoms id and id+1.
+func (t *findIssues) renderPassCommands(id atom.ID, rp *RenderPassObject) (begin, end *recordedCommand) {
+	begin = &recordedCommand{id: id, a: &VkCmdBeginRenderPass{}}
+	t.beginRenderPass(begin, testCommandBuffer, rp, testFramebuffer())
+	end = &recordedCommand{id: id + 1, a: &VkCmdEndRenderPass{}}
+	t.endRenderPass(end, testCommandBuffer)
+	return begin, end
+}
+
+func TestExternalDependencies(t *testing.T) {
+	ctx := log.Testing(t)
+	for _, test := range []struct {
+		name     string
+		deps     []VkSubpassDependency
+		src, dst bool
+	}{
+		{"none", nil, false, false},
+		{"internal", []VkSubpassDependency{{SrcSubpass: 0, DstSubpass: 1}}, false, false},
+		{"src", []VkSubpassDependency{{SrcSubpass: subpassExternal, DstSubpass: 0}}, true, false},
+		{"dst", []VkSubpassDependency{{SrcSubpass: 0, DstSubpass: subpassExternal}}, false, true},
+		{"both", []VkSubpassDependency{
+			{SrcSubpass: subpassExternal, DstSubpass: 0},
+			{SrcSubpass: 0, DstSubpass: subpassExternal},
+		}, true, true},
+	} {
+		src, dst := externalDependencies(testRenderPass(test.deps...))
+		assert.For(ctx, "%s src", test.name).That(src).Equals(test.src)
+		assert.For(ctx, "%s dst", test.name).That(dst).Equals(test.dst)
+	}
+}
+
+func TestFindIssuesHazards(t *testing.T) {
+	ctx := log.Testing(t)
+	img := imageHandle(testImage)
+
+	// A write followed by a read without a barrier is reported against the
+	// atom that recorded the read.
+	fi := newTestFindIssues()
+	issues := fi.run(ctx,
+		&recordedCommand{id: 10, a: &VkCmdClearColorImage{}, writes: []handle{img}},
+		&recordedCommand{id: 11, a: &VkCmdCopyImage{}, reads: []handle{img}},
+	)
+	if assert.For(ctx, "write, read").ThatSlice(issues).IsLength(1) {
+		assert.For(ctx, "write, read atom").That(issues[0].Atom).Equals(atom.ID(11))
+	}
+
+	// A pipeline barrier between the write and the read synchronizes them.
+	fi = newTestFindIssues()
+	issues = fi.run(ctx,
+		&recordedCommand{id: 10, a: &VkCmdClearColorImage{}, writes: []handle{img}},
+		&recordedCommand{id: 11, a: &VkCmdPipelineBarrier{}, syncs: []handle{img}},
+		&recordedCommand{id: 12, a: &VkCmdCopyImage{}, reads: []handle{img}},
+	)
+	assert.For(ctx, "write, barrier, read").ThatSlice(issues).IsEmpty()
+
+	// A render pass loading an attachment written by an earlier render pass
+	// without any external dependency is a hazard.
+	fi = newTestFindIssues()
+	begin1, end1 := fi.renderPassCommands(10, testRenderPass())
+	begin2, end2 := fi.renderPassCommands(20, testRenderPass())
+	issues = fi.run(ctx, begin1, end1, begin2, end2)
+	if assert.For(ctx, "no dependencies").ThatSlice(issues).IsLength(1) {
+		assert.For(ctx, "no dependencies atom").That(issues[0].Atom).Equals(atom.ID(20))
+	}
+
+	// An external destination dependency of the earlier render pass
+	// synchronizes its writes with the later one.
+	fi = newTestFindIssues()
+	begin1, end1 = fi.renderPassCommands(10, testRenderPass(
+		VkSubpassDependency{SrcSubpass: 0, DstSubpass: subpassExternal}))
+	begin2, end2 = fi.renderPassCommands(20, testRenderPass())
+	issues = fi.run(ctx, begin1, end1, begin2, end2)
+	assert.For(ctx, "dst dependency").ThatSlice(issues).IsEmpty()
+
+	// An external source dependency of the later render pass synchronizes the
+	// writes of the earlier one before the load.
+	fi = newTestFindIssues()
+	begin1, end1 = fi.renderPassCommands(10, testRenderPass())
+	begin2, end2 = fi.renderPassCommands(20, testRenderPass(
+		VkSubpassDependency{SrcSubpass: subpassExternal, DstSubpass: 0}))
+	issues = fi.run(ctx, begin1, end1, begin2, end2)
+	assert.For(ctx, "src dependency").ThatSlice(issues).IsEmpty()
+}
+
+func TestFindIssuesDeferredCommands(t *testing.T) {
+	ctx := log.Testing(t)
+	img := imageHandle(testImage)
+
+	fi := newTestFindIssues()
+	st := GetState(fi.state)
+	queue := &QueueObject{
+		VulkanHandle:  testQueue,
+		PendingEvents: VkEventːEventObjectʳᵐ{testEvent: &EventObject{}},
+	}
+	st.Queues = VkQueueːQueueObjectʳᵐ{testQueue: queue}
+	st.LastBoundQueue = queue
+
+	// The queue waits on an event, so the submitted commands are deferred.
+	var write, read atom.Atom = &VkCmdClearColorImage{}, &VkCmdCopyImage{}
+	fi.commands[write] = &recordedCommand{id: 10, a: write, writes: []handle{img}}
+	fi.commands[read] = &recordedCommand{id: 11, a: read, reads: []handle{img}}
+	st.CommandBuffers = VkCommandBufferːCommandBufferObjectʳᵐ{
+		testCommandBuffer: &CommandBufferObject{Commands: CommandBufferCommands{
+			{func() {}, &write},
+			{func() {}, &read},
+		}},
+	}
+	e := externs{ctx: ctx, s: fi.state}
+	restore := fi.hook(ctx, testCommandBuffer)
+	e.execCommands(testCommandBuffer)
+	restore()
+	assert.For(ctx, "pending").ThatSlice(queue.PendingCommands).IsLength(2)
+	assert.For(ctx, "issues before signal").ThatSlice(fi.issues).IsEmpty()
+
+	// Signalling the event executes the deferred commands, which are checked
+	// even though the hooks have been removed.
+	delete(queue.PendingEvents, testEvent)
+	e.execPendingCommands(testQueue)
+	if assert.For(ctx, "issues after signal").ThatSlice(fi.issues).IsLength(1) {
+		assert.For(ctx, "issue atom").That(fi.issues[0].Atom).Equals(atom.ID(11))
+	}
+}
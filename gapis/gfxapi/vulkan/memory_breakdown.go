@@ -0,0 +1,213 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vulkan
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/gfxapi"
+)
+
+var memoryPropertyNames = []struct {
+	bit  VkMemoryPropertyFlagBits
+	name string
+}{
+	{VkMemoryPropertyFlagBits_VK_MEMORY_PROPERTY_DEVICE_LOCAL_BIT, "DEVICE_LOCAL"},
+	{VkMemoryPropertyFlagBits_VK_MEMORY_PROPERTY_HOST_VISIBLE_BIT, "HOST_VISIBLE"},
+	{VkMemoryPropertyFlagBits_VK_MEMORY_PROPERTY_HOST_COHERENT_BIT, "HOST_COHERENT"},
+	{VkMemoryPropertyFlagBits_VK_MEMORY_PROPERTY_HOST_CACHED_BIT, "HOST_CACHED"},
+	{VkMemoryPropertyFlagBits_VK_MEMORY_PROPERTY_LAZILY_ALLOCATED_BIT, "LAZILY_ALLOCATED"},
+}
+
+type heapKey struct {
+	device VkDevice
+	index  uint32
+}
+
+// MemoryBreakdown implements the gfxapi.MemoryBreakdownProvider interface.
+func (api) MemoryBreakdown(ctx context.Context, s *gfxapi.State) (*gfxapi.MemoryBreakdown, error) {
+	ctx = log.Enter(ctx, "MemoryBreakdown")
+	st := GetState(s)
+	if st == nil || len(st.DeviceMemories) == 0 {
+		return nil, nil
+	}
+
+	out := &gfxapi.MemoryBreakdown{}
+
+	heaps := map[heapKey]*gfxapi.MemoryHeap{}
+	for _, device := range st.Devices.KeysSorted() {
+		physicalDevice := st.PhysicalDevices.Get(st.Devices.Get(device).PhysicalDevice)
+		if physicalDevice == nil {
+			continue
+		}
+		props := physicalDevice.MemoryProperties
+		for i := uint32(0); i < props.MemoryHeapCount; i++ {
+			heap := props.MemoryHeaps[i]
+			h := &gfxapi.MemoryHeap{
+				Device: uint64(device),
+				Index:  i,
+				Size:   uint64(heap.Size),
+			}
+			if heap.Flags&VkMemoryHeapFlags(VkMemoryHeapFlagBits_VK_MEMORY_HEAP_DEVICE_LOCAL_BIT) != 0 {
+				h.Flags = append(h.Flags, "DEVICE_LOCAL")
+			}
+			heaps[heapKey{device, i}] = h
+			out.Heaps = append(out.Heaps, h)
+		}
+	}
+
+	// Gather the resources bound to each allocation.
+	bindings := map[VkDeviceMemory][]*gfxapi.MemoryBinding{}
+	for _, handle := range st.Buffers.KeysSorted() {
+		buffer := st.Buffers.Get(handle)
+		if buffer.Memory == nil {
+			continue
+		}
+		size := uint64(buffer.MemoryRequirements.Size)
+		if size == 0 {
+			// The application never queried the buffer's requirements.
+			size = uint64(buffer.Info.Size)
+		}
+		mem := buffer.Memory.VulkanHandle
+		bindings[mem] = append(bindings[mem], &gfxapi.MemoryBinding{
+			Name:   fmt.Sprintf("Buffer<0x%x>", uint64(handle)),
+			Handle: uint64(handle),
+			Offset: uint64(buffer.MemoryOffset),
+			Size:   size,
+		})
+	}
+	for _, handle := range st.Images.KeysSorted() {
+		image := st.Images.Get(handle)
+		if image.BoundMemory == nil || image.IsSwapchainImage {
+			continue
+		}
+		size := uint64(image.MemoryRequirements.Size)
+		if size == 0 {
+			// The application never queried the image's requirements, so
+			// estimate the size from the image's levels.
+			inferred, err := subInferImageSize(ctx, nil, nil, s, nil, nil, image)
+			if err != nil {
+				log.W(ctx, "Cannot infer the size of image %v: %v", handle, err)
+			}
+			size = uint64(inferred)
+		}
+		mem := image.BoundMemory.VulkanHandle
+		bindings[mem] = append(bindings[mem], &gfxapi.MemoryBinding{
+			Name:   fmt.Sprintf("Image<0x%x>", uint64(handle)),
+			Handle: uint64(handle),
+			Offset: uint64(image.BoundMemoryOffset),
+			Size:   size,
+		})
+	}
+
+	for _, handle := range st.DeviceMemories.KeysSorted() {
+		mem := st.DeviceMemories.Get(handle)
+		a := &gfxapi.MemoryAllocation{
+			Device:    uint64(mem.Device),
+			Handle:    uint64(handle),
+			TypeIndex: mem.MemoryTypeIndex,
+			Size:      uint64(mem.AllocationSize),
+			HeapIndex: gfxapi.UnknownHeapIndex,
+		}
+		if device := st.Devices.Get(mem.Device); device != nil {
+			if physicalDevice := st.PhysicalDevices.Get(device.PhysicalDevice); physicalDevice != nil {
+				props := physicalDevice.MemoryProperties
+				if mem.MemoryTypeIndex < props.MemoryTypeCount {
+					ty := props.MemoryTypes[mem.MemoryTypeIndex]
+					a.HeapIndex = ty.HeapIndex
+					for _, p := range memoryPropertyNames {
+						if ty.PropertyFlags&VkMemoryPropertyFlags(p.bit) != 0 {
+							a.Properties = append(a.Properties, p.name)
+						}
+					}
+				}
+			}
+		}
+		if mem.MappedSize != 0 {
+			offset, size := uint64(mem.MappedOffset), uint64(mem.MappedSize)
+			if offset+size > a.Size || offset+size < offset { // VK_WHOLE_SIZE
+				size = a.Size - offset
+			}
+			a.Mapped = &gfxapi.MemoryRange{Offset: offset, Size: size}
+		}
+
+		a.Bindings = bindings[handle]
+		var bound uint64
+		a.Unused, bound = layoutBindings(a.Bindings, a.Size)
+
+		h, ok := heaps[heapKey{mem.Device, a.HeapIndex}]
+		if !ok {
+			// The memory type or its heap is not known. Charge the allocation
+			// to the device's unknown heap rather than to a real heap.
+			a.HeapIndex = gfxapi.UnknownHeapIndex
+			key := heapKey{mem.Device, gfxapi.UnknownHeapIndex}
+			if h, ok = heaps[key]; !ok {
+				h = &gfxapi.MemoryHeap{Device: uint64(mem.Device), Index: gfxapi.UnknownHeapIndex}
+				heaps[key] = h
+				out.Heaps = append(out.Heaps, h)
+			}
+		}
+		h.AllocationCount++
+		h.Allocated += a.Size
+		h.Bound += bound
+		out.Allocations = append(out.Allocations, a)
+	}
+
+	return out, nil
+}
+
+// layoutBindings sorts the bindings of an allocation of the given size by
+// offset and fills in their aliases. layoutBindings returns the ranges of the
+// allocation that are not bound to any resource, and the number of bytes of
+// the allocation that are bound. Bytes bound past the end of the allocation
+// are not counted.
+func layoutBindings(bindings []*gfxapi.MemoryBinding, size uint64) ([]*gfxapi.MemoryRange, uint64) {
+	sort.SliceStable(bindings, func(i, j int) bool { return bindings[i].Offset < bindings[j].Offset })
+
+	for i, a := range bindings {
+		for j, b := range bindings {
+			if i != j && a.Offset < b.Offset+b.Size && b.Offset < a.Offset+a.Size {
+				a.Aliases = append(a.Aliases, uint32(j))
+			}
+		}
+	}
+
+	unused := []*gfxapi.MemoryRange{}
+	bound, end := uint64(0), uint64(0)
+	for _, b := range bindings {
+		start, e := b.Offset, size
+		if start > size {
+			start = size
+		}
+		if b.Size < size-start {
+			e = start + b.Size
+		}
+		if start > end {
+			unused = append(unused, &gfxapi.MemoryRange{Offset: end, Size: start - end})
+			end = start
+		}
+		if e > end {
+			bound += e - end
+			end = e
+		}
+	}
+	if end < size {
+		unused = append(unused, &gfxapi.MemoryRange{Offset: end, Size: size - end})
+	}
+	return unused, bound
+}
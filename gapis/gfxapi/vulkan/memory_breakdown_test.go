@@ -0,0 +1,103 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vulkan
+
+import (
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/gfxapi"
+)
+
+func TestLayoutBindings(t *testing.T) {
+	ctx := log.Testing(t)
+	type binding struct{ offset, size uint64 }
+	for _, test := range []struct {
+		name     string
+		size     uint64
+		bindings []binding
+		order    []uint64   // Offsets of the bindings once sorted.
+		aliases  [][]uint32 // Aliases of each sorted binding.
+		unused   []gfxapi.MemoryRange
+		bound    uint64
+	}{
+		{
+			name:   "empty",
+			size:   100,
+			unused: []gfxapi.MemoryRange{{Offset: 0, Size: 100}},
+		}, {
+			name:     "gaps",
+			size:     100,
+			bindings: []binding{{60, 20}, {10, 20}},
+			order:    []uint64{10, 60},
+			aliases:  [][]uint32{nil, nil},
+			unused: []gfxapi.MemoryRange{
+				{Offset: 0, Size: 10}, {Offset: 30, Size: 30}, {Offset: 80, Size: 20},
+			},
+			bound: 40,
+		}, {
+			name:     "aliased",
+			size:     100,
+			bindings: []binding{{0, 100}, {20, 10}, {50, 10}},
+			order:    []uint64{0, 20, 50},
+			aliases:  [][]uint32{{1, 2}, {0}, {0}},
+			unused:   []gfxapi.MemoryRange{},
+			bound:    100,
+		}, {
+			name:     "adjacent",
+			size:     100,
+			bindings: []binding{{0, 50}, {50, 50}},
+			order:    []uint64{0, 50},
+			aliases:  [][]uint32{nil, nil},
+			unused:   []gfxapi.MemoryRange{},
+			bound:    100,
+		}, {
+			name:     "overrun",
+			size:     100,
+			bindings: []binding{{80, 40}, {150, 10}},
+			order:    []uint64{80, 150},
+			aliases:  [][]uint32{nil, nil},
+			unused:   []gfxapi.MemoryRange{{Offset: 0, Size: 80}},
+			bound:    20,
+		}, {
+			name:     "huge",
+			size:     100,
+			bindings: []binding{{10, ^uint64(0)}},
+			order:    []uint64{10},
+			aliases:  [][]uint32{nil},
+			unused:   []gfxapi.MemoryRange{{Offset: 0, Size: 10}},
+			bound:    90,
+		},
+	} {
+		ctx := log.Enter(ctx, test.name)
+		bindings := make([]*gfxapi.MemoryBinding, len(test.bindings))
+		for i, b := range test.bindings {
+			bindings[i] = &gfxapi.MemoryBinding{Offset: b.offset, Size: b.size}
+		}
+		unused, bound := layoutBindings(bindings, test.size)
+
+		assert.For(ctx, "bound").That(bound).Equals(test.bound)
+		gotUnused := make([]gfxapi.MemoryRange, len(unused))
+		for i, r := range unused {
+			gotUnused[i] = *r
+		}
+		assert.For(ctx, "unused").ThatSlice(gotUnused).DeepEquals(test.unused)
+		for i, b := range bindings {
+			assert.For(ctx, "offset %d", i).That(b.Offset).Equals(test.order[i])
+			assert.For(ctx, "aliases %d", i).ThatSlice(b.Aliases).DeepEquals(test.aliases[i])
+		}
+	}
+}
@@ -36,6 +36,7 @@ var (
 	// Interface compliance tests
 	_ = replay.QueryIssues(api{})
 	_ = replay.QueryFramebufferAttachment(api{})
+	_ = replay.QuerySubcommandFramebufferAttachment(api{})
 	_ = replay.Support(api{})
 )
 
@@ -60,6 +61,10 @@ type makeAttachementReadable struct {
 // drawConfig is a replay.Config used by colorBufferRequest and
 // depthBufferRequests.
 type drawConfig struct {
+	// The command and sub-command that the replay is truncated at, if any.
+	// Requests for different sub-commands cannot share a replay.
+	subcommandOf atom.ID
+	subcommand   string
 }
 
 type imgRes struct {
@@ -70,6 +75,7 @@ type imgRes struct {
 // framebufferRequest requests a postback of a framebuffer's attachment.
 type framebufferRequest struct {
 	after            atom.ID
+	subcommand       []uint64
 	width, height    uint32
 	attachment       gfxapi.FramebufferAttachment
 	out              chan imgRes
@@ -488,11 +494,14 @@ func (a api) Replay(
 	// Terminate after all atoms of interest.
 	earlyTerminator := &transform.EarlyTerminator{}
 
+	// Truncates the submission holding the sub-command of interest.
+	var truncate *truncateSubmit
+
 	for _, rr := range rrs {
 		switch req := rr.Request.(type) {
 		case issuesRequest:
 			if issues == nil {
-				issues = &findIssues{}
+				issues = newFindIssues(ctx, capture)
 			}
 			issues.reportTo(rr.Result)
 
@@ -503,14 +512,22 @@ func (a api) Replay(
 				dceInfo.deadCodeElimination.Request(req.after)
 			}
 
+			res := rr.Result
+			if len(req.subcommand) > 0 {
+				if truncate == nil {
+					truncate = &truncateSubmit{after: req.after, subcommand: req.subcommand}
+				}
+				res = truncate.result(res)
+			}
+
 			switch req.attachment {
 			case gfxapi.FramebufferAttachment_Depth:
-				readFramebuffer.Depth(req.after, rr.Result)
+				readFramebuffer.Depth(req.after, res)
 			case gfxapi.FramebufferAttachment_Stencil:
 				return fmt.Errorf("Stencil attachments are not currently supported")
 			default:
 				idx := uint32(req.attachment - gfxapi.FramebufferAttachment_Color0)
-				readFramebuffer.Color(req.after, req.width, req.height, idx, rr.Result)
+				readFramebuffer.Color(req.after, req.width, req.height, idx, res)
 			}
 		}
 	}
@@ -527,6 +544,10 @@ func (a api) Replay(
 		transforms.Add(earlyTerminator)
 	}
 
+	if truncate != nil {
+		transforms.Add(truncate)
+	}
+
 	// Cleanup
 	transforms.Add(readFramebuffer, injector)
 	transforms.Add(&destroyResourcesAtEOS{})
@@ -579,6 +600,27 @@ func (a api) QueryFramebufferAttachment(
 	return res.(*image.Image2D), nil
 }
 
+func (a api) QuerySubcommandFramebufferAttachment(
+	ctx context.Context,
+	intent replay.Intent,
+	mgr *replay.Manager,
+	after atom.ID,
+	subcommand []uint64,
+	width, height uint32,
+	attachment gfxapi.FramebufferAttachment,
+	wireframeMode replay.WireframeMode,
+	hints *service.UsageHints) (*image.Image2D, error) {
+
+	c := drawConfig{subcommandOf: after, subcommand: fmt.Sprint(subcommand)}
+	out := make(chan imgRes, 1)
+	r := framebufferRequest{after: after, subcommand: subcommand, width: width, height: height, attachment: attachment, out: out}
+	res, err := mgr.Replay(ctx, intent, c, r, a, hints)
+	if err != nil {
+		return nil, err
+	}
+	return res.(*image.Image2D), nil
+}
+
 func (a api) QueryIssues(
 	ctx context.Context,
 	intent replay.Intent,
@@ -31,8 +31,9 @@ import (
 	"github.com/google/gapid/gapis/messages"
 	"github.com/google/gapid/gapis/resolve"
 	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/box"
 	"github.com/google/gapid/gapis/service/path"
-	"github.com/google/gapid/gapis/shadertools"
+	"github.com/google/gapid/gapis/shadertools/spirv"
 )
 
 func (t *ImageObject) IsResource() bool {
@@ -578,7 +579,10 @@ func (s *ShaderModuleObject) ResourceType(ctx context.Context) gfxapi.ResourceTy
 func (s *ShaderModuleObject) ResourceData(ctx context.Context, t *gfxapi.State) (*gfxapi.ResourceData, error) {
 	ctx = log.Enter(ctx, "ShaderModuleObject.ResourceData()")
 	words := s.Words.Read(ctx, nil, t, nil)
-	source := shadertools.DisassembleSpirvBinary(words)
+	source, err := spirv.Disassemble(words)
+	if err != nil {
+		return nil, log.Err(ctx, err, "Failed to disassemble the shader module")
+	}
 	return gfxapi.NewResourceData(&gfxapi.Shader{Type: gfxapi.ShaderType_Spirv, Source: source}), nil
 }
 
@@ -630,14 +634,26 @@ func (shader *ShaderModuleObject) SetResourceData(
 	return fmt.Errorf("No atom to set data in")
 }
 
+// assembleShaderModule assembles the edited SPIR-V source of the shader module
+// created with info, keeping the ids used by the original code.
+func assembleShaderModule(ctx context.Context, a atom.Atom, s *gfxapi.State, info VkShaderModuleCreateInfo, source string) ([]uint32, error) {
+	names := map[string]spirv.ID{}
+	original := info.PCode.Slice(0, uint64(info.CodeSize/4), s.MemoryLayout).Read(ctx, a, s, nil)
+	if m, err := spirv.Parse(original); err == nil {
+		names = m.Names()
+	}
+	return spirv.Assemble(source, names)
+}
+
 func (a *VkCreateShaderModule) Replace(ctx context.Context, c *capture.Capture, data *gfxapi.ResourceData) interface{} {
 	ctx = log.Enter(ctx, "VkCreateShaderModule.Replace()")
 	state := c.NewState()
 	a.Mutate(ctx, state, nil)
 
-	shader := data.GetShader()
-	codeSlice := shadertools.AssembleSpirvText(shader.Source)
-	if codeSlice == nil {
+	createInfo := a.PCreateInfo.Read(ctx, a, state, nil)
+	codeSlice, err := assembleShaderModule(ctx, a, state, createInfo, data.GetShader().Source)
+	if err != nil {
+		log.E(ctx, "Failed to assemble the shader module: %v", err)
 		return nil
 	}
 
@@ -646,7 +662,6 @@ func (a *VkCreateShaderModule) Replace(ctx context.Context, c *capture.Capture,
 	pAlloc := memory.Pointer(a.PAllocator)
 	pShaderModule := memory.Pointer(a.PShaderModule)
 	result := a.Result
-	createInfo := a.PCreateInfo.Read(ctx, a, state, nil)
 
 	createInfo.PCode = NewU32ᶜᵖ(code.Ptr())
 	createInfo.CodeSize = memory.Size(len(codeSlice) * 4)
@@ -684,16 +699,16 @@ func (a *RecreateShaderModule) Replace(ctx context.Context, c *capture.Capture,
 	state := c.NewState()
 	a.Mutate(ctx, state, nil)
 
-	shader := data.GetShader()
-	codeSlice := shadertools.AssembleSpirvText(shader.Source)
-	if codeSlice == nil {
+	createInfo := a.PCreateInfo.Read(ctx, a, state, nil)
+	codeSlice, err := assembleShaderModule(ctx, a, state, createInfo, data.GetShader().Source)
+	if err != nil {
+		log.E(ctx, "Failed to assemble the shader module: %v", err)
 		return nil
 	}
 
 	code := atom.Must(atom.AllocData(ctx, state, codeSlice))
 	device := a.Device
 	pShaderModule := memory.Pointer(a.PShaderModule)
-	createInfo := a.PCreateInfo.Read(ctx, a, state, nil)
 
 	createInfo.PCode = NewU32ᶜᵖ(code.Ptr())
 	createInfo.CodeSize = memory.Size(len(codeSlice) * 4)
@@ -725,3 +740,92 @@ func (a *RecreateShaderModule) Replace(ctx context.Context, c *capture.Capture,
 	}
 	return newAtom
 }
+
+// IsResource returns true if this instance should be considered as a resource.
+func (p *GraphicsPipelineObject) IsResource() bool {
+	return true
+}
+
+// ResourceHandle returns the UI identity for the resource.
+func (p *GraphicsPipelineObject) ResourceHandle() string {
+	return fmt.Sprintf("Pipeline<0x%x>", p.VulkanHandle)
+}
+
+// ResourceLabel returns an optional debug label for the resource.
+func (p *GraphicsPipelineObject) ResourceLabel() string {
+	return ""
+}
+
+// Order returns an integer used to sort the resources for presentation.
+func (p *GraphicsPipelineObject) Order() uint64 {
+	return uint64(p.VulkanHandle)
+}
+
+// ResourceType returns the type of this resource.
+func (p *GraphicsPipelineObject) ResourceType(ctx context.Context) gfxapi.ResourceType {
+	return gfxapi.ResourceType_PipelineResource
+}
+
+// ResourceData returns the resource data given the current state.
+// The pipeline object holds all the state of the create-info used to create
+// the pipeline, including the shader stages, layout and render pass.
+func (p *GraphicsPipelineObject) ResourceData(ctx context.Context, t *gfxapi.State) (*gfxapi.ResourceData, error) {
+	return gfxapi.NewResourceData(&gfxapi.Pipeline{CreateInfo: box.NewValue(p)}), nil
+}
+
+func (p *GraphicsPipelineObject) SetResourceData(ctx context.Context, at *path.Command,
+	data *gfxapi.ResourceData, resources gfxapi.ResourceMap, edits gfxapi.ReplaceCallback) error {
+	return fmt.Errorf("SetResourceData is not supported for GraphicsPipelineObject")
+}
+
+// IsResource returns true if this instance should be considered as a resource.
+func (p *ComputePipelineObject) IsResource() bool {
+	return true
+}
+
+// ResourceHandle returns the UI identity for the resource.
+func (p *ComputePipelineObject) ResourceHandle() string {
+	return fmt.Sprintf("Pipeline<0x%x>", p.VulkanHandle)
+}
+
+// ResourceLabel returns an optional debug label for the resource.
+func (p *ComputePipelineObject) ResourceLabel() string {
+	return ""
+}
+
+// Order returns an integer used to sort the resources for presentation.
+func (p *ComputePipelineObject) Order() uint64 {
+	return uint64(p.VulkanHandle)
+}
+
+// ResourceType returns the type of this resource.
+func (p *ComputePipelineObject) ResourceType(ctx context.Context) gfxapi.ResourceType {
+	return gfxapi.ResourceType_PipelineResource
+}
+
+// ResourceData returns the resource data given the current state.
+func (p *ComputePipelineObject) ResourceData(ctx context.Context, t *gfxapi.State) (*gfxapi.ResourceData, error) {
+	return gfxapi.NewResourceData(&gfxapi.Pipeline{CreateInfo: box.NewValue(p)}), nil
+}
+
+func (p *ComputePipelineObject) SetResourceData(ctx context.Context, at *path.Command,
+	data *gfxapi.ResourceData, resources gfxapi.ResourceMap, edits gfxapi.ReplaceCallback) error {
+	return fmt.Errorf("SetResourceData is not supported for ComputePipelineObject")
+}
+
+// accessBoundPipeline marks the pipeline used by the draw or dispatch a as
+// accessed, so that the pipeline resource lists the commands that used it.
+func accessBoundPipeline(s *gfxapi.State, a atom.Atom) {
+	st := GetState(s)
+	switch a.(type) {
+	case *VkCmdDraw, *VkCmdDrawIndexed, *VkCmdDrawIndirect, *VkCmdDrawIndexedIndirect,
+		*RecreateCmdDraw, *RecreateCmdDrawIndexed, *RecreateCmdDrawIndirect, *RecreateCmdDrawIndexedIndirect:
+		if p := st.LastDrawInfo.GraphicsPipeline; p != nil {
+			p.OnAccess(s)
+		}
+	case *VkCmdDispatch, *VkCmdDispatchIndirect, *RecreateCmdDispatch, *RecreateCmdDispatchIndirect:
+		if p := st.CurrentComputePipeline; p != nil {
+			p.OnAccess(s)
+		}
+	}
+}
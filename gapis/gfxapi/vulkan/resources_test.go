@@ -0,0 +1,56 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vulkan
+
+import (
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/gfxapi"
+)
+
+func TestPipelineResource(t *testing.T) {
+	ctx := log.Testing(t)
+	s := gfxapi.NewStateWithEmptyAllocator(device.Little32)
+
+	var current atom.Atom
+	created := []gfxapi.Resource{}
+	accesses := map[gfxapi.Resource][]atom.Atom{}
+	s.OnResourceCreated = func(r gfxapi.Resource) { created = append(created, r) }
+	s.OnResourceAccessed = func(r gfxapi.Resource) { accesses[r] = append(accesses[r], current) }
+
+	// Pipelines are created before their handle is assigned.
+	graphics := (&GraphicsPipelineObject{}).OnCreate(s)
+	graphics.VulkanHandle = 1
+	compute := (&ComputePipelineObject{}).OnCreate(s)
+	compute.VulkanHandle = 2
+	assert.For(ctx, "created").ThatSlice(created).Equals([]gfxapi.Resource{graphics, compute})
+
+	st := GetState(s)
+	st.LastDrawInfo.GraphicsPipeline = graphics
+	st.CurrentComputePipeline = compute
+
+	bind, draw, drawIndexed, copyImage, dispatch :=
+		&VkCmdBindPipeline{}, &VkCmdDraw{}, &VkCmdDrawIndexed{}, &VkCmdCopyImage{}, &VkCmdDispatch{}
+	for _, a := range []atom.Atom{bind, draw, copyImage, drawIndexed, dispatch} {
+		current = a
+		accessBoundPipeline(s, a)
+	}
+	assert.For(ctx, "graphics accesses").ThatSlice(accesses[graphics]).Equals([]atom.Atom{draw, drawIndexed})
+	assert.For(ctx, "compute accesses").ThatSlice(accesses[compute]).Equals([]atom.Atom{dispatch})
+}
@@ -0,0 +1,183 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vulkan
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/memory"
+	"github.com/google/gapid/gapis/resolve"
+)
+
+// The sub-commands of a vkQueueSubmit are indexed by the submit info, the
+// command buffer within the submit info and the command within the command
+// buffer. Commands executed by secondary command buffers are part of the
+// vkCmdExecuteCommands that executes them.
+
+// Subcommands implements the resolve.SubcommandProvider interface.
+// Each command buffer submitted by a vkQueueSubmit is grouped by its render
+// passes and then by its subpasses.
+func (api) Subcommands(ctx context.Context, a atom.Atom, s *gfxapi.State) (*resolve.SubcommandGroup, error) {
+	submit, ok := a.(*VkQueueSubmit)
+	if !ok {
+		return nil, a.Mutate(ctx, s, nil)
+	}
+
+	multipleInfos := submit.SubmitCount > 1
+
+	root := &resolve.SubcommandGroup{}
+	var info, buffer, pass, subpass *resolve.SubcommandGroup
+	var infoIdx, bufferIdx uint64
+
+	err := mutateSubmit(ctx, submit, s, func(idx resolve.SubcommandIndex, cb VkCommandBuffer, cmd CommandBufferCommand) {
+		if info == nil || idx[0] != infoIdx {
+			info, infoIdx, buffer = root, idx[0], nil
+			if multipleInfos {
+				info = root.AddGroup(fmt.Sprintf("Submit Info %d", idx[0]))
+			}
+		}
+		if buffer == nil || idx[1] != bufferIdx {
+			buffer, bufferIdx = info.AddGroup(fmt.Sprintf("Command Buffer 0x%x", uint64(cb))), idx[1]
+			pass, subpass = nil, nil
+		}
+
+		cmd.function()
+
+		st := GetState(s)
+		switch (*cmd.a).(type) {
+		case *VkCmdBeginRenderPass:
+			name := "Render Pass"
+			if rp := st.LastDrawInfo.RenderPass; rp != nil {
+				name = fmt.Sprintf("Render Pass 0x%x", uint64(rp.VulkanHandle))
+			}
+			pass = buffer.AddGroup(name)
+			pass.AddSubcommand(idx)
+			subpass = pass.AddGroup("Subpass 0")
+		case *VkCmdNextSubpass:
+			if pass == nil {
+				buffer.AddSubcommand(idx)
+				break
+			}
+			subpass = pass.AddGroup(fmt.Sprintf("Subpass %d", st.LastDrawInfo.LastSubpass))
+			subpass.AddSubcommand(idx)
+		case *VkCmdEndRenderPass:
+			if pass == nil {
+				buffer.AddSubcommand(idx)
+				break
+			}
+			pass.AddSubcommand(idx)
+			pass, subpass = nil, nil
+		default:
+			if subpass != nil {
+				subpass.AddSubcommand(idx)
+			} else {
+				buffer.AddSubcommand(idx)
+			}
+		}
+	})
+	return root, err
+}
+
+// MutateSubcommands implements the resolve.SubcommandProvider interface.
+func (api) MutateSubcommands(ctx context.Context, a atom.Atom, s *gfxapi.State, idx resolve.SubcommandIndex) (atom.Atom, error) {
+	submit, ok := a.(*VkQueueSubmit)
+	if !ok || len(idx) != 3 {
+		return nil, fmt.Errorf("%v has no sub-command %v", a.AtomName(), idx)
+	}
+
+	var found atom.Atom
+	err := mutateSubmit(ctx, submit, s, func(i resolve.SubcommandIndex, cb VkCommandBuffer, cmd CommandBufferCommand) {
+		if found != nil {
+			return // Skip all the commands after idx.
+		}
+		cmd.function()
+		if i.Equals(idx) {
+			found = *cmd.a
+		}
+	})
+	if err != nil && err == context.Canceled {
+		return nil, err
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%v has no sub-command %v", a.AtomName(), idx)
+	}
+	return found, nil
+}
+
+// MutateEachSubcommand implements the resolve.SubcommandProvider interface.
+// Each draw and dispatch also accesses the pipeline it uses.
+func (api) MutateEachSubcommand(ctx context.Context, a atom.Atom, s *gfxapi.State, f func(idx resolve.SubcommandIndex, mutate func())) error {
+	submit, ok := a.(*VkQueueSubmit)
+	if !ok {
+		return a.Mutate(ctx, s, nil)
+	}
+	return mutateSubmit(ctx, submit, s, func(idx resolve.SubcommandIndex, cb VkCommandBuffer, cmd CommandBufferCommand) {
+		f(idx, func() {
+			cmd.function()
+			accessBoundPipeline(s, *cmd.a)
+		})
+	})
+}
+
+// mutateSubmit mutates the state s by the queue submission a, calling f in
+// place of each of the commands executed from the submitted command buffers.
+// f is responsible for calling the command's function if the command is to be
+// executed.
+func mutateSubmit(ctx context.Context, a *VkQueueSubmit, s *gfxapi.State, f func(idx resolve.SubcommandIndex, cb VkCommandBuffer, cmd CommandBufferCommand)) error {
+	l := s.MemoryLayout
+	a.Extras().Observations().ApplyReads(s.Memory[memory.ApplicationPool])
+
+	// Commands deferred by pending events are executed by a later atom, after
+	// the hooks have been removed.
+	done := false
+	restores := []func(){}
+	defer func() {
+		done = true
+		for i := len(restores) - 1; i >= 0; i-- {
+			restores[i]()
+		}
+	}()
+
+	hooked := map[VkCommandBuffer]bool{}
+	for i, info := range a.PSubmits.Slice(0, uint64(a.SubmitCount), l).Read(ctx, a, s, nil) {
+		cbs := info.PCommandBuffers.Slice(0, uint64(info.CommandBufferCount), l).Read(ctx, a, s, nil)
+		for j, cb := range cbs {
+			o := GetState(s).CommandBuffers.Get(cb)
+			if o == nil || hooked[cb] {
+				continue
+			}
+			hooked[cb] = true
+			original := o.Commands
+			commands := make(CommandBufferCommands, len(original))
+			for k, cmd := range original {
+				idx, cb, cmd := resolve.SubcommandIndex{uint64(i), uint64(j), uint64(k)}, cb, cmd
+				commands[k] = CommandBufferCommand{func() {
+					if done {
+						cmd.function()
+					} else {
+						f(idx, cb, cmd)
+					}
+				}, cmd.a}
+			}
+			o.Commands = commands
+			restores = append(restores, func() { o.Commands = original })
+		}
+	}
+
+	return a.Mutate(ctx, s, nil)
+}
@@ -0,0 +1,216 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vulkan
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/atom/transform"
+	"github.com/google/gapid/gapis/memory"
+	"github.com/google/gapid/gapis/replay"
+)
+
+// truncateSubmit is an atom transform that replaces the vkQueueSubmit at the
+// atom after with a submission that only executes the commands up to and
+// including the sub-command subcommand. The commands of the command buffer
+// holding the sub-command are recorded into a new command buffer, and any
+// render pass left open is ended.
+type truncateSubmit struct {
+	after      atom.ID
+	subcommand []uint64
+	err        error // The error that prevented the truncation, if any.
+}
+
+// result returns a replay.Result that reports the truncation error, if any,
+// in place of the result passed to r.
+func (t *truncateSubmit) result(r replay.Result) replay.Result {
+	return func(val interface{}, err error) {
+		if t.err != nil {
+			r(nil, t.err)
+			return
+		}
+		r(val, err)
+	}
+}
+
+func (t *truncateSubmit) Transform(ctx context.Context, id atom.ID, a atom.Atom, out transform.Writer) {
+	submit, ok := a.(*VkQueueSubmit)
+	if id != t.after || !ok {
+		out.MutateAndWrite(ctx, id, a)
+		return
+	}
+	if t.err = t.truncate(ctx, id, submit, out); t.err != nil {
+		log.E(ctx, "Couldn't truncate %v at sub-command %v: %v", a.AtomName(), t.subcommand, t.err)
+		out.MutateAndWrite(ctx, id, a)
+	}
+}
+
+func (t *truncateSubmit) Flush(ctx context.Context, out transform.Writer) {}
+
+func (t *truncateSubmit) truncate(ctx context.Context, id atom.ID, a *VkQueueSubmit, out transform.Writer) error {
+	s := out.State()
+	l := s.MemoryLayout
+	st := GetState(s)
+	idx := t.subcommand
+	if len(idx) != 3 {
+		return fmt.Errorf("%v has no sub-command %v", a.AtomName(), idx)
+	}
+
+	a.Extras().Observations().ApplyReads(s.Memory[memory.ApplicationPool])
+	infos := a.PSubmits.Slice(0, uint64(a.SubmitCount), l).Read(ctx, a, s, nil)
+	if idx[0] >= uint64(len(infos)) {
+		return fmt.Errorf("%v has no sub-command %v", a.AtomName(), idx)
+	}
+	info := infos[idx[0]]
+	cbs := info.PCommandBuffers.Slice(0, uint64(info.CommandBufferCount), l).Read(ctx, a, s, nil)
+	if idx[1] >= uint64(len(cbs)) {
+		return fmt.Errorf("%v has no sub-command %v", a.AtomName(), idx)
+	}
+	cb := st.CommandBuffers.Get(cbs[idx[1]])
+	if cb == nil || idx[2] >= uint64(len(cb.Commands)) {
+		return fmt.Errorf("%v has no sub-command %v", a.AtomName(), idx)
+	}
+
+	// The commands up to the sub-command are recorded into a new command
+	// buffer, allocated from the pool of the truncated one.
+	newCb := VkCommandBuffer(newUnusedID(true, func(x uint64) bool { _, ok := st.CommandBuffers[VkCommandBuffer(x)]; return ok }))
+	recorded := make([]atom.Atom, idx[2]+1)
+	for i, cmd := range cb.Commands[:idx[2]+1] {
+		rec, err := rerecord(*cmd.a, newCb)
+		if err != nil {
+			return err
+		}
+		recorded[i] = rec
+	}
+
+	var allocated []atom.AllocResult
+	defer func() {
+		for _, d := range allocated {
+			d.Free()
+		}
+	}()
+	mustAllocData := func(v ...interface{}) atom.AllocResult {
+		res := atom.Must(atom.AllocData(ctx, s, v...))
+		allocated = append(allocated, res)
+		return res
+	}
+
+	allocateInfo := mustAllocData(VkCommandBufferAllocateInfo{
+		SType:              VkStructureType_VK_STRUCTURE_TYPE_COMMAND_BUFFER_ALLOCATE_INFO,
+		PNext:              NewVoidᶜᵖ(memory.Nullptr),
+		CommandPool:        cb.Pool,
+		Level:              VkCommandBufferLevel_VK_COMMAND_BUFFER_LEVEL_PRIMARY,
+		CommandBufferCount: 1,
+	})
+	newCbData := mustAllocData(newCb)
+	beginInfo := mustAllocData(VkCommandBufferBeginInfo{
+		SType:            VkStructureType_VK_STRUCTURE_TYPE_COMMAND_BUFFER_BEGIN_INFO,
+		PNext:            NewVoidᶜᵖ(memory.Nullptr),
+		Flags:            VkCommandBufferUsageFlags(VkCommandBufferUsageFlagBits_VK_COMMAND_BUFFER_USAGE_ONE_TIME_SUBMIT_BIT),
+		PInheritanceInfo: NewVkCommandBufferInheritanceInfoᶜᵖ(memory.Nullptr),
+	})
+	writeEach(ctx, out,
+		NewVkAllocateCommandBuffers(
+			cb.Device,
+			allocateInfo.Ptr(),
+			newCbData.Ptr(),
+			VkResult_VK_SUCCESS,
+		).AddRead(
+			allocateInfo.Data(),
+		).AddWrite(
+			newCbData.Data(),
+		),
+		NewVkBeginCommandBuffer(
+			newCb,
+			beginInfo.Ptr(),
+			VkResult_VK_SUCCESS,
+		).AddRead(
+			beginInfo.Data(),
+		),
+	)
+
+	// Record the commands up to the sub-command, keeping track of the render
+	// pass they end in.
+	inRenderPass, subpass, subpasses := false, 0, 0
+	for _, rec := range recorded {
+		out.MutateAndWrite(ctx, atom.NoID, rec)
+		switch rec := rec.(type) {
+		case *VkCmdBeginRenderPass:
+			inRenderPass, subpass, subpasses = true, 0, 1
+			begin := rec.PRenderPassBegin.Read(ctx, rec, s, nil)
+			if rp := st.RenderPasses.Get(begin.RenderPass); rp != nil {
+				subpasses = len(rp.SubpassDescriptions)
+			}
+		case *VkCmdNextSubpass:
+			subpass++
+		case *VkCmdEndRenderPass:
+			inRenderPass = false
+		}
+	}
+	if inRenderPass {
+		for ; subpass < subpasses-1; subpass++ {
+			writeEach(ctx, out, NewVkCmdNextSubpass(newCb, VkSubpassContents_VK_SUBPASS_CONTENTS_INLINE))
+		}
+		writeEach(ctx, out, NewVkCmdEndRenderPass(newCb))
+	}
+	writeEach(ctx, out, NewVkEndCommandBuffer(newCb, VkResult_VK_SUCCESS))
+
+	// Submit the command buffers before the truncated one, and the new one in
+	// its place.
+	cbs = append(cbs[:idx[1]:idx[1]], newCb)
+	cbsData := mustAllocData(cbs)
+	info.CommandBufferCount = uint32(len(cbs))
+	info.PCommandBuffers = NewVkCommandBufferᶜᵖ(cbsData.Ptr())
+	infos = append(infos[:idx[0]:idx[0]], info)
+	infosData := mustAllocData(infos)
+
+	truncated := NewVkQueueSubmit(
+		a.Queue,
+		uint32(len(infos)),
+		infosData.Ptr(),
+		a.Fence,
+		VkResult_VK_SUCCESS,
+	)
+	// The semaphores and wait stages of the submit infos are still read from
+	// the memory observed by the original submission.
+	if o := a.Extras().Observations(); o != nil {
+		observations := *o
+		truncated.Extras().Add(&observations)
+	}
+	truncated.AddRead(infosData.Data()).AddRead(cbsData.Data())
+	out.MutateAndWrite(ctx, id, truncated)
+	return nil
+}
+
+// rerecord returns a copy of the atom a, which recorded a command into a
+// command buffer, that records the command into cb instead.
+func rerecord(a atom.Atom, cb VkCommandBuffer) (atom.Atom, error) {
+	v := reflect.ValueOf(a)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Cannot re-record %v", a.AtomName())
+	}
+	c := reflect.New(v.Elem().Type())
+	c.Elem().Set(v.Elem())
+	f := c.Elem().FieldByName("CommandBuffer")
+	if !f.IsValid() || f.Type() != reflect.TypeOf(cb) {
+		return nil, fmt.Errorf("%v does not record into a command buffer", a.AtomName())
+	}
+	f.Set(reflect.ValueOf(cb))
+	return c.Interface().(atom.Atom), nil
+}
@@ -61,6 +61,23 @@ type QueryFramebufferAttachment interface {
 		hints *service.UsageHints) (*image.Image2D, error)
 }
 
+// QuerySubcommandFramebufferAttachment is the interface implemented by types
+// that can return the content of a framebuffer attachment after a sub-command
+// of a command in a capture, such as a draw recorded into a command buffer
+// that is executed by a queue submission.
+type QuerySubcommandFramebufferAttachment interface {
+	QuerySubcommandFramebufferAttachment(
+		ctx context.Context,
+		intent Intent,
+		mgr *Manager,
+		after atom.ID,
+		subcommand []uint64,
+		width, height uint32,
+		attachment gfxapi.FramebufferAttachment,
+		wireframeMode WireframeMode,
+		hints *service.UsageHints) (*image.Image2D, error)
+}
+
 // Issue represents a single replay issue reported by QueryIssues.
 type Issue struct {
 	Atom     atom.ID          // The atom that reported the issue.
@@ -37,6 +37,7 @@ set(files
     get_set_test.go
     index_limits.go
     memory.go
+    memory_breakdown.go
     mesh.go
     report.go
     requests_test.go
@@ -46,11 +47,16 @@ set(files
     resource_data.go
     resource_meta.go
     resources.go
+    resources_test.go
     set.go
     service.go
     state.go
     state_tree_test.go
     state_tree.go
+    subcommands.go
+    subcommands_test.go
+    threads.go
+    threads_test.go
     thumbnail.go
 )
 set(dirs
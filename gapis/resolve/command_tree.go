@@ -23,6 +23,7 @@ import (
 	"github.com/google/gapid/gapis/capture"
 	"github.com/google/gapid/gapis/database"
 	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/messages"
 	"github.com/google/gapid/gapis/service"
 	"github.com/google/gapid/gapis/service/path"
 )
@@ -39,16 +40,42 @@ func CommandTree(ctx context.Context, c *path.CommandTree) (*service.CommandTree
 }
 
 type commandTree struct {
-	path *path.CommandTree
-	root atom.Group
+	path        *path.CommandTree
+	root        atom.Group
+	subcommands map[atom.ID]*SubcommandGroup
 }
 
-func (t *commandTree) index(indices []uint64) atom.GroupOrID {
+// subcommand is a sub-command group or index of the atom id.
+type subcommand struct {
+	id   atom.ID
+	node SubcommandNode
+}
+
+// commands returns the path to the range of commands represented by the
+// sub-command node.
+func (s subcommand) commands(c *path.Capture) *path.Commands {
+	first, last := SubcommandIndex(nil), SubcommandIndex(nil)
+	switch n := s.node.(type) {
+	case SubcommandIndex:
+		first, last = n, n
+	case *SubcommandGroup:
+		first, last = n.First(), n.Last()
+	}
+	return &path.Commands{
+		Capture: c,
+		From:    append([]uint64{uint64(s.id)}, first...),
+		To:      append([]uint64{uint64(s.id)}, last...),
+	}
+}
+
+func (t *commandTree) index(indices []uint64) interface{} {
 	group := t.root
-	for _, idx := range indices {
+	for i, idx := range indices {
 		switch item := group.Index(idx).(type) {
 		case atom.Group:
 			group = item
+		case atom.ID:
+			return t.subcommand(item, indices[i+1:])
 		default:
 			return item
 		}
@@ -56,7 +83,34 @@ func (t *commandTree) index(indices []uint64) atom.GroupOrID {
 	return group
 }
 
-func (t *commandTree) indices(id atom.ID) []uint64 {
+// subcommand returns the node of the sub-command tree of the atom id at the
+// given child indices, or id if there are no indices.
+func (t *commandTree) subcommand(id atom.ID, indices []uint64) interface{} {
+	if len(indices) == 0 {
+		return id
+	}
+	group := t.subcommands[id]
+	if group == nil {
+		return nil
+	}
+	for i, idx := range indices {
+		if idx >= uint64(len(group.Children)) {
+			return nil
+		}
+		switch item := group.Children[idx].(type) {
+		case *SubcommandGroup:
+			group = item
+		case SubcommandIndex:
+			if i != len(indices)-1 {
+				return nil
+			}
+			return subcommand{id, item}
+		}
+	}
+	return subcommand{id, group}
+}
+
+func (t *commandTree) indices(id atom.ID, sub SubcommandIndex) []uint64 {
 	out := []uint64{}
 	group := t.root
 	for {
@@ -66,6 +120,11 @@ func (t *commandTree) indices(id atom.ID) []uint64 {
 		case atom.Group:
 			group = item
 		default:
+			if len(sub) > 0 {
+				if g := t.subcommands[id]; g != nil {
+					out = append(out, g.IndicesOf(sub)...)
+				}
+			}
 			return out
 		}
 	}
@@ -80,10 +139,15 @@ func CommandTreeNode(ctx context.Context, c *path.CommandTreeNode) (*service.Com
 
 	cmdTree := boxed.(*commandTree)
 
-	switch item := cmdTree.index(c.Indices).(type) {
+	node := cmdTree.index(c.Indices)
+	switch item := node.(type) {
 	case atom.ID:
+		numChildren := uint64(0)
+		if g := cmdTree.subcommands[item]; g != nil {
+			numChildren = uint64(len(g.Children))
+		}
 		return &service.CommandTreeNode{
-			NumChildren: 0, // TODO: Subcommands
+			NumChildren: numChildren,
 			Commands:    cmdTree.path.Capture.CommandRange(uint64(item), uint64(item)),
 		}, nil
 	case atom.Group:
@@ -93,9 +157,27 @@ func CommandTreeNode(ctx context.Context, c *path.CommandTreeNode) (*service.Com
 			Group:       item.Name,
 			NumCommands: item.DeepCount(func(g atom.Group) bool { return true /* TODO: Subcommands */ }),
 		}, nil
-	default:
-		panic(fmt.Errorf("Unexpected type: %T", item))
+	case subcommand:
+		switch node := item.node.(type) {
+		case SubcommandIndex:
+			return &service.CommandTreeNode{
+				Commands: item.commands(cmdTree.path.Capture),
+			}, nil
+		case *SubcommandGroup:
+			return &service.CommandTreeNode{
+				NumChildren: uint64(len(node.Children)),
+				Commands:    item.commands(cmdTree.path.Capture),
+				Group:       node.Name,
+				NumCommands: node.Count(),
+			}, nil
+		}
+	case nil:
+		return nil, &service.ErrInvalidPath{
+			Reason: messages.ErrCommandTreeNodeDoesNotExist(),
+			Path:   c.Path(),
+		}
 	}
+	panic(fmt.Errorf("Unexpected type: %T", node))
 }
 
 // CommandTreeNodeForCommand returns the path to the CommandTreeNode that
@@ -109,13 +191,10 @@ func CommandTreeNodeForCommand(ctx context.Context, p *path.CommandTreeNodeForCo
 	cmdTree := boxed.(*commandTree)
 
 	atomIdx := p.Command.Indices[0]
-	if len(p.Command.Indices) > 1 {
-		return nil, fmt.Errorf("Subcommands currently not supported") // TODO: Subcommands
-	}
 
 	return &path.CommandTreeNode{
 		Tree:    p.Tree,
-		Indices: cmdTree.indices(atom.ID(atomIdx)),
+		Indices: cmdTree.indices(atom.ID(atomIdx), SubcommandIndex(p.Command.Indices[1:])),
 	}, nil
 }
 
@@ -245,10 +324,7 @@ func (r *CommandTreeResolvable) Resolve(ctx context.Context) (interface{}, error
 	}
 
 	if p.GroupByThread {
-		// TODO: Threads
-		// groupers = append(groupers, &runGrouper{f: func(a atom.Atom, s *gfxapi.State) (interface{}, string) {
-		// 	return s.Thread, fmt.Sprintf("Thread: 0x%x", s.Thread)
-		// }})
+		groupers = append(groupers, threadGrouper())
 	}
 
 	if p.GroupByUserMarkers {
@@ -256,9 +332,18 @@ func (r *CommandTreeResolvable) Resolve(ctx context.Context) (interface{}, error
 	}
 
 	// Walk the list of unfiltered atoms to build the groups.
+	subcommands := map[atom.ID]*SubcommandGroup{}
 	s := c.NewState()
 	for i, a := range c.Atoms {
-		if err := a.Mutate(ctx, s, nil); err != nil && err == context.Canceled {
+		if provider, ok := a.API().(SubcommandProvider); ok && p.GroupBySubmission {
+			g, err := provider.Subcommands(ctx, a, s)
+			if err != nil && err == context.Canceled {
+				return nil, err
+			}
+			if g != nil && len(g.Children) > 0 {
+				subcommands[atom.ID(i)] = g
+			}
+		} else if err := a.Mutate(ctx, s, nil); err != nil && err == context.Canceled {
 			return nil, err
 		}
 		if filter(a, s) {
@@ -278,6 +363,7 @@ func (r *CommandTreeResolvable) Resolve(ctx context.Context) (interface{}, error
 			Name:  "root",
 			Range: atom.Range{End: atom.ID(len(c.Atoms))},
 		},
+		subcommands: subcommands,
 	}
 	for _, g := range groupers {
 		for _, l := range g.groups() {
@@ -19,7 +19,9 @@ import (
 	"fmt"
 
 	"github.com/google/gapid/core/math/u64"
+	"github.com/google/gapid/gapis/atom"
 	"github.com/google/gapid/gapis/capture"
+	"github.com/google/gapid/gapis/gfxapi"
 	"github.com/google/gapid/gapis/service"
 	"github.com/google/gapid/gapis/service/path"
 )
@@ -31,19 +33,72 @@ func Commands(ctx context.Context, p *path.Commands) (*service.Commands, error)
 		return nil, err
 	}
 	atomIdxFrom, atomIdxTo := p.From[0], p.To[0]
-	if len(p.From) > 1 || len(p.To) > 1 {
-		return nil, fmt.Errorf("Subcommands currently not supported") // TODO: Subcommands
-	}
+	subFrom, subTo := SubcommandIndex(p.From[1:]), SubcommandIndex(p.To[1:])
 	count := uint64(len(c.Atoms))
 	atomIdxFrom = u64.Min(atomIdxFrom, count-1)
 	atomIdxTo = u64.Min(atomIdxTo, count-1)
-	if atomIdxFrom > atomIdxTo {
+	if atomIdxFrom > atomIdxTo || (atomIdxFrom == atomIdxTo && subTo.LessThan(subFrom)) {
 		atomIdxFrom, atomIdxTo = atomIdxTo, atomIdxFrom
+		subFrom, subTo = subTo, subFrom
+	}
+	if len(subFrom) > 0 || len(subTo) > 0 {
+		ctx = capture.Put(ctx, p.Capture)
+		paths, err := subcommandPaths(ctx, c.NewState(), c.Atoms, p.Capture,
+			atomIdxFrom, subFrom, atomIdxTo, subTo)
+		if err != nil {
+			return nil, err
+		}
+		return &service.Commands{List: paths}, nil
 	}
 	count = atomIdxTo - atomIdxFrom
 	paths := make([]*path.Command, count)
 	for i := uint64(0); i < count; i++ {
-		paths[i] = p.Capture.Command(i)
+		paths[i] = p.Capture.Command(atomIdxFrom + i)
 	}
 	return &service.Commands{List: paths}, nil
 }
+
+// subcommandPaths returns the paths of the commands from the command
+// [from, subFrom...] up to, but not including, the command [to, subTo...].
+// The commands at from and to are replaced by their sub-commands in that range
+// if subFrom and subTo are not empty, respectively. The state s is mutated by
+// the atoms up to to.
+func subcommandPaths(ctx context.Context, s *gfxapi.State, atoms []atom.Atom, p *path.Capture,
+	from uint64, subFrom SubcommandIndex, to uint64, subTo SubcommandIndex) ([]*path.Command, error) {
+
+	paths := []*path.Command{}
+	for i := uint64(0); i <= to; i++ {
+		a := atoms[i]
+		expand := (i == from && len(subFrom) > 0) || (i == to && len(subTo) > 0)
+		if !expand {
+			if i == to {
+				break
+			}
+			if i >= from {
+				paths = append(paths, p.Command(i))
+			}
+			if err := a.Mutate(ctx, s, nil); err != nil && err == context.Canceled {
+				return nil, err
+			}
+			continue
+		}
+		provider, ok := a.API().(SubcommandProvider)
+		if !ok {
+			return nil, fmt.Errorf("Command %v has no sub-commands", a.AtomName())
+		}
+		err := provider.MutateEachSubcommand(ctx, a, s, func(idx SubcommandIndex, mutate func()) {
+			mutate()
+			if i == from && idx.LessThan(subFrom) {
+				return
+			}
+			if i == to && !idx.LessThan(subTo) {
+				return
+			}
+			paths = append(paths, p.Command(i, idx...))
+		})
+		if err != nil && err == context.Canceled {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
@@ -47,7 +47,11 @@ func buildFilter(ctx context.Context, p *path.Capture, f *path.CommandFilter) (f
 		})
 	}
 	if t := f.GetThread(); t.IsValid() {
-		// TODO: Thread filter.
+		t, err := Thread(ctx, p.Thread(t))
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, threadFilter(t.Id))
 	}
 	return func(a atom.Atom, s *gfxapi.State) bool {
 		for _, f := range filters {
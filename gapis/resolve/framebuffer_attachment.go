@@ -21,6 +21,8 @@ import (
 
 	"github.com/google/gapid/core/image"
 	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/capture"
 	"github.com/google/gapid/gapis/database"
 	"github.com/google/gapid/gapis/gfxapi"
 	"github.com/google/gapid/gapis/messages"
@@ -69,14 +71,14 @@ func FramebufferAttachment(
 // will trigger a computation for all atoms of this capture, which will be
 // cached to the database for subsequent calls, regardless of the given atom.
 func FramebufferAttachmentInfo(ctx context.Context, after *path.Command, att gfxapi.FramebufferAttachment) (framebufferAttachmentInfo, error) {
+	if len(after.Indices) > 1 {
+		return subcommandFramebufferAttachmentInfo(ctx, after, att)
+	}
 	changes, err := FramebufferChanges(ctx, path.FindCapture(after))
 	if err != nil {
 		return framebufferAttachmentInfo{}, err
 	}
 	atomIdx := after.Indices[0]
-	if len(after.Indices) > 1 {
-		return framebufferAttachmentInfo{}, fmt.Errorf("Subcommands currently not supported") // TODO: Subcommands
-	}
 	info, err := changes.attachments[att].after(ctx, atomIdx)
 	if err != nil {
 		return framebufferAttachmentInfo{}, err
@@ -87,6 +89,41 @@ func FramebufferAttachmentInfo(ctx context.Context, after *path.Command, att gfx
 	return info, nil
 }
 
+// subcommandFramebufferAttachmentInfo returns the framebuffer dimensions and
+// format after the sub-command after. The framebuffer changes are only
+// recorded for atoms, so the state is mutated up to the sub-command instead.
+func subcommandFramebufferAttachmentInfo(ctx context.Context, after *path.Command, att gfxapi.FramebufferAttachment) (framebufferAttachmentInfo, error) {
+	ctx = capture.Put(ctx, after.Capture)
+	list, err := NAtoms(ctx, after.Capture, after.Indices[0]+1)
+	if err != nil {
+		return framebufferAttachmentInfo{}, err
+	}
+	s, err := capture.NewState(ctx)
+	if err != nil {
+		return framebufferAttachmentInfo{}, err
+	}
+	return framebufferAttachmentInfoAfter(ctx, s, list.Atoms, after, att)
+}
+
+// framebufferAttachmentInfoAfter mutates the state s by the atoms up to and
+// including the command or sub-command after, and returns the dimensions and
+// format of the framebuffer attachment att.
+func framebufferAttachmentInfoAfter(ctx context.Context, s *gfxapi.State, atoms []atom.Atom, after *path.Command, att gfxapi.FramebufferAttachment) (framebufferAttachmentInfo, error) {
+	a, err := mutateUntil(ctx, s, atoms, after)
+	if err != nil {
+		return framebufferAttachmentInfo{}, err
+	}
+	api := a.API()
+	if api == nil {
+		return framebufferAttachmentInfo{}, &service.ErrDataUnavailable{Reason: messages.ErrFramebufferUnavailable()}
+	}
+	w, h, f, err := api.GetFramebufferAttachmentInfo(s, att)
+	if err != nil || f == nil {
+		return framebufferAttachmentInfo{}, &service.ErrDataUnavailable{Reason: messages.ErrFramebufferUnavailable()}
+	}
+	return framebufferAttachmentInfo{after: after.Indices[0], width: w, height: h, format: f, valid: true}, nil
+}
+
 // Resolve implements the database.Resolver interface.
 func (r *FramebufferAttachmentResolvable) Resolve(ctx context.Context) (interface{}, error) {
 	fbInfo, err := FramebufferAttachmentInfo(ctx, r.After, r.Attachment)
@@ -16,8 +16,8 @@ package resolve
 
 import (
 	"context"
-	"fmt"
 
+	"github.com/google/gapid/core/image"
 	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/gapis/atom"
 	"github.com/google/gapid/gapis/messages"
@@ -39,21 +39,12 @@ func (r *FramebufferAttachmentDataResolvable) Resolve(ctx context.Context) (inte
 	}
 
 	atomIdx := r.After.Indices[0]
-	if len(r.After.Indices) > 1 {
-		return nil, fmt.Errorf("Subcommands currently not supported") // TODO: Subcommands
-	}
 
 	api := after.API()
 	if api == nil {
 		return nil, &service.ErrDataUnavailable{Reason: messages.ErrFramebufferUnavailable()}
 	}
 
-	query, ok := api.(replay.QueryFramebufferAttachment)
-	if !ok {
-		log.E(ctx, "API %s does not implement FramebufferAttachmentDataResolvable", api.Name())
-		return nil, &service.ErrDataUnavailable{Reason: messages.ErrFramebufferUnavailable()}
-	}
-
 	wireframeMode := replay.WireframeMode_None
 	switch r.WireframeMode {
 	case service.WireframeMode_None:
@@ -67,17 +58,43 @@ func (r *FramebufferAttachmentDataResolvable) Resolve(ctx context.Context) (inte
 
 	mgr := replay.GetManager(ctx)
 
-	res, err := query.QueryFramebufferAttachment(
-		ctx,
-		intent,
-		mgr,
-		atom.ID(atomIdx),
-		r.Width,
-		r.Height,
-		r.Attachment,
-		wireframeMode,
-		r.Hints,
-	)
+	var res *image.Image2D
+	if sub := r.After.Indices[1:]; len(sub) > 0 {
+		query, ok := api.(replay.QuerySubcommandFramebufferAttachment)
+		if !ok {
+			log.E(ctx, "API %s does not implement QuerySubcommandFramebufferAttachment", api.Name())
+			return nil, &service.ErrDataUnavailable{Reason: messages.ErrFramebufferUnavailable()}
+		}
+		res, err = query.QuerySubcommandFramebufferAttachment(
+			ctx,
+			intent,
+			mgr,
+			atom.ID(atomIdx),
+			sub,
+			r.Width,
+			r.Height,
+			r.Attachment,
+			wireframeMode,
+			r.Hints,
+		)
+	} else {
+		query, ok := api.(replay.QueryFramebufferAttachment)
+		if !ok {
+			log.E(ctx, "API %s does not implement FramebufferAttachmentDataResolvable", api.Name())
+			return nil, &service.ErrDataUnavailable{Reason: messages.ErrFramebufferUnavailable()}
+		}
+		res, err = query.QueryFramebufferAttachment(
+			ctx,
+			intent,
+			mgr,
+			atom.ID(atomIdx),
+			r.Width,
+			r.Height,
+			r.Attachment,
+			wireframeMode,
+			r.Hints,
+		)
+	}
 	if err != nil {
 		if _, ok := err.(*service.ErrDataUnavailable); ok {
 			return nil, err
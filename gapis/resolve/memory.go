@@ -30,9 +30,6 @@ func Memory(ctx context.Context, p *path.Memory) (*service.Memory, error) {
 	ctx = capture.Put(ctx, path.FindCapture(p))
 
 	atomIdx := p.After.Indices[0]
-	if len(p.After.Indices) > 1 {
-		return nil, fmt.Errorf("Subcommands currently not supported") // TODO: Subcommands
-	}
 
 	list, err := NAtoms(ctx, p.After.Capture, atomIdx+1)
 	if err != nil {
@@ -57,17 +54,27 @@ func Memory(ctx context.Context, p *path.Memory) (*service.Memory, error) {
 	r := memory.Range{Base: p.Address, Size: p.Size}
 
 	var reads, writes, observed memory.RangeList
+	observing := false
 	pool.OnRead = func(rng memory.Range) {
-		if rng.Overlaps(r) {
+		if observing && rng.Overlaps(r) {
 			interval.Merge(&reads, rng.Window(r).Span(), false)
 		}
 	}
 	pool.OnWrite = func(rng memory.Range) {
-		if rng.Overlaps(r) {
+		if observing && rng.Overlaps(r) {
 			interval.Merge(&writes, rng.Window(r).Span(), false)
 		}
 	}
-	list.Atoms[atomIdx].Mutate(ctx, s, nil /* no builder, just mutate */)
+	// Only the reads and writes of the sub-command are reported if the path
+	// refers to one, not those of the earlier sub-commands.
+	err = mutateAround(ctx, s, list.Atoms[atomIdx], p.After.Indices[1:], func(mutate func()) {
+		observing = true
+		mutate()
+		observing = false
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	slice := pool.Slice(r)
 
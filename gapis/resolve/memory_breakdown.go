@@ -0,0 +1,69 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolve
+
+import (
+	"context"
+
+	"github.com/google/gapid/gapis/capture"
+	"github.com/google/gapid/gapis/database"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/messages"
+	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+// MemoryBreakdown resolves the device memory allocations at the point in the
+// capture specified by p.
+func MemoryBreakdown(ctx context.Context, p *path.MemoryBreakdown) (*gfxapi.MemoryBreakdown, error) {
+	obj, err := database.Build(ctx, &MemoryBreakdownResolvable{p})
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*gfxapi.MemoryBreakdown), nil
+}
+
+// Resolve implements the database.Resolver interface.
+func (r *MemoryBreakdownResolvable) Resolve(ctx context.Context) (interface{}, error) {
+	ctx = capture.Put(ctx, r.Path.After.Capture)
+	s, err := GlobalState(ctx, r.Path.After.StateAfter())
+	if err != nil {
+		return nil, err
+	}
+
+	var out *gfxapi.MemoryBreakdown
+	for api := range s.APIs {
+		provider, ok := api.(gfxapi.MemoryBreakdownProvider)
+		if !ok {
+			continue
+		}
+		b, err := provider.MemoryBreakdown(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+		if b == nil {
+			continue
+		}
+		if out == nil {
+			out = &gfxapi.MemoryBreakdown{}
+		}
+		out.Heaps = append(out.Heaps, b.Heaps...)
+		out.Allocations = append(out.Allocations, b.Allocations...)
+	}
+	if out == nil {
+		return nil, &service.ErrDataUnavailable{Reason: messages.ErrMemoryBreakdownNotAvailable()}
+	}
+	return out, nil
+}
@@ -292,6 +292,8 @@ func ResolveInternal(ctx context.Context, p path.Node) (interface{}, error) {
 		return MapIndex(ctx, p)
 	case *path.Memory:
 		return Memory(ctx, p)
+	case *path.MemoryBreakdown:
+		return MemoryBreakdown(ctx, p)
 	case *path.Mesh:
 		return Mesh(ctx, p)
 	case *path.Parameter:
@@ -314,6 +316,10 @@ func ResolveInternal(ctx context.Context, p path.Node) (interface{}, error) {
 		return StateTreeNode(ctx, p)
 	case *path.StateTreeNodeForPath:
 		return StateTreeNodeForPath(ctx, p)
+	case *path.Thread:
+		return Thread(ctx, p)
+	case *path.Threads:
+		return Threads(ctx, p)
 	case *path.Thumbnail:
 		return Thumbnail(ctx, p)
 	default:
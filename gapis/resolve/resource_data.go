@@ -47,9 +47,6 @@ func (r *AllResourceDataResolvable) Resolve(ctx context.Context) (interface{}, e
 
 func buildResources(ctx context.Context, p *path.Command) (*ResolvedResources, error) {
 	atomIdx := p.Indices[0]
-	if len(p.Indices) > 1 {
-		return nil, fmt.Errorf("Subcommands currently not supported") // TODO: Subcommands
-	}
 
 	list, err := NAtoms(ctx, p.Capture, atomIdx+1)
 	if err != nil {
@@ -72,13 +69,18 @@ func buildResources(ctx context.Context, p *path.Command) (*ResolvedResources, e
 		resources[i] = r
 	}
 
-	for i, a := range list.Atoms[:atomIdx+1] {
+	for i, a := range list.Atoms[:atomIdx] {
 		currentAtomResourceCount = 0
 		currentAtomIndex = uint64(i)
 		if err := a.Mutate(ctx, state, nil); err != nil && err == context.Canceled {
 			return nil, err
 		}
 	}
+	currentAtomResourceCount = 0
+	currentAtomIndex = atomIdx
+	if _, err := mutateCommand(ctx, state, list.Atoms[atomIdx], p.Indices[1:]); err != nil {
+		return nil, err
+	}
 
 	resourceData := make(map[id.ID]interface{})
 	for k, v := range resources {
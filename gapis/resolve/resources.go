@@ -48,8 +48,15 @@ func (r *ResourcesResolvable) Resolve(ctx context.Context) (interface{}, error)
 	seen := map[gfxapi.Resource]int{}
 
 	var currentAtomIndex uint64
+	var currentSubcommand SubcommandIndex
 	var currentAtomResourceCount int
 
+	// current returns the command indices of the access of r by the atom or
+	// sub-command currently being mutated.
+	current := func(r gfxapi.Resource) []uint64 {
+		return accessedBy(ctx, r, currentAtomIndex, currentSubcommand)
+	}
+
 	state := c.NewState()
 	state.OnResourceCreated = func(r gfxapi.Resource) {
 		currentAtomResourceCount++
@@ -57,21 +64,32 @@ func (r *ResourcesResolvable) Resolve(ctx context.Context) (interface{}, error)
 		resources = append(resources, trackedResource{
 			resource: r,
 			id:       genResourceID(currentAtomIndex, currentAtomResourceCount),
-			accesses: []uint64{currentAtomIndex},
+			accesses: [][]uint64{current(r)},
 		})
 	}
 	state.OnResourceAccessed = func(r gfxapi.Resource) {
 		if index, ok := seen[r]; ok { // Update the list of accesses
 			c := len(resources[index].accesses)
-			if c == 0 || resources[index].accesses[c-1] != currentAtomIndex {
-				resources[index].accesses = append(resources[index].accesses, currentAtomIndex)
+			at := current(r)
+			if c == 0 || !SubcommandIndex(resources[index].accesses[c-1]).Equals(at) {
+				resources[index].accesses = append(resources[index].accesses, at)
 			}
 		}
 	}
 	for i, a := range c.Atoms {
 		currentAtomResourceCount = 0
 		currentAtomIndex = uint64(i)
-		a.Mutate(ctx, state, nil /* no builder, just mutate */)
+		if provider, ok := a.API().(SubcommandProvider); ok {
+			// Pipelines accessed by sub-commands are attributed to the
+			// sub-command. See accessedBy.
+			provider.MutateEachSubcommand(ctx, a, state, func(idx SubcommandIndex, mutate func()) {
+				currentSubcommand = idx
+				mutate()
+				currentSubcommand = nil
+			})
+		} else {
+			a.Mutate(ctx, state, nil /* no builder, just mutate */)
+		}
 	}
 
 	types := map[gfxapi.ResourceType]*service.ResourcesByType{}
@@ -93,11 +111,23 @@ func (r *ResourcesResolvable) Resolve(ctx context.Context) (interface{}, error)
 	return out, nil
 }
 
+// accessedBy returns the command indices of the access of the resource r by
+// the sub-command sub of the atom atomIdx. Only pipelines are attributed to
+// the sub-command, so that the draws that use a pipeline are listed instead
+// of the submission that executed them. Other resources are attributed to
+// the atom.
+func accessedBy(ctx context.Context, r gfxapi.Resource, atomIdx uint64, sub SubcommandIndex) []uint64 {
+	if len(sub) == 0 || r.ResourceType(ctx) != gfxapi.ResourceType_PipelineResource {
+		return []uint64{atomIdx}
+	}
+	return append([]uint64{atomIdx}, sub...)
+}
+
 type trackedResource struct {
 	resource gfxapi.Resource
 	id       id.ID
 	name     string
-	accesses []uint64
+	accesses [][]uint64
 }
 
 func (r trackedResource) asService(p *path.Capture) *service.Resource {
@@ -109,7 +139,7 @@ func (r trackedResource) asService(p *path.Capture) *service.Resource {
 		Accesses: make([]*path.Command, len(r.accesses)),
 	}
 	for i, a := range r.accesses {
-		out.Accesses[i] = p.Command(a)
+		out.Accesses[i] = p.Command(a[0], a[1:]...)
 	}
 	return out
 }
@@ -0,0 +1,51 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolve
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/gfxapi"
+)
+
+type fakeResource struct {
+	gfxapi.Resource
+	ty gfxapi.ResourceType
+}
+
+func (r fakeResource) ResourceType(context.Context) gfxapi.ResourceType { return r.ty }
+
+func TestAccessedBy(t *testing.T) {
+	ctx := log.Testing(t)
+	pipeline := fakeResource{ty: gfxapi.ResourceType_PipelineResource}
+	texture := fakeResource{ty: gfxapi.ResourceType_TextureResource}
+	for _, test := range []struct {
+		name     string
+		r        gfxapi.Resource
+		sub      SubcommandIndex
+		expected []uint64
+	}{
+		{"pipeline by atom", pipeline, nil, []uint64{5}},
+		{"pipeline by sub-command", pipeline, SubcommandIndex{0, 1, 2}, []uint64{5, 0, 1, 2}},
+		{"texture by atom", texture, nil, []uint64{5}},
+		{"texture by sub-command", texture, SubcommandIndex{0, 1, 2}, []uint64{5}},
+	} {
+		got := accessedBy(ctx, test.r, 5, test.sub)
+		assert.For(ctx, test.name).ThatSlice(got).Equals(test.expected)
+	}
+}
@@ -26,6 +26,8 @@ func internalToService(v interface{}) (interface{}, error) {
 		return atom.ToService(v)
 	case *InternalContext:
 		return &service.Context{Name: v.Name, Api: v.Api}, nil
+	case *InternalThread:
+		return &service.Thread{Name: v.Name}, nil
 	default:
 		return v, nil
 	}
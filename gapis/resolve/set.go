@@ -70,9 +70,9 @@ func change(ctx context.Context, p path.Node, val interface{}) (path.Node, error
 		}
 
 		atomIdx := p.After.Indices[0]
-		if len(p.After.Indices) > 1 {
-			return nil, fmt.Errorf("Subcommands currently not supported") // TODO: Subcommands
-		}
+		// Resources are not created by sub-commands, so the resource is set
+		// at the command that executes the sub-command.
+		after := &path.Command{Capture: p.After.Capture, Indices: p.After.Indices[:1]}
 
 		oldList, err := NAtoms(ctx, p.After.Capture, atomIdx+1)
 		if err != nil {
@@ -89,7 +89,7 @@ func change(ctx context.Context, p path.Node, val interface{}) (path.Node, error
 			return nil, fmt.Errorf("Expected ResourceData, got %T", val)
 		}
 
-		if err := meta.Resource.SetResourceData(ctx, p.After, data, meta.IDMap, replaceAtoms); err != nil {
+		if err := meta.Resource.SetResourceData(ctx, after, data, meta.IDMap, replaceAtoms); err != nil {
 			return nil, err
 		}
 
@@ -109,9 +109,6 @@ func change(ctx context.Context, p path.Node, val interface{}) (path.Node, error
 
 	case *path.Command:
 		atomIdx := p.Indices[0]
-		if len(p.Indices) > 1 {
-			return nil, fmt.Errorf("Subcommands currently not supported") // TODO: Subcommands
-		}
 
 		// Resolve the command list
 		oldList, err := NAtoms(ctx, p.Capture, atomIdx+1)
@@ -119,6 +116,13 @@ func change(ctx context.Context, p path.Node, val interface{}) (path.Node, error
 			return nil, err
 		}
 
+		if len(p.Indices) > 1 {
+			// A sub-command is changed by changing the atom that recorded it.
+			if atomIdx, err = recordingAtomIndex(ctx, oldList.Atoms, p); err != nil {
+				return nil, err
+			}
+		}
+
 		// Validate the value
 		if val == nil {
 			return nil, fmt.Errorf("Command cannot be nil")
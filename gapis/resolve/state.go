@@ -16,7 +16,6 @@ package resolve
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/google/gapid/gapis/atom"
 	"github.com/google/gapid/gapis/capture"
@@ -50,9 +49,6 @@ func APIState(ctx context.Context, p *path.State) (interface{}, error) {
 func (r *GlobalStateResolvable) Resolve(ctx context.Context) (interface{}, error) {
 	ctx = capture.Put(ctx, r.Path.After.Capture)
 	atomIdx := r.Path.After.Indices[0]
-	if len(r.Path.After.Indices) > 1 {
-		return nil, fmt.Errorf("Subcommands currently not supported") // TODO: Subcommands
-	}
 	list, err := NAtoms(ctx, r.Path.After.Capture, atomIdx+1)
 	if err != nil {
 		return nil, err
@@ -61,10 +57,8 @@ func (r *GlobalStateResolvable) Resolve(ctx context.Context) (interface{}, error
 	if err != nil {
 		return nil, err
 	}
-	for _, a := range list.Atoms[:atomIdx+1] {
-		if err := a.Mutate(ctx, s, nil); err != nil && err == context.Canceled {
-			return nil, err
-		}
+	if _, err := mutateUntil(ctx, s, list.Atoms, r.Path.After); err != nil {
+		return nil, err
 	}
 	return s, nil
 }
@@ -73,9 +67,6 @@ func (r *GlobalStateResolvable) Resolve(ctx context.Context) (interface{}, error
 func (r *APIStateResolvable) Resolve(ctx context.Context) (interface{}, error) {
 	ctx = capture.Put(ctx, r.Path.After.Capture)
 	atomIdx := r.Path.After.Indices[0]
-	if len(r.Path.After.Indices) > 1 {
-		return nil, fmt.Errorf("Subcommands currently not supported") // TODO: Subcommands
-	}
 	list, err := NAtoms(ctx, r.Path.After.Capture, atomIdx+1)
 	if err != nil {
 		return nil, err
@@ -85,9 +76,6 @@ func (r *APIStateResolvable) Resolve(ctx context.Context) (interface{}, error) {
 
 func apiState(ctx context.Context, atoms []atom.Atom, p *path.State) (interface{}, error) {
 	atomIdx := p.After.Indices[0]
-	if len(p.After.Indices) > 1 {
-		return nil, fmt.Errorf("Subcommands currently not supported") // TODO: Subcommands
-	}
 	if count := uint64(len(atoms)); atomIdx >= count {
 		return nil, errPathOOB(atomIdx, "Index", 0, count-1, p)
 	}
@@ -99,10 +87,8 @@ func apiState(ctx context.Context, atoms []atom.Atom, p *path.State) (interface{
 	if err != nil {
 		return nil, err
 	}
-	for _, a := range atoms[:atomIdx+1] {
-		if err := a.Mutate(ctx, s, nil); err != nil && err == context.Canceled {
-			return nil, err
-		}
+	if _, err := mutateUntil(ctx, s, atoms, p.After); err != nil {
+		return nil, err
 	}
 	res, found := s.APIs[api]
 	if !found {
@@ -0,0 +1,257 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolve
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/capture"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+// SubcommandProvider is the interface implemented by APIs that have commands
+// that execute previously recorded sub-commands, such as vkQueueSubmit.
+type SubcommandProvider interface {
+	// Subcommands mutates the state s by the atom a and returns the hierarchy
+	// of sub-commands executed by a. Subcommands returns a nil group if a did
+	// not execute any sub-commands.
+	Subcommands(ctx context.Context, a atom.Atom, s *gfxapi.State) (*SubcommandGroup, error)
+
+	// MutateSubcommands mutates the state s by the atom a, only executing the
+	// sub-commands up to and including the sub-command idx. MutateSubcommands
+	// returns the atom that recorded the sub-command idx.
+	MutateSubcommands(ctx context.Context, a atom.Atom, s *gfxapi.State, idx SubcommandIndex) (atom.Atom, error)
+
+	// MutateEachSubcommand mutates the state s by the atom a, calling f for
+	// each of the sub-commands executed by a. f must call mutate to execute the
+	// sub-command.
+	MutateEachSubcommand(ctx context.Context, a atom.Atom, s *gfxapi.State, f func(idx SubcommandIndex, mutate func())) error
+}
+
+// SubcommandNode is either a *SubcommandGroup or a SubcommandIndex.
+type SubcommandNode interface {
+	isSubcommandNode()
+}
+
+// SubcommandIndex is the index of a sub-command relative to the command that
+// executed it.
+type SubcommandIndex []uint64
+
+// SubcommandGroup is a named group of sub-commands.
+type SubcommandGroup struct {
+	// Name of the group.
+	Name string
+	// Children of the group.
+	Children []SubcommandNode
+}
+
+func (SubcommandIndex) isSubcommandNode()  {}
+func (*SubcommandGroup) isSubcommandNode() {}
+
+// AddGroup appends a new child group with the given name to g, returning the
+// new group.
+func (g *SubcommandGroup) AddGroup(name string) *SubcommandGroup {
+	out := &SubcommandGroup{Name: name}
+	g.Children = append(g.Children, out)
+	return out
+}
+
+// AddSubcommand appends the sub-command idx to g.
+func (g *SubcommandGroup) AddSubcommand(idx SubcommandIndex) {
+	g.Children = append(g.Children, idx)
+}
+
+// First returns the index of the first sub-command in the group, or nil if the
+// group holds no sub-commands.
+func (g *SubcommandGroup) First() SubcommandIndex {
+	for _, c := range g.Children {
+		switch c := c.(type) {
+		case SubcommandIndex:
+			return c
+		case *SubcommandGroup:
+			if idx := c.First(); idx != nil {
+				return idx
+			}
+		}
+	}
+	return nil
+}
+
+// Last returns the index of the last sub-command in the group, or nil if the
+// group holds no sub-commands.
+func (g *SubcommandGroup) Last() SubcommandIndex {
+	for i := len(g.Children) - 1; i >= 0; i-- {
+		switch c := g.Children[i].(type) {
+		case SubcommandIndex:
+			return c
+		case *SubcommandGroup:
+			if idx := c.Last(); idx != nil {
+				return idx
+			}
+		}
+	}
+	return nil
+}
+
+// Count returns the total number of sub-commands in the group and all its
+// descendants.
+func (g *SubcommandGroup) Count() uint64 {
+	count := uint64(0)
+	for _, c := range g.Children {
+		switch c := c.(type) {
+		case SubcommandIndex:
+			count++
+		case *SubcommandGroup:
+			count += c.Count()
+		}
+	}
+	return count
+}
+
+// IndicesOf returns the child indices from g to the sub-command idx, or nil if
+// idx is not found in g.
+func (g *SubcommandGroup) IndicesOf(idx SubcommandIndex) []uint64 {
+	for i, c := range g.Children {
+		switch c := c.(type) {
+		case SubcommandIndex:
+			if c.Equals(idx) {
+				return []uint64{uint64(i)}
+			}
+		case *SubcommandGroup:
+			if sub := c.IndicesOf(idx); sub != nil {
+				return append([]uint64{uint64(i)}, sub...)
+			}
+		}
+	}
+	return nil
+}
+
+// Equals returns true if i and o are the same sub-command index.
+func (i SubcommandIndex) Equals(o SubcommandIndex) bool {
+	if len(i) != len(o) {
+		return false
+	}
+	for j := range i {
+		if i[j] != o[j] {
+			return false
+		}
+	}
+	return true
+}
+
+// LessThan returns true if the sub-command i is executed before the
+// sub-command o.
+func (i SubcommandIndex) LessThan(o SubcommandIndex) bool {
+	for j := 0; j < len(i) && j < len(o); j++ {
+		if i[j] != o[j] {
+			return i[j] < o[j]
+		}
+	}
+	return len(i) < len(o)
+}
+
+// mutateUntil mutates the state s by each of the atoms up to and including the
+// command or sub-command p. mutateUntil returns the atom for p, which for a
+// sub-command is the atom that recorded it.
+func mutateUntil(ctx context.Context, s *gfxapi.State, atoms []atom.Atom, p *path.Command) (atom.Atom, error) {
+	atomIdx := p.Indices[0]
+	if count := uint64(len(atoms)); atomIdx >= count {
+		return nil, errPathOOB(atomIdx, "Index", 0, count-1, p)
+	}
+	for _, a := range atoms[:atomIdx] {
+		if err := a.Mutate(ctx, s, nil); err != nil && err == context.Canceled {
+			return nil, err
+		}
+	}
+	return mutateCommand(ctx, s, atoms[atomIdx], p.Indices[1:])
+}
+
+// recordingAtomIndex returns the index in atoms of the atom that recorded
+// the sub-command p.
+func recordingAtomIndex(ctx context.Context, atoms []atom.Atom, p *path.Command) (uint64, error) {
+	s, err := capture.NewState(ctx)
+	if err != nil {
+		return 0, err
+	}
+	a, err := mutateUntil(ctx, s, atoms, p)
+	if err != nil {
+		return 0, err
+	}
+	for i, o := range atoms {
+		if o == a {
+			return uint64(i), nil
+		}
+	}
+	return 0, fmt.Errorf("Atom that recorded sub-command %v not found", p.Indices)
+}
+
+// mutateCommand mutates the state s by the atom a. If sub is not empty then
+// only the sub-commands of a up to and including sub are executed.
+// mutateCommand returns the atom for the command, which for a sub-command is
+// the atom that recorded it.
+func mutateCommand(ctx context.Context, s *gfxapi.State, a atom.Atom, sub []uint64) (atom.Atom, error) {
+	if len(sub) == 0 {
+		if err := a.Mutate(ctx, s, nil); err != nil && err == context.Canceled {
+			return nil, err
+		}
+		return a, nil
+	}
+	provider, ok := a.API().(SubcommandProvider)
+	if !ok {
+		return nil, fmt.Errorf("Command %v has no sub-commands", a.AtomName())
+	}
+	return provider.MutateSubcommands(ctx, a, s, SubcommandIndex(sub))
+}
+
+// mutateAround mutates the state s by the atom a in the same way as
+// mutateCommand, but calls around to execute the command, or the sub-command
+// sub if sub is not empty. around must call mutate. This is used to observe
+// the changes made by a single sub-command of a.
+func mutateAround(ctx context.Context, s *gfxapi.State, a atom.Atom, sub []uint64, around func(mutate func())) error {
+	if len(sub) == 0 {
+		var err error
+		around(func() { err = a.Mutate(ctx, s, nil) })
+		if err != nil && err == context.Canceled {
+			return err
+		}
+		return nil
+	}
+	provider, ok := a.API().(SubcommandProvider)
+	if !ok {
+		return fmt.Errorf("Command %v has no sub-commands", a.AtomName())
+	}
+	found := false
+	err := provider.MutateEachSubcommand(ctx, a, s, func(idx SubcommandIndex, mutate func()) {
+		switch {
+		case found:
+			// Skip all the sub-commands after sub.
+		case idx.Equals(SubcommandIndex(sub)):
+			found = true
+			around(mutate)
+		default:
+			mutate()
+		}
+	})
+	if err != nil && err == context.Canceled {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("%v has no sub-command %v", a.AtomName(), sub)
+	}
+	return nil
+}
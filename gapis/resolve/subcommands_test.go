@@ -0,0 +1,281 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolve
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/image"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/gapil/constset"
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/replay/builder"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+func TestSubcommandGroup(t *testing.T) {
+	assert := assert.To(t)
+
+	root := &SubcommandGroup{}
+	cb := root.AddGroup("Command Buffer")
+	cb.AddSubcommand(SubcommandIndex{0, 0, 0})
+	pass := cb.AddGroup("Render Pass")
+	pass.AddSubcommand(SubcommandIndex{0, 0, 1})
+	subpass := pass.AddGroup("Subpass 0")
+	subpass.AddSubcommand(SubcommandIndex{0, 0, 2})
+	subpass.AddSubcommand(SubcommandIndex{0, 0, 3})
+	pass.AddSubcommand(SubcommandIndex{0, 0, 4})
+	empty := root.AddGroup("Empty")
+
+	assert.For("count").That(root.Count()).Equals(uint64(5))
+	assert.For("first").ThatSlice(root.First()).Equals(SubcommandIndex{0, 0, 0})
+	assert.For("last").ThatSlice(root.Last()).Equals(SubcommandIndex{0, 0, 4})
+	assert.For("subpass first").ThatSlice(subpass.First()).Equals(SubcommandIndex{0, 0, 2})
+	assert.For("empty first").That(empty.First()).IsNil()
+	assert.For("indices").ThatSlice(root.IndicesOf(SubcommandIndex{0, 0, 3})).Equals([]uint64{0, 1, 1, 1})
+	assert.For("missing").That(root.IndicesOf(SubcommandIndex{1, 0, 0})).IsNil()
+}
+
+func TestSubcommandIndexLessThan(t *testing.T) {
+	assert := assert.To(t)
+	for _, test := range []struct {
+		a, b     SubcommandIndex
+		expected bool
+	}{
+		{SubcommandIndex{0, 0, 1}, SubcommandIndex{0, 0, 2}, true},
+		{SubcommandIndex{0, 1, 0}, SubcommandIndex{0, 0, 2}, false},
+		{SubcommandIndex{0, 0, 1}, SubcommandIndex{0, 0, 1}, false},
+		{SubcommandIndex{0, 0}, SubcommandIndex{0, 0, 1}, true},
+		{SubcommandIndex{}, SubcommandIndex{0}, true},
+	} {
+		assert.For("%v < %v", test.a, test.b).That(test.a.LessThan(test.b)).Equals(test.expected)
+	}
+}
+
+// fakeAPI is an API whose fakeSubmit atoms execute their fakeCommands as
+// sub-commands.
+type fakeAPI struct{}
+
+// fakeState is the state of fakeAPI.
+type fakeState struct {
+	executed []string // The names of the commands executed, in order.
+	width    uint32   // The width of the framebuffer.
+}
+
+func getFakeState(s *gfxapi.State) *fakeState {
+	st, ok := s.APIs[fakeAPI{}].(*fakeState)
+	if !ok {
+		st = &fakeState{}
+		s.APIs[fakeAPI{}] = st
+	}
+	return st
+}
+
+func (fakeAPI) Name() string                         { return "fake" }
+func (fakeAPI) Index() uint8                         { return 0 }
+func (fakeAPI) ID() gfxapi.ID                        { return gfxapi.ID{} }
+func (fakeAPI) ConstantSets() *constset.Pack         { return nil }
+func (fakeAPI) Context(*gfxapi.State) gfxapi.Context { return nil }
+func (fakeAPI) GetFramebufferAttachmentInfo(s *gfxapi.State, att gfxapi.FramebufferAttachment) (uint32, uint32, *image.Format, error) {
+	st := getFakeState(s)
+	if st.width == 0 {
+		return 0, 0, nil, fmt.Errorf("No framebuffer")
+	}
+	return st.width, st.width, image.RGBA_U8_NORM, nil
+}
+
+func (fakeAPI) Subcommands(ctx context.Context, a atom.Atom, s *gfxapi.State) (*SubcommandGroup, error) {
+	root := &SubcommandGroup{}
+	err := fakeAPI{}.MutateEachSubcommand(ctx, a, s, func(idx SubcommandIndex, mutate func()) {
+		mutate()
+		root.AddSubcommand(idx)
+	})
+	return root, err
+}
+
+func (fakeAPI) MutateSubcommands(ctx context.Context, a atom.Atom, s *gfxapi.State, idx SubcommandIndex) (atom.Atom, error) {
+	var found atom.Atom
+	err := fakeAPI{}.MutateEachSubcommand(ctx, a, s, func(i SubcommandIndex, mutate func()) {
+		if found != nil {
+			return
+		}
+		mutate()
+		if i.Equals(idx) {
+			found = a.(*fakeSubmit).cmds[i[1]]
+		}
+	})
+	if found == nil {
+		return nil, fmt.Errorf("No sub-command %v", idx)
+	}
+	return found, err
+}
+
+func (fakeAPI) MutateEachSubcommand(ctx context.Context, a atom.Atom, s *gfxapi.State, f func(idx SubcommandIndex, mutate func())) error {
+	submit, ok := a.(*fakeSubmit)
+	if !ok {
+		return a.Mutate(ctx, s, nil)
+	}
+	for i, c := range submit.cmds {
+		c := c
+		f(SubcommandIndex{0, uint64(i)}, func() { c.execute(s) })
+	}
+	return nil
+}
+
+// fakeCommand is an atom that sets the framebuffer width when it is executed,
+// either directly or as a sub-command of a fakeSubmit.
+type fakeCommand struct {
+	name  string
+	width uint32
+}
+
+func (a *fakeCommand) AtomName() string      { return a.name }
+func (a *fakeCommand) API() gfxapi.API       { return fakeAPI{} }
+func (a *fakeCommand) AtomFlags() atom.Flags { return 0 }
+func (a *fakeCommand) Extras() *atom.Extras  { return nil }
+func (a *fakeCommand) Mutate(ctx context.Context, s *gfxapi.State, b *builder.Builder) error {
+	a.execute(s)
+	return nil
+}
+
+func (a *fakeCommand) execute(s *gfxapi.State) {
+	st := getFakeState(s)
+	st.executed = append(st.executed, a.name)
+	if a.width != 0 {
+		st.width = a.width
+	}
+}
+
+// fakeSubmit is an atom that executes the commands it holds.
+type fakeSubmit struct {
+	cmds []*fakeCommand
+}
+
+func (a *fakeSubmit) AtomName() string      { return "Submit" }
+func (a *fakeSubmit) API() gfxapi.API       { return fakeAPI{} }
+func (a *fakeSubmit) AtomFlags() atom.Flags { return 0 }
+func (a *fakeSubmit) Extras() *atom.Extras  { return nil }
+func (a *fakeSubmit) Mutate(ctx context.Context, s *gfxapi.State, b *builder.Builder) error {
+	for _, c := range a.cmds {
+		c.execute(s)
+	}
+	return nil
+}
+
+// newFakeAtoms returns the atoms A, Submit{B, C, D} and E.
+func newFakeAtoms() []atom.Atom {
+	return []atom.Atom{
+		&fakeCommand{name: "A", width: 10},
+		&fakeSubmit{cmds: []*fakeCommand{
+			{name: "B", width: 20},
+			{name: "C", width: 30},
+			{name: "D", width: 40},
+		}},
+		&fakeCommand{name: "E", width: 50},
+	}
+}
+
+func TestStateAfterSubcommand(t *testing.T) {
+	ctx := log.Testing(t)
+	assert := assert.To(t)
+
+	atoms := newFakeAtoms()
+	s := gfxapi.NewStateWithEmptyAllocator(device.Little32)
+	a, err := mutateUntil(ctx, s, atoms, &path.Command{Indices: []uint64{1, 0, 1}})
+	assert.For("err").ThatError(err).Succeeded()
+	assert.For("atom").That(a).Equals(atoms[1].(*fakeSubmit).cmds[1])
+	assert.For("executed").ThatSlice(getFakeState(s).executed).Equals([]string{"A", "B", "C"})
+
+	s = gfxapi.NewStateWithEmptyAllocator(device.Little32)
+	_, err = mutateUntil(ctx, s, atoms, &path.Command{Indices: []uint64{1, 0, 5}})
+	assert.For("missing sub-command").ThatError(err).Failed()
+}
+
+func TestFramebufferAttachmentInfoAfterSubcommand(t *testing.T) {
+	ctx := log.Testing(t)
+	assert := assert.To(t)
+
+	for _, test := range []struct {
+		indices []uint64
+		width   uint32
+	}{
+		{[]uint64{0}, 10},
+		{[]uint64{1, 0, 0}, 20},
+		{[]uint64{1, 0, 1}, 30},
+		{[]uint64{1}, 40},
+	} {
+		s := gfxapi.NewStateWithEmptyAllocator(device.Little32)
+		after := &path.Command{Indices: test.indices}
+		info, err := framebufferAttachmentInfoAfter(ctx, s, newFakeAtoms(), after, gfxapi.FramebufferAttachment_Color0)
+		assert.For("%v err", test.indices).ThatError(err).Succeeded()
+		assert.For("%v width", test.indices).That(info.width).Equals(test.width)
+		assert.For("%v valid", test.indices).That(info.valid).Equals(true)
+	}
+}
+
+func TestMutateAround(t *testing.T) {
+	ctx := log.Testing(t)
+	assert := assert.To(t)
+
+	atoms := newFakeAtoms()
+	s := gfxapi.NewStateWithEmptyAllocator(device.Little32)
+	atoms[0].Mutate(ctx, s, nil)
+
+	var around []string
+	err := mutateAround(ctx, s, atoms[1], []uint64{0, 1}, func(mutate func()) {
+		before := len(getFakeState(s).executed)
+		mutate()
+		around = append(around, getFakeState(s).executed[before:]...)
+	})
+	assert.For("err").ThatError(err).Succeeded()
+	assert.For("around").ThatSlice(around).Equals([]string{"C"})
+	assert.For("executed").ThatSlice(getFakeState(s).executed).Equals([]string{"A", "B", "C"})
+}
+
+func TestSubcommandPaths(t *testing.T) {
+	ctx := log.Testing(t)
+	assert := assert.To(t)
+
+	for _, test := range []struct {
+		name     string
+		from     uint64
+		subFrom  SubcommandIndex
+		to       uint64
+		subTo    SubcommandIndex
+		expected [][]uint64
+	}{
+		{"to sub-command", 0, nil, 1, SubcommandIndex{0, 2},
+			[][]uint64{{0}, {1, 0, 0}, {1, 0, 1}}},
+		{"from sub-command", 1, SubcommandIndex{0, 1}, 2, nil,
+			[][]uint64{{1, 0, 1}, {1, 0, 2}}},
+		{"within command", 1, SubcommandIndex{0, 1}, 1, SubcommandIndex{0, 2},
+			[][]uint64{{1, 0, 1}}},
+	} {
+		s := gfxapi.NewStateWithEmptyAllocator(device.Little32)
+		paths, err := subcommandPaths(ctx, s, newFakeAtoms(), &path.Capture{},
+			test.from, test.subFrom, test.to, test.subTo)
+		assert.For("%s err", test.name).ThatError(err).Succeeded()
+		got := make([][]uint64, len(paths))
+		for i, p := range paths {
+			got[i] = p.Indices
+		}
+		assert.For("%s", test.name).ThatSlice(got).DeepEquals(test.expected)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolve
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/capture"
+	"github.com/google/gapid/gapis/database"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/messages"
+	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+// Threads resolves the list of threads belonging to a capture.
+func Threads(ctx context.Context, p *path.Threads) (*service.Threads, error) {
+	obj, err := database.Build(ctx, &ThreadListResolvable{p.Capture})
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*service.Threads), nil
+}
+
+// Thread resolves the single thread.
+func Thread(ctx context.Context, p *path.Thread) (*InternalThread, error) {
+	boxed, err := database.Resolve(ctx, p.Id.ID())
+	if err != nil {
+		return nil, &service.ErrInvalidPath{
+			Reason: messages.ErrThreadDoesNotExist(p.Id),
+			Path:   p.Path(),
+		}
+	}
+	return boxed.(*InternalThread), nil
+}
+
+// Resolve implements the database.Resolver interface.
+func (r *ThreadListResolvable) Resolve(ctx context.Context) (interface{}, error) {
+	ctx = capture.Put(ctx, r.Capture)
+
+	c, err := capture.Resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := threadsOf(ctx, c.NewState(), c.Atoms)
+	if err != nil {
+		return nil, err
+	}
+
+	threads := make([]*path.Thread, len(ids))
+	for i, thread := range ids {
+		id, err := database.Store(ctx, &InternalThread{
+			Id:   thread,
+			Name: threadName(thread),
+		})
+		if err != nil {
+			return nil, err
+		}
+		threads[i] = r.Capture.Thread(path.NewID(id))
+	}
+
+	return &service.Threads{List: threads}, nil
+}
+
+// threadsOf mutates s with each of the atoms, returning the distinct threads
+// the atoms were executed on, in order of first use.
+func threadsOf(ctx context.Context, s *gfxapi.State, atoms []atom.Atom) ([]uint64, error) {
+	seen := map[uint64]bool{}
+	threads := []uint64{}
+	for _, a := range atoms {
+		if task.Stopped(ctx) {
+			return nil, task.StopReason(ctx)
+		}
+		if err := a.Mutate(ctx, s, nil); err == context.Canceled {
+			return nil, err
+		}
+		if !seen[s.Thread] {
+			seen[s.Thread] = true
+			threads = append(threads, s.Thread)
+		}
+	}
+	return threads, nil
+}
+
+// threadGrouper returns a grouper that groups runs of atoms executed on the
+// same thread.
+func threadGrouper() grouper {
+	return &runGrouper{f: func(a atom.Atom, s *gfxapi.State) (interface{}, string) {
+		return s.Thread, threadName(s.Thread)
+	}}
+}
+
+// threadFilter returns a filter that only passes the atoms executed on
+// thread.
+func threadFilter(thread uint64) filter {
+	return func(a atom.Atom, s *gfxapi.State) bool {
+		return s.Thread == thread
+	}
+}
+
+func threadName(thread uint64) string {
+	return fmt.Sprintf("Thread: 0x%x", thread)
+}
@@ -0,0 +1,117 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolve
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/replay/builder"
+)
+
+// fakeSwitchThread is an atom that changes the current thread, like
+// core.SwitchThread.
+type fakeSwitchThread struct {
+	thread uint64
+}
+
+func (a *fakeSwitchThread) AtomName() string      { return "SwitchThread" }
+func (a *fakeSwitchThread) API() gfxapi.API       { return nil }
+func (a *fakeSwitchThread) AtomFlags() atom.Flags { return 0 }
+func (a *fakeSwitchThread) Extras() *atom.Extras  { return nil }
+func (a *fakeSwitchThread) Mutate(ctx context.Context, s *gfxapi.State, b *builder.Builder) error {
+	s.Thread = a.thread
+	return nil
+}
+
+// newThreadedAtoms returns the atoms A and B on thread 1, C on thread 2 and D
+// back on thread 1.
+func newThreadedAtoms() []atom.Atom {
+	return []atom.Atom{
+		&fakeSwitchThread{thread: 1},
+		&fakeCommand{name: "A"},
+		&fakeCommand{name: "B"},
+		&fakeSwitchThread{thread: 2},
+		&fakeCommand{name: "C"},
+		&fakeSwitchThread{thread: 1},
+		&fakeCommand{name: "D"},
+	}
+}
+
+func TestThreadsOf(t *testing.T) {
+	ctx := log.Testing(t)
+	assert := assert.To(t)
+
+	s := gfxapi.NewStateWithEmptyAllocator(device.Little32)
+	threads, err := threadsOf(ctx, s, newThreadedAtoms())
+	assert.For("err").ThatError(err).Succeeded()
+	assert.For("threads").ThatSlice(threads).Equals([]uint64{1, 2})
+
+	s = gfxapi.NewStateWithEmptyAllocator(device.Little32)
+	threads, err = threadsOf(ctx, s, []atom.Atom{&fakeCommand{name: "A"}})
+	assert.For("err").ThatError(err).Succeeded()
+	assert.For("no switch").ThatSlice(threads).Equals([]uint64{0})
+}
+
+func TestThreadGrouper(t *testing.T) {
+	ctx := log.Testing(t)
+	assert := assert.To(t)
+
+	atoms := newThreadedAtoms()
+	s := gfxapi.NewStateWithEmptyAllocator(device.Little32)
+	g := threadGrouper()
+	for i, a := range atoms {
+		a.Mutate(ctx, s, nil)
+		g.process(ctx, atom.ID(i), a, s)
+	}
+	g.flush(uint64(len(atoms)))
+
+	assert.For("groups").ThatSlice(g.groups()).Equals([]group{
+		{0, 3, "Thread: 0x1"},
+		{3, 5, "Thread: 0x2"},
+		{5, 7, "Thread: 0x1"},
+	})
+}
+
+func TestThreadFilter(t *testing.T) {
+	ctx := log.Testing(t)
+	assert := assert.To(t)
+
+	atoms := newThreadedAtoms()
+	for _, test := range []struct {
+		thread   uint64
+		expected []atom.ID
+	}{
+		{1, []atom.ID{0, 1, 2, 5, 6}},
+		{2, []atom.ID{3, 4}},
+		{3, []atom.ID{}},
+	} {
+		s := gfxapi.NewStateWithEmptyAllocator(device.Little32)
+		f := threadFilter(test.thread)
+		got := []atom.ID{}
+		for i, a := range atoms {
+			a.Mutate(ctx, s, nil)
+			if f(a, s) {
+				got = append(got, atom.ID(i))
+			}
+		}
+		assert.For("thread %v", test.thread).ThatSlice(got).Equals(test.expected)
+	}
+}
@@ -19,6 +19,8 @@
 
 set(files
     grpc.go
+    http.go
+    http_test.go
     server.go
 )
 set(dirs
@@ -29,6 +29,7 @@ import (
 	"github.com/google/gapid/gapis/service"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 
 	xctx "golang.org/x/net/context"
 )
@@ -47,17 +48,19 @@ func Listen(ctx context.Context, addr string, cfg Config) error {
 // This is a blocking call.
 func NewWithListener(ctx context.Context, l net.Listener, cfg Config, srvChan chan<- *grpc.Server) error {
 	keepAlive := make(chan struct{}, 1)
+	handler := New(ctx, cfg)
 	s := &grpcServer{
-		handler: New(ctx, cfg),
-		bindCtx: func(c context.Context) context.Context {
+		handler: handler,
+		bindCtx: func(c context.Context) (context.Context, error) {
 			// Write to keepAlive if it has no pending signal.
 			select {
 			case keepAlive <- struct{}{}:
 			default:
 			}
-			return keys.Clone(c, ctx)
+			return handler.BindSession(keys.Clone(c, ctx), sessionID(c))
 		},
 	}
+	authInterceptor := auth.ServerInterceptor(cfg.AuthToken)
 	return grpcutil.ServeWithListener(ctx, l, func(ctx context.Context, listener net.Listener, server *grpc.Server) error {
 		if addr, ok := listener.Addr().(*net.TCPAddr); ok {
 			// The following message is parsed by launchers to detect the selected port. DO NOT CHANGE!
@@ -70,13 +73,59 @@ func NewWithListener(ctx context.Context, l net.Listener, cfg Config, srvChan ch
 		if cfg.IdleTimeout != 0 {
 			go s.stopIfIdle(ctx, server, keepAlive, cfg.IdleTimeout)
 		}
+		if cfg.HTTPAddr != "" {
+			go func() {
+				if err := s.ListenHTTP(ctx, cfg.HTTPAddr, cfg.AuthToken); err != nil {
+					log.E(ctx, "HTTP/JSON gateway stopped. Error: %v", err)
+				}
+			}()
+		}
 		return nil
-	}, grpc.UnaryInterceptor(auth.ServerInterceptor(cfg.AuthToken)))
+	},
+		grpc.UnaryInterceptor(func(c xctx.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			return authInterceptor(c, req, info, func(c xctx.Context, req interface{}) (interface{}, error) {
+				c, err := s.bindCtx(c)
+				if err != nil {
+					return nil, err
+				}
+				return handler(c, req)
+			})
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			c, err := s.bindCtx(ss.Context())
+			if err != nil {
+				return err
+			}
+			return handler(srv, boundStream{ss, c})
+		}))
+}
+
+// boundStream is a grpc.ServerStream with the context bound by
+// grpcServer.bindCtx.
+type boundStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s boundStream) Context() xctx.Context { return s.ctx }
+
+// sessionID returns the client's session identifier held by the RPC metadata
+// of ctx, or an empty string if there is none.
+func sessionID(ctx context.Context) string {
+	if md, ok := metadata.FromContext(ctx); ok {
+		if ids := md[service.SessionHeader]; len(ids) == 1 {
+			return ids[0]
+		}
+	}
+	return ""
 }
 
 type grpcServer struct {
 	handler Server
-	bindCtx func(context.Context) context.Context
+	// bindCtx returns the context of a request bound to the server and to the
+	// client's session. It is called by the interceptors before each RPC
+	// method, so the methods are passed the bound context.
+	bindCtx func(context.Context) (context.Context, error)
 }
 
 // stopIfIdle calls GracefulStop on server if there are no writes the the
@@ -98,7 +147,7 @@ func (s *grpcServer) stopIfIdle(ctx context.Context, server *grpc.Server, keepAl
 }
 
 func (s *grpcServer) Ping(ctx xctx.Context, req *service.PingRequest) (*service.PingResponse, error) {
-	err := s.handler.Ping(s.bindCtx(ctx))
+	err := s.handler.Ping(ctx)
 	if err := service.NewError(err); err != nil {
 		return &service.PingResponse{}, nil
 	}
@@ -106,7 +155,7 @@ func (s *grpcServer) Ping(ctx xctx.Context, req *service.PingRequest) (*service.
 }
 
 func (s *grpcServer) GetServerInfo(ctx xctx.Context, req *service.GetServerInfoRequest) (*service.GetServerInfoResponse, error) {
-	info, err := s.handler.GetServerInfo(s.bindCtx(ctx))
+	info, err := s.handler.GetServerInfo(ctx)
 	if err := service.NewError(err); err != nil {
 		return &service.GetServerInfoResponse{Res: &service.GetServerInfoResponse_Error{Error: err}}, nil
 	}
@@ -114,7 +163,7 @@ func (s *grpcServer) GetServerInfo(ctx xctx.Context, req *service.GetServerInfoR
 }
 
 func (s *grpcServer) Get(ctx xctx.Context, req *service.GetRequest) (*service.GetResponse, error) {
-	res, err := s.handler.Get(s.bindCtx(ctx), req.Path)
+	res, err := s.handler.Get(ctx, req.Path)
 	if err := service.NewError(err); err != nil {
 		return &service.GetResponse{Res: &service.GetResponse_Error{Error: err}}, nil
 	}
@@ -123,7 +172,7 @@ func (s *grpcServer) Get(ctx xctx.Context, req *service.GetRequest) (*service.Ge
 }
 
 func (s *grpcServer) Set(ctx xctx.Context, req *service.SetRequest) (*service.SetResponse, error) {
-	res, err := s.handler.Set(s.bindCtx(ctx), req.Path, req.Value.Get())
+	res, err := s.handler.Set(ctx, req.Path, req.Value.Get())
 	if err := service.NewError(err); err != nil {
 		return &service.SetResponse{Res: &service.SetResponse_Error{Error: err}}, nil
 	}
@@ -131,7 +180,7 @@ func (s *grpcServer) Set(ctx xctx.Context, req *service.SetRequest) (*service.Se
 }
 
 func (s *grpcServer) Follow(ctx xctx.Context, req *service.FollowRequest) (*service.FollowResponse, error) {
-	res, err := s.handler.Follow(s.bindCtx(ctx), req.Path)
+	res, err := s.handler.Follow(ctx, req.Path)
 	if err := service.NewError(err); err != nil {
 		return &service.FollowResponse{Res: &service.FollowResponse_Error{Error: err}}, nil
 	}
@@ -139,7 +188,7 @@ func (s *grpcServer) Follow(ctx xctx.Context, req *service.FollowRequest) (*serv
 }
 
 func (s *grpcServer) BeginCPUProfile(ctx xctx.Context, req *service.BeginCPUProfileRequest) (*service.BeginCPUProfileResponse, error) {
-	err := s.handler.BeginCPUProfile(s.bindCtx(ctx))
+	err := s.handler.BeginCPUProfile(ctx)
 	if err := service.NewError(err); err != nil {
 		return &service.BeginCPUProfileResponse{Error: err}, nil
 	}
@@ -147,7 +196,7 @@ func (s *grpcServer) BeginCPUProfile(ctx xctx.Context, req *service.BeginCPUProf
 }
 
 func (s *grpcServer) EndCPUProfile(ctx xctx.Context, req *service.EndCPUProfileRequest) (*service.EndCPUProfileResponse, error) {
-	data, err := s.handler.EndCPUProfile(s.bindCtx(ctx))
+	data, err := s.handler.EndCPUProfile(ctx)
 	if err := service.NewError(err); err != nil {
 		return &service.EndCPUProfileResponse{Res: &service.EndCPUProfileResponse_Error{Error: err}}, nil
 	}
@@ -155,7 +204,7 @@ func (s *grpcServer) EndCPUProfile(ctx xctx.Context, req *service.EndCPUProfileR
 }
 
 func (s *grpcServer) GetPerformanceCounters(ctx xctx.Context, req *service.GetPerformanceCountersRequest) (*service.GetPerformanceCountersResponse, error) {
-	data, err := s.handler.GetPerformanceCounters(s.bindCtx(ctx))
+	data, err := s.handler.GetPerformanceCounters(ctx)
 	if err := service.NewError(err); err != nil {
 		return &service.GetPerformanceCountersResponse{Res: &service.GetPerformanceCountersResponse_Error{Error: err}}, nil
 	}
@@ -163,7 +212,7 @@ func (s *grpcServer) GetPerformanceCounters(ctx xctx.Context, req *service.GetPe
 }
 
 func (s *grpcServer) GetProfile(ctx xctx.Context, req *service.GetProfileRequest) (*service.GetProfileResponse, error) {
-	data, err := s.handler.GetProfile(s.bindCtx(ctx), req.Name, req.Debug)
+	data, err := s.handler.GetProfile(ctx, req.Name, req.Debug)
 	if err := service.NewError(err); err != nil {
 		return &service.GetProfileResponse{Res: &service.GetProfileResponse_Error{Error: err}}, nil
 	}
@@ -171,7 +220,7 @@ func (s *grpcServer) GetProfile(ctx xctx.Context, req *service.GetProfileRequest
 }
 
 func (s *grpcServer) GetAvailableStringTables(ctx xctx.Context, req *service.GetAvailableStringTablesRequest) (*service.GetAvailableStringTablesResponse, error) {
-	tables, err := s.handler.GetAvailableStringTables(s.bindCtx(ctx))
+	tables, err := s.handler.GetAvailableStringTables(ctx)
 	if err := service.NewError(err); err != nil {
 		return &service.GetAvailableStringTablesResponse{Res: &service.GetAvailableStringTablesResponse_Error{Error: err}}, nil
 	}
@@ -183,15 +232,23 @@ func (s *grpcServer) GetAvailableStringTables(ctx xctx.Context, req *service.Get
 }
 
 func (s *grpcServer) GetStringTable(ctx xctx.Context, req *service.GetStringTableRequest) (*service.GetStringTableResponse, error) {
-	table, err := s.handler.GetStringTable(s.bindCtx(ctx), req.Table)
+	table, err := s.handler.GetStringTable(ctx, req.Table)
 	if err := service.NewError(err); err != nil {
 		return &service.GetStringTableResponse{Res: &service.GetStringTableResponse_Error{Error: err}}, nil
 	}
 	return &service.GetStringTableResponse{Res: &service.GetStringTableResponse_Table{Table: table}}, nil
 }
 
+func (s *grpcServer) GetPreferredStringTable(ctx xctx.Context, req *service.GetPreferredStringTableRequest) (*service.GetPreferredStringTableResponse, error) {
+	table, err := s.handler.GetPreferredStringTable(ctx, req.CultureCodes)
+	if err := service.NewError(err); err != nil {
+		return &service.GetPreferredStringTableResponse{Res: &service.GetPreferredStringTableResponse_Error{Error: err}}, nil
+	}
+	return &service.GetPreferredStringTableResponse{Res: &service.GetPreferredStringTableResponse_Table{Table: table}}, nil
+}
+
 func (s *grpcServer) ImportCapture(ctx xctx.Context, req *service.ImportCaptureRequest) (*service.ImportCaptureResponse, error) {
-	capture, err := s.handler.ImportCapture(s.bindCtx(ctx), req.Name, req.Data)
+	capture, err := s.handler.ImportCapture(ctx, req.Name, req.Data)
 	if err := service.NewError(err); err != nil {
 		return &service.ImportCaptureResponse{Res: &service.ImportCaptureResponse_Error{Error: err}}, nil
 	}
@@ -199,7 +256,7 @@ func (s *grpcServer) ImportCapture(ctx xctx.Context, req *service.ImportCaptureR
 }
 
 func (s *grpcServer) ExportCapture(ctx xctx.Context, req *service.ExportCaptureRequest) (*service.ExportCaptureResponse, error) {
-	data, err := s.handler.ExportCapture(s.bindCtx(ctx), req.Capture)
+	data, err := s.handler.ExportCapture(ctx, req.Capture)
 	if err := service.NewError(err); err != nil {
 		return &service.ExportCaptureResponse{Res: &service.ExportCaptureResponse_Error{Error: err}}, nil
 	}
@@ -207,7 +264,7 @@ func (s *grpcServer) ExportCapture(ctx xctx.Context, req *service.ExportCaptureR
 }
 
 func (s *grpcServer) LoadCapture(ctx xctx.Context, req *service.LoadCaptureRequest) (*service.LoadCaptureResponse, error) {
-	capture, err := s.handler.LoadCapture(s.bindCtx(ctx), req.Path)
+	capture, err := s.handler.LoadCapture(ctx, req.Path)
 	if err := service.NewError(err); err != nil {
 		return &service.LoadCaptureResponse{Res: &service.LoadCaptureResponse_Error{Error: err}}, nil
 	}
@@ -215,7 +272,7 @@ func (s *grpcServer) LoadCapture(ctx xctx.Context, req *service.LoadCaptureReque
 }
 
 func (s *grpcServer) GetDevices(ctx xctx.Context, req *service.GetDevicesRequest) (*service.GetDevicesResponse, error) {
-	devices, err := s.handler.GetDevices(s.bindCtx(ctx))
+	devices, err := s.handler.GetDevices(ctx)
 	if err := service.NewError(err); err != nil {
 		return &service.GetDevicesResponse{Res: &service.GetDevicesResponse_Error{Error: err}}, nil
 	}
@@ -227,7 +284,7 @@ func (s *grpcServer) GetDevices(ctx xctx.Context, req *service.GetDevicesRequest
 }
 
 func (s *grpcServer) GetDevicesForReplay(ctx xctx.Context, req *service.GetDevicesForReplayRequest) (*service.GetDevicesForReplayResponse, error) {
-	devices, err := s.handler.GetDevicesForReplay(s.bindCtx(ctx), req.Capture)
+	devices, err := s.handler.GetDevicesForReplay(ctx, req.Capture)
 	if err := service.NewError(err); err != nil {
 		return &service.GetDevicesForReplayResponse{Res: &service.GetDevicesForReplayResponse_Error{Error: err}}, nil
 	}
@@ -240,7 +297,7 @@ func (s *grpcServer) GetDevicesForReplay(ctx xctx.Context, req *service.GetDevic
 
 func (s *grpcServer) GetFramebufferAttachment(ctx xctx.Context, req *service.GetFramebufferAttachmentRequest) (*service.GetFramebufferAttachmentResponse, error) {
 	image, err := s.handler.GetFramebufferAttachment(
-		s.bindCtx(ctx),
+		ctx,
 		req.Device,
 		req.After,
 		req.Attachment,
@@ -256,10 +313,46 @@ func (s *grpcServer) GetFramebufferAttachment(ctx xctx.Context, req *service.Get
 func (s *grpcServer) GetLogStream(req *service.GetLogStreamRequest, server service.Gapid_GetLogStreamServer) error {
 	ctx := server.Context()
 	h := log.NewHandler(func(m *log.Message) { server.Send(log_pb.From(m)) }, nil)
-	return s.handler.GetLogStream(s.bindCtx(ctx), h)
+	return s.handler.GetLogStream(ctx, h)
 }
 
 func (s *grpcServer) Find(req *service.FindRequest, server service.Gapid_FindServer) error {
 	ctx := server.Context()
-	return s.handler.Find(s.bindCtx(ctx), req, server.Send)
+	return s.handler.Find(ctx, req, server.Send)
+}
+
+func (s *grpcServer) CreateSession(ctx xctx.Context, req *service.CreateSessionRequest) (*service.CreateSessionResponse, error) {
+	session, err := s.handler.CreateSession(ctx)
+	if err := service.NewError(err); err != nil {
+		return &service.CreateSessionResponse{Res: &service.CreateSessionResponse_Error{Error: err}}, nil
+	}
+	return &service.CreateSessionResponse{Res: &service.CreateSessionResponse_Session{Session: session}}, nil
+}
+
+func (s *grpcServer) GetSessions(ctx xctx.Context, req *service.GetSessionsRequest) (*service.GetSessionsResponse, error) {
+	sessions, err := s.handler.GetSessions(ctx)
+	if err := service.NewError(err); err != nil {
+		return &service.GetSessionsResponse{Res: &service.GetSessionsResponse_Error{Error: err}}, nil
+	}
+	return &service.GetSessionsResponse{
+		Res: &service.GetSessionsResponse_Sessions{
+			Sessions: &service.Sessions{List: sessions},
+		},
+	}, nil
+}
+
+func (s *grpcServer) CloseSession(ctx xctx.Context, req *service.CloseSessionRequest) (*service.CloseSessionResponse, error) {
+	err := s.handler.CloseSession(ctx, req.Id)
+	if err := service.NewError(err); err != nil {
+		return &service.CloseSessionResponse{Error: err}, nil
+	}
+	return &service.CloseSessionResponse{}, nil
+}
+
+func (s *grpcServer) PurgeSession(ctx xctx.Context, req *service.PurgeSessionRequest) (*service.PurgeSessionResponse, error) {
+	err := s.handler.PurgeSession(ctx, req.Id)
+	if err := service.NewError(err); err != nil {
+		return &service.PurgeSessionResponse{Error: err}, nil
+	}
+	return &service.PurgeSessionResponse{}, nil
 }
@@ -0,0 +1,510 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/gapid/core/app/auth"
+	"github.com/google/gapid/core/event/task"
+	img "github.com/google/gapid/core/image"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/log/log_pb"
+	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/path"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// httpPrefix is the URL path prefix of all the HTTP/JSON gateway endpoints.
+	httpPrefix = "/gapid/"
+
+	// SessionHTTPHeader is the name of the HTTP header that holds the
+	// identifier of the client's session, as returned by CreateSession.
+	SessionHTTPHeader = "Session-Id"
+)
+
+// httpMethod is a single unary RPC exposed by the HTTP/JSON gateway.
+type httpMethod struct {
+	// req returns a new, empty request message for the method.
+	req func() proto.Message
+	// call invokes the RPC with the decoded request.
+	call func(ctx context.Context, req proto.Message) (proto.Message, error)
+}
+
+// ListenHTTP starts a new HTTP/JSON gateway for the GRPC server s, listening
+// on addr. This is a blocking call.
+func (s *grpcServer) ListenHTTP(ctx context.Context, addr string, token auth.Token) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return log.Errf(ctx, err, "Could not start http server at %v", addr)
+	}
+	defer listener.Close()
+
+	if addr, ok := listener.Addr().(*net.TCPAddr); ok {
+		log.I(ctx, "HTTP/JSON gateway bound on port '%d'", addr.Port)
+	}
+
+	srv := &http.Server{Handler: s.httpHandler(ctx, token)}
+	go func() {
+		<-task.ShouldStop(ctx)
+		srv.Close()
+	}()
+
+	if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
+		return log.Errf(ctx, err, "Abort running http server: %v", listener.Addr())
+	}
+	return nil
+}
+
+// httpHandler returns the http.Handler that maps each of the Gapid service
+// methods to the URL path httpPrefix + <method-name>.
+//
+// Unary methods accept the JSON encoded request message as the request body
+// (an empty body is treated as an empty request), and respond with the JSON
+// encoded response message. Responses holding a service error are sent with
+// the status code returned by httpStatus. Streaming methods respond with a
+// stream of server-sent events, one event per streamed message. As browsers
+// cannot set headers on event streams, the streaming methods also accept the
+// auth token as a query parameter.
+func (s *grpcServer) httpHandler(ctx context.Context, token auth.Token) http.Handler {
+	mux := http.NewServeMux()
+	for name, m := range s.httpMethods() {
+		name, m := name, m
+		mux.HandleFunc(httpPrefix+name, checkHTTPAuth(token, auth.CheckHTTP, func(w http.ResponseWriter, r *http.Request) {
+			s.serveUnary(ctx, w, r, name, m)
+		}))
+	}
+	mux.HandleFunc(httpPrefix+"GetLogStream", checkHTTPAuth(token, auth.CheckHTTPStream, func(w http.ResponseWriter, r *http.Request) {
+		s.serveLogStream(ctx, w, r)
+	}))
+	mux.HandleFunc(httpPrefix+"Find", checkHTTPAuth(token, auth.CheckHTTPStream, func(w http.ResponseWriter, r *http.Request) {
+		s.serveFind(ctx, w, r)
+	}))
+	return mux
+}
+
+// checkHTTPAuth returns a http.HandlerFunc that calls f if check accepts the
+// auth token of the request, otherwise responding with StatusUnauthorized.
+func checkHTTPAuth(token auth.Token, check func(auth.Token, *http.Request) error, f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := check(token, r); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		f(w, r)
+	}
+}
+
+func (s *grpcServer) httpMethods() map[string]httpMethod {
+	return map[string]httpMethod{
+		"Ping": {
+			func() proto.Message { return &service.PingRequest{} },
+			func(ctx context.Context, req proto.Message) (proto.Message, error) {
+				return s.Ping(ctx, req.(*service.PingRequest))
+			},
+		},
+		"GetServerInfo": {
+			func() proto.Message { return &service.GetServerInfoRequest{} },
+			func(ctx context.Context, req proto.Message) (proto.Message, error) {
+				return s.GetServerInfo(ctx, req.(*service.GetServerInfoRequest))
+			},
+		},
+		"Get": {
+			func() proto.Message { return &service.GetRequest{} },
+			func(ctx context.Context, req proto.Message) (proto.Message, error) {
+				return s.Get(ctx, req.(*service.GetRequest))
+			},
+		},
+		"Set": {
+			func() proto.Message { return &service.SetRequest{} },
+			func(ctx context.Context, req proto.Message) (proto.Message, error) {
+				return s.Set(ctx, req.(*service.SetRequest))
+			},
+		},
+		"Follow": {
+			func() proto.Message { return &service.FollowRequest{} },
+			func(ctx context.Context, req proto.Message) (proto.Message, error) {
+				return s.Follow(ctx, req.(*service.FollowRequest))
+			},
+		},
+		"BeginCPUProfile": {
+			func() proto.Message { return &service.BeginCPUProfileRequest{} },
+			func(ctx context.Context, req proto.Message) (proto.Message, error) {
+				return s.BeginCPUProfile(ctx, req.(*service.BeginCPUProfileRequest))
+			},
+		},
+		"EndCPUProfile": {
+			func() proto.Message { return &service.EndCPUProfileRequest{} },
+			func(ctx context.Context, req proto.Message) (proto.Message, error) {
+				return s.EndCPUProfile(ctx, req.(*service.EndCPUProfileRequest))
+			},
+		},
+		"GetPerformanceCounters": {
+			func() proto.Message { return &service.GetPerformanceCountersRequest{} },
+			func(ctx context.Context, req proto.Message) (proto.Message, error) {
+				return s.GetPerformanceCounters(ctx, req.(*service.GetPerformanceCountersRequest))
+			},
+		},
+		"GetProfile": {
+			func() proto.Message { return &service.GetProfileRequest{} },
+			func(ctx context.Context, req proto.Message) (proto.Message, error) {
+				return s.GetProfile(ctx, req.(*service.GetProfileRequest))
+			},
+		},
+		"GetAvailableStringTables": {
+			func() proto.Message { return &service.GetAvailableStringTablesRequest{} },
+			func(ctx context.Context, req proto.Message) (proto.Message, error) {
+				return s.GetAvailableStringTables(ctx, req.(*service.GetAvailableStringTablesRequest))
+			},
+		},
+		"GetStringTable": {
+			func() proto.Message { return &service.GetStringTableRequest{} },
+			func(ctx context.Context, req proto.Message) (proto.Message, error) {
+				return s.GetStringTable(ctx, req.(*service.GetStringTableRequest))
+			},
+		},
+		"GetPreferredStringTable": {
+			func() proto.Message { return &service.GetPreferredStringTableRequest{} },
+			func(ctx context.Context, req proto.Message) (proto.Message, error) {
+				return s.GetPreferredStringTable(ctx, req.(*service.GetPreferredStringTableRequest))
+			},
+		},
+		"ImportCapture": {
+			func() proto.Message { return &service.ImportCaptureRequest{} },
+			func(ctx context.Context, req proto.Message) (proto.Message, error) {
+				return s.ImportCapture(ctx, req.(*service.ImportCaptureRequest))
+			},
+		},
+		"ExportCapture": {
+			func() proto.Message { return &service.ExportCaptureRequest{} },
+			func(ctx context.Context, req proto.Message) (proto.Message, error) {
+				return s.ExportCapture(ctx, req.(*service.ExportCaptureRequest))
+			},
+		},
+		"LoadCapture": {
+			func() proto.Message { return &service.LoadCaptureRequest{} },
+			func(ctx context.Context, req proto.Message) (proto.Message, error) {
+				return s.LoadCapture(ctx, req.(*service.LoadCaptureRequest))
+			},
+		},
+		"GetDevices": {
+			func() proto.Message { return &service.GetDevicesRequest{} },
+			func(ctx context.Context, req proto.Message) (proto.Message, error) {
+				return s.GetDevices(ctx, req.(*service.GetDevicesRequest))
+			},
+		},
+		"GetDevicesForReplay": {
+			func() proto.Message { return &service.GetDevicesForReplayRequest{} },
+			func(ctx context.Context, req proto.Message) (proto.Message, error) {
+				return s.GetDevicesForReplay(ctx, req.(*service.GetDevicesForReplayRequest))
+			},
+		},
+		"GetFramebufferAttachment": {
+			func() proto.Message { return &service.GetFramebufferAttachmentRequest{} },
+			func(ctx context.Context, req proto.Message) (proto.Message, error) {
+				return s.GetFramebufferAttachment(ctx, req.(*service.GetFramebufferAttachmentRequest))
+			},
+		},
+		"CreateSession": {
+			func() proto.Message { return &service.CreateSessionRequest{} },
+			func(ctx context.Context, req proto.Message) (proto.Message, error) {
+				return s.CreateSession(ctx, req.(*service.CreateSessionRequest))
+			},
+		},
+		"GetSessions": {
+			func() proto.Message { return &service.GetSessionsRequest{} },
+			func(ctx context.Context, req proto.Message) (proto.Message, error) {
+				return s.GetSessions(ctx, req.(*service.GetSessionsRequest))
+			},
+		},
+		"CloseSession": {
+			func() proto.Message { return &service.CloseSessionRequest{} },
+			func(ctx context.Context, req proto.Message) (proto.Message, error) {
+				return s.CloseSession(ctx, req.(*service.CloseSessionRequest))
+			},
+		},
+		"PurgeSession": {
+			func() proto.Message { return &service.PurgeSessionRequest{} },
+			func(ctx context.Context, req proto.Message) (proto.Message, error) {
+				return s.PurgeSession(ctx, req.(*service.PurgeSessionRequest))
+			},
+		},
+	}
+}
+
+// serveUnary handles a single HTTP request for the unary method m.
+func (s *grpcServer) serveUnary(ctx context.Context, w http.ResponseWriter, r *http.Request, name string, m httpMethod) {
+	ctx = log.V{"method": name}.Bind(ctx)
+	ctx, err := s.bindCtx(httpContext(ctx, r))
+	if err != nil {
+		http.Error(w, err.Error(), httpStatus(err))
+		return
+	}
+
+	req := m.req()
+	if err := decodeHTTPRequest(r, req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res, err := m.call(ctx, req)
+	if err != nil {
+		http.Error(w, err.Error(), httpStatus(err))
+		return
+	}
+
+	status := http.StatusOK
+	if res, ok := res.(errorResponse); ok {
+		if err := res.GetError(); err != nil {
+			status = httpStatus(err.Get())
+		}
+	}
+
+	if wantsPNG(r) && status == http.StatusOK {
+		s.writePNG(ctx, w, res)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := (&jsonpb.Marshaler{}).Marshal(w, res); err != nil {
+		log.E(ctx, "Failed to encode response. Error: %v", err)
+	}
+}
+
+// serveLogStream handles a GetLogStream request, sending each log message as
+// a server-sent event until the client disconnects.
+func (s *grpcServer) serveLogStream(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	ctx, err := s.bindCtx(httpContext(ctx, r))
+	if err != nil {
+		http.Error(w, err.Error(), httpStatus(err))
+		return
+	}
+	sse, err := newEventStream(w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// The log handler is called on another goroutine, so the messages are
+	// passed back to this one, which is the only one writing to w.
+	msgs := make(chan *log_pb.Message, 64)
+	h := log.NewHandler(func(m *log.Message) {
+		select {
+		case msgs <- log_pb.From(m):
+		case <-task.ShouldStop(ctx):
+		}
+	}, nil)
+	done := make(chan error, 1)
+	go func() { done <- s.handler.GetLogStream(ctx, h) }()
+	for {
+		select {
+		case m := <-msgs:
+			if err := sse.send(m); err != nil {
+				return
+			}
+		case <-done:
+			// Send the messages logged before GetLogStream returned.
+			for {
+				select {
+				case m := <-msgs:
+					if err := sse.send(m); err != nil {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// serveFind handles a Find request, sending each result as a server-sent
+// event.
+func (s *grpcServer) serveFind(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	ctx, err := s.bindCtx(httpContext(ctx, r))
+	if err != nil {
+		http.Error(w, err.Error(), httpStatus(err))
+		return
+	}
+	req := &service.FindRequest{}
+	if err := decodeHTTPRequest(r, req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sse, err := newEventStream(w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	err = s.handler.Find(ctx, req, func(res *service.FindResponse) error {
+		return sse.send(res)
+	})
+	if err := service.NewError(err); err != nil {
+		sse.sendEvent("error", err)
+	}
+}
+
+// writePNG writes the framebuffer image referenced by the
+// GetFramebufferAttachmentResponse res as a PNG image to w.
+func (s *grpcServer) writePNG(ctx context.Context, w http.ResponseWriter, res proto.Message) {
+	fb, ok := res.(*service.GetFramebufferAttachmentResponse)
+	if !ok {
+		http.Error(w, "PNG encoding is only supported for GetFramebufferAttachment", http.StatusNotAcceptable)
+		return
+	}
+	rgba, err := s.loadImage(ctx, fb.GetImage())
+	if err != nil {
+		http.Error(w, err.Error(), httpStatus(err))
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	if err := png.Encode(w, rgba); err != nil {
+		log.E(ctx, "Failed to encode PNG. Error: %v", err)
+	}
+}
+
+// loadImage resolves the image at p and returns it converted to RGBA.
+func (s *grpcServer) loadImage(ctx context.Context, p *path.ImageInfo) (*image.NRGBA, error) {
+	iio, err := s.handler.Get(ctx, p.Path())
+	if err != nil {
+		return nil, err
+	}
+	ii, ok := iio.(*img.Info2D)
+	if !ok {
+		return nil, fmt.Errorf("Unexpected image type %T", iio)
+	}
+	dataO, err := s.handler.Get(ctx, path.NewBlob(ii.Data.ID()).Path())
+	if err != nil {
+		return nil, err
+	}
+	w, h := int(ii.Width), int(ii.Height)
+	data, err := img.Convert(dataO.([]byte), w, h, ii.Format, img.RGBA_U8_NORM)
+	if err != nil {
+		return nil, err
+	}
+	return &image.NRGBA{
+		Rect:   image.Rect(0, 0, w, h),
+		Stride: w * 4,
+		Pix:    data,
+	}, nil
+}
+
+// errorResponse is implemented by the response messages that can hold a
+// service error.
+type errorResponse interface {
+	GetError() *service.Error
+}
+
+// httpStatus returns the HTTP status code used to report the error err.
+func httpStatus(err error) int {
+	switch err.(type) {
+	case *service.ErrInvalidPath, *service.ErrInvalidArgument, *service.ErrPathNotFollowable:
+		return http.StatusBadRequest
+	case *service.ErrDataUnavailable:
+		return http.StatusNotFound
+	}
+	if err == auth.ErrInvalidToken {
+		return http.StatusUnauthorized
+	}
+	return http.StatusInternalServerError
+}
+
+// httpContext returns a context derived from ctx that is cancelled when the
+// client of the HTTP request r disconnects. If the request holds a
+// SessionHTTPHeader header, then the returned context will be bound to the
+// session with that identifier.
+func httpContext(ctx context.Context, r *http.Request) context.Context {
+	if id := r.Header.Get(SessionHTTPHeader); id != "" {
+		ctx = metadata.NewContext(ctx, metadata.Pairs(service.SessionHeader, id))
+	}
+	ctx, cancel := task.WithCancel(ctx)
+	go func() {
+		<-r.Context().Done()
+		cancel()
+	}()
+	return ctx
+}
+
+// decodeHTTPRequest decodes the JSON body of r into msg. An empty body leaves
+// msg unaltered.
+func decodeHTTPRequest(r *http.Request, msg proto.Message) error {
+	if r.Body == nil {
+		return nil
+	}
+	err := jsonpb.Unmarshal(r.Body, msg)
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+// wantsPNG returns true if the HTTP request asked for a PNG encoded response,
+// either with the Accept header or the format=png query parameter.
+func wantsPNG(r *http.Request) bool {
+	return r.URL.Query().Get("format") == "png" ||
+		strings.Contains(r.Header.Get("Accept"), "image/png")
+}
+
+// eventStream writes messages to a http.ResponseWriter as server-sent events.
+type eventStream struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	m       jsonpb.Marshaler
+}
+
+func newEventStream(w http.ResponseWriter) (*eventStream, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, fmt.Errorf("Streaming is not supported by the connection")
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	return &eventStream{w: w, flusher: flusher}, nil
+}
+
+// send writes msg as an unnamed event.
+func (s *eventStream) send(msg proto.Message) error {
+	return s.sendEvent("", msg)
+}
+
+// sendEvent writes msg as an event with the given name.
+func (s *eventStream) sendEvent(name string, msg proto.Message) error {
+	data, err := s.m.MarshalToString(msg)
+	if err != nil {
+		return err
+	}
+	if name != "" {
+		if _, err := fmt.Fprintf(s.w, "event: %s\n", name); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(s.w, "data: %s\n\n", data); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
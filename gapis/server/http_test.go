@@ -0,0 +1,255 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/gapid/core/app/auth"
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/data/id"
+	img "github.com/google/gapid/core/image"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/messages"
+	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+const testHTTPToken = auth.Token("secret")
+
+// fakeServer implements the Server methods used by the HTTP tests.
+// All other methods are left unimplemented.
+type fakeServer struct {
+	Server
+	get func(p *path.Any) (interface{}, error)
+	fb  func() (*path.ImageInfo, error)
+}
+
+func (s *fakeServer) Ping(ctx context.Context) error { return nil }
+
+func (s *fakeServer) Get(ctx context.Context, p *path.Any) (interface{}, error) {
+	return s.get(p)
+}
+
+func (s *fakeServer) GetFramebufferAttachment(
+	ctx context.Context,
+	device *path.Device,
+	after *path.Command,
+	attachment gfxapi.FramebufferAttachment,
+	settings *service.RenderSettings,
+	hints *service.UsageHints) (*path.ImageInfo, error) {
+	return s.fb()
+}
+
+func (s *fakeServer) GetLogStream(ctx context.Context, h log.Handler) error {
+	h.Handle(&log.Message{Text: "hello"})
+	return nil
+}
+
+func (s *fakeServer) Find(ctx context.Context, req *service.FindRequest, h service.FindHandler) error {
+	for i := 0; i < 2; i++ {
+		if err := h(&service.FindResponse{}); err != nil {
+			return err
+		}
+	}
+	return &service.ErrDataUnavailable{Reason: messages.ErrStateUnavailable()}
+}
+
+// newTestHTTPServer returns a httptest.Server serving the HTTP/JSON gateway
+// of a grpcServer using s as its handler.
+func newTestHTTPServer(ctx context.Context, s *fakeServer) *httptest.Server {
+	g := &grpcServer{handler: s, bindCtx: func(c context.Context) (context.Context, error) { return c, nil }}
+	return httptest.NewServer(g.httpHandler(ctx, testHTTPToken))
+}
+
+// post sends the JSON encoded req to the gateway method at url, with the
+// given auth token header. It returns the response status and body.
+func post(url string, token auth.Token, req proto.Message) (int, []byte, error) {
+	body := &bytes.Buffer{}
+	if req != nil {
+		if err := (&jsonpb.Marshaler{}).Marshal(body, req); err != nil {
+			return 0, nil, err
+		}
+	}
+	r, err := http.NewRequest("POST", url, body)
+	if err != nil {
+		return 0, nil, err
+	}
+	if token != auth.NoAuth {
+		r.Header.Set(auth.HTTPHeader, string(token))
+	}
+	res, err := http.DefaultClient.Do(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	return res.StatusCode, data, err
+}
+
+func TestHTTPUnary(t *testing.T) {
+	ctx := log.Testing(t)
+	capture := path.NewCapture(id.OfString("capture"))
+	srv := newTestHTTPServer(ctx, &fakeServer{get: func(p *path.Any) (interface{}, error) {
+		return &service.Capture{Name: "my-capture"}, nil
+	}})
+	defer srv.Close()
+
+	status, _, err := post(srv.URL+httpPrefix+"Ping", auth.NoAuth, nil)
+	assert.For(ctx, "ping without token").ThatError(err).Succeeded()
+	assert.For(ctx, "ping without token status").That(status).Equals(http.StatusUnauthorized)
+
+	status, _, err = post(srv.URL+httpPrefix+"Ping", testHTTPToken, nil)
+	assert.For(ctx, "ping").ThatError(err).Succeeded()
+	assert.For(ctx, "ping status").That(status).Equals(http.StatusOK)
+
+	status, body, err := post(srv.URL+httpPrefix+"Get", testHTTPToken, &service.GetRequest{Path: capture.Path()})
+	assert.For(ctx, "get").ThatError(err).Succeeded()
+	assert.For(ctx, "get status").That(status).Equals(http.StatusOK)
+	res := &service.GetResponse{}
+	assert.For(ctx, "get decode").ThatError(jsonpb.Unmarshal(bytes.NewReader(body), res)).Succeeded()
+	assert.For(ctx, "get name").That(res.GetValue().GetCapture().GetName()).Equals("my-capture")
+}
+
+func TestHTTPErrorStatus(t *testing.T) {
+	ctx := log.Testing(t)
+	for _, test := range []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{"invalid path", &service.ErrInvalidPath{}, http.StatusBadRequest},
+		{"invalid argument", &service.ErrInvalidArgument{}, http.StatusBadRequest},
+		{"not followable", &service.ErrPathNotFollowable{}, http.StatusBadRequest},
+		{"data unavailable", &service.ErrDataUnavailable{}, http.StatusNotFound},
+		{"internal", fmt.Errorf("Oh noes"), http.StatusInternalServerError},
+	} {
+		want := test.err
+		srv := newTestHTTPServer(ctx, &fakeServer{get: func(p *path.Any) (interface{}, error) {
+			return nil, want
+		}})
+		req := &service.GetRequest{Path: path.NewCapture(id.OfString("capture")).Path()}
+		status, body, err := post(srv.URL+httpPrefix+"Get", testHTTPToken, req)
+		srv.Close()
+		assert.For(ctx, "%s", test.name).ThatError(err).Succeeded()
+		assert.For(ctx, "%s status", test.name).That(status).Equals(test.expected)
+		res := &service.GetResponse{}
+		assert.For(ctx, "%s decode", test.name).ThatError(jsonpb.Unmarshal(bytes.NewReader(body), res)).Succeeded()
+		assert.For(ctx, "%s error", test.name).That(res.GetError()).IsNotNil()
+	}
+	assert.For(ctx, "invalid token").That(httpStatus(auth.ErrInvalidToken)).Equals(http.StatusUnauthorized)
+}
+
+func TestHTTPFramebufferPNG(t *testing.T) {
+	ctx := log.Testing(t)
+	pixels := []byte{
+		0xff, 0x00, 0x00, 0xff,
+		0x00, 0xff, 0x00, 0xff,
+	}
+	dataID := id.OfBytes(pixels)
+	info := &img.Info2D{Format: img.RGBA_U8_NORM, Width: 2, Height: 1, Data: img.NewID(dataID)}
+	infoID := id.OfString("image-info")
+
+	fake := &fakeServer{
+		get: func(p *path.Any) (interface{}, error) {
+			switch p := p.Node().(type) {
+			case *path.ImageInfo:
+				if p.Id.ID() == infoID {
+					return info, nil
+				}
+			case *path.Blob:
+				if p.Id.ID() == dataID {
+					return pixels, nil
+				}
+			}
+			return nil, &service.ErrInvalidPath{Reason: messages.ErrStateUnavailable(), Path: p}
+		},
+		fb: func() (*path.ImageInfo, error) { return path.NewImageInfo(infoID), nil },
+	}
+	srv := newTestHTTPServer(ctx, fake)
+	defer srv.Close()
+	url := srv.URL + httpPrefix + "GetFramebufferAttachment?format=png"
+
+	status, body, err := post(url, testHTTPToken, nil)
+	assert.For(ctx, "png").ThatError(err).Succeeded()
+	assert.For(ctx, "png status").That(status).Equals(http.StatusOK)
+	got, err := png.Decode(bytes.NewReader(body))
+	if assert.For(ctx, "png decode").ThatError(err).Succeeded() {
+		assert.For(ctx, "png bounds").That(got.Bounds().Dx()).Equals(2)
+		r, g, _, _ := got.At(0, 0).RGBA()
+		assert.For(ctx, "png pixel 0").That([]uint32{r, g}).DeepEquals([]uint32{0xffff, 0})
+		r, g, _, _ = got.At(1, 0).RGBA()
+		assert.For(ctx, "png pixel 1").That([]uint32{r, g}).DeepEquals([]uint32{0, 0xffff})
+	}
+
+	// A missing image blob is reported with the status of the error.
+	dataID = id.OfString("missing")
+	status, _, err = post(url, testHTTPToken, nil)
+	assert.For(ctx, "missing blob").ThatError(err).Succeeded()
+	assert.For(ctx, "missing blob status").That(status).Equals(http.StatusBadRequest)
+
+	// An unavailable framebuffer is reported with the status of the error.
+	fake.fb = func() (*path.ImageInfo, error) {
+		return nil, &service.ErrDataUnavailable{Reason: messages.ErrFramebufferUnavailable()}
+	}
+	status, _, err = post(url, testHTTPToken, nil)
+	assert.For(ctx, "unavailable").ThatError(err).Succeeded()
+	assert.For(ctx, "unavailable status").That(status).Equals(http.StatusNotFound)
+}
+
+func TestHTTPEventStreams(t *testing.T) {
+	ctx := log.Testing(t)
+	srv := newTestHTTPServer(ctx, &fakeServer{})
+	defer srv.Close()
+
+	get := func(url string) (int, string) {
+		res, err := http.Get(url)
+		if !assert.For(ctx, "GET %s", url).ThatError(err).Succeeded() {
+			return 0, ""
+		}
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		assert.For(ctx, "read %s", url).ThatError(err).Succeeded()
+		return res.StatusCode, string(body)
+	}
+
+	// The streaming methods accept the auth token as a query parameter.
+	status, body := get(srv.URL + httpPrefix + "GetLogStream?" + auth.HTTPQuery + "=" + string(testHTTPToken))
+	assert.For(ctx, "log status").That(status).Equals(http.StatusOK)
+	assert.For(ctx, "log body").ThatString(body).Contains(`data: {"text":"hello"`)
+
+	status, body = get(srv.URL + httpPrefix + "Find?" + auth.HTTPQuery + "=" + string(testHTTPToken))
+	assert.For(ctx, "find status").That(status).Equals(http.StatusOK)
+	assert.For(ctx, "find results").That(strings.Count(body, "data: ")).Equals(3)
+	assert.For(ctx, "find error").ThatString(body).Contains("event: error\ndata: ")
+
+	status, _ = get(srv.URL + httpPrefix + "Find?" + auth.HTTPQuery + "=wrong")
+	assert.For(ctx, "wrong token status").That(status).Equals(http.StatusUnauthorized)
+
+	// The unary methods do not.
+	status, _ = get(srv.URL + httpPrefix + "Ping?" + auth.HTTPQuery + "=" + string(testHTTPToken))
+	assert.For(ctx, "unary query status").That(status).Equals(http.StatusUnauthorized)
+}
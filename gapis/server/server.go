@@ -33,10 +33,12 @@ import (
 	"github.com/google/gapid/core/os/device/bind"
 	"github.com/google/gapid/gapis/capture"
 	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/messages"
 	"github.com/google/gapid/gapis/replay/devices"
 	"github.com/google/gapid/gapis/resolve"
 	"github.com/google/gapid/gapis/service"
 	"github.com/google/gapid/gapis/service/path"
+	"github.com/google/gapid/gapis/session"
 	"github.com/google/gapid/gapis/stringtable"
 
 	// Register all the gfxapis
@@ -51,11 +53,29 @@ type Config struct {
 	DeviceScanDone task.Signal
 	LogBroadcaster *log.Broadcaster
 	IdleTimeout    time.Duration
+	// SessionMemoryBudget is the maximum number of bytes of data each client
+	// session can store. 0 is unlimited.
+	SessionMemoryBudget uint64
+	// SessionIdleTimeout is the duration after which unused client sessions
+	// are closed. 0 keeps sessions open until they are explicitly closed.
+	SessionIdleTimeout time.Duration
+	// HTTPAddr is the TCP host:port of the optional HTTP/JSON gateway.
+	// If empty, the gateway is not started.
+	HTTPAddr string
+	// DefaultLocale is the culture code of the string table used for messages
+	// missing from the other string tables.
+	DefaultLocale string
 }
 
 // Server is the server interface to GAPIS.
 type Server interface {
 	service.Service
+
+	// BindSession returns a new context derived from ctx that is bound to the
+	// client session with the given identifier. An empty identifier binds the
+	// default session. BindSession returns an error if there is no open
+	// session with the identifier.
+	BindSession(ctx context.Context, id string) (context.Context, error)
 }
 
 // New constructs and returns a new Server.
@@ -63,18 +83,22 @@ func New(ctx context.Context, cfg Config) Server {
 	return &server{
 		cfg.Info,
 		cfg.StringTables,
+		defaultStringTable(cfg.StringTables, cfg.DefaultLocale),
 		cfg.DeviceScanDone,
 		cfg.LogBroadcaster,
 		bytes.Buffer{},
+		session.NewManager(ctx, cfg.SessionMemoryBudget, cfg.SessionIdleTimeout),
 	}
 }
 
 type server struct {
 	info           *service.ServerInfo
 	stbs           []*stringtable.StringTable
+	defaultStb     *stringtable.StringTable
 	deviceScanDone task.Signal
 	logBroadcaster *log.Broadcaster
 	profile        bytes.Buffer
+	sessions       *session.Manager
 }
 
 func (s *server) Ping(ctx context.Context) error {
@@ -86,11 +110,19 @@ func (s *server) GetServerInfo(ctx context.Context) (*service.ServerInfo, error)
 	return s.info, nil
 }
 
+// defaultStringTable returns the table in tables that best matches locale,
+// falling back to the stringtable.DefaultCultureCode table.
+func defaultStringTable(tables []*stringtable.StringTable, locale string) *stringtable.StringTable {
+	return stringtable.Negotiate(tables, []string{locale, stringtable.DefaultCultureCode})
+}
+
 func (s *server) GetAvailableStringTables(ctx context.Context) ([]*stringtable.Info, error) {
 	ctx = log.Enter(ctx, "GetAvailableStringTables")
 	infos := make([]*stringtable.Info, len(s.stbs))
 	for i, table := range s.stbs {
-		infos[i] = table.Info
+		info := *table.Info
+		info.IsDefault = table == s.defaultStb
+		infos[i] = &info
 	}
 	return infos, nil
 }
@@ -99,12 +131,23 @@ func (s *server) GetStringTable(ctx context.Context, info *stringtable.Info) (*s
 	ctx = log.Enter(ctx, "GetStringTable")
 	for _, table := range s.stbs {
 		if table.Info.CultureCode == info.CultureCode {
-			return table, nil
+			return table.WithFallback(s.defaultStb), nil
 		}
 	}
 	return nil, fmt.Errorf("String table not found")
 }
 
+func (s *server) GetPreferredStringTable(ctx context.Context, cultureCodes []string) (*stringtable.StringTable, error) {
+	ctx = log.Enter(ctx, "GetPreferredStringTable")
+	if table := stringtable.Negotiate(s.stbs, cultureCodes); table != nil {
+		return table.WithFallback(s.defaultStb), nil
+	}
+	if s.defaultStb != nil {
+		return s.defaultStb, nil
+	}
+	return nil, fmt.Errorf("String table not found")
+}
+
 func (s *server) ImportCapture(ctx context.Context, name string, data []uint8) (*path.Capture, error) {
 	ctx = log.Enter(ctx, "ImportCapture")
 	return capture.Import(ctx, name, data)
@@ -255,3 +298,53 @@ func (s *server) GetProfile(ctx context.Context, name string, debug int32) ([]by
 	}
 	return b.Bytes(), nil
 }
+
+func (s *server) BindSession(ctx context.Context, id string) (context.Context, error) {
+	if id == "" {
+		return ctx, nil
+	}
+	sess, err := s.sessions.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return sess.Bind(ctx), nil
+}
+
+func (s *server) CreateSession(ctx context.Context) (*service.Session, error) {
+	ctx = log.Enter(ctx, "CreateSession")
+	return s.sessions.Create(ctx).Service(ctx), nil
+}
+
+func (s *server) GetSessions(ctx context.Context) ([]*service.Session, error) {
+	ctx = log.Enter(ctx, "GetSessions")
+	if own := session.Get(ctx); own != nil {
+		return []*service.Session{own.Service(ctx)}, nil
+	}
+	return []*service.Session{}, nil
+}
+
+func (s *server) CloseSession(ctx context.Context, id string) error {
+	ctx = log.Enter(ctx, "CloseSession")
+	if err := checkOwnSession(ctx, id); err != nil {
+		return err
+	}
+	return s.sessions.Close(ctx, id)
+}
+
+func (s *server) PurgeSession(ctx context.Context, id string) error {
+	ctx = log.Enter(ctx, "PurgeSession")
+	if err := checkOwnSession(ctx, id); err != nil {
+		return err
+	}
+	return s.sessions.Purge(ctx, id)
+}
+
+// checkOwnSession returns an error if the session with the given identifier
+// is not the session bound to ctx. The error is the same as for a session
+// that does not exist, so callers cannot probe for the sessions of others.
+func checkOwnSession(ctx context.Context, id string) error {
+	if own := session.Get(ctx); own == nil || own.ID != id {
+		return &service.ErrInvalidArgument{Reason: messages.ErrSessionDoesNotExist(id)}
+	}
+	return nil
+}
@@ -25,7 +25,11 @@ func (e *ErrInvalidPath) Error() string {
 }
 
 func (e *ErrInvalidArgument) Error() string {
-	return fmt.Sprintf("The argument is invalid", e.Reason.Text(nil))
+	msg := fmt.Sprintf("The argument is invalid. Reason: %v", e.Reason.Text(nil))
+	for _, d := range e.Diagnostics {
+		msg += fmt.Sprintf("\n%d:%d: %v: %s", d.Line, d.Column, d.Severity, d.Message)
+	}
+	return msg
 }
 
 func (e *ErrPathNotFollowable) Error() string {
@@ -74,6 +74,7 @@ func (n *Field) Path() *Any                     { return &Any{&Any_Field{n}} }
 func (n *ImageInfo) Path() *Any                 { return &Any{&Any_ImageInfo{n}} }
 func (n *MapIndex) Path() *Any                  { return &Any{&Any_MapIndex{n}} }
 func (n *Memory) Path() *Any                    { return &Any{&Any_Memory{n}} }
+func (n *MemoryBreakdown) Path() *Any           { return &Any{&Any_MemoryBreakdown{n}} }
 func (n *Mesh) Path() *Any                      { return &Any{&Any_Mesh{n}} }
 func (n *Parameter) Path() *Any                 { return &Any{&Any_Parameter{n}} }
 func (n *Report) Path() *Any                    { return &Any{&Any_Report{n}} }
@@ -85,6 +86,8 @@ func (n *State) Path() *Any                     { return &Any{&Any_State{n}} }
 func (n *StateTree) Path() *Any                 { return &Any{&Any_StateTree{n}} }
 func (n *StateTreeNode) Path() *Any             { return &Any{&Any_StateTreeNode{n}} }
 func (n *StateTreeNodeForPath) Path() *Any      { return &Any{&Any_StateTreeNodeForPath{n}} }
+func (n *Thread) Path() *Any                    { return &Any{&Any_Thread{n}} }
+func (n *Threads) Path() *Any                   { return &Any{&Any_Threads{n}} }
 func (n *Thumbnail) Path() *Any                 { return &Any{&Any_Thumbnail{n}} }
 
 func (n API) Parent() Node                       { return nil }
@@ -106,6 +109,7 @@ func (n Field) Parent() Node                     { return oneOfNode(n.Struct) }
 func (n ImageInfo) Parent() Node                 { return nil }
 func (n MapIndex) Parent() Node                  { return oneOfNode(n.Map) }
 func (n Memory) Parent() Node                    { return n.After }
+func (n MemoryBreakdown) Parent() Node           { return n.After }
 func (n Mesh) Parent() Node                      { return oneOfNode(n.Object) }
 func (n Parameter) Parent() Node                 { return n.Command }
 func (n Report) Parent() Node                    { return n.Capture }
@@ -117,6 +121,8 @@ func (n State) Parent() Node                     { return n.After }
 func (n StateTree) Parent() Node                 { return n.After }
 func (n StateTreeNode) Parent() Node             { return nil }
 func (n StateTreeNodeForPath) Parent() Node      { return nil }
+func (n Thread) Parent() Node                    { return n.Capture }
+func (n Threads) Parent() Node                   { return n.Capture }
 func (n Thumbnail) Parent() Node                 { return oneOfNode(n.Object) }
 
 func (n ArrayIndex) Text() string { return fmt.Sprintf("%v[%v]", n.Parent().Text(), n.Index) }
@@ -148,6 +154,9 @@ func (n Field) Text() string     { return fmt.Sprintf("%v.%v", n.Parent().Text()
 func (n ImageInfo) Text() string { return fmt.Sprintf("image-info<%x>", n.Id) }
 func (n MapIndex) Text() string  { return fmt.Sprintf("%v[%x]", n.Parent().Text(), n.Key) }
 func (n Memory) Text() string    { return fmt.Sprintf("%v.memory-after", n.Parent().Text()) }
+func (n MemoryBreakdown) Text() string {
+	return fmt.Sprintf("%v.memory-breakdown", n.Parent().Text())
+}
 func (n Mesh) Text() string      { return fmt.Sprintf("%v.mesh", n.Parent().Text()) }
 func (n Parameter) Text() string { return fmt.Sprintf("%v.%v", n.Parent().Text(), n.Name) }
 func (n Report) Text() string    { return fmt.Sprintf("%v.report", n.Parent().Text()) }
@@ -165,6 +174,8 @@ func (n StateTreeNode) Text() string {
 func (n StateTreeNodeForPath) Text() string {
 	return fmt.Sprintf("state-tree-for<%v, %v>", n.Tree, n.Member.Text())
 }
+func (n Thread) Text() string    { return fmt.Sprintf("%v.threads[%x]", n.Parent().Text(), n.Id) }
+func (n Threads) Text() string   { return fmt.Sprintf("%v.threads", n.Parent().Text()) }
 func (n Thumbnail) Text() string { return fmt.Sprintf("%v.thumbnail", n.Parent().Text()) }
 
 func (n *ArrayIndex) SetParent(p Node) {
@@ -349,6 +360,11 @@ func (n *Capture) Contexts() *Contexts {
 	return &Contexts{Capture: n}
 }
 
+// Threads returns the path node to the capture's threads.
+func (n *Capture) Threads() *Threads {
+	return &Threads{Capture: n}
+}
+
 // Commands returns the path node to the capture's commands.
 func (n *Capture) Commands() *Commands {
 	return &Commands{
@@ -392,6 +408,11 @@ func (n *Capture) Context(id *ID) *Context {
 	return &Context{Capture: n, Id: id}
 }
 
+// Thread returns the path node to the a thread with the given ID.
+func (n *Capture) Thread(id *ID) *Thread {
+	return &Thread{Capture: n, Id: id}
+}
+
 // MemoryAfter returns the path node to the memory after this command.
 func (n *Command) MemoryAfter(pool uint32, addr, size uint64) *Memory {
 	return &Memory{addr, size, pool, n, false, false}
@@ -412,6 +433,12 @@ func (n *Command) Mesh(faceted bool) *Mesh {
 	}
 }
 
+// MemoryBreakdown returns the path node to the device memory allocations after
+// this command.
+func (n *Command) MemoryBreakdown() *MemoryBreakdown {
+	return &MemoryBreakdown{After: n}
+}
+
 // StateAfter returns the path node to the state after this command.
 func (n *Command) StateAfter() *State {
 	return &State{After: n}
@@ -43,8 +43,16 @@ type Service interface {
 	GetAvailableStringTables(ctx context.Context) ([]*stringtable.Info, error)
 
 	// GetStringTable returns the requested string table.
+	// Entries missing from the requested table are taken from the default
+	// table.
 	GetStringTable(ctx context.Context, info *stringtable.Info) (*stringtable.StringTable, error)
 
+	// GetPreferredStringTable returns the string table that best matches the
+	// list of culture codes, ordered from most to least preferred. If no table
+	// matches then the default table is returned. Entries missing from the
+	// returned table are taken from the default table.
+	GetPreferredStringTable(ctx context.Context, cultureCodes []string) (*stringtable.StringTable, error)
+
 	// ImportCapture imports capture data emitted by the graphics spy, returning
 	// the new capture identifier.
 	ImportCapture(ctx context.Context, name string, data []uint8) (*path.Capture, error)
@@ -120,8 +128,30 @@ type Service interface {
 
 	// Find performs a search using req, streaming the results to h.
 	Find(ctx context.Context, req *FindRequest, h FindHandler) error
+
+	// CreateSession opens a new client session, returning its description.
+	// The session's identifier is issued by the server.
+	CreateSession(ctx context.Context) (*Session, error)
+
+	// GetSessions returns the client session used by the caller, if any.
+	GetSessions(ctx context.Context) ([]*Session, error)
+
+	// CloseSession closes the session with the given identifier, releasing
+	// all of its captures and data. Only the caller's own session can be
+	// closed.
+	CloseSession(ctx context.Context, id string) error
+
+	// PurgeSession discards all the cached data of the session with the given
+	// identifier, keeping its captures. Only the caller's own session can be
+	// purged.
+	PurgeSession(ctx context.Context, id string) error
 }
 
+// SessionHeader is the name of the RPC metadata entry that holds the
+// identifier of the client's session, as returned by CreateSession. Requests
+// without this entry use the server's default session.
+const SessionHeader = "session_id"
+
 // FindHandler is the handler of found items using Service.Find.
 type FindHandler func(*FindResponse) error
 
@@ -187,8 +217,14 @@ func NewValue(v interface{}) *Value {
 		return &Value{&Value_StateTree{v}}
 	case *StateTreeNode:
 		return &Value{&Value_StateTreeNode{v}}
+	case *Thread:
+		return &Value{&Value_Thread{v}}
+	case *Threads:
+		return &Value{&Value_Threads{v}}
 	case *gfxapi.Mesh:
 		return &Value{&Value_Mesh{v}}
+	case *gfxapi.MemoryBreakdown:
+		return &Value{&Value_MemoryBreakdown{v}}
 	case *gfxapi.ResourceData:
 		return &Value{&Value_ResourceData{v}}
 	case *image.Info2D:
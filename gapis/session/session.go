@@ -0,0 +1,194 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package session implements isolated client sessions for GAPIS.
+//
+// Each session owns its own database, so captures imported and data resolved
+// by one client are not visible to, and cannot be evicted by, the clients of
+// other sessions. Session identifiers are issued by the server and are not
+// guessable, so a client can only use the sessions it created.
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/gapid/core/context/keys"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/capture"
+	"github.com/google/gapid/gapis/database"
+	"github.com/google/gapid/gapis/messages"
+	"github.com/google/gapid/gapis/service"
+)
+
+// Session is an isolated namespace of captures and cached data.
+type Session struct {
+	// ID is the unique identifier of the session.
+	ID string
+	// Created is the time the session was created.
+	Created time.Time
+	// Budget is the maximum number of bytes of data the session can store.
+	// 0 is unlimited.
+	Budget uint64
+
+	db       database.Database
+	lastUsed time.Time
+}
+
+// Bind returns a new context derived from ctx that holds the session and uses
+// its database.
+func (s *Session) Bind(ctx context.Context) context.Context {
+	return keys.WithValue(database.Replace(ctx, s.db), contextKey, s)
+}
+
+type contextKeyTy string
+
+const contextKey = contextKeyTy("session")
+
+// Get returns the session bound to ctx by Session.Bind, or nil if ctx is not
+// bound to a session.
+func Get(ctx context.Context) *Session {
+	s, _ := ctx.Value(contextKey).(*Session)
+	return s
+}
+
+// Service returns the service.Session description for this session.
+func (s *Session) Service(ctx context.Context) *service.Session {
+	return &service.Session{
+		Id:           s.ID,
+		Captures:     capture.Captures(s.Bind(ctx)),
+		MemoryUsed:   database.Usage(s.db),
+		MemoryBudget: s.Budget,
+		Created:      s.Created.Unix(),
+	}
+}
+
+// Manager holds all the open sessions of a server.
+type Manager struct {
+	mutex       sync.Mutex
+	ctx         context.Context
+	budget      uint64
+	idleTimeout time.Duration
+	sessions    map[string]*Session
+	now         func() time.Time
+}
+
+// NewManager returns a new session Manager. Sessions created by the manager
+// will have a memory budget of budget bytes. A budget of 0 is unlimited.
+// Sessions that are not used for idleTimeout are closed. An idleTimeout of 0
+// keeps sessions open until they are explicitly closed.
+func NewManager(ctx context.Context, budget uint64, idleTimeout time.Duration) *Manager {
+	return &Manager{
+		ctx:         ctx,
+		budget:      budget,
+		idleTimeout: idleTimeout,
+		sessions:    map[string]*Session{},
+		now:         time.Now,
+	}
+}
+
+// Create returns a new session with a new, random identifier.
+func (m *Manager) Create(ctx context.Context) *Session {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	now := m.now()
+	m.expireLocked(ctx, now)
+	id := newID()
+	log.I(ctx, "Creating session '%s'", id)
+	s := &Session{
+		ID:       id,
+		Created:  now,
+		Budget:   m.budget,
+		db:       database.NewInMemoryWithBudget(m.ctx, m.budget),
+		lastUsed: now,
+	}
+	m.sessions[id] = s
+	return s
+}
+
+// newID returns a new random session identifier.
+func newID() string {
+	id := [16]byte{}
+	if _, err := rand.Read(id[:]); err != nil {
+		panic(fmt.Errorf("rand.Read returned error: %v", err))
+	}
+	return hex.EncodeToString(id[:])
+}
+
+// Get returns the session with the given identifier, or an error if there is
+// no open session with the identifier.
+func (m *Manager) Get(ctx context.Context, id string) (*Session, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	now := m.now()
+	m.expireLocked(ctx, now)
+	s, ok := m.sessions[id]
+	if !ok {
+		return nil, &service.ErrInvalidArgument{Reason: messages.ErrSessionDoesNotExist(id)}
+	}
+	s.lastUsed = now
+	return s, nil
+}
+
+// expireLocked closes all the sessions that have not been used for the idle
+// timeout. It must be called with a locked mutex.
+func (m *Manager) expireLocked(ctx context.Context, now time.Time) {
+	if m.idleTimeout == 0 {
+		return
+	}
+	for id, s := range m.sessions {
+		if now.Sub(s.lastUsed) > m.idleTimeout {
+			log.I(ctx, "Closing idle session '%s'", id)
+			m.closeLocked(id)
+		}
+	}
+}
+
+// closeLocked removes the session with the given identifier, releasing its
+// captures. It must be called with a locked mutex.
+func (m *Manager) closeLocked(id string) {
+	capture.Release(m.sessions[id].db)
+	delete(m.sessions, id)
+}
+
+// Close closes the session with the given identifier, releasing all of its
+// captures and data. Subsequent uses of the identifier will fail.
+func (m *Manager) Close(ctx context.Context, id string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, ok := m.sessions[id]; !ok {
+		return &service.ErrInvalidArgument{Reason: messages.ErrSessionDoesNotExist(id)}
+	}
+	log.I(ctx, "Closing session '%s'", id)
+	m.closeLocked(id)
+	return nil
+}
+
+// Purge discards all the cached data of the session with the given
+// identifier, keeping its captures. Purged data will be rebuilt on next use.
+func (m *Manager) Purge(ctx context.Context, id string) error {
+	m.mutex.Lock()
+	s, ok := m.sessions[id]
+	m.mutex.Unlock()
+	if !ok {
+		return &service.ErrInvalidArgument{Reason: messages.ErrSessionDoesNotExist(id)}
+	}
+	count := database.Purge(s.db)
+	log.I(ctx, "Purged %d cached results from session '%s'", count, id)
+	return nil
+}
@@ -0,0 +1,104 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/capture"
+	"github.com/google/gapid/gapis/database"
+	"github.com/google/gapid/gapis/service"
+)
+
+func TestIsolation(t *testing.T) {
+	ctx := log.Testing(t)
+	m := NewManager(ctx, 0, 0)
+	a, b := m.Create(ctx), m.Create(ctx)
+	assert.For(ctx, "unique ids").That(a.ID == b.ID).Equals(false)
+	assert.For(ctx, "id length").That(len(a.ID)).Equals(32)
+
+	c, err := capture.Import(a.Bind(ctx), "capture", []byte{1, 2, 3})
+	assert.With(ctx).ThatError(err).Succeeded()
+
+	assert.For(ctx, "a captures").That(len(a.Service(ctx).Captures)).Equals(1)
+	assert.For(ctx, "b captures").That(len(b.Service(ctx).Captures)).Equals(0)
+	assert.For(ctx, "a contains").That(database.Contains(a.Bind(ctx), c.Id.ID())).Equals(true)
+	assert.For(ctx, "b contains").That(database.Contains(b.Bind(ctx), c.Id.ID())).Equals(false)
+	assert.For(ctx, "bound session").That(Get(a.Bind(ctx))).Equals(a)
+	assert.For(ctx, "unbound").That(Get(ctx) == nil).Equals(true)
+
+	got, err := m.Get(ctx, a.ID)
+	assert.For(ctx, "get").ThatError(err).Succeeded()
+	assert.For(ctx, "same session").That(got).Equals(a)
+}
+
+func TestGetUnknown(t *testing.T) {
+	ctx := log.Testing(t)
+	m := NewManager(ctx, 0, 0)
+	m.Create(ctx)
+	_, err := m.Get(ctx, "guessed")
+	_, isInvalid := err.(*service.ErrInvalidArgument)
+	assert.For(ctx, "client chosen id").That(isInvalid).Equals(true)
+}
+
+func TestClose(t *testing.T) {
+	ctx := log.Testing(t)
+	m := NewManager(ctx, 0, 0)
+	a := m.Create(ctx)
+	_, err := capture.Import(a.Bind(ctx), "capture", []byte{1, 2, 3})
+	assert.With(ctx).ThatError(err).Succeeded()
+
+	assert.For(ctx, "close").ThatError(m.Close(ctx, a.ID)).Succeeded()
+	_, err = m.Get(ctx, a.ID)
+	assert.For(ctx, "get closed").ThatError(err).Failed()
+	_, isInvalid := m.Close(ctx, a.ID).(*service.ErrInvalidArgument)
+	assert.For(ctx, "close again").That(isInvalid).Equals(true)
+	assert.For(ctx, "released captures").That(len(a.Service(ctx).Captures)).Equals(0)
+}
+
+func TestPurge(t *testing.T) {
+	ctx := log.Testing(t)
+	m := NewManager(ctx, 0, 0)
+	a := m.Create(ctx)
+	_, err := capture.Import(a.Bind(ctx), "capture", []byte{1, 2, 3})
+	assert.With(ctx).ThatError(err).Succeeded()
+
+	assert.For(ctx, "purge").ThatError(m.Purge(ctx, a.ID)).Succeeded()
+	assert.For(ctx, "kept captures").That(len(a.Service(ctx).Captures)).Equals(1)
+	_, isInvalid := m.Purge(ctx, "b").(*service.ErrInvalidArgument)
+	assert.For(ctx, "purge missing").That(isInvalid).Equals(true)
+}
+
+func TestIdleTimeout(t *testing.T) {
+	ctx := log.Testing(t)
+	m := NewManager(ctx, 0, time.Minute)
+	now := time.Unix(1000, 0)
+	m.now = func() time.Time { return now }
+
+	a, b := m.Create(ctx), m.Create(ctx)
+	now = now.Add(50 * time.Second)
+	m.Get(ctx, a.ID)
+	now = now.Add(50 * time.Second)
+	_, err := m.Get(ctx, a.ID)
+	assert.For(ctx, "used").ThatError(err).Succeeded()
+	_, err = m.Get(ctx, b.ID)
+	assert.For(ctx, "idle").ThatError(err).Failed()
+	now = now.Add(2 * time.Minute)
+	_, err = m.Get(ctx, a.ID)
+	assert.For(ctx, "expired").ThatError(err).Failed()
+}
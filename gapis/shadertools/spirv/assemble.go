@@ -0,0 +1,501 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spirv
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Assemble returns the binary SPIR-V module for the textual source, in the
+// format produced by Disassemble.
+//
+// Ids named in names are given the id they map to, ids written as numbers
+// (%12) keep their value and all other ids are given the next unused value.
+// The header's version, generator, bound and schema are read from the
+// comments emitted by Disassemble, if present.
+func Assemble(source string, names map[string]ID) ([]uint32, error) {
+	a := &assembler{
+		ids:         map[string]ID{},
+		types:       map[ID][]uint32{},
+		resultTypes: map[ID]ID{},
+		version:     0x10000,
+	}
+	lines := []line{}
+	for n, text := range strings.Split(source, "\n") {
+		l, err := a.tokenize(text)
+		if err != nil {
+			return nil, fmt.Errorf("Line %d: %v", n+1, err)
+		}
+		if len(l.tokens) > 0 {
+			l.number = n + 1
+			lines = append(lines, l)
+		}
+	}
+	if err := a.assignIDs(lines, names); err != nil {
+		return nil, err
+	}
+
+	words := []uint32{MagicNumber, a.version, a.generator, uint32(a.bound), a.schema}
+	for _, l := range lines {
+		inst, err := a.encode(l)
+		if err != nil {
+			return nil, fmt.Errorf("Line %d: %v", l.number, err)
+		}
+		words = append(words, inst...)
+	}
+	return words, nil
+}
+
+type assembler struct {
+	ids         map[string]ID   // Id name to value.
+	types       map[ID][]uint32 // Type instructions by result id.
+	resultTypes map[ID]ID       // Result type of each result id.
+	bound       ID
+	version     uint32
+	generator   uint32
+	schema      uint32
+}
+
+// line is a single instruction of the source, split into tokens.
+type line struct {
+	number int
+	tokens []string
+}
+
+// tokenize splits the line of source into tokens, dropping comments. Header
+// comments are parsed into the assembler's header fields.
+func (a *assembler) tokenize(text string) (line, error) {
+	l := line{}
+	for text = strings.TrimSpace(text); text != ""; text = strings.TrimSpace(text) {
+		switch text[0] {
+		case ';':
+			a.header(strings.TrimSpace(text[1:]))
+			return l, nil
+		case '"':
+			end := 1
+			for ; end < len(text) && text[end] != '"'; end++ {
+				if text[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(text) {
+				return l, fmt.Errorf("Unterminated string %s", text)
+			}
+			l.tokens, text = append(l.tokens, text[:end+1]), text[end+1:]
+		default:
+			end := strings.IndexAny(text, " \t\";")
+			if end < 0 {
+				end = len(text)
+			}
+			l.tokens, text = append(l.tokens, text[:end]), text[end:]
+		}
+	}
+	return l, nil
+}
+
+// header parses a header comment emitted by Disassemble.
+func (a *assembler) header(comment string) {
+	var major, minor, value uint32
+	switch {
+	case strings.HasPrefix(comment, "Version:"):
+		if _, err := fmt.Sscanf(comment, "Version: %d.%d", &major, &minor); err == nil {
+			a.version = major<<16 | minor<<8
+		}
+	case strings.HasPrefix(comment, "Generator:"):
+		parts := strings.Split(strings.TrimPrefix(comment, "Generator:"), ";")
+		if len(parts) != 2 {
+			return
+		}
+		name := strings.TrimSpace(parts[0])
+		vendor := -1
+		for i, g := range generators {
+			if g == name {
+				vendor = i
+			}
+		}
+		if vendor < 0 {
+			if _, err := fmt.Sscanf(name, "Unknown(%d)", &vendor); err != nil {
+				return
+			}
+		}
+		if _, err := fmt.Sscanf(strings.TrimSpace(parts[1]), "%d", &value); err == nil {
+			a.generator = uint32(vendor)<<16 | value&0xffff
+		}
+	case strings.HasPrefix(comment, "Bound:"):
+		if _, err := fmt.Sscanf(comment, "Bound: %d", &value); err == nil {
+			a.bound = ID(value)
+		}
+	case strings.HasPrefix(comment, "Schema:"):
+		if _, err := fmt.Sscanf(comment, "Schema: %d", &value); err == nil {
+			a.schema = value
+		}
+	}
+}
+
+// assignIDs gives every id name used by lines a value.
+func (a *assembler) assignIDs(lines []line, names map[string]ID) error {
+	taken := map[ID]bool{}
+	pending := []string{}
+	for _, l := range lines {
+		for _, t := range l.tokens {
+			if !strings.HasPrefix(t, "%") {
+				continue
+			}
+			name := t[1:]
+			if _, ok := a.ids[name]; ok {
+				continue
+			}
+			id, ok := names[name]
+			if !ok {
+				if n, err := strconv.ParseUint(name, 10, 32); err == nil {
+					id, ok = ID(n), true
+				}
+			}
+			if !ok || id == 0 || taken[id] {
+				pending = append(pending, name)
+				a.ids[name] = 0
+				continue
+			}
+			a.ids[name], taken[id] = id, true
+			if id >= a.bound {
+				a.bound = id + 1
+			}
+		}
+	}
+	if a.bound == 0 {
+		a.bound = 1
+	}
+	for _, name := range pending {
+		if a.bound == ^ID(0) {
+			return fmt.Errorf("Too many ids")
+		}
+		a.ids[name] = a.bound
+		a.bound++
+	}
+	return nil
+}
+
+// encode returns the words of the instruction on the line l.
+func (a *assembler) encode(l line) ([]uint32, error) {
+	e := &encoder{a: a, tokens: l.tokens}
+	if len(e.tokens) > 1 && e.tokens[1] == "=" {
+		id, err := e.id()
+		if err != nil {
+			return nil, err
+		}
+		e.result, e.pos = id, 2
+	}
+	name := e.next()
+	if !strings.HasPrefix(name, "Op") {
+		return nil, fmt.Errorf("Expected an opcode, got '%s'", name)
+	}
+	e.words = []uint32{0}
+	if op, ok := opcodes[name[2:]]; ok {
+		e.opcode = op
+		if err := e.encode(instructions[op].operands); err != nil {
+			return nil, err
+		}
+	} else if n, err := strconv.ParseUint(name[2:], 10, 16); err == nil {
+		// Unknown instruction. The operands are all raw literals.
+		e.opcode = Opcode(n)
+		for e.pos < len(e.tokens) {
+			if err := e.literal(); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		return nil, fmt.Errorf("Unknown opcode '%s'", name)
+	}
+	if e.pos < len(e.tokens) {
+		return nil, fmt.Errorf("Unexpected operand '%s'", e.tokens[e.pos])
+	}
+	if e.result != 0 && !e.usedResult {
+		return nil, fmt.Errorf("%v does not have a result", e.opcode)
+	}
+	if len(e.words) > 0xffff {
+		return nil, fmt.Errorf("%v has too many operands", e.opcode)
+	}
+	e.words[0] = uint32(len(e.words))<<16 | uint32(e.opcode)
+
+	if e.result != 0 {
+		if e.opcode.IsType() {
+			a.types[e.result] = e.words
+		}
+		a.resultTypes[e.result] = e.resultType
+	}
+	return e.words, nil
+}
+
+// encoder encodes a single instruction.
+type encoder struct {
+	a          *assembler
+	tokens     []string
+	pos        int
+	opcode     Opcode
+	words      []uint32
+	resultType ID
+	result     ID
+	usedResult bool
+	selector   ID
+}
+
+func (e *encoder) next() string {
+	if e.pos >= len(e.tokens) {
+		return ""
+	}
+	e.pos++
+	return e.tokens[e.pos-1]
+}
+
+func (e *encoder) encode(specs []operandSpec) error {
+	for _, s := range specs {
+		switch s.quantifier {
+		case one:
+			if s.kind != KindIdResult && e.pos >= len(e.tokens) {
+				return fmt.Errorf("Missing operand")
+			}
+			if err := e.operand(s.kind); err != nil {
+				return err
+			}
+		case optional:
+			if e.pos < len(e.tokens) {
+				if err := e.operand(s.kind); err != nil {
+					return err
+				}
+			}
+		case variadic:
+			for e.pos < len(e.tokens) {
+				if err := e.operand(s.kind); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (e *encoder) id() (ID, error) {
+	t := e.next()
+	if !strings.HasPrefix(t, "%") {
+		return 0, fmt.Errorf("Expected an id, got '%s'", t)
+	}
+	return e.a.ids[t[1:]], nil
+}
+
+func (e *encoder) ref() error {
+	id, err := e.id()
+	if err != nil {
+		return err
+	}
+	if e.selector == 0 {
+		e.selector = id
+	}
+	e.words = append(e.words, uint32(id))
+	return nil
+}
+
+func (e *encoder) literal() error {
+	t := e.next()
+	if v, err := strconv.ParseUint(t, 0, 32); err == nil {
+		e.words = append(e.words, uint32(v))
+		return nil
+	}
+	if v, err := strconv.ParseInt(t, 0, 32); err == nil {
+		e.words = append(e.words, uint32(v))
+		return nil
+	}
+	return fmt.Errorf("Expected an integer, got '%s'", t)
+}
+
+// number encodes a literal number with the width of the numeric type ty.
+func (e *encoder) number(ty ID) error {
+	t := e.next()
+	def := e.a.types[ty]
+	if len(def) < 3 {
+		e.pos--
+		return e.literal()
+	}
+	width := def[2]
+	count := 1
+	if width > 32 {
+		count = int(width+31) / 32
+	}
+	var bits uint64
+	var err error
+	switch {
+	case def[0]&0xffff == uint32(OpTypeFloat) && width == 32:
+		var f float64
+		f, err = strconv.ParseFloat(t, 32)
+		bits = uint64(math.Float32bits(float32(f)))
+	case def[0]&0xffff == uint32(OpTypeFloat) && width == 64:
+		var f float64
+		f, err = strconv.ParseFloat(t, 64)
+		bits = math.Float64bits(f)
+	case strings.HasPrefix(t, "-"):
+		var v int64
+		v, err = strconv.ParseInt(t, 0, 64)
+		bits = uint64(v)
+		if width < 64 {
+			bits &= 1<<width - 1
+		}
+	default:
+		bits, err = strconv.ParseUint(t, 0, 64)
+	}
+	if err != nil {
+		return fmt.Errorf("Invalid number '%s': %v", t, err)
+	}
+	for i := 0; i < count; i++ {
+		e.words = append(e.words, uint32(bits>>(32*uint(i))))
+	}
+	return nil
+}
+
+func (e *encoder) operand(kind OperandKind) error {
+	switch kind {
+	case KindIdResultType:
+		id, err := e.id()
+		if err != nil {
+			return err
+		}
+		e.resultType = id
+		e.words = append(e.words, uint32(id))
+		return nil
+	case KindIdResult:
+		if e.result == 0 {
+			return fmt.Errorf("Missing result id")
+		}
+		e.usedResult = true
+		e.words = append(e.words, uint32(e.result))
+		return nil
+	case KindIdRef:
+		return e.ref()
+	case KindLiteralString:
+		t := e.next()
+		s, err := unquote(t)
+		if err != nil {
+			return err
+		}
+		e.words = append(e.words, stringWords(s)...)
+		return nil
+	case KindLiteralNumber:
+		return e.number(e.resultType)
+	case KindExtInstNumber:
+		t := e.next()
+		for i, name := range glslStd450 {
+			if i > 0 && name == t {
+				e.words = append(e.words, uint32(i))
+				return nil
+			}
+		}
+		e.pos--
+		return e.literal()
+	case KindSpecConstantOpNumber:
+		t := e.next()
+		op, ok := opcodes[t]
+		if !ok {
+			return fmt.Errorf("Unknown OpSpecConstantOp opcode '%s'", t)
+		}
+		e.words = append(e.words, uint32(op))
+		specs := []operandSpec{}
+		for _, s := range instructions[op].operands {
+			if s.kind != KindIdResultType && s.kind != KindIdResult {
+				specs = append(specs, s)
+			}
+		}
+		return e.encode(specs)
+	case KindPairLiteralIdRef:
+		// Only used by OpSwitch, where the literal has the width of the
+		// selector's type.
+		if err := e.number(e.a.resultTypes[e.selector]); err != nil {
+			return err
+		}
+		return e.ref()
+	case KindPairIdRefLiteral:
+		if err := e.ref(); err != nil {
+			return err
+		}
+		return e.literal()
+	case KindPairIdRefIdRef:
+		if err := e.ref(); err != nil {
+			return err
+		}
+		return e.ref()
+	}
+
+	enum, ok := enums[kind]
+	if !ok {
+		return e.literal()
+	}
+	t := e.next()
+	value := uint32(0)
+	for _, part := range strings.Split(t, "|") {
+		v, ok := enum.names[part]
+		if !ok {
+			n, err := strconv.ParseUint(part, 0, 32)
+			if err != nil {
+				return fmt.Errorf("Unknown %v '%s'", enum.name, part)
+			}
+			v = uint32(n)
+		}
+		value |= v
+	}
+	e.words = append(e.words, value)
+	params := []OperandKind{}
+	if enum.mask {
+		for bit := uint(0); bit < 32; bit++ {
+			if v, ok := enum.values[1<<bit]; ok && value&(1<<bit) != 0 {
+				params = append(params, v.params...)
+			}
+		}
+	} else if v, ok := enum.values[value]; ok {
+		params = v.params
+	}
+	for _, p := range params {
+		if e.pos >= len(e.tokens) {
+			return fmt.Errorf("Missing %v parameter", enum.name)
+		}
+		if err := e.operand(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unquote returns the string literal t without its quotes and escapes.
+func unquote(t string) (string, error) {
+	if len(t) < 2 || t[0] != '"' || t[len(t)-1] != '"' {
+		return "", fmt.Errorf("Expected a string, got '%s'", t)
+	}
+	out := []byte{}
+	for i := 1; i < len(t)-1; i++ {
+		if t[i] == '\\' {
+			i++
+		}
+		out = append(out, t[i])
+	}
+	return string(out), nil
+}
+
+// stringWords returns the nul-terminated, nul-padded words of s.
+func stringWords(s string) []uint32 {
+	out := make([]uint32, len(s)/4+1)
+	for i := 0; i < len(s); i++ {
+		out[i/4] |= uint32(s[i]) << (8 * uint(i%4))
+	}
+	return out
+}
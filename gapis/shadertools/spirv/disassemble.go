@@ -0,0 +1,344 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spirv
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// resultIndent is the column at which the opcode of each instruction starts.
+const resultIndent = 15
+
+// Disassemble returns the textual disassembly of the SPIR-V module held in
+// words.
+func Disassemble(words []uint32) (string, error) {
+	m, err := Parse(words)
+	if err != nil {
+		return "", err
+	}
+	return m.Disassemble(), nil
+}
+
+// Disassemble returns the textual disassembly of the module.
+// Ids are given friendly names derived from their debug names and types.
+func (m *Module) Disassemble() string {
+	names := m.friendlyNames()
+	buf := &bytes.Buffer{}
+	fmt.Fprintln(buf, "; SPIR-V")
+	fmt.Fprintf(buf, "; Version: %d.%d\n", m.MajorVersion(), m.MinorVersion())
+	fmt.Fprintf(buf, "; Generator: %s; %d\n", generatorName(m.Generator>>16), m.Generator&0xffff)
+	fmt.Fprintf(buf, "; Bound: %d\n", m.Bound)
+	fmt.Fprintf(buf, "; Schema: %d\n", m.Schema)
+	for _, i := range m.Instructions {
+		if i.Result != 0 {
+			prefix := "%" + names[i.Result] + " = "
+			if pad := resultIndent - len(prefix); pad > 0 {
+				buf.WriteString(strings.Repeat(" ", pad))
+			}
+			buf.WriteString(prefix)
+		} else {
+			buf.WriteString(strings.Repeat(" ", resultIndent))
+		}
+		buf.WriteString(i.Opcode.String())
+		if i.ResultType != 0 {
+			buf.WriteString(" %" + names[i.ResultType])
+		}
+		for _, o := range i.Operands {
+			buf.WriteString(" ")
+			buf.WriteString(m.operandString(i, o, names))
+		}
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
+
+func generatorName(vendor uint32) string {
+	if vendor < uint32(len(generators)) {
+		return generators[vendor]
+	}
+	return fmt.Sprintf("Unknown(%d)", vendor)
+}
+
+func (m *Module) operandString(i *Instruction, o Operand, names map[ID]string) string {
+	switch o.Kind {
+	case KindIdResultType, KindIdResult, KindIdRef:
+		return "%" + names[o.ID()]
+	case KindLiteralString:
+		return quote(o.String())
+	case KindLiteralNumber:
+		ty := m.Def(i.ResultType)
+		if i.Opcode == OpSwitch {
+			ty = m.TypeOf(i.Operands[0].ID())
+		}
+		return numberString(ty, o)
+	case KindExtInstNumber:
+		if set := m.Def(i.Operands[0].ID()); set != nil && set.Operands[0].String() == "GLSL.std.450" {
+			if n := int(o.Literal()); n > 0 && n < len(glslStd450) {
+				return glslStd450[n]
+			}
+		}
+		return fmt.Sprint(o.Literal())
+	case KindSpecConstantOpNumber:
+		return strings.TrimPrefix(Opcode(o.Literal()).String(), "Op")
+	}
+	if enum, ok := enums[o.Kind]; ok {
+		return enum.valueString(o.Literal())
+	}
+	return fmt.Sprint(o.Literal())
+}
+
+func (e *enumKind) valueString(value uint32) string {
+	if !e.mask {
+		if v, ok := e.values[value]; ok {
+			return v.name
+		}
+		return fmt.Sprint(value)
+	}
+	if value == 0 {
+		return e.values[0].name
+	}
+	parts := []string{}
+	for bit := uint(0); bit < 32; bit++ {
+		if value&(1<<bit) == 0 {
+			continue
+		}
+		if v, ok := e.values[1<<bit]; ok {
+			parts = append(parts, v.name)
+		} else {
+			parts = append(parts, fmt.Sprintf("0x%x", uint32(1<<bit)))
+		}
+	}
+	return strings.Join(parts, "|")
+}
+
+// numberString returns the literal number o formatted for the numeric type ty.
+func numberString(ty *Instruction, o Operand) string {
+	if ty == nil || len(ty.Words) < 3 {
+		return fmt.Sprint(o.Literal64())
+	}
+	width := ty.Words[2]
+	switch ty.Opcode {
+	case OpTypeInt:
+		if len(ty.Words) > 3 && ty.Words[3] != 0 {
+			v := o.Literal64()
+			if width < 64 {
+				// Sign extend from the type's width.
+				shift := 64 - width
+				return fmt.Sprint(int64(v<<shift) >> shift)
+			}
+			return fmt.Sprint(int64(v))
+		}
+		return fmt.Sprint(o.Literal64())
+	case OpTypeFloat:
+		switch width {
+		case 32:
+			return strconv.FormatFloat(float64(math.Float32frombits(o.Literal())), 'g', -1, 32)
+		case 64:
+			return strconv.FormatFloat(math.Float64frombits(o.Literal64()), 'g', -1, 64)
+		}
+		return fmt.Sprintf("0x%x", o.Literal64())
+	}
+	return fmt.Sprint(o.Literal64())
+}
+
+func quote(s string) string {
+	buf := &bytes.Buffer{}
+	buf.WriteRune('"')
+	for _, r := range s {
+		if r == '"' || r == '\\' {
+			buf.WriteRune('\\')
+		}
+		buf.WriteRune(r)
+	}
+	buf.WriteRune('"')
+	return buf.String()
+}
+
+// friendlyNames returns a unique name for each of the ids defined or named by
+// the module. Names are taken from the OpName debug instructions, or are
+// derived from the type or value for types and constants.
+func (m *Module) friendlyNames() map[ID]string {
+	names := map[ID]string{}
+	used := map[string]bool{}
+	assign := func(id ID, name string) {
+		if _, ok := names[id]; ok {
+			return
+		}
+		unique := name
+		for i := 0; used[unique]; i++ {
+			unique = fmt.Sprintf("%s_%d", name, i)
+		}
+		used[unique] = true
+		names[id] = unique
+	}
+	name := func(id ID) string {
+		if n, ok := names[id]; ok {
+			return n
+		}
+		return fmt.Sprint(uint32(id))
+	}
+
+	for _, i := range m.Instructions {
+		if i.Opcode == OpName {
+			assign(i.Operands[0].ID(), sanitize(i.Operands[1].String()))
+		}
+	}
+	for _, i := range m.Instructions {
+		if i.Result == 0 {
+			continue
+		}
+		if n := m.derivedName(i, name); n != "" {
+			assign(i.Result, n)
+		}
+	}
+	// Remaining ids use their numeric value. Only the ids that are used are
+	// named, as the header's bound is not to be trusted.
+	for _, i := range m.Instructions {
+		for _, id := range i.ids() {
+			assign(id, fmt.Sprint(uint32(id)))
+		}
+	}
+	return names
+}
+
+// Names returns the ids of the module keyed by the names given to them by
+// Disassemble. Passing these to Assemble keeps the ids of a disassembled module
+// unchanged.
+func (m *Module) Names() map[string]ID {
+	out := map[string]ID{}
+	for id, name := range m.friendlyNames() {
+		out[name] = id
+	}
+	return out
+}
+
+// ids returns all the ids defined or referenced by the instruction.
+func (i *Instruction) ids() []ID {
+	out := []ID{}
+	if i.ResultType != 0 {
+		out = append(out, i.ResultType)
+	}
+	if i.Result != 0 {
+		out = append(out, i.Result)
+	}
+	for _, o := range i.Operands {
+		if o.Kind.IsID() {
+			out = append(out, o.ID())
+		}
+	}
+	return out
+}
+
+// derivedName returns the friendly name for the type or constant declared by
+// i, or an empty string if i is not a type or a constant.
+func (m *Module) derivedName(i *Instruction, name func(ID) string) string {
+	lit := func(n int) uint32 { return i.Operands[n].Literal() }
+	switch i.Opcode {
+	case OpTypeVoid:
+		return "void"
+	case OpTypeBool:
+		return "bool"
+	case OpTypeInt:
+		prefix := "u"
+		if lit(1) != 0 {
+			prefix = ""
+		}
+		switch lit(0) {
+		case 8:
+			return prefix + "char"
+		case 16:
+			return prefix + "short"
+		case 32:
+			return prefix + "int"
+		case 64:
+			return prefix + "long"
+		}
+		if prefix == "" {
+			prefix = "i"
+		}
+		return fmt.Sprintf("%s%d", prefix, lit(0))
+	case OpTypeFloat:
+		switch lit(0) {
+		case 16:
+			return "half"
+		case 32:
+			return "float"
+		case 64:
+			return "double"
+		}
+		return fmt.Sprintf("fp%d", lit(0))
+	case OpTypeVector:
+		return fmt.Sprintf("v%d%s", lit(1), name(i.Operands[0].ID()))
+	case OpTypeMatrix:
+		return fmt.Sprintf("mat%d%s", lit(1), name(i.Operands[0].ID()))
+	case OpTypeArray:
+		return fmt.Sprintf("_arr_%s_%s", name(i.Operands[0].ID()), name(i.Operands[1].ID()))
+	case OpTypeRuntimeArray:
+		return "_runtimearr_" + name(i.Operands[0].ID())
+	case OpTypePointer:
+		return fmt.Sprintf("_ptr_%s_%s", enums[KindStorageClass].valueString(lit(0)), name(i.Operands[1].ID()))
+	case OpTypeStruct:
+		return fmt.Sprintf("_struct_%d", uint32(i.Result))
+	case OpTypeImage:
+		return "type_image"
+	case OpTypeSampler:
+		return "type_sampler"
+	case OpTypeSampledImage:
+		return "type_sampled_image"
+	case OpTypeOpaque:
+		return "Opaque_" + sanitize(i.Operands[0].String())
+	case OpTypeEvent:
+		return "Event"
+	case OpTypeDeviceEvent:
+		return "DeviceEvent"
+	case OpTypeReserveId:
+		return "ReserveId"
+	case OpTypeQueue:
+		return "Queue"
+	case OpTypePipe:
+		return "Pipe" + enums[KindAccessQualifier].valueString(lit(0))
+	case OpTypePipeStorage:
+		return "PipeStorage"
+	case OpTypeNamedBarrier:
+		return "NamedBarrier"
+	case OpConstantTrue:
+		return "true"
+	case OpConstantFalse:
+		return "false"
+	case OpConstant:
+		value := numberString(m.Def(i.ResultType), i.Operands[0])
+		value = strings.NewReplacer("-", "n", ".", "_", "+", "p").Replace(value)
+		return fmt.Sprintf("%s_%s", name(i.ResultType), value)
+	}
+	return ""
+}
+
+// sanitize replaces all the characters in s that are not valid in an id name.
+func sanitize(s string) string {
+	if s == "" {
+		return "_"
+	}
+	out := []byte(s)
+	for i, c := range out {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			out[i] = '_'
+		}
+	}
+	return string(out)
+}
@@ -0,0 +1,984 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spirv
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// OperandKind is the kind of an instruction operand.
+type OperandKind int
+
+// The operand kinds of the SPIR-V grammar.
+const (
+	KindIdResultType OperandKind = iota
+	KindIdResult
+	KindIdRef
+	KindLiteralInteger
+	KindLiteralString
+	KindLiteralNumber // Literal whose width depends on the type of the instruction.
+	KindExtInstNumber
+	KindSpecConstantOpNumber
+	KindPairLiteralIdRef
+	KindPairIdRefLiteral
+	KindPairIdRefIdRef
+
+	// Value enumerants.
+	KindSourceLanguage
+	KindExecutionModel
+	KindAddressingModel
+	KindMemoryModel
+	KindExecutionMode
+	KindStorageClass
+	KindDim
+	KindSamplerAddressingMode
+	KindSamplerFilterMode
+	KindImageFormat
+	KindAccessQualifier
+	KindFunctionParameterAttribute
+	KindFPRoundingMode
+	KindLinkageType
+	KindDecoration
+	KindBuiltIn
+	KindGroupOperation
+	KindCapability
+
+	// Bit-mask enumerants.
+	KindImageOperands
+	KindFPFastMathMode
+	KindSelectionControl
+	KindLoopControl
+	KindFunctionControl
+	KindMemoryAccess
+)
+
+// IsID returns true if the operand kind is a reference to an id.
+func (k OperandKind) IsID() bool {
+	return k == KindIdResultType || k == KindIdResult || k == KindIdRef
+}
+
+// quantifier is the number of times an operand can appear.
+type quantifier int
+
+const (
+	one quantifier = iota
+	optional
+	variadic
+)
+
+type operandSpec struct {
+	kind       OperandKind
+	quantifier quantifier
+}
+
+type instructionInfo struct {
+	name     string
+	operands []operandSpec
+}
+
+type enumValue struct {
+	name   string
+	params []OperandKind
+}
+
+type enumKind struct {
+	name   string
+	mask   bool
+	def    string
+	values map[uint32]enumValue
+	names  map[string]uint32
+}
+
+var (
+	instructions = map[Opcode]*instructionInfo{}
+	opcodes      = map[string]Opcode{}
+	enums        = map[OperandKind]*enumKind{}
+	kindNames    = map[string]OperandKind{
+		"T":         KindIdResultType,
+		"R":         KindIdResult,
+		"Id":        KindIdRef,
+		"Lit":       KindLiteralInteger,
+		"Str":       KindLiteralString,
+		"Num":       KindLiteralNumber,
+		"ExtInst":   KindExtInstNumber,
+		"SpecOp":    KindSpecConstantOpNumber,
+		"PairLitId": KindPairLiteralIdRef,
+		"PairIdLit": KindPairIdRefLiteral,
+		"PairIdId":  KindPairIdRefIdRef,
+	}
+)
+
+func init() {
+	for _, e := range []struct {
+		kind OperandKind
+		name string
+		mask bool
+		def  string
+	}{
+		{KindSourceLanguage, "SourceLanguage", false, sourceLanguages},
+		{KindExecutionModel, "ExecutionModel", false, executionModels},
+		{KindAddressingModel, "AddressingModel", false, addressingModels},
+		{KindMemoryModel, "MemoryModel", false, memoryModels},
+		{KindExecutionMode, "ExecutionMode", false, executionModes},
+		{KindStorageClass, "StorageClass", false, storageClasses},
+		{KindDim, "Dim", false, dims},
+		{KindSamplerAddressingMode, "SamplerAddressingMode", false, samplerAddressingModes},
+		{KindSamplerFilterMode, "SamplerFilterMode", false, samplerFilterModes},
+		{KindImageFormat, "ImageFormat", false, imageFormats},
+		{KindAccessQualifier, "AccessQualifier", false, accessQualifiers},
+		{KindFunctionParameterAttribute, "FunctionParameterAttribute", false, functionParameterAttributes},
+		{KindFPRoundingMode, "FPRoundingMode", false, fpRoundingModes},
+		{KindLinkageType, "LinkageType", false, linkageTypes},
+		{KindDecoration, "Decoration", false, decorations},
+		{KindBuiltIn, "BuiltIn", false, builtIns},
+		{KindGroupOperation, "GroupOperation", false, groupOperations},
+		{KindCapability, "Capability", false, capabilities},
+		{KindImageOperands, "ImageOperands", true, imageOperands},
+		{KindFPFastMathMode, "FPFastMathMode", true, fpFastMathModes},
+		{KindSelectionControl, "SelectionControl", true, selectionControls},
+		{KindLoopControl, "LoopControl", true, loopControls},
+		{KindFunctionControl, "FunctionControl", true, functionControls},
+		{KindMemoryAccess, "MemoryAccess", true, memoryAccesses},
+	} {
+		kindNames[e.name] = e.kind
+		enums[e.kind] = &enumKind{name: e.name, mask: e.mask, def: e.def}
+	}
+	// Enumerant parameters may refer to other enums, so parse once all the
+	// enums are registered.
+	for _, enum := range enums {
+		enum.values = map[uint32]enumValue{}
+		enum.names = map[string]uint32{}
+		for _, fields := range grammarLines(enum.def) {
+			value, err := strconv.ParseUint(fields[0], 0, 32)
+			if err != nil {
+				panic(err)
+			}
+			enum.values[uint32(value)] = enumValue{fields[1], parseKinds(fields[2:])}
+			enum.names[fields[1]] = uint32(value)
+		}
+	}
+	for _, fields := range grammarLines(instructionGrammar) {
+		opcode, err := strconv.ParseUint(fields[0], 10, 16)
+		if err != nil {
+			panic(err)
+		}
+		info := &instructionInfo{name: fields[1]}
+		for _, f := range fields[2:] {
+			spec := operandSpec{quantifier: one}
+			switch {
+			case strings.HasSuffix(f, "?"):
+				spec.quantifier, f = optional, f[:len(f)-1]
+			case strings.HasSuffix(f, "*"):
+				spec.quantifier, f = variadic, f[:len(f)-1]
+			}
+			spec.kind = parseKinds([]string{f})[0]
+			info.operands = append(info.operands, spec)
+		}
+		instructions[Opcode(opcode)] = info
+		opcodes[info.name] = Opcode(opcode)
+	}
+}
+
+func grammarLines(def string) [][]string {
+	out := [][]string{}
+	for _, line := range strings.Split(def, "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			out = append(out, fields)
+		}
+	}
+	return out
+}
+
+func parseKinds(names []string) []OperandKind {
+	out := make([]OperandKind, len(names))
+	for i, n := range names {
+		kind, ok := kindNames[n]
+		if !ok {
+			panic(fmt.Errorf("Unknown operand kind '%s'", n))
+		}
+		out[i] = kind
+	}
+	return out
+}
+
+// The grammar of each instruction: opcode, name and operands.
+// Operands suffixed with '?' are optional and those suffixed with '*' may
+// appear any number of times.
+const instructionGrammar = `
+0 Nop
+1 Undef T R
+2 SourceContinued Str
+3 Source SourceLanguage Lit Id? Str?
+4 SourceExtension Str
+5 Name Id Str
+6 MemberName Id Lit Str
+7 String R Str
+8 Line Id Lit Lit
+10 Extension Str
+11 ExtInstImport R Str
+12 ExtInst T R Id ExtInst Id*
+14 MemoryModel AddressingModel MemoryModel
+15 EntryPoint ExecutionModel Id Str Id*
+16 ExecutionMode Id ExecutionMode
+17 Capability Capability
+19 TypeVoid R
+20 TypeBool R
+21 TypeInt R Lit Lit
+22 TypeFloat R Lit
+23 TypeVector R Id Lit
+24 TypeMatrix R Id Lit
+25 TypeImage R Id Dim Lit Lit Lit Lit ImageFormat AccessQualifier?
+26 TypeSampler R
+27 TypeSampledImage R Id
+28 TypeArray R Id Id
+29 TypeRuntimeArray R Id
+30 TypeStruct R Id*
+31 TypeOpaque R Str
+32 TypePointer R StorageClass Id
+33 TypeFunction R Id Id*
+34 TypeEvent R
+35 TypeDeviceEvent R
+36 TypeReserveId R
+37 TypeQueue R
+38 TypePipe R AccessQualifier
+39 TypeForwardPointer Id StorageClass
+41 ConstantTrue T R
+42 ConstantFalse T R
+43 Constant T R Num
+44 ConstantComposite T R Id*
+45 ConstantSampler T R SamplerAddressingMode Lit SamplerFilterMode
+46 ConstantNull T R
+48 SpecConstantTrue T R
+49 SpecConstantFalse T R
+50 SpecConstant T R Num
+51 SpecConstantComposite T R Id*
+52 SpecConstantOp T R SpecOp
+54 Function T R FunctionControl Id
+55 FunctionParameter T R
+56 FunctionEnd
+57 FunctionCall T R Id Id*
+59 Variable T R StorageClass Id?
+60 ImageTexelPointer T R Id Id Id
+61 Load T R Id MemoryAccess?
+62 Store Id Id MemoryAccess?
+63 CopyMemory Id Id MemoryAccess?
+64 CopyMemorySized Id Id Id MemoryAccess?
+65 AccessChain T R Id Id*
+66 InBoundsAccessChain T R Id Id*
+67 PtrAccessChain T R Id Id Id*
+68 ArrayLength T R Id Lit
+69 GenericPtrMemSemantics T R Id
+70 InBoundsPtrAccessChain T R Id Id Id*
+71 Decorate Id Decoration
+72 MemberDecorate Id Lit Decoration
+73 DecorationGroup R
+74 GroupDecorate Id Id*
+75 GroupMemberDecorate Id PairIdLit*
+77 VectorExtractDynamic T R Id Id
+78 VectorInsertDynamic T R Id Id Id
+79 VectorShuffle T R Id Id Lit*
+80 CompositeConstruct T R Id*
+81 CompositeExtract T R Id Lit*
+82 CompositeInsert T R Id Id Lit*
+83 CopyObject T R Id
+84 Transpose T R Id
+86 SampledImage T R Id Id
+87 ImageSampleImplicitLod T R Id Id ImageOperands?
+88 ImageSampleExplicitLod T R Id Id ImageOperands
+89 ImageSampleDrefImplicitLod T R Id Id Id ImageOperands?
+90 ImageSampleDrefExplicitLod T R Id Id Id ImageOperands
+91 ImageSampleProjImplicitLod T R Id Id ImageOperands?
+92 ImageSampleProjExplicitLod T R Id Id ImageOperands
+93 ImageSampleProjDrefImplicitLod T R Id Id Id ImageOperands?
+94 ImageSampleProjDrefExplicitLod T R Id Id Id ImageOperands
+95 ImageFetch T R Id Id ImageOperands?
+96 ImageGather T R Id Id Id ImageOperands?
+97 ImageDrefGather T R Id Id Id ImageOperands?
+98 ImageRead T R Id Id ImageOperands?
+99 ImageWrite Id Id Id ImageOperands?
+100 Image T R Id
+101 ImageQueryFormat T R Id
+102 ImageQueryOrder T R Id
+103 ImageQuerySizeLod T R Id Id
+104 ImageQuerySize T R Id
+105 ImageQueryLod T R Id Id
+106 ImageQueryLevels T R Id
+107 ImageQuerySamples T R Id
+109 ConvertFToU T R Id
+110 ConvertFToS T R Id
+111 ConvertSToF T R Id
+112 ConvertUToF T R Id
+113 UConvert T R Id
+114 SConvert T R Id
+115 FConvert T R Id
+116 QuantizeToF16 T R Id
+117 ConvertPtrToU T R Id
+118 SatConvertSToU T R Id
+119 SatConvertUToS T R Id
+120 ConvertUToPtr T R Id
+121 PtrCastToGeneric T R Id
+122 GenericCastToPtr T R Id
+123 GenericCastToPtrExplicit T R Id StorageClass
+124 Bitcast T R Id
+126 SNegate T R Id
+127 FNegate T R Id
+128 IAdd T R Id Id
+129 FAdd T R Id Id
+130 ISub T R Id Id
+131 FSub T R Id Id
+132 IMul T R Id Id
+133 FMul T R Id Id
+134 UDiv T R Id Id
+135 SDiv T R Id Id
+136 FDiv T R Id Id
+137 UMod T R Id Id
+138 SRem T R Id Id
+139 SMod T R Id Id
+140 FRem T R Id Id
+141 FMod T R Id Id
+142 VectorTimesScalar T R Id Id
+143 MatrixTimesScalar T R Id Id
+144 VectorTimesMatrix T R Id Id
+145 MatrixTimesVector T R Id Id
+146 MatrixTimesMatrix T R Id Id
+147 OuterProduct T R Id Id
+148 Dot T R Id Id
+149 IAddCarry T R Id Id
+150 ISubBorrow T R Id Id
+151 UMulExtended T R Id Id
+152 SMulExtended T R Id Id
+154 Any T R Id
+155 All T R Id
+156 IsNan T R Id
+157 IsInf T R Id
+158 IsFinite T R Id
+159 IsNormal T R Id
+160 SignBitSet T R Id
+161 LessOrGreater T R Id Id
+162 Ordered T R Id Id
+163 Unordered T R Id Id
+164 LogicalEqual T R Id Id
+165 LogicalNotEqual T R Id Id
+166 LogicalOr T R Id Id
+167 LogicalAnd T R Id Id
+168 LogicalNot T R Id
+169 Select T R Id Id Id
+170 IEqual T R Id Id
+171 INotEqual T R Id Id
+172 UGreaterThan T R Id Id
+173 SGreaterThan T R Id Id
+174 UGreaterThanEqual T R Id Id
+175 SGreaterThanEqual T R Id Id
+176 ULessThan T R Id Id
+177 SLessThan T R Id Id
+178 ULessThanEqual T R Id Id
+179 SLessThanEqual T R Id Id
+180 FOrdEqual T R Id Id
+181 FUnordEqual T R Id Id
+182 FOrdNotEqual T R Id Id
+183 FUnordNotEqual T R Id Id
+184 FOrdLessThan T R Id Id
+185 FUnordLessThan T R Id Id
+186 FOrdGreaterThan T R Id Id
+187 FUnordGreaterThan T R Id Id
+188 FOrdLessThanEqual T R Id Id
+189 FUnordLessThanEqual T R Id Id
+190 FOrdGreaterThanEqual T R Id Id
+191 FUnordGreaterThanEqual T R Id Id
+194 ShiftRightLogical T R Id Id
+195 ShiftRightArithmetic T R Id Id
+196 ShiftLeftLogical T R Id Id
+197 BitwiseOr T R Id Id
+198 BitwiseXor T R Id Id
+199 BitwiseAnd T R Id Id
+200 Not T R Id
+201 BitFieldInsert T R Id Id Id Id
+202 BitFieldSExtract T R Id Id Id
+203 BitFieldUExtract T R Id Id Id
+204 BitReverse T R Id
+205 BitCount T R Id
+207 DPdx T R Id
+208 DPdy T R Id
+209 Fwidth T R Id
+210 DPdxFine T R Id
+211 DPdyFine T R Id
+212 FwidthFine T R Id
+213 DPdxCoarse T R Id
+214 DPdyCoarse T R Id
+215 FwidthCoarse T R Id
+218 EmitVertex
+219 EndPrimitive
+220 EmitStreamVertex Id
+221 EndStreamPrimitive Id
+224 ControlBarrier Id Id Id
+225 MemoryBarrier Id Id
+227 AtomicLoad T R Id Id Id
+228 AtomicStore Id Id Id Id
+229 AtomicExchange T R Id Id Id Id
+230 AtomicCompareExchange T R Id Id Id Id Id Id
+231 AtomicCompareExchangeWeak T R Id Id Id Id Id Id
+232 AtomicIIncrement T R Id Id Id
+233 AtomicIDecrement T R Id Id Id
+234 AtomicIAdd T R Id Id Id Id
+235 AtomicISub T R Id Id Id Id
+236 AtomicSMin T R Id Id Id Id
+237 AtomicUMin T R Id Id Id Id
+238 AtomicSMax T R Id Id Id Id
+239 AtomicUMax T R Id Id Id Id
+240 AtomicAnd T R Id Id Id Id
+241 AtomicOr T R Id Id Id Id
+242 AtomicXor T R Id Id Id Id
+245 Phi T R PairIdId*
+246 LoopMerge Id Id LoopControl
+247 SelectionMerge Id SelectionControl
+248 Label R
+249 Branch Id
+250 BranchConditional Id Id Id Lit*
+251 Switch Id Id PairLitId*
+252 Kill
+253 Return
+254 ReturnValue Id
+255 Unreachable
+256 LifetimeStart Id Lit
+257 LifetimeStop Id Lit
+259 GroupAsyncCopy T R Id Id Id Id Id Id
+260 GroupWaitEvents Id Id Id
+261 GroupAll T R Id Id
+262 GroupAny T R Id Id
+263 GroupBroadcast T R Id Id Id
+264 GroupIAdd T R Id GroupOperation Id
+265 GroupFAdd T R Id GroupOperation Id
+266 GroupFMin T R Id GroupOperation Id
+267 GroupUMin T R Id GroupOperation Id
+268 GroupSMin T R Id GroupOperation Id
+269 GroupFMax T R Id GroupOperation Id
+270 GroupUMax T R Id GroupOperation Id
+271 GroupSMax T R Id GroupOperation Id
+274 ReadPipe T R Id Id Id Id
+275 WritePipe T R Id Id Id Id
+276 ReservedReadPipe T R Id Id Id Id Id Id
+277 ReservedWritePipe T R Id Id Id Id Id Id
+278 ReserveReadPipePackets T R Id Id Id Id
+279 ReserveWritePipePackets T R Id Id Id Id
+280 CommitReadPipe Id Id Id Id
+281 CommitWritePipe Id Id Id Id
+282 IsValidReserveId T R Id
+283 GetNumPipePackets T R Id Id Id
+284 GetMaxPipePackets T R Id Id Id
+285 GroupReserveReadPipePackets T R Id Id Id Id Id
+286 GroupReserveWritePipePackets T R Id Id Id Id Id
+287 GroupCommitReadPipe Id Id Id Id Id
+288 GroupCommitWritePipe Id Id Id Id Id
+291 EnqueueMarker T R Id Id Id Id
+292 EnqueueKernel T R Id Id Id Id Id Id Id Id Id Id Id*
+293 GetKernelNDrangeSubGroupCount T R Id Id Id Id Id
+294 GetKernelNDrangeMaxSubGroupSize T R Id Id Id Id Id
+295 GetKernelWorkGroupSize T R Id Id Id Id
+296 GetKernelPreferredWorkGroupSizeMultiple T R Id Id Id Id
+297 RetainEvent Id
+298 ReleaseEvent Id
+299 CreateUserEvent T R
+300 IsValidEvent T R Id
+301 SetUserEventStatus Id Id
+302 CaptureEventProfilingInfo Id Id Id
+303 GetDefaultQueue T R
+304 BuildNDRange T R Id Id Id
+305 ImageSparseSampleImplicitLod T R Id Id ImageOperands?
+306 ImageSparseSampleExplicitLod T R Id Id ImageOperands
+307 ImageSparseSampleDrefImplicitLod T R Id Id Id ImageOperands?
+308 ImageSparseSampleDrefExplicitLod T R Id Id Id ImageOperands
+309 ImageSparseSampleProjImplicitLod T R Id Id ImageOperands?
+310 ImageSparseSampleProjExplicitLod T R Id Id ImageOperands
+311 ImageSparseSampleProjDrefImplicitLod T R Id Id Id ImageOperands?
+312 ImageSparseSampleProjDrefExplicitLod T R Id Id Id ImageOperands
+313 ImageSparseFetch T R Id Id ImageOperands?
+314 ImageSparseGather T R Id Id Id ImageOperands?
+315 ImageSparseDrefGather T R Id Id Id ImageOperands?
+316 ImageSparseTexelsResident T R Id
+317 NoLine
+318 AtomicFlagTestAndSet T R Id Id Id
+319 AtomicFlagClear Id Id Id
+320 ImageSparseRead T R Id Id ImageOperands?
+321 SizeOf T R Id
+322 TypePipeStorage R
+323 ConstantPipeStorage T R Lit Lit Lit
+324 CreatePipeFromPipeStorage T R Id
+325 GetKernelLocalSizeForSubgroupCount T R Id Id Id Id Id
+326 GetKernelMaxNumSubgroups T R Id Id Id Id
+327 TypeNamedBarrier R
+328 NamedBarrierInitialize T R Id
+329 MemoryNamedBarrier Id Id Id
+330 ModuleProcessed Str
+331 ExecutionModeId Id ExecutionMode
+332 DecorateId Id Decoration
+4421 SubgroupBallotKHR T R Id
+4422 SubgroupFirstInvocationKHR T R Id
+4428 SubgroupAllKHR T R Id
+4429 SubgroupAnyKHR T R Id
+4430 SubgroupAllEqualKHR T R Id
+4432 SubgroupReadInvocationKHR T R Id Id
+`
+
+const sourceLanguages = `
+0 Unknown
+1 ESSL
+2 GLSL
+3 OpenCL_C
+4 OpenCL_CPP
+5 HLSL
+`
+
+const executionModels = `
+0 Vertex
+1 TessellationControl
+2 TessellationEvaluation
+3 Geometry
+4 Fragment
+5 GLCompute
+6 Kernel
+`
+
+const addressingModels = `
+0 Logical
+1 Physical32
+2 Physical64
+`
+
+const memoryModels = `
+0 Simple
+1 GLSL450
+2 OpenCL
+`
+
+const executionModes = `
+0 Invocations Lit
+1 SpacingEqual
+2 SpacingFractionalEven
+3 SpacingFractionalOdd
+4 VertexOrderCw
+5 VertexOrderCcw
+6 PixelCenterInteger
+7 OriginUpperLeft
+8 OriginLowerLeft
+9 EarlyFragmentTests
+10 PointMode
+11 Xfb
+12 DepthReplacing
+14 DepthGreater
+15 DepthLess
+16 DepthUnchanged
+17 LocalSize Lit Lit Lit
+18 LocalSizeHint Lit Lit Lit
+19 InputPoints
+20 InputLines
+21 InputLinesAdjacency
+22 Triangles
+23 InputTrianglesAdjacency
+24 Quads
+25 Isolines
+26 OutputVertices Lit
+27 OutputPoints
+28 OutputLineStrip
+29 OutputTriangleStrip
+30 VecTypeHint Lit
+31 ContractionOff
+33 Initializer
+34 Finalizer
+35 SubgroupSize Lit
+36 SubgroupsPerWorkgroup Lit
+37 SubgroupsPerWorkgroupId Id
+38 LocalSizeId Id Id Id
+39 LocalSizeHintId Id
+`
+
+const storageClasses = `
+0 UniformConstant
+1 Input
+2 Uniform
+3 Output
+4 Workgroup
+5 CrossWorkgroup
+6 Private
+7 Function
+8 Generic
+9 PushConstant
+10 AtomicCounter
+11 Image
+12 StorageBuffer
+`
+
+const dims = `
+0 1D
+1 2D
+2 3D
+3 Cube
+4 Rect
+5 Buffer
+6 SubpassData
+`
+
+const samplerAddressingModes = `
+0 None
+1 ClampToEdge
+2 Clamp
+3 Repeat
+4 RepeatMirrored
+`
+
+const samplerFilterModes = `
+0 Nearest
+1 Linear
+`
+
+const imageFormats = `
+0 Unknown
+1 Rgba32f
+2 Rgba16f
+3 R32f
+4 Rgba8
+5 Rgba8Snorm
+6 Rg32f
+7 Rg16f
+8 R11fG11fB10f
+9 R16f
+10 Rgba16
+11 Rgb10A2
+12 Rg16
+13 Rg8
+14 R16
+15 R8
+16 Rgba16Snorm
+17 Rg16Snorm
+18 Rg8Snorm
+19 R16Snorm
+20 R8Snorm
+21 Rgba32i
+22 Rgba16i
+23 Rgba8i
+24 R32i
+25 Rg32i
+26 Rg16i
+27 Rg8i
+28 R16i
+29 R8i
+30 Rgba32ui
+31 Rgba16ui
+32 Rgba8ui
+33 R32ui
+34 Rgb10a2ui
+35 Rg32ui
+36 Rg16ui
+37 Rg8ui
+38 R16ui
+39 R8ui
+`
+
+const accessQualifiers = `
+0 ReadOnly
+1 WriteOnly
+2 ReadWrite
+`
+
+const functionParameterAttributes = `
+0 Zext
+1 Sext
+2 ByVal
+3 Sret
+4 NoAlias
+5 NoCapture
+6 NoWrite
+7 NoReadWrite
+`
+
+const fpRoundingModes = `
+0 RTE
+1 RTZ
+2 RTP
+3 RTN
+`
+
+const linkageTypes = `
+0 Export
+1 Import
+`
+
+const decorations = `
+0 RelaxedPrecision
+1 SpecId Lit
+2 Block
+3 BufferBlock
+4 RowMajor
+5 ColMajor
+6 ArrayStride Lit
+7 MatrixStride Lit
+8 GLSLShared
+9 GLSLPacked
+10 CPacked
+11 BuiltIn BuiltIn
+13 NoPerspective
+14 Flat
+15 Patch
+16 Centroid
+17 Sample
+18 Invariant
+19 Restrict
+20 Aliased
+21 Volatile
+22 Constant
+23 Coherent
+24 NonWritable
+25 NonReadable
+26 Uniform
+28 SaturatedConversion
+29 Stream Lit
+30 Location Lit
+31 Component Lit
+32 Index Lit
+33 Binding Lit
+34 DescriptorSet Lit
+35 Offset Lit
+36 XfbBuffer Lit
+37 XfbStride Lit
+38 FuncParamAttr FunctionParameterAttribute
+39 FPRoundingMode FPRoundingMode
+40 FPFastMathMode FPFastMathMode
+41 LinkageAttributes Str LinkageType
+42 NoContraction
+43 InputAttachmentIndex Lit
+44 Alignment Lit
+45 MaxByteOffset Lit
+46 AlignmentId Id
+47 MaxByteOffsetId Id
+`
+
+const builtIns = `
+0 Position
+1 PointSize
+3 ClipDistance
+4 CullDistance
+5 VertexId
+6 InstanceId
+7 PrimitiveId
+8 InvocationId
+9 Layer
+10 ViewportIndex
+11 TessLevelOuter
+12 TessLevelInner
+13 TessCoord
+14 PatchVertices
+15 FragCoord
+16 PointCoord
+17 FrontFacing
+18 SampleId
+19 SamplePosition
+20 SampleMask
+22 FragDepth
+23 HelperInvocation
+24 NumWorkgroups
+25 WorkgroupSize
+26 WorkgroupId
+27 LocalInvocationId
+28 GlobalInvocationId
+29 LocalInvocationIndex
+30 WorkDim
+31 GlobalSize
+32 EnqueuedWorkgroupSize
+33 GlobalOffset
+34 GlobalLinearId
+36 SubgroupSize
+37 SubgroupMaxSize
+38 NumSubgroups
+39 NumEnqueuedSubgroups
+40 SubgroupId
+41 SubgroupLocalInvocationId
+42 VertexIndex
+43 InstanceIndex
+4416 SubgroupEqMaskKHR
+4417 SubgroupGeMaskKHR
+4418 SubgroupGtMaskKHR
+4419 SubgroupLeMaskKHR
+4420 SubgroupLtMaskKHR
+4424 BaseVertex
+4425 BaseInstance
+4426 DrawIndex
+4438 DeviceIndex
+4440 ViewIndex
+`
+
+const groupOperations = `
+0 Reduce
+1 InclusiveScan
+2 ExclusiveScan
+`
+
+const capabilities = `
+0 Matrix
+1 Shader
+2 Geometry
+3 Tessellation
+4 Addresses
+5 Linkage
+6 Kernel
+7 Vector16
+8 Float16Buffer
+9 Float16
+10 Float64
+11 Int64
+12 Int64Atomics
+13 ImageBasic
+14 ImageReadWrite
+15 ImageMipmap
+17 Pipes
+18 Groups
+19 DeviceEnqueue
+20 LiteralSampler
+21 AtomicStorage
+22 Int16
+23 TessellationPointSize
+24 GeometryPointSize
+25 ImageGatherExtended
+27 StorageImageMultisample
+28 UniformBufferArrayDynamicIndexing
+29 SampledImageArrayDynamicIndexing
+30 StorageBufferArrayDynamicIndexing
+31 StorageImageArrayDynamicIndexing
+32 ClipDistance
+33 CullDistance
+34 ImageCubeArray
+35 SampleRateShading
+36 ImageRect
+37 SampledRect
+38 GenericPointer
+39 Int8
+40 InputAttachment
+41 SparseResidency
+42 MinLod
+43 Sampled1D
+44 Image1D
+45 SampledCubeArray
+46 SampledBuffer
+47 ImageBuffer
+48 ImageMSArray
+49 StorageImageExtendedFormats
+50 ImageQuery
+51 DerivativeControl
+52 InterpolationFunction
+53 TransformFeedback
+54 GeometryStreams
+55 StorageImageReadWithoutFormat
+56 StorageImageWriteWithoutFormat
+57 MultiViewport
+58 SubgroupDispatch
+59 NamedBarrier
+60 PipeStorage
+4423 SubgroupBallotKHR
+4427 DrawParameters
+4431 SubgroupVoteKHR
+4433 StorageBuffer16BitAccess
+4434 UniformAndStorageBuffer16BitAccess
+4435 StoragePushConstant16
+4436 StorageInputOutput16
+4437 DeviceGroup
+4439 MultiView
+4441 VariablePointersStorageBuffer
+4442 VariablePointers
+`
+
+const imageOperands = `
+0x0 None
+0x1 Bias Id
+0x2 Lod Id
+0x4 Grad Id Id
+0x8 ConstOffset Id
+0x10 Offset Id
+0x20 ConstOffsets Id
+0x40 Sample Id
+0x80 MinLod Id
+`
+
+const fpFastMathModes = `
+0x0 None
+0x1 NotNaN
+0x2 NotInf
+0x4 NSZ
+0x8 AllowRecip
+0x10 Fast
+`
+
+const selectionControls = `
+0x0 None
+0x1 Flatten
+0x2 DontFlatten
+`
+
+const loopControls = `
+0x0 None
+0x1 Unroll
+0x2 DontUnroll
+0x4 DependencyInfinite
+0x8 DependencyLength Lit
+`
+
+const functionControls = `
+0x0 None
+0x1 Inline
+0x2 DontInline
+0x4 Pure
+0x8 Const
+`
+
+const memoryAccesses = `
+0x0 None
+0x1 Volatile
+0x2 Aligned Lit
+0x4 Nontemporal
+`
+
+// glslStd450 holds the names of the GLSL.std.450 extended instructions.
+var glslStd450 = strings.Fields(`
+_ Round RoundEven Trunc FAbs SAbs FSign SSign Floor Ceil Fract Radians
+Degrees Sin Cos Tan Asin Acos Atan Sinh Cosh Tanh Asinh Acosh Atanh Atan2 Pow
+Exp Log Exp2 Log2 Sqrt InverseSqrt Determinant MatrixInverse Modf ModfStruct
+FMin UMin SMin FMax UMax SMax FClamp UClamp SClamp FMix IMix Step SmoothStep
+Fma Frexp FrexpStruct Ldexp PackSnorm4x8 PackUnorm4x8 PackSnorm2x16
+PackUnorm2x16 PackHalf2x16 PackDouble2x32 UnpackSnorm2x16 UnpackUnorm2x16
+UnpackHalf2x16 UnpackSnorm4x8 UnpackUnorm4x8 UnpackDouble2x32 Length Distance
+Cross Normalize FaceForward Reflect Refract FindILsb FindSMsb FindUMsb
+InterpolateAtCentroid InterpolateAtSample InterpolateAtOffset NMin NMax NClamp
+`)
+
+// generators holds the names of the registered SPIR-V generator vendors.
+var generators = []string{
+	"Khronos",
+	"LunarG",
+	"Valve",
+	"Codeplay",
+	"NVIDIA",
+	"ARM",
+	"Khronos LLVM/SPIR-V Translator",
+	"Khronos SPIR-V Tools Assembler",
+	"Khronos Glslang Reference Front End",
+	"Qualcomm",
+	"AMD",
+	"Intel",
+	"Imagination",
+	"Google Shaderc over Glslang",
+	"Google spiregg",
+	"Google rspirv",
+	"X-LEGEND Mesa-IR/SPIR-V Translator",
+	"Khronos SPIR-V Tools Linker",
+}
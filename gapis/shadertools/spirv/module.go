@@ -0,0 +1,163 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package spirv implements a decoder, disassembler and assembler for SPIR-V
+// modules.
+package spirv
+
+// Module is a decoded SPIR-V module.
+type Module struct {
+	// Version is the SPIR-V version number of the module.
+	Version uint32
+	// Generator is the magic number of the tool that generated the module.
+	Generator uint32
+	// Bound is the upper bound of all the ids used by the module.
+	Bound uint32
+	// Schema is the instruction schema of the module.
+	Schema uint32
+	// Instructions are all the instructions of the module, in order.
+	Instructions []*Instruction
+
+	defs map[ID]*Instruction
+}
+
+// EntryPoint is an entry point declared by an OpEntryPoint.
+type EntryPoint struct {
+	// Model is the execution model of the entry point.
+	Model uint32
+	// Function is the id of the entry point's function.
+	Function ID
+	// Name is the name of the entry point.
+	Name string
+	// Interface are the ids of the entry point's interface variables.
+	Interface []ID
+}
+
+// Decoration is a decoration applied to an id or a structure member.
+type Decoration struct {
+	// Target is the decorated id.
+	Target ID
+	// Member is the decorated structure member, or -1 if the decoration is
+	// applied to Target itself.
+	Member int
+	// Decoration is the decoration value.
+	Decoration uint32
+	// Operands are the extra operands of the decoration.
+	Operands []Operand
+}
+
+// MajorVersion returns the major part of the module's version.
+func (m *Module) MajorVersion() int { return int(m.Version>>16) & 0xff }
+
+// MinorVersion returns the minor part of the module's version.
+func (m *Module) MinorVersion() int { return int(m.Version>>8) & 0xff }
+
+// Def returns the instruction that defines the id, or nil if the id is not
+// defined.
+func (m *Module) Def(id ID) *Instruction { return m.defs[id] }
+
+// TypeOf returns the instruction that declares the type of id, or nil if id
+// has no type.
+func (m *Module) TypeOf(id ID) *Instruction {
+	if def := m.defs[id]; def != nil {
+		return m.defs[def.ResultType]
+	}
+	return nil
+}
+
+// Name returns the debug name given to the id by an OpName, or an empty string
+// if the id has no name.
+func (m *Module) Name(id ID) string {
+	for _, i := range m.Instructions {
+		if i.Opcode == OpName && i.Operands[0].ID() == id {
+			return i.Operands[1].String()
+		}
+	}
+	return ""
+}
+
+// MemberName returns the debug name given to the member of the structure type
+// id by an OpMemberName, or an empty string if the member has no name.
+func (m *Module) MemberName(id ID, member int) string {
+	for _, i := range m.Instructions {
+		if i.Opcode == OpMemberName && i.Operands[0].ID() == id &&
+			int(i.Operands[1].Literal()) == member {
+			return i.Operands[2].String()
+		}
+	}
+	return ""
+}
+
+// EntryPoints returns all the entry points declared by the module.
+func (m *Module) EntryPoints() []EntryPoint {
+	out := []EntryPoint{}
+	for _, i := range m.Instructions {
+		if i.Opcode != OpEntryPoint {
+			continue
+		}
+		ep := EntryPoint{
+			Model:    i.Operands[0].Literal(),
+			Function: i.Operands[1].ID(),
+			Name:     i.Operands[2].String(),
+		}
+		for _, o := range i.Operands[3:] {
+			ep.Interface = append(ep.Interface, o.ID())
+		}
+		out = append(out, ep)
+	}
+	return out
+}
+
+// Decorations returns all the decorations applied to the id and its members,
+// including those applied through decoration groups.
+func (m *Module) Decorations(id ID) []Decoration {
+	out := []Decoration{}
+	add := func(target, group ID, member int, i *Instruction) {
+		switch i.Opcode {
+		case OpDecorate, OpDecorateId:
+			if i.Operands[0].ID() == group {
+				out = append(out, Decoration{target, member, i.Operands[1].Literal(), i.Operands[2:]})
+			}
+		case OpMemberDecorate:
+			if i.Operands[0].ID() == group {
+				out = append(out, Decoration{target, int(i.Operands[1].Literal()), i.Operands[2].Literal(), i.Operands[3:]})
+			}
+		}
+	}
+	for _, i := range m.Instructions {
+		add(id, id, -1, i)
+		switch i.Opcode {
+		case OpGroupDecorate:
+			for _, o := range i.Operands[1:] {
+				if o.ID() == id {
+					for _, g := range m.Instructions {
+						add(id, i.Operands[0].ID(), -1, g)
+					}
+				}
+			}
+		case OpGroupMemberDecorate:
+			for j := 1; j+1 < len(i.Operands); j += 2 {
+				if i.Operands[j].ID() == id {
+					member := int(i.Operands[j+1].Literal())
+					for _, g := range m.Instructions {
+						if g.Opcode == OpDecorate && g.Operands[0].ID() == i.Operands[0].ID() {
+							out = append(out, Decoration{id, member, g.Operands[1].Literal(), g.Operands[2:]})
+						}
+					}
+				}
+			}
+		}
+	}
+	return out
+}
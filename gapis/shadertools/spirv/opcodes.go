@@ -0,0 +1,107 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spirv
+
+import "fmt"
+
+// Opcode is a SPIR-V instruction opcode.
+type Opcode uint16
+
+// The opcodes that are referenced by this package.
+// All the other opcodes are only known by name through the grammar.
+const (
+	OpNop                 Opcode = 0
+	OpUndef               Opcode = 1
+	OpSource              Opcode = 3
+	OpSourceExtension     Opcode = 4
+	OpName                Opcode = 5
+	OpMemberName          Opcode = 6
+	OpString              Opcode = 7
+	OpExtension           Opcode = 10
+	OpExtInstImport       Opcode = 11
+	OpExtInst             Opcode = 12
+	OpMemoryModel         Opcode = 14
+	OpEntryPoint          Opcode = 15
+	OpExecutionMode       Opcode = 16
+	OpCapability          Opcode = 17
+	OpTypeVoid            Opcode = 19
+	OpTypeBool            Opcode = 20
+	OpTypeInt             Opcode = 21
+	OpTypeFloat           Opcode = 22
+	OpTypeVector          Opcode = 23
+	OpTypeMatrix          Opcode = 24
+	OpTypeImage           Opcode = 25
+	OpTypeSampler         Opcode = 26
+	OpTypeSampledImage    Opcode = 27
+	OpTypeArray           Opcode = 28
+	OpTypeRuntimeArray    Opcode = 29
+	OpTypeStruct          Opcode = 30
+	OpTypeOpaque          Opcode = 31
+	OpTypePointer         Opcode = 32
+	OpTypeFunction        Opcode = 33
+	OpTypeEvent           Opcode = 34
+	OpTypeDeviceEvent     Opcode = 35
+	OpTypeReserveId       Opcode = 36
+	OpTypeQueue           Opcode = 37
+	OpTypePipe            Opcode = 38
+	OpTypeForwardPointer  Opcode = 39
+	OpConstantTrue        Opcode = 41
+	OpConstantFalse       Opcode = 42
+	OpConstant            Opcode = 43
+	OpConstantComposite   Opcode = 44
+	OpConstantNull        Opcode = 46
+	OpSpecConstantTrue    Opcode = 48
+	OpSpecConstantFalse   Opcode = 49
+	OpSpecConstant        Opcode = 50
+	OpSpecConstantOp      Opcode = 52
+	OpFunction            Opcode = 54
+	OpFunctionParameter   Opcode = 55
+	OpFunctionEnd         Opcode = 56
+	OpVariable            Opcode = 59
+	OpLoad                Opcode = 61
+	OpStore               Opcode = 62
+	OpDecorate            Opcode = 71
+	OpMemberDecorate      Opcode = 72
+	OpDecorationGroup     Opcode = 73
+	OpGroupDecorate       Opcode = 74
+	OpGroupMemberDecorate Opcode = 75
+	OpLabel               Opcode = 248
+	OpSwitch              Opcode = 251
+	OpReturn              Opcode = 253
+	OpTypePipeStorage     Opcode = 322
+	OpTypeNamedBarrier    Opcode = 327
+	OpDecorateId          Opcode = 332
+)
+
+func (o Opcode) String() string {
+	if info, ok := instructions[o]; ok {
+		return "Op" + info.name
+	}
+	return fmt.Sprintf("Op%d", uint16(o))
+}
+
+// IsType returns true if the opcode declares a type.
+func (o Opcode) IsType() bool {
+	switch o {
+	case OpTypeVoid, OpTypeBool, OpTypeInt, OpTypeFloat, OpTypeVector,
+		OpTypeMatrix, OpTypeImage, OpTypeSampler, OpTypeSampledImage,
+		OpTypeArray, OpTypeRuntimeArray, OpTypeStruct, OpTypeOpaque,
+		OpTypePointer, OpTypeFunction, OpTypeEvent, OpTypeDeviceEvent,
+		OpTypeReserveId, OpTypeQueue, OpTypePipe, OpTypePipeStorage,
+		OpTypeNamedBarrier:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,313 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spirv
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// MagicNumber is the first word of every SPIR-V module.
+const MagicNumber = 0x07230203
+
+// headerWords is the number of words in the module header.
+const headerWords = 5
+
+// ID is a SPIR-V result identifier.
+type ID uint32
+
+// Operand is a single decoded operand of an instruction.
+type Operand struct {
+	// Kind is the kind of the operand.
+	Kind OperandKind
+	// Words holds the words that encode the operand.
+	Words []uint32
+}
+
+// Instruction is a single decoded SPIR-V instruction.
+type Instruction struct {
+	// Opcode is the instruction's opcode.
+	Opcode Opcode
+	// ResultType is the id of the type of the result, or 0 if the instruction
+	// has no result type.
+	ResultType ID
+	// Result is the id of the result, or 0 if the instruction has no result.
+	Result ID
+	// Operands are the decoded operands of the instruction, excluding the
+	// result type and result id.
+	Operands []Operand
+	// Words are all the words of the instruction, including the first word
+	// holding the opcode and word count.
+	Words []uint32
+}
+
+// ID returns the operand as an id.
+func (o Operand) ID() ID { return ID(o.Words[0]) }
+
+// Literal returns the first word of the operand.
+func (o Operand) Literal() uint32 { return o.Words[0] }
+
+// Literal64 returns the operand as a 64-bit literal. Single word operands are
+// zero extended.
+func (o Operand) Literal64() uint64 {
+	if len(o.Words) > 1 {
+		return uint64(o.Words[0]) | uint64(o.Words[1])<<32
+	}
+	return uint64(o.Words[0])
+}
+
+// String returns the operand decoded as a literal string.
+func (o Operand) String() string {
+	bytes := make([]byte, 0, len(o.Words)*4)
+	for _, w := range o.Words {
+		for i := uint(0); i < 4; i++ {
+			b := byte(w >> (i * 8))
+			if b == 0 {
+				return string(bytes)
+			}
+			bytes = append(bytes, b)
+		}
+	}
+	return string(bytes)
+}
+
+// ParseBytes decodes the SPIR-V module held in data.
+func ParseBytes(data []byte) (*Module, error) {
+	if len(data)%4 != 0 {
+		return nil, fmt.Errorf("SPIR-V binary size (%d bytes) is not a multiple of 4", len(data))
+	}
+	words := make([]uint32, len(data)/4)
+	for i := range words {
+		words[i] = binary.LittleEndian.Uint32(data[i*4:])
+	}
+	return Parse(words)
+}
+
+// Parse decodes the SPIR-V module held in words.
+func Parse(words []uint32) (*Module, error) {
+	if len(words) < headerWords {
+		return nil, fmt.Errorf("SPIR-V binary is too short (%d words)", len(words))
+	}
+	switch {
+	case words[0] == MagicNumber:
+	case swap(words[0]) == MagicNumber:
+		swapped := make([]uint32, len(words))
+		for i, w := range words {
+			swapped[i] = swap(w)
+		}
+		words = swapped
+	default:
+		return nil, fmt.Errorf("Invalid SPIR-V magic number 0x%.8x", words[0])
+	}
+
+	m := &Module{
+		Version:   words[1],
+		Generator: words[2],
+		Bound:     words[3],
+		Schema:    words[4],
+		defs:      map[ID]*Instruction{},
+	}
+
+	for i := headerWords; i < len(words); {
+		count, opcode := int(words[i]>>16), Opcode(words[i]&0xffff)
+		if count == 0 {
+			return nil, fmt.Errorf("Instruction at word %d has a word count of 0", i)
+		}
+		if i+count > len(words) {
+			return nil, fmt.Errorf("Instruction %v at word %d has %d words, but only %d remain",
+				opcode, i, count, len(words)-i)
+		}
+		inst := &Instruction{Opcode: opcode, Words: words[i : i+count]}
+		if err := m.decode(inst); err != nil {
+			return nil, fmt.Errorf("Instruction %v at word %d: %v", opcode, i, err)
+		}
+		if inst.Result != 0 {
+			m.defs[inst.Result] = inst
+		}
+		m.Instructions = append(m.Instructions, inst)
+		i += count
+	}
+	return m, nil
+}
+
+func swap(w uint32) uint32 {
+	return w>>24 | (w>>8)&0xff00 | (w<<8)&0xff0000 | w<<24
+}
+
+// decoder decodes the operands of a single instruction.
+type decoder struct {
+	m     *Module
+	inst  *Instruction
+	words []uint32
+	pos   int
+}
+
+func (m *Module) decode(inst *Instruction) error {
+	info, ok := instructions[inst.Opcode]
+	if !ok {
+		// Unknown instruction. Keep all the operands as raw literals.
+		for _, w := range inst.Words[1:] {
+			inst.Operands = append(inst.Operands, Operand{KindLiteralInteger, []uint32{w}})
+		}
+		return nil
+	}
+	d := &decoder{m: m, inst: inst, words: inst.Words[1:]}
+	if err := d.decode(info.operands); err != nil {
+		return err
+	}
+	if d.pos != len(d.words) {
+		return fmt.Errorf("%d unexpected trailing words", len(d.words)-d.pos)
+	}
+	return nil
+}
+
+func (d *decoder) decode(specs []operandSpec) error {
+	for _, s := range specs {
+		switch s.quantifier {
+		case one:
+			if d.pos >= len(d.words) {
+				return fmt.Errorf("Missing operand")
+			}
+			if err := d.operand(s.kind); err != nil {
+				return err
+			}
+		case optional:
+			if d.pos < len(d.words) {
+				if err := d.operand(s.kind); err != nil {
+					return err
+				}
+			}
+		case variadic:
+			for d.pos < len(d.words) {
+				if err := d.operand(s.kind); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (d *decoder) take(kind OperandKind, count int) error {
+	if d.pos+count > len(d.words) {
+		return fmt.Errorf("Operand extends past the end of the instruction")
+	}
+	d.inst.Operands = append(d.inst.Operands, Operand{kind, d.words[d.pos : d.pos+count]})
+	d.pos += count
+	return nil
+}
+
+func (d *decoder) operand(kind OperandKind) error {
+	switch kind {
+	case KindIdResultType:
+		d.inst.ResultType = ID(d.words[d.pos])
+		d.pos++
+		return nil
+	case KindIdResult:
+		d.inst.Result = ID(d.words[d.pos])
+		d.pos++
+		return nil
+	case KindLiteralString:
+		for i := d.pos; i < len(d.words); i++ {
+			if w := d.words[i]; w&0xff == 0 || w&0xff00 == 0 || w&0xff0000 == 0 || w&0xff000000 == 0 {
+				return d.take(kind, i-d.pos+1)
+			}
+		}
+		return fmt.Errorf("Unterminated literal string")
+	case KindLiteralNumber:
+		return d.take(kind, d.literalWords(d.inst.ResultType))
+	case KindSpecConstantOpNumber:
+		op := Opcode(d.words[d.pos])
+		if err := d.take(kind, 1); err != nil {
+			return err
+		}
+		info, ok := instructions[op]
+		if !ok {
+			return fmt.Errorf("Unknown OpSpecConstantOp opcode %d", uint16(op))
+		}
+		specs := []operandSpec{}
+		for _, s := range info.operands {
+			if s.kind != KindIdResultType && s.kind != KindIdResult {
+				specs = append(specs, s)
+			}
+		}
+		return d.decode(specs)
+	case KindPairLiteralIdRef:
+		// Only used by OpSwitch, where the literal has the width of the
+		// selector's type.
+		selector := ID(0)
+		if len(d.inst.Operands) > 0 {
+			if def := d.m.defs[d.inst.Operands[0].ID()]; def != nil {
+				selector = def.ResultType
+			}
+		}
+		if err := d.take(KindLiteralNumber, d.literalWords(selector)); err != nil {
+			return err
+		}
+		return d.take(KindIdRef, 1)
+	case KindPairIdRefLiteral:
+		if err := d.take(KindIdRef, 1); err != nil {
+			return err
+		}
+		return d.take(KindLiteralInteger, 1)
+	case KindPairIdRefIdRef:
+		if err := d.take(KindIdRef, 1); err != nil {
+			return err
+		}
+		return d.take(KindIdRef, 1)
+	}
+
+	enum, ok := enums[kind]
+	if !ok {
+		return d.take(kind, 1)
+	}
+	value := d.words[d.pos]
+	if err := d.take(kind, 1); err != nil {
+		return err
+	}
+	params := []OperandKind{}
+	if enum.mask {
+		for bit := uint(0); bit < 32; bit++ {
+			if v, ok := enum.values[1<<bit]; ok && value&(1<<bit) != 0 {
+				params = append(params, v.params...)
+			}
+		}
+	} else if v, ok := enum.values[value]; ok {
+		params = v.params
+	}
+	for _, p := range params {
+		if d.pos >= len(d.words) {
+			return fmt.Errorf("Missing %v parameter", enum.name)
+		}
+		if err := d.operand(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// literalWords returns the number of words used by a literal number of the
+// given type.
+func (d *decoder) literalWords(ty ID) int {
+	if def := d.m.defs[ty]; def != nil && len(def.Words) > 2 {
+		switch def.Opcode {
+		case OpTypeInt, OpTypeFloat:
+			if def.Words[2] > 32 {
+				return int(def.Words[2]+31) / 32
+			}
+		}
+	}
+	return 1
+}
@@ -0,0 +1,206 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spirv_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/gapis/shadertools/spirv"
+)
+
+// op encodes a single instruction.
+func op(opcode spirv.Opcode, operands ...uint32) []uint32 {
+	return append([]uint32{uint32(len(operands)+1)<<16 | uint32(opcode)}, operands...)
+}
+
+// str encodes a literal string.
+func str(s string) []uint32 {
+	b := append([]byte(s), make([]byte, 4-len(s)%4)...)
+	out := make([]uint32, len(b)/4)
+	for i := range out {
+		out[i] = uint32(b[i*4]) | uint32(b[i*4+1])<<8 | uint32(b[i*4+2])<<16 | uint32(b[i*4+3])<<24
+	}
+	return out
+}
+
+func cat(parts ...[]uint32) []uint32 {
+	out := []uint32{}
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+// module is the binary of the following GLSL fragment shader:
+//
+//	layout(location = 0) out float color;
+//	void main() { color = -1.5; }
+var module = cat(
+	[]uint32{spirv.MagicNumber, 0x10000, 0x80001, 12, 0},
+	op(spirv.OpCapability, 1),
+	op(spirv.OpExtInstImport, cat([]uint32{1}, str("GLSL.std.450"))...),
+	op(spirv.OpMemoryModel, 0, 1),
+	op(spirv.OpEntryPoint, cat([]uint32{4, 2}, str("main"), []uint32{9})...),
+	op(spirv.OpExecutionMode, 2, 7),
+	op(spirv.OpName, cat([]uint32{2}, str("main"))...),
+	op(spirv.OpName, cat([]uint32{9}, str("color"))...),
+	op(spirv.OpDecorate, 9, 30, 0),
+	op(spirv.OpTypeVoid, 3),
+	op(spirv.OpTypeFunction, 4, 3),
+	op(spirv.OpTypeFloat, 5, 32),
+	op(spirv.OpTypePointer, 6, 3, 5),
+	op(spirv.OpConstant, 5, 7, 0xbfc00000),
+	op(spirv.OpVariable, 6, 9, 3),
+	op(spirv.OpFunction, 3, 2, 0, 4),
+	op(spirv.OpLabel, 10),
+	op(spirv.OpStore, 9, 7),
+	op(spirv.OpReturn),
+	op(spirv.OpFunctionEnd),
+)
+
+func TestDisassemble(t *testing.T) {
+	assert := assert.To(t)
+	got, err := spirv.Disassemble(module)
+	assert.For("err").ThatError(err).Succeeded()
+	assert.For("disassembly").ThatString(got).Equals(`; SPIR-V
+; Version: 1.0
+; Generator: Khronos Glslang Reference Front End; 1
+; Bound: 12
+; Schema: 0
+               OpCapability Shader
+          %1 = OpExtInstImport "GLSL.std.450"
+               OpMemoryModel Logical GLSL450
+               OpEntryPoint Fragment %main "main" %color
+               OpExecutionMode %main OriginUpperLeft
+               OpName %main "main"
+               OpName %color "color"
+               OpDecorate %color Location 0
+       %void = OpTypeVoid
+          %4 = OpTypeFunction %void
+      %float = OpTypeFloat 32
+%_ptr_Output_float = OpTypePointer Output %float
+ %float_n1_5 = OpConstant %float -1.5
+      %color = OpVariable %_ptr_Output_float Output
+       %main = OpFunction %void None %4
+         %10 = OpLabel
+               OpStore %color %float_n1_5
+               OpReturn
+               OpFunctionEnd
+`)
+}
+
+func TestModule(t *testing.T) {
+	assert := assert.To(t)
+	m, err := spirv.Parse(module)
+	assert.For("err").ThatError(err).Succeeded()
+	assert.For("version").That(m.MinorVersion()).Equals(0)
+	assert.For("name").That(m.Name(9)).Equals("color")
+	assert.For("type").That(m.TypeOf(9).Opcode).Equals(spirv.OpTypePointer)
+	assert.For("entry points").ThatSlice(m.EntryPoints()).DeepEquals([]spirv.EntryPoint{
+		{Model: 4, Function: 2, Name: "main", Interface: []spirv.ID{9}},
+	})
+	decorations := m.Decorations(9)
+	assert.For("decorations").That(len(decorations)).Equals(1)
+	assert.For("location").That(decorations[0].Decoration).Equals(uint32(30))
+	assert.For("member").That(decorations[0].Member).Equals(-1)
+}
+
+func TestParseErrors(t *testing.T) {
+	assert := assert.To(t)
+	for _, test := range []struct {
+		name  string
+		words []uint32
+	}{
+		{"short", []uint32{spirv.MagicNumber}},
+		{"magic", []uint32{1, 2, 3, 4, 5}},
+		{"truncated", cat(module[:5], []uint32{3<<16 | uint32(spirv.OpName), 1})},
+		{"zero count", cat(module[:5], []uint32{0})},
+		{"missing operand", cat(module[:5], op(spirv.OpTypeInt, 1))},
+	} {
+		_, err := spirv.Parse(test.words)
+		assert.For("%s", test.name).ThatError(err).Failed()
+	}
+}
+
+func TestSwappedEndianness(t *testing.T) {
+	assert := assert.To(t)
+	swapped := make([]uint32, len(module))
+	for i, w := range module {
+		swapped[i] = w>>24 | (w>>8)&0xff00 | (w<<8)&0xff0000 | w<<24
+	}
+	m, err := spirv.Parse(swapped)
+	assert.For("err").ThatError(err).Succeeded()
+	assert.For("instructions").That(len(m.Instructions)).Equals(19)
+}
+
+func TestHugeBound(t *testing.T) {
+	assert := assert.To(t)
+	huge := cat([]uint32{spirv.MagicNumber, 0x10000, 0, 0xffffffff, 0}, module[5:])
+	m, err := spirv.Parse(huge)
+	assert.For("err").ThatError(err).Succeeded()
+	assert.For("names").That(len(m.Names())).Equals(9)
+}
+
+func TestAssembleRoundTrip(t *testing.T) {
+	assert := assert.To(t)
+	m, err := spirv.Parse(module)
+	assert.For("err").ThatError(err).Succeeded()
+	got, err := spirv.Assemble(m.Disassemble(), m.Names())
+	assert.For("err").ThatError(err).Succeeded()
+	assert.For("words").ThatSlice(got).Equals(module)
+}
+
+func TestAssembleEdit(t *testing.T) {
+	assert := assert.To(t)
+	m, err := spirv.Parse(module)
+	assert.For("err").ThatError(err).Succeeded()
+	source := strings.Replace(m.Disassemble(),
+		"OpStore %color %float_n1_5",
+		"%half = OpConstant %float 0.5\n%sum = OpFAdd %float %float_n1_5 %half\nOpStore %color %sum", 1)
+	words, err := spirv.Assemble(source, m.Names())
+	assert.For("err").ThatError(err).Succeeded()
+
+	edited, err := spirv.Parse(words)
+	assert.For("err").ThatError(err).Succeeded()
+	assert.For("bound").That(edited.Bound).Equals(uint32(14))
+	assert.For("color").That(edited.Name(9)).Equals("color")
+	assert.For("main").That(edited.Def(2).Opcode).Equals(spirv.OpFunction)
+	assert.For("half").That(edited.Def(12).Opcode).Equals(spirv.OpConstant)
+	assert.For("sum").That(edited.Def(13).Opcode.String()).Equals("OpFAdd")
+	assert.For("constant").ThatSlice(edited.Def(12).Operands[0].Words).Equals([]uint32{0x3f000000})
+}
+
+func TestAssembleErrors(t *testing.T) {
+	assert := assert.To(t)
+	for _, test := range []struct {
+		name   string
+		source string
+	}{
+		{"unknown opcode", "OpFoo"},
+		{"not an opcode", "%1 = %2"},
+		{"missing operand", "%1 = OpTypeInt 32"},
+		{"missing result", "OpTypeVoid"},
+		{"unexpected result", "%1 = OpReturn"},
+		{"extra operand", "OpReturn %1"},
+		{"bad enum", "OpCapability Teleport"},
+		{"bad number", "%1 = OpTypeFloat 32\n%2 = OpConstant %1 pi"},
+		{"bad string", `OpSourceExtension "foo`},
+	} {
+		_, err := spirv.Assemble(test.source, nil)
+		assert.For("%s", test.name).ThatError(err).Failed()
+	}
+}
@@ -19,9 +19,12 @@
 
 set(files
     load.go
+    locale.go
+    locale_test.go
     msg.go
     stringtable.pb.go
     stringtable.proto
+    validate.go
     value.go
 )
 set(dirs
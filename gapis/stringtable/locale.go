@@ -0,0 +1,82 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stringtable
+
+import "strings"
+
+// DefaultCultureCode is the culture code of the table that is used when no
+// other table holds a message.
+const DefaultCultureCode = "en-us"
+
+// NormalizeCultureCode returns the culture code c in the lower-case, dash
+// separated form used by the string tables. For example "en_US.UTF-8"
+// becomes "en-us".
+func NormalizeCultureCode(c string) string {
+	if i := strings.IndexAny(c, ".@"); i >= 0 {
+		c = c[:i] // Strip POSIX locale encoding and modifier.
+	}
+	return strings.ToLower(strings.Replace(c, "_", "-", -1))
+}
+
+// language returns the language part of the culture code c.
+// For example "de-at" returns "de".
+func language(c string) string {
+	if i := strings.Index(c, "-"); i >= 0 {
+		return c[:i]
+	}
+	return c
+}
+
+// Negotiate returns the table that best matches the list of culture codes in
+// preferred, which is ordered from most to least preferred.
+// A table with an identical culture code is preferred over a table that only
+// shares the language. If no table matches any of the preferred culture codes
+// then nil is returned.
+func Negotiate(tables []*StringTable, preferred []string) *StringTable {
+	for _, p := range preferred {
+		p = NormalizeCultureCode(p)
+		for _, t := range tables {
+			if NormalizeCultureCode(t.Info.CultureCode) == p {
+				return t
+			}
+		}
+		for _, t := range tables {
+			if language(NormalizeCultureCode(t.Info.CultureCode)) == language(p) {
+				return t
+			}
+		}
+	}
+	return nil
+}
+
+// WithFallback returns a string table holding all the entries of t, along
+// with the entries of fallback that are missing from t.
+// If fallback is nil or t then t is returned.
+func (t *StringTable) WithFallback(fallback *StringTable) *StringTable {
+	if fallback == nil || fallback == t {
+		return t
+	}
+	out := &StringTable{
+		Info:    t.Info,
+		Entries: make(map[string]*Node, len(fallback.Entries)),
+	}
+	for k, v := range fallback.Entries {
+		out.Entries[k] = v
+	}
+	for k, v := range t.Entries {
+		out.Entries[k] = v
+	}
+	return out
+}
@@ -0,0 +1,106 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stringtable_test
+
+import (
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	st "github.com/google/gapid/gapis/stringtable"
+)
+
+var (
+	T = func(s string) *st.Node { return &st.Node{Node: &st.Node_Text{Text: &st.Text{Text: s}}} }
+	P = func(k string) *st.Node { return &st.Node{Node: &st.Node_Parameter{Parameter: &st.Parameter{Key: k}}} }
+	B = func(c ...*st.Node) *st.Node { return &st.Node{Node: &st.Node_Block{Block: &st.Block{Children: c}}} }
+
+	enUS = &st.StringTable{
+		Info: &st.Info{CultureCode: "en-us"},
+		Entries: map[string]*st.Node{
+			"HELLO":   B(T("Hello "), P("name")),
+			"GOODBYE": T("Goodbye"),
+		},
+	}
+	enGB = &st.StringTable{
+		Info: &st.Info{CultureCode: "en-gb"},
+		Entries: map[string]*st.Node{
+			"HELLO": B(T("Hello "), P("name")),
+		},
+	}
+	de = &st.StringTable{
+		Info: &st.Info{CultureCode: "de"},
+		Entries: map[string]*st.Node{
+			"HELLO":   B(T("Hallo "), P("person")),
+			"WELCOME": T("Willkommen"),
+		},
+	}
+	tables = []*st.StringTable{enUS, enGB, de}
+)
+
+func TestNormalizeCultureCode(t *testing.T) {
+	assert := assert.To(t)
+	for _, test := range []struct {
+		code, expected string
+	}{
+		{"en-us", "en-us"},
+		{"en_US", "en-us"},
+		{"en_US.UTF-8", "en-us"},
+		{"de_DE@euro", "de-de"},
+		{"DE", "de"},
+	} {
+		assert.For("%s", test.code).ThatString(st.NormalizeCultureCode(test.code)).Equals(test.expected)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	assert := assert.To(t)
+	for _, test := range []struct {
+		name      string
+		preferred []string
+		expected  *st.StringTable
+	}{
+		{"none", nil, nil},
+		{"exact", []string{"en-gb"}, enGB},
+		{"posix", []string{"en_GB.UTF-8"}, enGB},
+		{"language", []string{"de-at"}, de},
+		{"order", []string{"fr", "de", "en-us"}, de},
+		{"no match", []string{"fr", "ja"}, nil},
+	} {
+		assert.For("%s", test.name).That(st.Negotiate(tables, test.preferred)).Equals(test.expected)
+	}
+}
+
+func TestWithFallback(t *testing.T) {
+	assert := assert.To(t)
+	got := de.WithFallback(enUS)
+	assert.For("info").That(got.Info).Equals(de.Info)
+	assert.For("overridden").That(got.Entries["HELLO"]).Equals(de.Entries["HELLO"])
+	assert.For("fallback").That(got.Entries["GOODBYE"]).Equals(enUS.Entries["GOODBYE"])
+	assert.For("extra").That(got.Entries["WELCOME"]).Equals(de.Entries["WELCOME"])
+	assert.For("self").That(enUS.WithFallback(enUS)).Equals(enUS)
+	assert.For("nil").That(enUS.WithFallback(nil)).Equals(enUS)
+}
+
+func TestValidate(t *testing.T) {
+	assert := assert.To(t)
+	got := st.Validate(enUS, tables)
+	expected := []st.Problem{
+		{CultureCode: "en-gb", Key: "GOODBYE", Error: false, Message: "Entry is missing"},
+		{CultureCode: "de", Key: "GOODBYE", Error: false, Message: "Entry is missing"},
+		{CultureCode: "de", Key: "HELLO", Error: true, Message: "Parameters [person] do not match [name] in the en-us table"},
+		{CultureCode: "de", Key: "WELCOME", Error: true, Message: "Entry is not in the en-us table"},
+	}
+	assert.For("problems").ThatSlice(got).DeepEquals(expected)
+}
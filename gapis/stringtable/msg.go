@@ -19,6 +19,8 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+
+	"github.com/google/gapid/core/data/protoutil"
 )
 
 // Text returns a plain-text representation of the message.
@@ -46,6 +48,10 @@ func (m *Msg) Text(tbl *StringTable) string {
 
 func writeNodes(n interface{}, args map[string]*Value, w *bytes.Buffer) {
 	switch n := n.(type) {
+	case *Node:
+		if n != nil {
+			writeNodes(protoutil.OneOf(n.Node), args, w)
+		}
 	case *Block:
 		for _, n := range n.Children {
 			writeNodes(n, args, w)
@@ -0,0 +1,128 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stringtable
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/google/gapid/core/data/protoutil"
+)
+
+// Problem is an inconsistency between string tables found by Validate.
+type Problem struct {
+	// The culture code of the table with the problem.
+	CultureCode string
+	// The entry key with the problem.
+	Key string
+	// If true then the problem will cause messages to be displayed
+	// incorrectly, otherwise the message will fall back to the default table.
+	Error bool
+	// The description of the problem.
+	Message string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%v: %v: %v", p.CultureCode, p.Key, p.Message)
+}
+
+// Validate checks each of the tables for consistency with the default table
+// def, returning the list of problems found. Problems are reported for:
+// • Entries of def that are missing from a table.
+// • Entries of a table that are not in def.
+// • Entries that use different parameter names to the entry in def.
+func Validate(def *StringTable, tables []*StringTable) []Problem {
+	out := []Problem{}
+	for _, t := range tables {
+		if t == def {
+			continue
+		}
+		code := t.Info.CultureCode
+		for _, key := range sortedKeys(def.Entries) {
+			if _, ok := t.Entries[key]; !ok {
+				out = append(out, Problem{code, key, false, "Entry is missing"})
+			}
+		}
+		for _, key := range sortedKeys(t.Entries) {
+			expected, ok := def.Entries[key]
+			if !ok {
+				out = append(out, Problem{code, key, true,
+					fmt.Sprintf("Entry is not in the %v table", def.Info.CultureCode)})
+				continue
+			}
+			got, expect := t.Entries[key].Parameters(), expected.Parameters()
+			if !reflect.DeepEqual(got, expect) {
+				out = append(out, Problem{code, key, true,
+					fmt.Sprintf("Parameters %v do not match %v in the %v table", got, expect, def.Info.CultureCode)})
+			}
+		}
+	}
+	return out
+}
+
+// Parameters returns the sorted, unique list of parameter keys used by the
+// node and its descendants.
+func (n *Node) Parameters() []string {
+	set := map[string]struct{}{}
+	collectParameters(n, set)
+	out := make([]string, 0, len(set))
+	for k := range set {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func collectParameters(n interface{}, set map[string]struct{}) {
+	switch n := n.(type) {
+	case *Node:
+		if n != nil {
+			collectParameters(protoutil.OneOf(n.Node), set)
+		}
+	case *Block:
+		for _, c := range n.Children {
+			collectParameters(c, set)
+		}
+	case *Parameter:
+		set[n.Key] = struct{}{}
+	case *Link:
+		collectParameters(n.Body, set)
+		collectParameters(n.Target, set)
+	case *Bold:
+		collectParameters(n.Body, set)
+	case *Italic:
+		collectParameters(n.Body, set)
+	case *Underlined:
+		collectParameters(n.Body, set)
+	case *Heading:
+		collectParameters(n.Body, set)
+	case *Code:
+		collectParameters(n.Body, set)
+	case *List:
+		for _, c := range n.Items {
+			collectParameters(c, set)
+		}
+	}
+}
+
+func sortedKeys(m map[string]*Node) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}